@@ -0,0 +1,249 @@
+// Code generated by protoc-gen-go-grpc. DO NOT EDIT.
+// versions:
+// - protoc-gen-go-grpc v1.2.0
+// - protoc             v4.25.1
+// source: api/v1/auth/Auth.proto
+
+package auth
+
+import (
+	context "context"
+	grpc "google.golang.org/grpc"
+	codes "google.golang.org/grpc/codes"
+	status "google.golang.org/grpc/status"
+)
+
+// This is a compile-time assertion to ensure that this generated file
+// is compatible with the grpc package it is being compiled against.
+// Requires gRPC-Go v1.32.0 or later.
+const _ = grpc.SupportPackageIsVersion7
+
+// AuthManagementClient is the client API for AuthManagement service.
+//
+// For semantics around ctx use and closing/ending streaming RPCs, please refer to https://pkg.go.dev/google.golang.org/grpc/?tab=doc#ClientConn.NewStream.
+type AuthManagementClient interface {
+	ValidateToken(ctx context.Context, in *Jwtoken, opts ...grpc.CallOption) (*ValidateTokenResponse, error)
+	GetTokenClaims(ctx context.Context, in *Jwtoken, opts ...grpc.CallOption) (*GetTokenClaimsResponse, error)
+	CheckPermission(ctx context.Context, in *CheckPermissionRequest, opts ...grpc.CallOption) (*CheckPermissionResponse, error)
+	RefreshToken(ctx context.Context, in *Jwtoken, opts ...grpc.CallOption) (*RefreshTokenResponse, error)
+	ValidateAPIKey(ctx context.Context, in *APIKey, opts ...grpc.CallOption) (*ValidateAPIKeyResponse, error)
+}
+
+type authManagementClient struct {
+	cc grpc.ClientConnInterface
+}
+
+func NewAuthManagementClient(cc grpc.ClientConnInterface) AuthManagementClient {
+	return &authManagementClient{cc}
+}
+
+func (c *authManagementClient) ValidateToken(ctx context.Context, in *Jwtoken, opts ...grpc.CallOption) (*ValidateTokenResponse, error) {
+	out := new(ValidateTokenResponse)
+	err := c.cc.Invoke(ctx, "/v1_auth.AuthManagement/ValidateToken", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *authManagementClient) GetTokenClaims(ctx context.Context, in *Jwtoken, opts ...grpc.CallOption) (*GetTokenClaimsResponse, error) {
+	out := new(GetTokenClaimsResponse)
+	err := c.cc.Invoke(ctx, "/v1_auth.AuthManagement/GetTokenClaims", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *authManagementClient) CheckPermission(ctx context.Context, in *CheckPermissionRequest, opts ...grpc.CallOption) (*CheckPermissionResponse, error) {
+	out := new(CheckPermissionResponse)
+	err := c.cc.Invoke(ctx, "/v1_auth.AuthManagement/CheckPermission", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *authManagementClient) RefreshToken(ctx context.Context, in *Jwtoken, opts ...grpc.CallOption) (*RefreshTokenResponse, error) {
+	out := new(RefreshTokenResponse)
+	err := c.cc.Invoke(ctx, "/v1_auth.AuthManagement/RefreshToken", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *authManagementClient) ValidateAPIKey(ctx context.Context, in *APIKey, opts ...grpc.CallOption) (*ValidateAPIKeyResponse, error) {
+	out := new(ValidateAPIKeyResponse)
+	err := c.cc.Invoke(ctx, "/v1_auth.AuthManagement/ValidateAPIKey", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+// AuthManagementServer is the server API for AuthManagement service.
+// All implementations must embed UnimplementedAuthManagementServer
+// for forward compatibility
+type AuthManagementServer interface {
+	ValidateToken(context.Context, *Jwtoken) (*ValidateTokenResponse, error)
+	GetTokenClaims(context.Context, *Jwtoken) (*GetTokenClaimsResponse, error)
+	CheckPermission(context.Context, *CheckPermissionRequest) (*CheckPermissionResponse, error)
+	RefreshToken(context.Context, *Jwtoken) (*RefreshTokenResponse, error)
+	ValidateAPIKey(context.Context, *APIKey) (*ValidateAPIKeyResponse, error)
+	mustEmbedUnimplementedAuthManagementServer()
+}
+
+// UnimplementedAuthManagementServer must be embedded to have forward compatible implementations.
+type UnimplementedAuthManagementServer struct {
+}
+
+func (UnimplementedAuthManagementServer) ValidateToken(context.Context, *Jwtoken) (*ValidateTokenResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method ValidateToken not implemented")
+}
+func (UnimplementedAuthManagementServer) GetTokenClaims(context.Context, *Jwtoken) (*GetTokenClaimsResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method GetTokenClaims not implemented")
+}
+func (UnimplementedAuthManagementServer) CheckPermission(context.Context, *CheckPermissionRequest) (*CheckPermissionResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method CheckPermission not implemented")
+}
+func (UnimplementedAuthManagementServer) RefreshToken(context.Context, *Jwtoken) (*RefreshTokenResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method RefreshToken not implemented")
+}
+func (UnimplementedAuthManagementServer) ValidateAPIKey(context.Context, *APIKey) (*ValidateAPIKeyResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method ValidateAPIKey not implemented")
+}
+func (UnimplementedAuthManagementServer) mustEmbedUnimplementedAuthManagementServer() {}
+
+// UnsafeAuthManagementServer may be embedded to opt out of forward compatibility for this service.
+// Use of this interface is not recommended, as added methods to AuthManagementServer will
+// result in compilation errors.
+type UnsafeAuthManagementServer interface {
+	mustEmbedUnimplementedAuthManagementServer()
+}
+
+func RegisterAuthManagementServer(s grpc.ServiceRegistrar, srv AuthManagementServer) {
+	s.RegisterService(&AuthManagement_ServiceDesc, srv)
+}
+
+func _AuthManagement_ValidateToken_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(Jwtoken)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(AuthManagementServer).ValidateToken(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/v1_auth.AuthManagement/ValidateToken",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(AuthManagementServer).ValidateToken(ctx, req.(*Jwtoken))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _AuthManagement_GetTokenClaims_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(Jwtoken)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(AuthManagementServer).GetTokenClaims(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/v1_auth.AuthManagement/GetTokenClaims",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(AuthManagementServer).GetTokenClaims(ctx, req.(*Jwtoken))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _AuthManagement_CheckPermission_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(CheckPermissionRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(AuthManagementServer).CheckPermission(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/v1_auth.AuthManagement/CheckPermission",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(AuthManagementServer).CheckPermission(ctx, req.(*CheckPermissionRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _AuthManagement_RefreshToken_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(Jwtoken)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(AuthManagementServer).RefreshToken(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/v1_auth.AuthManagement/RefreshToken",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(AuthManagementServer).RefreshToken(ctx, req.(*Jwtoken))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _AuthManagement_ValidateAPIKey_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(APIKey)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(AuthManagementServer).ValidateAPIKey(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/v1_auth.AuthManagement/ValidateAPIKey",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(AuthManagementServer).ValidateAPIKey(ctx, req.(*APIKey))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+// AuthManagement_ServiceDesc is the grpc.ServiceDesc for AuthManagement service.
+// It's only intended for direct use with grpc.RegisterService,
+// and not to be introspected or modified (even as a copy)
+var AuthManagement_ServiceDesc = grpc.ServiceDesc{
+	ServiceName: "v1_auth.AuthManagement",
+	HandlerType: (*AuthManagementServer)(nil),
+	Methods: []grpc.MethodDesc{
+		{
+			MethodName: "ValidateToken",
+			Handler:    _AuthManagement_ValidateToken_Handler,
+		},
+		{
+			MethodName: "GetTokenClaims",
+			Handler:    _AuthManagement_GetTokenClaims_Handler,
+		},
+		{
+			MethodName: "CheckPermission",
+			Handler:    _AuthManagement_CheckPermission_Handler,
+		},
+		{
+			MethodName: "RefreshToken",
+			Handler:    _AuthManagement_RefreshToken_Handler,
+		},
+		{
+			MethodName: "ValidateAPIKey",
+			Handler:    _AuthManagement_ValidateAPIKey_Handler,
+		},
+	},
+	Streams:  []grpc.StreamDesc{},
+	Metadata: "api/v1/auth/Auth.proto",
+}
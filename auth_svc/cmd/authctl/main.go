@@ -0,0 +1,359 @@
+// authctl is the operators' client of the auth_svc /v1/admin endpoints
+//
+//	authctl [-url URL] [-token TOKEN] [-o json|table] <command> [flags]
+//
+//	clients create  -id ID -domain DOMAIN [-user-id USERID]
+//	clients rotate  -id ID
+//	clients revoke  -id ID
+//	users list      [-skip N] [-limit N]
+//	users disable   -email EMAIL
+//	users delete    -email EMAIL
+//	sessions revoke -subject EMAIL|SERVICEID -role user|APIserver
+//	keys rotate
+//	debugtoken      -service SERVICEID [-scope SCOPE] [-ttl 5m]
+//	webhooks create      -url URL -events user.signup,user.deleted...
+//	webhooks list
+//	webhooks delete      -id ID
+//	webhooks deadletters [-skip N] [-limit N]
+//	webhooks replay      -id DELIVERYID
+//
+// the url and the token default to AUTHCTL_URL and AUTHCTL_TOKEN
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"sort"
+	"strings"
+	"text/tabwriter"
+	"time"
+)
+
+const (
+	urlDefault     = "http://localhost:9096"
+	timeoutDefault = 10 * time.Second
+)
+
+type client struct {
+	url   string
+	token string
+	http  *http.Client
+}
+
+func main() {
+	if err := run(os.Args[1:], os.Stdout); err != nil {
+		fmt.Fprintln(os.Stderr, "authctl:", err)
+		os.Exit(1)
+	}
+}
+
+func run(args []string, out io.Writer) error {
+	fs := flag.NewFlagSet("authctl", flag.ContinueOnError)
+	url := fs.String("url", envOr("AUTHCTL_URL", urlDefault), "auth_svc base url")
+	token := fs.String("token", os.Getenv("AUTHCTL_TOKEN"), "admin token")
+	output := fs.String("o", "table", "output format, json or table")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if *output != "json" && *output != "table" {
+		return fmt.Errorf("unknown output %q", *output)
+	}
+	if *token == "" {
+		return fmt.Errorf("admin token missing, set -token or AUTHCTL_TOKEN")
+	}
+
+	c := &client{
+		url:   strings.TrimRight(*url, "/"),
+		token: *token,
+		http:  &http.Client{Timeout: timeoutDefault},
+	}
+
+	rest := fs.Args()
+	if len(rest) == 0 {
+		return fmt.Errorf("command missing")
+	}
+
+	var (
+		result []byte
+		err    error
+	)
+	switch rest[0] {
+	case "clients":
+		result, err = c.clients(rest[1:])
+	case "users":
+		result, err = c.users(rest[1:])
+	case "sessions":
+		result, err = c.sessions(rest[1:])
+	case "keys":
+		result, err = c.keys(rest[1:])
+	case "debugtoken":
+		result, err = c.debugToken(rest[1:])
+	case "webhooks":
+		result, err = c.webhooks(rest[1:])
+	default:
+		return fmt.Errorf("unknown command %q", rest[0])
+	}
+	if err != nil {
+		return err
+	}
+
+	return render(out, result, *output)
+}
+
+func (c *client) clients(args []string) ([]byte, error) {
+	if len(args) == 0 {
+		return nil, fmt.Errorf("clients: create, rotate or revoke")
+	}
+
+	fs := flag.NewFlagSet("clients "+args[0], flag.ContinueOnError)
+	id := fs.String("id", "", "client id")
+	domain := fs.String("domain", "", "client domain(create only)")
+	userID := fs.String("user-id", "", "client user id(create only), default to the id")
+	if err := fs.Parse(args[1:]); err != nil {
+		return nil, err
+	}
+	if *id == "" {
+		return nil, fmt.Errorf("clients %v: -id is required", args[0])
+	}
+
+	payload := map[string]interface{}{"id": *id}
+	switch args[0] {
+	case "create":
+		payload["domain"] = *domain
+		payload["user_id"] = *userID
+		return c.do(http.MethodPost, "/v1/admin/clients", payload)
+	case "rotate":
+		return c.do(http.MethodPost, "/v1/admin/clients/rotate", payload)
+	case "revoke":
+		return c.do(http.MethodPost, "/v1/admin/clients/revoke", payload)
+	}
+	return nil, fmt.Errorf("clients: unknown command %q", args[0])
+}
+
+func (c *client) users(args []string) ([]byte, error) {
+	if len(args) == 0 {
+		return nil, fmt.Errorf("users: list, disable or delete")
+	}
+
+	fs := flag.NewFlagSet("users "+args[0], flag.ContinueOnError)
+	skip := fs.Int("skip", 0, "number of users to skip(list only)")
+	limit := fs.Int("limit", 50, "max number of users(list only)")
+	email := fs.String("email", "", "user email(disable and delete only)")
+	if err := fs.Parse(args[1:]); err != nil {
+		return nil, err
+	}
+
+	switch args[0] {
+	case "list":
+		return c.do(http.MethodGet, fmt.Sprintf("/v1/admin/users?skip=%d&limit=%d", *skip, *limit), nil)
+	case "disable":
+		if *email == "" {
+			return nil, fmt.Errorf("users disable: -email is required")
+		}
+		return c.do(http.MethodPost, "/v1/admin/users/disable", map[string]interface{}{"email": *email})
+	case "delete":
+		if *email == "" {
+			return nil, fmt.Errorf("users delete: -email is required")
+		}
+		return c.do(http.MethodPost, "/v1/admin/users/delete", map[string]interface{}{"email": *email})
+	}
+	return nil, fmt.Errorf("users: unknown command %q", args[0])
+}
+
+func (c *client) sessions(args []string) ([]byte, error) {
+	if len(args) == 0 || args[0] != "revoke" {
+		return nil, fmt.Errorf("sessions: revoke")
+	}
+
+	fs := flag.NewFlagSet("sessions revoke", flag.ContinueOnError)
+	subject := fs.String("subject", "", "user email or service id")
+	role := fs.String("role", "user", "user or APIserver")
+	if err := fs.Parse(args[1:]); err != nil {
+		return nil, err
+	}
+	if *subject == "" {
+		return nil, fmt.Errorf("sessions revoke: -subject is required")
+	}
+
+	return c.do(http.MethodPost, "/v1/admin/sessions/revoke", map[string]interface{}{
+		"subject": *subject,
+		"role":    *role,
+	})
+}
+
+func (c *client) keys(args []string) ([]byte, error) {
+	if len(args) == 0 || args[0] != "rotate" {
+		return nil, fmt.Errorf("keys: rotate")
+	}
+	return c.do(http.MethodPost, "/v1/admin/keys/rotate", nil)
+}
+
+func (c *client) debugToken(args []string) ([]byte, error) {
+	fs := flag.NewFlagSet("debugtoken", flag.ContinueOnError)
+	service := fs.String("service", "", "service id the token is minted for")
+	scope := fs.String("scope", "read", "token scope")
+	ttl := fs.Duration("ttl", 5*time.Minute, "token lifetime, capped by the server")
+	if err := fs.Parse(args); err != nil {
+		return nil, err
+	}
+	if *service == "" {
+		return nil, fmt.Errorf("debugtoken: -service is required")
+	}
+
+	return c.do(http.MethodPost, "/v1/admin/debugtoken", map[string]interface{}{
+		"service":     *service,
+		"scope":       *scope,
+		"ttl_seconds": int(ttl.Seconds()),
+	})
+}
+
+func (c *client) webhooks(args []string) ([]byte, error) {
+	if len(args) == 0 {
+		return nil, fmt.Errorf("webhooks: create, list, delete, deadletters or replay")
+	}
+
+	fs := flag.NewFlagSet("webhooks "+args[0], flag.ContinueOnError)
+	id := fs.String("id", "", "subscription id(delete) or delivery id(replay)")
+	url := fs.String("url", "", "endpoint the events are posted to(create only)")
+	events := fs.String("events", "", "comma separated events(create only)")
+	skip := fs.Int("skip", 0, "number of dead letters to skip(deadletters only)")
+	limit := fs.Int("limit", 50, "max number of dead letters(deadletters only)")
+	if err := fs.Parse(args[1:]); err != nil {
+		return nil, err
+	}
+
+	switch args[0] {
+	case "create":
+		if *url == "" || *events == "" {
+			return nil, fmt.Errorf("webhooks create: -url and -events are required")
+		}
+		return c.do(http.MethodPost, "/v1/admin/webhooks", map[string]interface{}{
+			"url":    *url,
+			"events": strings.Split(*events, ","),
+		})
+	case "list":
+		return c.do(http.MethodGet, "/v1/admin/webhooks", nil)
+	case "deadletters":
+		return c.do(http.MethodGet, fmt.Sprintf("/v1/admin/webhooks/deadletters?skip=%d&limit=%d", *skip, *limit), nil)
+	case "delete", "replay":
+		if *id == "" {
+			return nil, fmt.Errorf("webhooks %v: -id is required", args[0])
+		}
+		return c.do(http.MethodPost, "/v1/admin/webhooks/"+args[0], map[string]interface{}{"id": *id})
+	}
+	return nil, fmt.Errorf("webhooks: unknown command %q", args[0])
+}
+
+// do send the request and return the response body, an error status
+// is returned as an error carrying the body
+func (c *client) do(method, path string, payload interface{}) ([]byte, error) {
+	var body io.Reader
+	if payload != nil {
+		b, err := json.Marshal(payload)
+		if err != nil {
+			return nil, err
+		}
+		body = bytes.NewReader(b)
+	}
+
+	req, err := http.NewRequest(method, c.url+path, body)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Authorization", "Bearer "+c.token)
+	if payload != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+
+	resp, err := c.http.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	b, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, err
+	}
+
+	if resp.StatusCode >= http.StatusBadRequest {
+		return nil, fmt.Errorf("%v %v: %v", method, path, strings.TrimSpace(string(b)))
+	}
+
+	return b, nil
+}
+
+// render print the response as it is(json) or as a table,
+// an object is printed as key/value rows, a list as one row per item
+func render(out io.Writer, b []byte, output string) error {
+	if output == "json" {
+		var buf bytes.Buffer
+		if err := json.Indent(&buf, b, "", "  "); err != nil {
+			return err
+		}
+		_, err := fmt.Fprintln(out, buf.String())
+		return err
+	}
+
+	var v interface{}
+	if err := json.Unmarshal(b, &v); err != nil {
+		return err
+	}
+
+	tw := tabwriter.NewWriter(out, 0, 0, 2, ' ', 0)
+
+	switch data := v.(type) {
+	case map[string]interface{}:
+		fmt.Fprintln(tw, "KEY\tVALUE")
+		for _, k := range sortedKeys(data) {
+			fmt.Fprintf(tw, "%v\t%v\n", k, data[k])
+		}
+	case []interface{}:
+		if len(data) == 0 {
+			fmt.Fprintln(tw, "no result")
+			break
+		}
+		first, ok := data[0].(map[string]interface{})
+		if !ok {
+			for _, item := range data {
+				fmt.Fprintln(tw, item)
+			}
+			break
+		}
+		columns := sortedKeys(first)
+		fmt.Fprintln(tw, strings.ToUpper(strings.Join(columns, "\t")))
+		for _, item := range data {
+			row, _ := item.(map[string]interface{})
+			values := make([]string, 0, len(columns))
+			for _, col := range columns {
+				values = append(values, fmt.Sprintf("%v", row[col]))
+			}
+			fmt.Fprintln(tw, strings.Join(values, "\t"))
+		}
+	default:
+		fmt.Fprintln(tw, data)
+	}
+
+	return tw.Flush()
+}
+
+func sortedKeys(m map[string]interface{}) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+func envOr(key, def string) string {
+	if v := os.Getenv(key); v != "" {
+		return v
+	}
+	return def
+}
@@ -0,0 +1,120 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"gitlab.com/grpasr/common/tests"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+const testToken = "adminToken"
+
+// request is a request served by the fake auth_svc
+type request struct {
+	method  string
+	path    string
+	payload map[string]interface{}
+}
+
+// fakeAuthSvc serve the admin endpoints, the requests are recorded and
+// answered with resp, the requests without the admin token are rejected
+func fakeAuthSvc(t *testing.T, resp string, requests *[]request) *httptest.Server {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "Bearer "+testToken {
+			w.WriteHeader(http.StatusUnauthorized)
+			w.Write([]byte(`{"code":401,"message":"unauthorized"}`))
+			return
+		}
+		req := request{method: r.Method, path: r.URL.RequestURI()}
+		json.NewDecoder(r.Body).Decode(&req.payload)
+		*requests = append(*requests, req)
+
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(resp))
+	}))
+	t.Cleanup(srv.Close)
+	return srv
+}
+
+func TestRunClientsCreate(t *testing.T) {
+	tests.MaybeFail = tests.InitFailFunc(t)
+
+	var requests []request
+	srv := fakeAuthSvc(t, `{"id":"order","secret":"s3cret"}`, &requests)
+
+	out := &bytes.Buffer{}
+	err := run([]string{"-url", srv.URL, "-token", testToken, "-o", "json",
+		"clients", "create", "-id", "order", "-domain", "http://order"}, out)
+	tests.MaybeFail("clients_create", err,
+		tests.Expect(len(requests), 1),
+		tests.Expect(requests[0].method, http.MethodPost),
+		tests.Expect(requests[0].path, "/v1/admin/clients"),
+		tests.Expect(requests[0].payload["id"], "order"),
+		tests.Expect(requests[0].payload["domain"], "http://order"),
+		tests.Expect(strings.Contains(out.String(), `"secret": "s3cret"`), true))
+
+	err = run([]string{"-url", srv.URL, "-token", testToken, "clients", "create", "-domain", "http://order"}, out)
+	tests.MaybeFail("clients_create_no_id",
+		tests.Expect(err != nil, true),
+		tests.Expect(len(requests), 1))
+}
+
+func TestRunKeysRotate(t *testing.T) {
+	tests.MaybeFail = tests.InitFailFunc(t)
+
+	var requests []request
+	srv := fakeAuthSvc(t, `{"key_id":"4f2a"}`, &requests)
+
+	out := &bytes.Buffer{}
+	err := run([]string{"-url", srv.URL + "/", "-token", testToken, "keys", "rotate"}, out)
+	tests.MaybeFail("keys_rotate", err,
+		tests.Expect(len(requests), 1),
+		tests.Expect(requests[0].method, http.MethodPost),
+		tests.Expect(requests[0].path, "/v1/admin/keys/rotate"),
+		tests.Expect(strings.Contains(out.String(), "key_id"), true),
+		tests.Expect(strings.Contains(out.String(), "4f2a"), true))
+}
+
+func TestRunErrors(t *testing.T) {
+	tests.MaybeFail = tests.InitFailFunc(t)
+
+	var requests []request
+	srv := fakeAuthSvc(t, `{}`, &requests)
+	t.Setenv("AUTHCTL_TOKEN", "")
+
+	err := run([]string{"-url", srv.URL, "keys", "rotate"}, &bytes.Buffer{})
+	tests.MaybeFail("token_missing",
+		tests.Expect(err != nil, true),
+		tests.Expect(len(requests), 0))
+
+	// the error status is returned with the body
+	err = run([]string{"-url", srv.URL, "-token", "wrong", "keys", "rotate"}, &bytes.Buffer{})
+	tests.MaybeFail("unauthorized",
+		tests.Expect(err != nil, true),
+		tests.Expect(err != nil && strings.Contains(err.Error(), "unauthorized"), true))
+
+	err = run([]string{"-url", srv.URL, "-token", testToken, "unknown"}, &bytes.Buffer{})
+	tests.MaybeFail("unknown_command", tests.Expect(err != nil, true))
+
+	err = run([]string{"-url", srv.URL, "-token", testToken, "-o", "yaml", "keys", "rotate"}, &bytes.Buffer{})
+	tests.MaybeFail("unknown_output", tests.Expect(err != nil, true))
+}
+
+func TestRenderTable(t *testing.T) {
+	tests.MaybeFail = tests.InitFailFunc(t)
+
+	out := &bytes.Buffer{}
+	err := render(out, []byte(`[{"email":"a@b.c","disabled":false},{"email":"d@e.f","disabled":true}]`), "table")
+	lines := strings.Split(strings.TrimSpace(out.String()), "\n")
+	tests.MaybeFail("render_list", err,
+		tests.Expect(len(lines), 3),
+		tests.Expect(strings.Fields(lines[0]), []string{"DISABLED", "EMAIL"}),
+		tests.Expect(strings.Fields(lines[2]), []string{"true", "d@e.f"}))
+
+	out.Reset()
+	err = render(out, []byte(`[]`), "table")
+	tests.MaybeFail("render_empty", err, tests.Expect(strings.TrimSpace(out.String()), "no result"))
+}
@@ -1,21 +1,28 @@
 package main
 
 import (
+	"context"
 	"flag"
 	"fmt"
 	// "fmt"
 	"log"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
 	// "os"
 
 	"gitlab.com/grpasr/asonrythme/auth_svc/internal/config"
 	"gitlab.com/grpasr/asonrythme/auth_svc/internal/handlers"
-	"gitlab.com/grpasr/asonrythme/auth_svc/internal/repository"
+	"gitlab.com/grpasr/asonrythme/auth_svc/internal/mailer"
 	"gitlab.com/grpasr/asonrythme/auth_svc/internal/services"
 
 	"github.com/djedjethai/go-oauth2-openid/errors"
 	"github.com/djedjethai/go-oauth2-openid/manage"
 	"github.com/djedjethai/go-oauth2-openid/server"
 	obs "gitlab.com/grpasr/common/observability"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials"
 
 	mongo "github.com/djedjethai/mongo-openid"
 )
@@ -58,35 +65,38 @@ func main() {
 	// TODO create an endpoint to switch between dev and prod
 	obs.Logging.SetLoggingEnvToDevelopment()
 
+	// export the traces and metrics to the otel collector
+	if conf.GlbGetenv() != "localhost" {
+		shutdownObs, err := setObservability(conf)
+		if err != nil {
+			obs.Logging.NewLogHandler(obs.Logging.LLHFatal()).
+				Err(err).
+				Send()
+		}
+		defer shutdownObs()
+	}
+
 	// note that, the accessToken(and the jwt_access_token by cons) for APIServer role
-	// are set to 6 month, to avoid(for the moment)
-	// implementing the refresh token logic on the servers
+	// are set to 1 day, the servers refresh it ahead of its expiry
+	// through /v1/refreshopenid(see their tokensource). It was 180 days only
+	// because the servers could not refresh it, a leaked service jwt is now
+	// valid for a day at most, the refresh token(360 days) keep the renewal
+	// working while a server run
 	mc := manage.ManagerConfig{
 		AuthorizeCodeTokenCfgAccess:      2,
 		AuthorizeCodeTokenCfgRefresh:     24 * 15,
-		AuthorizeCodeAPIServerCfgAccess:  24 * 180,
+		AuthorizeCodeAPIServerCfgAccess:  24,
 		AuthorizeCodeAPIServerCfgRefresh: 24 * 360,
 	}
 
 	manager := manage.NewDefaultManager(mc)
 
-	// set connectionTimeout(7s) and the requestsTimeout(5s) // is optional
-	storeConfigs := mongo.NewStoreConfig(7, 7)
-
-	mongoConf := createMongoAuthConfig(conf)
-
-	fmt.Println("see mongo conf: ", mongoConf)
-
-	// use mongodb token store
-	manager.MapTokenStorage(
-		mongo.NewTokenStore(mongoConf, storeConfigs), // with timeout
-		// mongo.NewTokenStore(mongoConf), // no timeout
-	)
-
-	clientStore := mongo.NewClientStore(mongoConf, storeConfigs) // with timeout
-	// clientStore := mongo.NewClientStore(mongoConf) // no timeout
-
-	manager.MapClientStorage(clientStore)
+	// the oauth2 stores and the service domain, on mongo/redis or embedded
+	repos, clientStore, closeStorage, err := setStorage(conf, manager)
+	if err != nil {
+		log.Fatal("set storage failed: ", err)
+	}
+	defer closeStorage()
 
 	// register all app services
 	registerServices(clientStore, conf)
@@ -97,19 +107,70 @@ func main() {
 	// the token will be return as a json payload
 	srv.SetModeAPI()
 
-	// set the service domain
-	repos := repository.NewRepository(conf)
+	// apply the pending schema migrations(indexes...) before serving
+	if err := repos.MigrationsRun(); err != nil {
+		log.Fatal("migrations failed: ", err)
+	}
 
 	// set the service services
-	authService := services.NewAuthenticationService(srv, repos)
-	oauth2Service := services.NewOauth2Service(srv, repos)
-	tokenService := services.NewTokenService(srv, repos)
+	// the keyRing is shared by the instances through the storage, the
+	// first one to start save its initial key
+	keyRing := services.NewKeyRing(jwtKeyID, jwtSignedKey)
+	if err := keyRing.KeyRingLoad(context.Background(), repos); err != nil {
+		log.Fatal("load the signing keys failed: ", err)
+	}
+	pkcePolicy := setPKCEPolicy(conf)
+	signupPolicy, err := setSignupPolicy(conf)
+	if err != nil {
+		log.Fatal("set signup policy failed: ", err)
+	}
+	tenantPolicy := setTenantPolicy(conf, repos)
+	webhookService := services.NewWebhookService(repos, setWebhookConfig(conf))
+	invitationService := services.NewInvitationService(
+		repos,
+		time.Duration(conf.AdmGetInvitationTTL())*24*time.Hour)
+	authService := services.NewAuthenticationService(
+		srv,
+		repos,
+		pkcePolicy,
+		signupPolicy,
+		invitationService,
+		tenantPolicy,
+		keyRing,
+		webhookService)
+	oauth2Service := services.NewOauth2Service(srv, repos, tenantPolicy, keyRing, webhookService)
+	tokenService := services.NewTokenService(srv, repos, keyRing)
+	jwtokenService := services.NewJwtokenService(keyRing, tokenService)
+	adminService := services.NewAdminService(
+		srv,
+		repos,
+		clientStore,
+		tenantPolicy,
+		keyRing,
+		time.Duration(conf.AdmGetDebugTokenMaxTTL())*time.Minute,
+		webhookService)
+	apiKeyService := services.NewAPIKeyService(
+		repos,
+		time.Duration(conf.AdmGetAPIKeyMaxTTL())*24*time.Hour)
+	magicLinkService := services.NewMagicLinkService(
+		srv,
+		repos,
+		pkcePolicy,
+		tenantPolicy,
+		mailer.NewFileMailer(conf.MglGetMailerDir()),
+		setMagicLinkConfig(conf))
+
+	// the readiness check mongo and redis, served on http and grpc
+	healthChecker := setHealth(conf, repos)
 
 	// handlers will handle all handlers
 	authHandler := handlers.NewAuthenticationHandler(authService)
-	tokenHandler := handlers.NewTokenHandler(tokenService)
+	keySetService := services.NewKeySetService(clientStore, keyRing, conf.JwtGetKeysValidators())
+	tokenHandler := handlers.NewTokenHandler(tokenService, keySetService)
+	adminHandler := handlers.NewAdminHandler(adminService, webhookService, apiKeyService, invitationService, conf.AdmGetToken())
+	magicLinkHandler := handlers.NewMagicLinkHandler(magicLinkService)
 	// handler := handlers.NewHandlers(dumpvar, srv, repos)
-	handlersHandle := handlers.NewHandlers(authHandler, tokenHandler)
+	handlersHandle := handlers.NewHandlers(authHandler, tokenHandler, adminHandler, magicLinkHandler, healthChecker)
 
 	// set the authorization staff
 	srv.SetUserAuthorizationHandler(oauth2Service.UserAuthorizeService)
@@ -129,7 +190,46 @@ func main() {
 		log.Println("Response Error:", re.Error.Error())
 	})
 
-	handlersHandle.Run(portvar)
+	// stop on SIGINT/SIGTERM, the servers drain their requests in flight
+	// and the webhooks not delivered yet are dead lettered
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	// see the keys rotated by the other instances
+	go keyRing.Run(ctx, keyRingReloadInterval)
+
+	// deliver the identity events to the webhooks
+	webhooksDone := make(chan struct{})
+	go func() {
+		webhookService.WebhookRun(ctx)
+		close(webhooksDone)
+	}()
+
+	// serve the token operations over grpc(mTLS)
+	serverTLSConfig, err := conf.GRPCGetServerTLSConfig()
+	if err != nil {
+		log.Fatal("grpc tls config failed: ", err)
+	}
+	grpcOpts := []grpc.ServerOption{grpc.Creds(credentials.NewTLS(serverTLSConfig))}
+	if conf.GlbGetenv() != "localhost" {
+		grpcOpts = append(grpcOpts, grpc.UnaryInterceptor(obs.Tracing.GRPCTraceInterceptorServer))
+	}
+	grpcServer := handlers.NewAuthGrpcServer(jwtokenService, apiKeyService, healthChecker, grpcOpts...)
+	go handlers.GrpcListen(grpcServer, conf.GRPCGetPort())
+
+	// refresh the grpc health state, set not ready once ctx is done
+	go healthChecker.HealthWatch(ctx, time.Duration(conf.HTTPGetHealthInterval())*time.Second)
+
+	if err := handlersHandle.Run(ctx, setServerConfig(conf, portvar)); err != nil {
+		log.Println("http server failed: ", err)
+		stop()
+	}
+
+	grpcServer.GracefulStop()
+	<-webhooksDone
+
+	obs.Logging.NewLogHandler(obs.Logging.LLHInfo()).
+		Msg("auth_svc stopped")
 
 }
 
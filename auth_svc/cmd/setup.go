@@ -1,16 +1,199 @@
 package main
 
 import (
+	"context"
+	"github.com/djedjethai/go-oauth2-openid/manage"
 	"github.com/djedjethai/go-oauth2-openid/models"
+	"github.com/djedjethai/go-oauth2-openid/store"
 	mongo "github.com/djedjethai/mongo-openid"
 	"gitlab.com/grpasr/asonrythme/auth_svc/internal/config"
+	"gitlab.com/grpasr/asonrythme/auth_svc/internal/handlers"
+	"gitlab.com/grpasr/asonrythme/auth_svc/internal/repository"
+	"gitlab.com/grpasr/asonrythme/auth_svc/internal/services"
+	"gitlab.com/grpasr/asonrythme/shared/health"
+	obs "gitlab.com/grpasr/common/observability"
+	"time"
 )
 
+// jwtKeyID and jwtSignedKey are the first key of the keyRing if the
+// storage has none yet, they are replaced on /v1/admin/keys/rotate
+const (
+	jwtKeyID     = "theKeyID"
+	jwtSignedKey = "mySecretKey"
+)
+
+// keyRingReloadInterval is the delay for an instance to sign with a key
+// rotated by an other one, the validation see it at once
+const keyRingReloadInterval = time.Minute
+
 func setConfigs() (*config.Config, error) {
 	return config.SetConfigs()
 }
 
-func registerServices(clientStore *mongo.ClientStore, conf *config.Config) {
+// setObservability export the traces and the metrics to the otel collector(mTLS),
+// the returned func flush and stop the exporters
+func setObservability(conf *config.Config) (func(), error) {
+	ctx := context.Background()
+
+	clientTLSConfig, err := conf.GRPCGetClientTLSConfig(conf.OBSGetCollectorHost())
+	if err != nil {
+		return nil, err
+	}
+
+	tp, err := obs.Tracing.SetupTracing(
+		ctx,
+		clientTLSConfig,
+		conf.OBSGetSampling(),
+		conf.GlbGetServiceName(),
+		conf.OBSGetCollectorEndpoint(),
+		conf.GlbGetenv())
+	if err != nil {
+		return nil, err
+	}
+
+	mp, err := obs.Metrics.SetupMetrics(
+		ctx,
+		clientTLSConfig,
+		conf.OBSGetScratchDelay(),
+		conf.GlbGetServiceName(),
+		conf.OBSGetCollectorEndpoint(),
+		conf.GlbGetenv())
+	if err != nil {
+		tp.Shutdown(ctx)
+		return nil, err
+	}
+
+	return func() {
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		tp.Shutdown(ctx)
+		mp.Shutdown(ctx)
+	}, nil
+}
+
+// setPKCEPolicy map each registered client_id to its code_challenge_method
+func setPKCEPolicy(conf *config.Config) *services.PKCEPolicy {
+	methods := make(map[string]string)
+	for _, svc := range conf.SVCGetServices() {
+		methods[svc.SVCGetID()] = svc.SVCGetCodeChallengeMethod()
+	}
+	return services.NewPKCEPolicy(methods)
+}
+
+// setSignupPolicy set the password and email rules of the signups, and
+// whether they require an invitation
+func setSignupPolicy(conf *config.Config) (*services.SignupPolicy, error) {
+	return services.NewSignupPolicy(services.SignupPolicyConfig{
+		Open:              conf.SgnIsOpen(),
+		PasswordMinLength: conf.SgnGetPasswordMinLength(),
+		PasswordClasses:   conf.SgnGetPasswordCharacterClasses(),
+		BreachedFile:      conf.SgnGetPasswordBreachedFile(),
+		AllowedDomains:    conf.SgnGetAllowedDomains(),
+		DeniedDomains:     conf.SgnGetDeniedDomains(),
+		BlockDisposable:   conf.SgnIsBlockDisposable(),
+		DisposableFile:    conf.SgnGetDisposableFile(),
+	})
+}
+
+// setTenantPolicy map each registered client_id to its tenant, the clients
+// created by an admin are read from the repository
+func setTenantPolicy(conf *config.Config, repos *repository.Repository) *services.TenantPolicy {
+	tenants := make(map[string]string)
+	for _, svc := range conf.SVCGetServices() {
+		tenants[svc.SVCGetID()] = svc.SVCGetTenant()
+	}
+	return services.NewTenantPolicy(tenants, repos)
+}
+
+// setMagicLinkConfig set the magic links, the rate limit window is the links' ttl
+func setMagicLinkConfig(conf *config.Config) services.MagicLinkConfig {
+	return services.MagicLinkConfig{
+		URL:       conf.MglGetURL(),
+		Secret:    []byte(conf.MglGetSecret()),
+		TTL:       time.Duration(conf.MglGetTTL()) * time.Minute,
+		MaxPerTTL: conf.MglGetMaxPerTTL(),
+	}
+}
+
+// setWebhookConfig set the identity events deliveries
+func setWebhookConfig(conf *config.Config) services.WebhookConfig {
+	return services.WebhookConfig{
+		MaxAttempts: conf.WhkGetMaxAttempts(),
+		Backoff:     time.Duration(conf.WhkGetBackoff()) * time.Second,
+		Timeout:     time.Duration(conf.WhkGetTimeout()) * time.Second,
+		Workers:     conf.WhkGetWorkers(),
+	}
+}
+
+// setServerConfig set the http server
+func setServerConfig(conf *config.Config, port int) handlers.ServerConfig {
+	return handlers.ServerConfig{
+		Port:               port,
+		ReadTimeout:        time.Duration(conf.HTTPGetReadTimeout()) * time.Second,
+		WriteTimeout:       time.Duration(conf.HTTPGetWriteTimeout()) * time.Second,
+		IdleTimeout:        time.Duration(conf.HTTPGetIdleTimeout()) * time.Second,
+		ShutdownTimeout:    time.Duration(conf.HTTPGetShutdownTimeout()) * time.Second,
+		MaxBodyBytes:       int64(conf.HTTPGetMaxBodyBytes()),
+		CORSAllowedOrigins: conf.HTTPGetCORSAllowedOrigins(),
+	}
+}
+
+// setHealth register the dependencies checked by the readiness
+func setHealth(conf *config.Config, repos *repository.Repository) *health.Health {
+	hl := health.NewHealth(time.Duration(conf.HTTPGetHealthTimeout()) * time.Second)
+	if conf.StgIsEmbedded() {
+		hl.HealthRegister("embedded", repos.UserPing)
+		return hl
+	}
+	hl.HealthRegister("mongo", repos.UserPing)
+	hl.HealthRegister("redis", repos.RedisPing)
+	return hl
+}
+
+// setStorage map the oauth2 token and client stores on the manager and
+// create the repository, on mongo and redis or on the embedded file
+// (STORAGE_BACKEND=embedded), the returned func close the storage
+func setStorage(conf *config.Config, manager *manage.Manager) (*repository.Repository, services.IClientStore, func(), error) {
+	if conf.StgIsEmbedded() {
+		es, err := repository.NewEmbeddedStore(conf.StgGetPath())
+		if err != nil {
+			return nil, nil, nil, err
+		}
+
+		// the oauth2 tokens are kept next to the embedded store
+		manager.MustTokenStorage(store.NewFileTokenStore(conf.StgGetPath() + ".tokens"))
+
+		clientStore := es.ClientStore()
+		manager.MapClientStorage(clientStore)
+
+		return repository.NewEmbeddedRepository(es), clientStore, func() { es.Close() }, nil
+	}
+
+	// set connectionTimeout(7s) and the requestsTimeout(5s) // is optional
+	storeConfigs := mongo.NewStoreConfig(7, 7)
+
+	mongoConf := createMongoAuthConfig(conf)
+
+	// use mongodb token store
+	manager.MapTokenStorage(
+		mongo.NewTokenStore(mongoConf, storeConfigs), // with timeout
+		// mongo.NewTokenStore(mongoConf), // no timeout
+	)
+
+	clientStore := mongo.NewClientStore(mongoConf, storeConfigs) // with timeout
+	// clientStore := mongo.NewClientStore(mongoConf) // no timeout
+
+	manager.MapClientStorage(clientStore)
+
+	repos, err := repository.NewRepository(conf)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+
+	return repos, clientStore, func() {}, nil
+}
+
+func registerServices(clientStore services.IClientStore, conf *config.Config) {
 	svcs := conf.SVCGetServices()
 
 	// register the frontend
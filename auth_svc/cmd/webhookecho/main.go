@@ -0,0 +1,106 @@
+// webhookecho is a local stand-in of a webhook receiver, it verify the
+// signature of the deliveries and print them
+//
+//	webhookecho [-addr :9099] [-secret SECRET] [-fail N]
+//
+// the secret is the one returned by "authctl webhooks create", it default
+// to WEBHOOK_SECRET, -fail answer a 500 to the first N deliveries so the
+// retries and the dead letters can be tried out
+package main
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// signatureMaxAge reject the replayed deliveries
+const signatureMaxAge = 5 * time.Minute
+
+func main() {
+	addr := flag.String("addr", ":9099", "listen address")
+	secret := flag.String("secret", os.Getenv("WEBHOOK_SECRET"), "subscription secret")
+	fail := flag.Int("fail", 0, "number of deliveries to answer with a 500")
+	flag.Parse()
+
+	var (
+		mu   sync.Mutex
+		hits int
+	)
+
+	http.HandleFunc("/", func(w http.ResponseWriter, r *http.Request) {
+		body, err := io.ReadAll(r.Body)
+		if err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+
+		mu.Lock()
+		hits++
+		n := hits
+		mu.Unlock()
+
+		id := r.Header.Get("X-Webhook-ID")
+		event := r.Header.Get("X-Webhook-Event")
+
+		if *secret != "" {
+			if err := verify(*secret, r.Header.Get("X-Webhook-Signature"), body); err != nil {
+				log.Printf("#%d %v %v rejected: %v", n, event, id, err)
+				w.WriteHeader(http.StatusUnauthorized)
+				return
+			}
+		}
+
+		if n <= *fail {
+			log.Printf("#%d %v %v failed on purpose", n, event, id)
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+
+		log.Printf("#%d %v %v %s", n, event, id, body)
+	})
+
+	log.Printf("webhookecho listening on %v", *addr)
+	log.Fatal(http.ListenAndServe(*addr, nil))
+}
+
+// verify check the header t=<unix time>,v1=<hex(hmac_sha256(secret, "<unix time>.<body>"))>
+func verify(secret, header string, body []byte) error {
+	var ts, sig string
+	for _, part := range strings.Split(header, ",") {
+		switch {
+		case strings.HasPrefix(part, "t="):
+			ts = strings.TrimPrefix(part, "t=")
+		case strings.HasPrefix(part, "v1="):
+			sig = strings.TrimPrefix(part, "v1=")
+		}
+	}
+
+	unix, err := strconv.ParseInt(ts, 10, 64)
+	if err != nil || sig == "" {
+		return fmt.Errorf("malformed signature %q", header)
+	}
+	if age := time.Since(time.Unix(unix, 0)); age > signatureMaxAge || age < -signatureMaxAge {
+		return fmt.Errorf("signature too old")
+	}
+
+	mac := hmac.New(sha256.New, []byte(secret))
+	fmt.Fprintf(mac, "%d.", unix)
+	mac.Write(body)
+	expected := hex.EncodeToString(mac.Sum(nil))
+
+	if !hmac.Equal([]byte(expected), []byte(sig)) {
+		return fmt.Errorf("invalid signature")
+	}
+	return nil
+}
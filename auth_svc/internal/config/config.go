@@ -1,7 +1,10 @@
 package config
 
 import (
+	"crypto/tls"
+	"errors"
 	"github.com/spf13/viper"
+	"net"
 	"os"
 	"path/filepath"
 	"strconv"
@@ -28,10 +31,56 @@ const (
 	jwtUserSecretkeyDefault           = "userSecretkeyDefault"
 	jwtServiceKeyIDDefault            = "serviceKeyIDDefault"
 	jwtServiceSecretkeyDefault        = "serviceSecretKeyDefault"
+	jwtKeysValidatorsDefault          = "registrySvc"
 	redisPortDefault                  = "6379"
 	redisAddressDefault               = "redis"
 	redisMaxIdleDefault           int = 80
 	redisMaxActiveDefault         int = 12000
+	codeChallengeMethodDefault        = "S256"
+	tenantDefault                     = "default"
+	grpcPortDefault                   = "50003"
+	pathToTLSDefault                  = "../configs/v1/certificates"
+	serverCertFileDefault             = "server.crt"
+	serverKeyFileDefault              = "server.key"
+	caFileDefault                     = "rootCA.crt"
+	adminDebugTokenMaxTTLDefault  int = 15
+	adminAPIKeyMaxTTLDefault      int = 365
+	adminInvitationTTLDefault     int = 7
+	magicLinkURLDefault               = "http://localhost:9096/v1/magiclink/callback"
+	magicLinkSecretDefault            = "magicLinkSecretDefault"
+	magicLinkTTLDefault           int = 15
+	magicLinkMaxPerTTLDefault     int = 3
+	mailerDirDefault                  = "../mails"
+	webhookMaxAttemptsDefault     int = 5
+	webhookBackoffDefault         int = 2
+	webhookTimeoutDefault         int = 5
+	webhookWorkersDefault         int = 2
+	httpReadTimeoutDefault        int = 10
+	httpWriteTimeoutDefault       int = 15
+	httpIdleTimeoutDefault        int = 60
+	httpShutdownTimeoutDefault    int = 20
+	httpMaxBodyBytesDefault       int = 1 << 20
+	httpHealthTimeoutDefault      int = 3
+	httpHealthIntervalDefault     int = 10
+	corsAllowedOriginsDefault         = "http://localhost:80"
+	clientCertFileDefault             = "client.crt"
+	clientKeyFileDefault              = "client.key"
+	obsSamplingDefault                = 0.6
+	obsScratchDelayDefault        int = 30
+	obsCollectorEndpointDefault       = "otel_collector:4317"
+	storageBackendDefault             = storageBackendMongo
+	storagePathDefault                = "../data/auth_svc.db"
+	passwordMinLengthDefault      int = 8
+	signupBlockDisposableDefault      = true
+	signupOpenDefault                 = true
+	signupOpenProduction              = false
+)
+
+// storage backends, the embedded one keep all datas in a single file so
+// the service run without mongo and redis(locally, tests...)
+const (
+	storageBackendMongo    = "mongo"
+	storageBackendEmbedded = "embedded"
 )
 
 func SetConfigs() (*Config, error) {
@@ -76,6 +125,8 @@ func SetConfigs() (*Config, error) {
 	c.jwtSetUserSecretkey(jwtUserSecretkey)
 	c.jwtSetServiceKeyID(jwtServiceKeyID)
 	c.jwtSetServiceSecretkey(jwtServiceSecretkey)
+	jwtKeysValidators := os.Getenv("JWT_KEYS_VALIDATORS")
+	c.jwtSetKeysValidators(jwtKeysValidators)
 
 	// Redis
 	redisMaxIdle := os.Getenv("REDIS_MAX_IDLE")
@@ -87,6 +138,108 @@ func SetConfigs() (*Config, error) {
 	c.rdsSetPort(redisPort)
 	c.rdsSetAddress(redisAddress)
 
+	// GRPC
+	grpcPort := os.Getenv("GRPC_PORT")
+	pathToTLS := os.Getenv("PATH_TO_TLS")
+	serverCertFile := os.Getenv("SERVER_CERT_FILE")
+	serverKeyFile := os.Getenv("SERVER_KEY_FILE")
+	caFile := os.Getenv("CA_FILE")
+	clientCertFile := os.Getenv("CLIENT_CERT_FILE")
+	clientKeyFile := os.Getenv("CLIENT_KEY_FILE")
+	c.grpcSetPort(grpcPort)
+	c.grpcSetPathToTLS(pathToTLS)
+	c.grpcSetServerCertFile(serverCertFile)
+	c.grpcSetServerKeyFile(serverKeyFile)
+	c.grpcSetCAFile(caFile)
+	c.grpcSetClientCertFile(clientCertFile)
+	c.grpcSetClientKeyFile(clientKeyFile)
+
+	// Admin
+	adminToken := os.Getenv("ADMIN_TOKEN")
+	adminDebugTokenMaxTTL := os.Getenv("ADMIN_DEBUG_TOKEN_MAX_TTL")
+	adminAPIKeyMaxTTL := os.Getenv("ADMIN_API_KEY_MAX_TTL")
+	adminInvitationTTL := os.Getenv("ADMIN_INVITATION_TTL")
+	c.admSetToken(adminToken)
+	c.admSetDebugTokenMaxTTL(adminDebugTokenMaxTTL)
+	c.admSetAPIKeyMaxTTL(adminAPIKeyMaxTTL)
+	c.admSetInvitationTTL(adminInvitationTTL)
+
+	// MagicLink
+	magicLinkURL := os.Getenv("MAGICLINK_URL")
+	magicLinkSecret := os.Getenv("MAGICLINK_SECRET")
+	magicLinkTTL := os.Getenv("MAGICLINK_TTL")
+	magicLinkMaxPerTTL := os.Getenv("MAGICLINK_MAX_PER_TTL")
+	mailerDir := os.Getenv("MAILER_DIR")
+	c.mglSetURL(magicLinkURL)
+	c.mglSetSecret(magicLinkSecret)
+	c.mglSetTTL(magicLinkTTL)
+	c.mglSetMaxPerTTL(magicLinkMaxPerTTL)
+	c.mglSetMailerDir(mailerDir)
+	// the links signed with the public default secret could be forged
+	if magicLinkSecret == "" && golangEnv != "" && golangEnv != "localhost" {
+		return c, errors.New("MAGICLINK_SECRET is required outside localhost")
+	}
+
+	// Webhook
+	webhookMaxAttempts := os.Getenv("WEBHOOK_MAX_ATTEMPTS")
+	webhookBackoff := os.Getenv("WEBHOOK_BACKOFF")
+	webhookTimeout := os.Getenv("WEBHOOK_TIMEOUT")
+	webhookWorkers := os.Getenv("WEBHOOK_WORKERS")
+	c.whkSetMaxAttempts(webhookMaxAttempts)
+	c.whkSetBackoff(webhookBackoff)
+	c.whkSetTimeout(webhookTimeout)
+	c.whkSetWorkers(webhookWorkers)
+
+	// HTTP
+	httpReadTimeout := os.Getenv("HTTP_READ_TIMEOUT")
+	httpWriteTimeout := os.Getenv("HTTP_WRITE_TIMEOUT")
+	httpIdleTimeout := os.Getenv("HTTP_IDLE_TIMEOUT")
+	httpShutdownTimeout := os.Getenv("HTTP_SHUTDOWN_TIMEOUT")
+	httpMaxBodyBytes := os.Getenv("HTTP_MAX_BODY_BYTES")
+	corsAllowedOrigins := os.Getenv("CORS_ALLOWED_ORIGINS")
+	httpHealthTimeout := os.Getenv("HEALTH_CHECK_TIMEOUT")
+	httpHealthInterval := os.Getenv("HEALTH_CHECK_INTERVAL")
+	c.httpSetReadTimeout(httpReadTimeout)
+	c.httpSetWriteTimeout(httpWriteTimeout)
+	c.httpSetIdleTimeout(httpIdleTimeout)
+	c.httpSetShutdownTimeout(httpShutdownTimeout)
+	c.httpSetMaxBodyBytes(httpMaxBodyBytes)
+	c.httpSetCORSAllowedOrigins(corsAllowedOrigins)
+	c.httpSetHealthTimeout(httpHealthTimeout)
+	c.httpSetHealthInterval(httpHealthInterval)
+
+	// Signup
+	passwordMinLength := os.Getenv("PASSWORD_MIN_LENGTH")
+	passwordCharacterClasses := os.Getenv("PASSWORD_CHARACTER_CLASSES")
+	passwordBreachedFile := os.Getenv("PASSWORD_BREACHED_FILE")
+	signupAllowedDomains := os.Getenv("SIGNUP_ALLOWED_DOMAINS")
+	signupDeniedDomains := os.Getenv("SIGNUP_DENIED_DOMAINS")
+	signupBlockDisposable := os.Getenv("SIGNUP_BLOCK_DISPOSABLE")
+	signupDisposableFile := os.Getenv("SIGNUP_DISPOSABLE_FILE")
+	signupOpen := os.Getenv("SIGNUP_OPEN")
+	c.sgnSetPasswordMinLength(passwordMinLength)
+	c.sgnSetPasswordCharacterClasses(passwordCharacterClasses)
+	c.sgnSetPasswordBreachedFile(passwordBreachedFile)
+	c.sgnSetAllowedDomains(signupAllowedDomains)
+	c.sgnSetDeniedDomains(signupDeniedDomains)
+	c.sgnSetBlockDisposable(signupBlockDisposable)
+	c.sgnSetDisposableFile(signupDisposableFile)
+	c.sgnSetOpen(signupOpen)
+
+	// Storage
+	storageBackend := os.Getenv("STORAGE_BACKEND")
+	storagePath := os.Getenv("STORAGE_PATH")
+	c.stgSetBackend(storageBackend)
+	c.stgSetPath(storagePath)
+
+	// Observability
+	sampling := os.Getenv("OBS_SAMPLING")
+	scrDelay := os.Getenv("OBS_SCRATCH_DELAY")
+	collEndpoint := os.Getenv("OBS_COLLECTOR_ENDPOINT")
+	c.obsSetSampling(sampling)
+	c.obsSetScratchDelay(scrDelay)
+	c.obsSetCollectorEndpoint(collEndpoint)
+
 	return c, nil
 }
 
@@ -97,6 +250,14 @@ type Config struct {
 	*MongoDB
 	*JWTEncryption
 	*Redis
+	*GRPC
+	*Admin
+	*MagicLink
+	*Webhook
+	*HTTP
+	*Observability
+	*Storage
+	*Signup
 }
 
 func NewConfig(goEnv string, serviceName ...string) *Config {
@@ -111,6 +272,14 @@ func NewConfig(goEnv string, serviceName ...string) *Config {
 		MongoDB:       NewMongoDB(g.GlbGetenv()),
 		JWTEncryption: NewJWTEncryption(),
 		Redis:         NewRedis(g.GlbGetenv()),
+		GRPC:          NewGRPC(),
+		Admin:         NewAdmin(),
+		MagicLink:     NewMagicLink(),
+		Webhook:       NewWebhook(),
+		HTTP:          NewHTTP(),
+		Observability: NewObservability(),
+		Storage:       NewStorage(),
+		Signup:        NewSignup(g.GlbGetenv()),
 	}
 
 	return c
@@ -145,12 +314,16 @@ type IService interface {
 	SVCGetID() string
 	SVCGetSecret() string
 	SVCGetDomain() string
+	SVCGetCodeChallengeMethod() string
+	SVCGetTenant() string
 }
 
 type service struct {
-	id     string
-	secret string
-	domain string
+	id                  string
+	secret              string
+	domain              string
+	codeChallengeMethod string
+	tenant              string
 }
 
 func (s service) SVCGetID() string {
@@ -162,6 +335,12 @@ func (s service) SVCGetSecret() string {
 func (s service) SVCGetDomain() string {
 	return s.domain
 }
+func (s service) SVCGetCodeChallengeMethod() string {
+	return s.codeChallengeMethod
+}
+func (s service) SVCGetTenant() string {
+	return s.tenant
+}
 
 type services struct {
 	services             map[string]IService
@@ -217,7 +396,7 @@ func (ss *services) loadServices() error {
 			ref[parts[0]] = struct{}{}
 			services := viper.Sub(parts[0])
 			settings := services.AllSettings()
-			svc := service{}
+			svc := service{codeChallengeMethod: codeChallengeMethodDefault, tenant: tenantDefault}
 			for key, value := range settings {
 				switch key {
 				case "id":
@@ -226,6 +405,10 @@ func (ss *services) loadServices() error {
 					svc.secret = value.(string)
 				case "domain":
 					svc.domain = value.(string)
+				case "code_challenge_method":
+					svc.codeChallengeMethod = value.(string)
+				case "tenant":
+					svc.tenant = value.(string)
 				}
 			}
 			ss.services[parts[0]] = svc
@@ -408,6 +591,7 @@ type JWTEncryption struct {
 	userSecretkey    string
 	serviceKeyID     string
 	serviceSecretkey string
+	keysValidators   []string
 }
 
 func NewJWTEncryption() *JWTEncryption {
@@ -417,6 +601,7 @@ func NewJWTEncryption() *JWTEncryption {
 	e.userSecretkey = jwtUserSecretkeyDefault
 	e.serviceKeyID = jwtServiceKeyIDDefault
 	e.serviceSecretkey = jwtServiceSecretkeyDefault
+	e.keysValidators = splitList(jwtKeysValidatorsDefault)
 	return e
 }
 
@@ -469,3 +654,672 @@ func (e *JWTEncryption) jwtSetServiceSecretkey(ssk string) {
 func (e *JWTEncryption) JwtGetServiceSecretkey() string {
 	return e.serviceSecretkey
 }
+
+// jwtSetKeysValidators set the clients(comma separated) allowed to fetch
+// the signing keys to validate the jwt themselves
+func (e *JWTEncryption) jwtSetKeysValidators(validators string) {
+	if validators != "" {
+		e.keysValidators = splitList(validators)
+	}
+}
+
+func (e *JWTEncryption) JwtGetKeysValidators() []string {
+	return e.keysValidators
+}
+
+// GRPC are the grpc server configs, the server run over mTLS
+type GRPC struct {
+	port           string
+	pathToTLS      string
+	serverCertFile string
+	serverKeyFile  string
+	caFile         string
+	clientCertFile string
+	clientKeyFile  string
+}
+
+func NewGRPC() *GRPC {
+	g := &GRPC{}
+	g.port = grpcPortDefault
+	g.pathToTLS = pathToTLSDefault
+	g.serverCertFile = serverCertFileDefault
+	g.serverKeyFile = serverKeyFileDefault
+	g.caFile = caFileDefault
+	g.clientCertFile = clientCertFileDefault
+	g.clientKeyFile = clientKeyFileDefault
+	return g
+}
+
+func (g *GRPC) grpcSetPort(p string) {
+	if p != "" {
+		g.port = p
+	}
+}
+
+func (g *GRPC) GRPCGetPort() string {
+	return g.port
+}
+
+func (g *GRPC) grpcSetPathToTLS(p string) {
+	if p != "" {
+		g.pathToTLS = p
+	}
+}
+
+func (g *GRPC) GRPCGetPathToTLS() string {
+	return g.pathToTLS
+}
+
+func (g *GRPC) grpcSetServerCertFile(f string) {
+	if f != "" {
+		g.serverCertFile = f
+	}
+}
+
+func (g *GRPC) grpcSetServerKeyFile(f string) {
+	if f != "" {
+		g.serverKeyFile = f
+	}
+}
+
+func (g *GRPC) grpcSetCAFile(f string) {
+	if f != "" {
+		g.caFile = f
+	}
+}
+
+func (g *GRPC) grpcSetClientCertFile(f string) {
+	if f != "" {
+		g.clientCertFile = f
+	}
+}
+
+func (g *GRPC) grpcSetClientKeyFile(f string) {
+	if f != "" {
+		g.clientKeyFile = f
+	}
+}
+
+// GRPCGetServerTLSConfig load the certificates, the clients must present
+// a certificate signed by the same CA
+func (g *GRPC) GRPCGetServerTLSConfig() (*tls.Config, error) {
+	return setupTLSConfig(tlsConfig{
+		certFile: filepath.Join(g.pathToTLS, g.serverCertFile),
+		keyFile:  filepath.Join(g.pathToTLS, g.serverKeyFile),
+		caFile:   filepath.Join(g.pathToTLS, g.caFile),
+		server:   true,
+	})
+}
+
+// GRPCGetClientTLSConfig load the certificates auth_svc present to the
+// servers it dial(as the otel collector), serverName is the one of the server
+func (g *GRPC) GRPCGetClientTLSConfig(serverName string) (*tls.Config, error) {
+	return setupTLSConfig(tlsConfig{
+		certFile:      filepath.Join(g.pathToTLS, g.clientCertFile),
+		keyFile:       filepath.Join(g.pathToTLS, g.clientKeyFile),
+		caFile:        filepath.Join(g.pathToTLS, g.caFile),
+		serverAddress: serverName,
+	})
+}
+
+// Admin are the configs of the /v1/admin endpoints,
+// an empty token disable them
+type Admin struct {
+	token            string
+	debugTokenMaxTTL int // in minutes
+	apiKeyMaxTTL     int // in days
+	invitationTTL    int // in days
+}
+
+func NewAdmin() *Admin {
+	a := &Admin{}
+	a.debugTokenMaxTTL = adminDebugTokenMaxTTLDefault
+	a.apiKeyMaxTTL = adminAPIKeyMaxTTLDefault
+	a.invitationTTL = adminInvitationTTLDefault
+	return a
+}
+
+func (a *Admin) admSetToken(t string) {
+	if t != "" {
+		a.token = t
+	}
+}
+
+func (a *Admin) AdmGetToken() string {
+	return a.token
+}
+
+func (a *Admin) admSetDebugTokenMaxTTL(ttl string) {
+	if ttl != "" {
+		if ttlInt, err := strconv.Atoi(ttl); err == nil && ttlInt > 0 {
+			a.debugTokenMaxTTL = ttlInt
+		}
+	}
+}
+
+func (a *Admin) AdmGetDebugTokenMaxTTL() int {
+	return a.debugTokenMaxTTL
+}
+
+func (a *Admin) admSetAPIKeyMaxTTL(ttl string) {
+	if ttl != "" {
+		if ttlInt, err := strconv.Atoi(ttl); err == nil && ttlInt > 0 {
+			a.apiKeyMaxTTL = ttlInt
+		}
+	}
+}
+
+func (a *Admin) AdmGetAPIKeyMaxTTL() int {
+	return a.apiKeyMaxTTL
+}
+
+func (a *Admin) admSetInvitationTTL(ttl string) {
+	if ttl != "" {
+		if ttlInt, err := strconv.Atoi(ttl); err == nil && ttlInt > 0 {
+			a.invitationTTL = ttlInt
+		}
+	}
+}
+
+func (a *Admin) AdmGetInvitationTTL() int {
+	return a.invitationTTL
+}
+
+// MagicLink are the configs of the passwordless login, the url is the page
+// the link point to(the frontend, or the callback endpoint)
+type MagicLink struct {
+	url       string
+	secret    string
+	ttl       int // in minutes
+	maxPerTTL int // max number of links per email within the ttl
+	mailerDir string
+}
+
+func NewMagicLink() *MagicLink {
+	m := &MagicLink{}
+	m.url = magicLinkURLDefault
+	m.secret = magicLinkSecretDefault
+	m.ttl = magicLinkTTLDefault
+	m.maxPerTTL = magicLinkMaxPerTTLDefault
+	m.mailerDir = mailerDirDefault
+	return m
+}
+
+func (m *MagicLink) mglSetURL(u string) {
+	if u != "" {
+		m.url = u
+	}
+}
+
+func (m *MagicLink) MglGetURL() string {
+	return m.url
+}
+
+func (m *MagicLink) mglSetSecret(s string) {
+	if s != "" {
+		m.secret = s
+	}
+}
+
+func (m *MagicLink) MglGetSecret() string {
+	return m.secret
+}
+
+func (m *MagicLink) mglSetTTL(ttl string) {
+	if ttl != "" {
+		if ttlInt, err := strconv.Atoi(ttl); err == nil && ttlInt > 0 {
+			m.ttl = ttlInt
+		}
+	}
+}
+
+func (m *MagicLink) MglGetTTL() int {
+	return m.ttl
+}
+
+func (m *MagicLink) mglSetMaxPerTTL(max string) {
+	if max != "" {
+		if maxInt, err := strconv.Atoi(max); err == nil && maxInt > 0 {
+			m.maxPerTTL = maxInt
+		}
+	}
+}
+
+func (m *MagicLink) MglGetMaxPerTTL() int {
+	return m.maxPerTTL
+}
+
+func (m *MagicLink) mglSetMailerDir(d string) {
+	if d != "" {
+		m.mailerDir = d
+	}
+}
+
+func (m *MagicLink) MglGetMailerDir() string {
+	return m.mailerDir
+}
+
+// Webhook are the configs of the identity events deliveries, a delivery
+// is retried maxAttempts times, waiting backoff, then 2*backoff, 4*backoff...
+type Webhook struct {
+	maxAttempts int
+	backoff     int // in seconds
+	timeout     int // in seconds, of a single attempt
+	workers     int
+}
+
+func NewWebhook() *Webhook {
+	w := &Webhook{}
+	w.maxAttempts = webhookMaxAttemptsDefault
+	w.backoff = webhookBackoffDefault
+	w.timeout = webhookTimeoutDefault
+	w.workers = webhookWorkersDefault
+	return w
+}
+
+func (w *Webhook) whkSetMaxAttempts(max string) {
+	if max != "" {
+		if maxInt, err := strconv.Atoi(max); err == nil && maxInt > 0 {
+			w.maxAttempts = maxInt
+		}
+	}
+}
+
+func (w *Webhook) WhkGetMaxAttempts() int {
+	return w.maxAttempts
+}
+
+func (w *Webhook) whkSetBackoff(backoff string) {
+	if backoff != "" {
+		if backoffInt, err := strconv.Atoi(backoff); err == nil && backoffInt > 0 {
+			w.backoff = backoffInt
+		}
+	}
+}
+
+func (w *Webhook) WhkGetBackoff() int {
+	return w.backoff
+}
+
+func (w *Webhook) whkSetTimeout(timeout string) {
+	if timeout != "" {
+		if timeoutInt, err := strconv.Atoi(timeout); err == nil && timeoutInt > 0 {
+			w.timeout = timeoutInt
+		}
+	}
+}
+
+func (w *Webhook) WhkGetTimeout() int {
+	return w.timeout
+}
+
+func (w *Webhook) whkSetWorkers(workers string) {
+	if workers != "" {
+		if workersInt, err := strconv.Atoi(workers); err == nil && workersInt > 0 {
+			w.workers = workersInt
+		}
+	}
+}
+
+func (w *Webhook) WhkGetWorkers() int {
+	return w.workers
+}
+
+// HTTP are the configs of the http server, the timeouts are in seconds
+type HTTP struct {
+	readTimeout        int
+	writeTimeout       int
+	idleTimeout        int
+	shutdownTimeout    int // time given to the requests in flight to complete
+	maxBodyBytes       int
+	corsAllowedOrigins []string
+	healthTimeout      int // time given to each dependency's check
+	healthInterval     int // refresh period of the grpc health state
+}
+
+func NewHTTP() *HTTP {
+	h := &HTTP{}
+	h.readTimeout = httpReadTimeoutDefault
+	h.writeTimeout = httpWriteTimeoutDefault
+	h.idleTimeout = httpIdleTimeoutDefault
+	h.shutdownTimeout = httpShutdownTimeoutDefault
+	h.maxBodyBytes = httpMaxBodyBytesDefault
+	h.corsAllowedOrigins = strings.Split(corsAllowedOriginsDefault, ",")
+	h.healthTimeout = httpHealthTimeoutDefault
+	h.healthInterval = httpHealthIntervalDefault
+	return h
+}
+
+func (h *HTTP) httpSetReadTimeout(t string) {
+	if t != "" {
+		if tInt, err := strconv.Atoi(t); err == nil && tInt > 0 {
+			h.readTimeout = tInt
+		}
+	}
+}
+
+func (h *HTTP) HTTPGetReadTimeout() int {
+	return h.readTimeout
+}
+
+func (h *HTTP) httpSetWriteTimeout(t string) {
+	if t != "" {
+		if tInt, err := strconv.Atoi(t); err == nil && tInt > 0 {
+			h.writeTimeout = tInt
+		}
+	}
+}
+
+func (h *HTTP) HTTPGetWriteTimeout() int {
+	return h.writeTimeout
+}
+
+func (h *HTTP) httpSetIdleTimeout(t string) {
+	if t != "" {
+		if tInt, err := strconv.Atoi(t); err == nil && tInt > 0 {
+			h.idleTimeout = tInt
+		}
+	}
+}
+
+func (h *HTTP) HTTPGetIdleTimeout() int {
+	return h.idleTimeout
+}
+
+func (h *HTTP) httpSetShutdownTimeout(t string) {
+	if t != "" {
+		if tInt, err := strconv.Atoi(t); err == nil && tInt > 0 {
+			h.shutdownTimeout = tInt
+		}
+	}
+}
+
+func (h *HTTP) HTTPGetShutdownTimeout() int {
+	return h.shutdownTimeout
+}
+
+func (h *HTTP) httpSetMaxBodyBytes(max string) {
+	if max != "" {
+		if maxInt, err := strconv.Atoi(max); err == nil && maxInt > 0 {
+			h.maxBodyBytes = maxInt
+		}
+	}
+}
+
+func (h *HTTP) HTTPGetMaxBodyBytes() int {
+	return h.maxBodyBytes
+}
+
+// httpSetCORSAllowedOrigins take a comma separated list of origins, a "*"
+// allow the others without credentials(cookies)
+func (h *HTTP) httpSetCORSAllowedOrigins(origins string) {
+	if origins == "" {
+		return
+	}
+	allowed := []string{}
+	for _, o := range strings.Split(origins, ",") {
+		if o = strings.TrimSpace(o); o != "" {
+			allowed = append(allowed, o)
+		}
+	}
+	if len(allowed) > 0 {
+		h.corsAllowedOrigins = allowed
+	}
+}
+
+func (h *HTTP) HTTPGetCORSAllowedOrigins() []string {
+	return h.corsAllowedOrigins
+}
+
+func (h *HTTP) httpSetHealthTimeout(t string) {
+	if t != "" {
+		if tInt, err := strconv.Atoi(t); err == nil && tInt > 0 {
+			h.healthTimeout = tInt
+		}
+	}
+}
+
+func (h *HTTP) HTTPGetHealthTimeout() int {
+	return h.healthTimeout
+}
+
+func (h *HTTP) httpSetHealthInterval(t string) {
+	if t != "" {
+		if tInt, err := strconv.Atoi(t); err == nil && tInt > 0 {
+			h.healthInterval = tInt
+		}
+	}
+}
+
+func (h *HTTP) HTTPGetHealthInterval() int {
+	return h.healthInterval
+}
+
+// Observability are the configs of the traces and metrics, exported
+// to the otel collector
+type Observability struct {
+	sampling          float64
+	scratchDelay      int // in seconds, between two metrics exports
+	collectorEndpoint string
+}
+
+func NewObservability() *Observability {
+	o := &Observability{}
+	o.sampling = obsSamplingDefault
+	o.scratchDelay = obsScratchDelayDefault
+	o.collectorEndpoint = obsCollectorEndpointDefault
+	return o
+}
+
+func (o *Observability) obsSetSampling(sampling string) {
+	if sampling != "" {
+		if f64, err := strconv.ParseFloat(sampling, 64); err == nil && f64 >= 0 && f64 <= 1 {
+			o.sampling = f64
+		}
+	}
+}
+
+func (o *Observability) OBSGetSampling() float64 {
+	return o.sampling
+}
+
+func (o *Observability) obsSetScratchDelay(delay string) {
+	if delay != "" {
+		if delayInt, err := strconv.Atoi(delay); err == nil && delayInt > 0 {
+			o.scratchDelay = delayInt
+		}
+	}
+}
+
+func (o *Observability) OBSGetScratchDelay() int {
+	return o.scratchDelay
+}
+
+func (o *Observability) obsSetCollectorEndpoint(ep string) {
+	if ep != "" {
+		o.collectorEndpoint = ep
+	}
+}
+
+func (o *Observability) OBSGetCollectorEndpoint() string {
+	return o.collectorEndpoint
+}
+
+// OBSGetCollectorHost is the collector's host, the name its certificate is checked against
+func (o *Observability) OBSGetCollectorHost() string {
+	if host, _, err := net.SplitHostPort(o.collectorEndpoint); err == nil {
+		return host
+	}
+	return o.collectorEndpoint
+}
+
+// Storage select the backend of the repository, path is the file of the
+// embedded backend
+type Storage struct {
+	backend string
+	path    string
+}
+
+func NewStorage() *Storage {
+	return &Storage{
+		backend: storageBackendDefault,
+		path:    storagePathDefault,
+	}
+}
+
+// stgSetBackend ignore the unknown backends
+func (s *Storage) stgSetBackend(backend string) {
+	switch backend {
+	case storageBackendMongo, storageBackendEmbedded:
+		s.backend = backend
+	}
+}
+
+func (s *Storage) StgGetBackend() string {
+	return s.backend
+}
+
+func (s *Storage) StgIsEmbedded() bool {
+	return s.backend == storageBackendEmbedded
+}
+
+func (s *Storage) stgSetPath(path string) {
+	if path != "" {
+		s.path = path
+	}
+}
+
+func (s *Storage) StgGetPath() string {
+	return s.path
+}
+
+// Signup are the configs of the signup policy, the password classes are
+// lower, upper, digit and symbol, an empty domains list allow all of them.
+// A closed signup require an invitation, it is closed in production by default
+type Signup struct {
+	golangEnv                string
+	open                     bool
+	passwordMinLength        int
+	passwordCharacterClasses []string
+	passwordBreachedFile     string // one sha1 per line, as the haveibeenpwned lists
+	allowedDomains           []string
+	deniedDomains            []string
+	blockDisposable          bool
+	disposableFile           string // one domain per line, added to the builtin ones
+}
+
+func NewSignup(goEnv string) *Signup {
+	s := &Signup{
+		golangEnv:         goEnv,
+		passwordMinLength: passwordMinLengthDefault,
+		blockDisposable:   signupBlockDisposableDefault,
+	}
+	s.sgnSetOpenDefault()
+	return s
+}
+
+func (s *Signup) sgnSetOpenDefault() {
+	switch s.golangEnv {
+	case "production":
+		s.open = signupOpenProduction
+	default:
+		s.open = signupOpenDefault
+	}
+}
+
+func (s *Signup) sgnSetOpen(open string) {
+	if open != "" {
+		if b, err := strconv.ParseBool(open); err == nil {
+			s.open = b
+		}
+	}
+}
+
+func (s *Signup) SgnIsOpen() bool {
+	return s.open
+}
+
+func (s *Signup) sgnSetPasswordMinLength(l string) {
+	if l != "" {
+		if lInt, err := strconv.Atoi(l); err == nil && lInt > 0 {
+			s.passwordMinLength = lInt
+		}
+	}
+}
+
+func (s *Signup) SgnGetPasswordMinLength() int {
+	return s.passwordMinLength
+}
+
+func (s *Signup) sgnSetPasswordCharacterClasses(classes string) {
+	if classes != "" {
+		s.passwordCharacterClasses = splitList(classes)
+	}
+}
+
+func (s *Signup) SgnGetPasswordCharacterClasses() []string {
+	return s.passwordCharacterClasses
+}
+
+func (s *Signup) sgnSetPasswordBreachedFile(f string) {
+	if f != "" {
+		s.passwordBreachedFile = f
+	}
+}
+
+func (s *Signup) SgnGetPasswordBreachedFile() string {
+	return s.passwordBreachedFile
+}
+
+func (s *Signup) sgnSetAllowedDomains(domains string) {
+	if domains != "" {
+		s.allowedDomains = splitList(strings.ToLower(domains))
+	}
+}
+
+func (s *Signup) SgnGetAllowedDomains() []string {
+	return s.allowedDomains
+}
+
+func (s *Signup) sgnSetDeniedDomains(domains string) {
+	if domains != "" {
+		s.deniedDomains = splitList(strings.ToLower(domains))
+	}
+}
+
+func (s *Signup) SgnGetDeniedDomains() []string {
+	return s.deniedDomains
+}
+
+func (s *Signup) sgnSetBlockDisposable(block string) {
+	if block != "" {
+		if b, err := strconv.ParseBool(block); err == nil {
+			s.blockDisposable = b
+		}
+	}
+}
+
+func (s *Signup) SgnIsBlockDisposable() bool {
+	return s.blockDisposable
+}
+
+func (s *Signup) sgnSetDisposableFile(f string) {
+	if f != "" {
+		s.disposableFile = f
+	}
+}
+
+func (s *Signup) SgnGetDisposableFile() string {
+	return s.disposableFile
+}
+
+// splitList split a comma separated list, the empty items are dropped
+func splitList(list string) []string {
+	items := []string{}
+	for _, item := range strings.Split(list, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			items = append(items, item)
+		}
+	}
+	return items
+}
@@ -20,10 +20,35 @@ const (
 	jwtUserSecretkey       = "userSecretkeyDefaultA"
 	jwtServiceKeyID        = "serviceKeyIDDefaultA"
 	jwtServiceSecretkey    = "serviceSecretkeyDefaultA"
+	jwtKeysValidators      = "registrySvcA, orderSvcA"
 	redisPort              = "63790"
 	redisAddress           = "redisA"
 	redisMaxIdle           = "809"
 	redisMaxActive         = "120009"
+	grpcPort               = "50009"
+	pathToTLS              = "../certificates"
+	adminToken             = "adminTokenA"
+	adminDebugTokenMaxTTL  = "5"
+	adminAPIKeyMaxTTL      = "90"
+	magicLinkURL           = "http://localhost:80/magiclink"
+	magicLinkSecret        = "magicLinkSecret"
+	magicLinkTTL           = "10"
+	mailerDir              = "/tmp/mails"
+	webhookMaxAttempts     = "8"
+	webhookWorkers         = "4"
+	httpShutdownTimeout    = "30"
+	corsAllowedOrigins     = "http://localhost:80, http://localhost:3000"
+	healthCheckInterval    = "5"
+	storageBackend         = "embedded"
+	storagePath            = "/tmp/auth_svc.db"
+	obsSampling            = "0.3"
+	obsCollectorEndpoint   = "otel_collectorA:4317"
+	passwordMinLength      = "12"
+	passwordClasses        = "lower, upper,digit"
+	signupDeniedDomains    = "Competitor.com,rival.io"
+	signupBlockDisposable  = "false"
+	signupOpen             = "false"
+	adminInvitationTTL     = "14"
 )
 
 func Test_default_configs(t *testing.T) {
@@ -43,6 +68,8 @@ func Test_default_configs(t *testing.T) {
 		tests.Expect(svcs["frontend"].SVCGetID(), "222222"),
 		tests.Expect(svcs["frontend"].SVCGetSecret(), "22222222"),
 		tests.Expect(svcs["frontend"].SVCGetDomain(), "http://localhost:80"),
+		tests.Expect(svcs["frontend"].SVCGetCodeChallengeMethod(), "S256"),
+		tests.Expect(svcs["frontend"].SVCGetTenant(), "default"),
 		tests.Expect(svcs["broker_svc"].SVCGetID(), "brokerSvc"),
 		tests.Expect(svcs["registry_svc"].SVCGetDomain(), "http://localhost:4000"),
 		tests.Expect(conf.MgoGetAuthDatabaseName(), mongoAuthDatabaseNameDefault),
@@ -61,6 +88,39 @@ func Test_default_configs(t *testing.T) {
 		tests.Expect(conf.JwtGetUserSecretkey(), jwtUserSecretkeyDefault),
 		tests.Expect(conf.JwtGetServiceKeyID(), jwtServiceKeyIDDefault),
 		tests.Expect(conf.JwtGetServiceSecretkey(), jwtServiceSecretkeyDefault),
+		tests.Expect(conf.JwtGetKeysValidators(), []string{jwtKeysValidatorsDefault}),
+		tests.Expect(conf.GRPCGetPort(), grpcPortDefault),
+		tests.Expect(conf.GRPCGetPathToTLS(), pathToTLSDefault),
+		tests.Expect(conf.AdmGetToken(), ""),
+		tests.Expect(conf.AdmGetDebugTokenMaxTTL(), adminDebugTokenMaxTTLDefault),
+		tests.Expect(conf.AdmGetAPIKeyMaxTTL(), adminAPIKeyMaxTTLDefault),
+		tests.Expect(conf.MglGetURL(), magicLinkURLDefault),
+		tests.Expect(conf.MglGetTTL(), magicLinkTTLDefault),
+		tests.Expect(conf.MglGetMaxPerTTL(), magicLinkMaxPerTTLDefault),
+		tests.Expect(conf.MglGetMailerDir(), mailerDirDefault),
+		tests.Expect(conf.WhkGetMaxAttempts(), webhookMaxAttemptsDefault),
+		tests.Expect(conf.WhkGetBackoff(), webhookBackoffDefault),
+		tests.Expect(conf.WhkGetTimeout(), webhookTimeoutDefault),
+		tests.Expect(conf.WhkGetWorkers(), webhookWorkersDefault),
+		tests.Expect(conf.HTTPGetShutdownTimeout(), httpShutdownTimeoutDefault),
+		tests.Expect(conf.HTTPGetMaxBodyBytes(), httpMaxBodyBytesDefault),
+		tests.Expect(len(conf.HTTPGetCORSAllowedOrigins()), 1),
+		tests.Expect(conf.HTTPGetHealthTimeout(), httpHealthTimeoutDefault),
+		tests.Expect(conf.HTTPGetHealthInterval(), httpHealthIntervalDefault),
+		tests.Expect(conf.OBSGetSampling(), obsSamplingDefault),
+		tests.Expect(conf.OBSGetScratchDelay(), obsScratchDelayDefault),
+		tests.Expect(conf.OBSGetCollectorEndpoint(), obsCollectorEndpointDefault),
+		tests.Expect(conf.OBSGetCollectorHost(), "otel_collector"),
+		tests.Expect(conf.StgGetBackend(), storageBackendMongo),
+		tests.Expect(conf.StgIsEmbedded(), false),
+		tests.Expect(conf.StgGetPath(), storagePathDefault),
+		tests.Expect(conf.SgnGetPasswordMinLength(), passwordMinLengthDefault),
+		tests.Expect(len(conf.SgnGetPasswordCharacterClasses()), 0),
+		tests.Expect(len(conf.SgnGetAllowedDomains()), 0),
+		tests.Expect(conf.SgnIsBlockDisposable(), signupBlockDisposableDefault),
+		tests.Expect(conf.SgnIsOpen(), signupOpenDefault),
+		tests.Expect(NewSignup("production").SgnIsOpen(), signupOpenProduction),
+		tests.Expect(conf.AdmGetInvitationTTL(), adminInvitationTTLDefault),
 	)
 }
 
@@ -82,10 +142,35 @@ func Test_varenv_configs(t *testing.T) {
 	os.Setenv("JWT_USER_SECRETKEY", jwtUserSecretkey)
 	os.Setenv("JWT_SERVICE_KEYID", jwtServiceKeyID)
 	os.Setenv("JWT_SERVICE_SECRETKEY", jwtServiceSecretkey)
+	os.Setenv("JWT_KEYS_VALIDATORS", jwtKeysValidators)
 	os.Setenv("REDIS_MAX_IDLE", redisMaxIdle)
 	os.Setenv("REDIS_MAX_ACTIVE", redisMaxActive)
 	os.Setenv("REDIS_PORT", redisPort)
 	os.Setenv("REDIS_ADDRESS", redisAddress)
+	os.Setenv("GRPC_PORT", grpcPort)
+	os.Setenv("PATH_TO_TLS", pathToTLS)
+	os.Setenv("ADMIN_TOKEN", adminToken)
+	os.Setenv("ADMIN_DEBUG_TOKEN_MAX_TTL", adminDebugTokenMaxTTL)
+	os.Setenv("ADMIN_API_KEY_MAX_TTL", adminAPIKeyMaxTTL)
+	os.Setenv("MAGICLINK_URL", magicLinkURL)
+	os.Setenv("MAGICLINK_SECRET", magicLinkSecret)
+	os.Setenv("MAGICLINK_TTL", magicLinkTTL)
+	os.Setenv("MAILER_DIR", mailerDir)
+	os.Setenv("WEBHOOK_MAX_ATTEMPTS", webhookMaxAttempts)
+	os.Setenv("WEBHOOK_WORKERS", webhookWorkers)
+	os.Setenv("HTTP_SHUTDOWN_TIMEOUT", httpShutdownTimeout)
+	os.Setenv("CORS_ALLOWED_ORIGINS", corsAllowedOrigins)
+	os.Setenv("HEALTH_CHECK_INTERVAL", healthCheckInterval)
+	os.Setenv("STORAGE_BACKEND", storageBackend)
+	os.Setenv("STORAGE_PATH", storagePath)
+	os.Setenv("OBS_SAMPLING", obsSampling)
+	os.Setenv("OBS_COLLECTOR_ENDPOINT", obsCollectorEndpoint)
+	os.Setenv("PASSWORD_MIN_LENGTH", passwordMinLength)
+	os.Setenv("PASSWORD_CHARACTER_CLASSES", passwordClasses)
+	os.Setenv("SIGNUP_DENIED_DOMAINS", signupDeniedDomains)
+	os.Setenv("SIGNUP_BLOCK_DISPOSABLE", signupBlockDisposable)
+	os.Setenv("SIGNUP_OPEN", signupOpen)
+	os.Setenv("ADMIN_INVITATION_TTL", adminInvitationTTL)
 
 	conf, _ := SetConfigs()
 
@@ -115,5 +200,47 @@ func Test_varenv_configs(t *testing.T) {
 		tests.Expect(conf.JwtGetUserSecretkey(), jwtUserSecretkey),
 		tests.Expect(conf.JwtGetServiceKeyID(), jwtServiceKeyID),
 		tests.Expect(conf.JwtGetServiceSecretkey(), jwtServiceSecretkey),
+		tests.Expect(conf.JwtGetKeysValidators(), []string{"registrySvcA", "orderSvcA"}),
+		tests.Expect(conf.GRPCGetPort(), grpcPort),
+		tests.Expect(conf.GRPCGetPathToTLS(), pathToTLS),
+		tests.Expect(conf.AdmGetToken(), adminToken),
+		tests.Expect(conf.AdmGetDebugTokenMaxTTL(), 5),
+		tests.Expect(conf.AdmGetAPIKeyMaxTTL(), 90),
+		tests.Expect(conf.MglGetURL(), magicLinkURL),
+		tests.Expect(conf.MglGetSecret(), magicLinkSecret),
+		tests.Expect(conf.MglGetTTL(), 10),
+		tests.Expect(conf.MglGetMailerDir(), mailerDir),
+		tests.Expect(conf.WhkGetMaxAttempts(), 8),
+		tests.Expect(conf.WhkGetWorkers(), 4),
+		tests.Expect(conf.HTTPGetShutdownTimeout(), 30),
+		tests.Expect(conf.HTTPGetCORSAllowedOrigins()[1], "http://localhost:3000"),
+		tests.Expect(conf.HTTPGetHealthInterval(), 5),
+		tests.Expect(conf.StgIsEmbedded(), true),
+		tests.Expect(conf.StgGetPath(), storagePath),
+		tests.Expect(conf.OBSGetSampling(), 0.3),
+		tests.Expect(conf.OBSGetCollectorHost(), "otel_collectorA"),
+		tests.Expect(conf.SgnGetPasswordMinLength(), 12),
+		tests.Expect(conf.SgnGetPasswordCharacterClasses()[1], "upper"),
+		tests.Expect(conf.SgnGetDeniedDomains()[0], "competitor.com"),
+		tests.Expect(conf.SgnIsBlockDisposable(), false),
+		tests.Expect(conf.SgnIsOpen(), false),
+		tests.Expect(conf.AdmGetInvitationTTL(), 14),
 	)
 }
+
+func Test_magiclink_secret_required(t *testing.T) {
+	tests.MaybeFail = tests.InitFailFunc(t)
+
+	t.Setenv("CONFIG_FILE_PATH", "../../configs/v1/")
+	t.Setenv("CONFIG_FILE_NAME", "servicesTest")
+	t.Setenv("MAGICLINK_SECRET", "")
+
+	// the default secret is only accepted on localhost
+	t.Setenv("GOENV", "production")
+	_, err := SetConfigs()
+	tests.MaybeFail("magiclink_secret_production", tests.Expect(err != nil, true))
+
+	t.Setenv("GOENV", "localhost")
+	conf, err := SetConfigs()
+	tests.MaybeFail("magiclink_secret_localhost", err, tests.Expect(conf.MglGetSecret(), magicLinkSecretDefault))
+}
@@ -0,0 +1,430 @@
+package handlers
+
+import (
+	"crypto/subtle"
+	"encoding/json"
+	"gitlab.com/grpasr/asonrythme/auth_svc/internal/services"
+	e "gitlab.com/grpasr/common/errors/json"
+	obs "gitlab.com/grpasr/common/observability"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+type IAdminHandler interface {
+	ClientCreate(w http.ResponseWriter, r *http.Request)
+	ClientRotate(w http.ResponseWriter, r *http.Request)
+	ClientRevoke(w http.ResponseWriter, r *http.Request)
+	UserList(w http.ResponseWriter, r *http.Request)
+	UserDisable(w http.ResponseWriter, r *http.Request)
+	UserDelete(w http.ResponseWriter, r *http.Request)
+	SessionRevoke(w http.ResponseWriter, r *http.Request)
+	KeyRotate(w http.ResponseWriter, r *http.Request)
+	DebugToken(w http.ResponseWriter, r *http.Request)
+	WebhookList(w http.ResponseWriter, r *http.Request)
+	WebhookCreate(w http.ResponseWriter, r *http.Request)
+	WebhookDelete(w http.ResponseWriter, r *http.Request)
+	WebhookDeadLetters(w http.ResponseWriter, r *http.Request)
+	WebhookReplay(w http.ResponseWriter, r *http.Request)
+	APIKeyList(w http.ResponseWriter, r *http.Request)
+	APIKeyCreate(w http.ResponseWriter, r *http.Request)
+	APIKeyRevoke(w http.ResponseWriter, r *http.Request)
+	InvitationList(w http.ResponseWriter, r *http.Request)
+	InvitationCreate(w http.ResponseWriter, r *http.Request)
+	InvitationRevoke(w http.ResponseWriter, r *http.Request)
+}
+
+// adminRequest is the payload of the admin endpoints, each one use only
+// the fields it needs
+type adminRequest struct {
+	ID         string   `json:"id"`
+	Domain     string   `json:"domain"`
+	UserID     string   `json:"user_id"`
+	Tenant     string   `json:"tenant"`
+	Email      string   `json:"email"`
+	Subject    string   `json:"subject"`
+	Role       string   `json:"role"`
+	Service    string   `json:"service"`
+	Scope      string   `json:"scope"`
+	TTLSeconds int      `json:"ttl_seconds"`
+	URL        string   `json:"url"`
+	Events     []string `json:"events"`
+	Name       string   `json:"name"`
+	Scopes     []string `json:"scopes"`
+	Inviter    string   `json:"inviter"`
+}
+
+// AdminHandler serve the operators' endpoints, requests must carry
+// the admin token as a Bearer, an empty token disable the endpoints
+type AdminHandler struct {
+	adminSvc      services.IAdminService
+	webhookSvc    services.IWebhookService
+	apiKeySvc     services.IAPIKeyService
+	invitationSvc services.IInvitationService
+	token         string
+}
+
+func NewAdminHandler(adminSvc services.IAdminService, webhookSvc services.IWebhookService, apiKeySvc services.IAPIKeyService, invitationSvc services.IInvitationService, token string) IAdminHandler {
+	return AdminHandler{adminSvc, webhookSvc, apiKeySvc, invitationSvc, token}
+}
+
+func (a AdminHandler) ClientCreate(w http.ResponseWriter, r *http.Request) {
+	req, ok := a.decode(w, r, "ClientCreate")
+	if !ok {
+		return
+	}
+
+	client, ce := a.adminSvc.AdminClientCreate(r.Context(), req.ID, req.Domain, req.UserID, req.Tenant)
+	if ce != nil {
+		writeError(w, ce)
+		return
+	}
+
+	writeResponse(w, http.StatusCreated, client)
+}
+
+func (a AdminHandler) ClientRotate(w http.ResponseWriter, r *http.Request) {
+	req, ok := a.decode(w, r, "ClientRotate")
+	if !ok {
+		return
+	}
+
+	client, ce := a.adminSvc.AdminClientRotate(r.Context(), req.ID)
+	if ce != nil {
+		writeError(w, ce)
+		return
+	}
+
+	writeResponse(w, http.StatusOK, client)
+}
+
+func (a AdminHandler) ClientRevoke(w http.ResponseWriter, r *http.Request) {
+	req, ok := a.decode(w, r, "ClientRevoke")
+	if !ok {
+		return
+	}
+
+	if ce := a.adminSvc.AdminClientRevoke(r.Context(), req.ID); ce != nil {
+		writeError(w, ce)
+		return
+	}
+
+	writeResponse(w, http.StatusOK, map[string]string{"id": req.ID, "status": "revoked"})
+}
+
+func (a AdminHandler) UserList(w http.ResponseWriter, r *http.Request) {
+	obs.Logging.NewLogHandler(obs.Logging.LLHDebug()).
+		Msg("UserList - hit admin handler")
+
+	if !a.authorize(w, r) {
+		return
+	}
+
+	skip, _ := strconv.ParseInt(r.URL.Query().Get("skip"), 10, 64)
+	limit, _ := strconv.ParseInt(r.URL.Query().Get("limit"), 10, 64)
+
+	users, ce := a.adminSvc.AdminUserList(r.Context(), skip, limit)
+	if ce != nil {
+		writeError(w, ce)
+		return
+	}
+
+	writeResponse(w, http.StatusOK, users)
+}
+
+func (a AdminHandler) UserDisable(w http.ResponseWriter, r *http.Request) {
+	req, ok := a.decode(w, r, "UserDisable")
+	if !ok {
+		return
+	}
+
+	if ce := a.adminSvc.AdminUserDisable(r.Context(), req.Tenant, req.Email); ce != nil {
+		writeError(w, ce)
+		return
+	}
+
+	writeResponse(w, http.StatusOK, map[string]string{"email": req.Email, "status": "disabled"})
+}
+
+func (a AdminHandler) UserDelete(w http.ResponseWriter, r *http.Request) {
+	req, ok := a.decode(w, r, "UserDelete")
+	if !ok {
+		return
+	}
+
+	if ce := a.adminSvc.AdminUserDelete(r.Context(), req.Tenant, req.Email); ce != nil {
+		writeError(w, ce)
+		return
+	}
+
+	writeResponse(w, http.StatusOK, map[string]string{"email": req.Email, "status": "deleted"})
+}
+
+func (a AdminHandler) SessionRevoke(w http.ResponseWriter, r *http.Request) {
+	req, ok := a.decode(w, r, "SessionRevoke")
+	if !ok {
+		return
+	}
+
+	if ce := a.adminSvc.AdminSessionRevoke(r.Context(), req.Tenant, req.Subject, req.Role); ce != nil {
+		writeError(w, ce)
+		return
+	}
+
+	writeResponse(w, http.StatusOK, map[string]string{"subject": req.Subject, "status": "revoked"})
+}
+
+func (a AdminHandler) KeyRotate(w http.ResponseWriter, r *http.Request) {
+	obs.Logging.NewLogHandler(obs.Logging.LLHDebug()).
+		Msg("KeyRotate - hit admin handler")
+
+	if !a.authorize(w, r) {
+		return
+	}
+
+	keyID, ce := a.adminSvc.AdminKeyRotate(r.Context())
+	if ce != nil {
+		writeError(w, ce)
+		return
+	}
+
+	writeResponse(w, http.StatusOK, map[string]string{"key_id": keyID})
+}
+
+func (a AdminHandler) DebugToken(w http.ResponseWriter, r *http.Request) {
+	req, ok := a.decode(w, r, "DebugToken")
+	if !ok {
+		return
+	}
+
+	ttl := time.Duration(req.TTLSeconds) * time.Second
+	token, ce := a.adminSvc.AdminDebugToken(r.Context(), req.Service, req.Scope, ttl)
+	if ce != nil {
+		writeError(w, ce)
+		return
+	}
+
+	writeResponse(w, http.StatusOK, token)
+}
+
+func (a AdminHandler) WebhookList(w http.ResponseWriter, r *http.Request) {
+	obs.Logging.NewLogHandler(obs.Logging.LLHDebug()).
+		Msg("WebhookList - hit admin handler")
+
+	if !a.authorize(w, r) {
+		return
+	}
+
+	subs, ce := a.webhookSvc.WebhookSubscriptionList()
+	if ce != nil {
+		writeError(w, ce)
+		return
+	}
+
+	writeResponse(w, http.StatusOK, subs)
+}
+
+// WebhookCreate subscribe an url, the secret is only returned here
+func (a AdminHandler) WebhookCreate(w http.ResponseWriter, r *http.Request) {
+	req, ok := a.decode(w, r, "WebhookCreate")
+	if !ok {
+		return
+	}
+
+	sub, ce := a.webhookSvc.WebhookSubscribe(req.URL, req.Events)
+	if ce != nil {
+		writeError(w, ce)
+		return
+	}
+
+	writeResponse(w, http.StatusCreated, sub)
+}
+
+func (a AdminHandler) WebhookDelete(w http.ResponseWriter, r *http.Request) {
+	req, ok := a.decode(w, r, "WebhookDelete")
+	if !ok {
+		return
+	}
+
+	if ce := a.webhookSvc.WebhookUnsubscribe(req.ID); ce != nil {
+		writeError(w, ce)
+		return
+	}
+
+	writeResponse(w, http.StatusOK, map[string]string{"id": req.ID, "status": "deleted"})
+}
+
+func (a AdminHandler) WebhookDeadLetters(w http.ResponseWriter, r *http.Request) {
+	obs.Logging.NewLogHandler(obs.Logging.LLHDebug()).
+		Msg("WebhookDeadLetters - hit admin handler")
+
+	if !a.authorize(w, r) {
+		return
+	}
+
+	skip, _ := strconv.ParseInt(r.URL.Query().Get("skip"), 10, 64)
+	limit, _ := strconv.ParseInt(r.URL.Query().Get("limit"), 10, 64)
+
+	dls, ce := a.webhookSvc.WebhookDeadLetterList(skip, limit)
+	if ce != nil {
+		writeError(w, ce)
+		return
+	}
+
+	writeResponse(w, http.StatusOK, dls)
+}
+
+// WebhookReplay queue a dead letter again, it is dead lettered
+// again if all the new attempts fail
+func (a AdminHandler) WebhookReplay(w http.ResponseWriter, r *http.Request) {
+	req, ok := a.decode(w, r, "WebhookReplay")
+	if !ok {
+		return
+	}
+
+	if ce := a.webhookSvc.WebhookReplay(req.ID); ce != nil {
+		writeError(w, ce)
+		return
+	}
+
+	writeResponse(w, http.StatusAccepted, map[string]string{"id": req.ID, "status": "replayed"})
+}
+
+// APIKeyList return the keys of the tenant query param, all keys if not set
+func (a AdminHandler) APIKeyList(w http.ResponseWriter, r *http.Request) {
+	obs.Logging.NewLogHandler(obs.Logging.LLHDebug()).
+		Msg("APIKeyList - hit admin handler")
+
+	if !a.authorize(w, r) {
+		return
+	}
+
+	keys, ce := a.apiKeySvc.APIKeyList(r.Context(), r.URL.Query().Get("tenant"))
+	if ce != nil {
+		writeError(w, ce)
+		return
+	}
+
+	writeResponse(w, http.StatusOK, keys)
+}
+
+// APIKeyCreate create a key, the key is only returned here
+func (a AdminHandler) APIKeyCreate(w http.ResponseWriter, r *http.Request) {
+	req, ok := a.decode(w, r, "APIKeyCreate")
+	if !ok {
+		return
+	}
+
+	ttl := time.Duration(req.TTLSeconds) * time.Second
+	key, ce := a.apiKeySvc.APIKeyCreate(r.Context(), req.Name, req.Tenant, req.Scopes, ttl)
+	if ce != nil {
+		writeError(w, ce)
+		return
+	}
+
+	writeResponse(w, http.StatusCreated, key)
+}
+
+func (a AdminHandler) APIKeyRevoke(w http.ResponseWriter, r *http.Request) {
+	req, ok := a.decode(w, r, "APIKeyRevoke")
+	if !ok {
+		return
+	}
+
+	if ce := a.apiKeySvc.APIKeyRevoke(r.Context(), req.ID); ce != nil {
+		writeError(w, ce)
+		return
+	}
+
+	writeResponse(w, http.StatusOK, map[string]string{"id": req.ID, "status": "revoked"})
+}
+
+// InvitationList return the invitations of the tenant query param, all of them if not set
+func (a AdminHandler) InvitationList(w http.ResponseWriter, r *http.Request) {
+	obs.Logging.NewLogHandler(obs.Logging.LLHDebug()).
+		Msg("InvitationList - hit admin handler")
+
+	if !a.authorize(w, r) {
+		return
+	}
+
+	invs, ce := a.invitationSvc.InvitationList(r.Context(), r.URL.Query().Get("tenant"))
+	if ce != nil {
+		writeError(w, ce)
+		return
+	}
+
+	writeResponse(w, http.StatusOK, invs)
+}
+
+// InvitationCreate issue an invitation, its token is only returned here
+func (a AdminHandler) InvitationCreate(w http.ResponseWriter, r *http.Request) {
+	req, ok := a.decode(w, r, "InvitationCreate")
+	if !ok {
+		return
+	}
+
+	inv, ce := a.invitationSvc.InvitationCreate(r.Context(), req.Tenant, req.Email, req.Role, req.Inviter)
+	if ce != nil {
+		writeError(w, ce)
+		return
+	}
+
+	writeResponse(w, http.StatusCreated, inv)
+}
+
+func (a AdminHandler) InvitationRevoke(w http.ResponseWriter, r *http.Request) {
+	req, ok := a.decode(w, r, "InvitationRevoke")
+	if !ok {
+		return
+	}
+
+	if ce := a.invitationSvc.InvitationRevoke(r.Context(), req.ID); ce != nil {
+		writeError(w, ce)
+		return
+	}
+
+	writeResponse(w, http.StatusOK, map[string]string{"id": req.ID, "status": "revoked"})
+}
+
+// authorize make sure the admin endpoints are enabled and the
+// request carry the admin token
+func (a AdminHandler) authorize(w http.ResponseWriter, r *http.Request) bool {
+	if a.token == "" {
+		writeError(w, e.NewCustomHTTPStatus(e.StatusNotFound))
+		return false
+	}
+
+	bearer := strings.TrimPrefix(r.Header.Get("Authorization"), "Bearer ")
+	if subtle.ConstantTimeCompare([]byte(bearer), []byte(a.token)) != 1 {
+		obs.Logging.NewLogHandler(obs.Logging.LLHError()).
+			Msg("authorize - invalid admin token")
+		writeError(w, e.NewCustomHTTPStatus(e.StatusUnauthorized))
+		return false
+	}
+
+	return true
+}
+
+// decode authorize the request and decode its payload
+func (a AdminHandler) decode(w http.ResponseWriter, r *http.Request, name string) (adminRequest, bool) {
+	obs.Logging.NewLogHandler(obs.Logging.LLHDebug()).
+		Msgf("%v - hit admin handler", name)
+
+	var req adminRequest
+
+	if !a.authorize(w, r) {
+		return req, false
+	}
+
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		obs.Logging.NewLogHandler(obs.Logging.LLHError()).
+			Err(err).
+			Msgf("%v - decode payload failed", name)
+		writeError(w, e.NewCustomHTTPStatus(e.StatusBadRequest, "", "invalid payload"))
+		return req, false
+	}
+
+	return req, true
+}
@@ -1,7 +1,6 @@
 package handlers
 
 import (
-	"encoding/json"
 	"gitlab.com/grpasr/asonrythme/auth_svc/internal/services"
 	e "gitlab.com/grpasr/common/errors/json"
 	obs "gitlab.com/grpasr/common/observability"
@@ -13,6 +12,7 @@ type IAuthenticationHandler interface {
 	Signup(w http.ResponseWriter, r *http.Request)
 	Signin(w http.ResponseWriter, r *http.Request)
 	ApiAuth(w http.ResponseWriter, r *http.Request)
+	VerifyEmail(w http.ResponseWriter, r *http.Request)
 	// Authorize(w http.ResponseWriter, r *http.Request)
 }
 
@@ -34,50 +34,34 @@ func (a AuthenticationHandler) Signout(w http.ResponseWriter, r *http.Request) {
 	// get jwtToken
 	cookie, err := r.Cookie("jwt_token")
 	if err != nil {
-		ce := e.NewCustomHTTPStatus(e.StatusForbidden)
-		json.NewEncoder(w).Encode(ce)
+		writeError(w, e.NewCustomHTTPStatus(e.StatusForbidden))
 		return
 	}
 
-	ce := a.authSvc.SignoutService(r, cookie)
-	if ce != nil {
-		switch ce.GetCode() {
-		case http.StatusUnauthorized:
-			w.WriteHeader(http.StatusUnauthorized)
-		case http.StatusForbidden:
-			w.WriteHeader(http.StatusForbidden)
-		case http.StatusInternalServerError:
-			w.WriteHeader(http.StatusInternalServerError)
-		default:
-			w.WriteHeader(http.StatusBadRequest)
-		}
+	if ce := a.authSvc.SignoutService(r, cookie); ce != nil {
+		writeError(w, ce)
+	}
+}
+
+// VerifyEmail validate the user's email with the code sent at signup
+func (a AuthenticationHandler) VerifyEmail(w http.ResponseWriter, r *http.Request) {
+	obs.Logging.NewLogHandler(obs.Logging.LLHDebug()).
+		Msgf("VerifyEmail - hit handler", r)
 
-		json.NewEncoder(w).Encode(ce)
+	if ce := a.authSvc.VerifyEmailService(r); ce != nil {
+		writeError(w, ce)
+		return
 	}
+
+	writeResponse(w, http.StatusOK, map[string]string{"status": "email validated"})
 }
 
 func (a AuthenticationHandler) Signup(w http.ResponseWriter, r *http.Request) {
 	obs.Logging.NewLogHandler(obs.Logging.LLHDebug()).
 		Msgf("Signup - hit handler", r)
 
-	if r.Method == "POST" {
-		ce := a.authSvc.SignupService(w, r)
-		if ce != nil {
-			switch ce.GetCode() {
-			case http.StatusUnauthorized:
-				w.WriteHeader(http.StatusUnauthorized)
-			case http.StatusBadRequest:
-				w.WriteHeader(http.StatusBadRequest)
-			case http.StatusInternalServerError:
-				w.WriteHeader(http.StatusInternalServerError)
-			default:
-				w.WriteHeader(http.StatusBadRequest)
-			}
-			json.NewEncoder(w).Encode(ce)
-		}
-	} else {
-		w.WriteHeader(http.StatusBadRequest)
-		json.NewEncoder(w).Encode(e.NewCustomHTTPStatus(e.StatusBadRequest))
+	if ce := a.authSvc.SignupService(w, r); ce != nil {
+		writeError(w, ce)
 	}
 }
 
@@ -85,25 +69,8 @@ func (a AuthenticationHandler) Signin(w http.ResponseWriter, r *http.Request) {
 	obs.Logging.NewLogHandler(obs.Logging.LLHDebug()).
 		Msgf("Signin - hit handler", r)
 
-	if r.Method == "POST" {
-		ce := a.authSvc.SigninService(w, r)
-		if ce != nil {
-			switch ce.GetCode() {
-			case http.StatusUnauthorized:
-				w.WriteHeader(http.StatusUnauthorized)
-			case http.StatusBadRequest:
-				w.WriteHeader(http.StatusBadRequest)
-			case http.StatusInternalServerError:
-				w.WriteHeader(http.StatusInternalServerError)
-			default:
-				w.WriteHeader(http.StatusBadRequest)
-			}
-
-			json.NewEncoder(w).Encode(ce)
-		}
-	} else {
-		w.WriteHeader(http.StatusBadRequest)
-		json.NewEncoder(w).Encode(e.NewCustomHTTPStatus(e.StatusBadRequest))
+	if ce := a.authSvc.SigninService(w, r); ce != nil {
+		writeError(w, ce)
 	}
 }
 
@@ -113,25 +80,8 @@ func (a AuthenticationHandler) ApiAuth(w http.ResponseWriter, r *http.Request) {
 	obs.Logging.NewLogHandler(obs.Logging.LLHDebug()).
 		Msgf("ApiAuth - hit handler", r)
 
-	if r.Method == "POST" {
-		ce := a.authSvc.ApiAuthService(w, r)
-		if ce != nil {
-			switch ce.GetCode() {
-			case http.StatusUnauthorized:
-				w.WriteHeader(http.StatusUnauthorized)
-			case http.StatusBadRequest:
-				w.WriteHeader(http.StatusBadRequest)
-			case http.StatusInternalServerError:
-				w.WriteHeader(http.StatusInternalServerError)
-			default:
-				w.WriteHeader(http.StatusBadRequest)
-			}
-
-			json.NewEncoder(w).Encode(ce)
-		}
-	} else {
-		w.WriteHeader(http.StatusBadRequest)
-		json.NewEncoder(w).Encode(e.NewCustomHTTPStatus(e.StatusBadRequest))
+	if ce := a.authSvc.ApiAuthService(w, r); ce != nil {
+		writeError(w, ce)
 	}
 }
 
@@ -151,23 +101,6 @@ func (a AuthenticationHandler) ApiAuth(w http.ResponseWriter, r *http.Request) {
 
 // // =================
 
-// func allowCORS(w http.ResponseWriter, r *http.Request) bool {
-// 	// Set the CORS headers
-// 	w.Header().Set("Access-Control-Allow-Origin", "*")
-// 	// w.Header().Set("Access-Control-Allow-Origin", "http://localhost:3000")
-// 	w.Header().Set("Access-Control-Allow-Methods", "GET, POST, PUT, DELETE, OPTIONS")
-// 	w.Header().Set("Access-Control-Allow-Headers", "Content-Type, Authorization")
-// 	w.Header().Set("Access-Control-Allow-Credentials", "true")
-//
-// 	// Handle preflight requests
-// 	if r.Method == http.MethodOptions {
-// 		w.WriteHeader(http.StatusOK)
-// 		return false
-// 	}
-//
-// 	return true
-// }
-
 // func (a AuthenticationHandler) Authorize(w http.ResponseWriter, r *http.Request) {
 //
 // 	// if carryon := allowCORS(w, r); !carryon {
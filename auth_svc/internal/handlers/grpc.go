@@ -0,0 +1,154 @@
+package handlers
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"net/http"
+
+	pb "gitlab.com/grpasr/asonrythme/auth_svc/api/v1/auth"
+	"gitlab.com/grpasr/asonrythme/auth_svc/internal/services"
+	"gitlab.com/grpasr/asonrythme/shared/health"
+	obs "gitlab.com/grpasr/common/observability"
+	"google.golang.org/grpc"
+)
+
+// AuthGrpcServer serve the token operations to the others services,
+// errors are returned within the response_code as for brokerjwt,
+// the readiness is served along by the grpc.health.v1 service
+type AuthGrpcServer struct {
+	pb.UnimplementedAuthManagementServer
+	jwtSvc    services.IJwtokenService
+	apiKeySvc services.IAPIKeyService
+}
+
+func NewAuthGrpcServer(jwtSvc services.IJwtokenService, apiKeySvc services.IAPIKeyService, hl health.IHealth, opts ...grpc.ServerOption) *grpc.Server {
+	gsrv := grpc.NewServer(opts...)
+
+	pb.RegisterAuthManagementServer(gsrv, &AuthGrpcServer{jwtSvc: jwtSvc, apiKeySvc: apiKeySvc})
+	hl.HealthRegisterGRPC(gsrv, pb.AuthManagement_ServiceDesc.ServiceName)
+
+	return gsrv
+}
+
+func (a *AuthGrpcServer) ValidateToken(ctx context.Context, jwt *pb.Jwtoken) (*pb.ValidateTokenResponse, error) {
+	obs.Logging.NewLogHandler(obs.Logging.LLHDebug()).
+		Msg("ValidateToken - hit grpc handler")
+
+	resp := &pb.ValidateTokenResponse{}
+
+	infos, ce := a.jwtSvc.JwtokenValidate(ctx, jwt.GetJwt())
+	if ce != nil {
+		resp.ResponseCode = int32(ce.GetCode())
+		return resp, nil
+	}
+
+	resp.IsOk = true
+	resp.ResponseCode = http.StatusOK
+	resp.Role = infos["role"]
+	resp.Svc = infos["svc"]
+	resp.Scope = infos["scope"]
+	resp.Tenant = infos["tenant"]
+
+	return resp, nil
+}
+
+func (a *AuthGrpcServer) GetTokenClaims(ctx context.Context, jwt *pb.Jwtoken) (*pb.GetTokenClaimsResponse, error) {
+	obs.Logging.NewLogHandler(obs.Logging.LLHDebug()).
+		Msg("GetTokenClaims - hit grpc handler")
+
+	resp := &pb.GetTokenClaimsResponse{}
+
+	claims, ce := a.jwtSvc.JwtokenGetClaims(ctx, jwt.GetJwt())
+	if ce != nil {
+		resp.ResponseCode = int32(ce.GetCode())
+		return resp, nil
+	}
+
+	resp.ResponseCode = http.StatusOK
+	resp.Sub = claims.Subject
+	resp.Aud = claims.Audience
+	resp.ExpiresAt = claims.ExpiresAt
+	resp.OpenidInfo = claims.OpenidInfo
+
+	return resp, nil
+}
+
+func (a *AuthGrpcServer) CheckPermission(ctx context.Context, req *pb.CheckPermissionRequest) (*pb.CheckPermissionResponse, error) {
+	obs.Logging.NewLogHandler(obs.Logging.LLHDebug()).
+		Msg("CheckPermission - hit grpc handler")
+
+	resp := &pb.CheckPermissionResponse{}
+
+	scope, ce := a.jwtSvc.JwtokenCheckPermission(ctx, req.GetJwt(), req.GetPermission())
+	resp.Scope = scope
+	if ce != nil {
+		resp.ResponseCode = int32(ce.GetCode())
+		return resp, nil
+	}
+
+	resp.IsAllowed = true
+	resp.ResponseCode = http.StatusOK
+
+	return resp, nil
+}
+
+func (a *AuthGrpcServer) RefreshToken(ctx context.Context, jwt *pb.Jwtoken) (*pb.RefreshTokenResponse, error) {
+	obs.Logging.NewLogHandler(obs.Logging.LLHDebug()).
+		Msg("RefreshToken - hit grpc handler")
+
+	resp := &pb.RefreshTokenResponse{}
+
+	token, ce := a.jwtSvc.JwtokenRefresh(ctx, jwt.GetJwt())
+	if ce != nil {
+		resp.ResponseCode = int32(ce.GetCode())
+		return resp, nil
+	}
+
+	resp.ResponseCode = http.StatusOK
+	resp.Jwt = token
+
+	return resp, nil
+}
+
+func (a *AuthGrpcServer) ValidateAPIKey(ctx context.Context, req *pb.APIKey) (*pb.ValidateAPIKeyResponse, error) {
+	obs.Logging.NewLogHandler(obs.Logging.LLHDebug()).
+		Msg("ValidateAPIKey - hit grpc handler")
+
+	resp := &pb.ValidateAPIKeyResponse{}
+
+	kd, ce := a.apiKeySvc.APIKeyValidate(ctx, req.GetKey())
+	if ce != nil {
+		resp.ResponseCode = int32(ce.GetCode())
+		return resp, nil
+	}
+
+	resp.IsOk = true
+	resp.ResponseCode = http.StatusOK
+	resp.KeyId = kd.ID
+	resp.Name = kd.Name
+	resp.Tenant = kd.Tenant
+	resp.Scopes = kd.Scopes
+
+	return resp, nil
+}
+
+// GrpcListen serve the grpc server, it blocks
+func GrpcListen(gsrv *grpc.Server, grpcPort string) {
+	lis, err := net.Listen("tcp", fmt.Sprintf(":%v", grpcPort))
+	if err != nil {
+		obs.Logging.NewLogHandler(obs.Logging.LLHFatal()).
+			Err(err).
+			Msg("err creating grpc listener")
+	}
+
+	obs.Logging.NewLogHandler(obs.Logging.LLHInfo()).
+		Str("GRPC listen on port: ", grpcPort).
+		Send()
+	err = gsrv.Serve(lis)
+	if err != nil {
+		obs.Logging.NewLogHandler(obs.Logging.LLHFatal()).
+			Err(err).
+			Msg("err grpc server listen")
+	}
+}
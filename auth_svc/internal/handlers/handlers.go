@@ -1,54 +1,218 @@
 package handlers
 
 import (
+	"context"
+	"encoding/json"
+	"errors"
 	"fmt"
-	"log"
+	"github.com/gorilla/mux"
+	"gitlab.com/grpasr/asonrythme/shared/health"
+	e "gitlab.com/grpasr/common/errors/json"
+	obs "gitlab.com/grpasr/common/observability"
 	"net/http"
+	"sort"
+	"strings"
+	"time"
 )
 
+// ServerConfig set the http server and its middlewares
+type ServerConfig struct {
+	Port               int
+	ReadTimeout        time.Duration
+	WriteTimeout       time.Duration
+	IdleTimeout        time.Duration
+	ShutdownTimeout    time.Duration
+	MaxBodyBytes       int64
+	CORSAllowedOrigins []string
+}
+
 type Handlers struct {
 	authHandler  IAuthenticationHandler
 	tokenHandler ITokenHandler
+	adminHandler IAdminHandler
+	mglHandler   IMagicLinkHandler
+	health       health.IHealth
 }
 
-func NewHandlers(a IAuthenticationHandler, t ITokenHandler) Handlers {
+func NewHandlers(a IAuthenticationHandler, t ITokenHandler, ad IAdminHandler, ml IMagicLinkHandler, hl health.IHealth) Handlers {
 	return Handlers{
 		authHandler:  a,
 		tokenHandler: t,
+		adminHandler: ad,
+		mglHandler:   ml,
+		health:       hl,
 	}
 }
 
-func (h Handlers) Run(portvar int) {
-	// TODO set all handler in a separate func
+// Router return the routes wrapped in the middlewares:
+// requestID > accessLog > recovery > cors > bodyLimit > routes > tracing
+func (h Handlers) Router(cfg ServerConfig) http.Handler {
+	router := mux.NewRouter()
+	router.NotFoundHandler = http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		writeError(w, e.NewCustomHTTPStatus(e.StatusNotFound))
+	})
+	router.MethodNotAllowedHandler = methodNotAllowedHandler(router)
+
 	// Endpoints for the front-end
 	// (use this service for the example but a specific users' service may be better)
-	http.HandleFunc("/v1/signup", h.authHandler.Signup)
-	http.HandleFunc("/v1/signin", h.authHandler.Signin)
-	http.HandleFunc("/v1/signout", h.authHandler.Signout)
+	router.HandleFunc("/v1/signup", h.authHandler.Signup).Methods(http.MethodPost)
+	router.HandleFunc("/v1/signin", h.authHandler.Signin).Methods(http.MethodPost)
+	router.HandleFunc("/v1/signout", h.authHandler.Signout).Methods(http.MethodGet, http.MethodPost)
+	router.HandleFunc("/v1/verifyemail", h.authHandler.VerifyEmail).Methods(http.MethodPost)
+	router.HandleFunc("/v1/magiclink", h.mglHandler.MagicLink).Methods(http.MethodPost)
+	router.HandleFunc("/v1/magiclink/callback", h.mglHandler.MagicLinkCallback).Methods(http.MethodGet, http.MethodPost)
 
 	// Endpoints for the backend services to authenticate and get their token
-	http.HandleFunc("/v1/apiauth", h.authHandler.ApiAuth)
-	http.HandleFunc("/v1/refreshopenid", h.tokenHandler.RefreshOpenid)
+	router.HandleFunc("/v1/apiauth", h.authHandler.ApiAuth).Methods(http.MethodPost)
+	router.HandleFunc("/v1/refreshopenid", h.tokenHandler.RefreshOpenid).Methods(http.MethodGet, http.MethodPost)
 
 	// Endpoints specific to validate the authorization
-	// http.HandleFunc("/v1/oauth/authorize", h.authHandler.Authorize)
-	http.HandleFunc("/v1/oauth/token", h.tokenHandler.Token)
+	router.HandleFunc("/v1/oauth/token", h.tokenHandler.Token).Methods(http.MethodGet, http.MethodPost)
 
 	// Endpoint which validate a client's token and the given permission
-	http.HandleFunc("/v1/jwtvalidation", h.tokenHandler.JwtValidation)
-	http.HandleFunc("/v1/jwtgetdata", h.tokenHandler.JwtGetdata)
-	http.HandleFunc("/v1/permission", h.tokenHandler.ValidPermission)
-
-	// healthcheck endpoint
-	http.HandleFunc("/v1/health", func(w http.ResponseWriter, r *http.Request) {
-		fmt.Println("auth-svc, reach the healthcheck..................")
-		w.WriteHeader(http.StatusOK)
-		w.Write([]byte("OK"))
+	router.HandleFunc("/v1/jwtvalidation", h.tokenHandler.JwtValidation).Methods(http.MethodGet, http.MethodPost)
+	router.HandleFunc("/v1/jwtgetdata", h.tokenHandler.JwtGetdata).Methods(http.MethodGet, http.MethodPost)
+	router.HandleFunc("/v1/permission", h.tokenHandler.ValidPermission).Methods(http.MethodGet, http.MethodPost)
+
+	// Endpoint publishing the signing keys to the services validating the jwt
+	router.HandleFunc("/v1/keys", h.tokenHandler.Keys).Methods(http.MethodGet)
+
+	// Endpoints for the operators(see cmd/authctl)
+	router.HandleFunc("/v1/admin/clients", h.adminHandler.ClientCreate).Methods(http.MethodPost)
+	router.HandleFunc("/v1/admin/clients/rotate", h.adminHandler.ClientRotate).Methods(http.MethodPost)
+	router.HandleFunc("/v1/admin/clients/revoke", h.adminHandler.ClientRevoke).Methods(http.MethodPost)
+	router.HandleFunc("/v1/admin/users", h.adminHandler.UserList).Methods(http.MethodGet)
+	router.HandleFunc("/v1/admin/users/disable", h.adminHandler.UserDisable).Methods(http.MethodPost)
+	router.HandleFunc("/v1/admin/users/delete", h.adminHandler.UserDelete).Methods(http.MethodPost)
+	router.HandleFunc("/v1/admin/sessions/revoke", h.adminHandler.SessionRevoke).Methods(http.MethodPost)
+	router.HandleFunc("/v1/admin/keys/rotate", h.adminHandler.KeyRotate).Methods(http.MethodPost)
+	router.HandleFunc("/v1/admin/debugtoken", h.adminHandler.DebugToken).Methods(http.MethodPost)
+	router.HandleFunc("/v1/admin/webhooks", h.adminHandler.WebhookList).Methods(http.MethodGet)
+	router.HandleFunc("/v1/admin/webhooks", h.adminHandler.WebhookCreate).Methods(http.MethodPost)
+	router.HandleFunc("/v1/admin/webhooks/delete", h.adminHandler.WebhookDelete).Methods(http.MethodPost)
+	router.HandleFunc("/v1/admin/webhooks/deadletters", h.adminHandler.WebhookDeadLetters).Methods(http.MethodGet)
+	router.HandleFunc("/v1/admin/webhooks/replay", h.adminHandler.WebhookReplay).Methods(http.MethodPost)
+	router.HandleFunc("/v1/admin/apikeys", h.adminHandler.APIKeyList).Methods(http.MethodGet)
+	router.HandleFunc("/v1/admin/apikeys", h.adminHandler.APIKeyCreate).Methods(http.MethodPost)
+	router.HandleFunc("/v1/admin/apikeys/revoke", h.adminHandler.APIKeyRevoke).Methods(http.MethodPost)
+	router.HandleFunc("/v1/admin/invitations", h.adminHandler.InvitationList).Methods(http.MethodGet)
+	router.HandleFunc("/v1/admin/invitations", h.adminHandler.InvitationCreate).Methods(http.MethodPost)
+	router.HandleFunc("/v1/admin/invitations/revoke", h.adminHandler.InvitationRevoke).Methods(http.MethodPost)
+
+	// healthcheck endpoints, /v1/health is kept as the liveness
+	router.HandleFunc("/v1/health", h.health.LivenessHandler).Methods(http.MethodGet)
+	router.HandleFunc("/v1/health/live", h.health.LivenessHandler).Methods(http.MethodGet)
+	router.HandleFunc("/v1/health/ready", h.health.ReadinessHandler).Methods(http.MethodGet)
+
+	// the span is started once the route is matched, to be named after it
+	router.Use(tracingMiddleware)
+
+	// the cors middleware must run before the routing, so the
+	// preflight requests do not end up as method not allowed
+	var handler http.Handler = router
+	handler = bodyLimitMiddleware(cfg.MaxBodyBytes)(handler)
+	handler = corsMiddleware(cfg.CORSAllowedOrigins)(handler)
+	handler = recoveryMiddleware(handler)
+	handler = accessLogMiddleware(handler)
+	handler = requestIDMiddleware(handler)
+
+	return handler
+}
+
+// Run serve until ctx is done, then stop accepting connections and
+// wait, up to the shutdown timeout, for the requests in flight
+func (h Handlers) Run(ctx context.Context, cfg ServerConfig) error {
+	srv := &http.Server{
+		Addr:              fmt.Sprintf(":%d", cfg.Port),
+		Handler:           h.Router(cfg),
+		ReadHeaderTimeout: cfg.ReadTimeout,
+		ReadTimeout:       cfg.ReadTimeout,
+		WriteTimeout:      cfg.WriteTimeout,
+		IdleTimeout:       cfg.IdleTimeout,
+	}
+
+	errc := make(chan error, 1)
+	go func() {
+		obs.Logging.NewLogHandler(obs.Logging.LLHInfo()).
+			Msg(fmt.Sprintf("HTTP listen on port: %d", cfg.Port))
+		errc <- srv.ListenAndServe()
+	}()
+
+	select {
+	case err := <-errc:
+		return err
+	case <-ctx.Done():
+	}
+
+	obs.Logging.NewLogHandler(obs.Logging.LLHInfo()).
+		Msg("HTTP server shutting down, draining the requests in flight")
+
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), cfg.ShutdownTimeout)
+	defer cancel()
+
+	if err := srv.Shutdown(shutdownCtx); err != nil {
+		return err
+	}
+	if err := <-errc; err != nil && !errors.Is(err, http.ErrServerClosed) {
+		return err
+	}
+
+	obs.Logging.NewLogHandler(obs.Logging.LLHInfo()).
+		Msg("HTTP server stopped")
+
+	return nil
+}
+
+// methodNotAllowedHandler answer 405 with the methods of the route in
+// the Allow header(rfc9110 section 15.5.6)
+func methodNotAllowedHandler(router *mux.Router) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Allow", strings.Join(allowedMethods(router, r), ", "))
+		writeError(w, e.NewCustomHTTPStatus(http.StatusMethodNotAllowed, r.URL.Path, "method not allowed"))
+	})
+}
+
+// allowedMethods are the methods of the routes matching r but its method
+func allowedMethods(router *mux.Router, r *http.Request) []string {
+	seen := map[string]bool{}
+	methods := []string{}
+	router.Walk(func(route *mux.Route, _ *mux.Router, _ []*mux.Route) error {
+		match := &mux.RouteMatch{}
+		if !route.Match(r, match) && match.MatchErr != mux.ErrMethodMismatch {
+			return nil
+		}
+		ms, err := route.GetMethods()
+		if err != nil {
+			return nil
+		}
+		for _, m := range ms {
+			if !seen[m] {
+				seen[m] = true
+				methods = append(methods, m)
+			}
+		}
+		return nil
 	})
+	sort.Strings(methods)
+	return methods
+}
+
+// writeError write the error with its status, a status which is not
+// an error one is reported as an internal error
+func writeError(w http.ResponseWriter, ce e.IError) {
+	code := ce.GetCode()
+	if code < http.StatusBadRequest || code > 599 {
+		ce = e.NewCustomHTTPStatus(e.StatusInternalServerError)
+		code = http.StatusInternalServerError
+	}
 
-	log.Printf("Server is running at %d port.\n", portvar)
-	log.Printf("Point your OAuth client Auth endpoint to %s:%d%s", "http://localhost", portvar, "/oauth/authorize")
-	log.Printf("Point your OAuth client Token endpoint to %s:%d%s", "http://localhost", portvar, "/oauth/token")
-	log.Fatal(http.ListenAndServe(fmt.Sprintf(":%d", portvar), nil))
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(code)
+	json.NewEncoder(w).Encode(ce)
+}
 
+func writeResponse(w http.ResponseWriter, status int, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(v)
 }
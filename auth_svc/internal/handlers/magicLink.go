@@ -0,0 +1,44 @@
+package handlers
+
+import (
+	"gitlab.com/grpasr/asonrythme/auth_svc/internal/services"
+	obs "gitlab.com/grpasr/common/observability"
+	"net/http"
+)
+
+type IMagicLinkHandler interface {
+	MagicLink(w http.ResponseWriter, r *http.Request)
+	MagicLinkCallback(w http.ResponseWriter, r *http.Request)
+}
+
+type MagicLinkHandler struct {
+	magicLinkSvc services.IMagicLinkService
+}
+
+func NewMagicLinkHandler(ml services.IMagicLinkService) IMagicLinkHandler {
+	return MagicLinkHandler{ml}
+}
+
+// MagicLink email a login link, the response is the same
+// whether the email exist or not
+func (m MagicLinkHandler) MagicLink(w http.ResponseWriter, r *http.Request) {
+	obs.Logging.NewLogHandler(obs.Logging.LLHDebug()).
+		Msgf("MagicLink - hit handler", r)
+
+	if ce := m.magicLinkSvc.MagicLinkRequestService(r); ce != nil {
+		writeError(w, ce)
+		return
+	}
+
+	writeResponse(w, http.StatusAccepted, map[string]string{"status": "if the account exists, a link has been sent"})
+}
+
+// MagicLinkCallback consume the link and respond with the authorization code
+func (m MagicLinkHandler) MagicLinkCallback(w http.ResponseWriter, r *http.Request) {
+	obs.Logging.NewLogHandler(obs.Logging.LLHDebug()).
+		Msgf("MagicLinkCallback - hit handler", r)
+
+	if ce := m.magicLinkSvc.MagicLinkCallbackService(w, r); ce != nil {
+		writeError(w, ce)
+	}
+}
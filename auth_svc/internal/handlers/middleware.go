@@ -0,0 +1,181 @@
+package handlers
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"github.com/gorilla/mux"
+	e "gitlab.com/grpasr/common/errors/json"
+	obs "gitlab.com/grpasr/common/observability"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/propagation"
+	"net/http"
+	"runtime/debug"
+	"strings"
+	"time"
+)
+
+const requestIDHeader = "X-Request-ID"
+
+type ctxKey int
+
+const requestIDKey ctxKey = iota
+
+// RequestID return the id the requestID middleware set on the request
+func RequestID(ctx context.Context) string {
+	id, _ := ctx.Value(requestIDKey).(string)
+	return id
+}
+
+// requestIDMiddleware keep the caller's X-Request-ID, or generate one,
+// it is set on the response and in the request's context
+func requestIDMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		id := r.Header.Get(requestIDHeader)
+		if id == "" || len(id) > 64 {
+			b := make([]byte, 8)
+			_, _ = rand.Read(b)
+			id = hex.EncodeToString(b)
+		}
+
+		w.Header().Set(requestIDHeader, id)
+		next.ServeHTTP(w, r.WithContext(context.WithValue(r.Context(), requestIDKey, id)))
+	})
+}
+
+// recoveryMiddleware turn a panic into a 500, the server keep running
+func recoveryMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		defer func() {
+			if rec := recover(); rec != nil {
+				if rec == http.ErrAbortHandler {
+					panic(rec)
+				}
+				obs.Logging.NewLogHandler(obs.Logging.LLHError()).
+					Msg(fmt.Sprintf("recoveryMiddleware - [%v] %v %v panic: %v\n%s",
+						RequestID(r.Context()), r.Method, r.URL.Path, rec, debug.Stack()))
+				writeError(w, e.NewCustomHTTPStatus(e.StatusInternalServerError))
+			}
+		}()
+		next.ServeHTTP(w, r)
+	})
+}
+
+// tracingMiddleware start the request's span, named after the route,
+// within the caller's trace if the request carry one
+func tracingMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		route := r.URL.Path
+		if cr := mux.CurrentRoute(r); cr != nil {
+			if tpl, err := cr.GetPathTemplate(); err == nil {
+				route = tpl
+			}
+		}
+
+		ctx := otel.GetTextMapPropagator().Extract(r.Context(), propagation.HeaderCarrier(r.Header))
+		ctx, span := obs.Tracing.SPNGetFromCTX(ctx, fmt.Sprintf("HTTP %v %v", r.Method, route),
+			obs.Tracing.TAString("http.method", r.Method),
+			obs.Tracing.TAString("http.route", route),
+			obs.Tracing.TAString("request.id", RequestID(r.Context())))
+		defer span.End()
+
+		next.ServeHTTP(w, r.WithContext(ctx))
+	})
+}
+
+// statusRecorder keep the status written by the handler
+type statusRecorder struct {
+	http.ResponseWriter
+	status int
+	size   int
+}
+
+func (s *statusRecorder) WriteHeader(code int) {
+	if s.status == 0 {
+		s.status = code
+	}
+	s.ResponseWriter.WriteHeader(code)
+}
+
+func (s *statusRecorder) Write(b []byte) (int, error) {
+	if s.status == 0 {
+		s.status = http.StatusOK
+	}
+	n, err := s.ResponseWriter.Write(b)
+	s.size += n
+	return n, err
+}
+
+// accessLogMiddleware log a line per request, the query is left out
+// as it may carry credentials
+func accessLogMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		start := time.Now()
+		rec := &statusRecorder{ResponseWriter: w}
+
+		next.ServeHTTP(rec, r)
+
+		if rec.status == 0 {
+			rec.status = http.StatusOK
+		}
+		obs.Logging.NewLogHandler(obs.Logging.LLHInfo()).
+			Msg(fmt.Sprintf("[%v] %v %v %v %vB %v",
+				RequestID(r.Context()), r.Method, r.URL.Path, rec.status, rec.size, time.Since(start)))
+	})
+}
+
+// corsMiddleware allow the listed origins, with credentials as the
+// jwt_token is a cookie, and answer the preflight requests. A "*" entry
+// allow any other origin but without credentials, a site the user visit
+// must not be able to act with its cookies
+func corsMiddleware(allowedOrigins []string) func(http.Handler) http.Handler {
+	allowed := make(map[string]bool, len(allowedOrigins))
+	for _, o := range allowedOrigins {
+		allowed[strings.TrimRight(o, "/")] = true
+	}
+
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			origin := r.Header.Get("Origin")
+			switch {
+			case origin == "":
+			case allowed[origin]:
+				w.Header().Set("Access-Control-Allow-Origin", origin)
+				w.Header().Set("Access-Control-Allow-Credentials", "true")
+				w.Header().Add("Vary", "Origin")
+				setCORSHeaders(w)
+			case allowed["*"]:
+				w.Header().Set("Access-Control-Allow-Origin", "*")
+				setCORSHeaders(w)
+			}
+
+			// preflight
+			if r.Method == http.MethodOptions && r.Header.Get("Access-Control-Request-Method") != "" {
+				w.WriteHeader(http.StatusNoContent)
+				return
+			}
+
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+func setCORSHeaders(w http.ResponseWriter) {
+	w.Header().Set("Access-Control-Allow-Methods", "GET, POST, PUT, DELETE, OPTIONS")
+	w.Header().Set("Access-Control-Allow-Headers", "Content-Type, Authorization, "+requestIDHeader)
+}
+
+// bodyLimitMiddleware fail the reads of a body bigger than max bytes
+func bodyLimitMiddleware(max int64) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if r.ContentLength > max {
+				writeError(w, e.NewCustomHTTPStatus(e.StatusBadRequest, r.URL.Path, "request body too large"))
+				return
+			}
+			r.Body = http.MaxBytesReader(w, r.Body, max)
+			next.ServeHTTP(w, r)
+		})
+	}
+}
@@ -0,0 +1,89 @@
+package handlers
+
+import (
+	"github.com/gorilla/mux"
+	"gitlab.com/grpasr/common/tests"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+var okHandler = http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+	w.WriteHeader(http.StatusOK)
+})
+
+func TestMethodNotAllowed(t *testing.T) {
+	tests.MaybeFail = tests.InitFailFunc(t)
+
+	router := mux.NewRouter()
+	router.MethodNotAllowedHandler = methodNotAllowedHandler(router)
+	router.Handle("/v1/signout", okHandler).Methods(http.MethodGet, http.MethodPost)
+	router.Handle("/v1/admin/webhooks", okHandler).Methods(http.MethodGet)
+	router.Handle("/v1/admin/webhooks", okHandler).Methods(http.MethodPost)
+
+	serve := func(method, path string) *httptest.ResponseRecorder {
+		rec := httptest.NewRecorder()
+		router.ServeHTTP(rec, httptest.NewRequest(method, path, nil))
+		return rec
+	}
+
+	rec := serve(http.MethodDelete, "/v1/signout")
+	tests.MaybeFail("methodNotAllowed",
+		tests.Expect(rec.Code, http.StatusMethodNotAllowed),
+		tests.Expect(rec.Header().Get("Allow"), "GET, POST"))
+
+	// the methods of the routes sharing the path are all allowed
+	rec = serve(http.MethodPut, "/v1/admin/webhooks")
+	tests.MaybeFail("methodNotAllowed_routes",
+		tests.Expect(rec.Code, http.StatusMethodNotAllowed),
+		tests.Expect(rec.Header().Get("Allow"), "GET, POST"))
+
+	tests.MaybeFail("methodNotAllowed_allowed", tests.Expect(serve(http.MethodPost, "/v1/admin/webhooks").Code, http.StatusOK))
+	tests.MaybeFail("methodNotAllowed_not_found", tests.Expect(serve(http.MethodGet, "/v1/unknown").Code, http.StatusNotFound))
+}
+
+func TestCORSMiddleware(t *testing.T) {
+	tests.MaybeFail = tests.InitFailFunc(t)
+
+	serve := func(origins []string, origin, method string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, "/v1/signin", nil)
+		if origin != "" {
+			req.Header.Set("Origin", origin)
+		}
+		if method == http.MethodOptions {
+			req.Header.Set("Access-Control-Request-Method", http.MethodPost)
+		}
+		rec := httptest.NewRecorder()
+		corsMiddleware(origins)(okHandler).ServeHTTP(rec, req)
+		return rec
+	}
+
+	// a listed origin is allowed with the credentials
+	rec := serve([]string{"http://localhost:3000/"}, "http://localhost:3000", http.MethodPost)
+	tests.MaybeFail("cors_listed",
+		tests.Expect(rec.Code, http.StatusOK),
+		tests.Expect(rec.Header().Get("Access-Control-Allow-Origin"), "http://localhost:3000"),
+		tests.Expect(rec.Header().Get("Access-Control-Allow-Credentials"), "true"))
+
+	rec = serve([]string{"http://localhost:3000"}, "http://evil.example", http.MethodPost)
+	tests.MaybeFail("cors_not_listed",
+		tests.Expect(rec.Header().Get("Access-Control-Allow-Origin"), ""),
+		tests.Expect(rec.Header().Get("Access-Control-Allow-Credentials"), ""))
+
+	// "*" never come with the credentials
+	rec = serve([]string{"*"}, "http://evil.example", http.MethodPost)
+	tests.MaybeFail("cors_star",
+		tests.Expect(rec.Header().Get("Access-Control-Allow-Origin"), "*"),
+		tests.Expect(rec.Header().Get("Access-Control-Allow-Credentials"), ""))
+
+	// a listed origin keep its credentials next to "*"
+	rec = serve([]string{"*", "http://localhost:3000"}, "http://localhost:3000", http.MethodPost)
+	tests.MaybeFail("cors_star_and_listed",
+		tests.Expect(rec.Header().Get("Access-Control-Allow-Origin"), "http://localhost:3000"),
+		tests.Expect(rec.Header().Get("Access-Control-Allow-Credentials"), "true"))
+
+	rec = serve([]string{"http://localhost:3000"}, "http://localhost:3000", http.MethodOptions)
+	tests.MaybeFail("cors_preflight",
+		tests.Expect(rec.Code, http.StatusNoContent),
+		tests.Expect(rec.Header().Get("Access-Control-Allow-Methods") != "", true))
+}
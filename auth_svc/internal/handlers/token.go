@@ -19,28 +19,25 @@ type ITokenHandler interface {
 	JwtGetdata(w http.ResponseWriter, r *http.Request)
 	JwtValidation(w http.ResponseWriter, r *http.Request)
 	ValidPermission(w http.ResponseWriter, r *http.Request)
+	Keys(w http.ResponseWriter, r *http.Request)
 }
 
 type TokenHandler struct {
-	srv services.ITokenService
+	srv  services.ITokenService
+	keys services.IKeySetService
 }
 
-func NewTokenHandler(srv services.ITokenService) ITokenHandler {
-	return TokenHandler{srv}
+func NewTokenHandler(srv services.ITokenService, ks services.IKeySetService) ITokenHandler {
+	return TokenHandler{srv, ks}
 }
 
 func (t TokenHandler) RefreshOpenid(w http.ResponseWriter, r *http.Request) {
 	obs.Logging.NewLogHandler(obs.Logging.LLHDebug()).
 		Msgf("RefreshOpenid - hit handler", r)
 
-	// w.Header().Set("Content-Type", "application/json")
-
-	// fmt.Println("Into the RefreshOpenid, get email from expiredToken: ")
-
 	cookie, err := r.Cookie("jwt_token")
 	if err != nil {
-		w.WriteHeader(http.StatusForbidden)
-		json.NewEncoder(w).Encode(e.NewCustomHTTPStatus(e.StatusForbidden))
+		writeError(w, e.NewCustomHTTPStatus(e.StatusForbidden))
 		return
 	}
 
@@ -54,18 +51,8 @@ func (t TokenHandler) Token(w http.ResponseWriter, r *http.Request) {
 
 	authHeader := r.Header.Get("Authorization")
 
-	ce := t.srv.TokenService(w, r, authHeader)
-	if ce != nil {
-		switch ce.GetCode() {
-		case http.StatusUnauthorized:
-			w.WriteHeader(http.StatusUnauthorized)
-		case http.StatusInternalServerError:
-			w.WriteHeader(http.StatusInternalServerError)
-		default:
-			ce = e.NewCustomHTTPStatus(e.StatusInternalServerError)
-			w.WriteHeader(http.StatusInternalServerError)
-		}
-		json.NewEncoder(w).Encode(ce)
+	if ce := t.srv.TokenService(w, r, authHeader); ce != nil {
+		writeError(w, ce)
 	}
 }
 
@@ -73,31 +60,19 @@ func (t TokenHandler) JwtGetdata(w http.ResponseWriter, r *http.Request) {
 	obs.Logging.NewLogHandler(obs.Logging.LLHDebug()).
 		Msgf("JwtGetdata - hit handler", r)
 
-	var ce e.IError
 	cookie, err := r.Cookie("jwt_token")
 	if err != nil {
-		w.WriteHeader(http.StatusForbidden)
-		ce = e.NewCustomHTTPStatus(e.StatusForbidden)
-	} else {
-		data, ce := t.srv.JwtGetdataService(w, r, cookie)
-		if ce != nil {
-			switch ce.GetCode() {
-			case http.StatusUnauthorized:
-				w.WriteHeader(http.StatusUnauthorized)
-			case http.StatusOK:
-				w.WriteHeader(http.StatusOK)
-			default:
-				ce = e.NewCustomHTTPStatus(e.StatusInternalServerError)
-				w.WriteHeader(http.StatusInternalServerError)
-			}
-		} else {
-			w.Header().Set("Content-Type", "application/json")
-			w.WriteHeader(http.StatusOK)
-			json.NewEncoder(w).Encode(data)
-			return
-		}
+		writeError(w, e.NewCustomHTTPStatus(e.StatusForbidden))
+		return
+	}
+
+	data, ce := t.srv.JwtGetdataService(w, r, cookie)
+	if ce != nil {
+		writeError(w, ce)
+		return
 	}
-	json.NewEncoder(w).Encode(ce)
+
+	writeResponse(w, http.StatusOK, data)
 }
 
 // TODO this have to be handle by the apigateway, here for testing
@@ -105,31 +80,19 @@ func (t TokenHandler) JwtValidation(w http.ResponseWriter, r *http.Request) {
 	obs.Logging.NewLogHandler(obs.Logging.LLHDebug()).
 		Msgf("JwtValidation - hit handler", r)
 
-	var ce e.IError
 	cookie, err := r.Cookie("jwt_token")
 	if err != nil {
-		w.WriteHeader(http.StatusForbidden)
-		ce = e.NewCustomHTTPStatus(e.StatusForbidden)
-		json.NewEncoder(w).Encode(ce)
+		writeError(w, e.NewCustomHTTPStatus(e.StatusForbidden))
 		return
-
 	}
 
-	ce = t.srv.JwtValidationService(r, cookie)
-	if ce != nil {
-		switch ce.GetCode() {
-		case http.StatusUnauthorized:
-			w.WriteHeader(http.StatusUnauthorized)
-		default:
-			ce = e.NewCustomHTTPStatus(e.StatusInternalServerError)
-			w.WriteHeader(http.StatusInternalServerError)
-		}
-		json.NewEncoder(w).Encode(ce)
-	} else {
-		// err is nil so token is valid, Respond with an OK status (200)
-		w.WriteHeader(http.StatusOK)
+	if ce := t.srv.JwtValidationService(r, cookie); ce != nil {
+		writeError(w, ce)
+		return
 	}
 
+	// the token is valid
+	w.WriteHeader(http.StatusOK)
 }
 
 // Endpoint to validate token and permission
@@ -139,22 +102,33 @@ func (t TokenHandler) ValidPermission(w http.ResponseWriter, r *http.Request) {
 
 	data, ce := t.srv.ValidPermissionService(w, r)
 	if ce != nil {
-		switch ce.GetCode() {
-		case http.StatusBadRequest:
-			w.WriteHeader(http.StatusBadRequest)
-		default:
-			w.WriteHeader(http.StatusInternalServerError)
-			ce = e.NewCustomHTTPStatus(e.StatusInternalServerError)
-		}
-		json.NewEncoder(w).Encode(ce)
+		writeError(w, ce)
 		return
 	}
 
+	w.Header().Set("Content-Type", "application/json")
 	ej := json.NewEncoder(w)
 	ej.SetIndent("", "  ")
 	ej.Encode(data)
 }
 
+// Keys serve the signing keys to the validators, authenticated with
+// their client credentials(basic auth)
+func (t TokenHandler) Keys(w http.ResponseWriter, r *http.Request) {
+	obs.Logging.NewLogHandler(obs.Logging.LLHDebug()).
+		Msgf("Keys - hit handler", r)
+
+	clientID, clientSecret, _ := r.BasicAuth()
+	set, ce := t.keys.KeySetGet(r.Context(), clientID, clientSecret)
+	if ce != nil {
+		writeError(w, ce)
+		return
+	}
+
+	w.Header().Set("Cache-Control", "no-store")
+	writeResponse(w, http.StatusOK, set)
+}
+
 // func dumpRequest(writer io.Writer, header string, r *http.Request) error {
 // 	data, err := httputil.DumpRequest(r, true)
 // 	if err != nil {
@@ -0,0 +1,54 @@
+package mailer
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+)
+
+// IMailer send the emails of the service(magic links...)
+type IMailer interface {
+	MailerSend(to, subject, body string) error
+}
+
+// FileMailer is the local stand-in of a real mailer,
+// each email is written as a .eml file into dir
+type FileMailer struct {
+	dir string
+	sync.Mutex
+}
+
+func NewFileMailer(dir string) *FileMailer {
+	return &FileMailer{dir: dir}
+}
+
+func (m *FileMailer) MailerSend(to, subject, body string) error {
+	m.Lock()
+	defer m.Unlock()
+
+	if err := os.MkdirAll(m.dir, 0o700); err != nil {
+		return err
+	}
+
+	now := time.Now()
+	name := fmt.Sprintf("%d-%s.eml", now.UnixNano(), sanitize(to))
+
+	content := fmt.Sprintf("Date: %s\r\nTo: %s\r\nSubject: %s\r\n\r\n%s\r\n",
+		now.Format(time.RFC1123Z), to, subject, body)
+
+	return os.WriteFile(filepath.Join(m.dir, name), []byte(content), 0o600)
+}
+
+// sanitize keep the address usable as a file name
+func sanitize(s string) string {
+	return strings.Map(func(r rune) rune {
+		switch {
+		case r >= 'a' && r <= 'z', r >= 'A' && r <= 'Z', r >= '0' && r <= '9', r == '@', r == '.', r == '-', r == '_':
+			return r
+		}
+		return '_'
+	}, s)
+}
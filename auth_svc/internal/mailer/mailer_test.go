@@ -0,0 +1,28 @@
+package mailer
+
+import (
+	"gitlab.com/grpasr/common/tests"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestFileMailerSend(t *testing.T) {
+	tests.MaybeFail = tests.InitFailFunc(t)
+
+	dir := t.TempDir()
+	m := NewFileMailer(filepath.Join(dir, "mails"))
+
+	err := m.MailerSend("robert@example.com", "Your login link", "http://localhost/link")
+	tests.MaybeFail("MailerSend", err)
+
+	files, err := os.ReadDir(filepath.Join(dir, "mails"))
+	tests.MaybeFail("MailerSend_files", err, tests.Expect(len(files), 1))
+
+	content, err := os.ReadFile(filepath.Join(dir, "mails", files[0].Name()))
+	tests.MaybeFail("MailerSend_content", err,
+		tests.Expect(strings.Contains(string(content), "To: robert@example.com"), true),
+		tests.Expect(strings.Contains(string(content), "http://localhost/link"), true),
+		tests.Expect(strings.HasSuffix(files[0].Name(), "-robert@example.com.eml"), true))
+}
@@ -2,6 +2,10 @@ package models
 
 import "fmt"
 
+// TenantDefault is the tenant of the clients which are not assigned one,
+// and of the datas created before the tenants
+const TenantDefault = "default"
+
 func AuthAPIserverKey(clientID string) string {
 	return fmt.Sprintf("apiserver#%s", clientID)
 }
@@ -9,3 +13,8 @@ func AuthAPIserverKey(clientID string) string {
 func AuthUserKey(userID string) string {
 	return fmt.Sprintf("userid#%s", userID)
 }
+
+// TenantKey scope k to the tenant, the same email may exist in several tenants
+func TenantKey(tenant, k string) string {
+	return fmt.Sprintf("%s#%s", tenant, k)
+}
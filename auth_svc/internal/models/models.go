@@ -7,13 +7,16 @@ import (
 
 type UserDatas struct {
 	ID                  primitive.ObjectID `bson:"_id,omitempty"`
+	Tenant              string             `bson:"tenant"`
 	Email               string             `bson:"email"`
 	Password            string             `bson:"password"`
 	Role                string             `bson:"role"`
+	TenantRole          string             `bson:"tenant_role"` // set by the invitation the user signed up with
 	RefreshTK           string             `bson:"refresh_tk"`
 	RefreshJWT          string             `bson:"refresh_jwt"`
 	EmailValidationCode string             `bson:"email_validation_code"`
 	IsEmailValidated    int                `bson:"is_email_validated"`
+	IsDisabled          int                `bson:"is_disabled"`
 	Name                string             `bson:"name"`
 	Age                 string             `bson:"age"`
 	City                string             `bson:"city"`
@@ -22,14 +25,31 @@ type UserDatas struct {
 }
 
 type UserRedisDatas struct {
-	Email    string `redis:"email"`
-	Password string `redis:"password"`
-	Path     string `redis:"path"` // signin or signup or signout or refreshopenid
+	Email      string `redis:"email"`
+	Tenant     string `redis:"tenant"`
+	Password   string `redis:"password"`
+	TenantRole string `redis:"tenant_role"`
+	// the invitation of the signup, it is used once the code is exchanged
+	InvitationID string `redis:"invitation_id"`
+	Path         string `redis:"path"` // signin or signup or signout or refreshopenid or magiclink
+}
+
+// MagicLinkDatas is a single-use login link, AuthorizeQuery hold the
+// authorize params(client_id, code_challenge...) of the request
+type MagicLinkDatas struct {
+	ID             string    `bson:"_id"`
+	Tenant         string    `bson:"tenant"`
+	Email          string    `bson:"email"`
+	AuthorizeQuery string    `bson:"authorize_query"`
+	IsUsed         int       `bson:"is_used"`
+	CreatedAT      time.Time `bson:"created_at"`
+	ExpiresAT      time.Time `bson:"expires_at"`
 }
 
 type APIserverDatas struct {
 	ID         primitive.ObjectID `bson:"_id,omitempty"`
 	ServiceID  string             `bson:"service_id"`
+	Tenant     string             `bson:"tenant"`
 	AccessTK   string             `bson:"access_tk,omitempty"`
 	RefreshTK  string             `bson:"refresh_tk"`
 	RefreshJWT string             `bson:"refresh_jwt"`
@@ -41,5 +61,82 @@ type APIserverDatas struct {
 
 type APIserverRedisDatas struct {
 	ServiceID string `redis:"service_id"`
+	Tenant    string `redis:"tenant"`
 	Path      string `redis:"path"` // apiauth or refreshopenid
 }
+
+// ClientTenantDatas assign a client, created by an admin, to its tenant
+type ClientTenantDatas struct {
+	ClientID  string    `bson:"_id"`
+	Tenant    string    `bson:"tenant"`
+	CreatedAT time.Time `bson:"created_at"`
+}
+
+// WebhookSubscriptionDatas is an endpoint notified of the Events,
+// the deliveries are signed with the Secret
+type WebhookSubscriptionDatas struct {
+	ID        string    `bson:"_id" json:"id"`
+	URL       string    `bson:"url" json:"url"`
+	Secret    string    `bson:"secret" json:"secret,omitempty"`
+	Events    []string  `bson:"events" json:"events"`
+	CreatedAT time.Time `bson:"created_at" json:"created_at"`
+}
+
+// WebhookDeliveryDatas is an event sent to a subscription, it is saved
+// as a dead letter once all the attempts failed
+type WebhookDeliveryDatas struct {
+	ID             string    `bson:"_id" json:"id"`
+	SubscriptionID string    `bson:"subscription_id" json:"subscription_id"`
+	Event          string    `bson:"event" json:"event"`
+	Payload        string    `bson:"payload" json:"payload"`
+	Attempts       int       `bson:"attempts" json:"attempts"`
+	LastError      string    `bson:"last_error" json:"last_error"`
+	CreatedAT      time.Time `bson:"created_at" json:"created_at"`
+	UpdatedAT      time.Time `bson:"updated_at" json:"updated_at"`
+}
+
+// APIKeyDatas is a key of a third party integrator, the key is
+// "ak_<ID>.<secret>" and only the sha256 of the secret is saved
+type APIKeyDatas struct {
+	ID         string    `bson:"_id" json:"id"`
+	Name       string    `bson:"name" json:"name"`
+	Tenant     string    `bson:"tenant" json:"tenant"`
+	Scopes     []string  `bson:"scopes" json:"scopes"`
+	Hash       string    `bson:"hash" json:"hash,omitempty"`
+	IsRevoked  int       `bson:"is_revoked" json:"is_revoked"`
+	CreatedAT  time.Time `bson:"created_at" json:"created_at"`
+	ExpiresAT  time.Time `bson:"expires_at" json:"expires_at"`
+	LastUsedAT time.Time `bson:"last_used_at" json:"last_used_at"`
+}
+
+// SigningKeysDatas is the ring of the jwt signing keys shared by the
+// auth_svc instances, Keys[0] is the current key. Version is bumped on each
+// save so concurrent rotations do not overwrite each other
+type SigningKeysDatas struct {
+	ID        string       `bson:"_id" json:"id"`
+	Keys      []SigningKey `bson:"keys" json:"keys"`
+	Version   int          `bson:"version" json:"version"`
+	UpdatedAT time.Time    `bson:"updated_at" json:"updated_at"`
+}
+
+type SigningKey struct {
+	KeyID     string `bson:"key_id" json:"key_id"`
+	SecretKey string `bson:"secret_key" json:"secret_key"`
+}
+
+// InvitationDatas let Email signup in the tenant with Role, the token is
+// "inv_<ID>.<secret>" and only the sha256 of the secret is saved. An
+// invitation is single use
+type InvitationDatas struct {
+	ID        string    `bson:"_id" json:"id"`
+	Tenant    string    `bson:"tenant" json:"tenant"`
+	Email     string    `bson:"email" json:"email"`
+	Role      string    `bson:"role" json:"role"`
+	Inviter   string    `bson:"inviter" json:"inviter"`
+	Hash      string    `bson:"hash" json:"hash,omitempty"`
+	IsUsed    int       `bson:"is_used" json:"is_used"`
+	IsRevoked int       `bson:"is_revoked" json:"is_revoked"`
+	CreatedAT time.Time `bson:"created_at" json:"created_at"`
+	ExpiresAT time.Time `bson:"expires_at" json:"expires_at"`
+	UsedAT    time.Time `bson:"used_at" json:"used_at"`
+}
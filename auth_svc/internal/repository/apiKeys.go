@@ -0,0 +1,157 @@
+package repository
+
+import (
+	"context"
+	"fmt"
+	"gitlab.com/grpasr/asonrythme/auth_svc/internal/models"
+	mgoCltProvider "gitlab.com/grpasr/common/databases/mongo"
+	obs "gitlab.com/grpasr/common/observability"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+	"time"
+)
+
+// the api keys of the third party integrators, a revoked key is
+// kept so the listing still show it
+type IAPIKeyStore interface {
+	APIKeyCreate(ctx context.Context, d models.APIKeyDatas) error
+	APIKeyGetByID(ctx context.Context, id string) (models.APIKeyDatas, error)
+	APIKeyList(ctx context.Context, tenant string) ([]models.APIKeyDatas, error)
+	APIKeyRevoke(ctx context.Context, id string) error
+	APIKeyTouch(ctx context.Context, id string, usedAt time.Time) error
+}
+
+type APIKeyStore struct {
+	storeCfg *mgoCltProvider.StoreConfig
+	client   *mongo.Client
+}
+
+func NewAPIKeyStore(storeCfg *mgoCltProvider.StoreConfig, client *mongo.Client) *APIKeyStore {
+	ks := &APIKeyStore{}
+	ks.storeCfg = storeCfg
+	ks.client = client
+	return ks
+}
+
+func (ks *APIKeyStore) getCollection(name string) *mongo.Collection {
+	return ks.client.Database(ks.storeCfg.GetDatabaseName()).Collection(name)
+}
+
+// setRequestContext bound the request's context with the store timeout
+func (ks *APIKeyStore) setRequestContext(ctx context.Context) (context.Context, context.CancelFunc) {
+	if ks.storeCfg.GetRequestTimeout() > 0 {
+		timeout := time.Duration(ks.storeCfg.GetRequestTimeout()) * time.Second
+		return context.WithTimeout(ctx, timeout)
+	}
+	return ctx, func() {}
+}
+
+func (ks *APIKeyStore) APIKeyCreate(ctx context.Context, d models.APIKeyDatas) error {
+	ctx, span := obs.Tracing.SPNGetFromCTX(ctx, "authRepo_apiKeyCreate", obs.Tracing.TAString("db.system", "mongodb"))
+	defer span.End()
+	ctx, cancel := ks.setRequestContext(ctx)
+	defer cancel()
+
+	d.CreatedAT = time.Now()
+
+	_, err := ks.getCollection(apiKeysCollection).InsertOne(ctx, d)
+	if err != nil {
+		obs.Logging.NewLogHandler(obs.Logging.LLHError()).
+			Err(err).
+			Msg(fmt.Sprintf("Auth_svc - database.go - APIKeyCreate() %v failed", d.ID))
+		return err
+	}
+
+	return nil
+}
+
+func (ks *APIKeyStore) APIKeyGetByID(ctx context.Context, id string) (models.APIKeyDatas, error) {
+	ctx, span := obs.Tracing.SPNGetFromCTX(ctx, "authRepo_apiKeyGetByID", obs.Tracing.TAString("db.system", "mongodb"))
+	defer span.End()
+	ctx, cancel := ks.setRequestContext(ctx)
+	defer cancel()
+
+	d := models.APIKeyDatas{}
+	err := ks.getCollection(apiKeysCollection).FindOne(ctx, bson.M{"_id": id}).Decode(&d)
+	if err != nil {
+		if err != mongo.ErrNoDocuments {
+			obs.Logging.NewLogHandler(obs.Logging.LLHError()).
+				Err(err).
+				Msg(fmt.Sprintf("Auth_svc - database.go - APIKeyGetByID() %v failed", id))
+		}
+		return d, err
+	}
+
+	return d, nil
+}
+
+// APIKeyList return the keys of the tenant, all the keys if tenant is empty
+func (ks *APIKeyStore) APIKeyList(ctx context.Context, tenant string) ([]models.APIKeyDatas, error) {
+	ctx, span := obs.Tracing.SPNGetFromCTX(ctx, "authRepo_apiKeyList", obs.Tracing.TAString("db.system", "mongodb"))
+	defer span.End()
+	ctx, cancel := ks.setRequestContext(ctx)
+	defer cancel()
+
+	keys := []models.APIKeyDatas{}
+
+	filter := bson.M{}
+	if tenant != "" {
+		filter["tenant"] = tenant
+	}
+
+	opts := options.Find().SetSort(bson.D{{Key: "created_at", Value: 1}})
+	cursor, err := ks.getCollection(apiKeysCollection).Find(ctx, filter, opts)
+	if err != nil {
+		obs.Logging.NewLogHandler(obs.Logging.LLHError()).
+			Err(err).
+			Msg("Auth_svc - database.go - APIKeyList() failed")
+		return keys, err
+	}
+	defer cursor.Close(ctx)
+
+	if err := cursor.All(ctx, &keys); err != nil {
+		return keys, err
+	}
+
+	return keys, nil
+}
+
+func (ks *APIKeyStore) APIKeyRevoke(ctx context.Context, id string) error {
+	ctx, span := obs.Tracing.SPNGetFromCTX(ctx, "authRepo_apiKeyRevoke", obs.Tracing.TAString("db.system", "mongodb"))
+	defer span.End()
+	ctx, cancel := ks.setRequestContext(ctx)
+	defer cancel()
+
+	res, err := ks.getCollection(apiKeysCollection).UpdateOne(ctx,
+		bson.M{"_id": id}, bson.M{"$set": bson.M{"is_revoked": 1}})
+	if err != nil {
+		obs.Logging.NewLogHandler(obs.Logging.LLHError()).
+			Err(err).
+			Msg(fmt.Sprintf("Auth_svc - database.go - APIKeyRevoke() %v failed", id))
+		return err
+	}
+	if res.MatchedCount == 0 {
+		return mongo.ErrNoDocuments
+	}
+
+	return nil
+}
+
+func (ks *APIKeyStore) APIKeyTouch(ctx context.Context, id string, usedAt time.Time) error {
+	ctx, span := obs.Tracing.SPNGetFromCTX(ctx, "authRepo_apiKeyTouch", obs.Tracing.TAString("db.system", "mongodb"))
+	defer span.End()
+	ctx, cancel := ks.setRequestContext(ctx)
+	defer cancel()
+
+	_, err := ks.getCollection(apiKeysCollection).UpdateOne(ctx,
+		bson.M{"_id": id}, bson.M{"$set": bson.M{"last_used_at": usedAt}})
+	if err != nil {
+		obs.Logging.NewLogHandler(obs.Logging.LLHError()).
+			Err(err).
+			Msg(fmt.Sprintf("Auth_svc - database.go - APIKeyTouch() %v failed", id))
+		return err
+	}
+
+	return nil
+}
@@ -14,11 +14,11 @@ import (
 
 // the APIservice database
 type IAPIserverStore interface {
-	APIserverCreate(k string, d models.APIserverDatas) error
-	APIserverUpdateTokens(svcID, refreshTK, jwtRefreshToken string) error
-	APIserverUpdate(svcID string, newData models.APIserverDatas) error
-	APIserverGetByID(k string) (models.APIserverDatas, error)
-	APIserverDelete(k string) error
+	APIserverCreate(ctx context.Context, k string, d models.APIserverDatas) error
+	APIserverUpdateTokens(ctx context.Context, svcID, refreshTK, jwtRefreshToken string) error
+	APIserverUpdate(ctx context.Context, svcID string, newData models.APIserverDatas) error
+	APIserverGetByID(ctx context.Context, k string) (models.APIserverDatas, error)
+	APIserverDelete(ctx context.Context, k string) error
 	APIserverCount() int
 	APIserverReset()
 }
@@ -39,25 +39,23 @@ func (as *APIserverStore) getCollection(name string) *mongo.Collection {
 	return as.client.Database(as.storeCfg.GetDatabaseName()).Collection(name)
 }
 
-func (as *APIserverStore) setRequestContext() (context.Context, context.CancelFunc) {
-	ctx := context.Background()
+// setRequestContext bound the request's context with the store timeout
+func (as *APIserverStore) setRequestContext(ctx context.Context) (context.Context, context.CancelFunc) {
 	if as.storeCfg.GetRequestTimeout() > 0 {
 		timeout := time.Duration(as.storeCfg.GetRequestTimeout()) * time.Second
 		return context.WithTimeout(ctx, timeout)
 	}
-	return nil, func() {}
+	return ctx, func() {}
 }
 
-func (as *APIserverStore) APIserverCreate(svcID string, d models.APIserverDatas) error {
+func (as *APIserverStore) APIserverCreate(ctx context.Context, svcID string, d models.APIserverDatas) error {
 	obs.Logging.NewLogHandler(obs.Logging.LLHDebug()).
 		Msg(fmt.Sprintf("Auth_svc - database.go - reach APIserviceCreate() %v", svcID))
 
-	ctx := context.Background()
-	ctxR, cancel := as.setRequestContext()
+	ctx, span := obs.Tracing.SPNGetFromCTX(ctx, "authRepo_aPIserverCreate", obs.Tracing.TAString("db.system", "mongodb"))
+	defer span.End()
+	ctx, cancel := as.setRequestContext(ctx)
 	defer cancel()
-	if ctxR != nil {
-		ctx = ctxR
-	}
 
 	d.CreatedAT = time.Now()
 
@@ -79,17 +77,15 @@ func (as *APIserverStore) APIserverCreate(svcID string, d models.APIserverDatas)
 
 }
 
-func (as *APIserverStore) APIserverUpdate(svcID string, newData models.APIserverDatas) error {
+func (as *APIserverStore) APIserverUpdate(ctx context.Context, svcID string, newData models.APIserverDatas) error {
 	// Log the entry point of the function
 	obs.Logging.NewLogHandler(obs.Logging.LLHDebug()).
 		Msg(fmt.Sprintf("Auth_svc - database.go - APIserverUpdate() %v", svcID))
 
-	ctx := context.Background()
-	ctxR, cancel := as.setRequestContext()
+	ctx, span := obs.Tracing.SPNGetFromCTX(ctx, "authRepo_aPIserverUpdate", obs.Tracing.TAString("db.system", "mongodb"))
+	defer span.End()
+	ctx, cancel := as.setRequestContext(ctx)
 	defer cancel()
-	if ctxR != nil {
-		ctx = ctxR
-	}
 
 	newData.UpdatedAT = time.Now()
 
@@ -109,16 +105,14 @@ func (as *APIserverStore) APIserverUpdate(svcID string, newData models.APIserver
 	return nil
 }
 
-func (as *APIserverStore) APIserverUpdateTokens(svcID, refreshTK, jwtRefreshToken string) error {
+func (as *APIserverStore) APIserverUpdateTokens(ctx context.Context, svcID, refreshTK, jwtRefreshToken string) error {
 	obs.Logging.NewLogHandler(obs.Logging.LLHDebug()).
 		Msg(fmt.Sprintf("Auth_svc - database.go - APIserverUpdateTokens() %v", svcID))
 
-	ctx := context.Background()
-	ctxR, cancel := as.setRequestContext()
+	ctx, span := obs.Tracing.SPNGetFromCTX(ctx, "authRepo_aPIserverUpdateTokens", obs.Tracing.TAString("db.system", "mongodb"))
+	defer span.End()
+	ctx, cancel := as.setRequestContext(ctx)
 	defer cancel()
-	if ctxR != nil {
-		ctx = ctxR
-	}
 
 	filter := bson.M{"service_id": svcID}
 	update := bson.M{"$set": bson.M{
@@ -139,16 +133,14 @@ func (as *APIserverStore) APIserverUpdateTokens(svcID, refreshTK, jwtRefreshToke
 	return nil
 
 }
-func (as *APIserverStore) APIserverGetByID(svcID string) (models.APIserverDatas, error) {
+func (as *APIserverStore) APIserverGetByID(ctx context.Context, svcID string) (models.APIserverDatas, error) {
 	obs.Logging.NewLogHandler(obs.Logging.LLHDebug()).
 		Msg(fmt.Sprintf("Auth_svc - database.go - reach APIserverGetByID() %v", svcID))
 
-	ctx := context.Background()
-	ctxR, cancel := as.setRequestContext()
+	ctx, span := obs.Tracing.SPNGetFromCTX(ctx, "authRepo_aPIserverGetByID", obs.Tracing.TAString("db.system", "mongodb"))
+	defer span.End()
+	ctx, cancel := as.setRequestContext(ctx)
 	defer cancel()
-	if ctxR != nil {
-		ctx = ctxR
-	}
 
 	ad := models.APIserverDatas{}
 
@@ -180,17 +172,15 @@ func (as *APIserverStore) APIserverGetByID(svcID string) (models.APIserverDatas,
 	return ad, nil
 
 }
-func (as *APIserverStore) APIserverDelete(svcID string) error {
+func (as *APIserverStore) APIserverDelete(ctx context.Context, svcID string) error {
 	// Log the entry point of the function
 	obs.Logging.NewLogHandler(obs.Logging.LLHDebug()).
 		Msg(fmt.Sprintf("Auth_svc - database.go - APIserverDelete() %v", svcID))
 
-	ctx := context.Background()
-	ctxR, cancel := as.setRequestContext()
+	ctx, span := obs.Tracing.SPNGetFromCTX(ctx, "authRepo_aPIserverDelete", obs.Tracing.TAString("db.system", "mongodb"))
+	defer span.End()
+	ctx, cancel := as.setRequestContext(ctx)
 	defer cancel()
-	if ctxR != nil {
-		ctx = ctxR
-	}
 
 	filter := bson.M{"service_id": svcID}
 	result, err := as.getCollection(apiServerCollection).DeleteOne(ctx, filter)
@@ -0,0 +1,103 @@
+package repository
+
+import (
+	"context"
+	"fmt"
+	"gitlab.com/grpasr/asonrythme/auth_svc/internal/models"
+	mgoCltProvider "gitlab.com/grpasr/common/databases/mongo"
+	obs "gitlab.com/grpasr/common/observability"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+	"time"
+)
+
+// the tenants of the clients created by an admin, the clients
+// of the config files get their tenant from there
+type IClientTenantStore interface {
+	ClientTenantSet(ctx context.Context, clientID, tenant string) error
+	ClientTenantGet(ctx context.Context, clientID string) (string, error)
+	ClientTenantDelete(ctx context.Context, clientID string) error
+}
+
+type ClientTenantStore struct {
+	storeCfg *mgoCltProvider.StoreConfig
+	client   *mongo.Client
+}
+
+func NewClientTenantStore(storeCfg *mgoCltProvider.StoreConfig, client *mongo.Client) *ClientTenantStore {
+	cs := &ClientTenantStore{}
+	cs.storeCfg = storeCfg
+	cs.client = client
+	return cs
+}
+
+func (cs *ClientTenantStore) getCollection(name string) *mongo.Collection {
+	return cs.client.Database(cs.storeCfg.GetDatabaseName()).Collection(name)
+}
+
+// setRequestContext bound the request's context with the store timeout
+func (cs *ClientTenantStore) setRequestContext(ctx context.Context) (context.Context, context.CancelFunc) {
+	if cs.storeCfg.GetRequestTimeout() > 0 {
+		timeout := time.Duration(cs.storeCfg.GetRequestTimeout()) * time.Second
+		return context.WithTimeout(ctx, timeout)
+	}
+	return ctx, func() {}
+}
+
+func (cs *ClientTenantStore) ClientTenantSet(ctx context.Context, clientID, tenant string) error {
+	ctx, span := obs.Tracing.SPNGetFromCTX(ctx, "authRepo_clientTenantSet", obs.Tracing.TAString("db.system", "mongodb"))
+	defer span.End()
+	ctx, cancel := cs.setRequestContext(ctx)
+	defer cancel()
+
+	d := models.ClientTenantDatas{ClientID: clientID, Tenant: tenant, CreatedAT: time.Now()}
+
+	_, err := cs.getCollection(clientTenantsCollection).ReplaceOne(ctx,
+		bson.M{"_id": clientID}, d, options.Replace().SetUpsert(true))
+	if err != nil {
+		obs.Logging.NewLogHandler(obs.Logging.LLHError()).
+			Err(err).
+			Msg(fmt.Sprintf("Auth_svc - database.go - ClientTenantSet() %v failed", clientID))
+		return err
+	}
+
+	return nil
+}
+
+func (cs *ClientTenantStore) ClientTenantGet(ctx context.Context, clientID string) (string, error) {
+	ctx, span := obs.Tracing.SPNGetFromCTX(ctx, "authRepo_clientTenantGet", obs.Tracing.TAString("db.system", "mongodb"))
+	defer span.End()
+	ctx, cancel := cs.setRequestContext(ctx)
+	defer cancel()
+
+	d := models.ClientTenantDatas{}
+	err := cs.getCollection(clientTenantsCollection).FindOne(ctx, bson.M{"_id": clientID}).Decode(&d)
+	if err != nil {
+		if err != mongo.ErrNoDocuments {
+			obs.Logging.NewLogHandler(obs.Logging.LLHError()).
+				Err(err).
+				Msg(fmt.Sprintf("Auth_svc - database.go - ClientTenantGet() %v failed", clientID))
+		}
+		return "", err
+	}
+
+	return d.Tenant, nil
+}
+
+func (cs *ClientTenantStore) ClientTenantDelete(ctx context.Context, clientID string) error {
+	ctx, span := obs.Tracing.SPNGetFromCTX(ctx, "authRepo_clientTenantDelete", obs.Tracing.TAString("db.system", "mongodb"))
+	defer span.End()
+	ctx, cancel := cs.setRequestContext(ctx)
+	defer cancel()
+
+	_, err := cs.getCollection(clientTenantsCollection).DeleteOne(ctx, bson.M{"_id": clientID})
+	if err != nil {
+		obs.Logging.NewLogHandler(obs.Logging.LLHError()).
+			Err(err).
+			Msg(fmt.Sprintf("Auth_svc - database.go - ClientTenantDelete() %v failed", clientID))
+		return err
+	}
+
+	return nil
+}
@@ -0,0 +1,847 @@
+package repository
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	oauth2 "github.com/djedjethai/go-oauth2-openid"
+	oauth2Models "github.com/djedjethai/go-oauth2-openid/models"
+	"gitlab.com/grpasr/asonrythme/auth_svc/internal/models"
+	e "gitlab.com/grpasr/common/errors/json"
+	obs "gitlab.com/grpasr/common/observability"
+	bolt "go.etcd.io/bbolt"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"os"
+	"path/filepath"
+	"sort"
+	"time"
+)
+
+// buckets of the embedded store, one per collection(mongo) or hash(redis)
+var (
+	embeddedUsersBucket          = []byte(usersCollection)
+	embeddedAPIserverBucket      = []byte(apiServerCollection)
+	embeddedMagicLinksBucket     = []byte(magicLinksCollection)
+	embeddedWebhookSubsBucket    = []byte(webhookSubscriptionsCollection)
+	embeddedDeadLettersBucket    = []byte(webhookDeadLettersCollection)
+	embeddedRedisUsersBucket     = []byte("redisUsers")
+	embeddedRedisAPIserverBucket = []byte("redisAPIservers")
+	embeddedTemporaryBucket      = []byte("temporary")
+	embeddedClientsBucket        = []byte("clients")
+	embeddedClientTenantsBucket  = []byte(clientTenantsCollection)
+	embeddedAPIKeysBucket        = []byte(apiKeysCollection)
+	embeddedInvitationsBucket    = []byte(invitationsCollection)
+	embeddedSigningKeysBucket    = []byte(signingKeysCollection)
+
+	embeddedBuckets = [][]byte{
+		embeddedUsersBucket,
+		embeddedAPIserverBucket,
+		embeddedMagicLinksBucket,
+		embeddedWebhookSubsBucket,
+		embeddedDeadLettersBucket,
+		embeddedRedisUsersBucket,
+		embeddedRedisAPIserverBucket,
+		embeddedTemporaryBucket,
+		embeddedClientsBucket,
+		embeddedClientTenantsBucket,
+		embeddedAPIKeysBucket,
+		embeddedInvitationsBucket,
+		embeddedSigningKeysBucket,
+	}
+)
+
+const embeddedOpenTimeout = 5 * time.Second
+
+var errEmbeddedNotFound = errors.New("Not found")
+
+// EmbeddedStore keep the users, APIservers, sessions(redis), magic links,
+// webhooks and oauth2 clients in a single bbolt file, it implements all
+// the stores of the Repository so auth_svc run without mongo and redis
+type EmbeddedStore struct {
+	db *bolt.DB
+}
+
+// NewEmbeddedStore open(or create) the file at path, the file is locked
+// so only one process can use it
+func NewEmbeddedStore(path string) (*EmbeddedStore, error) {
+	if err := os.MkdirAll(filepath.Dir(path), 0o700); err != nil {
+		return nil, err
+	}
+
+	db, err := bolt.Open(path, 0o600, &bolt.Options{Timeout: embeddedOpenTimeout})
+	if err != nil {
+		return nil, fmt.Errorf("open embedded store %s: %w", path, err)
+	}
+
+	es := &EmbeddedStore{db: db}
+	if err := es.MigrationsRun(); err != nil {
+		db.Close()
+		return nil, err
+	}
+
+	obs.Logging.NewLogHandler(obs.Logging.LLHInfo()).
+		Str("embedded store: ", path).
+		Send()
+
+	return es, nil
+}
+
+// NewEmbeddedRepository is the Repository backed by es
+func NewEmbeddedRepository(es *EmbeddedStore) *Repository {
+	return &Repository{es, es, es, es, es, es, es, es, es, es, es}
+}
+
+// ClientStore is the oauth2 clients store, on the same file
+func (es *EmbeddedStore) ClientStore() *EmbeddedClientStore {
+	return &EmbeddedClientStore{db: es.db}
+}
+
+func (es *EmbeddedStore) Close() error {
+	return es.db.Close()
+}
+
+// MigrationsRun create the missing buckets and move the users saved
+// before the tenants to the default tenant, the embedded store has no index
+func (es *EmbeddedStore) MigrationsRun() error {
+	return es.db.Update(func(tx *bolt.Tx) error {
+		for _, b := range embeddedBuckets {
+			if _, err := tx.CreateBucketIfNotExists(b); err != nil {
+				return err
+			}
+		}
+
+		b := tx.Bucket(embeddedUsersBucket)
+		uds := map[string]models.UserDatas{}
+		err := b.ForEach(func(k, dt []byte) error {
+			var ud models.UserDatas
+			if err := json.Unmarshal(dt, &ud); err != nil {
+				return err
+			}
+			if ud.Tenant == "" {
+				uds[string(k)] = ud
+			}
+			return nil
+		})
+		if err != nil {
+			return err
+		}
+
+		for k, ud := range uds {
+			ud.Tenant = models.TenantDefault
+			dt, err := json.Marshal(ud)
+			if err != nil {
+				return err
+			}
+			if err := b.Delete([]byte(k)); err != nil {
+				return err
+			}
+			if err := b.Put([]byte(models.TenantKey(ud.Tenant, ud.Email)), dt); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+func (es *EmbeddedStore) get(bucket []byte, k string, v interface{}) error {
+	return es.db.View(func(tx *bolt.Tx) error {
+		dt := tx.Bucket(bucket).Get([]byte(k))
+		if dt == nil {
+			return errEmbeddedNotFound
+		}
+		return json.Unmarshal(dt, v)
+	})
+}
+
+func (es *EmbeddedStore) put(bucket []byte, k string, v interface{}) error {
+	dt, err := json.Marshal(v)
+	if err != nil {
+		return err
+	}
+	return es.db.Update(func(tx *bolt.Tx) error {
+		return tx.Bucket(bucket).Put([]byte(k), dt)
+	})
+}
+
+func (es *EmbeddedStore) delete(bucket []byte, k string) error {
+	return es.db.Update(func(tx *bolt.Tx) error {
+		return tx.Bucket(bucket).Delete([]byte(k))
+	})
+}
+
+func (es *EmbeddedStore) count(bucket []byte) int {
+	var c int
+	es.db.View(func(tx *bolt.Tx) error {
+		c = tx.Bucket(bucket).Stats().KeyN
+		return nil
+	})
+	return c
+}
+
+func (es *EmbeddedStore) reset(bucket []byte) {
+	es.db.Update(func(tx *bolt.Tx) error {
+		if err := tx.DeleteBucket(bucket); err != nil {
+			return err
+		}
+		_, err := tx.CreateBucket(bucket)
+		return err
+	})
+}
+
+// update apply fn to the value at k, within a single transaction
+func embeddedUpdate[T any](es *EmbeddedStore, bucket []byte, k string, fn func(v *T) error) error {
+	return es.db.Update(func(tx *bolt.Tx) error {
+		b := tx.Bucket(bucket)
+		dt := b.Get([]byte(k))
+		if dt == nil {
+			return errEmbeddedNotFound
+		}
+
+		var v T
+		if err := json.Unmarshal(dt, &v); err != nil {
+			return err
+		}
+		if err := fn(&v); err != nil {
+			return err
+		}
+
+		dt, err := json.Marshal(v)
+		if err != nil {
+			return err
+		}
+		return b.Put([]byte(k), dt)
+	})
+}
+
+// embeddedList decode all values of the bucket
+func embeddedList[T any](es *EmbeddedStore, bucket []byte) ([]T, error) {
+	vs := []T{}
+	err := es.db.View(func(tx *bolt.Tx) error {
+		return tx.Bucket(bucket).ForEach(func(k, dt []byte) error {
+			var v T
+			if err := json.Unmarshal(dt, &v); err != nil {
+				return err
+			}
+			vs = append(vs, v)
+			return nil
+		})
+	})
+	return vs, err
+}
+
+// page return the [skip, skip+limit) part of vs, limit <= 0 is no limit
+func page[T any](vs []T, skip, limit int64) []T {
+	if skip >= int64(len(vs)) {
+		return []T{}
+	}
+	vs = vs[skip:]
+	if limit > 0 && limit < int64(len(vs)) {
+		vs = vs[:limit]
+	}
+	return vs
+}
+
+/************
+* ITemporaryStore
+*************/
+func (es *EmbeddedStore) TemporarySet(k, d string) error {
+	return es.put(embeddedTemporaryBucket, k, d)
+}
+
+func (es *EmbeddedStore) TemporaryGet(k string) (string, error) {
+	var d string
+	err := es.get(embeddedTemporaryBucket, k, &d)
+	return d, err
+}
+
+func (es *EmbeddedStore) TemporaryDelete(k string) error {
+	return es.delete(embeddedTemporaryBucket, k)
+}
+
+func (es *EmbeddedStore) TemporaryCount() int {
+	return es.count(embeddedTemporaryBucket)
+}
+
+func (es *EmbeddedStore) TemporaryReset() {
+	es.reset(embeddedTemporaryBucket)
+}
+
+/************
+* IUserStore, the users are keyed by tenant and email
+*************/
+func (es *EmbeddedStore) UserIsEmailExist(ctx context.Context, tenant, email string) error {
+	var ud models.UserDatas
+	err := es.get(embeddedUsersBucket, models.TenantKey(tenant, email), &ud)
+	if err == nil {
+		return e.NewCustomHTTPStatus(e.StatusBadRequest, "email already exist")
+	} else if err != errEmbeddedNotFound {
+		return e.NewCustomHTTPStatus(e.StatusInternalServerError)
+	}
+	return nil
+}
+
+func (es *EmbeddedStore) UserCreate(ctx context.Context, tenant, email string, d models.UserDatas) error {
+	k := []byte(models.TenantKey(tenant, email))
+	d.ID = primitive.NewObjectID()
+	d.Tenant = tenant
+	d.CreatedAT = time.Now()
+	dt, err := json.Marshal(d)
+	if err != nil {
+		return e.NewCustomHTTPStatus(e.StatusInternalServerError)
+	}
+
+	err = es.db.Update(func(tx *bolt.Tx) error {
+		b := tx.Bucket(embeddedUsersBucket)
+		if b.Get(k) != nil {
+			return e.NewCustomHTTPStatus(e.StatusBadRequest, "email already exist")
+		}
+		return b.Put(k, dt)
+	})
+	if err != nil {
+		if ce, ok := err.(e.IError); ok {
+			return ce
+		}
+		return e.NewCustomHTTPStatus(e.StatusInternalServerError)
+	}
+	return nil
+}
+
+// UserUpdate replace the user as the mongo $set would, a missing user is
+// not an error
+func (es *EmbeddedStore) UserUpdate(ctx context.Context, tenant, email string, newData models.UserDatas) error {
+	err := embeddedUpdate(es, embeddedUsersBucket, models.TenantKey(tenant, email), func(ud *models.UserDatas) error {
+		newData.ID = ud.ID
+		newData.Tenant = tenant
+		newData.UpdatedAT = time.Now()
+		*ud = newData
+		return nil
+	})
+	if err == errEmbeddedNotFound {
+		return nil
+	}
+	return err
+}
+
+func (es *EmbeddedStore) UserUpdateTokens(ctx context.Context, tenant, email, refreshTK, jwtRefreshToken string) error {
+	err := embeddedUpdate(es, embeddedUsersBucket, models.TenantKey(tenant, email), func(ud *models.UserDatas) error {
+		ud.RefreshTK = refreshTK
+		ud.RefreshJWT = jwtRefreshToken
+		ud.UpdatedAT = time.Now()
+		return nil
+	})
+	if err == errEmbeddedNotFound {
+		return nil
+	}
+	return err
+}
+
+func (es *EmbeddedStore) UserGetByEmail(ctx context.Context, tenant, email string) (models.UserDatas, error) {
+	ud := models.UserDatas{}
+	err := es.get(embeddedUsersBucket, models.TenantKey(tenant, email), &ud)
+	return ud, err
+}
+
+func (es *EmbeddedStore) UserDelete(ctx context.Context, tenant, email string) error {
+	return es.delete(embeddedUsersBucket, models.TenantKey(tenant, email))
+}
+
+// UserList is sorted by tenant and email(the bucket's keys order), without the
+// password and the tokens
+func (es *EmbeddedStore) UserList(ctx context.Context, skip, limit int64) ([]models.UserDatas, error) {
+	uds, err := embeddedList[models.UserDatas](es, embeddedUsersBucket)
+	if err != nil {
+		return nil, err
+	}
+	for i := range uds {
+		uds[i].Password = ""
+		uds[i].RefreshTK = ""
+		uds[i].RefreshJWT = ""
+	}
+	return page(uds, skip, limit), nil
+}
+
+func (es *EmbeddedStore) UserPing(ctx context.Context) error {
+	return es.db.View(func(tx *bolt.Tx) error {
+		if tx.Bucket(embeddedUsersBucket) == nil {
+			return errors.New("embedded store users bucket not found")
+		}
+		return nil
+	})
+}
+
+func (es *EmbeddedStore) UserCount() int {
+	return es.count(embeddedUsersBucket)
+}
+
+func (es *EmbeddedStore) UserReset() {
+	es.reset(embeddedUsersBucket)
+}
+
+/************
+* IAPIserverStore, the APIservers are keyed by service_id
+*************/
+func (es *EmbeddedStore) APIserverCreate(ctx context.Context, svcID string, d models.APIserverDatas) error {
+	d.ID = primitive.NewObjectID()
+	d.CreatedAT = time.Now()
+	return es.put(embeddedAPIserverBucket, svcID, d)
+}
+
+func (es *EmbeddedStore) APIserverUpdate(ctx context.Context, svcID string, newData models.APIserverDatas) error {
+	err := embeddedUpdate(es, embeddedAPIserverBucket, svcID, func(ad *models.APIserverDatas) error {
+		newData.ID = ad.ID
+		newData.UpdatedAT = time.Now()
+		*ad = newData
+		return nil
+	})
+	if err == errEmbeddedNotFound {
+		return nil
+	}
+	return err
+}
+
+func (es *EmbeddedStore) APIserverUpdateTokens(ctx context.Context, svcID, refreshTK, jwtRefreshToken string) error {
+	err := embeddedUpdate(es, embeddedAPIserverBucket, svcID, func(ad *models.APIserverDatas) error {
+		ad.RefreshTK = refreshTK
+		ad.RefreshJWT = jwtRefreshToken
+		ad.UpdatedAT = time.Now()
+		return nil
+	})
+	if err == errEmbeddedNotFound {
+		return nil
+	}
+	return err
+}
+
+func (es *EmbeddedStore) APIserverGetByID(ctx context.Context, svcID string) (models.APIserverDatas, error) {
+	ad := models.APIserverDatas{}
+	err := es.get(embeddedAPIserverBucket, svcID, &ad)
+	return ad, err
+}
+
+func (es *EmbeddedStore) APIserverDelete(ctx context.Context, svcID string) error {
+	return es.delete(embeddedAPIserverBucket, svcID)
+}
+
+func (es *EmbeddedStore) APIserverCount() int {
+	return es.count(embeddedAPIserverBucket)
+}
+
+func (es *EmbeddedStore) APIserverReset() {
+	es.reset(embeddedAPIserverBucket)
+}
+
+/************
+* IRedisStore, as for redis(HGETALL) a missing key is an empty value
+*************/
+func (es *EmbeddedStore) RedisUserSet(ctx context.Context, k string, d models.UserRedisDatas) error {
+	return es.put(embeddedRedisUsersBucket, k, d)
+}
+
+func (es *EmbeddedStore) RedisUserGet(ctx context.Context, k string) (models.UserRedisDatas, error) {
+	ud := models.UserRedisDatas{}
+	if err := es.get(embeddedRedisUsersBucket, k, &ud); err != nil && err != errEmbeddedNotFound {
+		return models.UserRedisDatas{}, err
+	}
+	ud.Email = k
+	return ud, nil
+}
+
+func (es *EmbeddedStore) RedisUserDelete(ctx context.Context, k string) error {
+	return es.delete(embeddedRedisUsersBucket, k)
+}
+
+func (es *EmbeddedStore) RedisUserCount() int {
+	return es.count(embeddedRedisUsersBucket)
+}
+
+func (es *EmbeddedStore) RedisUserReset() {
+	es.reset(embeddedRedisUsersBucket)
+}
+
+func (es *EmbeddedStore) RedisPing(ctx context.Context) error {
+	return nil
+}
+
+func (es *EmbeddedStore) RedisAPIserverSet(ctx context.Context, k string, d models.APIserverRedisDatas) error {
+	return es.put(embeddedRedisAPIserverBucket, k, d)
+}
+
+func (es *EmbeddedStore) RedisAPIserverGet(ctx context.Context, k string) (models.APIserverRedisDatas, error) {
+	ad := models.APIserverRedisDatas{}
+	if err := es.get(embeddedRedisAPIserverBucket, k, &ad); err != nil && err != errEmbeddedNotFound {
+		return models.APIserverRedisDatas{}, err
+	}
+	ad.ServiceID = k
+	return ad, nil
+}
+
+func (es *EmbeddedStore) RedisAPIserverDelete(ctx context.Context, k string) error {
+	return es.delete(embeddedRedisAPIserverBucket, k)
+}
+
+func (es *EmbeddedStore) RedisAPIserverCount() int {
+	return es.count(embeddedRedisAPIserverBucket)
+}
+
+func (es *EmbeddedStore) RedisAPIserverReset() {
+	es.reset(embeddedRedisAPIserverBucket)
+}
+
+/************
+* IMagicLinkStore
+*************/
+func (es *EmbeddedStore) MagicLinkCreate(d models.MagicLinkDatas) error {
+	d.CreatedAT = time.Now()
+	return es.put(embeddedMagicLinksBucket, d.ID, d)
+}
+
+// MagicLinkConsume mark the link used, only once and before it expires
+func (es *EmbeddedStore) MagicLinkConsume(id string) (models.MagicLinkDatas, error) {
+	md := models.MagicLinkDatas{}
+	err := embeddedUpdate(es, embeddedMagicLinksBucket, id, func(d *models.MagicLinkDatas) error {
+		if d.IsUsed == 1 || !d.ExpiresAT.After(time.Now()) {
+			return errEmbeddedNotFound
+		}
+		d.IsUsed = 1
+		md = *d
+		return nil
+	})
+	if err != nil {
+		return models.MagicLinkDatas{}, err
+	}
+	return md, nil
+}
+
+func (es *EmbeddedStore) MagicLinkCountSince(email string, since time.Time) (int, error) {
+	mds, err := embeddedList[models.MagicLinkDatas](es, embeddedMagicLinksBucket)
+	if err != nil {
+		return 0, err
+	}
+
+	var c int
+	for _, md := range mds {
+		if md.Email == email && !md.CreatedAT.Before(since) {
+			c++
+		}
+	}
+	return c, nil
+}
+
+/************
+* IWebhookStore
+*************/
+func (es *EmbeddedStore) WebhookSubscriptionCreate(d models.WebhookSubscriptionDatas) error {
+	d.CreatedAT = time.Now()
+	dt, err := json.Marshal(d)
+	if err != nil {
+		return err
+	}
+
+	return es.db.Update(func(tx *bolt.Tx) error {
+		b := tx.Bucket(embeddedWebhookSubsBucket)
+		if b.Get([]byte(d.ID)) != nil {
+			return errors.New("subscription already exist")
+		}
+		return b.Put([]byte(d.ID), dt)
+	})
+}
+
+func (es *EmbeddedStore) WebhookSubscriptionList() ([]models.WebhookSubscriptionDatas, error) {
+	subs, err := embeddedList[models.WebhookSubscriptionDatas](es, embeddedWebhookSubsBucket)
+	if err != nil {
+		return subs, err
+	}
+	sort.Slice(subs, func(i, j int) bool { return subs[i].CreatedAT.Before(subs[j].CreatedAT) })
+	return subs, nil
+}
+
+func (es *EmbeddedStore) WebhookSubscriptionGetByID(id string) (models.WebhookSubscriptionDatas, error) {
+	sub := models.WebhookSubscriptionDatas{}
+	err := es.get(embeddedWebhookSubsBucket, id, &sub)
+	return sub, err
+}
+
+func (es *EmbeddedStore) WebhookSubscriptionDelete(id string) error {
+	return es.db.Update(func(tx *bolt.Tx) error {
+		b := tx.Bucket(embeddedWebhookSubsBucket)
+		if b.Get([]byte(id)) == nil {
+			return errEmbeddedNotFound
+		}
+		return b.Delete([]byte(id))
+	})
+}
+
+func (es *EmbeddedStore) WebhookDeadLetterCreate(d models.WebhookDeliveryDatas) error {
+	d.UpdatedAT = time.Now()
+	return es.put(embeddedDeadLettersBucket, d.ID, d)
+}
+
+func (es *EmbeddedStore) WebhookDeadLetterList(skip, limit int64) ([]models.WebhookDeliveryDatas, error) {
+	dls, err := embeddedList[models.WebhookDeliveryDatas](es, embeddedDeadLettersBucket)
+	if err != nil {
+		return dls, err
+	}
+	sort.Slice(dls, func(i, j int) bool { return dls[i].UpdatedAT.After(dls[j].UpdatedAT) })
+	return page(dls, skip, limit), nil
+}
+
+func (es *EmbeddedStore) WebhookDeadLetterTake(id string) (models.WebhookDeliveryDatas, error) {
+	d := models.WebhookDeliveryDatas{}
+	err := es.db.Update(func(tx *bolt.Tx) error {
+		b := tx.Bucket(embeddedDeadLettersBucket)
+		dt := b.Get([]byte(id))
+		if dt == nil {
+			return errEmbeddedNotFound
+		}
+		if err := json.Unmarshal(dt, &d); err != nil {
+			return err
+		}
+		return b.Delete([]byte(id))
+	})
+	if err != nil {
+		return models.WebhookDeliveryDatas{}, err
+	}
+	return d, nil
+}
+
+/************
+* IClientTenantStore, the tenants are keyed by client ID
+*************/
+func (es *EmbeddedStore) ClientTenantSet(ctx context.Context, clientID, tenant string) error {
+	return es.put(embeddedClientTenantsBucket, clientID, models.ClientTenantDatas{
+		ClientID:  clientID,
+		Tenant:    tenant,
+		CreatedAT: time.Now(),
+	})
+}
+
+func (es *EmbeddedStore) ClientTenantGet(ctx context.Context, clientID string) (string, error) {
+	d := models.ClientTenantDatas{}
+	err := es.get(embeddedClientTenantsBucket, clientID, &d)
+	return d.Tenant, err
+}
+
+func (es *EmbeddedStore) ClientTenantDelete(ctx context.Context, clientID string) error {
+	return es.delete(embeddedClientTenantsBucket, clientID)
+}
+
+/************
+* oauth2 clients
+*************/
+type embeddedClient struct {
+	ID     string `json:"id"`
+	Secret string `json:"secret"`
+	Domain string `json:"domain"`
+	UserID string `json:"user_id"`
+}
+
+// EmbeddedClientStore implements the oauth2 ClientStore and the
+// services.IClientStore, the clients are keyed by ID
+type EmbeddedClientStore struct {
+	db *bolt.DB
+}
+
+// Create replace the client if it exists, so the updated credentials
+// take effect on restart
+func (cs *EmbeddedClientStore) Create(info oauth2.ClientInfo) error {
+	dt, err := json.Marshal(embeddedClient{
+		ID:     info.GetID(),
+		Secret: info.GetSecret(),
+		Domain: info.GetDomain(),
+		UserID: info.GetUserID(),
+	})
+	if err != nil {
+		return err
+	}
+
+	return cs.db.Update(func(tx *bolt.Tx) error {
+		return tx.Bucket(embeddedClientsBucket).Put([]byte(info.GetID()), dt)
+	})
+}
+
+func (cs *EmbeddedClientStore) GetByID(ctx context.Context, id string) (oauth2.ClientInfo, error) {
+	var c embeddedClient
+	err := cs.db.View(func(tx *bolt.Tx) error {
+		dt := tx.Bucket(embeddedClientsBucket).Get([]byte(id))
+		if dt == nil {
+			return errEmbeddedNotFound
+		}
+		return json.Unmarshal(dt, &c)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return &oauth2Models.Client{
+		ID:     c.ID,
+		Secret: c.Secret,
+		Domain: c.Domain,
+		UserID: c.UserID,
+	}, nil
+}
+
+func (cs *EmbeddedClientStore) RemoveByID(id string) error {
+	return cs.db.Update(func(tx *bolt.Tx) error {
+		return tx.Bucket(embeddedClientsBucket).Delete([]byte(id))
+	})
+}
+
+/************
+* IAPIKeyStore
+*************/
+func (es *EmbeddedStore) APIKeyCreate(ctx context.Context, d models.APIKeyDatas) error {
+	d.CreatedAT = time.Now()
+	dt, err := json.Marshal(d)
+	if err != nil {
+		return err
+	}
+
+	return es.db.Update(func(tx *bolt.Tx) error {
+		b := tx.Bucket(embeddedAPIKeysBucket)
+		if b.Get([]byte(d.ID)) != nil {
+			return errors.New("api key already exist")
+		}
+		return b.Put([]byte(d.ID), dt)
+	})
+}
+
+func (es *EmbeddedStore) APIKeyGetByID(ctx context.Context, id string) (models.APIKeyDatas, error) {
+	d := models.APIKeyDatas{}
+	err := es.get(embeddedAPIKeysBucket, id, &d)
+	return d, err
+}
+
+func (es *EmbeddedStore) APIKeyList(ctx context.Context, tenant string) ([]models.APIKeyDatas, error) {
+	all, err := embeddedList[models.APIKeyDatas](es, embeddedAPIKeysBucket)
+	if err != nil {
+		return all, err
+	}
+
+	keys := []models.APIKeyDatas{}
+	for _, d := range all {
+		if tenant == "" || d.Tenant == tenant {
+			keys = append(keys, d)
+		}
+	}
+	sort.Slice(keys, func(i, j int) bool { return keys[i].CreatedAT.Before(keys[j].CreatedAT) })
+	return keys, nil
+}
+
+func (es *EmbeddedStore) APIKeyRevoke(ctx context.Context, id string) error {
+	return embeddedUpdate(es, embeddedAPIKeysBucket, id, func(d *models.APIKeyDatas) error {
+		d.IsRevoked = 1
+		return nil
+	})
+}
+
+func (es *EmbeddedStore) APIKeyTouch(ctx context.Context, id string, usedAt time.Time) error {
+	return embeddedUpdate(es, embeddedAPIKeysBucket, id, func(d *models.APIKeyDatas) error {
+		d.LastUsedAT = usedAt
+		return nil
+	})
+}
+
+/************
+* IInvitationStore
+*************/
+func (es *EmbeddedStore) InvitationCreate(ctx context.Context, d models.InvitationDatas) error {
+	d.CreatedAT = time.Now()
+	dt, err := json.Marshal(d)
+	if err != nil {
+		return err
+	}
+
+	return es.db.Update(func(tx *bolt.Tx) error {
+		b := tx.Bucket(embeddedInvitationsBucket)
+		if b.Get([]byte(d.ID)) != nil {
+			return errors.New("invitation already exist")
+		}
+		return b.Put([]byte(d.ID), dt)
+	})
+}
+
+func (es *EmbeddedStore) InvitationGetByID(ctx context.Context, id string) (models.InvitationDatas, error) {
+	d := models.InvitationDatas{}
+	err := es.get(embeddedInvitationsBucket, id, &d)
+	return d, err
+}
+
+func (es *EmbeddedStore) InvitationList(ctx context.Context, tenant string) ([]models.InvitationDatas, error) {
+	all, err := embeddedList[models.InvitationDatas](es, embeddedInvitationsBucket)
+	if err != nil {
+		return all, err
+	}
+
+	invs := []models.InvitationDatas{}
+	for _, d := range all {
+		if tenant == "" || d.Tenant == tenant {
+			invs = append(invs, d)
+		}
+	}
+	sort.Slice(invs, func(i, j int) bool { return invs[i].CreatedAT.Before(invs[j].CreatedAT) })
+	return invs, nil
+}
+
+func (es *EmbeddedStore) InvitationRevoke(ctx context.Context, id string) error {
+	return embeddedUpdate(es, embeddedInvitationsBucket, id, func(d *models.InvitationDatas) error {
+		d.IsRevoked = 1
+		return nil
+	})
+}
+
+// InvitationConsume mark the invitation used, only once, if not revoked and before it expires
+func (es *EmbeddedStore) InvitationConsume(ctx context.Context, id string) (models.InvitationDatas, error) {
+	inv := models.InvitationDatas{}
+	err := embeddedUpdate(es, embeddedInvitationsBucket, id, func(d *models.InvitationDatas) error {
+		now := time.Now()
+		if d.IsUsed == 1 || d.IsRevoked == 1 || !d.ExpiresAT.After(now) {
+			return errEmbeddedNotFound
+		}
+		d.IsUsed = 1
+		d.UsedAT = now
+		inv = *d
+		return nil
+	})
+	if err != nil {
+		return models.InvitationDatas{}, err
+	}
+	return inv, nil
+}
+
+/*************
+* ISigningKeyStore
+*************/
+func (es *EmbeddedStore) SigningKeysGet(ctx context.Context) (models.SigningKeysDatas, error) {
+	d := models.SigningKeysDatas{}
+	err := es.get(embeddedSigningKeysBucket, signingKeysID, &d)
+	if errors.Is(err, errEmbeddedNotFound) {
+		return d, ErrSigningKeysNotFound
+	}
+	return d, err
+}
+
+func (es *EmbeddedStore) SigningKeysSave(ctx context.Context, d models.SigningKeysDatas) error {
+	return es.db.Update(func(tx *bolt.Tx) error {
+		b := tx.Bucket(embeddedSigningKeysBucket)
+
+		saved := models.SigningKeysDatas{}
+		if dt := b.Get([]byte(signingKeysID)); dt != nil {
+			if err := json.Unmarshal(dt, &saved); err != nil {
+				return err
+			}
+		}
+		if saved.Version != d.Version {
+			return ErrSigningKeysConflict
+		}
+
+		d.ID = signingKeysID
+		d.Version++
+		d.UpdatedAT = time.Now()
+		dt, err := json.Marshal(d)
+		if err != nil {
+			return err
+		}
+		return b.Put([]byte(signingKeysID), dt)
+	})
+}
@@ -0,0 +1,285 @@
+package repository
+
+import (
+	"context"
+	"github.com/djedjethai/go-oauth2-openid/models"
+	authModels "gitlab.com/grpasr/asonrythme/auth_svc/internal/models"
+	"gitlab.com/grpasr/common/tests"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func newTestEmbeddedStore(t *testing.T) (*EmbeddedStore, string) {
+	path := filepath.Join(t.TempDir(), "auth_svc.db")
+	es, err := NewEmbeddedStore(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return es, path
+}
+
+func TestEmbeddedUsers(t *testing.T) {
+	tests.MaybeFail = tests.InitFailFunc(t)
+	ctx := context.Background()
+
+	es, path := newTestEmbeddedStore(t)
+
+	err := es.UserCreate(ctx, "default", "robert@example.com", authModels.UserDatas{Email: "robert@example.com", Password: "hashed"})
+	tests.MaybeFail("UserCreate", err)
+
+	err = es.UserCreate(ctx, "default", "robert@example.com", authModels.UserDatas{Email: "robert@example.com"})
+	tests.MaybeFail("UserCreate_duplicate", tests.Expect(err != nil, true))
+	tests.MaybeFail("UserIsEmailExist", tests.Expect(es.UserIsEmailExist(ctx, "default", "robert@example.com") != nil, true))
+	tests.MaybeFail("UserIsEmailExist_unknown", es.UserIsEmailExist(ctx, "default", "jean@example.com"))
+	tests.MaybeFail("UserIsEmailExist_other_tenant", es.UserIsEmailExist(ctx, "acme", "robert@example.com"))
+
+	err = es.UserUpdateTokens(ctx, "default", "robert@example.com", "refreshTK", "refreshJWT")
+	tests.MaybeFail("UserUpdateTokens", err)
+
+	ud, err := es.UserGetByEmail(ctx, "default", "robert@example.com")
+	tests.MaybeFail("UserGetByEmail", err,
+		tests.Expect(ud.Password, "hashed"),
+		tests.Expect(ud.RefreshTK, "refreshTK"),
+		tests.Expect(ud.Tenant, "default"),
+		tests.Expect(ud.ID.IsZero(), false))
+
+	_, err = es.UserGetByEmail(ctx, "acme", "robert@example.com")
+	tests.MaybeFail("UserGetByEmail_other_tenant", tests.Expect(err, errEmbeddedNotFound))
+
+	_, err = es.UserGetByEmail(ctx, "default", "jean@example.com")
+	tests.MaybeFail("UserGetByEmail_unknown", tests.Expect(err, errEmbeddedNotFound))
+
+	// the datas survive a restart
+	tests.MaybeFail("Close", es.Close())
+	es, err = NewEmbeddedStore(path)
+	tests.MaybeFail("NewEmbeddedStore_reopen", err)
+	defer es.Close()
+
+	es.UserCreate(ctx, "default", "alice@example.com", authModels.UserDatas{Email: "alice@example.com", Password: "hashed"})
+	err = es.UserCreate(ctx, "acme", "robert@example.com", authModels.UserDatas{Email: "robert@example.com", Password: "hashed"})
+	tests.MaybeFail("UserCreate_other_tenant", err)
+
+	uds, err := es.UserList(ctx, 0, 10)
+	tests.MaybeFail("UserList", err,
+		tests.Expect(len(uds), 3),
+		tests.Expect(uds[0].Tenant, "acme"),
+		tests.Expect(uds[1].Email, "alice@example.com"),
+		tests.Expect(uds[2].Password, ""),
+		tests.Expect(es.UserCount(), 3))
+
+	uds, err = es.UserList(ctx, 1, 10)
+	tests.MaybeFail("UserList_skip", err, tests.Expect(len(uds), 2))
+
+	es.UserReset()
+	tests.MaybeFail("UserReset", tests.Expect(es.UserCount(), 0))
+}
+
+func TestEmbeddedUsersWithoutTenant(t *testing.T) {
+	tests.MaybeFail = tests.InitFailFunc(t)
+	ctx := context.Background()
+
+	es, path := newTestEmbeddedStore(t)
+
+	// a user saved before the tenants, keyed by email
+	err := es.put(embeddedUsersBucket, "robert@example.com", authModels.UserDatas{Email: "robert@example.com", Password: "hashed"})
+	tests.MaybeFail("put", err)
+	tests.MaybeFail("Close", es.Close())
+
+	es, err = NewEmbeddedStore(path)
+	tests.MaybeFail("NewEmbeddedStore_reopen", err)
+	defer es.Close()
+
+	ud, err := es.UserGetByEmail(ctx, authModels.TenantDefault, "robert@example.com")
+	tests.MaybeFail("UserGetByEmail", err,
+		tests.Expect(ud.Tenant, authModels.TenantDefault),
+		tests.Expect(ud.Password, "hashed"),
+		tests.Expect(es.UserCount(), 1))
+}
+
+func TestEmbeddedRedis(t *testing.T) {
+	tests.MaybeFail = tests.InitFailFunc(t)
+	ctx := context.Background()
+
+	es, _ := newTestEmbeddedStore(t)
+	defer es.Close()
+
+	// as redis, a missing key is an empty value
+	ud, err := es.RedisUserGet(ctx, "robert@example.com")
+	tests.MaybeFail("RedisUserGet_missing", err,
+		tests.Expect(ud.Email, "robert@example.com"),
+		tests.Expect(ud.Password, ""))
+
+	err = es.RedisUserSet(ctx, "robert@example.com", authModels.UserRedisDatas{Email: "robert@example.com", Password: "hashed"})
+	tests.MaybeFail("RedisUserSet", err)
+
+	ud, err = es.RedisUserGet(ctx, "robert@example.com")
+	tests.MaybeFail("RedisUserGet", err,
+		tests.Expect(ud.Password, "hashed"),
+		tests.Expect(es.RedisUserCount(), 1))
+
+	tests.MaybeFail("RedisUserDelete", es.RedisUserDelete(ctx, "robert@example.com"),
+		tests.Expect(es.RedisUserCount(), 0))
+}
+
+func TestEmbeddedMagicLinks(t *testing.T) {
+	tests.MaybeFail = tests.InitFailFunc(t)
+
+	es, _ := newTestEmbeddedStore(t)
+	defer es.Close()
+
+	err := es.MagicLinkCreate(authModels.MagicLinkDatas{
+		ID:        "hashedToken",
+		Email:     "robert@example.com",
+		ExpiresAT: time.Now().Add(time.Minute),
+	})
+	tests.MaybeFail("MagicLinkCreate", err)
+
+	count, err := es.MagicLinkCountSince("robert@example.com", time.Now().Add(-time.Minute))
+	tests.MaybeFail("MagicLinkCountSince", err, tests.Expect(count, 1))
+
+	md, err := es.MagicLinkConsume("hashedToken")
+	tests.MaybeFail("MagicLinkConsume", err, tests.Expect(md.Email, "robert@example.com"))
+
+	_, err = es.MagicLinkConsume("hashedToken")
+	tests.MaybeFail("MagicLinkConsume_used", tests.Expect(err != nil, true))
+}
+
+func TestEmbeddedClients(t *testing.T) {
+	tests.MaybeFail = tests.InitFailFunc(t)
+
+	es, _ := newTestEmbeddedStore(t)
+	defer es.Close()
+	cs := es.ClientStore()
+
+	err := cs.Create(&models.Client{ID: "222222", Secret: "22222222", Domain: "http://localhost:80", UserID: "frontend"})
+	tests.MaybeFail("Create", err)
+
+	ci, err := cs.GetByID(context.Background(), "222222")
+	tests.MaybeFail("GetByID", err,
+		tests.Expect(ci.GetSecret(), "22222222"),
+		tests.Expect(ci.GetUserID(), "frontend"))
+
+	tests.MaybeFail("RemoveByID", cs.RemoveByID("222222"))
+	_, err = cs.GetByID(context.Background(), "222222")
+	tests.MaybeFail("GetByID_removed", tests.Expect(err != nil, true))
+}
+
+func TestEmbeddedClientTenants(t *testing.T) {
+	tests.MaybeFail = tests.InitFailFunc(t)
+	ctx := context.Background()
+
+	es, _ := newTestEmbeddedStore(t)
+	defer es.Close()
+
+	tests.MaybeFail("ClientTenantSet", es.ClientTenantSet(ctx, "acmeFrontend", "acme"))
+
+	tenant, err := es.ClientTenantGet(ctx, "acmeFrontend")
+	tests.MaybeFail("ClientTenantGet", err, tests.Expect(tenant, "acme"))
+
+	tests.MaybeFail("ClientTenantDelete", es.ClientTenantDelete(ctx, "acmeFrontend"))
+	_, err = es.ClientTenantGet(ctx, "acmeFrontend")
+	tests.MaybeFail("ClientTenantGet_deleted", tests.Expect(err, errEmbeddedNotFound))
+}
+
+func TestEmbeddedAPIKeys(t *testing.T) {
+	tests.MaybeFail = tests.InitFailFunc(t)
+	ctx := context.Background()
+
+	es, _ := newTestEmbeddedStore(t)
+	defer es.Close()
+
+	err := es.APIKeyCreate(ctx, authModels.APIKeyDatas{ID: "key1", Name: "partner", Tenant: "acme", Hash: "hash1"})
+	tests.MaybeFail("APIKeyCreate", err)
+	err = es.APIKeyCreate(ctx, authModels.APIKeyDatas{ID: "key1", Name: "partner", Tenant: "acme"})
+	tests.MaybeFail("APIKeyCreate_exist", tests.Expect(err != nil, true))
+	_ = es.APIKeyCreate(ctx, authModels.APIKeyDatas{ID: "key2", Name: "other", Tenant: "default"})
+
+	keys, err := es.APIKeyList(ctx, "acme")
+	tests.MaybeFail("APIKeyList_tenant", err, tests.Expect(len(keys), 1), tests.Expect(keys[0].ID, "key1"))
+	keys, err = es.APIKeyList(ctx, "")
+	tests.MaybeFail("APIKeyList", err, tests.Expect(len(keys), 2))
+
+	usedAt := time.Now().Truncate(time.Second)
+	tests.MaybeFail("APIKeyTouch", es.APIKeyTouch(ctx, "key1", usedAt))
+	tests.MaybeFail("APIKeyRevoke", es.APIKeyRevoke(ctx, "key1"))
+
+	kd, err := es.APIKeyGetByID(ctx, "key1")
+	tests.MaybeFail("APIKeyGetByID", err,
+		tests.Expect(kd.Hash, "hash1"),
+		tests.Expect(kd.IsRevoked, 1),
+		tests.Expect(kd.LastUsedAT.Equal(usedAt), true))
+
+	err = es.APIKeyRevoke(ctx, "unknown")
+	tests.MaybeFail("APIKeyRevoke_unknown", tests.Expect(err, errEmbeddedNotFound))
+}
+
+func TestEmbeddedInvitations(t *testing.T) {
+	tests.MaybeFail = tests.InitFailFunc(t)
+	ctx := context.Background()
+
+	es, _ := newTestEmbeddedStore(t)
+	defer es.Close()
+
+	expiresAt := time.Now().Add(time.Hour)
+	err := es.InvitationCreate(ctx, authModels.InvitationDatas{ID: "inv1", Tenant: "acme", Email: "jean@example.com", Role: "owner", ExpiresAT: expiresAt})
+	tests.MaybeFail("InvitationCreate", err)
+	err = es.InvitationCreate(ctx, authModels.InvitationDatas{ID: "inv1", Tenant: "acme"})
+	tests.MaybeFail("InvitationCreate_exist", tests.Expect(err != nil, true))
+	_ = es.InvitationCreate(ctx, authModels.InvitationDatas{ID: "inv2", Tenant: "default", ExpiresAT: expiresAt})
+	_ = es.InvitationCreate(ctx, authModels.InvitationDatas{ID: "expired", Tenant: "acme", ExpiresAT: time.Now().Add(-time.Second)})
+
+	invs, err := es.InvitationList(ctx, "acme")
+	tests.MaybeFail("InvitationList_tenant", err, tests.Expect(len(invs), 2), tests.Expect(invs[0].ID, "inv1"))
+
+	inv, err := es.InvitationConsume(ctx, "inv1")
+	tests.MaybeFail("InvitationConsume", err,
+		tests.Expect(inv.Role, "owner"),
+		tests.Expect(inv.IsUsed, 1),
+		tests.Expect(inv.UsedAT.IsZero(), false))
+	_, err = es.InvitationConsume(ctx, "inv1")
+	tests.MaybeFail("InvitationConsume_used", tests.Expect(err, errEmbeddedNotFound))
+	_, err = es.InvitationConsume(ctx, "expired")
+	tests.MaybeFail("InvitationConsume_expired", tests.Expect(err, errEmbeddedNotFound))
+
+	tests.MaybeFail("InvitationRevoke", es.InvitationRevoke(ctx, "inv2"))
+	_, err = es.InvitationConsume(ctx, "inv2")
+	tests.MaybeFail("InvitationConsume_revoked", tests.Expect(err, errEmbeddedNotFound))
+}
+
+func TestEmbeddedSigningKeys(t *testing.T) {
+	tests.MaybeFail = tests.InitFailFunc(t)
+	ctx := context.Background()
+
+	es, path := newTestEmbeddedStore(t)
+
+	_, err := es.SigningKeysGet(ctx)
+	tests.MaybeFail("SigningKeysGet_none", tests.Expect(err, ErrSigningKeysNotFound))
+
+	ring := authModels.SigningKeysDatas{Keys: []authModels.SigningKey{{KeyID: "kid1", SecretKey: "secret1"}}}
+	tests.MaybeFail("SigningKeysSave", es.SigningKeysSave(ctx, ring))
+	tests.MaybeFail("SigningKeysSave_created_twice", tests.Expect(es.SigningKeysSave(ctx, ring), ErrSigningKeysConflict))
+
+	saved, err := es.SigningKeysGet(ctx)
+	tests.MaybeFail("SigningKeysGet", err,
+		tests.Expect(saved.Version, 1),
+		tests.Expect(saved.Keys[0].KeyID, "kid1"))
+
+	// a save based on an outdated ring is refused
+	saved.Keys = append([]authModels.SigningKey{{KeyID: "kid2", SecretKey: "secret2"}}, saved.Keys...)
+	tests.MaybeFail("SigningKeysSave_rotate", es.SigningKeysSave(ctx, saved))
+	tests.MaybeFail("SigningKeysSave_outdated", tests.Expect(es.SigningKeysSave(ctx, saved), ErrSigningKeysConflict))
+
+	// the ring survive a restart
+	es.Close()
+	es, err = NewEmbeddedStore(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer es.Close()
+	saved, err = es.SigningKeysGet(ctx)
+	tests.MaybeFail("SigningKeysGet_reopen", err,
+		tests.Expect(saved.Version, 2),
+		tests.Expect(len(saved.Keys), 2),
+		tests.Expect(saved.Keys[0].KeyID, "kid2"))
+}
@@ -0,0 +1,170 @@
+package repository
+
+import (
+	"context"
+	"fmt"
+	"gitlab.com/grpasr/asonrythme/auth_svc/internal/models"
+	mgoCltProvider "gitlab.com/grpasr/common/databases/mongo"
+	obs "gitlab.com/grpasr/common/observability"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+	"time"
+)
+
+// the invitations of the closed registrations, the used, revoked and
+// expired ones are kept so the listing still show them
+type IInvitationStore interface {
+	InvitationCreate(ctx context.Context, d models.InvitationDatas) error
+	InvitationGetByID(ctx context.Context, id string) (models.InvitationDatas, error)
+	InvitationList(ctx context.Context, tenant string) ([]models.InvitationDatas, error)
+	InvitationRevoke(ctx context.Context, id string) error
+	InvitationConsume(ctx context.Context, id string) (models.InvitationDatas, error)
+}
+
+type InvitationStore struct {
+	storeCfg *mgoCltProvider.StoreConfig
+	client   *mongo.Client
+}
+
+func NewInvitationStore(storeCfg *mgoCltProvider.StoreConfig, client *mongo.Client) *InvitationStore {
+	is := &InvitationStore{}
+	is.storeCfg = storeCfg
+	is.client = client
+	return is
+}
+
+func (is *InvitationStore) getCollection(name string) *mongo.Collection {
+	return is.client.Database(is.storeCfg.GetDatabaseName()).Collection(name)
+}
+
+// setRequestContext bound the request's context with the store timeout
+func (is *InvitationStore) setRequestContext(ctx context.Context) (context.Context, context.CancelFunc) {
+	if is.storeCfg.GetRequestTimeout() > 0 {
+		timeout := time.Duration(is.storeCfg.GetRequestTimeout()) * time.Second
+		return context.WithTimeout(ctx, timeout)
+	}
+	return ctx, func() {}
+}
+
+func (is *InvitationStore) InvitationCreate(ctx context.Context, d models.InvitationDatas) error {
+	ctx, span := obs.Tracing.SPNGetFromCTX(ctx, "authRepo_invitationCreate", obs.Tracing.TAString("db.system", "mongodb"))
+	defer span.End()
+	ctx, cancel := is.setRequestContext(ctx)
+	defer cancel()
+
+	d.CreatedAT = time.Now()
+
+	_, err := is.getCollection(invitationsCollection).InsertOne(ctx, d)
+	if err != nil {
+		obs.Logging.NewLogHandler(obs.Logging.LLHError()).
+			Err(err).
+			Msg(fmt.Sprintf("Auth_svc - database.go - InvitationCreate() %v failed", d.ID))
+		return err
+	}
+
+	return nil
+}
+
+func (is *InvitationStore) InvitationGetByID(ctx context.Context, id string) (models.InvitationDatas, error) {
+	ctx, span := obs.Tracing.SPNGetFromCTX(ctx, "authRepo_invitationGetByID", obs.Tracing.TAString("db.system", "mongodb"))
+	defer span.End()
+	ctx, cancel := is.setRequestContext(ctx)
+	defer cancel()
+
+	d := models.InvitationDatas{}
+	err := is.getCollection(invitationsCollection).FindOne(ctx, bson.M{"_id": id}).Decode(&d)
+	if err != nil {
+		if err != mongo.ErrNoDocuments {
+			obs.Logging.NewLogHandler(obs.Logging.LLHError()).
+				Err(err).
+				Msg(fmt.Sprintf("Auth_svc - database.go - InvitationGetByID() %v failed", id))
+		}
+		return d, err
+	}
+
+	return d, nil
+}
+
+// InvitationList return the invitations of the tenant, all of them if tenant is empty
+func (is *InvitationStore) InvitationList(ctx context.Context, tenant string) ([]models.InvitationDatas, error) {
+	ctx, span := obs.Tracing.SPNGetFromCTX(ctx, "authRepo_invitationList", obs.Tracing.TAString("db.system", "mongodb"))
+	defer span.End()
+	ctx, cancel := is.setRequestContext(ctx)
+	defer cancel()
+
+	invs := []models.InvitationDatas{}
+
+	filter := bson.M{}
+	if tenant != "" {
+		filter["tenant"] = tenant
+	}
+
+	opts := options.Find().SetSort(bson.D{{Key: "created_at", Value: 1}})
+	cursor, err := is.getCollection(invitationsCollection).Find(ctx, filter, opts)
+	if err != nil {
+		obs.Logging.NewLogHandler(obs.Logging.LLHError()).
+			Err(err).
+			Msg("Auth_svc - database.go - InvitationList() failed")
+		return invs, err
+	}
+	defer cursor.Close(ctx)
+
+	if err := cursor.All(ctx, &invs); err != nil {
+		return invs, err
+	}
+
+	return invs, nil
+}
+
+func (is *InvitationStore) InvitationRevoke(ctx context.Context, id string) error {
+	ctx, span := obs.Tracing.SPNGetFromCTX(ctx, "authRepo_invitationRevoke", obs.Tracing.TAString("db.system", "mongodb"))
+	defer span.End()
+	ctx, cancel := is.setRequestContext(ctx)
+	defer cancel()
+
+	res, err := is.getCollection(invitationsCollection).UpdateOne(ctx,
+		bson.M{"_id": id}, bson.M{"$set": bson.M{"is_revoked": 1}})
+	if err != nil {
+		obs.Logging.NewLogHandler(obs.Logging.LLHError()).
+			Err(err).
+			Msg(fmt.Sprintf("Auth_svc - database.go - InvitationRevoke() %v failed", id))
+		return err
+	}
+	if res.MatchedCount == 0 {
+		return mongo.ErrNoDocuments
+	}
+
+	return nil
+}
+
+// InvitationConsume mark the invitation as used and return it, it fails
+// if the invitation is unknown, already used, revoked or expired
+func (is *InvitationStore) InvitationConsume(ctx context.Context, id string) (models.InvitationDatas, error) {
+	ctx, span := obs.Tracing.SPNGetFromCTX(ctx, "authRepo_invitationConsume", obs.Tracing.TAString("db.system", "mongodb"))
+	defer span.End()
+	ctx, cancel := is.setRequestContext(ctx)
+	defer cancel()
+
+	d := models.InvitationDatas{}
+
+	now := time.Now()
+	filter := bson.M{
+		"_id":        id,
+		"is_used":    0,
+		"is_revoked": 0,
+		"expires_at": bson.M{"$gt": now},
+	}
+	update := bson.M{"$set": bson.M{"is_used": 1, "used_at": now}}
+	opts := options.FindOneAndUpdate().SetReturnDocument(options.After)
+
+	err := is.getCollection(invitationsCollection).FindOneAndUpdate(ctx, filter, update, opts).Decode(&d)
+	if err != nil {
+		obs.Logging.NewLogHandler(obs.Logging.LLHError()).
+			Err(err).
+			Msg(fmt.Sprintf("Auth_svc - database.go - InvitationConsume() %v failed", id))
+		return d, err
+	}
+
+	return d, nil
+}
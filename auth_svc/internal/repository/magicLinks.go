@@ -0,0 +1,123 @@
+package repository
+
+import (
+	"context"
+	"fmt"
+	"gitlab.com/grpasr/asonrythme/auth_svc/internal/models"
+	mgoCltProvider "gitlab.com/grpasr/common/databases/mongo"
+	obs "gitlab.com/grpasr/common/observability"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+	"time"
+)
+
+// the magic links database, the links are removed by the TTL index once expired
+type IMagicLinkStore interface {
+	MagicLinkCreate(d models.MagicLinkDatas) error
+	MagicLinkConsume(id string) (models.MagicLinkDatas, error)
+	MagicLinkCountSince(email string, since time.Time) (int, error)
+}
+
+type MagicLinkStore struct {
+	storeCfg *mgoCltProvider.StoreConfig
+	client   *mongo.Client
+}
+
+func NewMagicLinkStore(storeCfg *mgoCltProvider.StoreConfig, client *mongo.Client) *MagicLinkStore {
+	ms := &MagicLinkStore{}
+	ms.storeCfg = storeCfg
+	ms.client = client
+	return ms
+}
+
+func (ms *MagicLinkStore) getCollection(name string) *mongo.Collection {
+	return ms.client.Database(ms.storeCfg.GetDatabaseName()).Collection(name)
+}
+
+func (ms *MagicLinkStore) setRequestContext() (context.Context, context.CancelFunc) {
+	ctx := context.Background()
+	if ms.storeCfg.GetRequestTimeout() > 0 {
+		timeout := time.Duration(ms.storeCfg.GetRequestTimeout()) * time.Second
+		return context.WithTimeout(ctx, timeout)
+	}
+	return nil, func() {}
+}
+
+func (ms *MagicLinkStore) MagicLinkCreate(d models.MagicLinkDatas) error {
+	obs.Logging.NewLogHandler(obs.Logging.LLHDebug()).
+		Msg(fmt.Sprintf("Auth_svc - database.go - reach MagicLinkCreate() %v", d.Email))
+
+	ctx := context.Background()
+	ctxR, cancel := ms.setRequestContext()
+	defer cancel()
+	if ctxR != nil {
+		ctx = ctxR
+	}
+
+	d.CreatedAT = time.Now()
+
+	_, err := ms.getCollection(magicLinksCollection).InsertOne(ctx, d)
+	if err != nil {
+		obs.Logging.NewLogHandler(obs.Logging.LLHError()).
+			Err(err).
+			Msg(fmt.Sprintf("Auth_svc - database.go - MagicLinkCreate() %v failed", d.Email))
+		return err
+	}
+
+	return nil
+}
+
+// MagicLinkConsume mark the link as used and return it, it fails
+// if the link is unknown, already used or expired
+func (ms *MagicLinkStore) MagicLinkConsume(id string) (models.MagicLinkDatas, error) {
+	obs.Logging.NewLogHandler(obs.Logging.LLHDebug()).
+		Msg(fmt.Sprintf("Auth_svc - database.go - reach MagicLinkConsume() %v", id))
+
+	ctx := context.Background()
+	ctxR, cancel := ms.setRequestContext()
+	defer cancel()
+	if ctxR != nil {
+		ctx = ctxR
+	}
+
+	md := models.MagicLinkDatas{}
+
+	filter := bson.M{
+		"_id":        id,
+		"is_used":    0,
+		"expires_at": bson.M{"$gt": time.Now()},
+	}
+	update := bson.M{"$set": bson.M{"is_used": 1}}
+	opts := options.FindOneAndUpdate().SetReturnDocument(options.After)
+
+	err := ms.getCollection(magicLinksCollection).FindOneAndUpdate(ctx, filter, update, opts).Decode(&md)
+	if err != nil {
+		obs.Logging.NewLogHandler(obs.Logging.LLHError()).
+			Err(err).
+			Msg(fmt.Sprintf("Auth_svc - database.go - MagicLinkConsume() %v failed", id))
+		return md, err
+	}
+
+	return md, nil
+}
+
+func (ms *MagicLinkStore) MagicLinkCountSince(email string, since time.Time) (int, error) {
+	ctx := context.Background()
+	ctxR, cancel := ms.setRequestContext()
+	defer cancel()
+	if ctxR != nil {
+		ctx = ctxR
+	}
+
+	filter := bson.M{"email": email, "created_at": bson.M{"$gte": since}}
+	count, err := ms.getCollection(magicLinksCollection).CountDocuments(ctx, filter)
+	if err != nil {
+		obs.Logging.NewLogHandler(obs.Logging.LLHError()).
+			Err(err).
+			Msg(fmt.Sprintf("Auth_svc - database.go - MagicLinkCountSince() %v failed", email))
+		return 0, err
+	}
+
+	return int(count), nil
+}
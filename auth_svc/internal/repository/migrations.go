@@ -0,0 +1,382 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"gitlab.com/grpasr/asonrythme/auth_svc/internal/models"
+	mgoCltProvider "gitlab.com/grpasr/common/databases/mongo"
+	obs "gitlab.com/grpasr/common/observability"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+	"os"
+	"time"
+)
+
+const (
+	migrationsCollection     = "migrations"
+	migrationsLockCollection = "migrationsLock"
+	migrationsLockID         = "lock"
+	migrationsLockWait       = 2 * time.Second
+	migrationsTimeout        = 5 * time.Minute
+	// the lock is renewed every migrationsLockRenew while the migrations
+	// run, a lock left by a crashed instance expires after migrationsLockTTL
+	migrationsLockTTL   = time.Minute
+	migrationsLockRenew = migrationsLockTTL / 3
+)
+
+var (
+	// errMigrationsLocked is returned while an other instance hold the lock
+	errMigrationsLocked = errors.New("migrations locked by an other instance")
+	// errMigrationsLockLost is returned when the lock expired and may be
+	// held by an other instance, the migrations are stopped
+	errMigrationsLockLost = errors.New("migrations lock lost")
+)
+
+// migration is a versioned change of the schema, an applied migration
+// must never be edited, append a new one instead
+type migration struct {
+	version     int
+	description string
+	up          func(ctx context.Context, db *mongo.Database) error
+}
+
+// migrations are applied in order at startup, once
+var migrations = []migration{
+	{
+		version:     1,
+		description: "unique index on users.email",
+		up: func(ctx context.Context, db *mongo.Database) error {
+			return createIndex(ctx, db.Collection(usersCollection), mongo.IndexModel{
+				Keys:    bson.D{{Key: "email", Value: 1}},
+				Options: options.Index().SetName("email_unique").SetUnique(true),
+			})
+		},
+	},
+	{
+		version:     2,
+		description: "unique index on apiServer.service_id",
+		up: func(ctx context.Context, db *mongo.Database) error {
+			return createIndex(ctx, db.Collection(apiServerCollection), mongo.IndexModel{
+				Keys:    bson.D{{Key: "service_id", Value: 1}},
+				Options: options.Index().SetName("service_id_unique").SetUnique(true),
+			})
+		},
+	},
+	{
+		version:     3,
+		description: "TTL index on migrationsLock.expires_at",
+		up: func(ctx context.Context, db *mongo.Database) error {
+			return createTTLIndex(ctx, db.Collection(migrationsLockCollection), "expires_at", 0)
+		},
+	},
+	{
+		version:     4,
+		description: "TTL index on magicLinks.expires_at and index on magicLinks.email",
+		up: func(ctx context.Context, db *mongo.Database) error {
+			coll := db.Collection(magicLinksCollection)
+			if err := createTTLIndex(ctx, coll, "expires_at", 0); err != nil {
+				return err
+			}
+			return createIndex(ctx, coll, mongo.IndexModel{
+				Keys:    bson.D{{Key: "email", Value: 1}, {Key: "created_at", Value: 1}},
+				Options: options.Index().SetName("email_created_at"),
+			})
+		},
+	},
+	{
+		version:     5,
+		description: "index on webhookDeadLetters.updated_at",
+		up: func(ctx context.Context, db *mongo.Database) error {
+			return createIndex(ctx, db.Collection(webhookDeadLettersCollection), mongo.IndexModel{
+				Keys:    bson.D{{Key: "updated_at", Value: -1}},
+				Options: options.Index().SetName("updated_at"),
+			})
+		},
+	},
+	{
+		version:     6,
+		description: "tenant on users, apiServer and magicLinks, users.email unique per tenant",
+		up: func(ctx context.Context, db *mongo.Database) error {
+			for _, name := range []string{usersCollection, apiServerCollection, magicLinksCollection} {
+				_, err := db.Collection(name).UpdateMany(ctx,
+					bson.M{"tenant": bson.M{"$exists": false}},
+					bson.M{"$set": bson.M{"tenant": models.TenantDefault}})
+				if err != nil {
+					return err
+				}
+			}
+
+			coll := db.Collection(usersCollection)
+			if err := dropIndex(ctx, coll, "email_unique"); err != nil {
+				return err
+			}
+			return createIndex(ctx, coll, mongo.IndexModel{
+				Keys:    bson.D{{Key: "tenant", Value: 1}, {Key: "email", Value: 1}},
+				Options: options.Index().SetName("tenant_email_unique").SetUnique(true),
+			})
+		},
+	},
+	{
+		version:     7,
+		description: "index on invitations.tenant and created_at",
+		up: func(ctx context.Context, db *mongo.Database) error {
+			return createIndex(ctx, db.Collection(invitationsCollection), mongo.IndexModel{
+				Keys:    bson.D{{Key: "tenant", Value: 1}, {Key: "created_at", Value: 1}},
+				Options: options.Index().SetName("tenant_created_at"),
+			})
+		},
+	},
+}
+
+// migrationRecord is saved in the migrations collection once applied
+type migrationRecord struct {
+	Version     int       `bson:"_id"`
+	Description string    `bson:"description"`
+	AppliedAT   time.Time `bson:"applied_at"`
+}
+
+type migrationLock struct {
+	ID        string    `bson:"_id"`
+	Owner     string    `bson:"owner"`
+	ExpiresAT time.Time `bson:"expires_at"`
+}
+
+type IMigrator interface {
+	MigrationsRun() error
+}
+
+// migrationStore keep the lock and the applied migrations
+type migrationStore interface {
+	lockAcquire(ctx context.Context, owner string, ttl time.Duration) error
+	lockRenew(ctx context.Context, owner string, ttl time.Duration) error
+	lockRelease(ctx context.Context, owner string) error
+	applied(ctx context.Context) (map[int]bool, error)
+	record(ctx context.Context, r migrationRecord) error
+}
+
+// Migrator apply the pending migrations, the instances of the service
+// share a lock so only one of them migrate at a time
+type Migrator struct {
+	storeCfg *mgoCltProvider.StoreConfig
+	client   *mongo.Client
+}
+
+func NewMigrator(storeCfg *mgoCltProvider.StoreConfig, client *mongo.Client) *Migrator {
+	return &Migrator{
+		storeCfg: storeCfg,
+		client:   client,
+	}
+}
+
+func (m *Migrator) getDatabase() *mongo.Database {
+	return m.client.Database(m.storeCfg.GetDatabaseName())
+}
+
+// MigrationsRun apply, in order, the migrations which are not recorded yet
+func (m *Migrator) MigrationsRun() error {
+	if m.client == nil {
+		return errors.New("no mongo client")
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), migrationsTimeout)
+	defer cancel()
+
+	host, _ := os.Hostname()
+	owner := fmt.Sprintf("%v-%v", host, os.Getpid())
+
+	err := runMigrations(ctx, m, m.getDatabase(), migrations, owner, migrationsLockTTL, migrationsLockRenew)
+	if err != nil {
+		return err
+	}
+
+	obs.Logging.NewLogHandler(obs.Logging.LLHDebug()).
+		Msg("Auth_svc - migrations.go - MigrationsRun() exit successfully")
+
+	return nil
+}
+
+// runMigrations apply the migrations not recorded in store yet, holding
+// the lock of store, the lock is renewed until they are all applied
+func runMigrations(ctx context.Context, store migrationStore, db *mongo.Database, migrations []migration, owner string, ttl, renew time.Duration) error {
+	if err := waitLock(ctx, store, owner, ttl); err != nil {
+		return err
+	}
+	defer store.lockRelease(context.Background(), owner)
+
+	// the migrations stop once the lock is lost, an other instance
+	// may be migrating
+	ctx, cancel := context.WithCancelCause(ctx)
+	defer cancel(nil)
+	go func() {
+		ticker := time.NewTicker(renew)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+				if err := store.lockRenew(ctx, owner, ttl); err != nil {
+					obs.Logging.NewLogHandler(obs.Logging.LLHError()).
+						Err(err).
+						Msg("Auth_svc - migrations.go - renew the lock failed")
+					cancel(fmt.Errorf("%w: %v", errMigrationsLockLost, err))
+					return
+				}
+			}
+		}
+	}()
+
+	applied, err := store.applied(ctx)
+	if err != nil {
+		return err
+	}
+
+	for _, mg := range migrations {
+		if applied[mg.version] {
+			continue
+		}
+
+		obs.Logging.NewLogHandler(obs.Logging.LLHInfo()).
+			Msg(fmt.Sprintf("Auth_svc - migrations.go - apply migration %v: %v", mg.version, mg.description))
+
+		if err := mg.up(ctx, db); err != nil {
+			if cause := context.Cause(ctx); cause != nil {
+				err = cause
+			}
+			obs.Logging.NewLogHandler(obs.Logging.LLHError()).
+				Err(err).
+				Msg(fmt.Sprintf("Auth_svc - migrations.go - migration %v failed", mg.version))
+			return fmt.Errorf("migration %v(%v) failed: %w", mg.version, mg.description, err)
+		}
+
+		// a migration is recorded only if the lock is still held
+		if cause := context.Cause(ctx); cause != nil {
+			return fmt.Errorf("record migration %v failed: %w", mg.version, cause)
+		}
+		err := store.record(ctx, migrationRecord{
+			Version:     mg.version,
+			Description: mg.description,
+			AppliedAT:   time.Now(),
+		})
+		if err != nil {
+			return fmt.Errorf("record migration %v failed: %w", mg.version, err)
+		}
+	}
+
+	return nil
+}
+
+// waitLock wait for the other instances to release the lock
+func waitLock(ctx context.Context, store migrationStore, owner string, ttl time.Duration) error {
+	for {
+		err := store.lockAcquire(ctx, owner, ttl)
+		if err == nil {
+			return nil
+		}
+		if !errors.Is(err, errMigrationsLocked) {
+			return err
+		}
+
+		obs.Logging.NewLogHandler(obs.Logging.LLHInfo()).
+			Msg("Auth_svc - migrations.go - waiting for an other instance to migrate")
+
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(migrationsLockWait):
+		}
+	}
+}
+
+func (m *Migrator) applied(ctx context.Context) (map[int]bool, error) {
+	cursor, err := m.getDatabase().Collection(migrationsCollection).Find(ctx, bson.M{})
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(ctx)
+
+	records := []migrationRecord{}
+	if err := cursor.All(ctx, &records); err != nil {
+		return nil, err
+	}
+
+	applied := make(map[int]bool, len(records))
+	for _, r := range records {
+		applied[r.Version] = true
+	}
+	return applied, nil
+}
+
+// record save r, a migration already recorded is ignored
+func (m *Migrator) record(ctx context.Context, r migrationRecord) error {
+	_, err := m.getDatabase().Collection(migrationsCollection).InsertOne(ctx, r)
+	if err != nil && !mongo.IsDuplicateKeyError(err) {
+		return err
+	}
+	return nil
+}
+
+// lockAcquire take the lock, an expired lock is taken over
+func (m *Migrator) lockAcquire(ctx context.Context, owner string, ttl time.Duration) error {
+	coll := m.getDatabase().Collection(migrationsLockCollection)
+
+	_, _ = coll.DeleteOne(ctx, bson.M{
+		"_id":        migrationsLockID,
+		"expires_at": bson.M{"$lt": time.Now()},
+	})
+
+	_, err := coll.InsertOne(ctx, migrationLock{
+		ID:        migrationsLockID,
+		Owner:     owner,
+		ExpiresAT: time.Now().Add(ttl),
+	})
+	if mongo.IsDuplicateKeyError(err) {
+		return errMigrationsLocked
+	}
+	return err
+}
+
+// lockRenew push back the expiry of the lock held by owner
+func (m *Migrator) lockRenew(ctx context.Context, owner string, ttl time.Duration) error {
+	res, err := m.getDatabase().Collection(migrationsLockCollection).UpdateOne(ctx,
+		bson.M{"_id": migrationsLockID, "owner": owner},
+		bson.M{"$set": bson.M{"expires_at": time.Now().Add(ttl)}})
+	if err != nil {
+		return err
+	}
+	if res.MatchedCount == 0 {
+		return errMigrationsLockLost
+	}
+	return nil
+}
+
+func (m *Migrator) lockRelease(ctx context.Context, owner string) error {
+	_, err := m.getDatabase().Collection(migrationsLockCollection).DeleteOne(ctx,
+		bson.M{"_id": migrationsLockID, "owner": owner})
+	return err
+}
+
+func createIndex(ctx context.Context, coll *mongo.Collection, model mongo.IndexModel) error {
+	_, err := coll.Indexes().CreateOne(ctx, model)
+	return err
+}
+
+// dropIndex remove the index name, an index which does not exist is ignored
+func dropIndex(ctx context.Context, coll *mongo.Collection, name string) error {
+	_, err := coll.Indexes().DropOne(ctx, name)
+	var ce mongo.CommandError
+	if errors.As(err, &ce) && ce.Code == 27 { // IndexNotFound
+		return nil
+	}
+	return err
+}
+
+// createTTLIndex make mongo delete the documents once field is older than expireAfter(seconds)
+func createTTLIndex(ctx context.Context, coll *mongo.Collection, field string, expireAfter int32) error {
+	return createIndex(ctx, coll, mongo.IndexModel{
+		Keys:    bson.D{{Key: field, Value: 1}},
+		Options: options.Index().SetName(field + "_ttl").SetExpireAfterSeconds(expireAfter),
+	})
+}
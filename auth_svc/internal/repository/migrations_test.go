@@ -0,0 +1,208 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"gitlab.com/grpasr/common/tests"
+	"go.mongodb.org/mongo-driver/mongo"
+	"sync"
+	"testing"
+	"time"
+)
+
+// migrationStoreMock keep the lock and the records in memory, as the
+// migrationsLock and migrations collections do
+type migrationStoreMock struct {
+	owner     string
+	expiresAt time.Time
+	records   map[int]migrationRecord
+	// lostLock make the renewals fail, as if an other instance took the lock
+	lostLock bool
+	sync.Mutex
+}
+
+func newMigrationStoreMock() *migrationStoreMock {
+	return &migrationStoreMock{records: make(map[int]migrationRecord)}
+}
+
+func (ms *migrationStoreMock) lockAcquire(ctx context.Context, owner string, ttl time.Duration) error {
+	ms.Lock()
+	defer ms.Unlock()
+	if ms.owner != "" && time.Now().Before(ms.expiresAt) {
+		return errMigrationsLocked
+	}
+	ms.owner = owner
+	ms.expiresAt = time.Now().Add(ttl)
+	return nil
+}
+
+func (ms *migrationStoreMock) lockRenew(ctx context.Context, owner string, ttl time.Duration) error {
+	ms.Lock()
+	defer ms.Unlock()
+	if ms.lostLock || ms.owner != owner {
+		return errMigrationsLockLost
+	}
+	ms.expiresAt = time.Now().Add(ttl)
+	return nil
+}
+
+func (ms *migrationStoreMock) lockRelease(ctx context.Context, owner string) error {
+	ms.Lock()
+	defer ms.Unlock()
+	if ms.owner == owner {
+		ms.owner = ""
+	}
+	return nil
+}
+
+func (ms *migrationStoreMock) applied(ctx context.Context) (map[int]bool, error) {
+	ms.Lock()
+	defer ms.Unlock()
+	applied := make(map[int]bool, len(ms.records))
+	for v := range ms.records {
+		applied[v] = true
+	}
+	return applied, nil
+}
+
+func (ms *migrationStoreMock) record(ctx context.Context, r migrationRecord) error {
+	ms.Lock()
+	defer ms.Unlock()
+	if _, ok := ms.records[r.Version]; !ok {
+		ms.records[r.Version] = r
+	}
+	return nil
+}
+
+// testMigrations return count migrations appending their version to
+// applied when they run, each one last d
+func testMigrations(count int, d time.Duration, applied *[]int, mu *sync.Mutex) []migration {
+	mgs := []migration{}
+	for i := 1; i <= count; i++ {
+		version := i
+		mgs = append(mgs, migration{
+			version:     version,
+			description: "test migration",
+			up: func(ctx context.Context, db *mongo.Database) error {
+				select {
+				case <-ctx.Done():
+					return ctx.Err()
+				case <-time.After(d):
+				}
+				mu.Lock()
+				*applied = append(*applied, version)
+				mu.Unlock()
+				return nil
+			},
+		})
+	}
+	return mgs
+}
+
+func TestMigrationsOrderAndRerun(t *testing.T) {
+	tests.MaybeFail = tests.InitFailFunc(t)
+	ctx := context.Background()
+
+	store := newMigrationStoreMock()
+	mu := &sync.Mutex{}
+	var applied []int
+
+	err := runMigrations(ctx, store, nil, testMigrations(3, 0, &applied, mu), "a", time.Minute, time.Second)
+	tests.MaybeFail("runMigrations", err,
+		tests.Expect(applied, []int{1, 2, 3}),
+		tests.Expect(len(store.records), 3),
+		tests.Expect(store.owner, ""))
+
+	// a run again apply nothing, a new migration only
+	err = runMigrations(ctx, store, nil, testMigrations(3, 0, &applied, mu), "a", time.Minute, time.Second)
+	tests.MaybeFail("runMigrations_rerun", err, tests.Expect(applied, []int{1, 2, 3}))
+
+	err = runMigrations(ctx, store, nil, testMigrations(4, 0, &applied, mu), "a", time.Minute, time.Second)
+	tests.MaybeFail("runMigrations_new", err, tests.Expect(applied, []int{1, 2, 3, 4}))
+}
+
+func TestMigrationsFailedIsRetried(t *testing.T) {
+	tests.MaybeFail = tests.InitFailFunc(t)
+	ctx := context.Background()
+
+	store := newMigrationStoreMock()
+	mu := &sync.Mutex{}
+	var applied []int
+
+	// the migration 2 fail, the 3 is not applied before it
+	mgs := testMigrations(3, 0, &applied, mu)
+	up := mgs[1].up
+	mgs[1].up = func(ctx context.Context, db *mongo.Database) error { return errors.New("failed") }
+	err := runMigrations(ctx, store, nil, mgs, "a", time.Minute, time.Second)
+	tests.MaybeFail("runMigrations_failed",
+		tests.Expect(err != nil, true),
+		tests.Expect(applied, []int{1}),
+		tests.Expect(store.owner, ""))
+
+	mgs[1].up = up
+	err = runMigrations(ctx, store, nil, mgs, "a", time.Minute, time.Second)
+	tests.MaybeFail("runMigrations_retried", err, tests.Expect(applied, []int{1, 2, 3}))
+}
+
+func TestMigrationsContention(t *testing.T) {
+	tests.MaybeFail = tests.InitFailFunc(t)
+	ctx := context.Background()
+
+	store := newMigrationStoreMock()
+	mu := &sync.Mutex{}
+	var applied []int
+
+	// the instances start together, the migrations run once, in order
+	var wg sync.WaitGroup
+	errs := make([]error, 3)
+	for i, owner := range []string{"a", "b", "c"} {
+		wg.Add(1)
+		go func(i int, owner string) {
+			defer wg.Done()
+			errs[i] = runMigrations(ctx, store, nil, testMigrations(3, 20*time.Millisecond, &applied, mu), owner, time.Minute, time.Second)
+		}(i, owner)
+	}
+	wg.Wait()
+
+	tests.MaybeFail("runMigrations_contention", errs[0], errs[1], errs[2],
+		tests.Expect(applied, []int{1, 2, 3}),
+		tests.Expect(store.owner, ""))
+}
+
+func TestMigrationsLockRenewed(t *testing.T) {
+	tests.MaybeFail = tests.InitFailFunc(t)
+	ctx := context.Background()
+
+	store := newMigrationStoreMock()
+	mu := &sync.Mutex{}
+	var applied []int
+
+	// the migrations last longer than the lock ttl, it is renewed and
+	// an other instance can not take it over
+	done := make(chan error)
+	go func() {
+		done <- runMigrations(ctx, store, nil, testMigrations(1, 200*time.Millisecond, &applied, mu), "a", 50*time.Millisecond, 10*time.Millisecond)
+	}()
+
+	time.Sleep(120 * time.Millisecond)
+	tests.MaybeFail("lock_held", tests.Expect(store.lockAcquire(ctx, "b", time.Minute), errMigrationsLocked))
+
+	tests.MaybeFail("runMigrations_renewed", <-done, tests.Expect(applied, []int{1}))
+}
+
+func TestMigrationsLockLost(t *testing.T) {
+	tests.MaybeFail = tests.InitFailFunc(t)
+	ctx := context.Background()
+
+	store := newMigrationStoreMock()
+	store.lostLock = true
+	mu := &sync.Mutex{}
+	var applied []int
+
+	// the migration is stopped and not recorded once the lock is lost
+	err := runMigrations(ctx, store, nil, testMigrations(1, time.Second, &applied, mu), "a", time.Minute, 10*time.Millisecond)
+	tests.MaybeFail("runMigrations_lock_lost",
+		tests.Expect(errors.Is(err, errMigrationsLockLost), true),
+		tests.Expect(len(applied), 0),
+		tests.Expect(len(store.records), 0))
+}
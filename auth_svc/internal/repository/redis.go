@@ -1,24 +1,27 @@
 package repository
 
 import (
+	"context"
 	"fmt"
 	"github.com/gomodule/redigo/redis"
 	"gitlab.com/grpasr/asonrythme/auth_svc/internal/config"
 	"gitlab.com/grpasr/asonrythme/auth_svc/internal/models"
+	obs "gitlab.com/grpasr/common/observability"
 	"sync"
 )
 
 type IRedisStore interface {
 	// user
-	RedisUserSet(k string, d models.UserRedisDatas) error
-	RedisUserGet(k string) (models.UserRedisDatas, error)
-	RedisUserDelete(k string) error
+	RedisUserSet(ctx context.Context, k string, d models.UserRedisDatas) error
+	RedisUserGet(ctx context.Context, k string) (models.UserRedisDatas, error)
+	RedisUserDelete(ctx context.Context, k string) error
 	RedisUserCount() int
+	RedisPing(ctx context.Context) error
 	RedisUserReset()
 	// APIservice
-	RedisAPIserverSet(k string, d models.APIserverRedisDatas) error
-	RedisAPIserverGet(k string) (models.APIserverRedisDatas, error)
-	RedisAPIserverDelete(k string) error
+	RedisAPIserverSet(ctx context.Context, k string, d models.APIserverRedisDatas) error
+	RedisAPIserverGet(ctx context.Context, k string) (models.APIserverRedisDatas, error)
+	RedisAPIserverDelete(ctx context.Context, k string) error
 	RedisAPIserverCount() int
 	RedisAPIserverReset()
 }
@@ -46,13 +49,19 @@ func NewRedisStore(conf *config.Config) *RedisStore {
 }
 
 // RedisAPIservice
-func (rs *RedisStore) RedisAPIserverSet(k string, d models.APIserverRedisDatas) error {
-	conn := rs.redisPool.Get()
+func (rs *RedisStore) RedisAPIserverSet(ctx context.Context, k string, d models.APIserverRedisDatas) error {
+	ctx, span := obs.Tracing.SPNGetFromCTX(ctx, "authRepo_redisAPIserverSet", obs.Tracing.TAString("db.system", "redis"))
+	defer span.End()
+
+	conn, err := rs.redisPool.GetContext(ctx)
+	if err != nil {
+		return err
+	}
 	defer conn.Close()
 
 	k = models.AuthAPIserverKey(k)
 
-	_, err := conn.Do("HSET", redis.Args{}.Add(k).AddFlat(d)...)
+	_, err = conn.Do("HSET", redis.Args{}.Add(k).AddFlat(d)...)
 	if err != nil {
 		return err
 	}
@@ -60,8 +69,14 @@ func (rs *RedisStore) RedisAPIserverSet(k string, d models.APIserverRedisDatas)
 	return nil
 }
 
-func (rs *RedisStore) RedisAPIserverGet(k string) (models.APIserverRedisDatas, error) {
-	conn := rs.redisPool.Get()
+func (rs *RedisStore) RedisAPIserverGet(ctx context.Context, k string) (models.APIserverRedisDatas, error) {
+	ctx, span := obs.Tracing.SPNGetFromCTX(ctx, "authRepo_redisAPIserverGet", obs.Tracing.TAString("db.system", "redis"))
+	defer span.End()
+
+	conn, err := rs.redisPool.GetContext(ctx)
+	if err != nil {
+		return models.APIserverRedisDatas{}, err
+	}
 	defer conn.Close()
 
 	fk := models.AuthAPIserverKey(k)
@@ -83,13 +98,19 @@ func (rs *RedisStore) derializeAPIserver(clientID string, apiServer models.APIse
 	return apiServer
 }
 
-func (rs *RedisStore) RedisAPIserverDelete(k string) error {
-	conn := rs.redisPool.Get()
+func (rs *RedisStore) RedisAPIserverDelete(ctx context.Context, k string) error {
+	ctx, span := obs.Tracing.SPNGetFromCTX(ctx, "authRepo_redisAPIserverDelete", obs.Tracing.TAString("db.system", "redis"))
+	defer span.End()
+
+	conn, err := rs.redisPool.GetContext(ctx)
+	if err != nil {
+		return err
+	}
 	defer conn.Close()
 
 	k = models.AuthAPIserverKey(k)
 
-	_, err := conn.Do("DEL", k)
+	_, err = conn.Do("DEL", k)
 	if err != nil {
 		return err
 	}
@@ -105,13 +126,19 @@ func (rs *RedisStore) RedisAPIserverReset() {
 }
 
 // RedisUser
-func (rs *RedisStore) RedisUserSet(k string, d models.UserRedisDatas) error {
-	conn := rs.redisPool.Get()
+func (rs *RedisStore) RedisUserSet(ctx context.Context, k string, d models.UserRedisDatas) error {
+	ctx, span := obs.Tracing.SPNGetFromCTX(ctx, "authRepo_redisUserSet", obs.Tracing.TAString("db.system", "redis"))
+	defer span.End()
+
+	conn, err := rs.redisPool.GetContext(ctx)
+	if err != nil {
+		return err
+	}
 	defer conn.Close()
 
 	k = models.AuthUserKey(k)
 
-	_, err := conn.Do("HSET", redis.Args{}.Add(k).AddFlat(d)...)
+	_, err = conn.Do("HSET", redis.Args{}.Add(k).AddFlat(d)...)
 	if err != nil {
 		return err
 	}
@@ -119,8 +146,14 @@ func (rs *RedisStore) RedisUserSet(k string, d models.UserRedisDatas) error {
 	return nil
 }
 
-func (rs *RedisStore) RedisUserGet(k string) (models.UserRedisDatas, error) {
-	conn := rs.redisPool.Get()
+func (rs *RedisStore) RedisUserGet(ctx context.Context, k string) (models.UserRedisDatas, error) {
+	ctx, span := obs.Tracing.SPNGetFromCTX(ctx, "authRepo_redisUserGet", obs.Tracing.TAString("db.system", "redis"))
+	defer span.End()
+
+	conn, err := rs.redisPool.GetContext(ctx)
+	if err != nil {
+		return models.UserRedisDatas{}, err
+	}
 	defer conn.Close()
 
 	fk := models.AuthUserKey(k)
@@ -142,13 +175,19 @@ func (rs *RedisStore) derializeUser(email string, user models.UserRedisDatas) mo
 	return user
 }
 
-func (rs *RedisStore) RedisUserDelete(k string) error {
-	conn := rs.redisPool.Get()
+func (rs *RedisStore) RedisUserDelete(ctx context.Context, k string) error {
+	ctx, span := obs.Tracing.SPNGetFromCTX(ctx, "authRepo_redisUserDelete", obs.Tracing.TAString("db.system", "redis"))
+	defer span.End()
+
+	conn, err := rs.redisPool.GetContext(ctx)
+	if err != nil {
+		return err
+	}
 	defer conn.Close()
 
 	k = models.AuthUserKey(k)
 
-	_, err := conn.Do("DEL", k)
+	_, err = conn.Do("DEL", k)
 	if err != nil {
 		return err
 	}
@@ -156,6 +195,21 @@ func (rs *RedisStore) RedisUserDelete(k string) error {
 	return nil
 }
 
+// RedisPing check redis answer, for the readiness
+func (rs *RedisStore) RedisPing(ctx context.Context) error {
+	ctx, span := obs.Tracing.SPNGetFromCTX(ctx, "authRepo_redisPing", obs.Tracing.TAString("db.system", "redis"))
+	defer span.End()
+
+	conn, err := rs.redisPool.GetContext(ctx)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+
+	_, err = redis.DoContext(conn, ctx, "PING")
+	return err
+}
+
 func (rs *RedisStore) RedisUserCount() int {
 	return 0
 }
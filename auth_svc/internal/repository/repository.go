@@ -10,8 +10,17 @@ import (
 )
 
 const (
-	apiServerCollection = "apiServer"
-	usersCollection     = "users"
+	apiServerCollection  = "apiServer"
+	usersCollection      = "users"
+	magicLinksCollection = "magicLinks"
+
+	clientTenantsCollection = "clientTenants"
+	apiKeysCollection       = "apiKeys"
+	invitationsCollection   = "invitations"
+	signingKeysCollection   = "signingKeys"
+
+	webhookSubscriptionsCollection = "webhookSubscriptions"
+	webhookDeadLettersCollection   = "webhookDeadLetters"
 )
 
 type Repository struct {
@@ -19,9 +28,18 @@ type Repository struct {
 	IUserStore
 	IAPIserverStore
 	IRedisStore
+	IMigrator
+	IMagicLinkStore
+	IWebhookStore
+	IClientTenantStore
+	IAPIKeyStore
+	IInvitationStore
+	ISigningKeyStore
 }
 
-func NewRepository(conf *config.Config) *Repository {
+// NewRepository is the Repository backed by mongo and redis, it fails
+// when the mongo client can not be created
+func NewRepository(conf *config.Config) (*Repository, error) {
 	// set configs to create the client
 	nonReplicaSetConfig := mgoCltProvider.NewNonReplicaSetConfig(
 		conf.MgoGetURL(),
@@ -48,14 +66,21 @@ func NewRepository(conf *config.Config) *Repository {
 		obs.Logging.NewLogHandler(obs.Logging.LLHError()).
 			Err(err).
 			Msg("NewRepository - create mongo client failed")
-		// TODO stop the execution ??
+		return nil, err
 	}
 
 	return &Repository{
 		NewTmpStore(),
 		NewUserStore(storeConfig, client),
 		NewAPIserverStore(storeConfig, client),
-		NewRedisStore(conf)}
+		NewRedisStore(conf),
+		NewMigrator(storeConfig, client),
+		NewMagicLinkStore(storeConfig, client),
+		NewWebhookStore(storeConfig, client),
+		NewClientTenantStore(storeConfig, client),
+		NewAPIKeyStore(storeConfig, client),
+		NewInvitationStore(storeConfig, client),
+		NewSigningKeyStore(storeConfig, client)}, nil
 }
 
 // the LRU or on the app mem
@@ -1,9 +1,12 @@
 package repository
 
 import (
+	"context"
 	"errors"
 	"gitlab.com/grpasr/asonrythme/auth_svc/internal/models"
+	"sort"
 	"sync"
+	"time"
 )
 
 /************
@@ -20,9 +23,9 @@ func NewUserStoreMock() *UserStoreMock {
 	}
 }
 
-func (as *UserStoreMock) UserIsEmailExist(email string) error {
+func (as *UserStoreMock) UserIsEmailExist(ctx context.Context, tenant, email string) error {
 	as.RLock()
-	_, ok := as.str[email]
+	_, ok := as.str[models.TenantKey(tenant, email)]
 	as.RUnlock()
 	if ok {
 		return errors.New("email already exist")
@@ -30,37 +33,44 @@ func (as *UserStoreMock) UserIsEmailExist(email string) error {
 	return nil
 }
 
-func (as *UserStoreMock) UserCreate(k string, d models.UserDatas) error {
+func (as *UserStoreMock) UserCreate(ctx context.Context, tenant, k string, d models.UserDatas) error {
 	as.Lock()
-	as.str[k] = d
-	as.Unlock()
+	defer as.Unlock()
+	tk := models.TenantKey(tenant, k)
+	if _, ok := as.str[tk]; ok {
+		return errors.New("email already exist")
+	}
+	d.Tenant = tenant
+	as.str[tk] = d
 	return nil
 }
 
-func (as *UserStoreMock) UserUpdate(k string, d models.UserDatas) error {
+func (as *UserStoreMock) UserUpdate(ctx context.Context, tenant, k string, d models.UserDatas) error {
 	as.Lock()
-	as.str[k] = d
+	d.Tenant = tenant
+	as.str[models.TenantKey(tenant, k)] = d
 	as.Unlock()
 	return nil
 }
 
-func (as *UserStoreMock) UserUpdateTokens(k string, refreshTK, jwtRefreshToken string) error {
+func (as *UserStoreMock) UserUpdateTokens(ctx context.Context, tenant, k string, refreshTK, jwtRefreshToken string) error {
 	as.Lock()
-	dt, ok := as.str[k]
+	tk := models.TenantKey(tenant, k)
+	dt, ok := as.str[tk]
 	if !ok {
 		as.Unlock()
 		return errors.New("unfound email")
 	}
 	dt.RefreshTK = refreshTK
 	dt.RefreshJWT = jwtRefreshToken
-	as.str[k] = dt
+	as.str[tk] = dt
 	as.Unlock()
 	return nil
 }
 
-func (as *UserStoreMock) UserGetByEmail(k string) (models.UserDatas, error) {
+func (as *UserStoreMock) UserGetByEmail(ctx context.Context, tenant, k string) (models.UserDatas, error) {
 	as.RLock()
-	dt, ok := as.str[k]
+	dt, ok := as.str[models.TenantKey(tenant, k)]
 	as.RUnlock()
 	if !ok {
 		return models.UserDatas{}, errors.New("Not found")
@@ -68,13 +78,42 @@ func (as *UserStoreMock) UserGetByEmail(k string) (models.UserDatas, error) {
 	return dt, nil
 }
 
-func (as *UserStoreMock) UserDelete(k string) error {
+func (as *UserStoreMock) UserDelete(ctx context.Context, tenant, k string) error {
 	as.Lock()
-	delete(as.str, k)
+	delete(as.str, models.TenantKey(tenant, k))
 	as.Unlock()
 	return nil
 }
 
+func (as *UserStoreMock) UserList(ctx context.Context, skip, limit int64) ([]models.UserDatas, error) {
+	as.RLock()
+	uds := make([]models.UserDatas, 0, len(as.str))
+	for _, dt := range as.str {
+		uds = append(uds, dt)
+	}
+	as.RUnlock()
+
+	sort.Slice(uds, func(i, j int) bool {
+		if uds[i].Tenant != uds[j].Tenant {
+			return uds[i].Tenant < uds[j].Tenant
+		}
+		return uds[i].Email < uds[j].Email
+	})
+
+	if skip >= int64(len(uds)) {
+		return []models.UserDatas{}, nil
+	}
+	uds = uds[skip:]
+	if limit > 0 && limit < int64(len(uds)) {
+		uds = uds[:limit]
+	}
+	return uds, nil
+}
+
+func (as *UserStoreMock) UserPing(ctx context.Context) error {
+	return nil
+}
+
 func (as *UserStoreMock) UserCount() int {
 	var c int
 	as.RLock()
@@ -105,14 +144,14 @@ func NewAPIserverStoreMock() *APIserverStoreMock {
 	}
 }
 
-func (as *APIserverStoreMock) APIserverCreate(k string, d models.APIserverDatas) error {
+func (as *APIserverStoreMock) APIserverCreate(ctx context.Context, k string, d models.APIserverDatas) error {
 	as.Lock()
 	as.str[k] = d
 	as.Unlock()
 	return nil
 }
 
-func (as *APIserverStoreMock) APIserverUpdateTokens(svcID, refreshTK, jwtRefreshToken string) error {
+func (as *APIserverStoreMock) APIserverUpdateTokens(ctx context.Context, svcID, refreshTK, jwtRefreshToken string) error {
 	as.Lock()
 	dt, ok := as.str[svcID]
 	if !ok {
@@ -126,14 +165,14 @@ func (as *APIserverStoreMock) APIserverUpdateTokens(svcID, refreshTK, jwtRefresh
 	return nil
 }
 
-func (as *APIserverStoreMock) APIserverUpdate(svcID string, newData models.APIserverDatas) error {
+func (as *APIserverStoreMock) APIserverUpdate(ctx context.Context, svcID string, newData models.APIserverDatas) error {
 	as.Lock()
 	as.str[svcID] = newData
 	as.Unlock()
 	return nil
 }
 
-func (as *APIserverStoreMock) APIserverGetByID(k string) (models.APIserverDatas, error) {
+func (as *APIserverStoreMock) APIserverGetByID(ctx context.Context, k string) (models.APIserverDatas, error) {
 	as.RLock()
 	dt, ok := as.str[k]
 	as.RUnlock()
@@ -143,7 +182,7 @@ func (as *APIserverStoreMock) APIserverGetByID(k string) (models.APIserverDatas,
 	return dt, nil
 }
 
-func (as *APIserverStoreMock) APIserverDelete(k string) error {
+func (as *APIserverStoreMock) APIserverDelete(ctx context.Context, k string) error {
 	as.Lock()
 	delete(as.str, k)
 	as.Unlock()
@@ -183,14 +222,14 @@ func NewRedisMock() *RedisMock {
 }
 
 // RedisAPIservice
-func (as *RedisMock) RedisAPIserverSet(k string, d models.APIserverRedisDatas) error {
+func (as *RedisMock) RedisAPIserverSet(ctx context.Context, k string, d models.APIserverRedisDatas) error {
 	as.Lock()
 	as.apiStr[k] = d
 	as.Unlock()
 	return nil
 }
 
-func (as *RedisMock) RedisAPIserverGet(k string) (models.APIserverRedisDatas, error) {
+func (as *RedisMock) RedisAPIserverGet(ctx context.Context, k string) (models.APIserverRedisDatas, error) {
 	as.RLock()
 	dt, ok := as.apiStr[k]
 	as.RUnlock()
@@ -200,7 +239,7 @@ func (as *RedisMock) RedisAPIserverGet(k string) (models.APIserverRedisDatas, er
 	return dt, nil
 }
 
-func (as *RedisMock) RedisAPIserverDelete(k string) error {
+func (as *RedisMock) RedisAPIserverDelete(ctx context.Context, k string) error {
 	as.Lock()
 	delete(as.apiStr, k)
 	as.Unlock()
@@ -224,14 +263,14 @@ func (as *RedisMock) RedisAPIserverReset() {
 }
 
 // user
-func (as *RedisMock) RedisUserSet(k string, d models.UserRedisDatas) error {
+func (as *RedisMock) RedisUserSet(ctx context.Context, k string, d models.UserRedisDatas) error {
 	as.Lock()
 	as.userStr[k] = d
 	as.Unlock()
 	return nil
 }
 
-func (as *RedisMock) RedisUserGet(k string) (models.UserRedisDatas, error) {
+func (as *RedisMock) RedisUserGet(ctx context.Context, k string) (models.UserRedisDatas, error) {
 	as.RLock()
 	dt, ok := as.userStr[k]
 	as.RUnlock()
@@ -241,13 +280,17 @@ func (as *RedisMock) RedisUserGet(k string) (models.UserRedisDatas, error) {
 	return dt, nil
 }
 
-func (as *RedisMock) RedisUserDelete(k string) error {
+func (as *RedisMock) RedisUserDelete(ctx context.Context, k string) error {
 	as.Lock()
 	delete(as.userStr, k)
 	as.Unlock()
 	return nil
 }
 
+func (as *RedisMock) RedisPing(ctx context.Context) error {
+	return nil
+}
+
 func (as *RedisMock) RedisUserCount() int {
 	var c int
 	as.RLock()
@@ -263,3 +306,371 @@ func (as *RedisMock) RedisUserReset() {
 	as.userStr = make(map[string]models.UserRedisDatas)
 	as.Unlock()
 }
+
+/****************
+* MagicLinkStoreMock mock the MagicLinkStore, implement the IMagicLinkStore
+****************/
+type MagicLinkStoreMock struct {
+	str map[string]models.MagicLinkDatas
+	sync.RWMutex
+}
+
+func NewMagicLinkStoreMock() *MagicLinkStoreMock {
+	return &MagicLinkStoreMock{
+		str: make(map[string]models.MagicLinkDatas),
+	}
+}
+
+func (as *MagicLinkStoreMock) MagicLinkCreate(d models.MagicLinkDatas) error {
+	as.Lock()
+	d.CreatedAT = time.Now()
+	as.str[d.ID] = d
+	as.Unlock()
+	return nil
+}
+
+func (as *MagicLinkStoreMock) MagicLinkConsume(id string) (models.MagicLinkDatas, error) {
+	as.Lock()
+	defer as.Unlock()
+	dt, ok := as.str[id]
+	if !ok || dt.IsUsed == 1 || dt.ExpiresAT.Before(time.Now()) {
+		return models.MagicLinkDatas{}, errors.New("Not found")
+	}
+	dt.IsUsed = 1
+	as.str[id] = dt
+	return dt, nil
+}
+
+func (as *MagicLinkStoreMock) MagicLinkCountSince(email string, since time.Time) (int, error) {
+	var c int
+	as.RLock()
+	for _, dt := range as.str {
+		if dt.Email == email && !dt.CreatedAT.Before(since) {
+			c++
+		}
+	}
+	as.RUnlock()
+	return c, nil
+}
+
+/****************
+* WebhookStoreMock mock the WebhookStore, implement the IWebhookStore
+****************/
+type WebhookStoreMock struct {
+	subs        map[string]models.WebhookSubscriptionDatas
+	deadLetters map[string]models.WebhookDeliveryDatas
+	sync.RWMutex
+}
+
+func NewWebhookStoreMock() *WebhookStoreMock {
+	return &WebhookStoreMock{
+		subs:        make(map[string]models.WebhookSubscriptionDatas),
+		deadLetters: make(map[string]models.WebhookDeliveryDatas),
+	}
+}
+
+func (as *WebhookStoreMock) WebhookSubscriptionCreate(d models.WebhookSubscriptionDatas) error {
+	as.Lock()
+	defer as.Unlock()
+	if _, ok := as.subs[d.ID]; ok {
+		return errors.New("subscription already exist")
+	}
+	d.CreatedAT = time.Now()
+	as.subs[d.ID] = d
+	return nil
+}
+
+func (as *WebhookStoreMock) WebhookSubscriptionList() ([]models.WebhookSubscriptionDatas, error) {
+	as.RLock()
+	subs := make([]models.WebhookSubscriptionDatas, 0, len(as.subs))
+	for _, dt := range as.subs {
+		subs = append(subs, dt)
+	}
+	as.RUnlock()
+
+	sort.Slice(subs, func(i, j int) bool { return subs[i].CreatedAT.Before(subs[j].CreatedAT) })
+	return subs, nil
+}
+
+func (as *WebhookStoreMock) WebhookSubscriptionGetByID(id string) (models.WebhookSubscriptionDatas, error) {
+	as.RLock()
+	defer as.RUnlock()
+	dt, ok := as.subs[id]
+	if !ok {
+		return models.WebhookSubscriptionDatas{}, errors.New("Not found")
+	}
+	return dt, nil
+}
+
+func (as *WebhookStoreMock) WebhookSubscriptionDelete(id string) error {
+	as.Lock()
+	defer as.Unlock()
+	if _, ok := as.subs[id]; !ok {
+		return errors.New("Not found")
+	}
+	delete(as.subs, id)
+	return nil
+}
+
+func (as *WebhookStoreMock) WebhookDeadLetterCreate(d models.WebhookDeliveryDatas) error {
+	as.Lock()
+	d.UpdatedAT = time.Now()
+	as.deadLetters[d.ID] = d
+	as.Unlock()
+	return nil
+}
+
+func (as *WebhookStoreMock) WebhookDeadLetterList(skip, limit int64) ([]models.WebhookDeliveryDatas, error) {
+	as.RLock()
+	dls := make([]models.WebhookDeliveryDatas, 0, len(as.deadLetters))
+	for _, dt := range as.deadLetters {
+		dls = append(dls, dt)
+	}
+	as.RUnlock()
+
+	sort.Slice(dls, func(i, j int) bool { return dls[i].UpdatedAT.After(dls[j].UpdatedAT) })
+
+	if skip >= int64(len(dls)) {
+		return []models.WebhookDeliveryDatas{}, nil
+	}
+	dls = dls[skip:]
+	if limit > 0 && limit < int64(len(dls)) {
+		dls = dls[:limit]
+	}
+	return dls, nil
+}
+
+func (as *WebhookStoreMock) WebhookDeadLetterTake(id string) (models.WebhookDeliveryDatas, error) {
+	as.Lock()
+	defer as.Unlock()
+	dt, ok := as.deadLetters[id]
+	if !ok {
+		return models.WebhookDeliveryDatas{}, errors.New("Not found")
+	}
+	delete(as.deadLetters, id)
+	return dt, nil
+}
+
+/****************
+* ClientTenantStoreMock mock the ClientTenantStore, implement the IClientTenantStore
+****************/
+type ClientTenantStoreMock struct {
+	str map[string]string
+	sync.RWMutex
+}
+
+func NewClientTenantStoreMock() *ClientTenantStoreMock {
+	return &ClientTenantStoreMock{
+		str: make(map[string]string),
+	}
+}
+
+func (as *ClientTenantStoreMock) ClientTenantSet(ctx context.Context, clientID, tenant string) error {
+	as.Lock()
+	as.str[clientID] = tenant
+	as.Unlock()
+	return nil
+}
+
+func (as *ClientTenantStoreMock) ClientTenantGet(ctx context.Context, clientID string) (string, error) {
+	as.RLock()
+	tenant, ok := as.str[clientID]
+	as.RUnlock()
+	if !ok {
+		return "", errors.New("Not found")
+	}
+	return tenant, nil
+}
+
+func (as *ClientTenantStoreMock) ClientTenantDelete(ctx context.Context, clientID string) error {
+	as.Lock()
+	delete(as.str, clientID)
+	as.Unlock()
+	return nil
+}
+
+/****************
+* APIKeyStoreMock mock the APIKeyStore, implement the IAPIKeyStore
+****************/
+type APIKeyStoreMock struct {
+	str map[string]models.APIKeyDatas
+	sync.RWMutex
+}
+
+func NewAPIKeyStoreMock() *APIKeyStoreMock {
+	return &APIKeyStoreMock{
+		str: make(map[string]models.APIKeyDatas),
+	}
+}
+
+func (as *APIKeyStoreMock) APIKeyCreate(ctx context.Context, d models.APIKeyDatas) error {
+	as.Lock()
+	defer as.Unlock()
+	if _, ok := as.str[d.ID]; ok {
+		return errors.New("api key already exist")
+	}
+	d.CreatedAT = time.Now()
+	as.str[d.ID] = d
+	return nil
+}
+
+func (as *APIKeyStoreMock) APIKeyGetByID(ctx context.Context, id string) (models.APIKeyDatas, error) {
+	as.RLock()
+	defer as.RUnlock()
+	dt, ok := as.str[id]
+	if !ok {
+		return models.APIKeyDatas{}, errors.New("Not found")
+	}
+	return dt, nil
+}
+
+func (as *APIKeyStoreMock) APIKeyList(ctx context.Context, tenant string) ([]models.APIKeyDatas, error) {
+	as.RLock()
+	keys := make([]models.APIKeyDatas, 0, len(as.str))
+	for _, dt := range as.str {
+		if tenant == "" || dt.Tenant == tenant {
+			keys = append(keys, dt)
+		}
+	}
+	as.RUnlock()
+
+	sort.Slice(keys, func(i, j int) bool { return keys[i].CreatedAT.Before(keys[j].CreatedAT) })
+
+	return keys, nil
+}
+
+func (as *APIKeyStoreMock) APIKeyRevoke(ctx context.Context, id string) error {
+	as.Lock()
+	defer as.Unlock()
+	dt, ok := as.str[id]
+	if !ok {
+		return errors.New("Not found")
+	}
+	dt.IsRevoked = 1
+	as.str[id] = dt
+	return nil
+}
+
+func (as *APIKeyStoreMock) APIKeyTouch(ctx context.Context, id string, usedAt time.Time) error {
+	as.Lock()
+	defer as.Unlock()
+	dt, ok := as.str[id]
+	if !ok {
+		return errors.New("Not found")
+	}
+	dt.LastUsedAT = usedAt
+	as.str[id] = dt
+	return nil
+}
+
+/****************
+* InvitationStoreMock mock the InvitationStore, implement the IInvitationStore
+****************/
+type InvitationStoreMock struct {
+	str map[string]models.InvitationDatas
+	sync.RWMutex
+}
+
+func NewInvitationStoreMock() *InvitationStoreMock {
+	return &InvitationStoreMock{
+		str: make(map[string]models.InvitationDatas),
+	}
+}
+
+func (as *InvitationStoreMock) InvitationCreate(ctx context.Context, d models.InvitationDatas) error {
+	as.Lock()
+	defer as.Unlock()
+	if _, ok := as.str[d.ID]; ok {
+		return errors.New("invitation already exist")
+	}
+	d.CreatedAT = time.Now()
+	as.str[d.ID] = d
+	return nil
+}
+
+func (as *InvitationStoreMock) InvitationGetByID(ctx context.Context, id string) (models.InvitationDatas, error) {
+	as.RLock()
+	defer as.RUnlock()
+	dt, ok := as.str[id]
+	if !ok {
+		return models.InvitationDatas{}, errors.New("Not found")
+	}
+	return dt, nil
+}
+
+func (as *InvitationStoreMock) InvitationList(ctx context.Context, tenant string) ([]models.InvitationDatas, error) {
+	as.RLock()
+	invs := make([]models.InvitationDatas, 0, len(as.str))
+	for _, dt := range as.str {
+		if tenant == "" || dt.Tenant == tenant {
+			invs = append(invs, dt)
+		}
+	}
+	as.RUnlock()
+
+	sort.Slice(invs, func(i, j int) bool { return invs[i].CreatedAT.Before(invs[j].CreatedAT) })
+
+	return invs, nil
+}
+
+func (as *InvitationStoreMock) InvitationRevoke(ctx context.Context, id string) error {
+	as.Lock()
+	defer as.Unlock()
+	dt, ok := as.str[id]
+	if !ok {
+		return errors.New("Not found")
+	}
+	dt.IsRevoked = 1
+	as.str[id] = dt
+	return nil
+}
+
+func (as *InvitationStoreMock) InvitationConsume(ctx context.Context, id string) (models.InvitationDatas, error) {
+	as.Lock()
+	defer as.Unlock()
+	dt, ok := as.str[id]
+	now := time.Now()
+	if !ok || dt.IsUsed == 1 || dt.IsRevoked == 1 || !dt.ExpiresAT.After(now) {
+		return models.InvitationDatas{}, errors.New("Not found")
+	}
+	dt.IsUsed = 1
+	dt.UsedAT = now
+	as.str[id] = dt
+	return dt, nil
+}
+
+/****************
+* SigningKeyStoreMock mock the SigningKeyStore, implement the ISigningKeyStore
+****************/
+type SigningKeyStoreMock struct {
+	str   models.SigningKeysDatas
+	saved bool
+	sync.RWMutex
+}
+
+func NewSigningKeyStoreMock() *SigningKeyStoreMock {
+	return &SigningKeyStoreMock{}
+}
+
+func (ks *SigningKeyStoreMock) SigningKeysGet(ctx context.Context) (models.SigningKeysDatas, error) {
+	ks.RLock()
+	defer ks.RUnlock()
+	if !ks.saved {
+		return models.SigningKeysDatas{}, ErrSigningKeysNotFound
+	}
+	return ks.str, nil
+}
+
+func (ks *SigningKeyStoreMock) SigningKeysSave(ctx context.Context, d models.SigningKeysDatas) error {
+	ks.Lock()
+	defer ks.Unlock()
+	if ks.str.Version != d.Version {
+		return ErrSigningKeysConflict
+	}
+	d.ID = signingKeysID
+	d.Version++
+	d.UpdatedAT = time.Now()
+	ks.str = d
+	ks.saved = true
+	return nil
+}
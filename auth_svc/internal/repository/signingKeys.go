@@ -0,0 +1,116 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"gitlab.com/grpasr/asonrythme/auth_svc/internal/models"
+	mgoCltProvider "gitlab.com/grpasr/common/databases/mongo"
+	obs "gitlab.com/grpasr/common/observability"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"time"
+)
+
+// signingKeysID is the id of the single ring document
+const signingKeysID = "jwt"
+
+var (
+	// ErrSigningKeysNotFound is returned when no ring was saved yet
+	ErrSigningKeysNotFound = errors.New("signing keys not found")
+	// ErrSigningKeysConflict is returned when the ring was saved by an
+	// other instance since it was read
+	ErrSigningKeysConflict = errors.New("signing keys updated concurrently")
+)
+
+// the ring of the jwt signing keys, shared by the auth_svc instances
+type ISigningKeyStore interface {
+	SigningKeysGet(ctx context.Context) (models.SigningKeysDatas, error)
+	// SigningKeysSave save d if the saved ring is still at d.Version,
+	// a Version 0 create the ring
+	SigningKeysSave(ctx context.Context, d models.SigningKeysDatas) error
+}
+
+type SigningKeyStore struct {
+	storeCfg *mgoCltProvider.StoreConfig
+	client   *mongo.Client
+}
+
+func NewSigningKeyStore(storeCfg *mgoCltProvider.StoreConfig, client *mongo.Client) *SigningKeyStore {
+	ks := &SigningKeyStore{}
+	ks.storeCfg = storeCfg
+	ks.client = client
+	return ks
+}
+
+func (ks *SigningKeyStore) getCollection(name string) *mongo.Collection {
+	return ks.client.Database(ks.storeCfg.GetDatabaseName()).Collection(name)
+}
+
+// setRequestContext bound the request's context with the store timeout
+func (ks *SigningKeyStore) setRequestContext(ctx context.Context) (context.Context, context.CancelFunc) {
+	if ks.storeCfg.GetRequestTimeout() > 0 {
+		timeout := time.Duration(ks.storeCfg.GetRequestTimeout()) * time.Second
+		return context.WithTimeout(ctx, timeout)
+	}
+	return ctx, func() {}
+}
+
+func (ks *SigningKeyStore) SigningKeysGet(ctx context.Context) (models.SigningKeysDatas, error) {
+	ctx, span := obs.Tracing.SPNGetFromCTX(ctx, "authRepo_signingKeysGet", obs.Tracing.TAString("db.system", "mongodb"))
+	defer span.End()
+	ctx, cancel := ks.setRequestContext(ctx)
+	defer cancel()
+
+	d := models.SigningKeysDatas{}
+	err := ks.getCollection(signingKeysCollection).FindOne(ctx, bson.M{"_id": signingKeysID}).Decode(&d)
+	if err != nil {
+		if err == mongo.ErrNoDocuments {
+			return d, ErrSigningKeysNotFound
+		}
+		obs.Logging.NewLogHandler(obs.Logging.LLHError()).
+			Err(err).
+			Msg("Auth_svc - database.go - SigningKeysGet() failed")
+		return d, err
+	}
+
+	return d, nil
+}
+
+func (ks *SigningKeyStore) SigningKeysSave(ctx context.Context, d models.SigningKeysDatas) error {
+	ctx, span := obs.Tracing.SPNGetFromCTX(ctx, "authRepo_signingKeysSave", obs.Tracing.TAString("db.system", "mongodb"))
+	defer span.End()
+	ctx, cancel := ks.setRequestContext(ctx)
+	defer cancel()
+
+	version := d.Version
+	d.ID = signingKeysID
+	d.Version++
+	d.UpdatedAT = time.Now()
+
+	if version == 0 {
+		_, err := ks.getCollection(signingKeysCollection).InsertOne(ctx, d)
+		if mongo.IsDuplicateKeyError(err) {
+			return ErrSigningKeysConflict
+		}
+		if err != nil {
+			obs.Logging.NewLogHandler(obs.Logging.LLHError()).
+				Err(err).
+				Msg("Auth_svc - database.go - SigningKeysSave() failed")
+		}
+		return err
+	}
+
+	res, err := ks.getCollection(signingKeysCollection).ReplaceOne(ctx,
+		bson.M{"_id": signingKeysID, "version": version}, d)
+	if err != nil {
+		obs.Logging.NewLogHandler(obs.Logging.LLHError()).
+			Err(err).
+			Msg("Auth_svc - database.go - SigningKeysSave() failed")
+		return err
+	}
+	if res.MatchedCount == 0 {
+		return ErrSigningKeysConflict
+	}
+
+	return nil
+}
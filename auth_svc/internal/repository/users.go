@@ -10,18 +10,21 @@ import (
 	"go.mongodb.org/mongo-driver/bson"
 	// "go.mongodb.org/mongo-driver/bson/primitive"
 	"go.mongodb.org/mongo-driver/mongo"
-	// "go.mongodb.org/mongo-driver/mongo/options"
+	"go.mongodb.org/mongo-driver/mongo/options"
+	"go.mongodb.org/mongo-driver/mongo/readpref"
 	"time"
 )
 
 // the user database
 type IUserStore interface {
-	UserIsEmailExist(email string) error
-	UserCreate(k string, d models.UserDatas) error
-	UserUpdate(k string, d models.UserDatas) error
-	UserUpdateTokens(email, refreshTK, jwtRefreshToken string) error
-	UserGetByEmail(k string) (models.UserDatas, error)
-	UserDelete(k string) error
+	UserIsEmailExist(ctx context.Context, tenant, email string) error
+	UserCreate(ctx context.Context, tenant, k string, d models.UserDatas) error
+	UserUpdate(ctx context.Context, tenant, k string, d models.UserDatas) error
+	UserUpdateTokens(ctx context.Context, tenant, email, refreshTK, jwtRefreshToken string) error
+	UserGetByEmail(ctx context.Context, tenant, k string) (models.UserDatas, error)
+	UserDelete(ctx context.Context, tenant, k string) error
+	UserList(ctx context.Context, skip, limit int64) ([]models.UserDatas, error)
+	UserPing(ctx context.Context) error
 	UserCount() int
 	UserReset()
 }
@@ -43,29 +46,28 @@ func (us *UserStore) getCollection(name string) *mongo.Collection {
 	return us.client.Database(us.storeCfg.GetDatabaseName()).Collection(name)
 }
 
-func (us *UserStore) setRequestContext() (context.Context, context.CancelFunc) {
-	ctx := context.Background()
+// setRequestContext bound the request's context with the store timeout
+func (us *UserStore) setRequestContext(ctx context.Context) (context.Context, context.CancelFunc) {
 	if us.storeCfg.GetRequestTimeout() > 0 {
 		timeout := time.Duration(us.storeCfg.GetRequestTimeout()) * time.Second
 		return context.WithTimeout(ctx, timeout)
 	}
-	return nil, func() {}
+	return ctx, func() {}
 }
 
-func (us *UserStore) UserIsEmailExist(email string) error {
+// UserIsEmailExist return an error if the email is already used within the tenant
+func (us *UserStore) UserIsEmailExist(ctx context.Context, tenant, email string) error {
 	obs.Logging.NewLogHandler(obs.Logging.LLHDebug()).
 		Msg(fmt.Sprintf("Auth_svc - database.go - reach IsUserEmailExist() %v", email))
 
-	ctx := context.Background()
-	ctxR, cancel := us.setRequestContext()
+	ctx, span := obs.Tracing.SPNGetFromCTX(ctx, "authRepo_userIsEmailExist", obs.Tracing.TAString("db.system", "mongodb"))
+	defer span.End()
+	ctx, cancel := us.setRequestContext(ctx)
 	defer cancel()
-	if ctxR != nil {
-		ctx = ctxR
-	}
 
 	// Check if the email already exists
 	existingUser := models.UserDatas{}
-	err := us.getCollection(usersCollection).FindOne(ctx, bson.M{"email": email}).Decode(&existingUser)
+	err := us.getCollection(usersCollection).FindOne(ctx, bson.M{"tenant": tenant, "email": email}).Decode(&existingUser)
 	if err == nil {
 		// If user with the same email already exists, return an error
 		return e.NewCustomHTTPStatus(e.StatusBadRequest, "email already exist")
@@ -82,24 +84,29 @@ func (us *UserStore) UserIsEmailExist(email string) error {
 	return nil
 }
 
-func (us *UserStore) UserCreate(email string, d models.UserDatas) error {
+func (us *UserStore) UserCreate(ctx context.Context, tenant, email string, d models.UserDatas) error {
 	obs.Logging.NewLogHandler(obs.Logging.LLHDebug()).
 		Msg(fmt.Sprintf("Auth_svc - database.go - reach UserCreate() %v", d.Email))
 
-	ctx := context.Background()
-	ctxR, cancel := us.setRequestContext()
+	ctx, span := obs.Tracing.SPNGetFromCTX(ctx, "authRepo_userCreate", obs.Tracing.TAString("db.system", "mongodb"))
+	defer span.End()
+	ctx, cancel := us.setRequestContext(ctx)
 	defer cancel()
-	if ctxR != nil {
-		ctx = ctxR
-	}
 
 	// create the new user
 	// newID := primitive.NewObjectID()
 	// d.ID = newID
+	d.Tenant = tenant
 	d.CreatedAT = time.Now()
 
 	_, err := us.getCollection(usersCollection).InsertOne(ctx, d)
 	if err != nil {
+		// the unique index on tenant and email make the insert fail if the email exist
+		if mongo.IsDuplicateKeyError(err) {
+			obs.Logging.NewLogHandler(obs.Logging.LLHDebug()).
+				Msg(fmt.Sprintf("Auth_svc - database.go - UserCreate() %v already exist", d.Email))
+			return e.NewCustomHTTPStatus(e.StatusBadRequest, "email already exist")
+		}
 		obs.Logging.NewLogHandler(obs.Logging.LLHError()).
 			Err(err).
 			Msg(fmt.Sprintf("Auth_svc - database.go - UserCreate() %v failed", d.Email))
@@ -112,21 +119,20 @@ func (us *UserStore) UserCreate(email string, d models.UserDatas) error {
 	return nil
 }
 
-func (us *UserStore) UserUpdate(email string, newData models.UserDatas) error {
+func (us *UserStore) UserUpdate(ctx context.Context, tenant, email string, newData models.UserDatas) error {
 	// Log the entry point of the function
 	obs.Logging.NewLogHandler(obs.Logging.LLHDebug()).
 		Msg(fmt.Sprintf("Auth_svc - database.go - UserUpdate() %v", email))
 
-	ctx := context.Background()
-	ctxR, cancel := us.setRequestContext()
+	ctx, span := obs.Tracing.SPNGetFromCTX(ctx, "authRepo_userUpdate", obs.Tracing.TAString("db.system", "mongodb"))
+	defer span.End()
+	ctx, cancel := us.setRequestContext(ctx)
 	defer cancel()
-	if ctxR != nil {
-		ctx = ctxR
-	}
 
+	newData.Tenant = tenant
 	newData.UpdatedAT = time.Now()
 
-	filter := bson.M{"email": email}
+	filter := bson.M{"tenant": tenant, "email": email}
 	update := bson.M{"$set": newData}
 	result, err := us.getCollection(usersCollection).UpdateOne(ctx, filter, update)
 	if err != nil {
@@ -142,19 +148,17 @@ func (us *UserStore) UserUpdate(email string, newData models.UserDatas) error {
 	return nil
 }
 
-func (us *UserStore) UserUpdateTokens(email string, refreshTK, jwtRefreshToken string) error {
+func (us *UserStore) UserUpdateTokens(ctx context.Context, tenant, email string, refreshTK, jwtRefreshToken string) error {
 	// Log the entry point of the function
 	obs.Logging.NewLogHandler(obs.Logging.LLHDebug()).
 		Msg(fmt.Sprintf("Auth_svc - database.go - UserUpdateTokens() %v", email))
 
-	ctx := context.Background()
-	ctxR, cancel := us.setRequestContext()
+	ctx, span := obs.Tracing.SPNGetFromCTX(ctx, "authRepo_userUpdateTokens", obs.Tracing.TAString("db.system", "mongodb"))
+	defer span.End()
+	ctx, cancel := us.setRequestContext(ctx)
 	defer cancel()
-	if ctxR != nil {
-		ctx = ctxR
-	}
 
-	filter := bson.M{"email": email}
+	filter := bson.M{"tenant": tenant, "email": email}
 	update := bson.M{"$set": bson.M{
 		"refresh_tk":  refreshTK,
 		"refresh_jwt": jwtRefreshToken,
@@ -173,20 +177,18 @@ func (us *UserStore) UserUpdateTokens(email string, refreshTK, jwtRefreshToken s
 	return nil
 }
 
-func (us *UserStore) UserGetByEmail(email string) (models.UserDatas, error) {
+func (us *UserStore) UserGetByEmail(ctx context.Context, tenant, email string) (models.UserDatas, error) {
 	obs.Logging.NewLogHandler(obs.Logging.LLHDebug()).
 		Msg(fmt.Sprintf("Auth_svc - database.go - reach UserGetByEmail() %v", email))
 
-	ctx := context.Background()
-	ctxR, cancel := us.setRequestContext()
+	ctx, span := obs.Tracing.SPNGetFromCTX(ctx, "authRepo_userGetByEmail", obs.Tracing.TAString("db.system", "mongodb"))
+	defer span.End()
+	ctx, cancel := us.setRequestContext(ctx)
 	defer cancel()
-	if ctxR != nil {
-		ctx = ctxR
-	}
 
 	ud := models.UserDatas{}
 
-	filter := bson.M{"email": email}
+	filter := bson.M{"tenant": tenant, "email": email}
 	result := us.getCollection(usersCollection).FindOne(ctx, filter)
 	if err := result.Err(); err != nil {
 		if err == mongo.ErrNoDocuments {
@@ -214,20 +216,18 @@ func (us *UserStore) UserGetByEmail(email string) (models.UserDatas, error) {
 	return ud, nil
 }
 
-func (us *UserStore) UserDelete(email string) error {
+func (us *UserStore) UserDelete(ctx context.Context, tenant, email string) error {
 	// Log the entry point of the function
 	obs.Logging.NewLogHandler(obs.Logging.LLHDebug()).
 		Msg(fmt.Sprintf("Auth_svc - database.go - UserDelete() %v", email))
 
-	ctx := context.Background()
-	ctxR, cancel := us.setRequestContext()
+	ctx, span := obs.Tracing.SPNGetFromCTX(ctx, "authRepo_userDelete", obs.Tracing.TAString("db.system", "mongodb"))
+	defer span.End()
+	ctx, cancel := us.setRequestContext(ctx)
 	defer cancel()
-	if ctxR != nil {
-		ctx = ctxR
-	}
 
-	// Define the filter to find the user by email
-	filter := bson.M{"email": email}
+	// Define the filter to find the user by email within its tenant
+	filter := bson.M{"tenant": tenant, "email": email}
 	result, err := us.getCollection(usersCollection).DeleteOne(ctx, filter)
 	if err != nil {
 		obs.Logging.NewLogHandler(obs.Logging.LLHError()).
@@ -243,6 +243,58 @@ func (us *UserStore) UserDelete(email string) error {
 	return nil
 }
 
+func (us *UserStore) UserList(ctx context.Context, skip, limit int64) ([]models.UserDatas, error) {
+	obs.Logging.NewLogHandler(obs.Logging.LLHDebug()).
+		Msg(fmt.Sprintf("Auth_svc - database.go - reach UserList() skip %v limit %v", skip, limit))
+
+	ctx, span := obs.Tracing.SPNGetFromCTX(ctx, "authRepo_userList", obs.Tracing.TAString("db.system", "mongodb"))
+	defer span.End()
+	ctx, cancel := us.setRequestContext(ctx)
+	defer cancel()
+
+	opts := options.Find().
+		SetSort(bson.D{{Key: "tenant", Value: 1}, {Key: "email", Value: 1}}).
+		SetSkip(skip).
+		SetLimit(limit).
+		SetProjection(bson.M{"password": 0, "refresh_tk": 0, "refresh_jwt": 0})
+
+	cursor, err := us.getCollection(usersCollection).Find(ctx, bson.M{}, opts)
+	if err != nil {
+		obs.Logging.NewLogHandler(obs.Logging.LLHError()).
+			Err(err).
+			Msg("Auth_svc - database.go - UserList() failed")
+		return nil, err
+	}
+	defer cursor.Close(ctx)
+
+	uds := []models.UserDatas{}
+	if err := cursor.All(ctx, &uds); err != nil {
+		obs.Logging.NewLogHandler(obs.Logging.LLHError()).
+			Err(err).
+			Msg("Auth_svc - database.go - UserList() decode failed")
+		return nil, err
+	}
+
+	obs.Logging.NewLogHandler(obs.Logging.LLHDebug()).
+		Msg(fmt.Sprintf("Auth_svc - database.go - UserList() return %v user(s)", len(uds)))
+
+	return uds, nil
+}
+
+// UserPing check the mongo primary is reachable, for the readiness
+func (us *UserStore) UserPing(ctx context.Context) error {
+	ctx, span := obs.Tracing.SPNGetFromCTX(ctx, "authRepo_userPing", obs.Tracing.TAString("db.system", "mongodb"))
+	defer span.End()
+	ctx, cancel := us.setRequestContext(ctx)
+	defer cancel()
+
+	if us.client == nil {
+		return e.NewCustomHTTPStatus(e.StatusInternalServerError, "mongo client not set")
+	}
+
+	return us.client.Ping(ctx, readpref.Primary())
+}
+
 func (us *UserStore) UserCount() int {
 	return 0 // for the interface pupose
 }
@@ -0,0 +1,221 @@
+package repository
+
+import (
+	"context"
+	"fmt"
+	"gitlab.com/grpasr/asonrythme/auth_svc/internal/models"
+	mgoCltProvider "gitlab.com/grpasr/common/databases/mongo"
+	obs "gitlab.com/grpasr/common/observability"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+	"time"
+)
+
+// the webhooks database, the subscriptions and the failed deliveries(dead letters)
+type IWebhookStore interface {
+	WebhookSubscriptionCreate(d models.WebhookSubscriptionDatas) error
+	WebhookSubscriptionList() ([]models.WebhookSubscriptionDatas, error)
+	WebhookSubscriptionGetByID(id string) (models.WebhookSubscriptionDatas, error)
+	WebhookSubscriptionDelete(id string) error
+	WebhookDeadLetterCreate(d models.WebhookDeliveryDatas) error
+	WebhookDeadLetterList(skip, limit int64) ([]models.WebhookDeliveryDatas, error)
+	WebhookDeadLetterTake(id string) (models.WebhookDeliveryDatas, error)
+}
+
+type WebhookStore struct {
+	storeCfg *mgoCltProvider.StoreConfig
+	client   *mongo.Client
+}
+
+func NewWebhookStore(storeCfg *mgoCltProvider.StoreConfig, client *mongo.Client) *WebhookStore {
+	ws := &WebhookStore{}
+	ws.storeCfg = storeCfg
+	ws.client = client
+	return ws
+}
+
+func (ws *WebhookStore) getCollection(name string) *mongo.Collection {
+	return ws.client.Database(ws.storeCfg.GetDatabaseName()).Collection(name)
+}
+
+func (ws *WebhookStore) setRequestContext() (context.Context, context.CancelFunc) {
+	ctx := context.Background()
+	if ws.storeCfg.GetRequestTimeout() > 0 {
+		timeout := time.Duration(ws.storeCfg.GetRequestTimeout()) * time.Second
+		return context.WithTimeout(ctx, timeout)
+	}
+	return nil, func() {}
+}
+
+func (ws *WebhookStore) WebhookSubscriptionCreate(d models.WebhookSubscriptionDatas) error {
+	obs.Logging.NewLogHandler(obs.Logging.LLHDebug()).
+		Msg(fmt.Sprintf("Auth_svc - database.go - reach WebhookSubscriptionCreate() %v", d.URL))
+
+	ctx := context.Background()
+	ctxR, cancel := ws.setRequestContext()
+	defer cancel()
+	if ctxR != nil {
+		ctx = ctxR
+	}
+
+	d.CreatedAT = time.Now()
+
+	_, err := ws.getCollection(webhookSubscriptionsCollection).InsertOne(ctx, d)
+	if err != nil {
+		obs.Logging.NewLogHandler(obs.Logging.LLHError()).
+			Err(err).
+			Msg(fmt.Sprintf("Auth_svc - database.go - WebhookSubscriptionCreate() %v failed", d.URL))
+		return err
+	}
+
+	return nil
+}
+
+func (ws *WebhookStore) WebhookSubscriptionList() ([]models.WebhookSubscriptionDatas, error) {
+	ctx := context.Background()
+	ctxR, cancel := ws.setRequestContext()
+	defer cancel()
+	if ctxR != nil {
+		ctx = ctxR
+	}
+
+	subs := []models.WebhookSubscriptionDatas{}
+
+	opts := options.Find().SetSort(bson.D{{Key: "created_at", Value: 1}})
+	cursor, err := ws.getCollection(webhookSubscriptionsCollection).Find(ctx, bson.M{}, opts)
+	if err != nil {
+		obs.Logging.NewLogHandler(obs.Logging.LLHError()).
+			Err(err).
+			Msg("Auth_svc - database.go - WebhookSubscriptionList() failed")
+		return subs, err
+	}
+	defer cursor.Close(ctx)
+
+	if err := cursor.All(ctx, &subs); err != nil {
+		return subs, err
+	}
+
+	return subs, nil
+}
+
+func (ws *WebhookStore) WebhookSubscriptionGetByID(id string) (models.WebhookSubscriptionDatas, error) {
+	ctx := context.Background()
+	ctxR, cancel := ws.setRequestContext()
+	defer cancel()
+	if ctxR != nil {
+		ctx = ctxR
+	}
+
+	sub := models.WebhookSubscriptionDatas{}
+	err := ws.getCollection(webhookSubscriptionsCollection).FindOne(ctx, bson.M{"_id": id}).Decode(&sub)
+	if err != nil {
+		obs.Logging.NewLogHandler(obs.Logging.LLHError()).
+			Err(err).
+			Msg(fmt.Sprintf("Auth_svc - database.go - WebhookSubscriptionGetByID() %v failed", id))
+		return sub, err
+	}
+
+	return sub, nil
+}
+
+func (ws *WebhookStore) WebhookSubscriptionDelete(id string) error {
+	ctx := context.Background()
+	ctxR, cancel := ws.setRequestContext()
+	defer cancel()
+	if ctxR != nil {
+		ctx = ctxR
+	}
+
+	res, err := ws.getCollection(webhookSubscriptionsCollection).DeleteOne(ctx, bson.M{"_id": id})
+	if err != nil {
+		obs.Logging.NewLogHandler(obs.Logging.LLHError()).
+			Err(err).
+			Msg(fmt.Sprintf("Auth_svc - database.go - WebhookSubscriptionDelete() %v failed", id))
+		return err
+	}
+	if res.DeletedCount == 0 {
+		return mongo.ErrNoDocuments
+	}
+
+	return nil
+}
+
+func (ws *WebhookStore) WebhookDeadLetterCreate(d models.WebhookDeliveryDatas) error {
+	obs.Logging.NewLogHandler(obs.Logging.LLHDebug()).
+		Msg(fmt.Sprintf("Auth_svc - database.go - reach WebhookDeadLetterCreate() %v", d.ID))
+
+	ctx := context.Background()
+	ctxR, cancel := ws.setRequestContext()
+	defer cancel()
+	if ctxR != nil {
+		ctx = ctxR
+	}
+
+	d.UpdatedAT = time.Now()
+
+	filter := bson.M{"_id": d.ID}
+	opts := options.Replace().SetUpsert(true)
+	_, err := ws.getCollection(webhookDeadLettersCollection).ReplaceOne(ctx, filter, d, opts)
+	if err != nil {
+		obs.Logging.NewLogHandler(obs.Logging.LLHError()).
+			Err(err).
+			Msg(fmt.Sprintf("Auth_svc - database.go - WebhookDeadLetterCreate() %v failed", d.ID))
+		return err
+	}
+
+	return nil
+}
+
+func (ws *WebhookStore) WebhookDeadLetterList(skip, limit int64) ([]models.WebhookDeliveryDatas, error) {
+	ctx := context.Background()
+	ctxR, cancel := ws.setRequestContext()
+	defer cancel()
+	if ctxR != nil {
+		ctx = ctxR
+	}
+
+	deliveries := []models.WebhookDeliveryDatas{}
+
+	opts := options.Find().
+		SetSort(bson.D{{Key: "updated_at", Value: -1}}).
+		SetSkip(skip).
+		SetLimit(limit)
+
+	cursor, err := ws.getCollection(webhookDeadLettersCollection).Find(ctx, bson.M{}, opts)
+	if err != nil {
+		obs.Logging.NewLogHandler(obs.Logging.LLHError()).
+			Err(err).
+			Msg("Auth_svc - database.go - WebhookDeadLetterList() failed")
+		return deliveries, err
+	}
+	defer cursor.Close(ctx)
+
+	if err := cursor.All(ctx, &deliveries); err != nil {
+		return deliveries, err
+	}
+
+	return deliveries, nil
+}
+
+// WebhookDeadLetterTake remove the dead letter and return it, so
+// a dead letter is replayed once
+func (ws *WebhookStore) WebhookDeadLetterTake(id string) (models.WebhookDeliveryDatas, error) {
+	ctx := context.Background()
+	ctxR, cancel := ws.setRequestContext()
+	defer cancel()
+	if ctxR != nil {
+		ctx = ctxR
+	}
+
+	d := models.WebhookDeliveryDatas{}
+	err := ws.getCollection(webhookDeadLettersCollection).FindOneAndDelete(ctx, bson.M{"_id": id}).Decode(&d)
+	if err != nil {
+		obs.Logging.NewLogHandler(obs.Logging.LLHError()).
+			Err(err).
+			Msg(fmt.Sprintf("Auth_svc - database.go - WebhookDeadLetterTake() %v failed", id))
+		return d, err
+	}
+
+	return d, nil
+}
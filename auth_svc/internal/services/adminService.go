@@ -0,0 +1,376 @@
+package services
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/base64"
+	"fmt"
+	oauth2 "github.com/djedjethai/go-oauth2-openid"
+	"github.com/djedjethai/go-oauth2-openid/models"
+	"github.com/djedjethai/go-oauth2-openid/server"
+	"github.com/golang-jwt/jwt"
+	authModels "gitlab.com/grpasr/asonrythme/auth_svc/internal/models"
+	"gitlab.com/grpasr/asonrythme/auth_svc/internal/repository"
+	e "gitlab.com/grpasr/common/errors/json"
+	obs "gitlab.com/grpasr/common/observability"
+	"time"
+)
+
+const (
+	adminUserListLimitDefault int64 = 50
+	adminUserListLimitMax     int64 = 500
+	adminDebugTokenTTLDefault       = 5 * time.Minute
+)
+
+// IClientStore is the part of the oauth2 client store the admin needs
+type IClientStore interface {
+	Create(info oauth2.ClientInfo) error
+	GetByID(ctx context.Context, id string) (oauth2.ClientInfo, error)
+	RemoveByID(id string) error
+}
+
+// IAdminService hold the operations of the /v1/admin endpoints
+type IAdminService interface {
+	AdminClientCreate(ctx context.Context, clientID, domain, userID, tenant string) (*AdminClient, e.IError)
+	AdminClientRotate(ctx context.Context, clientID string) (*AdminClient, e.IError)
+	AdminClientRevoke(ctx context.Context, clientID string) e.IError
+	AdminUserList(ctx context.Context, skip, limit int64) ([]AdminUser, e.IError)
+	AdminUserDisable(ctx context.Context, tenant, email string) e.IError
+	AdminUserDelete(ctx context.Context, tenant, email string) e.IError
+	AdminSessionRevoke(ctx context.Context, tenant, subject, role string) e.IError
+	AdminKeyRotate(ctx context.Context) (string, e.IError)
+	AdminDebugToken(ctx context.Context, serviceID, scope string, ttl time.Duration) (*AdminDebugToken, e.IError)
+}
+
+// AdminClient is an oauth2 client, the secret is only returned
+// when it is created or rotated
+type AdminClient struct {
+	ID     string `json:"id"`
+	Secret string `json:"secret,omitempty"`
+	Domain string `json:"domain"`
+	UserID string `json:"user_id"`
+	Tenant string `json:"tenant"`
+}
+
+// AdminUser is the user's account as seen by the operators
+type AdminUser struct {
+	Tenant           string    `json:"tenant"`
+	Email            string    `json:"email"`
+	Role             string    `json:"role"`
+	IsEmailValidated bool      `json:"is_email_validated"`
+	IsDisabled       bool      `json:"is_disabled"`
+	CreatedAT        time.Time `json:"created_at"`
+	UpdatedAT        time.Time `json:"updated_at"`
+}
+
+// AdminDebugToken is a short lived jwt_token minted for a service
+type AdminDebugToken struct {
+	Jwt       string `json:"jwt"`
+	KeyID     string `json:"key_id"`
+	ExpiresAt int64  `json:"expires_at"`
+}
+
+type AdminService struct {
+	srv              *server.Server
+	repos            *repository.Repository
+	clients          IClientStore
+	tenants          *TenantPolicy
+	keys             IKeyRing
+	debugTokenMaxTTL time.Duration
+	webhooks         IWebhookPublisher
+}
+
+func NewAdminService(srv *server.Server, rp *repository.Repository, cs IClientStore, t *TenantPolicy, k IKeyRing, debugTokenMaxTTL time.Duration, wh IWebhookPublisher) IAdminService {
+	return &AdminService{
+		srv:              srv,
+		repos:            rp,
+		clients:          cs,
+		tenants:          t,
+		keys:             k,
+		debugTokenMaxTTL: debugTokenMaxTTL,
+		webhooks:         wh,
+	}
+}
+
+// AdminClientCreate register a new oauth2 client with a random secret,
+// its users and tokens belong to the tenant(default if not set)
+func (a *AdminService) AdminClientCreate(ctx context.Context, clientID, domain, userID, tenant string) (*AdminClient, e.IError) {
+	if clientID == "" || domain == "" {
+		return nil, e.NewCustomHTTPStatus(e.StatusBadRequest, "", "id and domain are required")
+	}
+	if userID == "" {
+		userID = clientID
+	}
+	tenant = tenantOrDefault(tenant)
+	if ce := TenantValidate(tenant); ce != nil {
+		return nil, ce
+	}
+
+	if _, err := a.clients.GetByID(ctx, clientID); err == nil {
+		return nil, e.NewCustomHTTPStatus(e.StatusBadRequest, "", "client already exist")
+	}
+
+	if ce := a.tenants.TenantSet(ctx, clientID, tenant); ce != nil {
+		return nil, ce
+	}
+
+	return a.createClient(clientID, domain, userID, tenant)
+}
+
+// AdminClientRotate replace the secret of an existing client
+func (a *AdminService) AdminClientRotate(ctx context.Context, clientID string) (*AdminClient, e.IError) {
+	client, err := a.clients.GetByID(ctx, clientID)
+	if err != nil || client == nil {
+		return nil, e.NewCustomHTTPStatus(e.StatusNotFound, "", "client not found")
+	}
+
+	if err := a.clients.RemoveByID(clientID); err != nil {
+		obs.Logging.NewLogHandler(obs.Logging.LLHError()).
+			Err(err).
+			Msg(fmt.Sprintf("AdminClientRotate - remove %v failed", clientID))
+		return nil, e.NewCustomHTTPStatus(e.StatusInternalServerError)
+	}
+
+	return a.createClient(clientID, client.GetDomain(), client.GetUserID(), a.tenants.TenantOf(ctx, clientID))
+}
+
+// AdminClientRevoke remove the client, it can no longer authenticate
+func (a *AdminService) AdminClientRevoke(ctx context.Context, clientID string) e.IError {
+	if _, err := a.clients.GetByID(ctx, clientID); err != nil {
+		return e.NewCustomHTTPStatus(e.StatusNotFound, "", "client not found")
+	}
+
+	if err := a.clients.RemoveByID(clientID); err != nil {
+		obs.Logging.NewLogHandler(obs.Logging.LLHError()).
+			Err(err).
+			Msg(fmt.Sprintf("AdminClientRevoke - remove %v failed", clientID))
+		return e.NewCustomHTTPStatus(e.StatusInternalServerError)
+	}
+	a.tenants.TenantDelete(ctx, clientID)
+
+	obs.Logging.NewLogHandler(obs.Logging.LLHInfo()).
+		Msg(fmt.Sprintf("AdminClientRevoke - client %v revoked", clientID))
+
+	return nil
+}
+
+func (a *AdminService) createClient(clientID, domain, userID, tenant string) (*AdminClient, e.IError) {
+	secret, err := newRandomString(24)
+	if err != nil {
+		obs.Logging.NewLogHandler(obs.Logging.LLHError()).
+			Err(err).
+			Msg("createClient - generate secret failed")
+		return nil, e.NewCustomHTTPStatus(e.StatusInternalServerError)
+	}
+
+	err = a.clients.Create(&models.Client{
+		ID:     clientID,
+		Secret: secret,
+		Domain: domain,
+		UserID: userID,
+	})
+	if err != nil {
+		obs.Logging.NewLogHandler(obs.Logging.LLHError()).
+			Err(err).
+			Msg(fmt.Sprintf("createClient - create %v failed", clientID))
+		return nil, e.NewCustomHTTPStatus(e.StatusInternalServerError)
+	}
+
+	obs.Logging.NewLogHandler(obs.Logging.LLHInfo()).
+		Msg(fmt.Sprintf("createClient - client %v saved", clientID))
+
+	return &AdminClient{ID: clientID, Secret: secret, Domain: domain, UserID: userID, Tenant: tenant}, nil
+}
+
+// AdminUserList return a page of the users, sorted by tenant and email
+func (a *AdminService) AdminUserList(ctx context.Context, skip, limit int64) ([]AdminUser, e.IError) {
+	if skip < 0 {
+		skip = 0
+	}
+	if limit <= 0 {
+		limit = adminUserListLimitDefault
+	}
+	if limit > adminUserListLimitMax {
+		limit = adminUserListLimitMax
+	}
+
+	uds, err := a.repos.UserList(ctx, skip, limit)
+	if err != nil {
+		return nil, e.NewCustomHTTPStatus(e.StatusInternalServerError)
+	}
+
+	users := make([]AdminUser, 0, len(uds))
+	for _, ud := range uds {
+		users = append(users, AdminUser{
+			Tenant:           ud.Tenant,
+			Email:            ud.Email,
+			Role:             ud.Role,
+			IsEmailValidated: ud.IsEmailValidated == 1,
+			IsDisabled:       ud.IsDisabled == 1,
+			CreatedAT:        ud.CreatedAT,
+			UpdatedAT:        ud.UpdatedAT,
+		})
+	}
+
+	return users, nil
+}
+
+// AdminUserDisable disable the user's account and revoke its session,
+// a disabled user can not signin nor refresh its jwt_token
+func (a *AdminService) AdminUserDisable(ctx context.Context, tenant, email string) e.IError {
+	tenant = tenantOrDefault(tenant)
+
+	ud, err := a.repos.UserGetByEmail(ctx, tenant, email)
+	if err != nil {
+		return e.NewCustomHTTPStatus(e.StatusNotFound, "", "user not found")
+	}
+
+	ud.IsDisabled = 1
+	if err := a.repos.UserUpdate(ctx, tenant, email, ud); err != nil {
+		return e.NewCustomHTTPStatus(e.StatusInternalServerError)
+	}
+
+	obs.Logging.NewLogHandler(obs.Logging.LLHInfo()).
+		Msg(fmt.Sprintf("AdminUserDisable - user %v disabled", email))
+
+	return a.AdminSessionRevoke(context.Background(), tenant, email, "user")
+}
+
+// AdminUserDelete revoke the user's session and delete the account
+func (a *AdminService) AdminUserDelete(ctx context.Context, tenant, email string) e.IError {
+	tenant = tenantOrDefault(tenant)
+
+	if ce := a.AdminSessionRevoke(ctx, tenant, email, "user"); ce != nil {
+		return ce
+	}
+
+	if err := a.repos.UserDelete(ctx, tenant, email); err != nil {
+		return e.NewCustomHTTPStatus(e.StatusInternalServerError)
+	}
+	_ = a.repos.RedisUserDelete(ctx, authModels.TenantKey(tenant, email))
+
+	obs.Logging.NewLogHandler(obs.Logging.LLHInfo()).
+		Msg(fmt.Sprintf("AdminUserDelete - user %v deleted", email))
+
+	a.webhooks.WebhookPublish(WebhookEventUserDeleted, map[string]string{"email": email, "tenant": tenant})
+
+	return nil
+}
+
+// AdminSessionRevoke remove the tokens of a user or an APIserver,
+// the current jwt_token stay valid until it expires but can not be refreshed,
+// the tenant is only used for the users
+func (a *AdminService) AdminSessionRevoke(ctx context.Context, tenant, subject, role string) e.IError {
+	var refreshTK string
+
+	switch role {
+	case "user":
+		tenant = tenantOrDefault(tenant)
+		ud, err := a.repos.UserGetByEmail(ctx, tenant, subject)
+		if err != nil {
+			return e.NewCustomHTTPStatus(e.StatusNotFound, "", "user not found")
+		}
+		refreshTK = ud.RefreshTK
+		if err := a.repos.UserUpdateTokens(ctx, tenant, subject, "", ""); err != nil {
+			return e.NewCustomHTTPStatus(e.StatusInternalServerError)
+		}
+	case "APIserver":
+		as, err := a.repos.APIserverGetByID(ctx, subject)
+		if err != nil {
+			return e.NewCustomHTTPStatus(e.StatusNotFound, "", "service not found")
+		}
+		refreshTK = as.RefreshTK
+		if err := a.repos.APIserverUpdateTokens(ctx, subject, "", ""); err != nil {
+			return e.NewCustomHTTPStatus(e.StatusInternalServerError)
+		}
+	default:
+		return e.NewCustomHTTPStatus(e.StatusBadRequest, "", "role must be user or APIserver")
+	}
+
+	if refreshTK != "" {
+		if err := a.srv.Manager.RemoveAllTokensByRefreshToken(ctx, refreshTK); err != nil {
+			obs.Logging.NewLogHandler(obs.Logging.LLHError()).
+				Err(err).
+				Msg(fmt.Sprintf("AdminSessionRevoke - remove %v tokens failed", subject))
+			return e.NewCustomHTTPStatus(e.StatusInternalServerError)
+		}
+	}
+
+	obs.Logging.NewLogHandler(obs.Logging.LLHInfo()).
+		Msg(fmt.Sprintf("AdminSessionRevoke - %v session revoked", subject))
+
+	return nil
+}
+
+// AdminKeyRotate sign the new jwt with a new key, the previous
+// keys are kept to validate the jwt signed before the rotation
+func (a *AdminService) AdminKeyRotate(ctx context.Context) (string, e.IError) {
+	keyID, err := a.keys.KeyRingRotate(ctx)
+	if err != nil {
+		obs.Logging.NewLogHandler(obs.Logging.LLHError()).
+			Err(err).
+			Msg("AdminKeyRotate - rotate failed")
+		return "", e.NewCustomHTTPStatus(e.StatusInternalServerError)
+	}
+
+	obs.Logging.NewLogHandler(obs.Logging.LLHInfo()).
+		Msg(fmt.Sprintf("AdminKeyRotate - new key %v", keyID))
+
+	return keyID, nil
+}
+
+// AdminDebugToken mint a short lived jwt_token for a registered service,
+// the ttl is capped to debugTokenMaxTTL
+func (a *AdminService) AdminDebugToken(ctx context.Context, serviceID, scope string, ttl time.Duration) (*AdminDebugToken, e.IError) {
+	if _, err := a.clients.GetByID(ctx, serviceID); err != nil {
+		return nil, e.NewCustomHTTPStatus(e.StatusNotFound, "", "service not found")
+	}
+	if scope == "" {
+		scope = "read"
+	}
+	if ttl <= 0 {
+		ttl = adminDebugTokenTTLDefault
+	}
+	if ttl > a.debugTokenMaxTTL {
+		ttl = a.debugTokenMaxTTL
+	}
+
+	expiresAt := time.Now().Add(ttl).Unix()
+	claims := jwt.MapClaims{
+		"aud": serviceID,
+		"sub": serviceID,
+		"exp": expiresAt,
+		"openidInfo": map[string]interface{}{
+			"role":       "APIserver",
+			"scope":      scope,
+			"service_id": serviceID,
+			"tenant":     a.tenants.TenantOf(ctx, serviceID),
+			"debug":      "true",
+		},
+	}
+
+	keyID, secretKey := a.keys.KeyRingCurrent()
+	token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
+	token.Header["kid"] = keyID
+
+	signed, err := token.SignedString([]byte(secretKey))
+	if err != nil {
+		obs.Logging.NewLogHandler(obs.Logging.LLHError()).
+			Err(err).
+			Msg("AdminDebugToken - sign token failed")
+		return nil, e.NewCustomHTTPStatus(e.StatusInternalServerError)
+	}
+
+	obs.Logging.NewLogHandler(obs.Logging.LLHInfo()).
+		Msg(fmt.Sprintf("AdminDebugToken - token minted for %v, expires at %v", serviceID, expiresAt))
+
+	return &AdminDebugToken{Jwt: signed, KeyID: keyID, ExpiresAt: expiresAt}, nil
+}
+
+func newRandomString(n int) (string, error) {
+	b := make([]byte, n)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(b), nil
+}
@@ -0,0 +1,151 @@
+package services
+
+import (
+	"context"
+	"errors"
+	oauth2 "github.com/djedjethai/go-oauth2-openid"
+	"github.com/djedjethai/go-oauth2-openid/models"
+	authModels "gitlab.com/grpasr/asonrythme/auth_svc/internal/models"
+	"gitlab.com/grpasr/common/tests"
+	"net/http"
+	"sync"
+	"testing"
+	"time"
+)
+
+// clientStoreMock implement the IClientStore
+type clientStoreMock struct {
+	str map[string]oauth2.ClientInfo
+	sync.RWMutex
+}
+
+func newClientStoreMock() *clientStoreMock {
+	return &clientStoreMock{str: make(map[string]oauth2.ClientInfo)}
+}
+
+func (cs *clientStoreMock) Create(info oauth2.ClientInfo) error {
+	cs.Lock()
+	cs.str[info.GetID()] = info
+	cs.Unlock()
+	return nil
+}
+
+func (cs *clientStoreMock) GetByID(ctx context.Context, id string) (oauth2.ClientInfo, error) {
+	cs.RLock()
+	info, ok := cs.str[id]
+	cs.RUnlock()
+	if !ok {
+		return nil, errors.New("Not found")
+	}
+	return info, nil
+}
+
+func (cs *clientStoreMock) RemoveByID(id string) error {
+	cs.Lock()
+	delete(cs.str, id)
+	cs.Unlock()
+	return nil
+}
+
+func newTestAdminService() (IAdminService, *clientStoreMock, *KeyRing) {
+	cs := newClientStoreMock()
+	_ = cs.Create(&models.Client{
+		ID:     brokerSvcID,
+		Secret: brokerSvcSecret,
+		Domain: brokerSvcDomain,
+		UserID: "brokerSvc",
+	})
+	kr := NewKeyRing(keyID, secretKey)
+	return NewAdminService(srv, repos, cs, tenantPolicy, kr, 15*time.Minute, webhookSvc), cs, kr
+}
+
+func TestAdminClientRotateAndRevoke(t *testing.T) {
+	tests.MaybeFail = tests.InitFailFunc(t)
+
+	as, cs, _ := newTestAdminService()
+	ctx := context.Background()
+
+	client, ce := as.AdminClientCreate(ctx, orderID, orderDomain, "", "")
+	tests.MaybeFail("AdminClientCreate", ce,
+		tests.Expect(client.UserID, orderID),
+		tests.Expect(client.Secret != "", true))
+
+	_, ce = as.AdminClientCreate(ctx, orderID, orderDomain, "", "")
+	tests.MaybeFail("AdminClientCreate_exist", tests.Expect(ce.GetCode(), http.StatusBadRequest))
+
+	rotated, ce := as.AdminClientRotate(ctx, brokerSvcID)
+	info, _ := cs.GetByID(ctx, brokerSvcID)
+	tests.MaybeFail("AdminClientRotate", ce,
+		tests.Expect(rotated.Secret != brokerSvcSecret, true),
+		tests.Expect(info.GetSecret(), rotated.Secret),
+		tests.Expect(info.GetDomain(), brokerSvcDomain))
+
+	ce = as.AdminClientRevoke(ctx, brokerSvcID)
+	_, err := cs.GetByID(ctx, brokerSvcID)
+	tests.MaybeFail("AdminClientRevoke", ce, tests.Expect(err != nil, true))
+
+	_, ce = as.AdminClientRotate(ctx, brokerSvcID)
+	tests.MaybeFail("AdminClientRotate_unknown", tests.Expect(ce.GetCode(), http.StatusNotFound))
+}
+
+func TestAdminUserListAndDisable(t *testing.T) {
+	tests.MaybeFail = tests.InitFailFunc(t)
+
+	repos.UserReset()
+	defer repos.UserReset()
+
+	for _, email := range []string{"b@admin.com", "a@admin.com", "c@admin.com"} {
+		_ = repos.UserCreate(context.Background(), tenantvar, email, authModels.UserDatas{
+			Email:      email,
+			Role:       "user",
+			RefreshJWT: "refreshJWT",
+		})
+	}
+
+	as, _, _ := newTestAdminService()
+
+	users, ce := as.AdminUserList(context.Background(), 1, 1)
+	tests.MaybeFail("AdminUserList", ce,
+		tests.Expect(len(users), 1),
+		tests.Expect(users[0].Email, "b@admin.com"))
+
+	ce = as.AdminUserDisable(context.Background(), tenantvar, "a@admin.com")
+	ud, _ := repos.UserGetByEmail(context.Background(), tenantvar, "a@admin.com")
+	tests.MaybeFail("AdminUserDisable", ce,
+		tests.Expect(ud.IsDisabled, 1),
+		tests.Expect(ud.RefreshJWT, ""))
+
+	ce = as.AdminUserDisable(context.Background(), tenantvar, "unknown@admin.com")
+	tests.MaybeFail("AdminUserDisable_unknown", tests.Expect(ce.GetCode(), http.StatusNotFound))
+
+	ce = as.AdminSessionRevoke(context.Background(), tenantvar, "b@admin.com", "admin")
+	tests.MaybeFail("AdminSessionRevoke_role", tests.Expect(ce.GetCode(), http.StatusBadRequest))
+
+	ce = as.AdminUserDelete(context.Background(), tenantvar, "c@admin.com")
+	_, err := repos.UserGetByEmail(context.Background(), tenantvar, "c@admin.com")
+	tests.MaybeFail("AdminUserDelete", ce, tests.Expect(err != nil, true))
+
+	ce = as.AdminUserDelete(context.Background(), tenantvar, "c@admin.com")
+	tests.MaybeFail("AdminUserDelete_unknown", tests.Expect(ce.GetCode(), http.StatusNotFound))
+}
+
+func TestAdminDebugToken(t *testing.T) {
+	tests.MaybeFail = tests.InitFailFunc(t)
+
+	as, _, kr := newTestAdminService()
+	ctx := context.Background()
+
+	dt, ce := as.AdminDebugToken(ctx, brokerSvcID, "read", time.Hour)
+	tests.MaybeFail("AdminDebugToken", ce,
+		tests.Expect(dt.KeyID, keyID),
+		tests.Expect(dt.ExpiresAt <= time.Now().Add(15*time.Minute).Unix(), true))
+
+	infos, ce := NewJwtokenService(kr, tokenService).JwtokenValidate(ctx, dt.Jwt)
+	tests.MaybeFail("AdminDebugToken_validate", ce,
+		tests.Expect(infos["svc"], brokerSvcID),
+		tests.Expect(infos["role"], "APIserver"),
+		tests.Expect(infos["scope"], "read"))
+
+	_, ce = as.AdminDebugToken(ctx, "unknownSvc", "read", time.Minute)
+	tests.MaybeFail("AdminDebugToken_unknown", tests.Expect(ce.GetCode(), http.StatusNotFound))
+}
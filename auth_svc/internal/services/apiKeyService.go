@@ -0,0 +1,185 @@
+package services
+
+import (
+	"context"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/hex"
+	"fmt"
+	"gitlab.com/grpasr/asonrythme/auth_svc/internal/models"
+	"gitlab.com/grpasr/asonrythme/auth_svc/internal/repository"
+	e "gitlab.com/grpasr/common/errors/json"
+	obs "gitlab.com/grpasr/common/observability"
+	"regexp"
+	"strings"
+	"time"
+)
+
+// an api key is "ak_<id>.<secret>", the prefix make the leaked keys
+// easy to spot(logs, repositories...)
+const apiKeyPrefix = "ak_"
+
+// the last use is saved at most once per apiKeyTouchInterval, not on each request
+const apiKeyTouchInterval = time.Minute
+
+// a scope is a word as read, orders:write...
+var apiKeyScopeRegexp = regexp.MustCompile(`^[a-zA-Z0-9_.:-]{1,64}$`)
+
+type IAPIKeyService interface {
+	APIKeyCreate(ctx context.Context, name, tenant string, scopes []string, ttl time.Duration) (*APIKey, e.IError)
+	APIKeyList(ctx context.Context, tenant string) ([]models.APIKeyDatas, e.IError)
+	APIKeyRevoke(ctx context.Context, id string) e.IError
+	APIKeyValidate(ctx context.Context, key string) (*models.APIKeyDatas, e.IError)
+}
+
+// APIKey is a created key, the Key is only returned once
+type APIKey struct {
+	ID        string    `json:"id"`
+	Key       string    `json:"key"`
+	Name      string    `json:"name"`
+	Tenant    string    `json:"tenant"`
+	Scopes    []string  `json:"scopes"`
+	ExpiresAt time.Time `json:"expires_at"`
+}
+
+// APIKeyService manage the keys of the third party integrators, they
+// are hashed, scoped to a tenant and expire after at most maxTTL
+type APIKeyService struct {
+	repos  *repository.Repository
+	maxTTL time.Duration
+}
+
+func NewAPIKeyService(rp *repository.Repository, maxTTL time.Duration) IAPIKeyService {
+	return &APIKeyService{
+		repos:  rp,
+		maxTTL: maxTTL,
+	}
+}
+
+// APIKeyCreate create a key, a ttl <= 0 or above the max is set to the max
+func (a *APIKeyService) APIKeyCreate(ctx context.Context, name, tenant string, scopes []string, ttl time.Duration) (*APIKey, e.IError) {
+	if name == "" {
+		return nil, e.NewCustomHTTPStatus(e.StatusBadRequest, "auth/v1/admin/apikeys", "name missing")
+	}
+	tenant = tenantOrDefault(tenant)
+	if ce := TenantValidate(tenant); ce != nil {
+		return nil, ce
+	}
+	if len(scopes) == 0 {
+		return nil, e.NewCustomHTTPStatus(e.StatusBadRequest, "auth/v1/admin/apikeys", "scopes missing")
+	}
+	for _, scope := range scopes {
+		if !apiKeyScopeRegexp.MatchString(scope) {
+			return nil, e.NewCustomHTTPStatus(e.StatusBadRequest, "auth/v1/admin/apikeys", "invalid scope "+scope)
+		}
+	}
+	if ttl <= 0 || ttl > a.maxTTL {
+		ttl = a.maxTTL
+	}
+
+	id, err := newRandomString(12)
+	if err != nil {
+		return nil, e.NewCustomHTTPStatus(e.StatusInternalServerError)
+	}
+	secret, err := newRandomString(32)
+	if err != nil {
+		return nil, e.NewCustomHTTPStatus(e.StatusInternalServerError)
+	}
+
+	kd := models.APIKeyDatas{
+		ID:        id,
+		Name:      name,
+		Tenant:    tenant,
+		Scopes:    scopes,
+		Hash:      apiKeyHash(secret),
+		ExpiresAT: time.Now().Add(ttl),
+	}
+	if err := a.repos.APIKeyCreate(ctx, kd); err != nil {
+		return nil, e.NewCustomHTTPStatus(e.StatusInternalServerError)
+	}
+
+	obs.Logging.NewLogHandler(obs.Logging.LLHInfo()).
+		Msg(fmt.Sprintf("APIKeyCreate - key %v created for %v, expires at %v", id, name, kd.ExpiresAT))
+
+	return &APIKey{
+		ID:        id,
+		Key:       apiKeyPrefix + id + "." + secret,
+		Name:      name,
+		Tenant:    tenant,
+		Scopes:    scopes,
+		ExpiresAt: kd.ExpiresAT,
+	}, nil
+}
+
+// APIKeyList return the keys of the tenant(all of them if empty), without their hash
+func (a *APIKeyService) APIKeyList(ctx context.Context, tenant string) ([]models.APIKeyDatas, e.IError) {
+	keys, err := a.repos.APIKeyList(ctx, tenant)
+	if err != nil {
+		return nil, e.NewCustomHTTPStatus(e.StatusInternalServerError)
+	}
+	for i := range keys {
+		keys[i].Hash = ""
+	}
+	return keys, nil
+}
+
+func (a *APIKeyService) APIKeyRevoke(ctx context.Context, id string) e.IError {
+	if id == "" {
+		return e.NewCustomHTTPStatus(e.StatusBadRequest, "auth/v1/admin/apikeys", "id missing")
+	}
+	if err := a.repos.APIKeyRevoke(ctx, id); err != nil {
+		return e.NewCustomHTTPStatus(e.StatusNotFound)
+	}
+
+	obs.Logging.NewLogHandler(obs.Logging.LLHInfo()).
+		Msg(fmt.Sprintf("APIKeyRevoke - key %v revoked", id))
+
+	return nil
+}
+
+// APIKeyValidate return the key's datas(without hash) if the key is valid,
+// an expired key is Unauthorized, an unknown or revoked one Forbidden
+func (a *APIKeyService) APIKeyValidate(ctx context.Context, key string) (*models.APIKeyDatas, e.IError) {
+	id, secret, ok := strings.Cut(strings.TrimPrefix(key, apiKeyPrefix), ".")
+	if !ok || !strings.HasPrefix(key, apiKeyPrefix) || id == "" || secret == "" {
+		return nil, e.NewCustomHTTPStatus(e.StatusForbidden)
+	}
+
+	kd, err := a.repos.APIKeyGetByID(ctx, id)
+	if err != nil {
+		return nil, e.NewCustomHTTPStatus(e.StatusForbidden)
+	}
+
+	if subtle.ConstantTimeCompare([]byte(apiKeyHash(secret)), []byte(kd.Hash)) != 1 {
+		obs.Logging.NewLogHandler(obs.Logging.LLHError()).
+			Msg(fmt.Sprintf("APIKeyValidate - invalid secret for key %v", id))
+		return nil, e.NewCustomHTTPStatus(e.StatusForbidden)
+	}
+	if kd.IsRevoked == 1 {
+		return nil, e.NewCustomHTTPStatus(e.StatusForbidden)
+	}
+
+	now := time.Now()
+	if !kd.ExpiresAT.After(now) {
+		return nil, e.NewCustomHTTPStatus(e.StatusUnauthorized)
+	}
+
+	if now.Sub(kd.LastUsedAT) >= apiKeyTouchInterval {
+		if err := a.repos.APIKeyTouch(ctx, id, now); err != nil {
+			obs.Logging.NewLogHandler(obs.Logging.LLHError()).
+				Err(err).
+				Msg(fmt.Sprintf("APIKeyValidate - save %v last use failed", id))
+		}
+		kd.LastUsedAT = now
+	}
+
+	kd.Hash = ""
+	return &kd, nil
+}
+
+// apiKeyHash is the hex(sha256) of the secret, the secrets are random
+// so they do not need a slow hash
+func apiKeyHash(secret string) string {
+	sum := sha256.Sum256([]byte(secret))
+	return hex.EncodeToString(sum[:])
+}
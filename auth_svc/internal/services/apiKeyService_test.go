@@ -0,0 +1,89 @@
+package services
+
+import (
+	"context"
+	"gitlab.com/grpasr/asonrythme/auth_svc/internal/repository"
+	"gitlab.com/grpasr/common/tests"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+)
+
+func newTestAPIKeyService() (IAPIKeyService, *repository.Repository) {
+	rp := &repository.Repository{IAPIKeyStore: repository.NewAPIKeyStoreMock()}
+	return NewAPIKeyService(rp, 24*time.Hour), rp
+}
+
+func TestAPIKeyCreateAndValidate(t *testing.T) {
+	tests.MaybeFail = tests.InitFailFunc(t)
+
+	as, rp := newTestAPIKeyService()
+	ctx := context.Background()
+
+	key, ce := as.APIKeyCreate(ctx, "partner", "acme", []string{"orders:read"}, 48*time.Hour)
+	tests.MaybeFail("APIKeyCreate", ce,
+		tests.Expect(strings.HasPrefix(key.Key, apiKeyPrefix+key.ID+"."), true),
+		tests.Expect(key.Tenant, "acme"),
+		tests.Expect(key.ExpiresAt.Before(time.Now().Add(25*time.Hour)), true))
+
+	// only the hash of the secret is saved
+	kd, _ := rp.APIKeyGetByID(ctx, key.ID)
+	tests.MaybeFail("APIKeyCreate_hash",
+		tests.Expect(kd.Hash != "", true),
+		tests.Expect(strings.Contains(key.Key, kd.Hash), false))
+
+	valid, ce := as.APIKeyValidate(ctx, key.Key)
+	tests.MaybeFail("APIKeyValidate", ce,
+		tests.Expect(valid.ID, key.ID),
+		tests.Expect(valid.Tenant, "acme"),
+		tests.Expect(valid.Scopes[0], "orders:read"),
+		tests.Expect(valid.Hash, ""))
+
+	kd, _ = rp.APIKeyGetByID(ctx, key.ID)
+	tests.MaybeFail("APIKeyValidate_lastUsed", tests.Expect(kd.LastUsedAT.IsZero(), false))
+
+	_, ce = as.APIKeyValidate(ctx, key.Key+"x")
+	tests.MaybeFail("APIKeyValidate_secret", tests.Expect(ce.GetCode(), http.StatusForbidden))
+
+	_, ce = as.APIKeyValidate(ctx, "notAKey")
+	tests.MaybeFail("APIKeyValidate_format", tests.Expect(ce.GetCode(), http.StatusForbidden))
+
+	_, ce = as.APIKeyCreate(ctx, "partner", "acme", nil, 0)
+	tests.MaybeFail("APIKeyCreate_scopes", tests.Expect(ce.GetCode(), http.StatusBadRequest))
+
+	_, ce = as.APIKeyCreate(ctx, "partner", "Acme#1", []string{"read"}, 0)
+	tests.MaybeFail("APIKeyCreate_tenant", tests.Expect(ce.GetCode(), http.StatusBadRequest))
+}
+
+func TestAPIKeyRevokeAndExpire(t *testing.T) {
+	tests.MaybeFail = tests.InitFailFunc(t)
+
+	as, rp := newTestAPIKeyService()
+	ctx := context.Background()
+
+	key, _ := as.APIKeyCreate(ctx, "partner", "", []string{"read"}, 0)
+	other, _ := as.APIKeyCreate(ctx, "other", "acme", []string{"read"}, 0)
+
+	keys, ce := as.APIKeyList(ctx, "default")
+	tests.MaybeFail("APIKeyList", ce,
+		tests.Expect(len(keys), 1),
+		tests.Expect(keys[0].ID, key.ID),
+		tests.Expect(keys[0].Hash, ""))
+
+	ce = as.APIKeyRevoke(ctx, key.ID)
+	_, vce := as.APIKeyValidate(ctx, key.Key)
+	tests.MaybeFail("APIKeyRevoke", ce, tests.Expect(vce.GetCode(), http.StatusForbidden))
+
+	ce = as.APIKeyRevoke(ctx, "unknown")
+	tests.MaybeFail("APIKeyRevoke_unknown", tests.Expect(ce.GetCode(), http.StatusNotFound))
+
+	// expire the other key
+	kd, _ := rp.APIKeyGetByID(ctx, other.ID)
+	kd.ID = "expired"
+	kd.ExpiresAT = time.Now().Add(-time.Minute)
+	_ = rp.APIKeyCreate(ctx, kd)
+	_, secret, _ := strings.Cut(other.Key, ".")
+	_, ce = as.APIKeyValidate(ctx, apiKeyPrefix+"expired."+secret)
+	tests.MaybeFail("APIKeyValidate_expired", tests.Expect(ce.GetCode(), http.StatusUnauthorized))
+}
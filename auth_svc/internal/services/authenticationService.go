@@ -1,7 +1,7 @@
 package services
 
 import (
-	"context"
+	"crypto/subtle"
 	"fmt"
 	"github.com/djedjethai/go-oauth2-openid/server"
 	"gitlab.com/grpasr/asonrythme/auth_svc/internal/models"
@@ -9,6 +9,7 @@ import (
 	e "gitlab.com/grpasr/common/errors/json"
 	obs "gitlab.com/grpasr/common/observability"
 	"net/http"
+	"time"
 )
 
 // TODO redis must be set as LRU,
@@ -28,16 +29,33 @@ type IAuthenticationService interface {
 	SignupService(w http.ResponseWriter, r *http.Request) e.IError
 	SigninService(w http.ResponseWriter, r *http.Request) e.IError
 	ApiAuthService(w http.ResponseWriter, r *http.Request) e.IError
+	VerifyEmailService(r *http.Request) e.IError
 }
 
 type AuthenticationService struct {
 	// repository
-	srv   *server.Server
-	repos *repository.Repository
+	srv         *server.Server
+	repos       *repository.Repository
+	pkce        *PKCEPolicy
+	signup      *SignupPolicy
+	invitations IInvitationService
+	tenants     *TenantPolicy
+	keys        IKeyRing
+	webhooks    IWebhookPublisher
 }
 
-func NewAuthenticationService(srv *server.Server, r *repository.Repository) IAuthenticationService {
-	return &AuthenticationService{srv, r}
+func NewAuthenticationService(srv *server.Server, r *repository.Repository, p *PKCEPolicy, sp *SignupPolicy, inv IInvitationService, t *TenantPolicy, k IKeyRing, wh IWebhookPublisher) IAuthenticationService {
+	return &AuthenticationService{srv, r, p, sp, inv, t, k, wh}
+}
+
+// validatePKCE make sure the authorization request carry a code_challenge
+// allowed by the client's policy
+func (a *AuthenticationService) validatePKCE(r *http.Request, path string) e.IError {
+	return a.pkce.PKCEValidate(
+		r.Form.Get("client_id"),
+		r.Form.Get("code_challenge"),
+		r.Form.Get("code_challenge_method"),
+		path)
 }
 
 func (a *AuthenticationService) AuthorizeService(w http.ResponseWriter, r *http.Request) error {
@@ -51,6 +69,11 @@ func (a *AuthenticationService) ApiAuthService(w http.ResponseWriter, r *http.Re
 	obs.Logging.NewLogHandler(obs.Logging.LLHDebug()).
 		Msg("ApiAuthService - hit handler")
 
+	ctx, span := obs.Tracing.SPNGetFromCTX(r.Context(), "authSvc_apiAuth",
+		obs.Tracing.TAString("function", "ApiAuthService"))
+	defer span.End()
+	r = r.WithContext(ctx)
+
 	if r.Form == nil {
 		if err := r.ParseForm(); err != nil {
 			obs.Logging.NewLogHandler(obs.Logging.LLHError()).
@@ -60,6 +83,10 @@ func (a *AuthenticationService) ApiAuthService(w http.ResponseWriter, r *http.Re
 		}
 	}
 
+	if ce := a.validatePKCE(r, "auth/v1/apiauth"); ce != nil {
+		return ce
+	}
+
 	clientID := r.Form.Get("client_id")
 	if clientID == "" {
 		obs.Logging.NewLogHandler(obs.Logging.LLHError()).
@@ -73,11 +100,12 @@ func (a *AuthenticationService) ApiAuthService(w http.ResponseWriter, r *http.Re
 		// save in db
 		svcData := models.APIserverRedisDatas{
 			// ServiceID: clid, // will be add with the deserializeAPIServer func
-			Path: "apiauth", // usefull ??
+			Tenant: a.tenants.TenantOf(ctx, clientID),
+			Path:   "apiauth", // usefull ??
 		}
 
 		// save all data present in the form, like serviceID(client_id)
-		err := a.repos.RedisAPIserverSet(clid, svcData)
+		err := a.repos.RedisAPIserverSet(ctx, clid, svcData)
 		if err != nil {
 			obs.Logging.NewLogHandler(obs.Logging.LLHError()).
 				Err(err).
@@ -114,15 +142,24 @@ func (a *AuthenticationService) SignoutService(r *http.Request, cookie *http.Coo
 	obs.Logging.NewLogHandler(obs.Logging.LLHDebug()).
 		Msg("SignoutService - hit handler")
 
+	ctx, span := obs.Tracing.SPNGetFromCTX(r.Context(), "authSvc_signout",
+		obs.Tracing.TAString("function", "SignoutService"))
+	defer span.End()
+
 	jwt := cookie.Value
 
 	// validate the token
-	keyID := "theKeyID"
-	secretKey := "mySecretKey"
+	keyID, secretKey, err := a.keys.KeyRingForToken(jwt)
+	if err != nil {
+		obs.Logging.NewLogHandler(obs.Logging.LLHError()).
+			Err(err).
+			Msg("SignoutService - no key for the jwt")
+		return e.NewCustomHTTPStatus(e.StatusUnauthorized)
+	}
 	encoding := "HS256"
 
 	// make sure the user is authenticated
-	usrData, err := a.srv.HandleJWTokenGetdata(context.TODO(), r, jwt, keyID, secretKey, encoding)
+	usrData, err := a.srv.HandleJWTokenGetdata(ctx, r, jwt, keyID, secretKey, encoding)
 	if err != nil {
 		obs.Logging.NewLogHandler(obs.Logging.LLHError()).
 			Err(err).
@@ -137,8 +174,8 @@ func (a *AuthenticationService) SignoutService(r *http.Request, cookie *http.Coo
 		return e.NewCustomHTTPStatus(e.StatusForbidden)
 	}
 
-	// get user refreshToken from user DB
-	userDatas, err := a.repos.UserGetByEmail(userEmail.(string))
+	// get user refreshToken from user DB, within the token's tenant
+	userDatas, err := a.repos.UserGetByEmail(ctx, tenantFromClaims(usrData), userEmail.(string))
 	if err != nil {
 		obs.Logging.NewLogHandler(obs.Logging.LLHError()).
 			Err(err).
@@ -147,7 +184,7 @@ func (a *AuthenticationService) SignoutService(r *http.Request, cookie *http.Coo
 	}
 
 	// Delete all tokens using the refreshToken(I could use the access token as well)
-	err = a.srv.Manager.RemoveAllTokensByRefreshToken(context.Background(), userDatas.RefreshTK)
+	err = a.srv.Manager.RemoveAllTokensByRefreshToken(ctx, userDatas.RefreshTK)
 	if err != nil {
 		obs.Logging.NewLogHandler(obs.Logging.LLHError()).
 			Err(err).
@@ -163,10 +200,22 @@ func (a *AuthenticationService) SignoutService(r *http.Request, cookie *http.Coo
 }
 
 // SignupService is the signup endpoint for the users
-func (a *AuthenticationService) SignupService(w http.ResponseWriter, r *http.Request) e.IError {
+func (a *AuthenticationService) SignupService(w http.ResponseWriter, r *http.Request) (ce e.IError) {
 	obs.Logging.NewLogHandler(obs.Logging.LLHDebug()).
 		Msg("SignupService - hit handler")
 
+	ctx, span := obs.Tracing.SPNGetFromCTX(r.Context(), "authSvc_signup",
+		obs.Tracing.TAString("function", "SignupService"))
+	defer span.End()
+	r = r.WithContext(ctx)
+
+	// the authorize request answer on w, its status is the outcome
+	sw := &statusWriter{ResponseWriter: w}
+	w = sw
+	defer func(start time.Time) {
+		metrics.recordSignup(ctx, start, sw.result(ce))
+	}(time.Now())
+
 	if r.Form == nil {
 		if err := r.ParseForm(); err != nil {
 			obs.Logging.NewLogHandler(obs.Logging.LLHError()).
@@ -176,6 +225,10 @@ func (a *AuthenticationService) SignupService(w http.ResponseWriter, r *http.Req
 		}
 	}
 
+	if ce := a.validatePKCE(r, "auth/v1/signup"); ce != nil {
+		return ce
+	}
+
 	email := r.Form.Get("email")
 	password := r.Form.Get("password")
 
@@ -185,21 +238,45 @@ func (a *AuthenticationService) SignupService(w http.ResponseWriter, r *http.Req
 			Msg("SignupService - email or password missing")
 		return e.NewCustomHTTPStatus(e.StatusForbidden)
 	} else {
+		// the password and email rules, the violations are returned per field
+		if ce := a.signup.SignupValidate(email, password, "auth/v1/signup"); ce != nil {
+			return ce
+		}
 
-		// make sure the email does not already exist in db
-		err := a.repos.UserIsEmailExist(email)
+		tenant := a.tenants.TenantOf(ctx, r.Form.Get("client_id"))
+
+		// make sure the email does not already exist in the tenant
+		err := a.repos.UserIsEmailExist(ctx, tenant, email)
 		if err != nil {
 			return err.(e.IError)
 		}
 
 		user := models.UserRedisDatas{
+			Tenant:   tenant,
 			Password: password,
 			Path:     "signup",
 		}
 
+		// a closed signup require an invitation, which set the user's role.
+		// It is used once the code is exchanged, see UserOpenidService
+		inviteToken := r.Form.Get("invite_token")
+		if inviteToken == "" && !a.signup.SignupIsOpen() {
+			obs.Logging.NewLogHandler(obs.Logging.LLHError()).
+				Msg(fmt.Sprintf("SignupService - %v has no invitation, the signup is closed", email))
+			return e.NewCustomHTTPStatus(e.StatusForbidden, "auth/v1/signup", "an invitation is required")
+		}
+		if inviteToken != "" {
+			inv, ce := a.invitations.InvitationVerify(ctx, inviteToken, tenant, email)
+			if ce != nil {
+				return ce
+			}
+			user.TenantRole = inv.Role
+			user.InvitationID = inv.ID
+		}
+
 		// save to redis/cache, as we are not sure the jwt will be deliver
 		// save all data present in the form, like password(hash), email
-		err = a.repos.RedisUserSet(email, user)
+		err = a.repos.RedisUserSet(ctx, models.TenantKey(tenant, email), user)
 		if err != nil {
 			obs.Logging.NewLogHandler(obs.Logging.LLHError()).
 				Err(err).
@@ -208,7 +285,7 @@ func (a *AuthenticationService) SignupService(w http.ResponseWriter, r *http.Req
 		}
 
 		// save user in a temporary store for the user to be reconized later on
-		key := fmt.Sprintf("LoggedInUserID-%v", email)
+		key := fmt.Sprintf("LoggedInUserID-%v", models.TenantKey(tenant, email))
 		err = a.repos.TemporarySet(key, email)
 		if err != nil {
 			obs.Logging.NewLogHandler(obs.Logging.LLHError()).
@@ -226,10 +303,22 @@ func (a *AuthenticationService) SignupService(w http.ResponseWriter, r *http.Req
 }
 
 // SigninService is the signin endpoint for the users
-func (a *AuthenticationService) SigninService(w http.ResponseWriter, r *http.Request) e.IError {
+func (a *AuthenticationService) SigninService(w http.ResponseWriter, r *http.Request) (ce e.IError) {
 	obs.Logging.NewLogHandler(obs.Logging.LLHDebug()).
 		Msg("SigninService - hit handler")
 
+	ctx, span := obs.Tracing.SPNGetFromCTX(r.Context(), "authSvc_signin",
+		obs.Tracing.TAString("function", "SigninService"))
+	defer span.End()
+	r = r.WithContext(ctx)
+
+	// the authorize request answer on w, its status is the outcome
+	sw := &statusWriter{ResponseWriter: w}
+	w = sw
+	defer func(start time.Time) {
+		metrics.recordSignin(ctx, start, sw.result(ce))
+	}(time.Now())
+
 	if r.Form == nil {
 		if err := r.ParseForm(); err != nil {
 			obs.Logging.NewLogHandler(obs.Logging.LLHError()).
@@ -240,6 +329,10 @@ func (a *AuthenticationService) SigninService(w http.ResponseWriter, r *http.Req
 		}
 	}
 
+	if ce := a.validatePKCE(r, "auth/v1/signin"); ce != nil {
+		return ce
+	}
+
 	email := r.Form.Get("email")
 	password := r.Form.Get("password")
 
@@ -249,15 +342,17 @@ func (a *AuthenticationService) SigninService(w http.ResponseWriter, r *http.Req
 
 		return e.NewCustomHTTPStatus(e.StatusForbidden)
 	} else {
+		tenant := a.tenants.TenantOf(ctx, r.Form.Get("client_id"))
 
 		user := models.UserRedisDatas{
+			Tenant:   tenant,
 			Password: password,
 			Path:     "signin",
 		}
 
 		// save to redis/cache, as we are not sure the jwt will be deliver
 		// save all data present in the form, like password(hash), email
-		err := a.repos.RedisUserSet(email, user)
+		err := a.repos.RedisUserSet(ctx, models.TenantKey(tenant, email), user)
 		if err != nil {
 			obs.Logging.NewLogHandler(obs.Logging.LLHError()).
 				Err(err).
@@ -266,7 +361,7 @@ func (a *AuthenticationService) SigninService(w http.ResponseWriter, r *http.Req
 		}
 
 		// save user in a temporary store for the user to be reconized later on
-		key := fmt.Sprintf("LoggedInUserID-%v", email)
+		key := fmt.Sprintf("LoggedInUserID-%v", models.TenantKey(tenant, email))
 		err = a.repos.TemporarySet(key, email)
 		if err != nil {
 			obs.Logging.NewLogHandler(obs.Logging.LLHError()).
@@ -283,3 +378,53 @@ func (a *AuthenticationService) SigninService(w http.ResponseWriter, r *http.Req
 	_ = a.AuthorizeService(w, r)
 	return nil
 }
+
+// VerifyEmailService validate the user's email with the code generated at signup,
+// the user is looked up within the tenant of the client_id
+func (a *AuthenticationService) VerifyEmailService(r *http.Request) e.IError {
+	obs.Logging.NewLogHandler(obs.Logging.LLHDebug()).
+		Msg("VerifyEmailService - hit handler")
+
+	ctx, span := obs.Tracing.SPNGetFromCTX(r.Context(), "authSvc_verifyEmail",
+		obs.Tracing.TAString("function", "VerifyEmailService"))
+	defer span.End()
+
+	email := r.FormValue("email")
+	code := r.FormValue("code")
+	if len(email) < 1 || len(code) < 1 {
+		obs.Logging.NewLogHandler(obs.Logging.LLHError()).
+			Msg("VerifyEmailService - email or code missing")
+		return e.NewCustomHTTPStatus(e.StatusBadRequest)
+	}
+
+	tenant := a.tenants.TenantOf(ctx, r.FormValue("client_id"))
+
+	user, err := a.repos.UserGetByEmail(ctx, tenant, email)
+	if err != nil {
+		return e.NewCustomHTTPStatus(e.StatusForbidden, "auth/v1/verifyemail", "invalid code")
+	}
+	if user.IsEmailValidated == 1 {
+		return e.NewCustomHTTPStatus(e.StatusBadRequest, "auth/v1/verifyemail", "email already validated")
+	}
+	if subtle.ConstantTimeCompare([]byte(code), []byte(user.EmailValidationCode)) != 1 {
+		obs.Logging.NewLogHandler(obs.Logging.LLHError()).
+			Msg(fmt.Sprintf("VerifyEmailService - invalid code for %v", email))
+		return e.NewCustomHTTPStatus(e.StatusForbidden, "auth/v1/verifyemail", "invalid code")
+	}
+
+	user.IsEmailValidated = 1
+	user.EmailValidationCode = ""
+	if err := a.repos.UserUpdate(ctx, tenant, email, user); err != nil {
+		obs.Logging.NewLogHandler(obs.Logging.LLHError()).
+			Err(err).
+			Msg(fmt.Sprintf("VerifyEmailService - update %v failed", email))
+		return e.NewCustomHTTPStatus(e.StatusInternalServerError)
+	}
+
+	a.webhooks.WebhookPublish(WebhookEventUserEmailVerified, map[string]string{"email": email, "tenant": tenant})
+
+	obs.Logging.NewLogHandler(obs.Logging.LLHDebug()).
+		Msg("VerifyEmailService - exit successfully")
+
+	return nil
+}
@@ -1,9 +1,11 @@
 package services
 
 import (
+	"context"
 	"encoding/base64"
 	"encoding/json"
 	"fmt"
+	"gitlab.com/grpasr/asonrythme/auth_svc/internal/models"
 	e "gitlab.com/grpasr/common/errors/json"
 	"gitlab.com/grpasr/common/tests"
 	"io/ioutil"
@@ -649,3 +651,39 @@ func TestApiAuthServiceWithInvalidClientID(t *testing.T) {
 // 	repos.UsersStr.RUnlock()
 // 	tests.MaybeFail("AuthenticationService_test_logout", tests.Expect(storeSizeAftLogout, 1))
 // }
+
+func TestVerifyEmail(t *testing.T) {
+	tests.MaybeFail = tests.InitFailFunc(t)
+
+	const email = "verify@example.com"
+	_ = repos.UserCreate(context.Background(), tenantvar, email, models.UserDatas{
+		Email:               email,
+		Role:                "user",
+		EmailValidationCode: "123456",
+	})
+	defer repos.UserDelete(context.Background(), tenantvar, email)
+
+	newVerifyEmailRequest := func(code string) *http.Request {
+		formValues := url.Values{}
+		formValues.Set("email", email)
+		formValues.Set("code", code)
+		req, err := http.NewRequest("POST", "/verifyemail", strings.NewReader(formValues.Encode()))
+		if err != nil {
+			t.Fatal("error making POST request: ", err)
+		}
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		return req
+	}
+
+	ce := authService.VerifyEmailService(newVerifyEmailRequest("654321"))
+	tests.MaybeFail("VerifyEmailService_invalid_code", tests.Expect(ce.GetCode(), http.StatusForbidden))
+
+	ce = authService.VerifyEmailService(newVerifyEmailRequest("123456"))
+	user, _ := repos.UserGetByEmail(context.Background(), tenantvar, email)
+	tests.MaybeFail("VerifyEmailService", ce,
+		tests.Expect(user.IsEmailValidated, 1),
+		tests.Expect(user.EmailValidationCode, ""))
+
+	ce = authService.VerifyEmailService(newVerifyEmailRequest("123456"))
+	tests.MaybeFail("VerifyEmailService_already_validated", tests.Expect(ce.GetCode(), http.StatusBadRequest))
+}
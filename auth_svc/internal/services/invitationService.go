@@ -0,0 +1,184 @@
+package services
+
+import (
+	"context"
+	"crypto/subtle"
+	"fmt"
+	"gitlab.com/grpasr/asonrythme/auth_svc/internal/models"
+	"gitlab.com/grpasr/asonrythme/auth_svc/internal/repository"
+	e "gitlab.com/grpasr/common/errors/json"
+	obs "gitlab.com/grpasr/common/observability"
+	"regexp"
+	"strings"
+	"time"
+)
+
+// an invitation token is "inv_<id>.<secret>"
+const invitationPrefix = "inv_"
+
+// the role of the invited users if the invitation does not set one
+const invitationRoleDefault = "member"
+
+// a role is a word as member, billing:admin...
+var invitationRoleRegexp = regexp.MustCompile(`^[a-zA-Z0-9_.:-]{1,64}$`)
+
+type IInvitationService interface {
+	InvitationCreate(ctx context.Context, tenant, email, role, inviter string) (*Invitation, e.IError)
+	InvitationList(ctx context.Context, tenant string) ([]models.InvitationDatas, e.IError)
+	InvitationRevoke(ctx context.Context, id string) e.IError
+	InvitationVerify(ctx context.Context, token, tenant, email string) (*models.InvitationDatas, e.IError)
+	InvitationConsume(ctx context.Context, token, tenant, email string) (*models.InvitationDatas, e.IError)
+}
+
+// Invitation is a created invitation, the Token is only returned once
+type Invitation struct {
+	ID        string    `json:"id"`
+	Token     string    `json:"token"`
+	Tenant    string    `json:"tenant"`
+	Email     string    `json:"email"`
+	Role      string    `json:"role"`
+	Inviter   string    `json:"inviter"`
+	ExpiresAt time.Time `json:"expires_at"`
+}
+
+// InvitationService manage the invitations of the closed registrations,
+// an invitation let one email signup in a tenant, once, before ttl
+type InvitationService struct {
+	repos *repository.Repository
+	ttl   time.Duration
+}
+
+func NewInvitationService(rp *repository.Repository, ttl time.Duration) IInvitationService {
+	return &InvitationService{
+		repos: rp,
+		ttl:   ttl,
+	}
+}
+
+func (a *InvitationService) InvitationCreate(ctx context.Context, tenant, email, role, inviter string) (*Invitation, e.IError) {
+	if email == "" || !emailRegexp.MatchString(strings.ToLower(email)) {
+		return nil, e.NewCustomHTTPStatus(e.StatusBadRequest, "auth/v1/admin/invitations", "invalid email")
+	}
+	if inviter == "" {
+		return nil, e.NewCustomHTTPStatus(e.StatusBadRequest, "auth/v1/admin/invitations", "inviter missing")
+	}
+	tenant = tenantOrDefault(tenant)
+	if ce := TenantValidate(tenant); ce != nil {
+		return nil, ce
+	}
+	if role == "" {
+		role = invitationRoleDefault
+	}
+	if !invitationRoleRegexp.MatchString(role) {
+		return nil, e.NewCustomHTTPStatus(e.StatusBadRequest, "auth/v1/admin/invitations", "invalid role "+role)
+	}
+
+	id, err := newRandomString(12)
+	if err != nil {
+		return nil, e.NewCustomHTTPStatus(e.StatusInternalServerError)
+	}
+	secret, err := newRandomString(32)
+	if err != nil {
+		return nil, e.NewCustomHTTPStatus(e.StatusInternalServerError)
+	}
+
+	inv := models.InvitationDatas{
+		ID:        id,
+		Tenant:    tenant,
+		Email:     strings.ToLower(email),
+		Role:      role,
+		Inviter:   inviter,
+		Hash:      apiKeyHash(secret),
+		ExpiresAT: time.Now().Add(a.ttl),
+	}
+	if err := a.repos.InvitationCreate(ctx, inv); err != nil {
+		return nil, e.NewCustomHTTPStatus(e.StatusInternalServerError)
+	}
+
+	obs.Logging.NewLogHandler(obs.Logging.LLHInfo()).
+		Msg(fmt.Sprintf("InvitationCreate - %v invited %v in %v as %v", inviter, inv.Email, tenant, role))
+
+	return &Invitation{
+		ID:        id,
+		Token:     invitationPrefix + id + "." + secret,
+		Tenant:    tenant,
+		Email:     inv.Email,
+		Role:      role,
+		Inviter:   inviter,
+		ExpiresAt: inv.ExpiresAT,
+	}, nil
+}
+
+// InvitationList return the invitations of the tenant(all of them if empty), without their hash
+func (a *InvitationService) InvitationList(ctx context.Context, tenant string) ([]models.InvitationDatas, e.IError) {
+	invs, err := a.repos.InvitationList(ctx, tenant)
+	if err != nil {
+		return nil, e.NewCustomHTTPStatus(e.StatusInternalServerError)
+	}
+	for i := range invs {
+		invs[i].Hash = ""
+	}
+	return invs, nil
+}
+
+func (a *InvitationService) InvitationRevoke(ctx context.Context, id string) e.IError {
+	if id == "" {
+		return e.NewCustomHTTPStatus(e.StatusBadRequest, "auth/v1/admin/invitations", "id missing")
+	}
+	if err := a.repos.InvitationRevoke(ctx, id); err != nil {
+		return e.NewCustomHTTPStatus(e.StatusNotFound)
+	}
+
+	obs.Logging.NewLogHandler(obs.Logging.LLHInfo()).
+		Msg(fmt.Sprintf("InvitationRevoke - invitation %v revoked", id))
+
+	return nil
+}
+
+// InvitationVerify check the invitation of token for the signup of email in
+// tenant without using it, an invitation is Forbidden if it was issued for
+// another email or tenant, or if it is unknown, used, revoked or expired
+func (a *InvitationService) InvitationVerify(ctx context.Context, token, tenant, email string) (*models.InvitationDatas, e.IError) {
+	id, secret, ok := strings.Cut(strings.TrimPrefix(token, invitationPrefix), ".")
+	if !ok || !strings.HasPrefix(token, invitationPrefix) || id == "" || secret == "" {
+		return nil, e.NewCustomHTTPStatus(e.StatusForbidden, "auth/v1/signup", "invalid invitation")
+	}
+
+	inv, err := a.repos.InvitationGetByID(ctx, id)
+	if err != nil {
+		return nil, e.NewCustomHTTPStatus(e.StatusForbidden, "auth/v1/signup", "invalid invitation")
+	}
+	if subtle.ConstantTimeCompare([]byte(apiKeyHash(secret)), []byte(inv.Hash)) != 1 ||
+		inv.Tenant != tenant || inv.Email != strings.ToLower(email) {
+		obs.Logging.NewLogHandler(obs.Logging.LLHError()).
+			Msg(fmt.Sprintf("InvitationVerify - invitation %v does not match the signup of %v", id, email))
+		return nil, e.NewCustomHTTPStatus(e.StatusForbidden, "auth/v1/signup", "invalid invitation")
+	}
+	if inv.IsUsed == 1 || inv.IsRevoked == 1 || !inv.ExpiresAT.After(time.Now()) {
+		return nil, e.NewCustomHTTPStatus(e.StatusForbidden, "auth/v1/signup", "invitation used, revoked or expired")
+	}
+
+	inv.Hash = ""
+	return &inv, nil
+}
+
+// InvitationConsume verify the invitation of token and use it, see
+// InvitationVerify
+func (a *InvitationService) InvitationConsume(ctx context.Context, token, tenant, email string) (*models.InvitationDatas, e.IError) {
+	verified, ce := a.InvitationVerify(ctx, token, tenant, email)
+	if ce != nil {
+		return nil, ce
+	}
+
+	// mark it used, the store make sure it is only once
+	inv, err := a.repos.InvitationConsume(ctx, verified.ID)
+	if err != nil {
+		return nil, e.NewCustomHTTPStatus(e.StatusForbidden, "auth/v1/signup", "invitation used, revoked or expired")
+	}
+
+	obs.Logging.NewLogHandler(obs.Logging.LLHInfo()).
+		Msg(fmt.Sprintf("InvitationConsume - invitation %v used by %v", inv.ID, email))
+
+	inv.Hash = ""
+	return &inv, nil
+}
@@ -0,0 +1,168 @@
+package services
+
+import (
+	"context"
+	"gitlab.com/grpasr/asonrythme/auth_svc/internal/models"
+	"gitlab.com/grpasr/asonrythme/auth_svc/internal/repository"
+	"gitlab.com/grpasr/common/tests"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestInvitationCreateAndConsume(t *testing.T) {
+	tests.MaybeFail = tests.InitFailFunc(t)
+
+	rp := &repository.Repository{IInvitationStore: repository.NewInvitationStoreMock()}
+	is := NewInvitationService(rp, time.Hour)
+	ctx := context.Background()
+
+	inv, ce := is.InvitationCreate(ctx, "acme", "Jean@Example.com", "billing:admin", "admin@acme.com")
+	tests.MaybeFail("InvitationCreate", ce,
+		tests.Expect(strings.HasPrefix(inv.Token, invitationPrefix+inv.ID+"."), true),
+		tests.Expect(inv.Email, "jean@example.com"),
+		tests.Expect(inv.Role, "billing:admin"))
+
+	other, _ := is.InvitationCreate(ctx, "acme", "paul@example.com", "", "admin@acme.com")
+	tests.MaybeFail("InvitationCreate_role", tests.Expect(other.Role, invitationRoleDefault))
+
+	_, ce = is.InvitationCreate(ctx, "acme", "notAnEmail", "", "admin@acme.com")
+	tests.MaybeFail("InvitationCreate_email", tests.Expect(ce.GetCode(), http.StatusBadRequest))
+	_, ce = is.InvitationCreate(ctx, "acme", "jean@example.com", "", "")
+	tests.MaybeFail("InvitationCreate_inviter", tests.Expect(ce.GetCode(), http.StatusBadRequest))
+
+	// an invitation is only valid for its email and tenant
+	_, ce = is.InvitationConsume(ctx, inv.Token, "acme", "paul@example.com")
+	tests.MaybeFail("InvitationConsume_email", tests.Expect(ce.GetCode(), http.StatusForbidden))
+	_, ce = is.InvitationConsume(ctx, inv.Token, "default", "jean@example.com")
+	tests.MaybeFail("InvitationConsume_tenant", tests.Expect(ce.GetCode(), http.StatusForbidden))
+	_, ce = is.InvitationConsume(ctx, inv.Token+"x", "acme", "jean@example.com")
+	tests.MaybeFail("InvitationConsume_secret", tests.Expect(ce.GetCode(), http.StatusForbidden))
+
+	used, ce := is.InvitationConsume(ctx, inv.Token, "acme", "jean@example.com")
+	tests.MaybeFail("InvitationConsume", ce,
+		tests.Expect(used.Role, "billing:admin"),
+		tests.Expect(used.Hash, ""))
+
+	// single use
+	_, ce = is.InvitationConsume(ctx, inv.Token, "acme", "jean@example.com")
+	tests.MaybeFail("InvitationConsume_used", tests.Expect(ce.GetCode(), http.StatusForbidden))
+
+	ce = is.InvitationRevoke(ctx, other.ID)
+	_, cce := is.InvitationConsume(ctx, other.Token, "acme", "paul@example.com")
+	tests.MaybeFail("InvitationRevoke", ce, tests.Expect(cce.GetCode(), http.StatusForbidden))
+
+	invs, ce := is.InvitationList(ctx, "acme")
+	tests.MaybeFail("InvitationList", ce,
+		tests.Expect(len(invs), 2),
+		tests.Expect(invs[0].IsUsed, 1),
+		tests.Expect(invs[1].IsRevoked, 1),
+		tests.Expect(invs[0].Hash, ""))
+}
+
+func TestUserSignupClosed(t *testing.T) {
+	tests.MaybeFail = tests.InitFailFunc(t)
+
+	signup, _ := NewSignupPolicy(SignupPolicyConfig{PasswordMinLength: 8})
+	invitations := NewInvitationService(repos, time.Hour)
+	closedAuthService := NewAuthenticationService(srv, repos,
+		NewPKCEPolicy(map[string]string{idvar: codeChallengeMethodS256}),
+		signup, invitations, tenantPolicy, keyRing, webhookSvc)
+
+	signupWith := func(inviteToken string) (*http.Response, error) {
+		formValues := url.Values{}
+		formValues.Set("email", "invited@example.com")
+		formValues.Set("password", userPassword)
+		if inviteToken != "" {
+			formValues.Set("invite_token", inviteToken)
+		}
+
+		req, _ := http.NewRequest("POST", "/signup?"+queryParamsClient.Encode(), strings.NewReader(formValues.Encode()))
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+
+		recorder := httptest.NewRecorder()
+		if ce := closedAuthService.SignupService(recorder, req); ce != nil {
+			return nil, ce
+		}
+		return recorder.Result(), nil
+	}
+
+	_, err := signupWith("")
+	tests.MaybeFail("SignupService_closed", tests.Expect(err != nil, true))
+
+	inv, _ := invitations.InvitationCreate(context.Background(), models.TenantDefault, "invited@example.com", "owner", "admin@example.com")
+	response, err := signupWith(inv.Token)
+	tests.MaybeFail("SignupService_invited", err, tests.Expect(response.StatusCode, http.StatusOK))
+
+	user, err := repos.RedisUserGet(context.Background(), models.TenantKey(models.TenantDefault, "invited@example.com"))
+	tests.MaybeFail("SignupService_invited_role", err, tests.Expect(user.TenantRole, "owner"))
+
+	// the invitation is used once the code is exchanged, not by the signup
+	stored, _ := repos.InvitationGetByID(context.Background(), inv.ID)
+	tests.MaybeFail("SignupService_invitation_not_used", tests.Expect(stored.IsUsed, 0))
+
+	jwtInfo, err := exchangeCode("invited@example.com", url.Values{"tenant_role": {"admin"}})
+	created, _ := repos.UserGetByEmail(context.Background(), models.TenantDefault, "invited@example.com")
+	stored, _ = repos.InvitationGetByID(context.Background(), inv.ID)
+	tests.MaybeFail("exchangeCode_invited", err,
+		tests.Expect(jwtInfo["tenant_role"], "owner"),
+		tests.Expect(created.TenantRole, "owner"),
+		tests.Expect(stored.IsUsed, 1))
+
+	_, err = signupWith(inv.Token)
+	tests.MaybeFail("SignupService_invitation_used", tests.Expect(err != nil, true))
+
+	repos.RedisUserReset()
+}
+
+func TestUserSignupForgedTenantRole(t *testing.T) {
+	tests.MaybeFail = tests.InitFailFunc(t)
+
+	formValues := url.Values{}
+	formValues.Set("email", "forger@example.com")
+	formValues.Set("password", userPassword)
+	req, _ := http.NewRequest("POST", "/signup?"+queryParamsClient.Encode(), strings.NewReader(formValues.Encode()))
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	err := authService.SignupService(httptest.NewRecorder(), req)
+	tests.MaybeFail("SignupService_uninvited", err)
+
+	// the tenant_role sent with the token request is ignored
+	jwtInfo, err := exchangeCode("forger@example.com", url.Values{"tenant_role": {"owner"}})
+	created, _ := repos.UserGetByEmail(context.Background(), models.TenantDefault, "forger@example.com")
+	tests.MaybeFail("exchangeCode_forged", err,
+		tests.Expect(jwtInfo["tenant_role"], nil),
+		tests.Expect(created.TenantRole, ""))
+
+	repos.RedisUserReset()
+}
+
+// exchangeCode run the openid and the payload handlers as the token
+// request of the code of email does, form is sent by the client
+func exchangeCode(email string, form url.Values) (map[string]interface{}, error) {
+	form.Set("role", "user")
+	form.Set("sub", email)
+	form.Set("client_id", idvar)
+	req, _ := http.NewRequest("POST", "/token", strings.NewReader(form.Encode()))
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+
+	jwtInfo, _, _, _, err := oauth2Service.UserOpenidService(httptest.NewRecorder(), req)
+	if err != nil {
+		return nil, err
+	}
+
+	data := map[string]interface{}{
+		"refresh_token":     "code",
+		"jwt_access_token":  "accessJWT",
+		"jwt_refresh_token": "refreshJWT",
+	}
+	for k, v := range jwtInfo {
+		data[k] = v
+	}
+	if err, _ := oauth2Service.UserCustomizeTokenPayloadService(req, data); err != nil {
+		return nil, err
+	}
+	return jwtInfo, nil
+}
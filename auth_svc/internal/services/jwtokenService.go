@@ -0,0 +1,241 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/golang-jwt/jwt"
+	e "gitlab.com/grpasr/common/errors/json"
+	obs "gitlab.com/grpasr/common/observability"
+	"strings"
+	"time"
+)
+
+// IJwtokenService hold the token operations exposed over grpc
+type IJwtokenService interface {
+	JwtokenValidate(ctx context.Context, tokenString string) (map[string]string, e.IError)
+	JwtokenGetClaims(ctx context.Context, tokenString string) (*JwtokenClaims, e.IError)
+	JwtokenCheckPermission(ctx context.Context, tokenString, permission string) (string, e.IError)
+	JwtokenRefresh(ctx context.Context, tokenString string) (string, e.IError)
+}
+
+// JwtokenClaims are the claims of a valid jwt_token
+type JwtokenClaims struct {
+	Subject    string
+	Audience   string
+	ExpiresAt  int64
+	OpenidInfo map[string]string
+}
+
+type JwtokenService struct {
+	keys         IKeyRing
+	tokenService ITokenService
+}
+
+func NewJwtokenService(k IKeyRing, ts ITokenService) IJwtokenService {
+	return &JwtokenService{
+		keys:         k,
+		tokenService: ts,
+	}
+}
+
+// JwtokenValidate valid the jwt_token, if valid return its role, svc(sub), scope and tenant
+func (j *JwtokenService) JwtokenValidate(ctx context.Context, tokenString string) (_ map[string]string, ce e.IError) {
+	ctx, span := obs.Tracing.SPNGetFromCTX(ctx, "authSvc_jwtokenValidate",
+		obs.Tracing.TAString("function", "JwtokenValidate"))
+	defer span.End()
+	defer func(start time.Time) {
+		metrics.recordToken(ctx, tokenOperationValidate, start, statusOf(ce))
+	}(time.Now())
+
+	return j.validate(tokenString)
+}
+
+// validate return the role, svc(sub), scope and tenant of a valid jwt_token,
+// a jwt_token without tenant is refused as it could reach any tenant
+func (j *JwtokenService) validate(tokenString string) (map[string]string, e.IError) {
+	claims, ce := j.parse(tokenString)
+	if ce != nil {
+		return nil, ce
+	}
+
+	openidInfo, ok := claims["openidInfo"].(map[string]interface{})
+	if !ok {
+		obs.Logging.NewLogHandler(obs.Logging.LLHError()).
+			Msg("JwtokenValidate - extracting openidInfo from token failed")
+		return nil, e.NewCustomHTTPStatus(e.StatusForbidden)
+	}
+
+	infos := make(map[string]string)
+
+	var errData = false
+	role, ok := openidInfo["role"].(string)
+	infos["role"] = role
+	if !ok {
+		obs.Logging.NewLogHandler(obs.Logging.LLHError()).
+			Msg("JwtokenValidate - no 'role' field in openidInfo")
+		errData = true
+	}
+
+	sub, ok := claims["sub"].(string)
+	infos["svc"] = sub
+	if !ok {
+		obs.Logging.NewLogHandler(obs.Logging.LLHError()).
+			Msg("JwtokenValidate - no 'sub' field in token")
+		errData = true
+	}
+
+	scope, ok := openidInfo["scope"].(string)
+	infos["scope"] = scope
+	if !ok {
+		obs.Logging.NewLogHandler(obs.Logging.LLHError()).
+			Msg("JwtokenValidate - no 'scope' field in openidInfo")
+		errData = true
+	}
+
+	tenant, ok := openidInfo["tenant"].(string)
+	infos["tenant"] = tenant
+	if !ok || tenant == "" {
+		obs.Logging.NewLogHandler(obs.Logging.LLHError()).
+			Msg("JwtokenValidate - no 'tenant' field in openidInfo")
+		errData = true
+	}
+
+	if errData {
+		return nil, e.NewCustomHTTPStatus(e.StatusForbidden, "", "token contains invalid data")
+	}
+
+	return infos, nil
+}
+
+// JwtokenGetClaims valid the jwt_token and return all its claims
+func (j *JwtokenService) JwtokenGetClaims(ctx context.Context, tokenString string) (_ *JwtokenClaims, ce e.IError) {
+	ctx, span := obs.Tracing.SPNGetFromCTX(ctx, "authSvc_jwtokenGetClaims",
+		obs.Tracing.TAString("function", "JwtokenGetClaims"))
+	defer span.End()
+	defer func(start time.Time) {
+		metrics.recordToken(ctx, tokenOperationGetdata, start, statusOf(ce))
+	}(time.Now())
+
+	claims, ce := j.parse(tokenString)
+	if ce != nil {
+		return nil, ce
+	}
+
+	jc := &JwtokenClaims{OpenidInfo: make(map[string]string)}
+	jc.Subject, _ = claims["sub"].(string)
+	jc.Audience, _ = claims["aud"].(string)
+	if exp, ok := claims["exp"].(float64); ok {
+		jc.ExpiresAt = int64(exp)
+	}
+
+	if openidInfo, ok := claims["openidInfo"].(map[string]interface{}); ok {
+		for k, v := range openidInfo {
+			jc.OpenidInfo[k] = fmt.Sprintf("%v", v)
+		}
+	}
+
+	return jc, nil
+}
+
+// JwtokenCheckPermission valid the jwt_token and make sure its scope
+// grant the permission, the rules are the same as for /v1/permission
+func (j *JwtokenService) JwtokenCheckPermission(ctx context.Context, tokenString, permission string) (_ string, ce e.IError) {
+	ctx, span := obs.Tracing.SPNGetFromCTX(ctx, "authSvc_jwtokenCheckPermission",
+		obs.Tracing.TAString("function", "JwtokenCheckPermission"))
+	defer span.End()
+	defer func(start time.Time) {
+		metrics.recordToken(ctx, tokenOperationPermission, start, statusOf(ce))
+	}(time.Now())
+
+	infos, ce := j.validate(tokenString)
+	if ce != nil {
+		return "", ce
+	}
+
+	scope := infos["scope"]
+
+	var allowed bool
+	switch permission {
+	case "read", "write":
+		allowed = hasScope(scope, permission) || hasScope(scope, "all")
+	case "all":
+		allowed = hasScope(scope, "all")
+	default:
+		return scope, e.NewCustomHTTPStatus(e.StatusBadRequest, "", "unknown permission")
+	}
+
+	if !allowed {
+		obs.Logging.NewLogHandler(obs.Logging.LLHDebug()).
+			Msg(fmt.Sprintf("JwtokenCheckPermission - %v has no %v permission", infos["svc"], permission))
+		return scope, e.NewCustomHTTPStatus(e.StatusForbidden)
+	}
+
+	return scope, nil
+}
+
+// hasScope is true if the scope, separated by spaces or commas, has the
+// exact permission, "readonly" does not grant "read"
+func hasScope(scope, permission string) bool {
+	for _, s := range strings.FieldsFunc(scope, func(c rune) bool { return c == ',' || c == ' ' }) {
+		if s == permission {
+			return true
+		}
+	}
+	return false
+}
+
+// JwtokenRefresh refresh the jwt_token, it runs the same flow as /v1/refreshopenid
+func (j *JwtokenService) JwtokenRefresh(ctx context.Context, tokenString string) (string, e.IError) {
+	return j.tokenService.RefreshOpenid(ctx, tokenString)
+}
+
+// parse make sure the token is signed with one of our keys and not expired
+func (j *JwtokenService) parse(tokenString string) (jwt.MapClaims, e.IError) {
+	tokenString = trimToken(tokenString)
+
+	var claims jwt.MapClaims
+
+	_, err := jwt.Parse(tokenString, func(token *jwt.Token) (interface{}, error) {
+		if _, ok := token.Method.(*jwt.SigningMethodHMAC); !ok {
+			return nil, errors.New("ErrInvalidJWToken")
+		}
+
+		var ok bool
+		claims, ok = token.Claims.(jwt.MapClaims)
+		if !ok {
+			return nil, errors.New("ErrInvalidJWToken")
+		}
+
+		err := claims.Valid()
+		if err != nil {
+			if err.Error() == "Token is expired" {
+				return nil, errors.New("ErrExpiredJWToken")
+			}
+			return nil, errors.New("ErrInvalidJWToken")
+		}
+
+		_, secretKey, err := j.keys.KeyRingForToken(tokenString)
+		if err != nil {
+			return nil, err
+		}
+		return []byte(secretKey), nil
+	})
+
+	if err != nil {
+		if strings.Contains(err.Error(), "ErrExpiredJWToken") {
+			obs.Logging.NewLogHandler(obs.Logging.LLHError()).
+				Err(err).
+				Msg("parse - json token expired")
+			return nil, e.NewCustomHTTPStatus(e.StatusUnauthorized)
+		}
+		return nil, e.NewCustomHTTPStatus(e.StatusForbidden)
+	}
+
+	return claims, nil
+}
+
+func trimToken(tokenString string) string {
+	tokenString = strings.TrimSpace(tokenString)
+	return strings.Trim(tokenString, `"`)
+}
@@ -0,0 +1,160 @@
+package services
+
+import (
+	"context"
+	"gitlab.com/grpasr/common/tests"
+	"net/http"
+	"testing"
+)
+
+func newTestJwtoken(t *testing.T, expiresAt int64, signedKey string, info map[string]interface{}) string {
+	ag := NewJWTAccessGenerate(keyID, signedKey)
+	token, err := ag.GenerateOpenidJWToken(expiresAt, info, brokerSvcID, brokerSvcID)
+	if err != nil {
+		t.Fatal("generate jwtoken failed: ", err)
+	}
+	return token
+}
+
+func TestJwtokenValidateValidToken(t *testing.T) {
+	tests.MaybeFail = tests.InitFailFunc(t)
+
+	token := newTestJwtoken(t, expireInAnHour, secretKey, map[string]interface{}{
+		"role":   "APIserver",
+		"scope":  "read, openid",
+		"tenant": "default",
+	})
+
+	js := NewJwtokenService(keyRing, tokenService)
+
+	infos, ce := js.JwtokenValidate(context.Background(), token)
+
+	tests.MaybeFail("JwtokenValidate_valid", ce,
+		tests.Expect(infos["role"], "APIserver"),
+		tests.Expect(infos["scope"], "read, openid"),
+		tests.Expect(infos["svc"], brokerSvcID),
+		tests.Expect(infos["tenant"], "default"))
+}
+
+func TestJwtokenValidateExpiredToken(t *testing.T) {
+	tests.MaybeFail = tests.InitFailFunc(t)
+
+	token := newTestJwtoken(t, expiredTime, secretKey, map[string]interface{}{
+		"role":   "APIserver",
+		"scope":  "read, openid",
+		"tenant": "default",
+	})
+
+	js := NewJwtokenService(keyRing, tokenService)
+
+	_, ce := js.JwtokenValidate(context.Background(), token)
+
+	tests.MaybeFail("JwtokenValidate_expired", tests.Expect(ce.GetCode(), http.StatusUnauthorized))
+}
+
+func TestJwtokenValidateInvalidSignature(t *testing.T) {
+	tests.MaybeFail = tests.InitFailFunc(t)
+
+	token := newTestJwtoken(t, expireInAnHour, "anotherSecretKey", map[string]interface{}{
+		"role":   "APIserver",
+		"scope":  "read, openid",
+		"tenant": "default",
+	})
+
+	js := NewJwtokenService(keyRing, tokenService)
+
+	_, ce := js.JwtokenValidate(context.Background(), token)
+
+	tests.MaybeFail("JwtokenValidate_signature", tests.Expect(ce.GetCode(), http.StatusForbidden))
+}
+
+func TestJwtokenValidateMissingInfos(t *testing.T) {
+	tests.MaybeFail = tests.InitFailFunc(t)
+
+	token := newTestJwtoken(t, expireInAnHour, secretKey, map[string]interface{}{
+		"roleInv": "APIserver",
+	})
+
+	js := NewJwtokenService(keyRing, tokenService)
+
+	_, ce := js.JwtokenValidate(context.Background(), token)
+
+	tests.MaybeFail("JwtokenValidate_infos",
+		tests.Expect(ce.GetCode(), http.StatusForbidden),
+		tests.Expect(ce.Error(), `403 : Request forbidden, Comment: token contains invalid data`))
+}
+
+func TestJwtokenValidateMissingTenant(t *testing.T) {
+	tests.MaybeFail = tests.InitFailFunc(t)
+
+	token := newTestJwtoken(t, expireInAnHour, secretKey, map[string]interface{}{
+		"role":  "APIserver",
+		"scope": "read, openid",
+	})
+
+	js := NewJwtokenService(keyRing, tokenService)
+
+	_, ce := js.JwtokenValidate(context.Background(), token)
+
+	tests.MaybeFail("JwtokenValidate_tenant", tests.Expect(ce.GetCode(), http.StatusForbidden))
+}
+
+func TestJwtokenGetClaims(t *testing.T) {
+	tests.MaybeFail = tests.InitFailFunc(t)
+
+	token := newTestJwtoken(t, expireInAnHour, secretKey, map[string]interface{}{
+		"role":  "user",
+		"scope": "read",
+		"name":  "Robert",
+	})
+
+	js := NewJwtokenService(keyRing, tokenService)
+
+	claims, ce := js.JwtokenGetClaims(context.Background(), token)
+
+	tests.MaybeFail("JwtokenGetClaims", ce,
+		tests.Expect(claims.Subject, brokerSvcID),
+		tests.Expect(claims.ExpiresAt, expireInAnHour),
+		tests.Expect(claims.OpenidInfo["name"], "Robert"),
+		tests.Expect(len(claims.OpenidInfo), 3))
+}
+
+func TestJwtokenCheckPermission(t *testing.T) {
+	tests.MaybeFail = tests.InitFailFunc(t)
+
+	token := newTestJwtoken(t, expireInAnHour, secretKey, map[string]interface{}{
+		"role":   "APIserver",
+		"scope":  "read, openid",
+		"tenant": "default",
+	})
+
+	js := NewJwtokenService(keyRing, tokenService)
+
+	scope, ce := js.JwtokenCheckPermission(context.Background(), token, "read")
+	tests.MaybeFail("JwtokenCheckPermission_read", ce, tests.Expect(scope, "read, openid"))
+
+	_, ce = js.JwtokenCheckPermission(context.Background(), token, "write")
+	tests.MaybeFail("JwtokenCheckPermission_write", tests.Expect(ce.GetCode(), http.StatusForbidden))
+
+	_, ce = js.JwtokenCheckPermission(context.Background(), token, "delete")
+	tests.MaybeFail("JwtokenCheckPermission_unknown", tests.Expect(ce.GetCode(), http.StatusBadRequest))
+
+	// the scopes are matched as a whole, not as a part of an other one
+	token = newTestJwtoken(t, expireInAnHour, secretKey, map[string]interface{}{
+		"role":   "APIserver",
+		"scope":  "readonly,install",
+		"tenant": "default",
+	})
+	_, ce = js.JwtokenCheckPermission(context.Background(), token, "read")
+	tests.MaybeFail("JwtokenCheckPermission_substring_read", tests.Expect(ce.GetCode(), http.StatusForbidden))
+	_, ce = js.JwtokenCheckPermission(context.Background(), token, "all")
+	tests.MaybeFail("JwtokenCheckPermission_substring_all", tests.Expect(ce.GetCode(), http.StatusForbidden))
+
+	token = newTestJwtoken(t, expireInAnHour, secretKey, map[string]interface{}{
+		"role":   "APIserver",
+		"scope":  "openid all",
+		"tenant": "default",
+	})
+	_, ce = js.JwtokenCheckPermission(context.Background(), token, "write")
+	tests.MaybeFail("JwtokenCheckPermission_all", ce)
+}
@@ -0,0 +1,263 @@
+package services
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"github.com/golang-jwt/jwt"
+	"gitlab.com/grpasr/asonrythme/auth_svc/internal/models"
+	"gitlab.com/grpasr/asonrythme/auth_svc/internal/repository"
+	obs "gitlab.com/grpasr/common/observability"
+	"sync"
+	"time"
+)
+
+const (
+	// previousKeysMax is the number of rotated keys still accepted to
+	// validate the jwt signed before a rotation
+	previousKeysMax = 2
+
+	// keyRingReloadMin is the min delay between two reloads of the ring
+	// triggered by an unknown kid, a rotation by an other instance is
+	// seen at once but a forged kid can not hammer the store
+	keyRingReloadMin = 5 * time.Second
+
+	keyRingRotateAttempts = 3
+)
+
+// ErrUnknownKeyID is returned for a jwt whose kid is not in the ring
+var ErrUnknownKeyID = errors.New("unknown jwt key id")
+
+// IKeyRing hold the keys the jwt are signed with
+type IKeyRing interface {
+	KeyRingCurrent() (keyID string, secretKey string)
+	KeyRingForToken(tokenString string) (keyID string, secretKey string, err error)
+	KeyRingRotate(ctx context.Context) (string, error)
+	KeyRingKeys() []models.SigningKey
+}
+
+type signingKey struct {
+	keyID     string
+	secretKey string
+}
+
+// KeyRing sign with the current key and keep the previous ones to validate
+// the jwt which are still alive. Once loaded from the store the ring is
+// shared by the auth_svc instances, a rotation is saved in the store and
+// seen by the others on their next reload, see Run
+type KeyRing struct {
+	current  signingKey
+	previous []signingKey
+	store    repository.ISigningKeyStore
+	version  int
+	loadedAt time.Time
+	sync.RWMutex
+}
+
+func NewKeyRing(keyID, secretKey string) *KeyRing {
+	return &KeyRing{
+		current: signingKey{keyID, secretKey},
+	}
+}
+
+// KeyRingLoad bind the ring to store, the saved ring replace the initial
+// key, the first instance to start save it
+func (k *KeyRing) KeyRingLoad(ctx context.Context, store repository.ISigningKeyStore) error {
+	k.Lock()
+	k.store = store
+	initial := k.datas()
+	k.Unlock()
+
+	err := store.SigningKeysSave(ctx, initial)
+	if err != nil && !errors.Is(err, repository.ErrSigningKeysConflict) {
+		return err
+	}
+
+	return k.KeyRingReload(ctx)
+}
+
+// KeyRingReload read again the ring saved in the store
+func (k *KeyRing) KeyRingReload(ctx context.Context) error {
+	k.RLock()
+	store := k.store
+	k.RUnlock()
+	if store == nil {
+		return nil
+	}
+
+	d, err := store.SigningKeysGet(ctx)
+	if err != nil {
+		return err
+	}
+	if len(d.Keys) == 0 {
+		return errors.New("signing keys ring is empty")
+	}
+
+	k.Lock()
+	defer k.Unlock()
+	k.current = signingKey{d.Keys[0].KeyID, d.Keys[0].SecretKey}
+	k.previous = k.previous[:0]
+	for _, key := range d.Keys[1:] {
+		k.previous = append(k.previous, signingKey{key.KeyID, key.SecretKey})
+	}
+	k.version = d.Version
+	k.loadedAt = time.Now()
+
+	return nil
+}
+
+// Run reload the ring every interval until ctx is done
+func (k *KeyRing) Run(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if err := k.KeyRingReload(ctx); err != nil {
+				obs.Logging.NewLogHandler(obs.Logging.LLHError()).
+					Err(err).
+					Msg("KeyRing - reload failed")
+			}
+		}
+	}
+}
+
+// KeyRingCurrent return the key to sign the new jwt with
+func (k *KeyRing) KeyRingCurrent() (string, string) {
+	k.RLock()
+	defer k.RUnlock()
+	return k.current.keyID, k.current.secretKey
+}
+
+// KeyRingForToken return the key matching the token's kid header, a kid
+// missing from the ring is looked for in the store before ErrUnknownKeyID
+func (k *KeyRing) KeyRingForToken(tokenString string) (string, string, error) {
+	token, _, err := new(jwt.Parser).ParseUnverified(trimToken(tokenString), jwt.MapClaims{})
+	if err != nil {
+		return "", "", err
+	}
+	kid, _ := token.Header["kid"].(string)
+	if kid == "" {
+		return "", "", ErrUnknownKeyID
+	}
+
+	if key, ok := k.lookup(kid); ok {
+		return key.keyID, key.secretKey, nil
+	}
+
+	k.RLock()
+	reload := k.store != nil && time.Since(k.loadedAt) > keyRingReloadMin
+	k.RUnlock()
+	if reload {
+		if err := k.KeyRingReload(context.Background()); err != nil {
+			return "", "", err
+		}
+		if key, ok := k.lookup(kid); ok {
+			return key.keyID, key.secretKey, nil
+		}
+	}
+
+	return "", "", ErrUnknownKeyID
+}
+
+func (k *KeyRing) lookup(kid string) (signingKey, bool) {
+	k.RLock()
+	defer k.RUnlock()
+
+	if k.current.keyID == kid {
+		return k.current, true
+	}
+	for _, key := range k.previous {
+		if key.keyID == kid {
+			return key, true
+		}
+	}
+	return signingKey{}, false
+}
+
+// KeyRingRotate generate a new current key and return its keyID, the ring
+// is saved in the store before it is used
+func (k *KeyRing) KeyRingRotate(ctx context.Context) (string, error) {
+	kid := make([]byte, 8)
+	if _, err := rand.Read(kid); err != nil {
+		return "", err
+	}
+	secret := make([]byte, 32)
+	if _, err := rand.Read(secret); err != nil {
+		return "", err
+	}
+	key := signingKey{
+		keyID:     hex.EncodeToString(kid),
+		secretKey: base64.RawURLEncoding.EncodeToString(secret),
+	}
+
+	k.RLock()
+	store := k.store
+	k.RUnlock()
+	if store == nil {
+		k.Lock()
+		k.rotate(key)
+		k.Unlock()
+		return key.keyID, nil
+	}
+
+	// an other instance may rotate at the same time, rotate its ring
+	for i := 0; i < keyRingRotateAttempts; i++ {
+		if err := k.KeyRingReload(ctx); err != nil {
+			return "", err
+		}
+
+		k.Lock()
+		k.rotate(key)
+		d := k.datas()
+		k.Unlock()
+
+		err := store.SigningKeysSave(ctx, d)
+		if err == nil {
+			return key.keyID, k.KeyRingReload(ctx)
+		}
+		if !errors.Is(err, repository.ErrSigningKeysConflict) {
+			k.KeyRingReload(ctx)
+			return "", err
+		}
+	}
+
+	return "", repository.ErrSigningKeysConflict
+}
+
+// rotate make key the current one, the caller hold the lock
+func (k *KeyRing) rotate(key signingKey) {
+	k.previous = append([]signingKey{k.current}, k.previous...)
+	if len(k.previous) > previousKeysMax {
+		k.previous = k.previous[:previousKeysMax]
+	}
+	k.current = key
+}
+
+// datas is the ring as saved in the store, the caller hold the lock
+func (k *KeyRing) datas() models.SigningKeysDatas {
+	return models.SigningKeysDatas{
+		Keys:    k.keys(),
+		Version: k.version,
+	}
+}
+
+func (k *KeyRing) keys() []models.SigningKey {
+	keys := []models.SigningKey{{KeyID: k.current.keyID, SecretKey: k.current.secretKey}}
+	for _, key := range k.previous {
+		keys = append(keys, models.SigningKey{KeyID: key.keyID, SecretKey: key.secretKey})
+	}
+	return keys
+}
+
+// KeyRingKeys return the keys of the ring, the current one first, they
+// are published to the validators, see KeySetService
+func (k *KeyRing) KeyRingKeys() []models.SigningKey {
+	k.RLock()
+	defer k.RUnlock()
+	return k.keys()
+}
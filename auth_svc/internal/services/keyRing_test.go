@@ -0,0 +1,153 @@
+package services
+
+import (
+	"context"
+	"encoding/base64"
+	"errors"
+	"github.com/djedjethai/go-oauth2-openid/models"
+	"gitlab.com/grpasr/asonrythme/auth_svc/internal/repository"
+	"gitlab.com/grpasr/common/tests"
+	"net/http"
+	"testing"
+)
+
+func TestKeyRingRotate(t *testing.T) {
+	tests.MaybeFail = tests.InitFailFunc(t)
+
+	kr := NewKeyRing(keyID, secretKey)
+	oldToken := newTestJwtoken(t, expireInAnHour, secretKey, map[string]interface{}{
+		"role":  "APIserver",
+		"scope": "read",
+	})
+
+	newKeyID, err := kr.KeyRingRotate(context.Background())
+	currentKeyID, currentSecretKey := kr.KeyRingCurrent()
+	tests.MaybeFail("KeyRingRotate", err,
+		tests.Expect(currentKeyID, newKeyID),
+		tests.Expect(currentSecretKey != secretKey, true))
+
+	// the token signed before the rotation is still valid
+	oldKeyID, oldSecretKey, err := kr.KeyRingForToken(oldToken)
+	tests.MaybeFail("KeyRingForToken_previous", err,
+		tests.Expect(oldKeyID, keyID),
+		tests.Expect(oldSecretKey, secretKey))
+
+	js := NewJwtokenService(kr, tokenService)
+	_, ce := js.JwtokenValidate(context.Background(), oldToken)
+	tests.MaybeFail("KeyRingRotate_validate_previous", ce)
+}
+
+func TestKeyRingDropOldestKey(t *testing.T) {
+	tests.MaybeFail = tests.InitFailFunc(t)
+
+	kr := NewKeyRing(keyID, secretKey)
+	oldToken := newTestJwtoken(t, expireInAnHour, secretKey, map[string]interface{}{
+		"role":  "APIserver",
+		"scope": "read",
+	})
+
+	for i := 0; i <= previousKeysMax; i++ {
+		if _, err := kr.KeyRingRotate(context.Background()); err != nil {
+			t.Fatal("rotate failed: ", err)
+		}
+	}
+
+	js := NewJwtokenService(kr, tokenService)
+	_, ce := js.JwtokenValidate(context.Background(), oldToken)
+	tests.MaybeFail("KeyRingDropOldestKey", tests.Expect(ce.GetCode(), http.StatusForbidden))
+}
+
+func TestKeyRingUnknownKeyID(t *testing.T) {
+	tests.MaybeFail = tests.InitFailFunc(t)
+
+	kr := NewKeyRing(keyID, secretKey)
+
+	// signed with the current secret but an other kid, the kid is not
+	// trusted to fall back on the current key
+	ag := NewJWTAccessGenerate("unknownKeyID", secretKey)
+	token, err := ag.GenerateOpenidJWToken(expireInAnHour, map[string]interface{}{
+		"role":  "APIserver",
+		"scope": "read",
+	}, brokerSvcID, brokerSvcID)
+	if err != nil {
+		t.Fatal("generate jwtoken failed: ", err)
+	}
+
+	_, _, err = kr.KeyRingForToken(token)
+	tests.MaybeFail("KeyRingForToken_unknown", tests.Expect(errors.Is(err, ErrUnknownKeyID), true))
+
+	js := NewJwtokenService(kr, tokenService)
+	_, ce := js.JwtokenValidate(context.Background(), token)
+	tests.MaybeFail("KeyRingUnknownKeyID_validate", tests.Expect(ce.GetCode(), http.StatusForbidden))
+}
+
+func TestKeyRingSharedByTheStore(t *testing.T) {
+	tests.MaybeFail = tests.InitFailFunc(t)
+
+	ctx := context.Background()
+	store := repository.NewSigningKeyStoreMock()
+
+	// the first instance save its initial key, the second one use it
+	first := NewKeyRing(keyID, secretKey)
+	second := NewKeyRing("otherKeyID", "otherSecretKey")
+	tests.MaybeFail("KeyRingLoad",
+		first.KeyRingLoad(ctx, store),
+		second.KeyRingLoad(ctx, store))
+	secondKeyID, _ := second.KeyRingCurrent()
+	tests.MaybeFail("KeyRingLoad_shared", tests.Expect(secondKeyID, keyID))
+
+	// the key rotated by the first instance is saved and a token it
+	// signed is validated by the second one
+	newKeyID, err := first.KeyRingRotate(ctx)
+	tests.MaybeFail("KeyRingRotate", err)
+	_, newSecretKey := first.KeyRingCurrent()
+
+	ag := NewJWTAccessGenerate(newKeyID, newSecretKey)
+	token, err := ag.GenerateOpenidJWToken(expireInAnHour, map[string]interface{}{
+		"role":  "APIserver",
+		"scope": "read",
+	}, brokerSvcID, brokerSvcID)
+	if err != nil {
+		t.Fatal("generate jwtoken failed: ", err)
+	}
+
+	second.loadedAt = second.loadedAt.Add(-2 * keyRingReloadMin)
+	foundKeyID, foundSecretKey, err := second.KeyRingForToken(token)
+	tests.MaybeFail("KeyRingForToken_rotated_elsewhere", err,
+		tests.Expect(foundKeyID, newKeyID),
+		tests.Expect(foundSecretKey, newSecretKey))
+
+	// a restarted instance load the rotated ring
+	restarted := NewKeyRing(keyID, secretKey)
+	tests.MaybeFail("KeyRingLoad_restarted", restarted.KeyRingLoad(ctx, store))
+	restartedKeyID, _ := restarted.KeyRingCurrent()
+	tests.MaybeFail("KeyRingLoad_persisted",
+		tests.Expect(restartedKeyID, newKeyID),
+		tests.Expect(len(restarted.KeyRingKeys()), 2))
+}
+
+func TestKeySetGet(t *testing.T) {
+	tests.MaybeFail = tests.InitFailFunc(t)
+
+	cs := newClientStoreMock()
+	_ = cs.Create(&models.Client{ID: "registrySvc", Secret: "registrySvcSecret", UserID: "registrySvc"})
+	_ = cs.Create(&models.Client{ID: brokerSvcID, Secret: brokerSvcSecret, UserID: "brokerSvc"})
+	ks := NewKeySetService(cs, NewKeyRing(keyID, secretKey), []string{"registrySvc"})
+	ctx := context.Background()
+
+	set, ce := ks.KeySetGet(ctx, "registrySvc", "registrySvcSecret")
+	tests.MaybeFail("KeySetGet", ce,
+		tests.Expect(len(set.Keys), 1),
+		tests.Expect(set.Keys[0].KeyID, keyID),
+		tests.Expect(set.Keys[0].K, base64.RawURLEncoding.EncodeToString([]byte(secretKey))))
+
+	_, ce = ks.KeySetGet(ctx, "registrySvc", "wrongSecret")
+	tests.MaybeFail("KeySetGet_wrong_secret", tests.Expect(ce.GetCode(), http.StatusUnauthorized))
+
+	_, ce = ks.KeySetGet(ctx, "", "")
+	tests.MaybeFail("KeySetGet_no_credentials", tests.Expect(ce.GetCode(), http.StatusUnauthorized))
+
+	// a client which is not a validator can not get the keys
+	_, ce = ks.KeySetGet(ctx, brokerSvcID, brokerSvcSecret)
+	tests.MaybeFail("KeySetGet_not_validator", tests.Expect(ce.GetCode(), http.StatusForbidden))
+}
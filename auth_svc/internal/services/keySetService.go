@@ -0,0 +1,76 @@
+package services
+
+import (
+	"context"
+	"crypto/subtle"
+	"encoding/base64"
+	e "gitlab.com/grpasr/common/errors/json"
+)
+
+// IKeySetService publish the signing keys to the services validating
+// the jwt themselves(registry_svc...)
+type IKeySetService interface {
+	KeySetGet(ctx context.Context, clientID, clientSecret string) (*KeySet, e.IError)
+}
+
+// KeySet is the ring as a jwks, the current key first
+type KeySet struct {
+	Keys []KeySetKey `json:"keys"`
+}
+
+// KeySetKey is a symmetric key(rfc7518 section 6.4), K is its base64url secret
+type KeySetKey struct {
+	KeyType   string `json:"kty"`
+	Algorithm string `json:"alg"`
+	KeyID     string `json:"kid"`
+	K         string `json:"k"`
+}
+
+// KeySetService serve the keys of the ring to the validators only, the
+// keys are symmetric and would let anyone else sign a jwt
+type KeySetService struct {
+	clients    IClientStore
+	keys       IKeyRing
+	validators map[string]bool
+}
+
+func NewKeySetService(cs IClientStore, k IKeyRing, validators []string) IKeySetService {
+	v := make(map[string]bool, len(validators))
+	for _, id := range validators {
+		v[id] = true
+	}
+	return &KeySetService{
+		clients:    cs,
+		keys:       k,
+		validators: v,
+	}
+}
+
+// KeySetGet authenticate the client with its credentials and return the
+// keys if it is one of the validators
+func (k *KeySetService) KeySetGet(ctx context.Context, clientID, clientSecret string) (*KeySet, e.IError) {
+	if clientID == "" || clientSecret == "" {
+		return nil, e.NewCustomHTTPStatus(e.StatusUnauthorized, "auth/v1/keys", "client credentials missing")
+	}
+
+	info, err := k.clients.GetByID(ctx, clientID)
+	if err != nil || info == nil ||
+		subtle.ConstantTimeCompare([]byte(info.GetSecret()), []byte(clientSecret)) != 1 {
+		return nil, e.NewCustomHTTPStatus(e.StatusUnauthorized, "auth/v1/keys", "invalid client credentials")
+	}
+	if !k.validators[clientID] {
+		return nil, e.NewCustomHTTPStatus(e.StatusForbidden, "auth/v1/keys", "client is not a validator")
+	}
+
+	set := &KeySet{}
+	for _, key := range k.keys.KeyRingKeys() {
+		set.Keys = append(set.Keys, KeySetKey{
+			KeyType:   "oct",
+			Algorithm: "HS256",
+			KeyID:     key.KeyID,
+			K:         base64.RawURLEncoding.EncodeToString([]byte(key.SecretKey)),
+		})
+	}
+
+	return set, nil
+}
@@ -0,0 +1,254 @@
+package services
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"fmt"
+	"github.com/djedjethai/go-oauth2-openid/server"
+	"gitlab.com/grpasr/asonrythme/auth_svc/internal/mailer"
+	"gitlab.com/grpasr/asonrythme/auth_svc/internal/models"
+	"gitlab.com/grpasr/asonrythme/auth_svc/internal/repository"
+	e "gitlab.com/grpasr/common/errors/json"
+	obs "gitlab.com/grpasr/common/observability"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// magicLinkAuthorizeParams are the authorize params kept with the link,
+// the callback replay them to complete the authorize request
+var magicLinkAuthorizeParams = []string{
+	"response_type",
+	"client_id",
+	"scope",
+	"state",
+	"redirect_uri",
+	"code_challenge",
+	"code_challenge_method",
+}
+
+type IMagicLinkService interface {
+	MagicLinkRequestService(r *http.Request) e.IError
+	MagicLinkCallbackService(w http.ResponseWriter, r *http.Request) e.IError
+}
+
+// MagicLinkConfig set the links, Secret sign them and at most
+// MaxPerTTL links are sent to an email within the TTL
+type MagicLinkConfig struct {
+	URL       string
+	Secret    []byte
+	TTL       time.Duration
+	MaxPerTTL int
+}
+
+type MagicLinkService struct {
+	srv     *server.Server
+	repos   *repository.Repository
+	pkce    *PKCEPolicy
+	tenants *TenantPolicy
+	mailer  mailer.IMailer
+	cfg     MagicLinkConfig
+}
+
+func NewMagicLinkService(srv *server.Server, rp *repository.Repository, p *PKCEPolicy, t *TenantPolicy, m mailer.IMailer, cfg MagicLinkConfig) IMagicLinkService {
+	return &MagicLinkService{
+		srv:     srv,
+		repos:   rp,
+		pkce:    p,
+		tenants: t,
+		mailer:  m,
+		cfg:     cfg,
+	}
+}
+
+// MagicLinkRequestService email a single-use login link to the user,
+// an unknown or disabled email get the same response, but no link
+func (m *MagicLinkService) MagicLinkRequestService(r *http.Request) e.IError {
+	obs.Logging.NewLogHandler(obs.Logging.LLHDebug()).
+		Msg("MagicLinkRequestService - hit service")
+
+	ctx, span := obs.Tracing.SPNGetFromCTX(r.Context(), "authSvc_magicLinkRequest",
+		obs.Tracing.TAString("function", "MagicLinkRequestService"))
+	defer span.End()
+
+	if r.Form == nil {
+		if err := r.ParseForm(); err != nil {
+			obs.Logging.NewLogHandler(obs.Logging.LLHError()).
+				Err(err).
+				Msg("MagicLinkRequestService - parse form failed")
+			return e.NewCustomHTTPStatus(e.StatusInternalServerError, "auth/v1/magiclink", err.Error())
+		}
+	}
+
+	ce := m.pkce.PKCEValidate(
+		r.Form.Get("client_id"),
+		r.Form.Get("code_challenge"),
+		r.Form.Get("code_challenge_method"),
+		"auth/v1/magiclink")
+	if ce != nil {
+		return ce
+	}
+
+	email := r.Form.Get("email")
+	if len(email) < 1 {
+		obs.Logging.NewLogHandler(obs.Logging.LLHError()).
+			Msg("MagicLinkRequestService - email missing")
+		return e.NewCustomHTTPStatus(e.StatusBadRequest)
+	}
+
+	count, err := m.repos.MagicLinkCountSince(email, time.Now().Add(-m.cfg.TTL))
+	if err != nil {
+		return e.NewCustomHTTPStatus(e.StatusInternalServerError)
+	}
+	if count >= m.cfg.MaxPerTTL {
+		obs.Logging.NewLogHandler(obs.Logging.LLHError()).
+			Msg(fmt.Sprintf("MagicLinkRequestService - too many links for %v", email))
+		return e.NewCustomHTTPStatus(http.StatusTooManyRequests, "auth/v1/magiclink", "too many requests, retry later")
+	}
+
+	tenant := m.tenants.TenantOf(ctx, r.Form.Get("client_id"))
+
+	user, err := m.repos.UserGetByEmail(ctx, tenant, email)
+	if err != nil || user.IsDisabled == 1 {
+		obs.Logging.NewLogHandler(obs.Logging.LLHDebug()).
+			Msg(fmt.Sprintf("MagicLinkRequestService - no link for %v", email))
+		return nil
+	}
+
+	authorizeQuery := url.Values{}
+	for _, k := range magicLinkAuthorizeParams {
+		if v := r.Form.Get(k); v != "" {
+			authorizeQuery.Set(k, v)
+		}
+	}
+
+	id, err := newRandomString(24)
+	if err != nil {
+		return e.NewCustomHTTPStatus(e.StatusInternalServerError)
+	}
+	expiresAt := time.Now().Add(m.cfg.TTL)
+
+	err = m.repos.MagicLinkCreate(models.MagicLinkDatas{
+		ID:             id,
+		Tenant:         tenant,
+		Email:          email,
+		AuthorizeQuery: authorizeQuery.Encode(),
+		ExpiresAT:      expiresAt,
+	})
+	if err != nil {
+		return e.NewCustomHTTPStatus(e.StatusInternalServerError)
+	}
+
+	link := fmt.Sprintf("%s?token=%s", m.cfg.URL, url.QueryEscape(m.sign(id, expiresAt.Unix())))
+	body := fmt.Sprintf("Use this link to sign in, it expires in %v minutes and works once:\r\n\r\n%s",
+		int(m.cfg.TTL.Minutes()), link)
+
+	if err := m.mailer.MailerSend(email, "Your sign in link", body); err != nil {
+		obs.Logging.NewLogHandler(obs.Logging.LLHError()).
+			Err(err).
+			Msg(fmt.Sprintf("MagicLinkRequestService - send link to %v failed", email))
+		return e.NewCustomHTTPStatus(e.StatusInternalServerError)
+	}
+
+	obs.Logging.NewLogHandler(obs.Logging.LLHDebug()).
+		Msg("MagicLinkRequestService - exit successfully")
+
+	return nil
+}
+
+// MagicLinkCallbackService consume the link and run the signin's
+// authorize request with path magiclink, it responds with the code
+func (m *MagicLinkService) MagicLinkCallbackService(w http.ResponseWriter, r *http.Request) e.IError {
+	obs.Logging.NewLogHandler(obs.Logging.LLHDebug()).
+		Msg("MagicLinkCallbackService - hit service")
+
+	ctx, span := obs.Tracing.SPNGetFromCTX(r.Context(), "authSvc_magicLinkCallback",
+		obs.Tracing.TAString("function", "MagicLinkCallbackService"))
+	defer span.End()
+
+	id, ce := m.verify(r.FormValue("token"))
+	if ce != nil {
+		return ce
+	}
+
+	link, err := m.repos.MagicLinkConsume(id)
+	if err != nil {
+		return e.NewCustomHTTPStatus(e.StatusForbidden, "auth/v1/magiclink", "link already used or expired")
+	}
+
+	authorizeQuery, err := url.ParseQuery(link.AuthorizeQuery)
+	if err != nil {
+		return e.NewCustomHTTPStatus(e.StatusInternalServerError)
+	}
+	authorizeQuery.Set("email", link.Email)
+	authorizeQuery.Set("role", "user")
+	r.Form = authorizeQuery
+
+	// the links saved before the tenants belong to the default tenant
+	tenant := tenantOrDefault(link.Tenant)
+
+	// save to redis/cache, as we are not sure the jwt will be deliver
+	user := models.UserRedisDatas{
+		Tenant: tenant,
+		Path:   "magiclink",
+	}
+	if err := m.repos.RedisUserSet(ctx, models.TenantKey(tenant, link.Email), user); err != nil {
+		obs.Logging.NewLogHandler(obs.Logging.LLHError()).
+			Err(err).
+			Msg(fmt.Sprintf("MagicLinkCallbackService - set %v to redis failed", link.Email))
+		return e.NewCustomHTTPStatus(e.StatusInternalServerError)
+	}
+
+	// save user in a temporary store for the user to be reconized later on
+	key := fmt.Sprintf("LoggedInUserID-%v", models.TenantKey(tenant, link.Email))
+	if err := m.repos.TemporarySet(key, link.Email); err != nil {
+		obs.Logging.NewLogHandler(obs.Logging.LLHError()).
+			Err(err).
+			Msg(fmt.Sprintf("MagicLinkCallbackService - set %v to temporaryStore failed", link.Email))
+		return e.NewCustomHTTPStatus(e.StatusInternalServerError)
+	}
+
+	obs.Logging.NewLogHandler(obs.Logging.LLHDebug()).
+		Msg("MagicLinkCallbackService - exit successfully")
+
+	// the err response is handled within HandleAuthorizeRequest
+	_ = m.srv.HandleAuthorizeRequest(w, r)
+	return nil
+}
+
+// sign return the link's token: id.expiresAt.signature
+func (m *MagicLinkService) sign(id string, expiresAt int64) string {
+	payload := fmt.Sprintf("%s.%d", id, expiresAt)
+	mac := hmac.New(sha256.New, m.cfg.Secret)
+	mac.Write([]byte(payload))
+	return payload + "." + base64.RawURLEncoding.EncodeToString(mac.Sum(nil))
+}
+
+// verify check the token's signature and expiry, and return the link's id
+func (m *MagicLinkService) verify(token string) (string, e.IError) {
+	parts := strings.Split(token, ".")
+	if len(parts) != 3 {
+		return "", e.NewCustomHTTPStatus(e.StatusForbidden, "auth/v1/magiclink", "invalid link")
+	}
+
+	expiresAt, err := strconv.ParseInt(parts[1], 10, 64)
+	if err != nil {
+		return "", e.NewCustomHTTPStatus(e.StatusForbidden, "auth/v1/magiclink", "invalid link")
+	}
+
+	expected := m.sign(parts[0], expiresAt)
+	if !hmac.Equal([]byte(expected), []byte(token)) {
+		obs.Logging.NewLogHandler(obs.Logging.LLHError()).
+			Msg("verify - invalid magic link signature")
+		return "", e.NewCustomHTTPStatus(e.StatusForbidden, "auth/v1/magiclink", "invalid link")
+	}
+
+	if time.Now().Unix() > expiresAt {
+		return "", e.NewCustomHTTPStatus(e.StatusUnauthorized, "auth/v1/magiclink", "link expired")
+	}
+
+	return parts[0], nil
+}
@@ -0,0 +1,142 @@
+package services
+
+import (
+	"context"
+	"encoding/json"
+	"gitlab.com/grpasr/asonrythme/auth_svc/internal/models"
+	"gitlab.com/grpasr/common/tests"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+const magicLinkEmail = "magic@example.com"
+
+// mailerMock keep the sent emails, implement the IMailer
+type mailerMock struct {
+	bodies []string
+	sync.Mutex
+}
+
+func (m *mailerMock) MailerSend(to, subject, body string) error {
+	m.Lock()
+	m.bodies = append(m.bodies, body)
+	m.Unlock()
+	return nil
+}
+
+func (m *mailerMock) lastToken(t *testing.T) string {
+	m.Lock()
+	defer m.Unlock()
+	if len(m.bodies) == 0 {
+		t.Fatal("no email sent")
+	}
+	body := m.bodies[len(m.bodies)-1]
+	u, err := url.Parse(strings.TrimSpace(body[strings.Index(body, "http"):]))
+	if err != nil {
+		t.Fatal("parse link failed: ", err)
+	}
+	return u.Query().Get("token")
+}
+
+func newTestMagicLinkService(maxPerTTL int) (IMagicLinkService, *mailerMock) {
+	pkcePolicy := NewPKCEPolicy(map[string]string{idvar: codeChallengeMethodS256})
+	m := &mailerMock{}
+	return NewMagicLinkService(srv, repos, pkcePolicy, tenantPolicy, m, MagicLinkConfig{
+		URL:       "http://localhost:80/magiclink",
+		Secret:    []byte("magicLinkSecret"),
+		TTL:       15 * time.Minute,
+		MaxPerTTL: maxPerTTL,
+	}), m
+}
+
+func newMagicLinkRequest(t *testing.T, email string) *http.Request {
+	formValues := url.Values{}
+	formValues.Set("email", email)
+
+	req, err := http.NewRequest("POST", "/magiclink?"+queryParamsClient.Encode(), strings.NewReader(formValues.Encode()))
+	if err != nil {
+		t.Fatal("error making POST request: ", err)
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	return req
+}
+
+func TestMagicLinkSignin(t *testing.T) {
+	tests.MaybeFail = tests.InitFailFunc(t)
+
+	_ = repos.UserCreate(context.Background(), tenantvar, magicLinkEmail, models.UserDatas{Email: magicLinkEmail, Role: "user"})
+	defer repos.UserDelete(context.Background(), tenantvar, magicLinkEmail)
+	defer repos.RedisUserReset()
+
+	ml, m := newTestMagicLinkService(3)
+
+	ce := ml.MagicLinkRequestService(newMagicLinkRequest(t, magicLinkEmail))
+	token := m.lastToken(t)
+	tests.MaybeFail("MagicLinkRequestService", ce, tests.Expect(len(m.bodies), 1))
+
+	req, _ := http.NewRequest("GET", "/magiclink/callback?token="+url.QueryEscape(token), nil)
+	recorder := httptest.NewRecorder()
+	ce = ml.MagicLinkCallbackService(recorder, req)
+	response := recorder.Result()
+
+	var codeBody CodeBody
+	err := json.NewDecoder(response.Body).Decode(&codeBody)
+	tests.MaybeFail("MagicLinkCallbackService", ce, err,
+		tests.Expect(response.StatusCode, http.StatusOK),
+		tests.Expect(len(codeBody.Code), 48))
+
+	redisUser, err := repos.RedisUserGet(context.Background(), models.TenantKey(tenantvar, magicLinkEmail))
+	tests.MaybeFail("MagicLinkCallbackService_path", err, tests.Expect(redisUser.Path, "magiclink"))
+
+	// the link works once
+	req, _ = http.NewRequest("GET", "/magiclink/callback?token="+url.QueryEscape(token), nil)
+	ce = ml.MagicLinkCallbackService(httptest.NewRecorder(), req)
+	tests.MaybeFail("MagicLinkCallbackService_reuse", tests.Expect(ce.GetCode(), http.StatusForbidden))
+}
+
+func TestMagicLinkInvalidToken(t *testing.T) {
+	tests.MaybeFail = tests.InitFailFunc(t)
+
+	ml, _ := newTestMagicLinkService(3)
+	other, _ := newTestMagicLinkService(3)
+
+	// signed with the right secret but expired
+	expired := ml.(*MagicLinkService).sign("linkID", time.Now().Add(-time.Minute).Unix())
+	req, _ := http.NewRequest("GET", "/magiclink/callback?token="+url.QueryEscape(expired), nil)
+	ce := ml.MagicLinkCallbackService(httptest.NewRecorder(), req)
+	tests.MaybeFail("MagicLinkCallbackService_expired", tests.Expect(ce.GetCode(), http.StatusUnauthorized))
+
+	// signed with an other secret
+	other.(*MagicLinkService).cfg.Secret = []byte("otherSecret")
+	forged := other.(*MagicLinkService).sign("linkID", time.Now().Add(time.Minute).Unix())
+	req, _ = http.NewRequest("GET", "/magiclink/callback?token="+url.QueryEscape(forged), nil)
+	ce = ml.MagicLinkCallbackService(httptest.NewRecorder(), req)
+	tests.MaybeFail("MagicLinkCallbackService_forged", tests.Expect(ce.GetCode(), http.StatusForbidden))
+}
+
+func TestMagicLinkRateLimitAndUnknownEmail(t *testing.T) {
+	tests.MaybeFail = tests.InitFailFunc(t)
+
+	email := "ratelimit@example.com"
+	_ = repos.UserCreate(context.Background(), tenantvar, email, models.UserDatas{Email: email, Role: "user"})
+	defer repos.UserDelete(context.Background(), tenantvar, email)
+
+	ml, m := newTestMagicLinkService(1)
+
+	ce := ml.MagicLinkRequestService(newMagicLinkRequest(t, email))
+	tests.MaybeFail("MagicLinkRequestService_first", ce)
+
+	ce = ml.MagicLinkRequestService(newMagicLinkRequest(t, email))
+	tests.MaybeFail("MagicLinkRequestService_limited",
+		tests.Expect(ce.GetCode(), http.StatusTooManyRequests),
+		tests.Expect(len(m.bodies), 1))
+
+	// no error to not disclose the account, but no email
+	ce = ml.MagicLinkRequestService(newMagicLinkRequest(t, "unknown@example.com"))
+	tests.MaybeFail("MagicLinkRequestService_unknown", ce, tests.Expect(len(m.bodies), 1))
+}
@@ -0,0 +1,130 @@
+package services
+
+import (
+	"context"
+	e "gitlab.com/grpasr/common/errors/json"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/metric/global"
+	"go.opentelemetry.io/otel/metric/instrument"
+	"net/http"
+	"time"
+)
+
+const metricsScope = "gitlab.com/grpasr/asonrythme/auth_svc"
+
+// token operations, the operation attribute of the token metrics
+const (
+	tokenOperationIssue      = "issue"
+	tokenOperationRefresh    = "refresh"
+	tokenOperationValidate   = "validate"
+	tokenOperationGetdata    = "getdata"
+	tokenOperationPermission = "permission"
+)
+
+// authMetrics are the counters and latencies of the signin, signup and
+// token operations, the instruments come from the global meter provider
+// so they are noop until obs.Metrics.SetupMetrics set the exporter
+type authMetrics struct {
+	signinCount   instrument.Int64Counter
+	signinLatency instrument.Float64Histogram
+	signupCount   instrument.Int64Counter
+	signupLatency instrument.Float64Histogram
+	tokenCount    instrument.Int64Counter
+	tokenLatency  instrument.Float64Histogram
+}
+
+var metrics = newAuthMetrics()
+
+func newAuthMetrics() *authMetrics {
+	meter := global.Meter(metricsScope)
+
+	// the errors are ignored, an instrument failing to be created is noop
+	m := &authMetrics{}
+	m.signinCount, _ = meter.Int64Counter("auth.signin.requests",
+		instrument.WithDescription("number of signin, by outcome"))
+	m.signinLatency, _ = meter.Float64Histogram("auth.signin.duration",
+		instrument.WithDescription("signin latency in seconds"))
+	m.signupCount, _ = meter.Int64Counter("auth.signup.requests",
+		instrument.WithDescription("number of signup, by outcome"))
+	m.signupLatency, _ = meter.Float64Histogram("auth.signup.duration",
+		instrument.WithDescription("signup latency in seconds"))
+	m.tokenCount, _ = meter.Int64Counter("auth.token.requests",
+		instrument.WithDescription("number of token operations, by operation and outcome"))
+	m.tokenLatency, _ = meter.Float64Histogram("auth.token.duration",
+		instrument.WithDescription("token operations latency in seconds"))
+
+	return m
+}
+
+func (m *authMetrics) recordSignin(ctx context.Context, start time.Time, status int) {
+	m.record(ctx, m.signinCount, m.signinLatency, start, outcomeAttributes(status)...)
+}
+
+func (m *authMetrics) recordSignup(ctx context.Context, start time.Time, status int) {
+	m.record(ctx, m.signupCount, m.signupLatency, start, outcomeAttributes(status)...)
+}
+
+func (m *authMetrics) recordToken(ctx context.Context, operation string, start time.Time, status int) {
+	attrs := append(outcomeAttributes(status), attribute.String("operation", operation))
+	m.record(ctx, m.tokenCount, m.tokenLatency, start, attrs...)
+}
+
+func (m *authMetrics) record(ctx context.Context, c instrument.Int64Counter, h instrument.Float64Histogram, start time.Time, attrs ...attribute.KeyValue) {
+	if c != nil {
+		c.Add(ctx, 1, attrs...)
+	}
+	if h != nil {
+		h.Record(ctx, time.Since(start).Seconds(), attrs...)
+	}
+}
+
+func outcomeAttributes(status int) []attribute.KeyValue {
+	outcome := "success"
+	if status >= http.StatusBadRequest {
+		outcome = "failure"
+	}
+	return []attribute.KeyValue{
+		attribute.String("outcome", outcome),
+		attribute.Int("status", status),
+	}
+}
+
+// statusOf is the http status of a service's result
+func statusOf(ce e.IError) int {
+	if ce == nil {
+		return http.StatusOK
+	}
+	return ce.GetCode()
+}
+
+// statusWriter keep the status written by the services answering
+// directly on the ResponseWriter
+type statusWriter struct {
+	http.ResponseWriter
+	status int
+}
+
+func (s *statusWriter) WriteHeader(code int) {
+	if s.status == 0 {
+		s.status = code
+	}
+	s.ResponseWriter.WriteHeader(code)
+}
+
+func (s *statusWriter) Write(b []byte) (int, error) {
+	if s.status == 0 {
+		s.status = http.StatusOK
+	}
+	return s.ResponseWriter.Write(b)
+}
+
+// result is the status of a service returning ce, or answering on s
+func (s *statusWriter) result(ce e.IError) int {
+	if ce != nil {
+		return ce.GetCode()
+	}
+	if s.status == 0 {
+		return http.StatusOK
+	}
+	return s.status
+}
@@ -21,14 +21,20 @@ type IOauth2Service interface {
 }
 
 type Oauth2Service struct {
-	srv   *server.Server
-	repos *repository.Repository
+	srv      *server.Server
+	repos    *repository.Repository
+	tenants  *TenantPolicy
+	keys     IKeyRing
+	webhooks IWebhookPublisher
 }
 
-func NewOauth2Service(sv *server.Server, rp *repository.Repository) IOauth2Service {
+func NewOauth2Service(sv *server.Server, rp *repository.Repository, t *TenantPolicy, k IKeyRing, wh IWebhookPublisher) IOauth2Service {
 	return &Oauth2Service{
-		srv:   sv,
-		repos: rp,
+		srv:      sv,
+		repos:    rp,
+		tenants:  t,
+		keys:     k,
+		webhooks: wh,
 	}
 }
 
@@ -47,7 +53,9 @@ func (o *Oauth2Service) UserAuthorizeService(w http.ResponseWriter, r *http.Requ
 			return "", e.NewCustomHTTPStatus(e.StatusForbidden)
 		}
 
-		uid, err := o.repos.TemporaryGet(fmt.Sprintf("LoggedInUserID-%v", email))
+		// the user is logged in within the tenant of the client
+		key := fmt.Sprintf("LoggedInUserID-%v", models.TenantKey(o.tenants.TenantOf(r.Context(), clientID), email))
+		uid, err := o.repos.TemporaryGet(key)
 		if err != nil {
 			obs.Logging.NewLogHandler(obs.Logging.LLHError()).
 				Err(err).
@@ -55,7 +63,7 @@ func (o *Oauth2Service) UserAuthorizeService(w http.ResponseWriter, r *http.Requ
 			return "", e.NewCustomHTTPStatus(e.StatusForbidden)
 		}
 
-		_ = o.repos.TemporaryDelete(fmt.Sprintf("LoggedInUserID-%v", email))
+		_ = o.repos.TemporaryDelete(key)
 
 		obs.Logging.NewLogHandler(obs.Logging.LLHDebug()).
 			Msg("UserAuthorizeService - exit successfully")
@@ -92,6 +100,10 @@ func (o *Oauth2Service) UserCustomizeTokenPayloadService(r *http.Request, data m
 	obs.Logging.NewLogHandler(obs.Logging.LLHDebug()).
 		Msgf("UserCustomizeTokenPayloadService - hit service", r)
 
+	ctx, span := obs.Tracing.SPNGetFromCTX(r.Context(), "authSvc_userCustomizeTokenPayload",
+		obs.Tracing.TAString("function", "UserCustomizeTokenPayloadService"))
+	defer span.End()
+
 	refreshToken, ok := data["refresh_token"].(string)
 	if !ok {
 		obs.Logging.NewLogHandler(obs.Logging.LLHError()).
@@ -129,6 +141,12 @@ func (o *Oauth2Service) UserCustomizeTokenPayloadService(r *http.Request, data m
 			Msg("UserCustomizeTokenPayloadService - path missing")
 		return e.NewCustomHTTPStatus(e.StatusBadRequest, "", "unfound path"), nil
 	}
+	var tenant string = r.FormValue("tenant")
+	if tenant == "" {
+		obs.Logging.NewLogHandler(obs.Logging.LLHError()).
+			Msg("UserCustomizeTokenPayloadService - tenant missing")
+		return e.NewCustomHTTPStatus(e.StatusBadRequest, "", "unfound tenant"), nil
+	}
 
 	switch role {
 	case "APIserver":
@@ -136,12 +154,13 @@ func (o *Oauth2Service) UserCustomizeTokenPayloadService(r *http.Request, data m
 		case "apiauth":
 			apiSvc := models.APIserverDatas{}
 			apiSvc.ServiceID = subject
+			apiSvc.Tenant = tenant
 			apiSvc.RefreshTK = refreshToken
 			apiSvc.RefreshJWT = jwtRefreshToken
 			apiSvc.Role = role
 
 			// save to database
-			err := o.repos.APIserverCreate(subject, apiSvc)
+			err := o.repos.APIserverCreate(ctx, subject, apiSvc)
 			if err != nil {
 				obs.Logging.NewLogHandler(obs.Logging.LLHError()).
 					Err(err).
@@ -149,7 +168,7 @@ func (o *Oauth2Service) UserCustomizeTokenPayloadService(r *http.Request, data m
 				return e.NewCustomHTTPStatus(e.StatusInternalServerError), nil
 			}
 		case "refreshopenid":
-			apiSvc, err := o.repos.APIserverGetByID(subject)
+			apiSvc, err := o.repos.APIserverGetByID(ctx, subject)
 			if err != nil {
 				obs.Logging.NewLogHandler(obs.Logging.LLHError()).
 					Err(err).
@@ -161,7 +180,7 @@ func (o *Oauth2Service) UserCustomizeTokenPayloadService(r *http.Request, data m
 			apiSvc.RefreshJWT = jwtRefreshToken
 
 			// save to database
-			err = o.repos.APIserverUpdate(subject, apiSvc)
+			err = o.repos.APIserverUpdate(ctx, subject, apiSvc)
 			if err != nil {
 				obs.Logging.NewLogHandler(obs.Logging.LLHError()).
 					Err(err).
@@ -176,9 +195,12 @@ func (o *Oauth2Service) UserCustomizeTokenPayloadService(r *http.Request, data m
 			var password string = r.FormValue("password")
 
 			user := models.UserDatas{}
+			user.Tenant = tenant
 			user.Email = subject
 			user.Password = password
 			user.Role = role
+			// the role set by UserOpenidService, never the one of the form
+			user.TenantRole, _ = data["tenant_role"].(string)
 			user.RefreshTK = refreshToken
 			user.RefreshJWT = jwtRefreshToken
 			user.Name = data["name"].(string)
@@ -191,14 +213,9 @@ func (o *Oauth2Service) UserCustomizeTokenPayloadService(r *http.Request, data m
 			user.EmailValidationCode = randomNumberString
 			user.IsEmailValidated = 0
 
-			// check a second time if userEmail exist, it may have been created
-			err := o.repos.UserIsEmailExist(subject)
-			if err != nil {
-				return err, nil
-			}
-
 			// save to database, return an err in case it fails, or email exist
-			err = o.repos.UserCreate(subject, user)
+			// (the unique index on users.tenant and email reject the duplicates)
+			err := o.repos.UserCreate(ctx, tenant, subject, user)
 			if err != nil {
 				obs.Logging.NewLogHandler(obs.Logging.LLHError()).
 					Err(err).
@@ -209,13 +226,15 @@ func (o *Oauth2Service) UserCustomizeTokenPayloadService(r *http.Request, data m
 				return err, nil
 			}
 
-			// TODO delete
-			obs.Logging.NewLogHandler(obs.Logging.LLHDebug()).
-				Msgf("UserCustomizeTokenPayloadService - signup - see the emailValidationCode", randomNumberString)
+			o.webhooks.WebhookPublish(WebhookEventUserSignup, map[string]string{
+				"email":  subject,
+				"name":   user.Name,
+				"tenant": tenant,
+			})
 
-		case "signin":
+		case "signin", "magiclink":
 			// update the user as new tokens has been provided
-			err := o.repos.UserUpdateTokens(subject, refreshToken, jwtRefreshToken)
+			err := o.repos.UserUpdateTokens(ctx, tenant, subject, refreshToken, jwtRefreshToken)
 			if err != nil {
 				obs.Logging.NewLogHandler(obs.Logging.LLHError()).
 					Err(err).
@@ -225,7 +244,7 @@ func (o *Oauth2Service) UserCustomizeTokenPayloadService(r *http.Request, data m
 
 		case "refreshopenid":
 			// get the user from db
-			user, err := o.repos.UserGetByEmail(subject)
+			user, err := o.repos.UserGetByEmail(ctx, tenant, subject)
 			if err != nil {
 				obs.Logging.NewLogHandler(obs.Logging.LLHError()).
 					Err(err).
@@ -237,7 +256,7 @@ func (o *Oauth2Service) UserCustomizeTokenPayloadService(r *http.Request, data m
 			user.RefreshJWT = jwtRefreshToken
 
 			// update database, return an err in case it fails
-			err = o.repos.UserUpdate(subject, user)
+			err = o.repos.UserUpdate(ctx, tenant, subject, user)
 			if err != nil {
 				obs.Logging.NewLogHandler(obs.Logging.LLHError()).
 					Err(err).
@@ -260,13 +279,16 @@ func (o *Oauth2Service) UserOpenidService(w http.ResponseWriter, r *http.Request
 	obs.Logging.NewLogHandler(obs.Logging.LLHDebug()).
 		Msgf("UserOpenidService - hit service", r)
 
+	ctx, span := obs.Tracing.SPNGetFromCTX(r.Context(), "authSvc_userOpenid",
+		obs.Tracing.TAString("function", "UserOpenidService"))
+	defer span.End()
+
 	// role[0] is the one register within the tokenInfo
 	// within the code req
 
 	err = nil
 
-	keyID = "theKeyID"
-	secretKey = "mySecretKey"
+	keyID, secretKey = o.keys.KeyRingCurrent()
 	encoding = "HS256"
 
 	// create the data we like to set into the jwt token
@@ -288,9 +310,15 @@ func (o *Oauth2Service) UserOpenidService(w http.ResponseWriter, r *http.Request
 		return nil, "", "", "", e.NewCustomHTTPStatus(e.StatusBadRequest)
 	}
 
+	// the tenant of the client which request the token, it is set(not
+	// added) so a tenant sent within the form is ignored
+	tenant := o.tenants.TenantOfRequest(r)
+	jwtInfo["tenant"] = tenant
+	r.Form.Set("tenant", tenant)
+
 	switch roleFromForm {
 	case "user":
-		user, err := o.repos.RedisUserGet(subject)
+		user, err := o.repos.RedisUserGet(ctx, models.TenantKey(tenant, subject))
 		if err != nil {
 			obs.Logging.NewLogHandler(obs.Logging.LLHError()).
 				Err(err).
@@ -299,7 +327,7 @@ func (o *Oauth2Service) UserOpenidService(w http.ResponseWriter, r *http.Request
 			return nil, "", "", "", e.NewCustomHTTPStatus(e.StatusInternalServerError)
 		}
 
-		_ = o.repos.RedisUserDelete(subject)
+		_ = o.repos.RedisUserDelete(ctx, models.TenantKey(tenant, subject))
 
 		switch user.Path {
 		case "signup":
@@ -308,13 +336,27 @@ func (o *Oauth2Service) UserOpenidService(w http.ResponseWriter, r *http.Request
 			jwtInfo["age"] = "35"
 			jwtInfo["city"] = "London"
 
-			// data which will be needed in the UserCustomizeTokenPayloadService
-			r.Form.Add("password", user.Password)
-			r.Form.Add("path", user.Path)
+			// the invitation is used now the code is exchanged, its role
+			// is the user's one
+			if user.InvitationID != "" {
+				inv, err := o.repos.InvitationConsume(ctx, user.InvitationID)
+				if err != nil {
+					obs.Logging.NewLogHandler(obs.Logging.LLHError()).
+						Err(err).
+						Msg(fmt.Sprintf("UserOpenidService - invitation %v of %v used, revoked or expired", user.InvitationID, subject))
+					return nil, "", "", "", e.NewCustomHTTPStatus(e.StatusForbidden)
+				}
+				jwtInfo["tenant_role"] = inv.Role
+			}
+
+			// data which will be needed in the UserCustomizeTokenPayloadService,
+			// they are set so the values sent within the form are ignored
+			r.Form.Set("password", user.Password)
+			r.Form.Set("path", user.Path)
 
-		case "signin":
+		case "signin", "magiclink":
 			// get data from db
-			userDT, err := o.repos.UserGetByEmail(subject)
+			userDT, err := o.repos.UserGetByEmail(ctx, tenant, subject)
 			if err != nil {
 				obs.Logging.NewLogHandler(obs.Logging.LLHError()).
 					Err(err).
@@ -323,19 +365,28 @@ func (o *Oauth2Service) UserOpenidService(w http.ResponseWriter, r *http.Request
 				return nil, "", "", "", e.NewCustomHTTPStatus(e.StatusInternalServerError)
 			}
 
+			if userDT.IsDisabled == 1 {
+				obs.Logging.NewLogHandler(obs.Logging.LLHError()).
+					Msg(fmt.Sprintf("UserOpenidService - subject %v is disabled", subject))
+				return nil, "", "", "", e.NewCustomHTTPStatus(e.StatusForbidden)
+			}
+
 			// TODO assert the password(user.Password) with the one saved in db
 
 			// set data for token
 			jwtInfo["name"] = userDT.Name
 			jwtInfo["age"] = userDT.Age
 			jwtInfo["city"] = userDT.City
+			if userDT.TenantRole != "" {
+				jwtInfo["tenant_role"] = userDT.TenantRole
+			}
 
 			// data which will be needed in the UserCustomizeTokenPayloadService
-			r.Form.Add("path", user.Path)
+			r.Form.Set("path", user.Path)
 		}
 
 	case "APIserver":
-		svcInfo, err := o.repos.RedisAPIserverGet(subject)
+		svcInfo, err := o.repos.RedisAPIserverGet(ctx, subject)
 		if err != nil {
 			obs.Logging.NewLogHandler(obs.Logging.LLHError()).
 				Err(err).
@@ -344,13 +395,13 @@ func (o *Oauth2Service) UserOpenidService(w http.ResponseWriter, r *http.Request
 			return nil, "", "", "", e.NewCustomHTTPStatus(e.StatusInternalServerError)
 		}
 
-		_ = o.repos.RedisAPIserverDelete(subject)
+		_ = o.repos.RedisAPIserverDelete(ctx, subject)
 
 		// datas which will be in the token, 'role' will be add behind the scene
 		jwtInfo["service_id"] = svcInfo.ServiceID
 
 		// data which will be needed in the UserCustomizeTokenPayloadService
-		r.Form.Add("path", svcInfo.Path)
+		r.Form.Set("path", svcInfo.Path)
 	}
 
 	obs.Logging.NewLogHandler(obs.Logging.LLHDebug()).
@@ -415,7 +466,7 @@ func (o *Oauth2Service) UserOpenidService(w http.ResponseWriter, r *http.Request
 // 			switch job.role {
 // 			case "user":
 // 				// Delete user record
-// 				// o.repos.RedisUserDelete(job.subject)
+// 				// o.repos.RedisUserDelete(ctx, job.subject)
 // 			case "APIserver":
 // 				// Delete API server record
 // 				// o.repos.RedisAPIserviceDelete(job.subject)
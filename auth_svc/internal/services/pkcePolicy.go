@@ -0,0 +1,90 @@
+package services
+
+import (
+	"fmt"
+	e "gitlab.com/grpasr/common/errors/json"
+	obs "gitlab.com/grpasr/common/observability"
+	"regexp"
+	"strings"
+)
+
+const (
+	codeChallengeMethodS256  = "S256"
+	codeChallengeMethodPlain = "plain"
+)
+
+// an S256 challenge is the base64url(without padding) of a sha256, so 43 chars
+var s256ChallengeRegexp = regexp.MustCompile(`^[A-Za-z0-9_-]{43}$`)
+
+// a plain challenge is the verifier itself, see rfc7636 section 4.1
+var plainChallengeRegexp = regexp.MustCompile(`^[A-Za-z0-9._~-]{43,128}$`)
+
+// PKCEPolicy hold, for each client_id, the code_challenge_method it must use
+// any client which is not registered falls back to S256
+type PKCEPolicy struct {
+	methods map[string]string
+}
+
+func NewPKCEPolicy(methods map[string]string) *PKCEPolicy {
+	p := &PKCEPolicy{methods: make(map[string]string, len(methods))}
+	for clientID, method := range methods {
+		p.methods[clientID] = method
+	}
+	return p
+}
+
+func (p *PKCEPolicy) getMethod(clientID string) string {
+	method, ok := p.methods[clientID]
+	if !ok || method == "" {
+		return codeChallengeMethodS256
+	}
+	return method
+}
+
+// PKCEValidate make sure the code_challenge of an authorization request
+// match the policy of the client, path is used for the error response
+func (p *PKCEPolicy) PKCEValidate(clientID, challenge, method, path string) e.IError {
+	if challenge == "" {
+		obs.Logging.NewLogHandler(obs.Logging.LLHError()).
+			Msg(fmt.Sprintf("PKCEValidate - %v code_challenge missing", clientID))
+		return e.NewCustomHTTPStatus(e.StatusBadRequest, path, "code_challenge is required")
+	}
+
+	// rfc7636 default the method to plain
+	if method == "" {
+		method = codeChallengeMethodPlain
+	}
+
+	switch p.getMethod(clientID) {
+	case codeChallengeMethodPlain:
+		// legacy clients may use plain, S256 is still accepted
+		if method != codeChallengeMethodPlain && method != codeChallengeMethodS256 {
+			return p.invalidMethod(clientID, method, path)
+		}
+	default:
+		if method != codeChallengeMethodS256 {
+			return p.invalidMethod(clientID, method, path)
+		}
+	}
+
+	var ok bool
+	switch method {
+	case codeChallengeMethodS256:
+		ok = s256ChallengeRegexp.MatchString(strings.TrimRight(challenge, "="))
+	case codeChallengeMethodPlain:
+		ok = plainChallengeRegexp.MatchString(challenge)
+	}
+	if !ok {
+		obs.Logging.NewLogHandler(obs.Logging.LLHError()).
+			Msg(fmt.Sprintf("PKCEValidate - %v malformed code_challenge", clientID))
+		return e.NewCustomHTTPStatus(e.StatusBadRequest, path, "code_challenge is malformed")
+	}
+
+	return nil
+}
+
+func (p *PKCEPolicy) invalidMethod(clientID, method, path string) e.IError {
+	obs.Logging.NewLogHandler(obs.Logging.LLHError()).
+		Msg(fmt.Sprintf("PKCEValidate - %v code_challenge_method %v not allowed", clientID, method))
+	return e.NewCustomHTTPStatus(e.StatusBadRequest, path, "code_challenge_method must be S256")
+}
@@ -0,0 +1,64 @@
+package services
+
+import (
+	"gitlab.com/grpasr/common/tests"
+	"net/http"
+	"testing"
+)
+
+func TestPKCEPolicyAcceptS256(t *testing.T) {
+	tests.MaybeFail = tests.InitFailFunc(t)
+
+	p := NewPKCEPolicy(map[string]string{idvar: codeChallengeMethodS256})
+
+	ce := p.PKCEValidate(idvar, s256ChallengeHash, codeChallengeMethodS256, "auth/v1/signin")
+
+	tests.MaybeFail("PKCEValidate_S256", tests.Expect(ce == nil, true))
+}
+
+func TestPKCEPolicyRejectMissingChallenge(t *testing.T) {
+	tests.MaybeFail = tests.InitFailFunc(t)
+
+	p := NewPKCEPolicy(map[string]string{idvar: codeChallengeMethodS256})
+
+	ce := p.PKCEValidate(idvar, "", "", "auth/v1/signin")
+
+	tests.MaybeFail("PKCEValidate_missing", tests.Expect(ce.GetCode(), http.StatusBadRequest))
+}
+
+func TestPKCEPolicyRejectPlain(t *testing.T) {
+	tests.MaybeFail = tests.InitFailFunc(t)
+
+	p := NewPKCEPolicy(map[string]string{idvar: codeChallengeMethodS256})
+
+	ce := p.PKCEValidate(idvar, codeVerifier, codeChallengeMethodPlain, "auth/v1/signin")
+	tests.MaybeFail("PKCEValidate_plain", tests.Expect(ce.GetCode(), http.StatusBadRequest))
+
+	// no method means plain
+	ce = p.PKCEValidate(idvar, codeVerifier, "", "auth/v1/signin")
+	tests.MaybeFail("PKCEValidate_no_method", tests.Expect(ce.GetCode(), http.StatusBadRequest))
+}
+
+func TestPKCEPolicyUnknownClientDefaultToS256(t *testing.T) {
+	tests.MaybeFail = tests.InitFailFunc(t)
+
+	p := NewPKCEPolicy(map[string]string{})
+
+	ce := p.PKCEValidate("unknown", codeVerifier, codeChallengeMethodPlain, "auth/v1/apiauth")
+	tests.MaybeFail("PKCEValidate_unknown_plain", tests.Expect(ce.GetCode(), http.StatusBadRequest))
+
+	ce = p.PKCEValidate("unknown", s256ChallengeHash, codeChallengeMethodS256, "auth/v1/apiauth")
+	tests.MaybeFail("PKCEValidate_unknown_S256", tests.Expect(ce == nil, true))
+}
+
+func TestPKCEPolicyPlainClient(t *testing.T) {
+	tests.MaybeFail = tests.InitFailFunc(t)
+
+	p := NewPKCEPolicy(map[string]string{"legacy": codeChallengeMethodPlain})
+
+	ce := p.PKCEValidate("legacy", codeVerifier, codeChallengeMethodPlain, "auth/v1/signin")
+	tests.MaybeFail("PKCEValidate_legacy_plain", tests.Expect(ce == nil, true))
+
+	ce = p.PKCEValidate("legacy", "short", codeChallengeMethodPlain, "auth/v1/signin")
+	tests.MaybeFail("PKCEValidate_legacy_short", tests.Expect(ce.GetCode(), http.StatusBadRequest))
+}
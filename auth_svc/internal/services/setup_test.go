@@ -35,6 +35,9 @@ var (
 	authService   IAuthenticationService
 	oauth2Service IOauth2Service
 	tokenService  ITokenService
+	webhookSvc    IWebhookService
+	keyRing       *KeyRing
+	tenantPolicy  *TenantPolicy
 
 	clientID          = "111111"
 	clientSecret      = "11111111"
@@ -55,6 +58,7 @@ const (
 	idvar     string = "222222"
 	secretvar string = "22222222"
 	domainvar string = "http://localhost:80"
+	tenantvar string = "default"
 
 	// credential for the preOrder service
 	orderID     string = "order"
@@ -99,16 +103,36 @@ func TestMain(m *testing.M) {
 	// manager.MapClientStorage(clientStore(csrv.URL, true))
 
 	repos = &repository.Repository{
-		ITemporaryStore: repository.NewTmpStore(),
-		IUserStore:      repository.NewUserStoreMock(),
-		IAPIserverStore: repository.NewAPIserverStoreMock(),
-		IRedisStore:     repository.NewRedisMock()}
+		ITemporaryStore:    repository.NewTmpStore(),
+		IUserStore:         repository.NewUserStoreMock(),
+		IAPIserverStore:    repository.NewAPIserverStoreMock(),
+		IRedisStore:        repository.NewRedisMock(),
+		IMagicLinkStore:    repository.NewMagicLinkStoreMock(),
+		IWebhookStore:      repository.NewWebhookStoreMock(),
+		IClientTenantStore: repository.NewClientTenantStoreMock(),
+		IInvitationStore:   repository.NewInvitationStoreMock()}
 	srv = server.NewServer(server.NewConfig(), manager)
 	srv.SetModeAPI()
 
-	authService = NewAuthenticationService(srv, repos)
-	oauth2Service = NewOauth2Service(srv, repos)
-	tokenService = NewTokenService(srv, repos)
+	pkcePolicy := NewPKCEPolicy(map[string]string{
+		idvar:       codeChallengeMethodS256,
+		brokerSvcID: codeChallengeMethodS256,
+	})
+
+	tenantPolicy = NewTenantPolicy(map[string]string{
+		idvar:       "default",
+		brokerSvcID: "default",
+	}, repos)
+
+	signupPolicy, _ := NewSignupPolicy(SignupPolicyConfig{Open: true, PasswordMinLength: 8, BlockDisposable: true})
+	invitationSvc := NewInvitationService(repos, 24*time.Hour)
+
+	keyRing = NewKeyRing(keyID, secretKey)
+
+	webhookSvc = NewWebhookService(repos, WebhookConfig{})
+	authService = NewAuthenticationService(srv, repos, pkcePolicy, signupPolicy, invitationSvc, tenantPolicy, keyRing, webhookSvc)
+	oauth2Service = NewOauth2Service(srv, repos, tenantPolicy, keyRing, webhookSvc)
+	tokenService = NewTokenService(srv, repos, keyRing)
 
 	// set the handler functions
 	srv.SetUserAuthorizationHandler(oauth2Service.UserAuthorizeService)
@@ -0,0 +1,297 @@
+package services
+
+import (
+	"bufio"
+	"crypto/sha1"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	e "gitlab.com/grpasr/common/errors/json"
+	obs "gitlab.com/grpasr/common/observability"
+	"os"
+	"regexp"
+	"strings"
+	"unicode"
+)
+
+// the password character classes
+const (
+	passwordClassLower  = "lower"
+	passwordClassUpper  = "upper"
+	passwordClassDigit  = "digit"
+	passwordClassSymbol = "symbol"
+)
+
+// an email is local@domain, the domain having at least a dot
+var emailRegexp = regexp.MustCompile(`^[^@\s]{1,64}@[a-z0-9]([a-z0-9-]*[a-z0-9])?(\.[a-z0-9]([a-z0-9-]*[a-z0-9])?)+$`)
+
+// the disposable domains blocked by default, SignupPolicyConfig.DisposableFile add more
+var disposableDomainsDefault = []string{
+	"10minutemail.com",
+	"discard.email",
+	"dispostable.com",
+	"getnada.com",
+	"guerrillamail.com",
+	"mailinator.com",
+	"maildrop.cc",
+	"mohmal.com",
+	"sharklasers.com",
+	"temp-mail.org",
+	"tempmail.com",
+	"throwawaymail.com",
+	"trashmail.com",
+	"yopmail.com",
+}
+
+// FieldError is the violation of a rule by one of the signup form's fields
+type FieldError struct {
+	Field   string `json:"field"`
+	Code    string `json:"code"`
+	Message string `json:"message"`
+}
+
+// ValidationError is a Bad Request carrying the field errors, they are
+// added to the json payload of the error
+type ValidationError struct {
+	e.IError
+	Fields []FieldError
+}
+
+func (v *ValidationError) MarshalJSON() ([]byte, error) {
+	payload := map[string]interface{}{}
+	if b, err := json.Marshal(v.IError); err == nil {
+		_ = json.Unmarshal(b, &payload)
+	}
+	payload["fields"] = v.Fields
+	return json.Marshal(payload)
+}
+
+// ISignupRule is a check of the signup form, a rule return nothing if
+// the email and password comply with it
+type ISignupRule interface {
+	SignupCheck(email, password string) []FieldError
+}
+
+// SignupRuleFunc make a func an ISignupRule
+type SignupRuleFunc func(email, password string) []FieldError
+
+func (f SignupRuleFunc) SignupCheck(email, password string) []FieldError {
+	return f(email, password)
+}
+
+// SignupPolicyConfig set the builtin rules, the files hold one item per
+// line, the breached one the upper case sha1 of the passwords(the
+// haveibeenpwned format, a ":count" suffix is ignored). A closed(not
+// Open) signup require an invitation
+type SignupPolicyConfig struct {
+	Open              bool
+	PasswordMinLength int
+	PasswordClasses   []string
+	BreachedFile      string
+	AllowedDomains    []string
+	DeniedDomains     []string
+	BlockDisposable   bool
+	DisposableFile    string
+}
+
+// SignupPolicy validate the signup forms against its rules, the builtin
+// ones are set from the config, more can be plugged with SignupAddRule
+type SignupPolicy struct {
+	open  bool
+	rules []ISignupRule
+}
+
+func NewSignupPolicy(cfg SignupPolicyConfig) (*SignupPolicy, error) {
+	for _, class := range cfg.PasswordClasses {
+		switch class {
+		case passwordClassLower, passwordClassUpper, passwordClassDigit, passwordClassSymbol:
+		default:
+			return nil, fmt.Errorf("unknown password character class %v", class)
+		}
+	}
+
+	p := &SignupPolicy{open: cfg.Open}
+	p.SignupAddRule(emailFormatRule())
+	p.SignupAddRule(passwordLengthRule(cfg.PasswordMinLength))
+	p.SignupAddRule(passwordClassesRule(cfg.PasswordClasses))
+
+	if cfg.BreachedFile != "" {
+		hashes, err := readList(cfg.BreachedFile, func(line string) string {
+			hash, _, _ := strings.Cut(line, ":")
+			return strings.ToUpper(hash)
+		})
+		if err != nil {
+			return nil, err
+		}
+		p.SignupAddRule(passwordBreachedRule(hashes))
+	}
+
+	if len(cfg.AllowedDomains) > 0 || len(cfg.DeniedDomains) > 0 {
+		p.SignupAddRule(emailDomainsRule(toSet(cfg.AllowedDomains), toSet(cfg.DeniedDomains)))
+	}
+
+	if cfg.BlockDisposable {
+		disposable := toSet(disposableDomainsDefault)
+		if cfg.DisposableFile != "" {
+			domains, err := readList(cfg.DisposableFile, strings.ToLower)
+			if err != nil {
+				return nil, err
+			}
+			for domain := range domains {
+				disposable[domain] = struct{}{}
+			}
+		}
+		p.SignupAddRule(emailDisposableRule(disposable))
+	}
+
+	return p, nil
+}
+
+// SignupIsOpen is false if the signup require an invitation
+func (p *SignupPolicy) SignupIsOpen() bool {
+	return p.open
+}
+
+// SignupAddRule plug a rule, it is checked after the ones already set
+func (p *SignupPolicy) SignupAddRule(rule ISignupRule) {
+	p.rules = append(p.rules, rule)
+}
+
+// SignupValidate check all the rules, the violations of all of them are
+// returned at once so the user can fix the form in one go
+func (p *SignupPolicy) SignupValidate(email, password, path string) e.IError {
+	fields := []FieldError{}
+	for _, rule := range p.rules {
+		fields = append(fields, rule.SignupCheck(email, password)...)
+	}
+	if len(fields) == 0 {
+		return nil
+	}
+
+	obs.Logging.NewLogHandler(obs.Logging.LLHError()).
+		Msg(fmt.Sprintf("SignupValidate - %v rule(s) violated by the signup of %v", len(fields), email))
+
+	return &ValidationError{
+		IError: e.NewCustomHTTPStatus(e.StatusBadRequest, path, "the signup form is invalid"),
+		Fields: fields,
+	}
+}
+
+func emailFormatRule() ISignupRule {
+	return SignupRuleFunc(func(email, password string) []FieldError {
+		if !emailRegexp.MatchString(strings.ToLower(email)) {
+			return []FieldError{{"email", "invalid_format", "the email is not valid"}}
+		}
+		return nil
+	})
+}
+
+func passwordLengthRule(minLength int) ISignupRule {
+	return SignupRuleFunc(func(email, password string) []FieldError {
+		if len([]rune(password)) < minLength {
+			return []FieldError{{"password", "too_short",
+				fmt.Sprintf("the password must have at least %d characters", minLength)}}
+		}
+		return nil
+	})
+}
+
+func passwordClassesRule(classes []string) ISignupRule {
+	return SignupRuleFunc(func(email, password string) []FieldError {
+		has := map[string]bool{}
+		for _, r := range password {
+			switch {
+			case unicode.IsLower(r):
+				has[passwordClassLower] = true
+			case unicode.IsUpper(r):
+				has[passwordClassUpper] = true
+			case unicode.IsDigit(r):
+				has[passwordClassDigit] = true
+			default:
+				has[passwordClassSymbol] = true
+			}
+		}
+
+		fields := []FieldError{}
+		for _, class := range classes {
+			if !has[class] {
+				fields = append(fields, FieldError{"password", "missing_" + class,
+					fmt.Sprintf("the password must have a %v character", class)})
+			}
+		}
+		return fields
+	})
+}
+
+func passwordBreachedRule(hashes map[string]struct{}) ISignupRule {
+	return SignupRuleFunc(func(email, password string) []FieldError {
+		sum := sha1.Sum([]byte(password))
+		if _, ok := hashes[strings.ToUpper(hex.EncodeToString(sum[:]))]; ok {
+			return []FieldError{{"password", "breached",
+				"the password appeared in a data breach, choose another one"}}
+		}
+		return nil
+	})
+}
+
+// emailDomainsRule allow only the allowed domains if any, then deny the denied ones
+func emailDomainsRule(allowed, denied map[string]struct{}) ISignupRule {
+	return SignupRuleFunc(func(email, password string) []FieldError {
+		domain := emailDomain(email)
+		if len(allowed) > 0 {
+			if _, ok := allowed[domain]; !ok {
+				return []FieldError{{"email", "domain_not_allowed", "the email domain is not allowed"}}
+			}
+		}
+		if _, ok := denied[domain]; ok {
+			return []FieldError{{"email", "domain_denied", "the email domain is not allowed"}}
+		}
+		return nil
+	})
+}
+
+func emailDisposableRule(disposable map[string]struct{}) ISignupRule {
+	return SignupRuleFunc(func(email, password string) []FieldError {
+		if _, ok := disposable[emailDomain(email)]; ok {
+			return []FieldError{{"email", "disposable_domain", "the disposable emails are not allowed"}}
+		}
+		return nil
+	})
+}
+
+func emailDomain(email string) string {
+	return strings.ToLower(email[strings.LastIndex(email, "@")+1:])
+}
+
+func toSet(items []string) map[string]struct{} {
+	set := make(map[string]struct{}, len(items))
+	for _, item := range items {
+		set[strings.ToLower(item)] = struct{}{}
+	}
+	return set
+}
+
+// readList read the file's items, one per line, the empty lines and the
+// ones starting with # are skipped
+func readList(path string, normalize func(string) string) (map[string]struct{}, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	items := map[string]struct{}{}
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		items[normalize(line)] = struct{}{}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+
+	return items, nil
+}
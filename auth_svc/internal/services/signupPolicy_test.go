@@ -0,0 +1,105 @@
+package services
+
+import (
+	"encoding/json"
+	"gitlab.com/grpasr/common/tests"
+	"net/http"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func fieldCodes(ce interface{}) []string {
+	codes := []string{}
+	if ve, ok := ce.(*ValidationError); ok {
+		for _, f := range ve.Fields {
+			codes = append(codes, f.Field+":"+f.Code)
+		}
+	}
+	return codes
+}
+
+func TestSignupPolicyPassword(t *testing.T) {
+	tests.MaybeFail = tests.InitFailFunc(t)
+
+	// sha1("Password123")
+	breached := filepath.Join(t.TempDir(), "breached.txt")
+	_ = os.WriteFile(breached, []byte("# pwned\nB2E98AD6F6EB8508DD6A14CFA704BAD7F05F6FB1:1024\n"), 0600)
+
+	p, err := NewSignupPolicy(SignupPolicyConfig{
+		PasswordMinLength: 10,
+		PasswordClasses:   []string{passwordClassUpper, passwordClassDigit},
+		BreachedFile:      breached,
+	})
+	tests.MaybeFail("NewSignupPolicy", err)
+
+	ce := p.SignupValidate(userEmail, "Correct-Horse-42", "auth/v1/signup")
+	tests.MaybeFail("SignupValidate_ok", tests.Expect(ce == nil, true))
+
+	ce = p.SignupValidate(userEmail, "short", "auth/v1/signup")
+	codes := fieldCodes(ce)
+	tests.MaybeFail("SignupValidate_weak",
+		tests.Expect(ce.GetCode(), http.StatusBadRequest),
+		tests.Expect(len(codes), 3),
+		tests.Expect(codes[0], "password:too_short"),
+		tests.Expect(codes[1], "password:missing_upper"),
+		tests.Expect(codes[2], "password:missing_digit"))
+
+	ce = p.SignupValidate(userEmail, "Password123", "auth/v1/signup")
+	codes = fieldCodes(ce)
+	tests.MaybeFail("SignupValidate_breached", tests.Expect(len(codes), 1), tests.Expect(codes[0], "password:breached"))
+
+	_, err = NewSignupPolicy(SignupPolicyConfig{PasswordClasses: []string{"emoji"}})
+	tests.MaybeFail("NewSignupPolicy_class", tests.Expect(err != nil, true))
+}
+
+func TestSignupPolicyEmail(t *testing.T) {
+	tests.MaybeFail = tests.InitFailFunc(t)
+
+	disposable := filepath.Join(t.TempDir(), "disposable.txt")
+	_ = os.WriteFile(disposable, []byte("Burner.dev\n"), 0600)
+
+	p, err := NewSignupPolicy(SignupPolicyConfig{
+		DeniedDomains:   []string{"rival.io"},
+		BlockDisposable: true,
+		DisposableFile:  disposable,
+	})
+	tests.MaybeFail("NewSignupPolicy", err)
+
+	for email, code := range map[string]string{
+		"not-an-email":       "email:invalid_format",
+		"bob@localhost":      "email:invalid_format",
+		"bob@rival.io":       "email:domain_denied",
+		"bob@Mailinator.com": "email:disposable_domain",
+		"bob@burner.dev":     "email:disposable_domain",
+	} {
+		codes := fieldCodes(p.SignupValidate(email, "secretpassword", "auth/v1/signup"))
+		tests.MaybeFail("SignupValidate_"+email, tests.Expect(len(codes), 1), tests.Expect(codes[0], code))
+	}
+
+	// only the allowed domains may signup
+	p, _ = NewSignupPolicy(SignupPolicyConfig{AllowedDomains: []string{"example.com"}})
+	ce := p.SignupValidate(userEmail, "secretpassword", "auth/v1/signup")
+	codes := fieldCodes(p.SignupValidate("bob@other.com", "secretpassword", "auth/v1/signup"))
+	tests.MaybeFail("SignupValidate_allowed",
+		tests.Expect(ce == nil, true),
+		tests.Expect(codes[0], "email:domain_not_allowed"))
+}
+
+func TestSignupPolicyFieldsPayload(t *testing.T) {
+	tests.MaybeFail = tests.InitFailFunc(t)
+
+	p, _ := NewSignupPolicy(SignupPolicyConfig{PasswordMinLength: 20})
+	ce := p.SignupValidate(userEmail, "secretpassword", "auth/v1/signup")
+
+	b, err := json.Marshal(ce)
+	payload := struct {
+		Fields []FieldError `json:"fields"`
+	}{}
+	_ = json.Unmarshal(b, &payload)
+
+	tests.MaybeFail("SignupValidate_payload", err,
+		tests.Expect(len(payload.Fields), 1),
+		tests.Expect(payload.Fields[0].Field, "password"),
+		tests.Expect(payload.Fields[0].Code, "too_short"))
+}
@@ -0,0 +1,122 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"gitlab.com/grpasr/asonrythme/auth_svc/internal/models"
+	"gitlab.com/grpasr/asonrythme/auth_svc/internal/repository"
+	e "gitlab.com/grpasr/common/errors/json"
+	obs "gitlab.com/grpasr/common/observability"
+	"net/http"
+	"regexp"
+	"sync"
+)
+
+// a tenant is part of the keys(tenant#email), so it can not hold a '#'
+var tenantRegexp = regexp.MustCompile(`^[a-z0-9][a-z0-9_-]{0,62}$`)
+
+// TenantPolicy hold, for each client_id, the tenant its users and
+// tokens belong to, the clients created by an admin are saved in the
+// repository. Any client which is not assigned falls back to the default tenant
+type TenantPolicy struct {
+	tenants map[string]string
+	store   repository.IClientTenantStore
+	sync.RWMutex
+}
+
+func NewTenantPolicy(tenants map[string]string, store repository.IClientTenantStore) *TenantPolicy {
+	p := &TenantPolicy{
+		tenants: make(map[string]string, len(tenants)),
+		store:   store,
+	}
+	for clientID, tenant := range tenants {
+		p.tenants[clientID] = tenant
+	}
+	return p
+}
+
+// TenantOf return the tenant of the client
+func (p *TenantPolicy) TenantOf(ctx context.Context, clientID string) string {
+	p.RLock()
+	tenant, ok := p.tenants[clientID]
+	p.RUnlock()
+	if ok && tenant != "" {
+		return tenant
+	}
+
+	if clientID != "" && p.store != nil {
+		if tenant, err := p.store.ClientTenantGet(ctx, clientID); err == nil && tenant != "" {
+			p.Lock()
+			p.tenants[clientID] = tenant
+			p.Unlock()
+			return tenant
+		}
+	}
+
+	return models.TenantDefault
+}
+
+// TenantOfRequest return the tenant of the client which send r, the
+// token requests authenticate the client with basic auth
+func (p *TenantPolicy) TenantOfRequest(r *http.Request) string {
+	if clientID, _, ok := r.BasicAuth(); ok {
+		return p.TenantOf(r.Context(), clientID)
+	}
+	return p.TenantOf(r.Context(), r.Form.Get("client_id"))
+}
+
+// TenantSet assign the client to the tenant and save it
+func (p *TenantPolicy) TenantSet(ctx context.Context, clientID, tenant string) e.IError {
+	if ce := TenantValidate(tenant); ce != nil {
+		return ce
+	}
+
+	if err := p.store.ClientTenantSet(ctx, clientID, tenant); err != nil {
+		obs.Logging.NewLogHandler(obs.Logging.LLHError()).
+			Err(err).
+			Msg(fmt.Sprintf("TenantSet - save %v tenant failed", clientID))
+		return e.NewCustomHTTPStatus(e.StatusInternalServerError)
+	}
+
+	p.Lock()
+	p.tenants[clientID] = tenant
+	p.Unlock()
+
+	return nil
+}
+
+// TenantDelete forget the tenant of a revoked client
+func (p *TenantPolicy) TenantDelete(ctx context.Context, clientID string) {
+	p.Lock()
+	delete(p.tenants, clientID)
+	p.Unlock()
+
+	if err := p.store.ClientTenantDelete(ctx, clientID); err != nil {
+		obs.Logging.NewLogHandler(obs.Logging.LLHError()).
+			Err(err).
+			Msg(fmt.Sprintf("TenantDelete - delete %v tenant failed", clientID))
+	}
+}
+
+// TenantValidate make sure the tenant can be used within the keys
+func TenantValidate(tenant string) e.IError {
+	if !tenantRegexp.MatchString(tenant) {
+		return e.NewCustomHTTPStatus(e.StatusBadRequest, "", "tenant must be lowercase alphanumerics, '-' or '_'")
+	}
+	return nil
+}
+
+// tenantOrDefault return the default tenant if tenant is not set
+func tenantOrDefault(tenant string) string {
+	if tenant == "" {
+		return models.TenantDefault
+	}
+	return tenant
+}
+
+// tenantFromClaims return the tenant of the jwt's datas, the jwt
+// issued before the tenants belong to the default tenant
+func tenantFromClaims(data map[string]interface{}) string {
+	tenant, _ := data["tenant"].(string)
+	return tenantOrDefault(tenant)
+}
@@ -1,6 +1,7 @@
 package services
 
 import (
+	"bytes"
 	"context"
 	"encoding/json"
 	"fmt"
@@ -16,6 +17,7 @@ import (
 
 type ITokenService interface {
 	RefreshOpenidService(w http.ResponseWriter, r *http.Request, cookie *http.Cookie)
+	RefreshOpenid(ctx context.Context, jwt string) (string, e.IError)
 	TokenService(w http.ResponseWriter, r *http.Request, authHeader string) e.IError
 	JwtGetdataService(w http.ResponseWriter, r *http.Request, cookie *http.Cookie) (map[string]interface{}, e.IError)
 	JwtValidationService(r *http.Request, cookie *http.Cookie) e.IError
@@ -25,12 +27,14 @@ type ITokenService interface {
 type TokenService struct {
 	srv   *server.Server
 	repos *repository.Repository
+	keys  IKeyRing
 }
 
-func NewTokenService(srv *server.Server, rp *repository.Repository) ITokenService {
+func NewTokenService(srv *server.Server, rp *repository.Repository, k IKeyRing) ITokenService {
 	return &TokenService{
 		srv:   srv,
 		repos: rp,
+		keys:  k,
 	}
 }
 
@@ -39,38 +43,91 @@ func (t *TokenService) RefreshOpenidService(w http.ResponseWriter, r *http.Reque
 	obs.Logging.NewLogHandler(obs.Logging.LLHDebug()).
 		Msgf("RefreshOpenidService - hit service", r)
 
-	jwt := cookie.Value
+	if ce := t.refreshOpenid(w, r, cookie.Value); ce != nil {
+		w.WriteHeader(ce.GetCode())
+		json.NewEncoder(w).Encode(ce)
+	}
+}
+
+// RefreshOpenid refresh the jwt out of an http request(grpc), the
+// oauth2 server answer on a tokenWriter the new jwt is read from
+func (t *TokenService) RefreshOpenid(ctx context.Context, jwt string) (string, e.IError) {
+	r, err := http.NewRequestWithContext(ctx, http.MethodPost, "/v1/refreshopenid", nil)
+	if err != nil {
+		return "", e.NewCustomHTTPStatus(e.StatusInternalServerError)
+	}
+
+	tw := newTokenWriter()
+	if ce := t.refreshOpenid(tw, r, trimToken(jwt)); ce != nil {
+		return "", ce
+	}
+	if tw.status != http.StatusOK {
+		obs.Logging.NewLogHandler(obs.Logging.LLHError()).
+			Msg(fmt.Sprintf("RefreshOpenid - refresh failed with status %v", tw.status))
+		return "", e.NewCustomHTTPStatus(e.StatusForbidden)
+	}
+
+	token := trimToken(tw.body.String())
+	if token == "" {
+		return "", e.NewCustomHTTPStatus(e.StatusInternalServerError)
+	}
+
+	return token, nil
+}
+
+// refreshOpenid is the refresh flow shared by /v1/refreshopenid and the
+// grpc RefreshToken, the new jwt is written on w by the oauth2 server
+func (t *TokenService) refreshOpenid(w http.ResponseWriter, r *http.Request, jwt string) (ce e.IError) {
+	ctx, span := obs.Tracing.SPNGetFromCTX(r.Context(), "authSvc_refreshOpenid",
+		obs.Tracing.TAString("function", "RefreshOpenidService"))
+	defer span.End()
+	r = r.WithContext(ctx)
+
+	// the oauth2 server answer on w, its status is the outcome
+	sw := &statusWriter{ResponseWriter: w}
+	w = sw
+	defer func(start time.Time) {
+		metrics.recordToken(ctx, tokenOperationRefresh, start, sw.result(ce))
+	}(time.Now())
 
 	// validate the token
-	keyID := "theKeyID"
-	secretKey := "mySecretKey"
+	keyID, secretKey, err := t.keys.KeyRingForToken(jwt)
+	if err != nil {
+		obs.Logging.NewLogHandler(obs.Logging.LLHError()).
+			Err(err).
+			Msg("RefreshOpenidService - no key for the jwt")
+		return e.NewCustomHTTPStatus(e.StatusForbidden)
+	}
 	encoding := "HS256"
 
 	// use this method which returns the data even the jwt is expired
-	data, err := t.srv.HandleJWTokenAdminGetdata(context.TODO(), r, jwt, keyID, secretKey, encoding)
+	data, err := t.srv.HandleJWTokenAdminGetdata(ctx, r, jwt, keyID, secretKey, encoding)
 
 	emailOrAPIsvcID, ok := data["sub"]
 	if !ok {
 		obs.Logging.NewLogHandler(obs.Logging.LLHError()).
 			Err(err).
 			Msg("RefreshOpenidService - emailOrAPIsvcID missing")
-		w.WriteHeader(http.StatusForbidden)
-		json.NewEncoder(w).Encode(e.NewCustomHTTPStatus(e.StatusForbidden))
-		return
+		return e.NewCustomHTTPStatus(e.StatusForbidden)
 	}
 
 	role := data["role"].(string)
+	tenant := tenantFromClaims(data)
 
 	switch role {
 	case "user":
-		user, err := t.repos.UserGetByEmail(emailOrAPIsvcID.(string))
+		user, err := t.repos.UserGetByEmail(ctx, tenant, emailOrAPIsvcID.(string))
 		if err != nil {
 			obs.Logging.NewLogHandler(obs.Logging.LLHError()).
 				Err(err).
 				Msg(fmt.Sprintf("RefreshOpenidService - get %v from db failed", emailOrAPIsvcID))
-			w.WriteHeader(http.StatusForbidden)
-			json.NewEncoder(w).Encode(e.NewCustomHTTPStatus(e.StatusForbidden))
-			return
+			return e.NewCustomHTTPStatus(e.StatusForbidden)
+		}
+
+		if user.IsDisabled == 1 {
+			obs.Logging.NewLogHandler(obs.Logging.LLHError()).
+				Msg(fmt.Sprintf("RefreshOpenidService - %v is disabled", emailOrAPIsvcID))
+			return e.NewCustomHTTPStatus(e.StatusForbidden)
 		}
 
 		r.Header.Set("refresh_token", user.RefreshTK)
@@ -79,15 +136,13 @@ func (t *TokenService) RefreshOpenidService(w http.ResponseWriter, r *http.Reque
 
 	case "APIserver":
 		// NOTE check the serviceID is whiteList ??? overkilled ?
-		apiSvc, err := t.repos.APIserverGetByID(emailOrAPIsvcID.(string))
+		apiSvc, err := t.repos.APIserverGetByID(ctx, emailOrAPIsvcID.(string))
 		if err != nil {
 			obs.Logging.NewLogHandler(obs.Logging.LLHError()).
 				Err(err).
 				Msg(fmt.Sprintf("RefreshOpenidService - get %v from db failed", emailOrAPIsvcID))
 			// do something better....
-			w.WriteHeader(http.StatusForbidden)
-			json.NewEncoder(w).Encode(e.NewCustomHTTPStatus(e.StatusForbidden))
-			return
+			return e.NewCustomHTTPStatus(e.StatusForbidden)
 		}
 
 		r.Header.Set("refresh_token", apiSvc.RefreshTK)
@@ -95,9 +150,7 @@ func (t *TokenService) RefreshOpenidService(w http.ResponseWriter, r *http.Reque
 		r.Header.Set("jwt_access_token", jwt)
 
 	default:
-		w.WriteHeader(http.StatusForbidden)
-		json.NewEncoder(w).Encode(e.NewCustomHTTPStatus(e.StatusForbidden))
-		return
+		return e.NewCustomHTTPStatus(e.StatusForbidden)
 	}
 
 	err = r.ParseForm()
@@ -105,34 +158,45 @@ func (t *TokenService) RefreshOpenidService(w http.ResponseWriter, r *http.Reque
 		obs.Logging.NewLogHandler(obs.Logging.LLHError()).
 			Err(err).
 			Msg("RefreshOpenidService - fail parse form")
-		w.WriteHeader(http.StatusBadRequest)
-		json.NewEncoder(w).Encode(e.NewCustomHTTPStatus(e.StatusBadRequest))
-		return
+		return e.NewCustomHTTPStatus(e.StatusBadRequest)
 	}
 
 	r.Form.Add("role", role)
 	r.Form.Add("path", "refreshopenid") // will inform the CustomizeHandler
 	r.Form.Add("sub", emailOrAPIsvcID.(string))
+	r.Form.Set("tenant", tenant) // the tenant stay the one of the jwt
 
 	obs.Logging.NewLogHandler(obs.Logging.LLHDebug()).
 		Msg("RefreshOpenidService - exit successfully")
 
 	// if error during refreshing, return err
-	err = t.srv.RefreshOpenidToken(context.Background(), w, r, data)
+	err = t.srv.RefreshOpenidToken(ctx, w, r, data)
 	if err != nil {
 		obs.Logging.NewLogHandler(obs.Logging.LLHError()).
 			Err(err).
 			Msg("RefreshOpenidService - t.srv.RefreshOpenidToken execution failed")
-		w.WriteHeader(http.StatusForbidden)
-		json.NewEncoder(w).Encode(e.NewCustomHTTPStatus(e.StatusForbidden, "/refreshopenid", err.Error()))
+		return e.NewCustomHTTPStatus(e.StatusForbidden, "/refreshopenid", err.Error())
 	}
+
+	return nil
 }
 
 // TokenService handle the request to provide the jwt(2nd request of the oauth/openid protocol)
-func (t *TokenService) TokenService(w http.ResponseWriter, r *http.Request, authHeader string) e.IError {
+func (t *TokenService) TokenService(w http.ResponseWriter, r *http.Request, authHeader string) (ce e.IError) {
 	obs.Logging.NewLogHandler(obs.Logging.LLHDebug()).
 		Msgf("TokenService - hit service", r)
 
+	ctx, span := obs.Tracing.SPNGetFromCTX(r.Context(), "authSvc_token",
+		obs.Tracing.TAString("function", "TokenService"))
+	defer span.End()
+	r = r.WithContext(ctx)
+
+	sw := &statusWriter{ResponseWriter: w}
+	w = sw
+	defer func(start time.Time) {
+		metrics.recordToken(ctx, tokenOperationIssue, start, sw.result(ce))
+	}(time.Now())
+
 	err := t.srv.HandleTokenRequest(w, r)
 	if err != nil {
 		return e.NewCustomHTTPStatus(e.StatusInternalServerError)
@@ -141,19 +205,31 @@ func (t *TokenService) TokenService(w http.ResponseWriter, r *http.Request, auth
 }
 
 // JwtGetdataService valid the jwt and return the data it holds
-func (t *TokenService) JwtGetdataService(w http.ResponseWriter, r *http.Request, cookie *http.Cookie) (map[string]interface{}, e.IError) {
+func (t *TokenService) JwtGetdataService(w http.ResponseWriter, r *http.Request, cookie *http.Cookie) (_ map[string]interface{}, ce e.IError) {
 	obs.Logging.NewLogHandler(obs.Logging.LLHDebug()).
 		Msgf("JwtGetdataService - hit service", r)
 
+	ctx, span := obs.Tracing.SPNGetFromCTX(r.Context(), "authSvc_jwtGetdata",
+		obs.Tracing.TAString("function", "JwtGetdataService"))
+	defer span.End()
+	defer func(start time.Time) {
+		metrics.recordToken(ctx, tokenOperationGetdata, start, statusOf(ce))
+	}(time.Now())
+
 	jwt := cookie.Value
 	// fmt.Println("see the cookie value: ", jwt)
 
 	// validate the token
-	keyID := "theKeyID"
-	secretKey := "mySecretKey"
+	keyID, secretKey, err := t.keys.KeyRingForToken(jwt)
+	if err != nil {
+		obs.Logging.NewLogHandler(obs.Logging.LLHError()).
+			Err(err).
+			Msg("JwtGetdataService - no key for the jwt")
+		return nil, e.NewCustomHTTPStatus(e.StatusForbidden)
+	}
 	encoding := "HS256"
 
-	data, err := t.srv.HandleJWTokenGetdata(context.TODO(), r, jwt, keyID, secretKey, encoding)
+	data, err := t.srv.HandleJWTokenGetdata(ctx, r, jwt, keyID, secretKey, encoding)
 	if err != nil {
 
 		switch err.Error() {
@@ -177,19 +253,31 @@ func (t *TokenService) JwtGetdataService(w http.ResponseWriter, r *http.Request,
 }
 
 // JwtValidationService validate the jwt(here optional, as it's handle by the broker)
-func (t *TokenService) JwtValidationService(r *http.Request, cookie *http.Cookie) e.IError {
+func (t *TokenService) JwtValidationService(r *http.Request, cookie *http.Cookie) (ce e.IError) {
 
 	obs.Logging.NewLogHandler(obs.Logging.LLHDebug()).
 		Msgf("JwtValidationService - hit service", r)
 
+	ctx, span := obs.Tracing.SPNGetFromCTX(r.Context(), "authSvc_jwtValidation",
+		obs.Tracing.TAString("function", "JwtValidationService"))
+	defer span.End()
+	defer func(start time.Time) {
+		metrics.recordToken(ctx, tokenOperationValidate, start, statusOf(ce))
+	}(time.Now())
+
 	jwt := cookie.Value
 
 	// validate the token
-	keyID := "theKeyID"
-	secretKey := "mySecretKey"
+	keyID, secretKey, err := t.keys.KeyRingForToken(jwt)
+	if err != nil {
+		obs.Logging.NewLogHandler(obs.Logging.LLHError()).
+			Err(err).
+			Msg("JwtValidationService - no key for the jwt")
+		return e.NewCustomHTTPStatus(e.StatusUnauthorized)
+	}
 	encoding := "HS256"
 
-	err := t.srv.HandleJWTokenValidation(context.TODO(), r, jwt, keyID, secretKey, encoding)
+	err = t.srv.HandleJWTokenValidation(ctx, r, jwt, keyID, secretKey, encoding)
 	if err != nil {
 		obs.Logging.NewLogHandler(obs.Logging.LLHError()).
 			Err(err).
@@ -206,9 +294,15 @@ func (t *TokenService) JwtValidationService(r *http.Request, cookie *http.Cookie
 
 // Endpoint to validate token and permission
 // func (t *TokenService) ValidPermissionService(w http.ResponseWriter, r *http.Request, permission string, token *http.Cookie) map[string]interface{} {
-func (t *TokenService) ValidPermissionService(w http.ResponseWriter, r *http.Request) (map[string]interface{}, e.IError) {
+func (t *TokenService) ValidPermissionService(w http.ResponseWriter, r *http.Request) (_ map[string]interface{}, ce e.IError) {
+
+	ctx, span := obs.Tracing.SPNGetFromCTX(r.Context(), "authSvc_validPermission",
+		obs.Tracing.TAString("function", "ValidPermissionService"))
+	defer span.End()
+	defer func(start time.Time) {
+		metrics.recordToken(ctx, tokenOperationPermission, start, statusOf(ce))
+	}(time.Now())
 
-	var ce e.IError
 	// validate the token
 	token, err := t.srv.ValidationBearerToken(r)
 	if err != nil {
@@ -259,3 +353,32 @@ func (t *TokenService) ValidPermissionService(w http.ResponseWriter, r *http.Req
 
 	return data, nil
 }
+
+// tokenWriter is the http.ResponseWriter the oauth2 server answer on
+// when the refresh is not served over http
+type tokenWriter struct {
+	header http.Header
+	status int
+	body   bytes.Buffer
+}
+
+func newTokenWriter() *tokenWriter {
+	return &tokenWriter{header: make(http.Header)}
+}
+
+func (tw *tokenWriter) Header() http.Header {
+	return tw.header
+}
+
+func (tw *tokenWriter) WriteHeader(code int) {
+	if tw.status == 0 {
+		tw.status = code
+	}
+}
+
+func (tw *tokenWriter) Write(b []byte) (int, error) {
+	if tw.status == 0 {
+		tw.status = http.StatusOK
+	}
+	return tw.body.Write(b)
+}
@@ -1,6 +1,7 @@
 package services
 
 import (
+	"context"
 	"encoding/base64"
 	"encoding/json"
 	"fmt"
@@ -162,7 +163,7 @@ func TestAPIserverRefreshJWTToken(t *testing.T) {
 	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
 	req.Header.Set("Authorization", authHeader)
 
-	APIserviceDataBF, _ := repos.APIserverGetByID(brokerSvcID)
+	APIserviceDataBF, _ := repos.APIserverGetByID(context.Background(), brokerSvcID)
 
 	tokenService.RefreshOpenidService(recorder, req, cookie)
 
@@ -187,12 +188,40 @@ func TestAPIserverRefreshJWTToken(t *testing.T) {
 
 	// make sure the refreshJWT(and the refreskTK still here) as been refreshed in db
 	// access the storage
-	APIserviceDataAFT, _ := repos.APIserverGetByID(brokerSvcID)
+	APIserviceDataAFT, _ := repos.APIserverGetByID(context.Background(), brokerSvcID)
 	tests.MaybeFail("TokenService_refresh_jwt_user_token_has_been_refresh_in_db", tests.Expect(APIserviceDataBF.RefreshJWT != APIserviceDataAFT.RefreshJWT, true))
 	tests.MaybeFail("TokenService_refresh_user_token_is_still_same_in_db", tests.Expect(APIserviceDataBF.RefreshTK == APIserviceDataAFT.RefreshTK, true))
 
 }
 
+// the grpc RefreshToken run the same flow without http request
+func TestAPIserverRefreshOpenid(t *testing.T) {
+	tests.MaybeFail = tests.InitFailFunc(t)
+
+	ag := NewJWTAccessGenerate(keyID, secretKey)
+
+	ui = make(map[string]interface{})
+	ui["scope"] = "read, openid"
+	ui["role"] = "APIserver"
+	ui["another"] = "whatever"
+
+	expiredToken, err := ag.GenerateOpenidJWToken(expiredTime, ui, brokerSvcID, brokerSvcID)
+	if err != nil {
+		t.Fatal("generate expiredToken failed: ", err)
+	}
+
+	token, ce := tokenService.RefreshOpenid(context.Background(), `"`+expiredToken+`"`)
+	tests.MaybeFail("RefreshOpenid", ce)
+
+	dataFromAG, err := ag.GetdataOpenidJWToken(nil, token)
+	tests.MaybeFail("RefreshOpenid_data", err,
+		tests.Expect(dataFromAG["another"], "whatever"),
+		tests.Expect(time.Unix(int64(dataFromAG["expiresAt"].(float64)), 0).After(time.Now()), true))
+
+	_, ce = tokenService.RefreshOpenid(context.Background(), "notAJwt")
+	tests.MaybeFail("RefreshOpenid_invalid", tests.Expect(ce.GetCode(), http.StatusForbidden))
+}
+
 func TestAPIserverRefreshJWTokenWithExpiredRefreshToken(t *testing.T) {
 	tests.MaybeFail = tests.InitFailFunc(t)
 
@@ -216,10 +245,10 @@ func TestAPIserverRefreshJWTokenWithExpiredRefreshToken(t *testing.T) {
 	}
 
 	// set the expired token as refreshToken
-	apiSvc, _ := repos.APIserverGetByID(brokerSvcID)
+	apiSvc, _ := repos.APIserverGetByID(context.Background(), brokerSvcID)
 	validRefreshToken := apiSvc.RefreshJWT
 	apiSvc.RefreshJWT = expiredToken
-	_ = repos.APIserverCreate(brokerSvcID, apiSvc)
+	_ = repos.APIserverCreate(context.Background(), brokerSvcID, apiSvc)
 
 	recorder := httptest.NewRecorder()
 
@@ -245,9 +274,9 @@ func TestAPIserverRefreshJWTokenWithExpiredRefreshToken(t *testing.T) {
 	tests.MaybeFail("TokenService_refresh_jwt_with_expired_refresh_token_comment", err, tests.Expect(responseBody.Comment, "expired jwt token"))
 
 	// reset valid refreshToken
-	apiSvc, _ = repos.APIserverGetByID(brokerSvcID)
+	apiSvc, _ = repos.APIserverGetByID(context.Background(), brokerSvcID)
 	apiSvc.RefreshJWT = validRefreshToken
-	_ = repos.APIserverCreate(brokerSvcID, apiSvc)
+	_ = repos.APIserverCreate(context.Background(), brokerSvcID, apiSvc)
 }
 
 func TestAPIserverRefreshJWTokenWithInvalidAccessToken(t *testing.T) {
@@ -517,7 +546,7 @@ func TestUserRefreshJWToken(t *testing.T) {
 
 	recorder := httptest.NewRecorder()
 
-	userDataBF, _ := repos.UserGetByEmail(userEmail)
+	userDataBF, _ := repos.UserGetByEmail(context.Background(), tenantvar, userEmail)
 
 	rURL := "/refreshtoken"
 	formValues := url.Values{}
@@ -556,7 +585,7 @@ func TestUserRefreshJWToken(t *testing.T) {
 
 	// make sure the refreshJWT(and the refreskTK still here) as been refreshed in db
 	// access the storage
-	userDataAFT, _ := repos.UserGetByEmail(userEmail)
+	userDataAFT, _ := repos.UserGetByEmail(context.Background(), tenantvar, userEmail)
 	tests.MaybeFail("TokenService_refresh_jwt_user_token_has_been_refresh_in_db", tests.Expect(userDataBF.RefreshJWT != userDataAFT.RefreshJWT, true))
 	tests.MaybeFail("TokenService_refresh_user_token_is_still_same_in_db", tests.Expect(userDataBF.RefreshTK == userDataAFT.RefreshTK, true))
 }
@@ -588,10 +617,10 @@ func TestUserRefreshJWTokenWithExpiredRefreshToken(t *testing.T) {
 	}
 
 	// set the expired token as refreshToken
-	user, _ := repos.UserGetByEmail(userEmail)
+	user, _ := repos.UserGetByEmail(context.Background(), tenantvar, userEmail)
 	validRefreshToken := user.RefreshJWT
 	user.RefreshJWT = expiredToken
-	_ = repos.UserCreate(userEmail, user)
+	_ = repos.UserUpdate(context.Background(), tenantvar, userEmail, user)
 
 	recorder := httptest.NewRecorder()
 
@@ -618,9 +647,9 @@ func TestUserRefreshJWTokenWithExpiredRefreshToken(t *testing.T) {
 	tests.MaybeFail("TokenService_refresh_jwt_with_expired_refresh_token_comment", err, tests.Expect(responseBody.Comment, "expired jwt token"))
 
 	// reset valid refreshToken
-	user, _ = repos.UserGetByEmail(userEmail)
+	user, _ = repos.UserGetByEmail(context.Background(), tenantvar, userEmail)
 	user.RefreshJWT = validRefreshToken
-	_ = repos.UserCreate(userEmail, user)
+	_ = repos.UserUpdate(context.Background(), tenantvar, userEmail, user)
 }
 
 func TestUserRefreshJWTokenWithInvalidAccessToken(t *testing.T) {
@@ -1056,7 +1085,7 @@ func runFlow(emails []string, ch chan<- error, wg *sync.WaitGroup) {
 	// refresh jwtTokens
 	for _, user := range jwtokens {
 		// get old jwtRefreshToken(as refreshing the accces will refresh both)
-		userFromDBbefore, err := repos.UserGetByEmail(user.email)
+		userFromDBbefore, err := repos.UserGetByEmail(context.Background(), tenantvar, user.email)
 		if err != nil {
 			ch <- err
 		}
@@ -1070,7 +1099,7 @@ func runFlow(emails []string, ch chan<- error, wg *sync.WaitGroup) {
 			ch <- fmt.Errorf("RefreshJWT invalid newJwtAccess")
 		}
 
-		userFromDBafter, err := repos.UserGetByEmail(user.email)
+		userFromDBafter, err := repos.UserGetByEmail(context.Background(), tenantvar, user.email)
 		if err != nil {
 			ch <- err
 		}
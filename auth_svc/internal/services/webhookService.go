@@ -0,0 +1,391 @@
+package services
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"gitlab.com/grpasr/asonrythme/auth_svc/internal/models"
+	"gitlab.com/grpasr/asonrythme/auth_svc/internal/repository"
+	e "gitlab.com/grpasr/common/errors/json"
+	obs "gitlab.com/grpasr/common/observability"
+	"io"
+	"net/http"
+	"net/url"
+	"sync"
+	"time"
+)
+
+// the identity events a subscription can be notified of
+const (
+	WebhookEventUserSignup        = "user.signup"
+	WebhookEventUserEmailVerified = "user.email_verified"
+	WebhookEventUserDeleted       = "user.deleted"
+)
+
+var webhookEvents = map[string]bool{
+	WebhookEventUserSignup:        true,
+	WebhookEventUserEmailVerified: true,
+	WebhookEventUserDeleted:       true,
+}
+
+// the headers of a delivery, the signature is
+// t=<unix time>,v1=<hex(hmac_sha256(secret, "<unix time>.<body>"))>
+const (
+	webhookHeaderID        = "X-Webhook-ID"
+	webhookHeaderEvent     = "X-Webhook-Event"
+	webhookHeaderSignature = "X-Webhook-Signature"
+)
+
+// IWebhookPublisher is what the services emitting the events need
+type IWebhookPublisher interface {
+	WebhookPublish(event string, data map[string]string)
+}
+
+type IWebhookService interface {
+	IWebhookPublisher
+	WebhookSubscribe(rawURL string, events []string) (*models.WebhookSubscriptionDatas, e.IError)
+	WebhookSubscriptionList() ([]models.WebhookSubscriptionDatas, e.IError)
+	WebhookUnsubscribe(id string) e.IError
+	WebhookDeadLetterList(skip, limit int64) ([]models.WebhookDeliveryDatas, e.IError)
+	WebhookReplay(id string) e.IError
+	WebhookRun(ctx context.Context)
+}
+
+// WebhookConfig set the deliveries, a failed attempt is retried after
+// Backoff, then 2*Backoff, 4*Backoff... until MaxAttempts
+type WebhookConfig struct {
+	MaxAttempts int
+	Backoff     time.Duration
+	Timeout     time.Duration
+	Workers     int
+	QueueSize   int
+}
+
+// webhookJob is a delivery with the subscription it is sent to
+type webhookJob struct {
+	delivery models.WebhookDeliveryDatas
+	sub      models.WebhookSubscriptionDatas
+}
+
+// WebhookService deliver the events in the background, the deliveries
+// which failed all their attempts are kept as dead letters to be replayed
+type WebhookService struct {
+	repos   *repository.Repository
+	cfg     WebhookConfig
+	client  *http.Client
+	queue   chan webhookJob
+	pending sync.WaitGroup
+}
+
+func NewWebhookService(rp *repository.Repository, cfg WebhookConfig) IWebhookService {
+	if cfg.MaxAttempts < 1 {
+		cfg.MaxAttempts = 1
+	}
+	if cfg.Workers < 1 {
+		cfg.Workers = 1
+	}
+	if cfg.QueueSize < 1 {
+		cfg.QueueSize = 256
+	}
+
+	return &WebhookService{
+		repos:  rp,
+		cfg:    cfg,
+		client: &http.Client{Timeout: cfg.Timeout},
+		queue:  make(chan webhookJob, cfg.QueueSize),
+	}
+}
+
+// WebhookPublish queue a delivery of the event to each subscription,
+// it never block the caller, a delivery which can not be queued is dead lettered
+func (w *WebhookService) WebhookPublish(event string, data map[string]string) {
+	subs, err := w.repos.WebhookSubscriptionList()
+	if err != nil {
+		obs.Logging.NewLogHandler(obs.Logging.LLHError()).
+			Err(err).
+			Msg(fmt.Sprintf("WebhookPublish - list subscriptions failed, %v not sent", event))
+		return
+	}
+
+	for _, sub := range subs {
+		if !webhookSubscribed(sub, event) {
+			continue
+		}
+
+		id, err := newRandomString(16)
+		if err != nil {
+			obs.Logging.NewLogHandler(obs.Logging.LLHError()).
+				Err(err).
+				Msg("WebhookPublish - generate delivery id failed")
+			return
+		}
+
+		now := time.Now()
+		payload, err := json.Marshal(map[string]interface{}{
+			"id":         id,
+			"event":      event,
+			"created_at": now.UTC().Format(time.RFC3339),
+			"data":       data,
+		})
+		if err != nil {
+			obs.Logging.NewLogHandler(obs.Logging.LLHError()).
+				Err(err).
+				Msg(fmt.Sprintf("WebhookPublish - marshal %v failed", event))
+			return
+		}
+
+		w.enqueue(webhookJob{
+			delivery: models.WebhookDeliveryDatas{
+				ID:             id,
+				SubscriptionID: sub.ID,
+				Event:          event,
+				Payload:        string(payload),
+				CreatedAT:      now,
+			},
+			sub: sub,
+		})
+	}
+}
+
+// WebhookSubscribe register the url for the events, the returned
+// subscription carry the secret the deliveries are signed with
+func (w *WebhookService) WebhookSubscribe(rawURL string, events []string) (*models.WebhookSubscriptionDatas, e.IError) {
+	u, err := url.Parse(rawURL)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return nil, e.NewCustomHTTPStatus(e.StatusBadRequest, "auth/v1/admin/webhooks", "invalid url")
+	}
+	if len(events) == 0 {
+		return nil, e.NewCustomHTTPStatus(e.StatusBadRequest, "auth/v1/admin/webhooks", "events missing")
+	}
+	for _, ev := range events {
+		if !webhookEvents[ev] {
+			return nil, e.NewCustomHTTPStatus(e.StatusBadRequest, "auth/v1/admin/webhooks", "unknown event "+ev)
+		}
+	}
+
+	id, err := newRandomString(12)
+	if err != nil {
+		return nil, e.NewCustomHTTPStatus(e.StatusInternalServerError)
+	}
+	secret, err := newRandomString(32)
+	if err != nil {
+		return nil, e.NewCustomHTTPStatus(e.StatusInternalServerError)
+	}
+
+	sub := models.WebhookSubscriptionDatas{
+		ID:     id,
+		URL:    u.String(),
+		Secret: secret,
+		Events: events,
+	}
+	if err := w.repos.WebhookSubscriptionCreate(sub); err != nil {
+		return nil, e.NewCustomHTTPStatus(e.StatusInternalServerError)
+	}
+
+	obs.Logging.NewLogHandler(obs.Logging.LLHInfo()).
+		Msg(fmt.Sprintf("WebhookSubscribe - %v subscribed to %v", sub.URL, events))
+
+	return &sub, nil
+}
+
+// WebhookSubscriptionList return the subscriptions, without their secret
+func (w *WebhookService) WebhookSubscriptionList() ([]models.WebhookSubscriptionDatas, e.IError) {
+	subs, err := w.repos.WebhookSubscriptionList()
+	if err != nil {
+		return nil, e.NewCustomHTTPStatus(e.StatusInternalServerError)
+	}
+	for i := range subs {
+		subs[i].Secret = ""
+	}
+	return subs, nil
+}
+
+func (w *WebhookService) WebhookUnsubscribe(id string) e.IError {
+	if id == "" {
+		return e.NewCustomHTTPStatus(e.StatusBadRequest, "auth/v1/admin/webhooks", "id missing")
+	}
+	if err := w.repos.WebhookSubscriptionDelete(id); err != nil {
+		return e.NewCustomHTTPStatus(e.StatusNotFound)
+	}
+	return nil
+}
+
+func (w *WebhookService) WebhookDeadLetterList(skip, limit int64) ([]models.WebhookDeliveryDatas, e.IError) {
+	if skip < 0 {
+		skip = 0
+	}
+	if limit <= 0 {
+		limit = adminUserListLimitDefault
+	}
+	if limit > adminUserListLimitMax {
+		limit = adminUserListLimitMax
+	}
+
+	dls, err := w.repos.WebhookDeadLetterList(skip, limit)
+	if err != nil {
+		return nil, e.NewCustomHTTPStatus(e.StatusInternalServerError)
+	}
+	return dls, nil
+}
+
+// WebhookReplay queue the dead letter again, with a fresh set of attempts
+func (w *WebhookService) WebhookReplay(id string) e.IError {
+	delivery, err := w.repos.WebhookDeadLetterTake(id)
+	if err != nil {
+		return e.NewCustomHTTPStatus(e.StatusNotFound)
+	}
+
+	sub, err := w.repos.WebhookSubscriptionGetByID(delivery.SubscriptionID)
+	if err != nil {
+		// the subscription is gone, keep the dead letter
+		_ = w.repos.WebhookDeadLetterCreate(delivery)
+		return e.NewCustomHTTPStatus(e.StatusNotFound, "auth/v1/admin/webhooks/replay", "subscription not found")
+	}
+
+	obs.Logging.NewLogHandler(obs.Logging.LLHInfo()).
+		Msg(fmt.Sprintf("WebhookReplay - replay %v to %v", delivery.ID, sub.URL))
+
+	delivery.Attempts = 0
+	delivery.LastError = ""
+	w.enqueue(webhookJob{delivery: delivery, sub: sub})
+	return nil
+}
+
+// WebhookRun deliver the queued events until ctx is done, then the
+// deliveries not sent yet are dead lettered, so they can be replayed
+func (w *WebhookService) WebhookRun(ctx context.Context) {
+	var workers sync.WaitGroup
+	for i := 0; i < w.cfg.Workers; i++ {
+		workers.Add(1)
+		go func() {
+			defer workers.Done()
+			for {
+				select {
+				case <-ctx.Done():
+					return
+				case job := <-w.queue:
+					w.deliver(ctx, job)
+				}
+			}
+		}()
+	}
+
+	workers.Wait()
+	w.pending.Wait()
+
+	for {
+		select {
+		case job := <-w.queue:
+			w.deadLetter(job.delivery, "shutdown before delivery")
+		default:
+			obs.Logging.NewLogHandler(obs.Logging.LLHInfo()).
+				Msg("WebhookRun - stopped")
+			return
+		}
+	}
+}
+
+func (w *WebhookService) enqueue(job webhookJob) {
+	select {
+	case w.queue <- job:
+	default:
+		w.deadLetter(job.delivery, "queue full")
+	}
+}
+
+// deliver make an attempt, a failed one is retried after the backoff
+// without holding the worker
+func (w *WebhookService) deliver(ctx context.Context, job webhookJob) {
+	job.delivery.Attempts++
+
+	err := w.send(ctx, job)
+	if err == nil {
+		obs.Logging.NewLogHandler(obs.Logging.LLHDebug()).
+			Msg(fmt.Sprintf("deliver - %v %v delivered to %v", job.delivery.Event, job.delivery.ID, job.sub.URL))
+		return
+	}
+
+	obs.Logging.NewLogHandler(obs.Logging.LLHError()).
+		Err(err).
+		Msg(fmt.Sprintf("deliver - attempt %v of %v to %v failed", job.delivery.Attempts, job.delivery.ID, job.sub.URL))
+
+	job.delivery.LastError = err.Error()
+	if job.delivery.Attempts >= w.cfg.MaxAttempts {
+		w.deadLetter(job.delivery, "")
+		return
+	}
+
+	wait := w.cfg.Backoff << (job.delivery.Attempts - 1)
+	w.pending.Add(1)
+	go func() {
+		defer w.pending.Done()
+		select {
+		case <-ctx.Done():
+			w.deadLetter(job.delivery, "")
+		case <-time.After(wait):
+			w.enqueue(job)
+		}
+	}()
+}
+
+func (w *WebhookService) send(ctx context.Context, job webhookJob) error {
+	body := []byte(job.delivery.Payload)
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, job.sub.URL, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set(webhookHeaderID, job.delivery.ID)
+	req.Header.Set(webhookHeaderEvent, job.delivery.Event)
+	req.Header.Set(webhookHeaderSignature, webhookSign(job.sub.Secret, time.Now().Unix(), body))
+
+	resp, err := w.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	_, _ = io.Copy(io.Discard, resp.Body)
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return fmt.Errorf("unexpected status %v", resp.StatusCode)
+	}
+	return nil
+}
+
+// deadLetter save the delivery, reason overwrite the last error if set
+func (w *WebhookService) deadLetter(d models.WebhookDeliveryDatas, reason string) {
+	if reason != "" {
+		d.LastError = reason
+	}
+
+	obs.Logging.NewLogHandler(obs.Logging.LLHError()).
+		Msg(fmt.Sprintf("deadLetter - %v %v dead lettered: %v", d.Event, d.ID, d.LastError))
+
+	if err := w.repos.WebhookDeadLetterCreate(d); err != nil {
+		obs.Logging.NewLogHandler(obs.Logging.LLHError()).
+			Err(err).
+			Msg(fmt.Sprintf("deadLetter - save %v failed, delivery lost", d.ID))
+	}
+}
+
+func webhookSubscribed(sub models.WebhookSubscriptionDatas, event string) bool {
+	for _, ev := range sub.Events {
+		if ev == event {
+			return true
+		}
+	}
+	return false
+}
+
+// webhookSign return the signature header of the body
+func webhookSign(secret string, timestamp int64, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	fmt.Fprintf(mac, "%d.", timestamp)
+	mac.Write(body)
+	return fmt.Sprintf("t=%d,v1=%s", timestamp, hex.EncodeToString(mac.Sum(nil)))
+}
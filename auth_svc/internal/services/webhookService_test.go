@@ -0,0 +1,194 @@
+package services
+
+import (
+	"context"
+	"encoding/json"
+	"gitlab.com/grpasr/asonrythme/auth_svc/internal/models"
+	"gitlab.com/grpasr/asonrythme/auth_svc/internal/repository"
+	"gitlab.com/grpasr/common/tests"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// webhookStandIn is the local http endpoint the deliveries are sent to,
+// it answer fails times with a 500 before accepting the deliveries
+type webhookStandIn struct {
+	srv      *httptest.Server
+	fails    int
+	hits     int
+	received []*http.Request
+	bodies   [][]byte
+	sync.Mutex
+}
+
+func newWebhookStandIn(fails int) *webhookStandIn {
+	si := &webhookStandIn{fails: fails}
+	si.srv = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+
+		si.Lock()
+		defer si.Unlock()
+		si.hits++
+		if si.hits <= si.fails {
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+		si.received = append(si.received, r)
+		si.bodies = append(si.bodies, body)
+	}))
+	return si
+}
+
+func (si *webhookStandIn) setFails(fails int) {
+	si.Lock()
+	si.fails = si.hits + fails
+	si.Unlock()
+}
+
+func (si *webhookStandIn) counts() (int, int) {
+	si.Lock()
+	defer si.Unlock()
+	return si.hits, len(si.received)
+}
+
+func newTestWebhookService(maxAttempts int) (IWebhookService, *repository.Repository) {
+	rp := &repository.Repository{IWebhookStore: repository.NewWebhookStoreMock()}
+	return NewWebhookService(rp, WebhookConfig{
+		MaxAttempts: maxAttempts,
+		Backoff:     10 * time.Millisecond,
+		Timeout:     time.Second,
+		Workers:     2,
+	}), rp
+}
+
+func waitFor(t *testing.T, cond func() bool) {
+	deadline := time.Now().Add(3 * time.Second)
+	for !cond() {
+		if time.Now().After(deadline) {
+			t.Fatal("condition not met in time")
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+}
+
+// verifyWebhookSignature is what a receiver does with the signature header
+func verifyWebhookSignature(secret, header string, body []byte) bool {
+	parts := strings.Split(header, ",")
+	if len(parts) != 2 || !strings.HasPrefix(parts[0], "t=") {
+		return false
+	}
+	ts, err := strconv.ParseInt(strings.TrimPrefix(parts[0], "t="), 10, 64)
+	if err != nil {
+		return false
+	}
+	return webhookSign(secret, ts, body) == header
+}
+
+func TestWebhookDeliveryRetriedAndSigned(t *testing.T) {
+	tests.MaybeFail = tests.InitFailFunc(t)
+
+	si := newWebhookStandIn(2)
+	defer si.srv.Close()
+
+	ws, rp := newTestWebhookService(5)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go ws.WebhookRun(ctx)
+
+	sub, ce := ws.WebhookSubscribe(si.srv.URL, []string{WebhookEventUserSignup})
+	tests.MaybeFail("WebhookSubscribe", ce, tests.Expect(len(sub.Secret) > 0, true))
+
+	ws.WebhookPublish(WebhookEventUserDeleted, map[string]string{"email": "not@subscribed.com"})
+	ws.WebhookPublish(WebhookEventUserSignup, map[string]string{"email": "webhook@example.com"})
+
+	waitFor(t, func() bool { _, delivered := si.counts(); return delivered == 1 })
+
+	hits, _ := si.counts()
+	req, body := si.received[0], si.bodies[0]
+
+	var payload map[string]interface{}
+	err := json.Unmarshal(body, &payload)
+	data, _ := payload["data"].(map[string]interface{})
+
+	dls, _ := rp.WebhookDeadLetterList(0, 10)
+
+	tests.MaybeFail("WebhookDelivery", err,
+		tests.Expect(hits, 3),
+		tests.Expect(req.Header.Get(webhookHeaderEvent), WebhookEventUserSignup),
+		tests.Expect(req.Header.Get(webhookHeaderID), payload["id"].(string)),
+		tests.Expect(verifyWebhookSignature(sub.Secret, req.Header.Get(webhookHeaderSignature), body), true),
+		tests.Expect(verifyWebhookSignature("otherSecret", req.Header.Get(webhookHeaderSignature), body), false),
+		tests.Expect(data["email"], "webhook@example.com"),
+		tests.Expect(len(dls), 0),
+	)
+}
+
+func TestWebhookDeadLetterAndReplay(t *testing.T) {
+	tests.MaybeFail = tests.InitFailFunc(t)
+
+	si := newWebhookStandIn(1000)
+	defer si.srv.Close()
+
+	ws, _ := newTestWebhookService(3)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go ws.WebhookRun(ctx)
+
+	_, ce := ws.WebhookSubscribe(si.srv.URL, []string{WebhookEventUserEmailVerified})
+	tests.MaybeFail("WebhookSubscribe", ce)
+
+	ws.WebhookPublish(WebhookEventUserEmailVerified, map[string]string{"email": "dead@example.com"})
+
+	var dls []models.WebhookDeliveryDatas
+	waitFor(t, func() bool { dls, _ = ws.WebhookDeadLetterList(0, 10); return len(dls) == 1 })
+
+	hits, delivered := si.counts()
+	tests.MaybeFail("WebhookDeadLetter", nil,
+		tests.Expect(hits, 3),
+		tests.Expect(delivered, 0),
+		tests.Expect(dls[0].Attempts, 3),
+		tests.Expect(dls[0].LastError, "unexpected status 500"),
+	)
+
+	si.setFails(0)
+	ce = ws.WebhookReplay(dls[0].ID)
+	waitFor(t, func() bool { _, delivered := si.counts(); return delivered == 1 })
+
+	dlsAfter, _ := ws.WebhookDeadLetterList(0, 10)
+	ceReplayed := ws.WebhookReplay(dls[0].ID)
+
+	tests.MaybeFail("WebhookReplay", ce,
+		tests.Expect(string(si.bodies[0]), dls[0].Payload),
+		tests.Expect(len(dlsAfter), 0),
+		tests.Expect(ceReplayed.GetCode(), http.StatusNotFound),
+	)
+}
+
+func TestWebhookSubscribeValidation(t *testing.T) {
+	tests.MaybeFail = tests.InitFailFunc(t)
+
+	ws, _ := newTestWebhookService(1)
+
+	_, ceURL := ws.WebhookSubscribe("ftp://example.com", []string{WebhookEventUserSignup})
+	_, ceEvent := ws.WebhookSubscribe("http://example.com", []string{"user.unknown"})
+	_, ceEmpty := ws.WebhookSubscribe("http://example.com", nil)
+	sub, ce := ws.WebhookSubscribe("http://example.com/hook", []string{WebhookEventUserDeleted})
+	subs, _ := ws.WebhookSubscriptionList()
+	ceDelete := ws.WebhookUnsubscribe(sub.ID)
+	ceDeleteAgain := ws.WebhookUnsubscribe(sub.ID)
+
+	tests.MaybeFail("WebhookSubscribe", ce, ceDelete,
+		tests.Expect(ceURL.GetCode(), http.StatusBadRequest),
+		tests.Expect(ceEvent.GetCode(), http.StatusBadRequest),
+		tests.Expect(ceEmpty.GetCode(), http.StatusBadRequest),
+		tests.Expect(len(subs), 1),
+		tests.Expect(subs[0].Secret, ""),
+		tests.Expect(ceDeleteAgain.GetCode(), http.StatusNotFound),
+	)
+}
@@ -26,6 +26,8 @@ type Jwtoken struct {
 	unknownFields protoimpl.UnknownFields
 
 	Jwt string `protobuf:"bytes,1,opt,name=jwt,proto3" json:"jwt,omitempty"`
+	// when set, the token must belong to this tenant
+	Tenant string `protobuf:"bytes,2,opt,name=tenant,proto3" json:"tenant,omitempty"`
 }
 
 func (x *Jwtoken) Reset() {
@@ -67,6 +69,13 @@ func (x *Jwtoken) GetJwt() string {
 	return ""
 }
 
+func (x *Jwtoken) GetTenant() string {
+	if x != nil {
+		return x.Tenant
+	}
+	return ""
+}
+
 type IsJwtokenOKResponse struct {
 	state         protoimpl.MessageState
 	sizeCache     protoimpl.SizeCache
@@ -77,6 +86,7 @@ type IsJwtokenOKResponse struct {
 	Role         string `protobuf:"bytes,3,opt,name=role,proto3" json:"role,omitempty"`
 	Svc          string `protobuf:"bytes,4,opt,name=svc,proto3" json:"svc,omitempty"`
 	Scope        string `protobuf:"bytes,5,opt,name=scope,proto3" json:"scope,omitempty"`
+	Tenant       string `protobuf:"bytes,6,opt,name=tenant,proto3" json:"tenant,omitempty"`
 }
 
 func (x *IsJwtokenOKResponse) Reset() {
@@ -146,23 +156,33 @@ func (x *IsJwtokenOKResponse) GetScope() string {
 	return ""
 }
 
+func (x *IsJwtokenOKResponse) GetTenant() string {
+	if x != nil {
+		return x.Tenant
+	}
+	return ""
+}
+
 var File_api_v1_brokerjwt_Brokerjwt_proto protoreflect.FileDescriptor
 
 var file_api_v1_brokerjwt_Brokerjwt_proto_rawDesc = []byte{
 	0x0a, 0x20, 0x61, 0x70, 0x69, 0x2f, 0x76, 0x31, 0x2f, 0x62, 0x72, 0x6f, 0x6b, 0x65, 0x72, 0x6a,
 	0x77, 0x74, 0x2f, 0x42, 0x72, 0x6f, 0x6b, 0x65, 0x72, 0x6a, 0x77, 0x74, 0x2e, 0x70, 0x72, 0x6f,
 	0x74, 0x6f, 0x12, 0x0c, 0x76, 0x31, 0x5f, 0x62, 0x72, 0x6f, 0x6b, 0x65, 0x72, 0x6a, 0x77, 0x74,
-	0x22, 0x1b, 0x0a, 0x07, 0x4a, 0x77, 0x74, 0x6f, 0x6b, 0x65, 0x6e, 0x12, 0x10, 0x0a, 0x03, 0x6a,
-	0x77, 0x74, 0x18, 0x01, 0x20, 0x01, 0x28, 0x09, 0x52, 0x03, 0x6a, 0x77, 0x74, 0x22, 0x8b, 0x01,
-	0x0a, 0x13, 0x49, 0x73, 0x4a, 0x77, 0x74, 0x6f, 0x6b, 0x65, 0x6e, 0x4f, 0x4b, 0x52, 0x65, 0x73,
-	0x70, 0x6f, 0x6e, 0x73, 0x65, 0x12, 0x13, 0x0a, 0x05, 0x69, 0x73, 0x5f, 0x6f, 0x6b, 0x18, 0x01,
-	0x20, 0x01, 0x28, 0x08, 0x52, 0x04, 0x69, 0x73, 0x4f, 0x6b, 0x12, 0x23, 0x0a, 0x0d, 0x72, 0x65,
-	0x73, 0x70, 0x6f, 0x6e, 0x73, 0x65, 0x5f, 0x63, 0x6f, 0x64, 0x65, 0x18, 0x02, 0x20, 0x01, 0x28,
-	0x05, 0x52, 0x0c, 0x72, 0x65, 0x73, 0x70, 0x6f, 0x6e, 0x73, 0x65, 0x43, 0x6f, 0x64, 0x65, 0x12,
-	0x12, 0x0a, 0x04, 0x72, 0x6f, 0x6c, 0x65, 0x18, 0x03, 0x20, 0x01, 0x28, 0x09, 0x52, 0x04, 0x72,
-	0x6f, 0x6c, 0x65, 0x12, 0x10, 0x0a, 0x03, 0x73, 0x76, 0x63, 0x18, 0x04, 0x20, 0x01, 0x28, 0x09,
-	0x52, 0x03, 0x73, 0x76, 0x63, 0x12, 0x14, 0x0a, 0x05, 0x73, 0x63, 0x6f, 0x70, 0x65, 0x18, 0x05,
-	0x20, 0x01, 0x28, 0x09, 0x52, 0x05, 0x73, 0x63, 0x6f, 0x70, 0x65, 0x32, 0x5c, 0x0a, 0x11, 0x4a,
+	0x22, 0x33, 0x0a, 0x07, 0x4a, 0x77, 0x74, 0x6f, 0x6b, 0x65, 0x6e, 0x12, 0x10, 0x0a, 0x03, 0x6a,
+	0x77, 0x74, 0x18, 0x01, 0x20, 0x01, 0x28, 0x09, 0x52, 0x03, 0x6a, 0x77, 0x74, 0x12, 0x16, 0x0a,
+	0x06, 0x74, 0x65, 0x6e, 0x61, 0x6e, 0x74, 0x18, 0x02, 0x20, 0x01, 0x28, 0x09, 0x52, 0x06, 0x74,
+	0x65, 0x6e, 0x61, 0x6e, 0x74, 0x22, 0xa3, 0x01, 0x0a, 0x13, 0x49, 0x73, 0x4a, 0x77, 0x74, 0x6f,
+	0x6b, 0x65, 0x6e, 0x4f, 0x4b, 0x52, 0x65, 0x73, 0x70, 0x6f, 0x6e, 0x73, 0x65, 0x12, 0x13, 0x0a,
+	0x05, 0x69, 0x73, 0x5f, 0x6f, 0x6b, 0x18, 0x01, 0x20, 0x01, 0x28, 0x08, 0x52, 0x04, 0x69, 0x73,
+	0x4f, 0x6b, 0x12, 0x23, 0x0a, 0x0d, 0x72, 0x65, 0x73, 0x70, 0x6f, 0x6e, 0x73, 0x65, 0x5f, 0x63,
+	0x6f, 0x64, 0x65, 0x18, 0x02, 0x20, 0x01, 0x28, 0x05, 0x52, 0x0c, 0x72, 0x65, 0x73, 0x70, 0x6f,
+	0x6e, 0x73, 0x65, 0x43, 0x6f, 0x64, 0x65, 0x12, 0x12, 0x0a, 0x04, 0x72, 0x6f, 0x6c, 0x65, 0x18,
+	0x03, 0x20, 0x01, 0x28, 0x09, 0x52, 0x04, 0x72, 0x6f, 0x6c, 0x65, 0x12, 0x10, 0x0a, 0x03, 0x73,
+	0x76, 0x63, 0x18, 0x04, 0x20, 0x01, 0x28, 0x09, 0x52, 0x03, 0x73, 0x76, 0x63, 0x12, 0x14, 0x0a,
+	0x05, 0x73, 0x63, 0x6f, 0x70, 0x65, 0x18, 0x05, 0x20, 0x01, 0x28, 0x09, 0x52, 0x05, 0x73, 0x63,
+	0x6f, 0x70, 0x65, 0x12, 0x16, 0x0a, 0x06, 0x74, 0x65, 0x6e, 0x61, 0x6e, 0x74, 0x18, 0x06, 0x20,
+	0x01, 0x28, 0x09, 0x52, 0x06, 0x74, 0x65, 0x6e, 0x61, 0x6e, 0x74, 0x32, 0x5c, 0x0a, 0x11, 0x4a,
 	0x77, 0x74, 0x6f, 0x6b, 0x65, 0x6e, 0x4d, 0x61, 0x6e, 0x61, 0x67, 0x65, 0x6d, 0x65, 0x6e, 0x74,
 	0x12, 0x47, 0x0a, 0x0b, 0x49, 0x73, 0x4a, 0x77, 0x74, 0x6f, 0x6b, 0x65, 0x6e, 0x4f, 0x4b, 0x12,
 	0x15, 0x2e, 0x76, 0x31, 0x5f, 0x62, 0x72, 0x6f, 0x6b, 0x65, 0x72, 0x6a, 0x77, 0x74, 0x2e, 0x4a,
@@ -8,9 +8,11 @@ import (
 	"gitlab.com/grpasr/asonrythme/broker_svc/broker/internal/handlers/grpc/servers"
 	"gitlab.com/grpasr/asonrythme/broker_svc/broker/internal/handlers/rest"
 	"gitlab.com/grpasr/asonrythme/broker_svc/broker/internal/services"
+	"gitlab.com/grpasr/asonrythme/shared/tokensource"
 	"gitlab.com/grpasr/common/apiserver"
 	obs "gitlab.com/grpasr/common/observability"
 	"log"
+	"time"
 )
 
 // see if a port is in use: sudo lsof -i :50002
@@ -21,7 +23,10 @@ import (
 // 	collectorEndpoint         = "localhost:4317"
 // )
 
-var conf *config.Config
+var (
+	conf        *config.Config
+	tokenSource *tokensource.TokenSource
+)
 
 func init() {
 
@@ -31,13 +36,19 @@ func init() {
 		log.Fatal("Error setting configs: ", err)
 	}
 
+	// a fresh PKCE code_verifier is generated for each login
+	codeVerifier, err := conf.JwtNewCodeVerifier()
+	if err != nil {
+		log.Fatal("broker_svc error generating the code_verifier: ", err)
+	}
+
 	// get jwtoken from auth_svc
 	apiServerAuth := apiserver.NewAPIserverAuth(
 		conf.JwtGetAuthSvcURL(),           // "http://localhost:9096/v1", // all varEnv
 		conf.JwtGetAuthSvcPath(),          // "apiauth",
 		conf.HTTPGetHTTPFormatedURL(),     // "http://localhost:8080",
 		conf.JwtGetAuthSvcTokenEndpoint(), // "http://localhost:9096/v1/oauth/token",
-		conf.JwtGetCodeVerifier(),         //"exampleCodeVerifier",
+		codeVerifier,                      // fresh per login
 		conf.JwtGetServiceKeyID(),         // "brokerSvc",
 		conf.JwtGetServiceSecretKey(),     // "brokerSvcSecret",
 		conf.JwtGetScope(),                // "read, openid",
@@ -48,8 +59,21 @@ func init() {
 		log.Fatal("Order svc error authentication: ", err)
 	}
 
-	conf.GlbSetJWToken(apiServerAuth.GetToken())
-	log.Println("broker_svc/broker see the jwtoken: ", conf.GlbGetJWToken())
+	// the jwtoken is refreshed ahead of its expiry, see Run
+	tokenSource, err = tokensource.New(apiServerAuth.GetToken(), tokensource.Config{
+		AuthSvcURL:   conf.JwtGetAuthSvcURL(),
+		ClientID:     conf.JwtGetServiceKeyID(),
+		ClientSecret: conf.JwtGetServiceSecretKey(),
+	})
+	if err != nil {
+		log.Fatal("broker_svc error reading the jwtoken: ", err)
+	}
+	log.Println("broker_svc/broker see the jwtoken expiry: ", tokenSource.Expiry())
+
+	// the configuration of registry_svc override the local one
+	if conf.KVGetURL() != "" {
+		loadRemoteConfigs()
+	}
 }
 
 func Run() {
@@ -92,7 +116,9 @@ func Run() {
 
 	}
 
-	grpcClients, err := clients.NewGRPCClients(conf)
+	go tokenSource.Run(ctx)
+
+	grpcClients, err := clients.NewGRPCClients(conf, tokenSource)
 	if err != nil {
 		obs.Logging.NewLogHandler(obs.Logging.LLHFatal(), ctx).
 			Err(err).
@@ -102,8 +128,12 @@ func Run() {
 	restServices := services.NewRestServices(grpcClients, conf)
 	grpcServices := services.NewGrpcServices(grpcClients, conf)
 
+	// the readiness, served on http and grpc, refreshed until Run return
+	healthChecker := setHealth(conf, grpcClients, grpcServices, tokenSource)
+	go healthChecker.HealthWatch(ctx, time.Duration(conf.HTTPGetHealthInterval())*time.Second)
+
 	// run grpcServer
-	grpcServer, err := servers.NewGRPCServers(grpcServices, conf)
+	grpcServer, err := servers.NewGRPCServers(grpcServices, conf, healthChecker)
 	if err != nil {
 		obs.Logging.NewLogHandler(obs.Logging.LLHFatal(), ctx).
 			Err(err).
@@ -112,5 +142,5 @@ func Run() {
 	grpcServer.GRPCServersListen()
 
 	// run restServer
-	rest.Handler(restServices, conf)
+	rest.Handler(restServices, conf, healthChecker)
 }
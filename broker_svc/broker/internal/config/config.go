@@ -1,7 +1,9 @@
 package config
 
 import (
+	"crypto/rand"
 	"crypto/tls"
+	"encoding/base64"
 	"fmt"
 	"github.com/spf13/viper"
 	"os"
@@ -23,9 +25,29 @@ const (
 	httpAddressDefault string = "localhost"
 	httpPortDefault           = "8080"
 
+	// health checks
+	healthTimeoutDefault  = 3  // seconds given to each dependency's check
+	healthIntervalDefault = 10 // seconds between the grpc health refreshes
+
+	// the scopes a principal need on the routes
+	orderReadScopeDefault  = "orders:read"
+	orderWriteScopeDefault = "orders:write"
+	adminScopeDefault      = "admin" // /v1/logging
+
+	// discovery, the services are resolved by registry_svc if its url is
+	// set, from the servicesAddr files otherwise
+	discoveryURLDefault      = ""
+	discoveryIntervalDefault = 10 // seconds between the resolutions
+
+	// config store of registry_svc, disabled if its url is not set
+	configStoreURLDefault   = ""
+	configStoreCacheDefault = "../broker/configs/v1/remote/config.json"
+
 	// grpc
 	grpcAddressDefault       = "localhost" // !!! 127.0.0.1 does not work
 	jwtValidationPortDefault = "50002"
+	authGrpcAddressDefault   = "localhost"
+	authGrpcPortDefault      = "50003"
 
 	// tls
 	pathToTLSDefault      string = "../broker/configs/v1/certificates"
@@ -39,13 +61,10 @@ const (
 	authSvcURLDefault           string = "http://localhost:9096/v1" // all varEnv
 	authSvcPathDefault                 = "apiauth"
 	authSvcTokenEndpointDefault        = "http://localhost:9096/v1/oauth/token"
-	codeVerifierDefault                = "exampleCodeVerifier"
 	serviceKeyIDDefault                = "brokerSvc"
 	serviceSecretkeyDefault            = "brokerSvcSecret"
 	scopeDefault                       = "read, openid"
-
-	// TOKENservice
-	signedKeyDefault string = "mySecretKey"
+	codeVerifierLength                 = 32 // random bytes, 43 chars once base64url encoded
 
 	// observability
 	obsSamplingDefault          float64 = 0.6
@@ -76,33 +95,60 @@ func SetConfigs() (*Config, error) {
 	c.httpSetAddress(svcAddr)
 	c.httpSetPort(svcPort)
 
+	// set the health checks
+	healthTimeout := os.Getenv("HEALTH_CHECK_TIMEOUT")
+	healthInterval := os.Getenv("HEALTH_CHECK_INTERVAL")
+	c.httpSetHealthTimeout(healthTimeout)
+	c.httpSetHealthInterval(healthInterval)
+
+	// set the scopes of the routes
+	orderReadScope := os.Getenv("ORDER_READ_SCOPE")
+	orderWriteScope := os.Getenv("ORDER_WRITE_SCOPE")
+	adminScope := os.Getenv("ADMIN_SCOPE")
+	c.httpSetOrderReadScope(orderReadScope)
+	c.httpSetOrderWriteScope(orderWriteScope)
+	c.httpSetAdminScope(adminScope)
+
+	// set the discovery
+	discoveryURL := os.Getenv("DISCOVERY_URL")
+	discoveryInterval := os.Getenv("DISCOVERY_INTERVAL")
+	c.dscSetURL(discoveryURL)
+	c.dscSetInterval(discoveryInterval)
+
+	// set the config store, the instance is the host name by default
+	storeURL := os.Getenv("CONFIG_STORE_URL")
+	storeCache := os.Getenv("CONFIG_STORE_CACHE")
+	instance := os.Getenv("SERVICE_INSTANCE")
+	c.kvSetURL(storeURL)
+	c.kvSetCacheFile(storeCache)
+	c.kvSetInstance(instance)
+
 	// set grpc configs
 	grpcAddr := os.Getenv("GRPC_ADDRESS")
 	grpcJwtValidationPort := os.Getenv("GRPC_JWT_VALIDATION_PORT")
 	c.grpcSetAddress(grpcAddr)
 	c.grpcSetJwtValidationPort(grpcJwtValidationPort)
 
+	// auth_svc grpc server, validate the jwt
+	authGrpcAddr := os.Getenv("AUTH_GRPC_ADDRESS")
+	authGrpcPort := os.Getenv("AUTH_GRPC_PORT")
+	c.grpcSetAuthAddress(authGrpcAddr)
+	c.grpcSetAuthPort(authGrpcPort)
+
 	// set the JWTRequestConfig
 	authSvcURL := os.Getenv("AUTH_SVC_URL")
 	authSvcPATH := os.Getenv("AUTH_SVC_PATH")
 	authSvcTokenEndpoint := os.Getenv("AUTH_SVC_TOKEN_ENDPOINT")
-	codeVerifier := os.Getenv("CODE_VERIFIER")
 	serviceKeyID := os.Getenv("SERVICE_KEY_ID")
 	serviceSecretkey := os.Getenv("SERVICE_SECRET_KEY")
 	scope := os.Getenv("SCOPE")
 	c.jwtSetAuthSvcURL(authSvcURL)
 	c.jwtSetAuthSvcPath(authSvcPATH)
 	c.jwtSetAuthSvcTokenEndpoint(authSvcTokenEndpoint)
-	c.jwtSetCodeVerifier(codeVerifier)
 	c.jwtSetServiceKeyID(serviceKeyID)
 	c.jwtSetServiceSecretKey(serviceSecretkey)
 	c.jwtSetScope(scope)
 
-	// set configs related to jwtTokenValidation
-	// TODO in prod must get it from secrets
-	signedKey := os.Getenv("SIGNED_KEY")
-	c.tokenSetSignedKey(signedKey)
-
 	// set the observability
 	sampling := os.Getenv("OBS_SAMPLING")
 	scrDelay := os.Getenv("OBS_SCRATCH_DELAY")
@@ -244,10 +290,11 @@ type Config struct {
 	*http
 	*grpc
 	*jwtRequestConfig
-	*token
 	ClientTLSConfig *tls.Config
 	ServerTLSConfig *tls.Config
 	*services
+	*discovery
+	*configStore
 	*observability
 }
 
@@ -272,7 +319,8 @@ func NewConfig(goEnv string, serviceName ...string) *Config {
 		http:             http,
 		grpc:             grpc,
 		jwtRequestConfig: NewJWTRequestConfig(),
-		token:            NewToken(),
+		discovery:        NewDiscovery(),
+		configStore:      NewConfigStore(),
 		observability:    NewObservability(),
 	}
 
@@ -283,7 +331,6 @@ func NewConfig(goEnv string, serviceName ...string) *Config {
 type global struct {
 	golangEnv   string
 	serviceName string
-	jwtoken     string
 }
 
 func NewGlobal(golangEnv, svcName string) *global {
@@ -296,14 +343,6 @@ func (g *global) GlbGetenv() string {
 	return g.golangEnv
 }
 
-func (g *global) GlbSetJWToken(tkn string) {
-	g.jwtoken = tkn
-}
-
-func (g *global) GlbGetJWToken() string {
-	return g.jwtoken
-}
-
 func (g *global) GLBSetServiceName(srvName string) {
 	if srvName != "" {
 		g.serviceName = srvName
@@ -316,8 +355,13 @@ func (g *global) GlbGetServiceName() string {
 
 // HTTTP configs
 type http struct {
-	address string
-	port    string
+	address         string
+	port            string
+	healthTimeout   int
+	healthInterval  int
+	orderReadScope  string
+	orderWriteScope string
+	adminScope      string
 }
 
 func NewHttp() *http {
@@ -326,6 +370,11 @@ func NewHttp() *http {
 	// set default
 	h.address = httpAddressDefault
 	h.port = httpPortDefault
+	h.healthTimeout = healthTimeoutDefault
+	h.healthInterval = healthIntervalDefault
+	h.orderReadScope = orderReadScopeDefault
+	h.orderWriteScope = orderWriteScopeDefault
+	h.adminScope = adminScopeDefault
 
 	return h
 }
@@ -354,10 +403,97 @@ func (h *http) HTTPGetHTTPFormatedURL() string {
 	return fmt.Sprintf("http://%v:%v", h.address, h.port)
 }
 
+func (h *http) httpSetHealthTimeout(timeout string) {
+	if t, err := strconv.Atoi(timeout); err == nil && t > 0 {
+		h.healthTimeout = t
+	}
+}
+
+func (h *http) HTTPGetHealthTimeout() int {
+	return h.healthTimeout
+}
+
+func (h *http) httpSetHealthInterval(interval string) {
+	if i, err := strconv.Atoi(interval); err == nil && i > 0 {
+		h.healthInterval = i
+	}
+}
+
+func (h *http) HTTPGetHealthInterval() int {
+	return h.healthInterval
+}
+
+func (h *http) httpSetOrderReadScope(scope string) {
+	if scope != "" {
+		h.orderReadScope = scope
+	}
+}
+
+func (h *http) HTTPGetOrderReadScope() string {
+	return h.orderReadScope
+}
+
+func (h *http) httpSetOrderWriteScope(scope string) {
+	if scope != "" {
+		h.orderWriteScope = scope
+	}
+}
+
+func (h *http) HTTPGetOrderWriteScope() string {
+	return h.orderWriteScope
+}
+
+func (h *http) httpSetAdminScope(scope string) {
+	if scope != "" {
+		h.adminScope = scope
+	}
+}
+
+func (h *http) HTTPGetAdminScope() string {
+	return h.adminScope
+}
+
+// discovery configs, registry_svc resolve the services
+type discovery struct {
+	url      string
+	interval int
+}
+
+func NewDiscovery() *discovery {
+	return &discovery{
+		url:      discoveryURLDefault,
+		interval: discoveryIntervalDefault,
+	}
+}
+
+func (d *discovery) dscSetURL(url string) {
+	if url != "" {
+		d.url = url
+	}
+}
+
+// DSCGetURL is the url of registry_svc, "http://localhost:4000", empty if
+// the services are read from the servicesAddr files
+func (d *discovery) DSCGetURL() string {
+	return d.url
+}
+
+func (d *discovery) dscSetInterval(interval string) {
+	if i, err := strconv.Atoi(interval); err == nil && i > 0 {
+		d.interval = i
+	}
+}
+
+func (d *discovery) DSCGetInterval() int {
+	return d.interval
+}
+
 // GRPC configs
 type grpc struct {
 	grpcAddress       string
 	jwtValidationPort string
+	authAddress       string
+	authPort          string
 }
 
 func NewGrpc() *grpc {
@@ -366,6 +502,8 @@ func NewGrpc() *grpc {
 	// set default
 	g.grpcAddress = grpcAddressDefault
 	g.jwtValidationPort = jwtValidationPortDefault
+	g.authAddress = authGrpcAddressDefault
+	g.authPort = authGrpcPortDefault
 
 	return g
 }
@@ -388,6 +526,24 @@ func (g *grpc) GRPCGetJwtValidationPort() string {
 	return g.jwtValidationPort
 }
 
+func (g *grpc) grpcSetAuthAddress(address string) {
+	if address != "" {
+		g.authAddress = address
+	}
+}
+func (g *grpc) GRPCGetAuthAddress() string {
+	return g.authAddress
+}
+
+func (g *grpc) grpcSetAuthPort(port string) {
+	if port != "" {
+		g.authPort = port
+	}
+}
+func (g *grpc) GRPCGetAuthPort() string {
+	return g.authPort
+}
+
 // setTLSConfig set the tls configuration
 func setTLSConfig(c *Config, ccf, ckf, scf, skf, ca, pathToTLS string) error {
 	// client tls config
@@ -447,7 +603,6 @@ type jwtRequestConfig struct {
 	authSvcURL           string
 	authSvcPath          string
 	authSvcTokenEndpoint string
-	codeVerifier         string
 	serviceKeyID         string
 	serviceSecretkey     string
 	scope                string
@@ -458,7 +613,6 @@ func NewJWTRequestConfig() *jwtRequestConfig {
 	r.authSvcURL = authSvcURLDefault
 	r.authSvcPath = authSvcPathDefault
 	r.authSvcTokenEndpoint = authSvcTokenEndpointDefault
-	r.codeVerifier = codeVerifierDefault
 	r.serviceKeyID = serviceKeyIDDefault
 	r.serviceSecretkey = serviceSecretkeyDefault
 	r.scope = scopeDefault
@@ -492,13 +646,14 @@ func (r *jwtRequestConfig) JwtGetAuthSvcTokenEndpoint() string {
 	return r.authSvcTokenEndpoint
 }
 
-func (r *jwtRequestConfig) jwtSetCodeVerifier(v string) {
-	if v != "" {
-		r.codeVerifier = v
+// JwtNewCodeVerifier generate a fresh PKCE code_verifier(rfc7636),
+// a new one must be used for each login
+func (r *jwtRequestConfig) JwtNewCodeVerifier() (string, error) {
+	b := make([]byte, codeVerifierLength)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
 	}
-}
-func (r *jwtRequestConfig) JwtGetCodeVerifier() string {
-	return r.codeVerifier
+	return base64.RawURLEncoding.EncodeToString(b), nil
 }
 
 func (r *jwtRequestConfig) jwtSetServiceKeyID(v string) {
@@ -528,25 +683,6 @@ func (r *jwtRequestConfig) JwtGetScope() string {
 	return r.scope
 }
 
-// token handle jwtToken validation
-type token struct {
-	signedKey string
-}
-
-func NewToken() *token {
-	return &token{signedKeyDefault}
-}
-
-func (t *token) tokenSetSignedKey(signedKey string) {
-	if signedKey != "" {
-		t.signedKey = signedKey
-	}
-}
-
-func (t *token) TOKENGetSignedKey() string {
-	return t.signedKey
-}
-
 // observability handle the observability configs
 type observability struct {
 	obsSampling          float64
@@ -19,16 +19,16 @@ func Test_default_configs(t *testing.T) {
 
 	tests.MaybeFail("Test_default_configs",
 		tests.Expect(fmt.Sprintf("%v", conf.global), "&{localhost brokerSvc }"),
-		tests.Expect(fmt.Sprintf("%v", conf.http), "&{localhost 8080}"),
-		tests.Expect(fmt.Sprintf("%v", conf.grpc), "&{localhost 50002}"),
-		tests.Expect(fmt.Sprintf("%v", conf.jwtRequestConfig), "&{http://localhost:9096/v1 apiauth http://localhost:9096/v1/oauth/token exampleCodeVerifier brokerSvc brokerSvcSecret read, openid}"),
+		tests.Expect(fmt.Sprintf("%v", conf.http), "&{localhost 8080 3 10 orders:read orders:write admin}"),
+		tests.Expect(fmt.Sprintf("%v", conf.grpc), "&{localhost 50002 localhost 50003}"),
+		tests.Expect(fmt.Sprintf("%v", conf.jwtRequestConfig), "&{http://localhost:9096/v1 apiauth http://localhost:9096/v1/oauth/token brokerSvc brokerSvcSecret read, openid}"),
 		tests.Expect(fmt.Sprintf("%v", conf.SVCSGetServices()), "map[order:{localhost 50001} preorder:{localhost 50001}]"),
+		tests.Expect(fmt.Sprintf("%v", conf.discovery), "&{ 10}"),
 		tests.Expect(fmt.Sprintf("%v", conf.OBSGetSampling()), "0.6"),
 		tests.Expect(fmt.Sprintf("%v", conf.OBSGetScratchDelay()), "30"),
 		tests.Expect(fmt.Sprintf("%v", conf.OBSGetCollectorEndpoint()), "otel_collector:4317"),
 		tests.Expect(conf.ClientTLSConfig != nil, true),
 		tests.Expect(conf.ServerTLSConfig != nil, true),
-		tests.Expect(fmt.Sprintf("%v", conf.TOKENGetSignedKey()), "mySecretKey"),
 	)
 }
 
@@ -43,6 +43,11 @@ func Test_configs_development_env(t *testing.T) {
 	// set http
 	os.Setenv("SERVICE_ADDRESS", "serviceAddress")
 	os.Setenv("SERVICE_PORT", "servicePort")
+	os.Setenv("HEALTH_CHECK_TIMEOUT", "5")
+	os.Setenv("HEALTH_CHECK_INTERVAL", "20")
+	os.Setenv("ORDER_READ_SCOPE", "read")
+	os.Setenv("ORDER_WRITE_SCOPE", "write")
+	os.Setenv("ADMIN_SCOPE", "all")
 
 	// set Grpc
 	os.Setenv("GRPC_BROKER_SVC_PORT", "grpcBrokerSvcPort")
@@ -52,13 +57,17 @@ func Test_configs_development_env(t *testing.T) {
 	os.Setenv("AUTH_SVC_URL", "authSvcUrl")
 	os.Setenv("AUTH_SVC_PATH", "authSvcPath")
 	os.Setenv("AUTH_SVC_TOKEN_ENDPOINT", "authSvcTokenEndpoint")
-	os.Setenv("CODE_VERIFIER", "codeVerifier")
 	os.Setenv("SERVICE_KEY_ID", "serviceKeyID")
 	os.Setenv("SERVICE_SECRET_KEY", "serviceSecretKey")
 	os.Setenv("SCOPE", "scope")
 
-	// set jwt token signedKey
-	os.Setenv("SIGNED_KEY", "newSecret")
+	// set auth_svc grpc
+	os.Setenv("AUTH_GRPC_ADDRESS", "authSvc")
+	os.Setenv("AUTH_GRPC_PORT", "authGrpcPort")
+
+	// set discovery
+	os.Setenv("DISCOVERY_URL", "http://registry:4000")
+	os.Setenv("DISCOVERY_INTERVAL", "5")
 
 	// set observability
 	os.Setenv("OBS_SAMPLING", "1")
@@ -70,14 +79,51 @@ func Test_configs_development_env(t *testing.T) {
 	tests.MaybeFail("Test_default_configs",
 		// tests.Expect(fmt.Sprintf("%v", conf.Global), "&{golangEnv serviceName serviceUrl}"),
 		tests.Expect(fmt.Sprintf("%v", conf.global), "&{development serviceName }"),
-		tests.Expect(fmt.Sprintf("%v", conf.http), "&{serviceAddress servicePort}"),
-		tests.Expect(fmt.Sprintf("%v", conf.grpc), "&{localhost 50002}"),
-		tests.Expect(fmt.Sprintf("%v", conf.jwtRequestConfig), "&{authSvcUrl authSvcPath authSvcTokenEndpoint codeVerifier serviceKeyID serviceSecretKey scope}"),
+		tests.Expect(fmt.Sprintf("%v", conf.http), "&{serviceAddress servicePort 5 20 read write all}"),
+		tests.Expect(fmt.Sprintf("%v", conf.grpc), "&{localhost 50002 authSvc authGrpcPort}"),
+		tests.Expect(fmt.Sprintf("%v", conf.jwtRequestConfig), "&{authSvcUrl authSvcPath authSvcTokenEndpoint serviceKeyID serviceSecretKey scope}"),
 		tests.Expect(fmt.Sprintf("%v", conf.SVCSGetServices()), "map[order:{order 50001} preorder:{preOrder 50001}]"),
 		tests.Expect(fmt.Sprintf("%v", conf.SVCSGetServices()["order"]), "{order 50001}"),
+		tests.Expect(fmt.Sprintf("%v", conf.discovery), "&{http://registry:4000 5}"),
 		tests.Expect(fmt.Sprintf("%v", conf.OBSGetSampling()), "1"),
 		tests.Expect(fmt.Sprintf("%v", conf.OBSGetScratchDelay()), "2"),
 		tests.Expect(fmt.Sprintf("%v", conf.OBSGetCollectorEndpoint()), "collector"),
-		tests.Expect(fmt.Sprintf("%v", conf.TOKENGetSignedKey()), "newSecret"),
+	)
+}
+
+func Test_remote_configs(t *testing.T) {
+	tests.MaybeFail = tests.InitFailFunc(t)
+
+	conf := NewConfig("localhost", "")
+
+	// the keys not set in registry_svc keep the local values
+	r := conf.Remote()
+	r.Health.Interval = 20
+	r.Discovery.URL = "http://registry:4000"
+	r.Obs.Sampling = 0.1
+	r.Obs.ScratchDelay = 45
+	conf.ApplyRemote(r)
+
+	tests.MaybeFail("Test_remote_configs",
+		tests.Expect(conf.HTTPGetHealthTimeout(), 3),
+		tests.Expect(conf.HTTPGetHealthInterval(), 20),
+		tests.Expect(conf.DSCGetURL(), "http://registry:4000"),
+		tests.Expect(conf.OBSGetSampling(), 0.1),
+		tests.Expect(conf.OBSGetScratchDelay(), 45),
+	)
+}
+
+func Test_new_code_verifier(t *testing.T) {
+	tests.MaybeFail = tests.InitFailFunc(t)
+
+	conf, _ := SetConfigs()
+
+	v1, err1 := conf.JwtNewCodeVerifier()
+	v2, err2 := conf.JwtNewCodeVerifier()
+
+	tests.MaybeFail("Test_new_code_verifier",
+		tests.Expect(err1 == nil && err2 == nil, true),
+		tests.Expect(len(v1), 43),
+		tests.Expect(v1 != v2, true),
 	)
 }
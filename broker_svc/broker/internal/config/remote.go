@@ -0,0 +1,100 @@
+package config
+
+import (
+	"os"
+	"strconv"
+)
+
+// configStore locate the configuration of the broker in registry_svc
+type configStore struct {
+	storeURL  string
+	cacheFile string
+	instance  string
+}
+
+func NewConfigStore() *configStore {
+	cs := &configStore{
+		storeURL:  configStoreURLDefault,
+		cacheFile: configStoreCacheDefault,
+	}
+	cs.instance, _ = os.Hostname()
+	return cs
+}
+
+func (cs *configStore) kvSetURL(url string) {
+	if url != "" {
+		cs.storeURL = url
+	}
+}
+
+// KVGetURL is the url of registry_svc, "http://localhost:4000", empty if
+// only the local configuration is used
+func (cs *configStore) KVGetURL() string {
+	return cs.storeURL
+}
+
+func (cs *configStore) kvSetCacheFile(path string) {
+	if path != "" {
+		cs.cacheFile = path
+	}
+}
+
+// KVGetCacheFile is the copy of the last configuration received, used if
+// registry_svc can not be reached
+func (cs *configStore) KVGetCacheFile() string {
+	return cs.cacheFile
+}
+
+func (cs *configStore) kvSetInstance(instance string) {
+	if instance != "" {
+		cs.instance = instance
+	}
+}
+
+func (cs *configStore) KVGetInstance() string {
+	return cs.instance
+}
+
+// Remote is the configuration the broker read from registry_svc, the keys
+// are "health.interval", "discovery.url", "obs.sampling"...
+type Remote struct {
+	Health struct {
+		Timeout  int `json:"timeout"`
+		Interval int `json:"interval"`
+	} `json:"health"`
+	Discovery struct {
+		URL      string `json:"url"`
+		Interval int    `json:"interval"`
+	} `json:"discovery"`
+	Obs struct {
+		Sampling          float64 `json:"sampling"`
+		ScratchDelay      int     `json:"scratchDelay"`
+		CollectorEndpoint string  `json:"collectorEndpoint"`
+	} `json:"obs"`
+}
+
+// Remote return the current values, the local fallbacks of the ones not
+// set in registry_svc
+func (c *Config) Remote() Remote {
+	r := Remote{}
+	r.Health.Timeout = c.HTTPGetHealthTimeout()
+	r.Health.Interval = c.HTTPGetHealthInterval()
+	r.Discovery.URL = c.DSCGetURL()
+	r.Discovery.Interval = c.DSCGetInterval()
+	r.Obs.Sampling = c.OBSGetSampling()
+	r.Obs.ScratchDelay = c.OBSGetScratchDelay()
+	r.Obs.CollectorEndpoint = c.OBSGetCollectorEndpoint()
+	return r
+}
+
+// ApplyRemote set the values read from registry_svc, the invalid ones
+// are ignored by the setters
+func (c *Config) ApplyRemote(r Remote) {
+	c.httpSetHealthTimeout(strconv.Itoa(r.Health.Timeout))
+	c.httpSetHealthInterval(strconv.Itoa(r.Health.Interval))
+	c.dscSetURL(r.Discovery.URL)
+	c.dscSetInterval(strconv.Itoa(r.Discovery.Interval))
+	c.obsSetSampling(strconv.FormatFloat(r.Obs.Sampling, 'f', -1, 64))
+	c.obsSetScratchDelay(strconv.Itoa(r.Obs.ScratchDelay))
+	c.obsSetCollectorEndpoint(r.Obs.CollectorEndpoint)
+}
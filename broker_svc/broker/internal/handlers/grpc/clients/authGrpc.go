@@ -0,0 +1,64 @@
+package clients
+
+import (
+	"context"
+	"fmt"
+	pb "gitlab.com/grpasr/asonrythme/broker_svc/broker/api/v1/auth"
+	"gitlab.com/grpasr/asonrythme/broker_svc/broker/internal/config"
+	obs "gitlab.com/grpasr/common/observability"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials"
+)
+
+// AuthGrpc is the client of the auth_svc token operations
+type AuthGrpc struct {
+	conn   *grpc.ClientConn
+	client pb.AuthManagementClient
+}
+
+func NewAuthGrpc(conf *config.Config, tokenCreds credentials.PerRPCCredentials) (*AuthGrpc, error) {
+	ag := &AuthGrpc{}
+	err := ag.authSetClient(conf, tokenCreds)
+	return ag, err
+}
+
+func (a *AuthGrpc) authSetClient(conf *config.Config, tokenCreds credentials.PerRPCCredentials) error {
+	ctx := context.Background()
+
+	// set TLS
+	creds := credentials.NewTLS(conf.ClientTLSConfig)
+
+	opts := []grpc.DialOption{
+		grpc.WithTransportCredentials(creds),
+		grpc.WithPerRPCCredentials(tokenCreds),
+	}
+	if conf.GlbGetenv() != "localhost" {
+		opts = append(opts, grpc.WithUnaryInterceptor(obs.Tracing.GRPCTraceInterceptorClient))
+	}
+
+	conn, err := grpc.DialContext(
+		ctx,
+		fmt.Sprintf("%s:%s",
+			conf.GRPCGetAuthAddress(),
+			conf.GRPCGetAuthPort()),
+		opts...,
+	)
+	if err != nil {
+		return err
+	}
+
+	obs.Logging.NewLogHandler(obs.Logging.LLHInfo()).
+		Msg("grpc auth client is ready")
+	a.conn = conn
+	a.client = pb.NewAuthManagementClient(conn)
+	return nil
+}
+
+func (a *AuthGrpc) AuthGetClient() pb.AuthManagementClient {
+	return a.client
+}
+
+// AuthGetConn is the connection to auth_svc, for the readiness
+func (a *AuthGrpc) AuthGetConn() *grpc.ClientConn {
+	return a.conn
+}
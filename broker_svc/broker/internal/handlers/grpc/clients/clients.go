@@ -2,6 +2,7 @@ package clients
 
 import (
 	"gitlab.com/grpasr/asonrythme/broker_svc/broker/internal/config"
+	"google.golang.org/grpc/credentials"
 )
 
 // const grpcPort = "50001"
@@ -9,19 +10,29 @@ import (
 // GRPCHandler is an handle to all grpc clients
 type GRPCClients struct {
 	*OrderGrpc
+	*AuthGrpc
 }
 
-func NewGRPCClients(config *config.Config) (GRPCClients, error) {
+// NewGRPCClients dial the services, the requests carry the broker's
+// jwtoken read from tokenCreds
+func NewGRPCClients(config *config.Config, tokenCreds credentials.PerRPCCredentials) (GRPCClients, error) {
 	def := GRPCClients{}
 
 	// orderClient
-	orderGRPC, err := NewOrderGrpc(config)
+	orderGRPC, err := NewOrderGrpc(config, tokenCreds)
+	if err != nil {
+		return def, err
+	}
+
+	// authClient
+	authGRPC, err := NewAuthGrpc(config, tokenCreds)
 	if err != nil {
 		return def, err
 	}
 
 	grpcHandler := GRPCClients{
 		OrderGrpc: orderGRPC,
+		AuthGrpc:  authGRPC,
 	}
 	return grpcHandler, nil
 }
@@ -5,9 +5,11 @@ import (
 	"fmt"
 	pb "gitlab.com/grpasr/asonrythme/broker_svc/broker/api/v1/order"
 	"gitlab.com/grpasr/asonrythme/broker_svc/broker/internal/config"
+	"gitlab.com/grpasr/asonrythme/shared/discovery"
 	obs "gitlab.com/grpasr/common/observability"
 	"google.golang.org/grpc"
 	"google.golang.org/grpc/credentials"
+	"time"
 )
 
 const (
@@ -15,47 +17,34 @@ const (
 )
 
 type OrderGrpc struct {
+	conn   *grpc.ClientConn
 	client pb.OrderManagementClient
 }
 
-func NewOrderGrpc(conf *config.Config) (*OrderGrpc, error) {
+func NewOrderGrpc(conf *config.Config, tokenCreds credentials.PerRPCCredentials) (*OrderGrpc, error) {
 	og := &OrderGrpc{}
-	err := og.orderSetClient(conf)
+	err := og.orderSetClient(conf, tokenCreds)
 	return og, err
 }
 
-func (o *OrderGrpc) orderSetClient(conf *config.Config) error {
+func (o *OrderGrpc) orderSetClient(conf *config.Config, tokenCreds credentials.PerRPCCredentials) error {
 	ctx := context.Background()
 
 	// set TLS
 	creds := credentials.NewTLS(conf.ClientTLSConfig)
 
-	// set clientConnection
-	var conn *grpc.ClientConn
-	var err error
-	orderSvcInfo := conf.SVCSGetServices()[svcOrder]
+	// set clientConnection, order is resolved by registry_svc if the
+	// discovery is set, the instances are then balanced
+	target, opts := orderTarget(conf, tokenCreds)
+	opts = append(opts,
+		grpc.WithTransportCredentials(creds),
+		grpc.WithPerRPCCredentials(tokenCreds),
+	)
 	if conf.GlbGetenv() != "localhost" {
-		traceDialOption := grpc.WithUnaryInterceptor(obs.Tracing.GRPCTraceInterceptorClient)
-		conn, err = grpc.DialContext(
-			ctx,
-			fmt.Sprintf("%s:%s",
-				orderSvcInfo.SVCGetAddress(),
-				orderSvcInfo.SVCGetPort()),
-			grpc.WithTransportCredentials(creds),
-			traceDialOption,
-			// grpc.WithTransportCredentials(insecure.NewCredentials()),
-		)
-
-	} else {
-		// conn, err = setConnection(ctx, orderSvcInfo, &creds)
-		conn, err = grpc.DialContext(
-			ctx,
-			fmt.Sprintf("%s:%s",
-				orderSvcInfo.SVCGetAddress(),
-				orderSvcInfo.SVCGetPort()),
-			grpc.WithTransportCredentials(creds),
-		)
+		opts = append(opts, grpc.WithUnaryInterceptor(obs.Tracing.GRPCTraceInterceptorClient))
 	}
+
+	conn, err := grpc.DialContext(ctx, target, opts...)
 	if err != nil {
 		return err
 	}
@@ -63,10 +52,34 @@ func (o *OrderGrpc) orderSetClient(conf *config.Config) error {
 	obs.Logging.NewLogHandler(obs.Logging.LLHInfo()).
 		Msg("grpc order client is ready")
 	client := pb.NewOrderManagementClient(conn)
+	o.conn = conn
 	o.client = client
 	return nil
 }
 
+// orderTarget is the static address of order from the servicesAddr
+// files, or its registry_svc target
+func orderTarget(conf *config.Config, tokenCreds credentials.PerRPCCredentials) (string, []grpc.DialOption) {
+	if conf.DSCGetURL() == "" {
+		orderSvcInfo := conf.SVCSGetServices()[svcOrder]
+		return fmt.Sprintf("%s:%s", orderSvcInfo.SVCGetAddress(), orderSvcInfo.SVCGetPort()), nil
+	}
+
+	builder := discovery.NewBuilder(
+		discovery.NewClient(conf.DSCGetURL(), tokenCreds),
+		conf.GlbGetenv(),
+		time.Duration(conf.DSCGetInterval())*time.Second)
+	return discovery.Target(svcOrder), []grpc.DialOption{
+		grpc.WithResolvers(builder),
+		grpc.WithDefaultServiceConfig(`{"loadBalancingConfig": [{"round_robin":{}}]}`),
+	}
+}
+
 func (o *OrderGrpc) OrderGetClient() pb.OrderManagementClient {
 	return o.client
 }
+
+// OrderGetConn is the connection to order, for the readiness
+func (o *OrderGrpc) OrderGetConn() *grpc.ClientConn {
+	return o.conn
+}
@@ -6,6 +6,7 @@ import (
 	pb "gitlab.com/grpasr/asonrythme/broker_svc/broker/api/v1/brokerjwt"
 	"gitlab.com/grpasr/asonrythme/broker_svc/broker/internal/services"
 	"gitlab.com/grpasr/asonrythme/broker_svc/broker/internal/services/jwtokenService"
+	e "gitlab.com/grpasr/common/errors/json"
 	"google.golang.org/grpc"
 	// "google.golang.org/grpc/credentials/insecure"
 )
@@ -37,8 +38,15 @@ func (bs *BrokerjwtServer) IsJwtokenOK(ctx context.Context, jwt *pb.Jwtoken) (*p
 
 	resp := &pb.IsJwtokenOKResponse{}
 
-	// validate the token
-	infos, err := bs.jwtSvc.JWTokenIsValidToken(context.TODO(), jwt.Jwt)
+	// validate the token, when the caller request a tenant
+	// the token must belong to it
+	var infos map[string]string
+	var err e.IError
+	if jwt.GetTenant() != "" {
+		infos, err = bs.jwtSvc.JWTokenIsValidTokenForTenant(ctx, jwt.Jwt, jwt.GetTenant())
+	} else {
+		infos, err = bs.jwtSvc.JWTokenIsValidToken(ctx, jwt.Jwt)
+	}
 	if err != nil {
 		resp.IsOk = false
 		resp.ResponseCode = int32(err.GetCode())
@@ -48,6 +56,7 @@ func (bs *BrokerjwtServer) IsJwtokenOK(ctx context.Context, jwt *pb.Jwtoken) (*p
 		resp.Role = infos["role"]
 		resp.Svc = infos["svc"]
 		resp.Scope = infos["scope"]
+		resp.Tenant = infos["tenant"]
 	}
 
 	return resp, nil
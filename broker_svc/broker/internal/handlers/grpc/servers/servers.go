@@ -2,8 +2,10 @@ package servers
 
 import (
 	"fmt"
+	pb "gitlab.com/grpasr/asonrythme/broker_svc/broker/api/v1/brokerjwt"
 	"gitlab.com/grpasr/asonrythme/broker_svc/broker/internal/config"
 	"gitlab.com/grpasr/asonrythme/broker_svc/broker/internal/services"
+	"gitlab.com/grpasr/asonrythme/shared/health"
 	obs "gitlab.com/grpasr/common/observability"
 	"google.golang.org/grpc"
 	"google.golang.org/grpc/credentials"
@@ -16,7 +18,8 @@ type GRPCServers struct {
 	brokerjwtServer *grpc.Server
 }
 
-func NewGRPCServers(svc services.GrpcServices, configs *config.Config) (*GRPCServers, error) {
+// NewGRPCServers serve the grpc.health.v1 service along each server
+func NewGRPCServers(svc services.GrpcServices, configs *config.Config, hl health.IHealth) (*GRPCServers, error) {
 	def := &GRPCServers{}
 
 	// set tls options
@@ -28,6 +31,7 @@ func NewGRPCServers(svc services.GrpcServices, configs *config.Config) (*GRPCSer
 	if err != nil {
 		return def, err
 	}
+	hl.HealthRegisterGRPC(brokerjwtServer, pb.JwtokenManagement_ServiceDesc.ServiceName)
 
 	return &GRPCServers{
 		configs:         configs,
@@ -1,13 +1,28 @@
 package rest
 
 import (
-	"fmt"
+	"encoding/json"
 	"gitlab.com/grpasr/asonrythme/broker_svc/broker/internal/services/jwtokenService"
-	// "github.com/gorilla/mux"
+	e "gitlab.com/grpasr/common/errors/json"
 	"net/http"
 	"strings"
 )
 
+// headerTenant, when set by the caller, the token must belong to this tenant,
+// when absent the request is served for the token's tenant. headerAPIKey carry
+// the key of a third party integrator instead of a jwt
+const (
+	headerTenant = "X-Tenant-ID"
+	headerAPIKey = "X-API-Key"
+)
+
+// the routes which are served without token, the probes only
+var authWhiteList = map[string]bool{
+	"/v1/health":       true,
+	"/v1/health/live":  true,
+	"/v1/health/ready": true,
+}
+
 type authMiddleware struct {
 	tokenService *jwtokenService.JWTokenService
 }
@@ -15,38 +30,61 @@ type authMiddleware struct {
 func (a authMiddleware) authorizationHandler() func(http.Handler) http.Handler {
 	return func(next http.Handler) http.Handler {
 		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-			fmt.Println("Hit the authMiddleware")
-			next.ServeHTTP(w, r)
-			// currentRoute := mux.CurrentRoute(r)
-			// currentRouteVars := mux.Vars(r)
-			// authHeader := r.Header.Get("Authorization")
-
-			// logger.Info("see the vars from route: " + fmt.Sprintf("%v", currentRouteVars))
-
-			// if authHeader != "" {
-			// 	token := getTokenFromHeader(authHeader)
-
-			// 	logger.Info("token in account route: " + token)
-
-			// 	// TODO set a whiteLists of allowed routes
-			// 	// if ok = whiteList[currentRoute.GetName()]{ no check :) }
-			// 	isAuthorized := a.tokenService.IsAuthorized(token, currentRoute.GetName(), currentRouteVars)
-
-			// 	if isAuthorized {
-			// 		next.ServeHTTP(w, r)
-			// 	} else {
-			// 		appErr := errs.AppError{
-			// 			Code:    http.StatusForbidden,
-			// 			Message: "Unauthorized"}
-			// 		writeResponse(w, appErr.Code, appErr.AsMessage())
-			// 	}
-			// } else {
-			// 	writeResponse(w, http.StatusUnauthorized, "missing token")
-			// }
+			if authWhiteList[r.URL.Path] {
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			var principal *jwtokenService.Principal
+			var ce e.IError
+			if key := r.Header.Get(headerAPIKey); key != "" {
+				principal, ce = a.tokenService.APIKeyIsValid(r.Context(), key)
+			} else {
+				principal, ce = a.jwtPrincipal(r)
+			}
+			if ce != nil {
+				authError(w, ce)
+				return
+			}
+
+			// every principal is bound to a tenant, with or without header
+			if principal.Tenant == "" {
+				authError(w, e.NewCustomHTTPStatus(e.StatusForbidden, "", "tenant missing"))
+				return
+			}
+			if tenant := r.Header.Get(headerTenant); tenant != "" && tenant != principal.Tenant {
+				authError(w, e.NewCustomHTTPStatus(e.StatusForbidden, "", "tenant mismatch"))
+				return
+			}
+
+			// the handlers scope their datas to the principal's tenant
+			ctx := jwtokenService.ContextWithPrincipal(r.Context(), principal)
+			next.ServeHTTP(w, r.WithContext(ctx))
 		})
 	}
 }
 
+// jwtPrincipal valid the Bearer jwt of r
+func (a authMiddleware) jwtPrincipal(r *http.Request) (*jwtokenService.Principal, e.IError) {
+	token := getTokenFromHeader(r.Header.Get("Authorization"))
+	if token == "" {
+		return nil, e.NewCustomHTTPStatus(e.StatusUnauthorized, "", "missing token")
+	}
+
+	infos, ce := a.tokenService.JWTokenIsValidToken(r.Context(), token)
+	if ce != nil {
+		return nil, ce
+	}
+
+	return jwtokenService.NewJWTPrincipal(infos), nil
+}
+
+func authError(w http.ResponseWriter, ce e.IError) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(ce.GetCode())
+	json.NewEncoder(w).Encode(ce)
+}
+
 func getTokenFromHeader(header string) string {
 	/*
 	   token is coming in the format as below
@@ -0,0 +1,108 @@
+package rest
+
+import (
+	"context"
+	pb "gitlab.com/grpasr/asonrythme/broker_svc/broker/api/v1/auth"
+	"gitlab.com/grpasr/asonrythme/broker_svc/broker/internal/services/jwtokenService"
+	"gitlab.com/grpasr/common/tests"
+	"google.golang.org/grpc"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+const (
+	tenantToken      = "tenantToken"
+	readerToken      = "readerToken"
+	otherTenantToken = "otherTenantToken"
+	tenant           = "default"
+)
+
+type authClientMock struct {
+	pb.AuthManagementClient
+}
+
+func (authClientMock) ValidateToken(ctx context.Context, in *pb.Jwtoken, opts ...grpc.CallOption) (*pb.ValidateTokenResponse, error) {
+	resp := &pb.ValidateTokenResponse{IsOk: true, ResponseCode: http.StatusOK, Svc: "apiServer", Scope: "orders:read, admin"}
+	switch in.GetJwt() {
+	case readerToken:
+		resp.Tenant = tenant
+		resp.Scope = "orders:read"
+	case tenantToken:
+		resp.Tenant = tenant
+	case otherTenantToken:
+		resp.Tenant = "otherTenant"
+	default:
+		return &pb.ValidateTokenResponse{ResponseCode: http.StatusForbidden}, nil
+	}
+	return resp, nil
+}
+
+// serve r through the auth middleware, the handler record the tenant of
+// the principal it was served for
+func serveAuth(r *http.Request) (*httptest.ResponseRecorder, string) {
+	served := ""
+	rec := serveAuthHandler(r, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		served, _ = jwtokenService.TenantFromContext(r.Context())
+	}))
+	return rec, served
+}
+
+func serveAuthHandler(r *http.Request, next http.Handler) *httptest.ResponseRecorder {
+	am := authMiddleware{jwtokenService.NewJWTokenService(authClientMock{})}
+	handler := am.authorizationHandler()(next)
+
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, r)
+	return rec
+}
+
+func Test_auth_tenant(t *testing.T) {
+	tests.MaybeFail = tests.InitFailFunc(t)
+
+	req := httptest.NewRequest(http.MethodPost, "/order/v1/getorder", nil)
+	req.Header.Set("Authorization", "Bearer "+tenantToken)
+	rec, served := serveAuth(req)
+	tests.MaybeFail("tenant_of_token", tests.Expect(rec.Code, http.StatusOK), tests.Expect(served, tenant))
+
+	req = httptest.NewRequest(http.MethodPost, "/order/v1/getorder", nil)
+	req.Header.Set("Authorization", "Bearer "+tenantToken)
+	req.Header.Set(headerTenant, tenant)
+	rec, served = serveAuth(req)
+	tests.MaybeFail("tenant_header", tests.Expect(rec.Code, http.StatusOK), tests.Expect(served, tenant))
+}
+
+func Test_auth_cross_tenant_denied(t *testing.T) {
+	tests.MaybeFail = tests.InitFailFunc(t)
+
+	// the token of otherTenant can not reach the datas of tenant
+	req := httptest.NewRequest(http.MethodPost, "/order/v1/getorder", nil)
+	req.Header.Set("Authorization", "Bearer "+otherTenantToken)
+	req.Header.Set(headerTenant, tenant)
+	rec, served := serveAuth(req)
+	tests.MaybeFail("cross_tenant", tests.Expect(rec.Code, http.StatusForbidden), tests.Expect(served, ""))
+}
+
+func Test_auth_white_list(t *testing.T) {
+	tests.MaybeFail = tests.InitFailFunc(t)
+
+	rec, _ := serveAuth(httptest.NewRequest(http.MethodGet, "/v1/health/ready", nil))
+	tests.MaybeFail("health", tests.Expect(rec.Code, http.StatusOK))
+
+	rec, _ = serveAuth(httptest.NewRequest(http.MethodGet, "/v1/logging", nil))
+	tests.MaybeFail("logging", tests.Expect(rec.Code, http.StatusUnauthorized))
+}
+
+func Test_logging_need_the_admin_scope(t *testing.T) {
+	tests.MaybeFail = tests.InitFailFunc(t)
+
+	req := httptest.NewRequest(http.MethodGet, "/v1/logging", nil)
+	req.Header.Set("Authorization", "Bearer "+readerToken)
+	rec := serveAuthHandler(req, loggingHandler("admin"))
+	tests.MaybeFail("logging_reader", tests.Expect(rec.Code, http.StatusForbidden))
+
+	req = httptest.NewRequest(http.MethodGet, "/v1/logging", nil)
+	req.Header.Set("Authorization", "Bearer "+tenantToken)
+	rec = serveAuthHandler(req, loggingHandler("admin"))
+	tests.MaybeFail("logging_admin", tests.Expect(rec.Code, http.StatusOK))
+}
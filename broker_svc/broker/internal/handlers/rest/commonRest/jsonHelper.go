@@ -3,6 +3,7 @@ package commonRest
 import (
 	"encoding/json"
 	"errors"
+	"gitlab.com/grpasr/asonrythme/broker_svc/broker/internal/services/jwtokenService"
 	e "gitlab.com/grpasr/common/errors/json"
 	"io"
 	"net/http"
@@ -56,3 +57,21 @@ func CustomResponseJson(w http.ResponseWriter, ce e.IError) error {
 	json.NewEncoder(w).Encode(ce)
 	return nil
 }
+
+// CustomErrorJson write ce with its status code
+func CustomErrorJson(w http.ResponseWriter, ce e.IError) error {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(ce.GetCode())
+	return json.NewEncoder(w).Encode(ce)
+}
+
+// RequireScope return true if the request's principal was granted scope,
+// else it write 403
+func RequireScope(w http.ResponseWriter, r *http.Request, scope string) bool {
+	p, ok := jwtokenService.PrincipalFromContext(r.Context())
+	if !ok || !p.HasScope(scope) {
+		CustomErrorJson(w, e.NewCustomHTTPStatus(e.StatusForbidden, "", "scope "+scope+" missing"))
+		return false
+	}
+	return true
+}
@@ -1,21 +1,31 @@
 package orderRest
 
 import (
+	"encoding/json"
 	"fmt"
 	"github.com/gorilla/mux"
 	"gitlab.com/grpasr/asonrythme/broker_svc/broker/internal/dto/orderDTO"
 	"gitlab.com/grpasr/asonrythme/broker_svc/broker/internal/handlers/rest/commonRest"
+	"gitlab.com/grpasr/asonrythme/broker_svc/broker/internal/services/jwtokenService"
 	"gitlab.com/grpasr/asonrythme/broker_svc/broker/internal/services/orderService"
+	e "gitlab.com/grpasr/common/errors/json"
 	"net/http"
 )
 
+// Scopes are the scopes a principal need to read and write the orders
+type Scopes struct {
+	Read  string
+	Write string
+}
+
 type OrderRest struct {
 	orderRouter *mux.Router
 	orderSvc    orderService.IOrderService
+	scopes      Scopes
 }
 
-func NewOrderRest(orderRouter *mux.Router, ordSvc orderService.IOrderService) *OrderRest {
-	return &OrderRest{orderRouter, ordSvc}
+func NewOrderRest(orderRouter *mux.Router, ordSvc orderService.IOrderService, scopes Scopes) *OrderRest {
+	return &OrderRest{orderRouter, ordSvc, scopes}
 }
 
 func (o *OrderRest) RunOrderRest() {
@@ -30,10 +40,30 @@ func (o *OrderRest) RunOrderRest() {
 	// 	Name("NewAccount")
 }
 
-// Order service handlers
+// Order service handlers, the orders are read and written for the tenant of
+// the request's principal only
+
+// requestTenant return the tenant of the request's principal, set by the auth
+// middleware, a request without tenant is forbidden
+func requestTenant(w http.ResponseWriter, r *http.Request) (string, bool) {
+	tenant, ok := jwtokenService.TenantFromContext(r.Context())
+	if !ok || tenant == "" {
+		commonRest.CustomErrorJson(w, e.NewCustomHTTPStatus(e.StatusForbidden, "", "tenant missing"))
+		return "", false
+	}
+	return tenant, true
+}
 
 func (o *OrderRest) CreateOrder(w http.ResponseWriter, r *http.Request) {
 	fmt.Println("internal/handlers/rest - CreateOrder.........")
+
+	if !commonRest.RequireScope(w, r, o.scopes.Write) {
+		return
+	}
+	tenant, ok := requestTenant(w, r)
+	if !ok {
+		return
+	}
 	fmt.Fprintln(w)
 
 	li := orderDTO.LineItem{}
@@ -46,7 +76,7 @@ func (o *OrderRest) CreateOrder(w http.ResponseWriter, r *http.Request) {
 	or.Items = []orderDTO.LineItem{li}
 	or.ShippingAddress = "the shipping address"
 
-	ce := o.orderSvc.OrderCreateService(or)
+	ce := o.orderSvc.OrderCreateService(r.Context(), tenant, or)
 
 	// // if use writeJson
 	// headers1 := make(http.Header)
@@ -55,9 +85,28 @@ func (o *OrderRest) CreateOrder(w http.ResponseWriter, r *http.Request) {
 	commonRest.CustomResponseJson(w, ce)
 }
 
+// GetOrder return the order {"id": ID} of the tenant
 func (o *OrderRest) GetOrder(w http.ResponseWriter, r *http.Request) {
-	fmt.Fprintln(w)
+	if !commonRest.RequireScope(w, r, o.scopes.Read) {
+		return
+	}
+	tenant, ok := requestTenant(w, r)
+	if !ok {
+		return
+	}
 
+	req := orderDTO.Order{}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil || req.ID == "" {
+		commonRest.CustomErrorJson(w, e.NewCustomHTTPStatus(e.StatusBadRequest, "", "order id missing"))
+		return
+	}
+
+	ce := o.orderSvc.OrderGetService(r.Context(), tenant, req.ID)
+	if ce.GetCode() != http.StatusOK {
+		commonRest.CustomErrorJson(w, ce)
+		return
+	}
+	commonRest.CustomResponseJson(w, ce)
 }
 
 // // Handle POST request for account creation
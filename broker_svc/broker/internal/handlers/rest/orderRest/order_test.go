@@ -8,6 +8,7 @@ import (
 	"gitlab.com/grpasr/asonrythme/broker_svc/broker/internal/handlers/rest/commonRest"
 	// "gitlab.com/grpasr/asonrythme/broker_svc/broker/internal/handlers/rest/orderRest"
 	"gitlab.com/grpasr/asonrythme/broker_svc/broker/internal/mocks/services"
+	"gitlab.com/grpasr/asonrythme/broker_svc/broker/internal/services/jwtokenService"
 	e "gitlab.com/grpasr/common/errors/json"
 	"gitlab.com/grpasr/common/tests"
 	"net/http"
@@ -22,12 +23,25 @@ var st *commonRest.SetupTests
 var mockOrderService *services.MockIOrderService
 var orderRestT *OrderRest
 
+const tenant = "default"
+
+// asTenant return r as authenticated by the auth middleware for tenant, the
+// principal is granted both orders' scopes
+func asTenant(r *http.Request, tenant string) *http.Request {
+	return asPrincipal(r, tenant, "orders:read", "orders:write")
+}
+
+func asPrincipal(r *http.Request, tenant string, scopes ...string) *http.Request {
+	p := &jwtokenService.Principal{Kind: jwtokenService.PrincipalJWT, Subject: "apiServer", Tenant: tenant, Scopes: scopes}
+	return r.WithContext(jwtokenService.ContextWithPrincipal(r.Context(), p))
+}
+
 func TestMain(m *testing.M) {
 	// Initialize your test context
 	st = commonRest.NewSetupTests()
 
 	mockOrderService = services.NewMockIOrderService(st.GetCtrl())
-	orderRestT = NewOrderRest(st.GetRouter(), mockOrderService)
+	orderRestT = NewOrderRest(st.GetRouter(), mockOrderService, Scopes{Read: "orders:read", Write: "orders:write"})
 
 	// Run tests
 	exitCode := m.Run()
@@ -69,10 +83,11 @@ func Test_create_order_create_an_order(t *testing.T) {
 
 	// NOTE right now as the OrderCreateService() input is hard coded
 	// order must match it.....
-	mockOrderService.EXPECT().OrderCreateService(order).Return(ce)
+	mockOrderService.EXPECT().OrderCreateService(gomock.Any(), tenant, order).Return(ce)
 
 	st.GetRouter().HandleFunc("/v1/createorder", orderRestT.CreateOrder)
 	request, _ := http.NewRequest(http.MethodPost, "/v1/createorder", nil)
+	request = asTenant(request, tenant)
 
 	// Act
 	recorder := httptest.NewRecorder()
@@ -99,10 +114,11 @@ func Test_create_order_create_return_an_error(t *testing.T) {
 	}
 
 	// we do not care the input, just set OrderCreateService to return ce
-	mockOrderService.EXPECT().OrderCreateService(gomock.Any()).Return(ce)
+	mockOrderService.EXPECT().OrderCreateService(gomock.Any(), gomock.Any(), gomock.Any()).Return(ce)
 
 	st.GetRouter().HandleFunc("/v1/createorder", orderRestT.CreateOrder)
 	request, _ := http.NewRequest(http.MethodPost, "/v1/createorder", nil)
+	request = asTenant(request, tenant)
 
 	// Act
 	recorder := httptest.NewRecorder()
@@ -116,3 +132,85 @@ func Test_create_order_create_return_an_error(t *testing.T) {
 		strings.TrimSpace(recorder.Body.String()),
 		strings.TrimSpace(string(ceJSON))))
 }
+
+func Test_order_routes_need_a_tenant(t *testing.T) {
+	tests.MaybeFail = tests.InitFailFunc(t)
+
+	// no call to the service is expected
+	st.GetRouter().HandleFunc("/v1/createorder", orderRestT.CreateOrder)
+	st.GetRouter().HandleFunc("/v1/getorder", orderRestT.GetOrder)
+	for _, path := range []string{"/v1/createorder", "/v1/getorder"} {
+		request, _ := http.NewRequest(http.MethodPost, path, strings.NewReader(`{"id":"123"}`))
+		request = asPrincipal(request, "", "orders:read", "orders:write")
+
+		recorder := httptest.NewRecorder()
+		st.GetRouter().ServeHTTP(recorder, request)
+
+		tests.MaybeFail("no_tenant"+path, tests.Expect(recorder.Code, http.StatusForbidden))
+	}
+}
+
+func Test_get_order_of_an_other_tenant_is_not_found(t *testing.T) {
+	tests.MaybeFail = tests.InitFailFunc(t)
+
+	// the order 123 belong to tenant, the service is queried for the
+	// principal's tenant only
+	mockOrderService.EXPECT().OrderGetService(gomock.Any(), "otherTenant", "123").
+		Return(e.NewCustomHTTPStatus(e.StatusNotFound))
+
+	st.GetRouter().HandleFunc("/v1/getorder", orderRestT.GetOrder)
+	request, _ := http.NewRequest(http.MethodPost, "/v1/getorder", strings.NewReader(`{"id":"123"}`))
+	request = asTenant(request, "otherTenant")
+
+	recorder := httptest.NewRecorder()
+	st.GetRouter().ServeHTTP(recorder, request)
+
+	tests.MaybeFail("other_tenant", tests.Expect(recorder.Code, http.StatusNotFound))
+}
+
+func Test_get_order_of_the_tenant(t *testing.T) {
+	tests.MaybeFail = tests.InitFailFunc(t)
+
+	ce := e.NewCustomHTTPStatus(e.StatusOK)
+	ce.SetPayload(map[string]interface{}{"order": orderDTO.Order{ID: "123"}})
+	mockOrderService.EXPECT().OrderGetService(gomock.Any(), tenant, "123").Return(ce)
+
+	st.GetRouter().HandleFunc("/v1/getorder", orderRestT.GetOrder)
+	request, _ := http.NewRequest(http.MethodPost, "/v1/getorder", strings.NewReader(`{"id":"123"}`))
+	request = asTenant(request, tenant)
+
+	recorder := httptest.NewRecorder()
+	st.GetRouter().ServeHTTP(recorder, request)
+
+	tests.MaybeFail("tenant", tests.Expect(recorder.Code, http.StatusOK))
+	tests.MaybeFail("tenant_body", tests.Expect(strings.Contains(recorder.Body.String(), `"id":"123"`), true))
+}
+
+func Test_order_routes_need_the_scope(t *testing.T) {
+	tests.MaybeFail = tests.InitFailFunc(t)
+
+	// no call to the service is expected
+	st.GetRouter().HandleFunc("/v1/createorder", orderRestT.CreateOrder)
+	st.GetRouter().HandleFunc("/v1/getorder", orderRestT.GetOrder)
+
+	// the principal may read but not create
+	request, _ := http.NewRequest(http.MethodPost, "/v1/createorder", nil)
+	request = asPrincipal(request, tenant, "orders:read")
+	recorder := httptest.NewRecorder()
+	st.GetRouter().ServeHTTP(recorder, request)
+	tests.MaybeFail("create_read_scope", tests.Expect(recorder.Code, http.StatusForbidden))
+
+	// the principal may create but not read
+	request, _ = http.NewRequest(http.MethodPost, "/v1/getorder", strings.NewReader(`{"id":"123"}`))
+	request = asPrincipal(request, tenant, "orders:write")
+	recorder = httptest.NewRecorder()
+	st.GetRouter().ServeHTTP(recorder, request)
+	tests.MaybeFail("get_write_scope", tests.Expect(recorder.Code, http.StatusForbidden))
+
+	// the jwt scopes of the APIservers do not grant the orders
+	request, _ = http.NewRequest(http.MethodPost, "/v1/createorder", nil)
+	request = asPrincipal(request, tenant, "read", "openid")
+	recorder = httptest.NewRecorder()
+	st.GetRouter().ServeHTTP(recorder, request)
+	tests.MaybeFail("create_no_scope", tests.Expect(recorder.Code, http.StatusForbidden))
+}
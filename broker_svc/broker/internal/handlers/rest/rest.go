@@ -4,35 +4,59 @@ import (
 	"fmt"
 	"github.com/gorilla/mux"
 	"gitlab.com/grpasr/asonrythme/broker_svc/broker/internal/config"
+	"gitlab.com/grpasr/asonrythme/broker_svc/broker/internal/handlers/rest/commonRest"
 	"gitlab.com/grpasr/asonrythme/broker_svc/broker/internal/handlers/rest/orderRest"
 	"gitlab.com/grpasr/asonrythme/broker_svc/broker/internal/services"
+	"gitlab.com/grpasr/asonrythme/shared/health"
 	obs "gitlab.com/grpasr/common/observability"
 	"net/http"
 )
 
-func Handler(services services.RestServices, configs *config.Config) {
+func Handler(services services.RestServices, configs *config.Config, hl health.IHealth) {
 
 	router := mux.NewRouter()
 
 	// Order service routes
 	orderRouter := router.PathPrefix("/order").Subrouter()
-	orderRest.NewOrderRest(orderRouter, services.OrderService).RunOrderRest()
+	orderScopes := orderRest.Scopes{
+		Read:  configs.HTTPGetOrderReadScope(),
+		Write: configs.HTTPGetOrderWriteScope(),
+	}
+	orderRest.NewOrderRest(orderRouter, services.OrderService, orderScopes).RunOrderRest()
 
 	// middleware
 	// tokenService := services.NewTokenService() // is not a pointer
 	am := authMiddleware{services.JWTokenService}
 	router.Use(am.authorizationHandler())
 
-	// healthcheck endpoint
-	router.HandleFunc("/v1/health", func(w http.ResponseWriter, r *http.Request) {
-		obs.Logging.NewLogHandler(obs.Logging.LLHDebug()).
-			Msg("reach the healthcheck")
-		w.WriteHeader(http.StatusOK)
-		w.Write([]byte("OK"))
-	})
+	// healthcheck endpoints, /v1/health is kept as the liveness
+	router.HandleFunc("/v1/health", hl.LivenessHandler).Methods(http.MethodGet)
+	router.HandleFunc("/v1/health/live", hl.LivenessHandler).Methods(http.MethodGet)
+	router.HandleFunc("/v1/health/ready", hl.ReadinessHandler).Methods(http.MethodGet)
+
+	// logging endpoint, switch the logging env of the service
+	router.HandleFunc("/v1/logging", loggingHandler(configs.HTTPGetAdminScope()))
+
+	// starting server
+	port := configs.HTTPGetPort()
+	obs.Logging.NewLogHandler(obs.Logging.LLHInfo()).
+		Str("HTTP listen on port: ", port).
+		Send()
+	err := http.ListenAndServe(fmt.Sprintf(":%s", port), router)
+	if err != nil {
+		obs.Logging.NewLogHandler(obs.Logging.LLHFatal()).
+			Err(err).
+			Send()
+	}
+}
 
-	// logging endpoint
-	router.HandleFunc("/v1/logging", func(w http.ResponseWriter, r *http.Request) {
+// loggingHandler switch the logging env between production and development,
+// for the principals granted adminScope only
+func loggingHandler(adminScope string) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if !commonRest.RequireScope(w, r, adminScope) {
+			return
+		}
 		obs.Logging.NewLogHandler(obs.Logging.LLHDebug()).
 			Msg("reach the logging endpoint")
 		logEnv := obs.Logging.GetLoggingEnv()
@@ -45,17 +69,5 @@ func Handler(services services.RestServices, configs *config.Config) {
 
 		w.WriteHeader(http.StatusOK)
 		w.Write([]byte("OK"))
-	})
-
-	// starting server
-	port := configs.HTTPGetPort()
-	obs.Logging.NewLogHandler(obs.Logging.LLHInfo()).
-		Str("HTTP listen on port: ", port).
-		Send()
-	err := http.ListenAndServe(fmt.Sprintf(":%s", port), router)
-	if err != nil {
-		obs.Logging.NewLogHandler(obs.Logging.LLHFatal()).
-			Err(err).
-			Send()
 	}
 }
@@ -5,6 +5,7 @@
 package services
 
 import (
+	context "context"
 	reflect "reflect"
 
 	gomock "github.com/golang/mock/gomock"
@@ -36,27 +37,29 @@ func (m *MockIOrderService) EXPECT() *MockIOrderServiceMockRecorder {
 }
 
 // OrderCreateService mocks base method.
-func (m *MockIOrderService) OrderCreateService(arg0 orderDTO.Order) json.IError {
+func (m *MockIOrderService) OrderCreateService(arg0 context.Context, arg1 string, arg2 orderDTO.Order) json.IError {
 	m.ctrl.T.Helper()
-	ret := m.ctrl.Call(m, "OrderCreateService", arg0)
+	ret := m.ctrl.Call(m, "OrderCreateService", arg0, arg1, arg2)
 	ret0, _ := ret[0].(json.IError)
 	return ret0
 }
 
 // OrderCreateService indicates an expected call of OrderCreateService.
-func (mr *MockIOrderServiceMockRecorder) OrderCreateService(arg0 interface{}) *gomock.Call {
+func (mr *MockIOrderServiceMockRecorder) OrderCreateService(arg0, arg1, arg2 interface{}) *gomock.Call {
 	mr.mock.ctrl.T.Helper()
-	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "OrderCreateService", reflect.TypeOf((*MockIOrderService)(nil).OrderCreateService), arg0)
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "OrderCreateService", reflect.TypeOf((*MockIOrderService)(nil).OrderCreateService), arg0, arg1, arg2)
 }
 
 // OrderGetService mocks base method.
-func (m *MockIOrderService) OrderGetService() {
+func (m *MockIOrderService) OrderGetService(arg0 context.Context, arg1, arg2 string) json.IError {
 	m.ctrl.T.Helper()
-	m.ctrl.Call(m, "OrderGetService")
+	ret := m.ctrl.Call(m, "OrderGetService", arg0, arg1, arg2)
+	ret0, _ := ret[0].(json.IError)
+	return ret0
 }
 
 // OrderGetService indicates an expected call of OrderGetService.
-func (mr *MockIOrderServiceMockRecorder) OrderGetService() *gomock.Call {
+func (mr *MockIOrderServiceMockRecorder) OrderGetService(arg0, arg1, arg2 interface{}) *gomock.Call {
 	mr.mock.ctrl.T.Helper()
-	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "OrderGetService", reflect.TypeOf((*MockIOrderService)(nil).OrderGetService))
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "OrderGetService", reflect.TypeOf((*MockIOrderService)(nil).OrderGetService), arg0, arg1, arg2)
 }
@@ -2,109 +2,113 @@ package jwtokenService
 
 import (
 	"context"
-	"errors"
-	"github.com/golang-jwt/jwt"
-	"gitlab.com/grpasr/asonrythme/broker_svc/broker/internal/config"
+	"fmt"
+	pb "gitlab.com/grpasr/asonrythme/broker_svc/broker/api/v1/auth"
 	e "gitlab.com/grpasr/common/errors/json"
 	obs "gitlab.com/grpasr/common/observability"
+	"net/http"
 	"strings"
 )
 
-// JWTAccessGenerate generate the jwt access token
+// JWTokenService delegate the jwt validation to auth_svc(grpc)
 type JWTokenService struct {
-	signedKey []byte // secret key
+	authClient pb.AuthManagementClient
 }
 
-func NewJWTokenService(conf *config.Config) *JWTokenService {
-	signedKey := conf.TOKENGetSignedKey()
+func NewJWTokenService(authClient pb.AuthManagementClient) *JWTokenService {
 	return &JWTokenService{
-		signedKey: []byte(signedKey),
+		authClient: authClient,
 	}
 }
 
 // JWTokenIsValidToken valid the jwt_token(only for APIserver), if valid return nil
 func (a *JWTokenService) JWTokenIsValidToken(ctx context.Context, tokenString string) (map[string]string, e.IError) {
 
-	var secretKey = a.signedKey
 	tokenString = strings.TrimSpace(tokenString)
 	tokenString = strings.Trim(tokenString, `"`)
 
-	var claims jwt.MapClaims
-	var ok bool
-
-	_, err := jwt.Parse(tokenString, func(token *jwt.Token) (interface{}, error) {
-
-		claims, ok = token.Claims.(jwt.MapClaims)
-		if !ok {
-			return nil, errors.New("ErrInvalidJWToken")
-		}
-
-		err := claims.Valid()
-		if err != nil {
-			if err.Error() == "Token is expired" {
-				return nil, errors.New("ErrExpiredJWToken")
-			}
-
-			return nil, errors.New("ErrInvalidJWToken")
-		}
-
-		return secretKey, nil
-	})
-
+	resp, err := a.authClient.ValidateToken(ctx, &pb.Jwtoken{Jwt: tokenString})
 	if err != nil {
-		if err.Error() == "ErrExpiredJWToken" {
+		obs.Logging.NewLogHandler(obs.Logging.LLHError()).
+			Err(err).
+			Msg("auth_svc ValidateToken request failed")
+		return nil, e.NewCustomHTTPStatus(e.StatusInternalServerError)
+	}
+
+	if !resp.GetIsOk() {
+		switch resp.GetResponseCode() {
+		case http.StatusUnauthorized:
 			obs.Logging.NewLogHandler(obs.Logging.LLHError()).
-				Err(err).
 				Msg("json token expired")
 			return nil, e.NewCustomHTTPStatus(e.StatusUnauthorized)
+		default:
+			return nil, e.NewCustomHTTPStatus(e.StatusForbidden)
 		}
-		return nil, e.NewCustomHTTPStatus(e.StatusForbidden)
 	}
 
-	// Token is valid process it
-	// Extracting the nested "openidInfo" map
-	openidInfo, ok := claims["openidInfo"].(map[string]interface{})
-	if !ok {
+	// a token issued without tenant can not be bound to any tenant's data
+	if resp.GetTenant() == "" {
 		obs.Logging.NewLogHandler(obs.Logging.LLHError()).
-			Err(err).
-			Msg("extracting openidInfo from token failed")
+			Msg("json token without tenant")
 		return nil, e.NewCustomHTTPStatus(e.StatusForbidden)
 	}
 
 	infos := make(map[string]string)
+	infos["role"] = resp.GetRole()
+	infos["svc"] = resp.GetSvc()
+	infos["scope"] = resp.GetScope()
+	infos["tenant"] = resp.GetTenant()
 
-	// Extracting role, sub, and scope
-	var errData = false
-	role, ok := openidInfo["role"].(string)
-	infos["role"] = role
-	if !ok {
-		obs.Logging.NewLogHandler(obs.Logging.LLHError()).
-			Err(err).
-			Msg("no 'role' field in openidInfo")
-		errData = true
+	return infos, nil
+}
+
+// JWTokenIsValidTokenForTenant valid the jwt_token and make sure it belong to tenant
+func (a *JWTokenService) JWTokenIsValidTokenForTenant(ctx context.Context, tokenString, tenant string) (map[string]string, e.IError) {
+	infos, ce := a.JWTokenIsValidToken(ctx, tokenString)
+	if ce != nil {
+		return nil, ce
 	}
 
-	sub, ok := claims["sub"].(string)
-	infos["svc"] = sub
-	if !ok {
+	if infos["tenant"] != tenant {
 		obs.Logging.NewLogHandler(obs.Logging.LLHError()).
-			Err(err).
-			Msg("no 'sub' field in openidInfo")
-		errData = true
+			Msg(fmt.Sprintf("json token of tenant %v used for tenant %v", infos["tenant"], tenant))
+		return nil, e.NewCustomHTTPStatus(e.StatusForbidden)
 	}
 
-	scope, ok := openidInfo["scope"].(string)
-	infos["scope"] = scope
-	if !ok {
+	return infos, nil
+}
+
+// APIKeyIsValid valid the api key of a third party integrator, if valid
+// return the principal it stand for
+func (a *JWTokenService) APIKeyIsValid(ctx context.Context, key string) (*Principal, e.IError) {
+	resp, err := a.authClient.ValidateAPIKey(ctx, &pb.APIKey{Key: strings.TrimSpace(key)})
+	if err != nil {
 		obs.Logging.NewLogHandler(obs.Logging.LLHError()).
 			Err(err).
-			Msg("no 'scope' field in openidInfo")
-		errData = true
+			Msg("auth_svc ValidateAPIKey request failed")
+		return nil, e.NewCustomHTTPStatus(e.StatusInternalServerError)
+	}
+
+	if !resp.GetIsOk() {
+		switch resp.GetResponseCode() {
+		case http.StatusUnauthorized:
+			obs.Logging.NewLogHandler(obs.Logging.LLHError()).
+				Msg("api key expired")
+			return nil, e.NewCustomHTTPStatus(e.StatusUnauthorized)
+		default:
+			return nil, e.NewCustomHTTPStatus(e.StatusForbidden)
+		}
 	}
 
-	if errData {
-		return nil, e.NewCustomHTTPStatus(e.StatusForbidden, "", "token contains invalid data")
+	if resp.GetTenant() == "" {
+		return nil, e.NewCustomHTTPStatus(e.StatusForbidden)
 	}
 
-	return infos, nil
+	return &Principal{
+		Kind:    PrincipalAPIKey,
+		Subject: resp.GetKeyId(),
+		Name:    resp.GetName(),
+		Tenant:  resp.GetTenant(),
+		Scopes:  resp.GetScopes(),
+	}, nil
 }
@@ -2,244 +2,189 @@ package jwtokenService
 
 import (
 	"context"
-	"fmt"
-	"github.com/golang-jwt/jwt"
-	"gitlab.com/grpasr/asonrythme/broker_svc/broker/internal/config"
+	"errors"
+	pb "gitlab.com/grpasr/asonrythme/broker_svc/broker/api/v1/auth"
 	obs "gitlab.com/grpasr/common/observability"
 	"gitlab.com/grpasr/common/tests"
+	"google.golang.org/grpc"
 	"net/http"
 	"os"
 	"testing"
-	"time"
 )
 
 const (
-	key      = "keyID"
-	signKey  = "secretKey"
-	subject  = "serviceName"
-	audience = "serviceDest"
-)
-
-var (
-	expireInAnHour = time.Now().Add(time.Hour).Unix()
-	expiredTime    = time.Now().Add(-time.Hour).Unix()
-	validToken     string
-	expiredToken   string
-	validConfig    *config.Config // means secretKey match const signKey
-	invalidConfig  *config.Config // secretKey does not match const signKey
-	ui             map[string]string
+	validToken    = "validToken"
+	expiredToken  = "expiredToken"
+	noTenantToken = "noTenantToken"
+	validAPIKey   = "ak_keyID.secret"
+	revokedAPIKey = "ak_revoked.secret"
+	subject       = "serviceName"
+	tenant        = "default"
 )
 
 func TestMain(m *testing.M) {
-	// Initialize your test context
-	setupTests()
-
-	// Run tests
-	exitCode := m.Run()
-
-	// Teardown
-	teardown()
-
-	// Exit with the appropriate code
-	os.Exit(exitCode)
+	obs.SetObservabilityFacade("broker_svc")
 
+	os.Exit(m.Run())
 }
 
 func Test_validate_valid_token(t *testing.T) {
 	tests.MaybeFail = tests.InitFailFunc(t)
 
-	jwtSVC := NewJWTokenService(validConfig)
+	jwtSVC := NewJWTokenService(&authClientMock{})
 
-	ctx := context.Background()
+	data, ce := jwtSVC.JWTokenIsValidToken(context.Background(), `"`+validToken+`"`)
 
-	data, ce := jwtSVC.JWTokenIsValidToken(ctx, validToken)
-
-	tests.MaybeFail("validate_valid_token", ce, tests.Expect(len(data), 3))
-	tests.MaybeFail("validate_valid_token", tests.Expect(data["role"], ui["role"]))
-	tests.MaybeFail("validate_valid_token", tests.Expect(data["scope"], ui["scope"]))
+	tests.MaybeFail("validate_valid_token", ce, tests.Expect(len(data), 4))
+	tests.MaybeFail("validate_valid_token", tests.Expect(data["role"], "APIserver"))
+	tests.MaybeFail("validate_valid_token", tests.Expect(data["scope"], "read, openid"))
 	tests.MaybeFail("validate_valid_token", tests.Expect(data["svc"], subject))
+	tests.MaybeFail("validate_valid_token", tests.Expect(data["tenant"], tenant))
 }
 
-func Test_validate_expired_token(t *testing.T) {
+func Test_validate_token_tenant(t *testing.T) {
 	tests.MaybeFail = tests.InitFailFunc(t)
 
-	jwtSVC := NewJWTokenService(validConfig)
+	jwtSVC := NewJWTokenService(&authClientMock{})
 
-	ctx := context.Background()
+	data, ce := jwtSVC.JWTokenIsValidTokenForTenant(context.Background(), validToken, tenant)
+	tests.MaybeFail("validate_token_tenant", ce, tests.Expect(data["tenant"], tenant))
 
-	data, ce := jwtSVC.JWTokenIsValidToken(ctx, expiredToken)
+	data, ce = jwtSVC.JWTokenIsValidTokenForTenant(context.Background(), validToken, "otherTenant")
+	tests.MaybeFail("validate_token_other_tenant",
+		tests.Expect(ce.GetCode(), http.StatusForbidden),
+		tests.Expect(len(data), 0))
 
-	tests.MaybeFail("http_status", tests.Expect(ce.GetCode(), http.StatusUnauthorized))
-	tests.MaybeFail("http_status", tests.Expect(len(ce.GetPayload()), 0))
-	tests.MaybeFail("http_status", tests.Expect(len(data), 0))
+	data, ce = jwtSVC.JWTokenIsValidToken(context.Background(), noTenantToken)
+	tests.MaybeFail("validate_token_no_tenant",
+		tests.Expect(ce.GetCode(), http.StatusForbidden),
+		tests.Expect(len(data), 0))
 }
 
-func Test_validate_invalid_secretKey(t *testing.T) {
+func Test_validate_expired_token(t *testing.T) {
 	tests.MaybeFail = tests.InitFailFunc(t)
 
-	jwtSVC := NewJWTokenService(invalidConfig)
+	jwtSVC := NewJWTokenService(&authClientMock{})
 
-	ctx := context.Background()
+	data, ce := jwtSVC.JWTokenIsValidToken(context.Background(), expiredToken)
 
-	data, ce := jwtSVC.JWTokenIsValidToken(ctx, validToken)
-
-	tests.MaybeFail("http_status", tests.Expect(ce.GetCode(), http.StatusForbidden))
-	tests.MaybeFail("http_status", tests.Expect(len(ce.GetPayload()), 0))
+	tests.MaybeFail("http_status", tests.Expect(ce.GetCode(), http.StatusUnauthorized))
 	tests.MaybeFail("http_status", tests.Expect(len(data), 0))
-
 }
 
-func Test_validate_invalid_info_token(t *testing.T) {
+func Test_validate_invalid_token(t *testing.T) {
 	tests.MaybeFail = tests.InitFailFunc(t)
 
-	ag := NewJWTAccessGenerate()
-
-	ii := make(map[string]string)
-	ii["nameInv"] = "robert"
-	ii["scopeInv"] = "read, openid"
-	ii["roleInv"] = "APIserver"
+	jwtSVC := NewJWTokenService(&authClientMock{})
 
-	invalidInfoToken, err := ag.GenerateOpenidJWToken(expireInAnHour, ii)
-	if err != nil {
-		fmt.Println("error created expiredToken")
-	}
-
-	jwtSVC := NewJWTokenService(validConfig)
-
-	ctx := context.Background()
-
-	data, ce := jwtSVC.JWTokenIsValidToken(ctx, invalidInfoToken)
+	data, ce := jwtSVC.JWTokenIsValidToken(context.Background(), "invalidToken")
 
 	tests.MaybeFail("http_status", tests.Expect(ce.GetCode(), http.StatusForbidden))
-	tests.MaybeFail("http_status", tests.Expect(ce.Error(), `403 : Request forbidden, Comment: token contains invalid data`))
-	tests.MaybeFail("http_status", tests.Expect(len(ce.GetPayload()), 0))
 	tests.MaybeFail("http_status", tests.Expect(len(data), 0))
 }
 
-func Test_validate_empty_info_token(t *testing.T) {
+func Test_validate_auth_svc_unreachable(t *testing.T) {
 	tests.MaybeFail = tests.InitFailFunc(t)
 
-	ag := NewJWTAccessGenerate()
-
-	ii := make(map[string]string)
-	ii["name"] = "robert"
-	ii["scope"] = ""
-	ii["role"] = "APIserver"
-
-	invalidInfoToken, err := ag.GenerateOpenidJWToken(expireInAnHour, ii)
-	if err != nil {
-		fmt.Println("error created expiredToken")
-	}
-
-	jwtSVC := NewJWTokenService(validConfig)
-
-	ctx := context.Background()
+	jwtSVC := NewJWTokenService(&authClientMock{err: errors.New("unavailable")})
 
-	data, ce := jwtSVC.JWTokenIsValidToken(ctx, invalidInfoToken)
+	data, ce := jwtSVC.JWTokenIsValidToken(context.Background(), validToken)
 
-	tests.MaybeFail("validate_valid_token", ce, tests.Expect(len(data), 3))
-	tests.MaybeFail("validate_valid_token", tests.Expect(data["role"], ii["role"]))
-	tests.MaybeFail("validate_valid_token", tests.Expect(data["scope"], ii["scope"]))
-	tests.MaybeFail("validate_valid_token", tests.Expect(data["svc"], subject))
+	tests.MaybeFail("http_status", tests.Expect(ce.GetCode(), http.StatusInternalServerError))
+	tests.MaybeFail("http_status", tests.Expect(len(data), 0))
 }
 
-func setupTests() {
-
-	obs.SetObservabilityFacade("broker_svc")
+func Test_validate_api_key(t *testing.T) {
+	tests.MaybeFail = tests.InitFailFunc(t)
 
-	ag := NewJWTAccessGenerate()
+	jwtSVC := NewJWTokenService(&authClientMock{})
 
-	ui = make(map[string]string)
-	ui["name"] = "robert"
-	ui["scope"] = "read, openid"
-	ui["role"] = "APIserver"
+	p, ce := jwtSVC.APIKeyIsValid(context.Background(), validAPIKey)
+	tests.MaybeFail("validate_api_key", ce,
+		tests.Expect(p.Kind, PrincipalAPIKey),
+		tests.Expect(p.Subject, "keyID"),
+		tests.Expect(p.Tenant, tenant),
+		tests.Expect(p.HasScope("orders:read"), true),
+		tests.Expect(p.HasScope("orders:write"), false))
 
-	var err error
-	validToken, err = ag.GenerateOpenidJWToken(expireInAnHour, ui)
-	if err != nil {
-		fmt.Println("error created validToken")
-	}
+	p, ce = jwtSVC.APIKeyIsValid(context.Background(), revokedAPIKey)
+	tests.MaybeFail("validate_api_key_revoked",
+		tests.Expect(ce.GetCode(), http.StatusForbidden),
+		tests.Expect(p == nil, true))
 
-	expiredToken, err = ag.GenerateOpenidJWToken(expiredTime, ui)
-	if err != nil {
-		fmt.Println("error created expiredToken")
-	}
+	jwtSVC = NewJWTokenService(&authClientMock{err: errors.New("unavailable")})
+	_, ce = jwtSVC.APIKeyIsValid(context.Background(), validAPIKey)
+	tests.MaybeFail("validate_api_key_unreachable", tests.Expect(ce.GetCode(), http.StatusInternalServerError))
+}
 
-	// set configs
-	os.Setenv("GOENV", "localhost")
-	os.Setenv("CONFIG_FILE_PATH", "../../../configs/v1/servicesAddr/")
-	os.Setenv("PATH_TO_TLS", "../../../configs/v1/certificates/")
+func Test_principal_of_jwt(t *testing.T) {
+	tests.MaybeFail = tests.InitFailFunc(t)
 
-	// create a config with the valid signedKey
-	os.Setenv("SIGNED_KEY", "secretKey")
-	validConfig, err = config.SetConfigs()
-	if err != nil {
-		fmt.Println("error set config: ", err)
-	}
+	p := NewJWTPrincipal(map[string]string{"svc": subject, "tenant": tenant, "scope": "read, openid"})
+	ctx := ContextWithPrincipal(context.Background(), p)
 
-	// create a config with an invalid signedKey
-	os.Setenv("SIGNED_KEY", "secretKeyInvalid")
-	invalidConfig, err = config.SetConfigs()
-	if err != nil {
-		fmt.Println("error set config: ", err)
-	}
-}
+	got, ok := PrincipalFromContext(ctx)
+	tests.MaybeFail("principal_of_jwt",
+		tests.Expect(ok, true),
+		tests.Expect(got.Kind, PrincipalJWT),
+		tests.Expect(got.Scopes, []string{"read", "openid"}))
 
-func teardown() {}
+	tn, ok := TenantFromContext(ctx)
+	tests.MaybeFail("principal_tenant", tests.Expect(ok, true), tests.Expect(tn, tenant))
 
-// JWTAccessGenerate generate the jwt access token
-type JWTAccessGenerate struct {
-	signedKeyID  string // identifiant refering to the SignedKey
-	signedKey    []byte // secret key
-	signedMethod jwt.SigningMethod
+	_, ok = TenantFromContext(context.Background())
+	tests.MaybeFail("principal_missing", tests.Expect(ok, false))
 }
 
-func NewJWTAccessGenerate() *JWTAccessGenerate {
-	na := &JWTAccessGenerate{}
-
-	na.signedKeyID = key
-	na.signedKey = []byte(signKey)
-	na.signedMethod = jwt.SigningMethodHS256
-	return na
+// authClientMock stand for the auth_svc grpc server
+type authClientMock struct {
+	pb.AuthManagementClient
+	err error
 }
 
-func (a *JWTAccessGenerate) GenerateOpenidJWToken(expiresAt int64, ui map[string]string) (string, error) {
-
-	claims := &JWTAccessClaims{
-		StandardClaims: jwt.StandardClaims{
-			Audience:  audience,
-			Subject:   subject,
-			ExpiresAt: expiresAt,
-		},
-		UserInfo: ui,
+func (a *authClientMock) ValidateToken(ctx context.Context, in *pb.Jwtoken, opts ...grpc.CallOption) (*pb.ValidateTokenResponse, error) {
+	if a.err != nil {
+		return nil, a.err
 	}
 
-	token := jwt.NewWithClaims(a.signedMethod, claims)
-	if a.signedKeyID != "" {
-		token.Header["kid"] = a.signedKeyID
+	switch in.GetJwt() {
+	case validToken:
+		return &pb.ValidateTokenResponse{
+			IsOk:         true,
+			ResponseCode: http.StatusOK,
+			Role:         "APIserver",
+			Svc:          subject,
+			Scope:        "read, openid",
+			Tenant:       tenant,
+		}, nil
+	case noTenantToken:
+		return &pb.ValidateTokenResponse{
+			IsOk:         true,
+			ResponseCode: http.StatusOK,
+			Role:         "APIserver",
+			Svc:          subject,
+		}, nil
+	case expiredToken:
+		return &pb.ValidateTokenResponse{ResponseCode: http.StatusUnauthorized}, nil
+	default:
+		return &pb.ValidateTokenResponse{ResponseCode: http.StatusForbidden}, nil
 	}
-	var key interface{}
-	key = a.signedKey
-
-	access, err := token.SignedString(key)
-	if err != nil {
-		return "", err
-	}
-
-	return access, nil
 }
 
-type JWTAccessClaims struct {
-	jwt.StandardClaims
-	UserInfo map[string]string `json:"openidInfo"`
-	// AccessToken  string            `json:"accessToken"`
-}
+func (a *authClientMock) ValidateAPIKey(ctx context.Context, in *pb.APIKey, opts ...grpc.CallOption) (*pb.ValidateAPIKeyResponse, error) {
+	if a.err != nil {
+		return nil, a.err
+	}
 
-// Valid claims verification
-func (a *JWTAccessClaims) Valid() error {
-	if time.Unix(a.ExpiresAt, 0).Before(time.Now()) {
-		// if a.ExpiresAt < oneMonthAgo {
-		return fmt.Errorf("error invalid access token")
+	if in.GetKey() != validAPIKey {
+		return &pb.ValidateAPIKeyResponse{ResponseCode: http.StatusForbidden}, nil
 	}
-	return nil
+	return &pb.ValidateAPIKeyResponse{
+		IsOk:         true,
+		ResponseCode: http.StatusOK,
+		KeyId:        "keyID",
+		Name:         "partner",
+		Tenant:       tenant,
+		Scopes:       []string{"orders:read"},
+	}, nil
 }
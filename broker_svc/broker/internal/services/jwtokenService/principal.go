@@ -0,0 +1,68 @@
+package jwtokenService
+
+import (
+	"context"
+	"strings"
+)
+
+// the kinds of caller the REST middleware authenticate
+const (
+	PrincipalJWT    = "jwt"
+	PrincipalAPIKey = "apikey"
+)
+
+// Principal is the authenticated caller of a request, an APIserver(jwt)
+// or a third party integrator(api key)
+type Principal struct {
+	Kind    string
+	Subject string
+	Name    string
+	Tenant  string
+	Scopes  []string
+}
+
+// NewJWTPrincipal return the principal of the infos of a validated jwt,
+// the jwt scope is space or comma separated
+func NewJWTPrincipal(infos map[string]string) *Principal {
+	return &Principal{
+		Kind:    PrincipalJWT,
+		Subject: infos["svc"],
+		Name:    infos["svc"],
+		Tenant:  infos["tenant"],
+		Scopes: strings.FieldsFunc(infos["scope"], func(r rune) bool {
+			return r == ' ' || r == ','
+		}),
+	}
+}
+
+// HasScope return true if the principal was granted scope
+func (p *Principal) HasScope(scope string) bool {
+	for _, s := range p.Scopes {
+		if s == scope {
+			return true
+		}
+	}
+	return false
+}
+
+type principalCtxKey struct{}
+
+// ContextWithPrincipal return a copy of ctx which carry the principal
+func ContextWithPrincipal(ctx context.Context, p *Principal) context.Context {
+	return context.WithValue(ctx, principalCtxKey{}, p)
+}
+
+// PrincipalFromContext return the principal set by ContextWithPrincipal
+func PrincipalFromContext(ctx context.Context) (*Principal, bool) {
+	p, ok := ctx.Value(principalCtxKey{}).(*Principal)
+	return p, ok
+}
+
+// TenantFromContext return the tenant of the request's principal
+func TenantFromContext(ctx context.Context) (string, bool) {
+	p, ok := PrincipalFromContext(ctx)
+	if !ok {
+		return "", false
+	}
+	return p.Tenant, true
+}
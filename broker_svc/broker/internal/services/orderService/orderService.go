@@ -7,8 +7,16 @@ import (
 	"gitlab.com/grpasr/asonrythme/broker_svc/broker/internal/dto/orderDTO"
 	e "gitlab.com/grpasr/common/errors/json"
 	obs "gitlab.com/grpasr/common/observability"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/wrapperspb"
 )
 
+// TenantMetadataKey carry the tenant of the order's requests, order scope its
+// datas to it
+const TenantMetadataKey = "x-tenant-id"
+
 // got a grpcHandle, like the service handle the conversion to grpc then the grpcHandle send the message
 // got an kafkaHandle, same...
 // depends of the request, run async(kafka) or sync(grpc) req
@@ -17,8 +25,8 @@ import (
 //
 //go:generate mockgen -destination=../../mocks/services/mockOrderService.go -package=services gitlab.com/grpasr/asonrythme/broker_svc/broker/internal/services/orderService IOrderService
 type IOrderService interface {
-	OrderCreateService(order orderDTO.Order) e.IError
-	OrderGetService()
+	OrderCreateService(ctx context.Context, tenant string, order orderDTO.Order) e.IError
+	OrderGetService(ctx context.Context, tenant, id string) e.IError
 }
 
 type orderService struct {
@@ -29,9 +37,9 @@ func NewOrderService(grpcClt pb.OrderManagementClient) IOrderService {
 	return &orderService{grpcClt}
 }
 
-func (o *orderService) OrderCreateService(order orderDTO.Order) e.IError {
+func (o *orderService) OrderCreateService(ctx context.Context, tenant string, order orderDTO.Order) e.IError {
 	// move the order to grpc or kfk
-	ctx := context.Background()
+	ctx = metadata.AppendToOutgoingContext(ctx, TenantMetadataKey, tenant)
 
 	pbOrder := &pb.Order{}
 
@@ -73,6 +81,40 @@ func (o *orderService) OrderCreateService(order orderDTO.Order) e.IError {
 	return ce
 }
 
-func (o *orderService) OrderGetService() {
-	fmt.Println("hit GetOrderService....")
+// OrderGetService return the order id of tenant, the order of an other tenant
+// is not found
+func (o *orderService) OrderGetService(ctx context.Context, tenant, id string) e.IError {
+	ctx = metadata.AppendToOutgoingContext(ctx, TenantMetadataKey, tenant)
+
+	ctx, span := obs.Tracing.SPNGetFromCTX(ctx, "brokerSvc_getOrder")
+	defer span.End()
+
+	res, err := o.grpcClient.GetOrder(ctx, &wrapperspb.StringValue{Value: id})
+	if err != nil {
+		if status.Code(err) == codes.NotFound {
+			return e.NewCustomHTTPStatus(e.StatusNotFound)
+		}
+		obs.Logging.NewLogHandler(obs.Logging.LLHError()).
+			Err(err).
+			Msg("failed GetOrder")
+		return e.NewCustomHTTPStatus(e.StatusBadRequest)
+	}
+
+	order := orderDTO.Order{
+		ID:              res.Id,
+		ShippingAddress: res.ShippingAddress,
+	}
+	for _, li := range res.LineItem {
+		order.Items = append(order.Items, orderDTO.LineItem{
+			ItemCode: li.ItemCode,
+			Quantity: int(li.Quantity),
+		})
+	}
+
+	ce := e.NewCustomHTTPStatus(e.StatusOK)
+	pl := make(map[string]interface{})
+	pl["order"] = order
+	ce.SetPayload(pl)
+
+	return ce
 }
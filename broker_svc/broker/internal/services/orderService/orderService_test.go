@@ -9,7 +9,10 @@ import (
 	obs "gitlab.com/grpasr/common/observability"
 	"gitlab.com/grpasr/common/tests"
 	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
 	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
 	"google.golang.org/protobuf/types/known/wrapperspb"
 	"log"
 	"net/http"
@@ -25,6 +28,8 @@ var client pb.OrderManagementClient
 var serv *grpc.Server
 var orderServiceT IOrderService
 
+const tenant = "default"
+
 func TestMain(m *testing.M) {
 	// Initialize your test context
 	setupTests()
@@ -53,7 +58,7 @@ func Test_create_an_order_fail_if_invalid_grpc_connection(t *testing.T) {
 		ShippingAddress: "the shipping address",
 	}
 
-	ce := orderServiceT.OrderCreateService(order)
+	ce := orderServiceT.OrderCreateService(context.Background(), tenant, order)
 
 	// Assert
 	tests.MaybeFail("http_status", tests.Expect(ce.GetCode(), http.StatusBadRequest))
@@ -80,17 +85,27 @@ func Test_create_an_order_is_successful(t *testing.T) {
 		ShippingAddress: "the shipping address",
 	}
 
-	ce := orderServiceT.OrderCreateService(order)
+	ce := orderServiceT.OrderCreateService(context.Background(), tenant, order)
 
 	// Assert
 	tests.MaybeFail("http_status", tests.Expect(ce.GetCode(), http.StatusOK))
 	tests.MaybeFail("http_status", tests.Expect(len(ce.GetPayload()), 1))
 	tests.MaybeFail("http_status", tests.Expect(ce.GetPayload()["order_id"], order.ID))
+
+	// the order is read back by its tenant only
+	ce = orderServiceT.OrderGetService(context.Background(), tenant, order.ID)
+	tests.MaybeFail("get_order", tests.Expect(ce.GetCode(), http.StatusOK))
+	got, _ := ce.GetPayload()["order"].(orderDTO.Order)
+	tests.MaybeFail("get_order", tests.Expect(got.ShippingAddress, order.ShippingAddress))
+
+	ce = orderServiceT.OrderGetService(context.Background(), "otherTenant", order.ID)
+	tests.MaybeFail("get_order_other_tenant", tests.Expect(ce.GetCode(), http.StatusNotFound))
 }
 
-// MOCK THE SERVER
+// MOCK THE SERVER, the orders are kept by tenant as order does
 type OrderServer struct {
 	pb.UnimplementedOrderManagementServer
+	orders map[string]*pb.Order
 }
 
 func (s *OrderServer) CreateOrder(ctx context.Context, order *pb.Order) (*wrapperspb.StringValue, error) {
@@ -99,11 +114,27 @@ func (s *OrderServer) CreateOrder(ctx context.Context, order *pb.Order) (*wrappe
 	// fmt.Println("get the order shippingAddress: ", order.ShippingAddress)
 	// fmt.Println("get the order : ", order.Id)
 
-	// TODO add what ever needed logic
+	s.orders[tenantFromMD(ctx)+"/"+order.Id] = order
 
 	return &wrapperspb.StringValue{Value: order.Id}, nil
 }
 
+func (s *OrderServer) GetOrder(ctx context.Context, id *wrapperspb.StringValue) (*pb.Order, error) {
+	order, ok := s.orders[tenantFromMD(ctx)+"/"+id.Value]
+	if !ok {
+		return nil, status.Error(codes.NotFound, "order not found")
+	}
+	return order, nil
+}
+
+func tenantFromMD(ctx context.Context) string {
+	md, _ := metadata.FromIncomingContext(ctx)
+	if v := md.Get(TenantMetadataKey); len(v) > 0 {
+		return v[0]
+	}
+	return ""
+}
+
 func setupTests() {
 
 	obs.SetObservabilityFacade("orderTest")
@@ -153,6 +184,7 @@ func MockGrpcOrderServer() (*grpc.Server, error) {
 
 	osrv := &OrderServer{
 		// jwtSvc: svc.JWTokenService,
+		orders: make(map[string]*pb.Order),
 	}
 
 	pb.RegisterOrderManagementServer(gsrv, osrv)
@@ -17,7 +17,7 @@ type RestServices struct {
 func NewRestServices(grpcHandler clients.GRPCClients, conf *config.Config) RestServices {
 	svc := RestServices{
 		OrderService:   orderService.NewOrderService(grpcHandler.OrderGetClient()),
-		JWTokenService: jwtokenService.NewJWTokenService(conf),
+		JWTokenService: jwtokenService.NewJWTokenService(grpcHandler.AuthGetClient()),
 	}
 	return svc
 }
@@ -29,7 +29,7 @@ type GrpcServices struct {
 
 func NewGrpcServices(grpcHandler clients.GRPCClients, conf *config.Config) GrpcServices {
 	svc := GrpcServices{
-		JWTokenService: jwtokenService.NewJWTokenService(conf),
+		JWTokenService: jwtokenService.NewJWTokenService(grpcHandler.AuthGetClient()),
 	}
 	return svc
 }
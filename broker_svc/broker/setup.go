@@ -1,9 +1,56 @@
 package broker
 
 import (
+	"context"
 	"gitlab.com/grpasr/asonrythme/broker_svc/broker/internal/config"
+	"gitlab.com/grpasr/asonrythme/broker_svc/broker/internal/handlers/grpc/clients"
+	"gitlab.com/grpasr/asonrythme/broker_svc/broker/internal/services"
+	"gitlab.com/grpasr/asonrythme/shared/health"
+	"gitlab.com/grpasr/asonrythme/shared/kvconfig"
+	"gitlab.com/grpasr/asonrythme/shared/tokensource"
+	"log"
+	"time"
 )
 
 func setConfigs() (*config.Config, error) {
 	return config.SetConfigs()
 }
+
+// loadRemoteConfigs apply the configuration of the broker stored in
+// registry_svc, the local one is kept if it can not be loaded
+func loadRemoteConfigs() {
+	client := kvconfig.New(kvconfig.Config{
+		RegistryURL: conf.KVGetURL(),
+		Env:         conf.GlbGetenv(),
+		Service:     conf.GlbGetServiceName(),
+		Instance:    conf.KVGetInstance(),
+		CacheFile:   conf.KVGetCacheFile(),
+	}, tokenSource)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	remote := conf.Remote()
+	revision, err := client.Load(ctx, &remote)
+	if err != nil {
+		log.Println("broker_svc/broker keep the local configs: ", err)
+		return
+	}
+	conf.ApplyRemote(remote)
+	log.Println("broker_svc/broker see the configs revision: ", revision)
+}
+
+// setHealth register the dependencies checked by the readiness: the grpc
+// connections to auth_svc and order, and the broker's own jwtoken
+func setHealth(conf *config.Config, grpcClients clients.GRPCClients, svc services.GrpcServices, ts *tokensource.TokenSource) *health.Health {
+	hl := health.NewHealth(time.Duration(conf.HTTPGetHealthTimeout()) * time.Second)
+	hl.HealthRegister("auth_grpc", health.GRPCConnCheck(grpcClients.AuthGetConn()))
+	hl.HealthRegister("order_grpc", health.GRPCConnCheck(grpcClients.OrderGetConn()))
+	hl.HealthRegister("jwtoken", func(ctx context.Context) error {
+		if _, ce := svc.JWTokenIsValidToken(ctx, ts.Token()); ce != nil {
+			return ce
+		}
+		return nil
+	})
+	return hl
+}
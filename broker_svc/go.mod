@@ -2,13 +2,16 @@ module gitlab.com/grpasr/asonrythme/broker_svc
 
 go 1.21.3
 
-replace gitlab.com/grpasr/common => ./../common
+replace (
+	gitlab.com/grpasr/asonrythme/shared => ./../shared
+	gitlab.com/grpasr/common => ./../common
+)
 
 require (
-	github.com/golang-jwt/jwt v3.2.2+incompatible
 	github.com/golang/mock v1.4.4
 	github.com/gorilla/mux v1.8.0
 	github.com/spf13/viper v1.17.0
+	gitlab.com/grpasr/asonrythme/shared v0.0.0-00010101000000-000000000000
 	gitlab.com/grpasr/common v0.0.0-00010101000000-000000000000
 	google.golang.org/grpc v1.59.0
 	google.golang.org/protobuf v1.31.0
@@ -4,11 +4,14 @@ go 1.21.0
 
 // toolchain go1.21.4
 
+replace gitlab.com/grpasr/asonrythme/shared => ./../shared
+
 // replace gitlab.com/grpasr/common => ./../common
 
 require (
 	github.com/confluentinc/confluent-kafka-go/v2 v2.3.0
 	github.com/google/uuid v1.3.1
+	gitlab.com/grpasr/asonrythme/shared v0.0.0-00010101000000-000000000000
 	gitlab.com/grpasr/common v0.0.0-20240418081513-7e280db72a3f
 	google.golang.org/grpc v1.59.0
 	google.golang.org/protobuf v1.31.0
@@ -1,6 +1,8 @@
 package loader
 
 import (
+	"crypto/rand"
+	"encoding/base64"
 	"fmt"
 )
 
@@ -16,10 +18,10 @@ const (
 	authSvcURLDefault           string = "http://localhost:9096/v1" // all varEnv
 	authSvcPathDefault                 = "apiauth"
 	authSvcTokenEndpointDefault        = "http://localhost:9096/v1/oauth/token"
-	codeVerifierDefault                = "exampleCodeVerifier"
 	serviceKeyIDDefault                = "order"
 	serviceSecretkeyDefault            = "orderSecret"
 	scopeDefault                       = "read, openid"
+	codeVerifierLength                 = 32 // random bytes, 43 chars once base64url encoded
 )
 
 // Config wrap all configs
@@ -101,7 +103,6 @@ type JWTRequestConfig struct {
 	authSvcURL           string
 	authSvcPath          string
 	authSvcTokenEndpoint string
-	codeVerifier         string
 	serviceKeyID         string
 	serviceSecretkey     string
 	scope                string
@@ -112,7 +113,6 @@ func NewJWTRequestConfig() *JWTRequestConfig {
 	r.authSvcURL = authSvcURLDefault
 	r.authSvcPath = authSvcPathDefault
 	r.authSvcTokenEndpoint = authSvcTokenEndpointDefault
-	r.codeVerifier = codeVerifierDefault
 	r.serviceKeyID = serviceKeyIDDefault
 	r.serviceSecretkey = serviceSecretkeyDefault
 	r.scope = scopeDefault
@@ -146,13 +146,14 @@ func (r *JWTRequestConfig) JwtGetAuthSvcTokenEndpoint() string {
 	return r.authSvcTokenEndpoint
 }
 
-func (r *JWTRequestConfig) JwtSetCodeVerifier(v string) {
-	if v != "" {
-		r.codeVerifier = v
+// JwtNewCodeVerifier generate a fresh PKCE code_verifier(rfc7636),
+// a new one must be used for each login
+func (r *JWTRequestConfig) JwtNewCodeVerifier() (string, error) {
+	b := make([]byte, codeVerifierLength)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
 	}
-}
-func (r *JWTRequestConfig) JwtGetCodeVerifier() string {
-	return r.codeVerifier
+	return base64.RawURLEncoding.EncodeToString(b), nil
 }
 
 func (r *JWTRequestConfig) JwtSetServiceKeyID(v string) {
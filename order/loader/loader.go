@@ -3,6 +3,8 @@ package loader
 import (
 	"context"
 	"fmt"
+	"gitlab.com/grpasr/asonrythme/shared/manifest"
+	"gitlab.com/grpasr/asonrythme/shared/tokensource"
 	"gitlab.com/grpasr/common/apiserver"
 	"gitlab.com/grpasr/common/configloader"
 	e "gitlab.com/grpasr/common/errors/json"
@@ -10,36 +12,48 @@ import (
 	"log"
 	"os"
 	"path/filepath"
+	"strings"
 	"time"
 )
 
-var jwtoken string
+var tokenSource *tokensource.TokenSource
 
 // init authenticate the service with the auth_svc and get the service's jwt_token
 func init() {
 
 	conf := setConfigs()
 
+	// a fresh PKCE code_verifier is generated for each login
+	codeVerifier, err := conf.JwtNewCodeVerifier()
+	if err != nil {
+		log.Fatal("order/loader error generating the code_verifier: ", err)
+	}
+
 	// get jwtoken from auth_svc
 	apiServerAuth := apiserver.NewAPIserverAuth(
 		conf.JwtGetAuthSvcURL(),           // "http://localhost:9096/v1", // all varEnv
 		conf.JwtGetAuthSvcPath(),          // "apiauth",
 		conf.GlbGetHTTPSvcURL(),           // "http://localhost:50001",
 		conf.JwtGetAuthSvcTokenEndpoint(), // "http://localhost:9096/v1/oauth/token",
-		conf.JwtGetCodeVerifier(),         //"exampleCodeVerifier",
+		codeVerifier,                      // fresh per login
 		conf.JwtGetServiceKeyID(),         // "order",
 		conf.JwtGetServiceSecretKey(),     // "orderSecret",
 		conf.JwtGetScope(),                // "read, openid",
 	)
 
-	err := apiServerAuth.Run(context.TODO(), int8(3), int8(5))
+	err = apiServerAuth.Run(context.TODO(), int8(3), int8(5))
 	if err != nil {
 		log.Fatal("Order svc error authentication: ", err)
 	}
 
-	jwtoken = apiServerAuth.GetToken()
-
-	fmt.Println("see the jwtoken: ", jwtoken)
+	tokenSource, err = tokensource.New(apiServerAuth.GetToken(), tokensource.Config{
+		AuthSvcURL:   conf.JwtGetAuthSvcURL(),
+		ClientID:     conf.JwtGetServiceKeyID(),
+		ClientSecret: conf.JwtGetServiceSecretKey(),
+	})
+	if err != nil {
+		log.Fatal("order/loader error reading the jwtoken: ", err)
+	}
 }
 
 func Run() {
@@ -61,14 +75,22 @@ func Run() {
 		return
 	}
 
+	// the loader may start long after the login, renew an expired jwtoken
+	if tokenSource.Valid() != nil {
+		if err := tokenSource.Refresh(context.Background()); err != nil {
+			log.Fatal("refresh jwtoken failed: ", err)
+		}
+	}
+
 	// add Bearer token to configs
 	ad := restclient.AuthData{
 		AuthType: restclient.Bearer,
-		Token:    jwtoken,
+		Token:    tokenSource.Token(),
 	}
 
 	// the url format must be "http://path:port"
-	conf := restclient.NewConfig(fmt.Sprintf("http://%s", c.LDRConfig().GetServiceEndpointFormatedURL()), ad)
+	registryURL := fmt.Sprintf("http://%s", c.LDRConfig().GetServiceEndpointFormatedURL())
+	conf := restclient.NewConfig(registryURL, ad)
 
 	restSvc, err := restclient.NewRestService(conf, "multipart/form-data")
 	if err != nil {
@@ -98,6 +120,12 @@ func Run() {
 			c.LDRConfig().LcfgGetVersion(),
 			grpcPackage)
 
+		// the registry answer 304 if the files did not change
+		if manifest.UpToDate(ctx, registryURL+"/"+strings.TrimPrefix(downloadURL, "/"), tokenSource.Token(), grpcStorageDir) {
+			log.Println("GRPC types up to date, skip the download of: ", grpcPackage)
+			continue
+		}
+
 		errIErr = restSvc.HandleRetryRequest(
 			ctx,
 			restclient.NewRequest("GET", downloadURL, nil),
@@ -130,6 +158,11 @@ func Run() {
 			c.LDRConfig().LcfgGetVersion(),
 			configFile)
 
+		if manifest.UpToDate(ctx, registryURL+"/"+strings.TrimPrefix(downloadURL, "/"), tokenSource.Token(), configStorageDir) {
+			log.Println("config files up to date, skip the download of: ", configFile)
+			continue
+		}
+
 		errIErr = restSvc.HandleRetryRequest(
 			ctx,
 			restclient.NewRequest("GET", downloadURL, nil),
@@ -17,14 +17,12 @@ func setConfigs() Config {
 	authSvcURL := os.Getenv("AUTH_SVC_URL")
 	authSvcPATH := os.Getenv("AUTH_SVC_PATH")
 	authSvcTokenEndpoint := os.Getenv("AUTH_SVC_TOKEN_ENDPOINT")
-	codeVerifier := os.Getenv("CODE_VERIFIER")
 	serviceKeyID := os.Getenv("SERVICE_KEY_ID")
 	serviceSecretkey := os.Getenv("SERVICE_SECRET_KEY")
 	scope := os.Getenv("SCOPE")
 	c.JwtSetAuthSvcURL(authSvcURL)
 	c.JwtSetAuthSvcPath(authSvcPATH)
 	c.JwtSetAuthSvcTokenEndpoint(authSvcTokenEndpoint)
-	c.JwtSetCodeVerifier(codeVerifier)
 	c.JwtSetServiceKeyID(serviceKeyID)
 	c.JwtSetServiceSecretKey(serviceSecretkey)
 	c.JwtSetScope(scope)
@@ -1,7 +1,9 @@
 package config
 
 import (
+	"crypto/rand"
 	"crypto/tls"
+	"encoding/base64"
 	"fmt"
 	"os"
 	"strconv"
@@ -31,16 +33,24 @@ const (
 	authSvcURLDefault           string = "http://localhost:9096/v1" // all varEnv
 	authSvcPathDefault                 = "apiauth"
 	authSvcTokenEndpointDefault        = "http://localhost:9096/v1/oauth/token"
-	codeVerifierDefault                = "exampleCodeVerifier"
 	serviceKeyIDDefault                = "order"
 	serviceSecretkeyDefault            = "orderSecret"
 	scopeDefault                       = "read, openid"
+	codeVerifierLength                 = 32 // random bytes, 43 chars once base64url encoded
 
 	// observability
 	obsSamplingDefault          float64 = 0.6
 	obsScratchDelayDefault      int     = 30
 	obsCollectorEndpointDefault string  = "otel_collector:4317"
 
+	// discovery, order register itself in registry_svc if its url is set
+	discoveryURLDefault = ""
+	discoveryTTLDefault = 30 // seconds of the registration's lease
+
+	// config store of registry_svc, disabled if its url is not set
+	configStoreURLDefault   = ""
+	configStoreCacheDefault = "../order/configs/v1/remote/config.json"
+
 	// kafka
 	kafkaURLDefault                  string = "127.0.0.1:29093"
 	kafkaProducerKeyLocationDefault         = "../order/configs/v1/kafka/producer.key.pem"
@@ -93,14 +103,12 @@ func SetConfigs() (*Config, error) {
 	authSvcURL := os.Getenv("AUTH_SVC_URL")
 	authSvcPATH := os.Getenv("AUTH_SVC_PATH")
 	authSvcTokenEndpoint := os.Getenv("AUTH_SVC_TOKEN_ENDPOINT")
-	codeVerifier := os.Getenv("CODE_VERIFIER")
 	serviceKeyID := os.Getenv("SERVICE_KEY_ID")
 	serviceSecretkey := os.Getenv("SERVICE_SECRET_KEY")
 	scope := os.Getenv("SCOPE")
 	c.JwtSetAuthSvcURL(authSvcURL)
 	c.JwtSetAuthSvcPath(authSvcPATH)
 	c.JwtSetAuthSvcTokenEndpoint(authSvcTokenEndpoint)
-	c.JwtSetCodeVerifier(codeVerifier)
 	c.JwtSetServiceKeyID(serviceKeyID)
 	c.JwtSetServiceSecretKey(serviceSecretkey)
 	c.JwtSetScope(scope)
@@ -113,6 +121,20 @@ func SetConfigs() (*Config, error) {
 	c.obsSetScratchDelay(scrDelay)
 	c.obsSetCollectorEndpoint(collEndpoint)
 
+	// set the discovery
+	discoveryURL := os.Getenv("DISCOVERY_URL")
+	discoveryTTL := os.Getenv("DISCOVERY_TTL")
+	c.dscSetURL(discoveryURL)
+	c.dscSetTTL(discoveryTTL)
+
+	// set the config store, the instance is the host name by default
+	storeURL := os.Getenv("CONFIG_STORE_URL")
+	storeCache := os.Getenv("CONFIG_STORE_CACHE")
+	instance := os.Getenv("SERVICE_INSTANCE")
+	c.kvSetURL(storeURL)
+	c.kvSetCacheFile(storeCache)
+	c.kvSetInstance(instance)
+
 	// kafka
 	kafkaURL := os.Getenv("KAFKA_URL")
 	kafkaProdKeyLocation := os.Getenv("KAFKA_PRODUCER_KEY_LOCATION")
@@ -227,6 +249,8 @@ type Config struct {
 	ServerTLSConfig *tls.Config
 	*observability
 	*kafka
+	*discovery
+	*configStore
 }
 
 func NewConfig(goEnv string, serviceName ...string) *Config {
@@ -254,11 +278,48 @@ func NewConfig(goEnv string, serviceName ...string) *Config {
 		JWTRequestConfig: NewJWTRequestConfig(),
 		observability:    NewObservability(),
 		kafka:            NewKafka(),
+		discovery:        NewDiscovery(),
+		configStore:      NewConfigStore(),
 	}
 
 	return c
 }
 
+// discovery configs, order register its instance in registry_svc
+type discovery struct {
+	url string
+	ttl int
+}
+
+func NewDiscovery() *discovery {
+	return &discovery{
+		url: discoveryURLDefault,
+		ttl: discoveryTTLDefault,
+	}
+}
+
+func (d *discovery) dscSetURL(url string) {
+	if url != "" {
+		d.url = url
+	}
+}
+
+// DSCGetURL is the url of registry_svc, "http://localhost:4000", empty if
+// order is not registered
+func (d *discovery) DSCGetURL() string {
+	return d.url
+}
+
+func (d *discovery) dscSetTTL(ttl string) {
+	if i, err := strconv.Atoi(ttl); err == nil && i > 0 {
+		d.ttl = i
+	}
+}
+
+func (d *discovery) DSCGetTTL() int {
+	return d.ttl
+}
+
 // Global may concern any configs
 type Global struct {
 	golangEnv   string
@@ -429,7 +490,6 @@ type JWTRequestConfig struct {
 	authSvcURL           string
 	authSvcPath          string
 	authSvcTokenEndpoint string
-	codeVerifier         string
 	serviceKeyID         string
 	serviceSecretkey     string
 	scope                string
@@ -440,7 +500,6 @@ func NewJWTRequestConfig() *JWTRequestConfig {
 	r.authSvcURL = authSvcURLDefault
 	r.authSvcPath = authSvcPathDefault
 	r.authSvcTokenEndpoint = authSvcTokenEndpointDefault
-	r.codeVerifier = codeVerifierDefault
 	r.serviceKeyID = serviceKeyIDDefault
 	r.serviceSecretkey = serviceSecretkeyDefault
 	r.scope = scopeDefault
@@ -474,13 +533,14 @@ func (r *JWTRequestConfig) JwtGetAuthSvcTokenEndpoint() string {
 	return r.authSvcTokenEndpoint
 }
 
-func (r *JWTRequestConfig) JwtSetCodeVerifier(v string) {
-	if v != "" {
-		r.codeVerifier = v
+// JwtNewCodeVerifier generate a fresh PKCE code_verifier(rfc7636),
+// a new one must be used for each login
+func (r *JWTRequestConfig) JwtNewCodeVerifier() (string, error) {
+	b := make([]byte, codeVerifierLength)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
 	}
-}
-func (r *JWTRequestConfig) JwtGetCodeVerifier() string {
-	return r.codeVerifier
+	return base64.RawURLEncoding.EncodeToString(b), nil
 }
 
 func (r *JWTRequestConfig) JwtSetServiceKeyID(v string) {
@@ -0,0 +1,88 @@
+package config
+
+import (
+	"os"
+	"strconv"
+)
+
+// configStore locate the configuration of order in registry_svc
+type configStore struct {
+	storeURL  string
+	cacheFile string
+	instance  string
+}
+
+func NewConfigStore() *configStore {
+	cs := &configStore{
+		storeURL:  configStoreURLDefault,
+		cacheFile: configStoreCacheDefault,
+	}
+	cs.instance, _ = os.Hostname()
+	return cs
+}
+
+func (cs *configStore) kvSetURL(url string) {
+	if url != "" {
+		cs.storeURL = url
+	}
+}
+
+// KVGetURL is the url of registry_svc, "http://localhost:4000", empty if
+// only the local configuration is used
+func (cs *configStore) KVGetURL() string {
+	return cs.storeURL
+}
+
+func (cs *configStore) kvSetCacheFile(path string) {
+	if path != "" {
+		cs.cacheFile = path
+	}
+}
+
+// KVGetCacheFile is the copy of the last configuration received, used if
+// registry_svc can not be reached
+func (cs *configStore) KVGetCacheFile() string {
+	return cs.cacheFile
+}
+
+func (cs *configStore) kvSetInstance(instance string) {
+	if instance != "" {
+		cs.instance = instance
+	}
+}
+
+func (cs *configStore) KVGetInstance() string {
+	return cs.instance
+}
+
+// Remote is the configuration order read from registry_svc, the keys are
+// "kafka.url", "obs.sampling"...
+type Remote struct {
+	Kafka struct {
+		URL string `json:"url"`
+	} `json:"kafka"`
+	Obs struct {
+		Sampling          float64 `json:"sampling"`
+		ScratchDelay      int     `json:"scratchDelay"`
+		CollectorEndpoint string  `json:"collectorEndpoint"`
+	} `json:"obs"`
+}
+
+// Remote return the current values, the local fallbacks of the ones not
+// set in registry_svc
+func (c *Config) Remote() Remote {
+	r := Remote{}
+	r.Kafka.URL = c.KFKGetURL()
+	r.Obs.Sampling = c.OBSGetSampling()
+	r.Obs.ScratchDelay = c.OBSGetScratchDelay()
+	r.Obs.CollectorEndpoint = c.OBSGetCollectorEndpoint()
+	return r
+}
+
+// ApplyRemote set the values read from registry_svc
+func (c *Config) ApplyRemote(r Remote) {
+	c.kfkSetURL(r.Kafka.URL)
+	c.obsSetSampling(strconv.FormatFloat(r.Obs.Sampling, 'f', -1, 64))
+	c.obsSetScratchDelay(strconv.Itoa(r.Obs.ScratchDelay))
+	c.obsSetCollectorEndpoint(r.Obs.CollectorEndpoint)
+}
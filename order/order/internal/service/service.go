@@ -6,8 +6,10 @@ import (
 	"sync"
 )
 
+// OrderSvc keep the orders by tenant, a tenant never see the orders of an
+// other one
 type OrderSvc struct {
-	orderMap map[string]types.Order
+	orderMap map[string]types.Order // tenant/id
 	sync.RWMutex
 }
 
@@ -18,13 +20,13 @@ func NewOrderSvc() OrderSvc {
 
 func (o *OrderSvc) PlaceOrder(order types.Order) {
 	o.Lock()
-	o.orderMap[order.ID] = order
+	o.orderMap[orderKey(order.Tenant, order.ID)] = order
 	o.Unlock()
 }
 
-func (o *OrderSvc) GetOrder(id string) (types.Order, error) {
+func (o *OrderSvc) GetOrder(tenant, id string) (types.Order, error) {
 	o.RLock()
-	value, ok := o.orderMap[id]
+	value, ok := o.orderMap[orderKey(tenant, id)]
 	o.RUnlock()
 	if ok {
 		orderFromMap := value
@@ -34,3 +36,7 @@ func (o *OrderSvc) GetOrder(id string) (types.Order, error) {
 		return no, fmt.Errorf("Order not found")
 	}
 }
+
+func orderKey(tenant, id string) string {
+	return tenant + "/" + id
+}
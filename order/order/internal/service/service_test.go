@@ -0,0 +1,21 @@
+package service
+
+import (
+	"gitlab.com/grpasr/common/tests"
+	"order/order/internal/types"
+	"testing"
+)
+
+func TestOrderSvcTenant(t *testing.T) {
+	tests.MaybeFail = tests.InitFailFunc(t)
+
+	o := NewOrderSvc()
+	o.PlaceOrder(types.Order{ID: "1a", Tenant: "default", ShippingAddress: "the shipping address"})
+
+	order, err := o.GetOrder("default", "1a")
+	tests.MaybeFail("GetOrder", err, tests.Expect(order.ShippingAddress, "the shipping address"))
+
+	// the order of an other tenant is not found
+	_, err = o.GetOrder("otherTenant", "1a")
+	tests.MaybeFail("GetOrder_other_tenant", tests.Expect(err != nil, true))
+}
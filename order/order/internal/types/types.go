@@ -2,6 +2,7 @@ package types
 
 type Order struct {
 	ID              string     `json:"id"`
+	Tenant          string     `json:"tenant"`
 	Items           []LineItem `json:"items"`
 	ShippingAddress string     `json:"shipping_address"`
 }
@@ -1,9 +1,85 @@
 package order
 
 import (
+	"context"
+	"fmt"
+	"gitlab.com/grpasr/asonrythme/shared/discovery"
+	"gitlab.com/grpasr/asonrythme/shared/health"
+	"gitlab.com/grpasr/asonrythme/shared/kvconfig"
+	"log"
+	pbb "order/order/api/v1/brokerjwt"
 	"order/order/internal/config"
+	"time"
 )
 
 func setConfigs() (*config.Config, error) {
 	return config.SetConfigs()
 }
+
+// loadRemoteConfigs apply the configuration of order stored in
+// registry_svc, the local one is kept if it can not be loaded
+func loadRemoteConfigs() {
+	client := kvconfig.New(kvconfig.Config{
+		RegistryURL: conf.KVGetURL(),
+		Env:         conf.GlbGetenv(),
+		Service:     conf.GlbGetServiceName(),
+		Instance:    conf.KVGetInstance(),
+		CacheFile:   conf.KVGetCacheFile(),
+	}, tokenSource)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	remote := conf.Remote()
+	revision, err := client.Load(ctx, &remote)
+	if err != nil {
+		log.Println("Order svc keep the local configs: ", err)
+		return
+	}
+	conf.ApplyRemote(remote)
+	log.Println("Order svc see the configs revision: ", revision)
+}
+
+// register keep the instance of order registered in registry_svc until
+// ctx is done, the returned channel is closed once it is deregistered
+func register(ctx context.Context) <-chan struct{} {
+	none := make(chan struct{})
+	close(none)
+	if conf.DSCGetURL() == "" {
+		return none
+	}
+
+	// registry_svc accept only the name of the jwtoken's subject
+	client := discovery.NewClient(conf.DSCGetURL(), tokenSource)
+	deregistered, err := client.Keepalive(ctx, discovery.Instance{
+		Name:    conf.GlbGetServiceName(),
+		Env:     conf.GlbGetenv(),
+		Address: conf.GRPCGetAddress(),
+		Port:    conf.GRPCGetPort(),
+	}, time.Duration(conf.DSCGetTTL())*time.Second)
+	if err != nil {
+		log.Println("Order svc is not registered in registry_svc: ", err)
+		return none
+	}
+	log.Println("Order svc registered in registry_svc")
+	return deregistered
+}
+
+// setHealth register the dependencies checked by the readiness: kafka,
+// the grpc connection to broker_svc and the order's own jwtoken
+func setHealth(bjc *BrokerJWTClient) *health.Health {
+	hl := health.NewHealth(0)
+	hl.HealthRegister("kafka", kfk.ping)
+	hl.HealthRegister("broker_grpc", health.GRPCConnCheck(bjc.conn))
+	hl.HealthRegister("jwtoken", func(ctx context.Context) error {
+		resp, err := bjc.getBrokerClient().IsJwtokenOK(ctx, &pbb.Jwtoken{Jwt: tokenSource.Token()})
+		if err != nil {
+			return err
+		}
+		if !resp.GetIsOk() {
+			return fmt.Errorf("jwtoken rejected, response code %d", resp.GetResponseCode())
+		}
+		return nil
+	})
+	return hl
+}
@@ -6,16 +6,19 @@ import (
 	"github.com/gorilla/mux"
 	"gitlab.com/grpasr/asonrythme/registry_svc/internal/config"
 	"gitlab.com/grpasr/asonrythme/registry_svc/internal/handlers/rest"
+	"gitlab.com/grpasr/asonrythme/registry_svc/internal/health"
 	"gitlab.com/grpasr/asonrythme/registry_svc/internal/services"
+	"gitlab.com/grpasr/asonrythme/shared/tokensource"
 	"gitlab.com/grpasr/common/apiserver"
 	"log"
 	"net/http"
-	// "os"
+	"os"
+	"path/filepath"
 )
 
 var (
-	jwtoken string
-	conf    *config.Config
+	tokenSource *tokensource.TokenSource
+	conf        *config.Config
 )
 
 func init() {
@@ -23,38 +26,87 @@ func init() {
 
 	conf = config.NewConfig()
 
+	// a fresh PKCE code_verifier is generated for each login
+	codeVerifier, err := conf.JwtNewCodeVerifier()
+	if err != nil {
+		log.Fatal("registry_svc error generating the code_verifier: ", err)
+	}
+
 	// get jwtoken from auth_svc
 	apiServerAuth := apiserver.NewAPIserverAuth(
 		conf.JwtGetAuthSvcURL(),           // "http://localhost:9096/v1", // all varEnv
 		conf.JwtGetAuthSvcPath(),          // "apiauth",
 		conf.HTTPGetHTTPFormatedURL(),     // "http://localhost:4000",
 		conf.JwtGetAuthSvcTokenEndpoint(), // "http://localhost:9096/v1/oauth/token",
-		conf.JwtGetCodeVerifier(),         //"exampleCodeVerifier",
+		codeVerifier,                      // fresh per login
 		conf.JwtGetServiceKeyID(),         // "registrySvc",
 		conf.JwtGetServiceSecretKey(),     // "registrySvcSecret",
 		conf.JwtGetScope(),                // "read, openid",
 	)
 
-	err := apiServerAuth.Run(context.TODO(), int8(3), int8(5))
+	err = apiServerAuth.Run(context.TODO(), int8(3), int8(5))
 	if err != nil {
 		log.Fatal("registry_svc error authentication: ", err)
 	}
 
-	jwtoken = apiServerAuth.GetToken()
-
-	fmt.Println("see the registry_svc token: ", jwtoken)
+	// the jwtoken is refreshed ahead of its expiry, see main
+	tokenSource, err = tokensource.New(apiServerAuth.GetToken(), tokensource.Config{
+		AuthSvcURL:   conf.JwtGetAuthSvcURL(),
+		ClientID:     conf.JwtGetServiceKeyID(),
+		ClientSecret: conf.JwtGetServiceSecretKey(),
+	})
+	if err != nil {
+		log.Fatal("registry_svc error reading the jwtoken: ", err)
+	}
 }
 
 func main() {
 	restConfigs := config.NewRestConfig()
 
-	svc := services.NewServices()
+	go tokenSource.Run(context.Background())
+
+	// the jwt are validated with the signing keys published by auth_svc
+	keySet := services.NewKeySetClient(
+		conf.JwtGetAuthSvcURL(),
+		conf.JwtGetServiceKeyID(),
+		conf.JwtGetServiceSecretKey())
+	svc := services.NewServices(keySet)
 
-	router := rest.Handler(restConfigs, svc.JWTokenService)
+	// the readiness check the storage and the registry's own jwtoken
+	hl := setHealth(restConfigs, svc.JWTokenService)
+
+	router := rest.Handler(restConfigs, svc.JWTokenService, hl)
 
 	startServer(router, conf)
 }
 
+func setHealth(restConfigs config.IRestConfig, jwtSvc *services.JWTokenService) *health.Health {
+	hl := health.NewHealth(0)
+
+	hl.HealthRegister("storage", func(ctx context.Context) error {
+		for _, dir := range []string{restConfigs.RESTGetGrpcDirectory(), restConfigs.RESTGetConfigsDirectory()} {
+			p := filepath.Join(restConfigs.RESTGetPathToStorage(), dir)
+			fi, err := os.Stat(p)
+			if err != nil {
+				return err
+			}
+			if !fi.IsDir() {
+				return fmt.Errorf("%s is not a directory", p)
+			}
+		}
+		return nil
+	})
+
+	hl.HealthRegister("jwtoken", func(ctx context.Context) error {
+		if _, ce := jwtSvc.JWTokenIsValidToken(ctx, tokenSource.Token()); ce != nil {
+			return ce
+		}
+		return nil
+	})
+
+	return hl
+}
+
 func startServer(router *mux.Router, configs *config.Config) {
 	// starting server
 	// address := os.Getenv("SERVER_ADDRESS")
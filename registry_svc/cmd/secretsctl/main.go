@@ -0,0 +1,148 @@
+// secretsctl seal the private keys served by registry_svc, the storage
+// directory keeps only the sealed secrets(FILE.enc)
+//
+//	secretsctl [-key-file FILE] [-root DIR] <command> [flags]
+//
+//	keygen -id ID          print a new master key entry, append it to the ring
+//	import [-keep] FILE... seal the files and remove the cleartext
+//	rotate DIR...          seal again the secrets with new data keys under
+//	                       the current master key
+//	scan DIR...            list the cleartext private keys, fail if any
+//
+// the ring of master keys is SECRETS_MASTER_KEY or the file -key-file,
+// SECRETS_MASTER_KEY_FILE by default, as for registry_svc. To rotate the
+// master key append a new one to the ring, run rotate then remove the old.
+// A secret is bound to its path under -root, the configs directory served
+// by registry_svc, the files and directories must be under it
+package main
+
+import (
+	"flag"
+	"fmt"
+	"gitlab.com/grpasr/asonrythme/registry_svc/internal/secrets"
+	"io"
+	"os"
+)
+
+func main() {
+	if err := run(os.Args[1:], os.Stdout); err != nil {
+		fmt.Fprintln(os.Stderr, "secretsctl:", err)
+		os.Exit(1)
+	}
+}
+
+func run(args []string, out io.Writer) error {
+	fs := flag.NewFlagSet("secretsctl", flag.ContinueOnError)
+	keyFile := fs.String("key-file", os.Getenv("SECRETS_MASTER_KEY_FILE"), "file of the master keys ring")
+	root := fs.String("root", "configs", "configs directory served by registry_svc")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+
+	rest := fs.Args()
+	if len(rest) == 0 {
+		return fmt.Errorf("command missing")
+	}
+
+	// the commands but keygen and scan need the master keys
+	ring := func() (*secrets.KeyRing, error) {
+		return secrets.LoadKeyRing(os.Getenv("SECRETS_MASTER_KEY"), *keyFile)
+	}
+
+	switch rest[0] {
+	case "keygen":
+		return keygen(rest[1:], out)
+	case "import":
+		return importFiles(rest[1:], out, *root, ring)
+	case "rotate":
+		return rotate(rest[1:], out, *root, ring)
+	case "scan":
+		return scan(rest[1:], out)
+	}
+	return fmt.Errorf("unknown command %q", rest[0])
+}
+
+func keygen(args []string, out io.Writer) error {
+	fs := flag.NewFlagSet("keygen", flag.ContinueOnError)
+	id := fs.String("id", "", "id of the master key")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if *id == "" {
+		return fmt.Errorf("keygen: -id is required")
+	}
+
+	entry, err := secrets.NewMasterKey(*id)
+	if err != nil {
+		return err
+	}
+	fmt.Fprintln(out, entry)
+	return nil
+}
+
+func importFiles(args []string, out io.Writer, root string, ring func() (*secrets.KeyRing, error)) error {
+	fs := flag.NewFlagSet("import", flag.ContinueOnError)
+	keep := fs.Bool("keep", false, "keep the cleartext files")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if fs.NArg() == 0 {
+		return fmt.Errorf("import: files missing")
+	}
+
+	kr, err := ring()
+	if err != nil {
+		return err
+	}
+	for _, path := range fs.Args() {
+		sealed, err := secrets.ImportFile(kr, root, path, *keep)
+		if err != nil {
+			return err
+		}
+		fmt.Fprintf(out, "%s sealed with %s\n", sealed, kr.Current())
+	}
+	return nil
+}
+
+func rotate(args []string, out io.Writer, root string, ring func() (*secrets.KeyRing, error)) error {
+	if len(args) == 0 {
+		return fmt.Errorf("rotate: directories missing")
+	}
+
+	kr, err := ring()
+	if err != nil {
+		return err
+	}
+	for _, dir := range args {
+		rotated, err := secrets.RotateDir(kr, root, dir)
+		for _, path := range rotated {
+			fmt.Fprintf(out, "%s sealed with %s\n", path, kr.Current())
+		}
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func scan(args []string, out io.Writer) error {
+	if len(args) == 0 {
+		return fmt.Errorf("scan: directories missing")
+	}
+
+	count := 0
+	for _, dir := range args {
+		found, err := secrets.ScanDir(dir)
+		if err != nil {
+			return err
+		}
+		for _, path := range found {
+			fmt.Fprintln(out, path)
+		}
+		count += len(found)
+	}
+	if count > 0 {
+		return fmt.Errorf("%d cleartext private keys, seal them with import", count)
+	}
+	return nil
+}
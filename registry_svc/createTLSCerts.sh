@@ -55,6 +55,14 @@ setup_certs(){
 
     chmod 666 configs/v1/certificates/server.crt configs/v1/certificates/server.key configs/v1/certificates/rootCA.crt
   }
+
+  { # seal the private keys, registry_svc does not serve them in cleartext.
+    # the master key is SECRETS_MASTER_KEY or SECRETS_MASTER_KEY_FILE
+    go run ./cmd/secretsctl -root configs import \
+      configs/v1/certificates/rootCA.key \
+      configs/v1/certificates/server.key \
+      configs/v1/certificates/client.key
+  }
 }
 setup_certs
 
@@ -4,10 +4,14 @@ go 1.21.0
 
 toolchain go1.21.4
 
+replace gitlab.com/grpasr/asonrythme/shared => ./../shared
+
 require (
+	github.com/bufbuild/protocompile v0.6.0
 	github.com/golang-jwt/jwt v3.2.2+incompatible
 	github.com/gorilla/mux v1.8.1
 	github.com/spf13/viper v1.17.0
+	gitlab.com/grpasr/asonrythme/shared v0.0.0-00010101000000-000000000000
 	gitlab.com/grpasr/common v0.0.0-20240418081513-7e280db72a3f
 	google.golang.org/grpc v1.59.0
 	google.golang.org/protobuf v1.31.0
@@ -31,6 +35,7 @@ require (
 	go.uber.org/multierr v1.9.0 // indirect
 	golang.org/x/exp v0.0.0-20230905200255-921286631fa9 // indirect
 	golang.org/x/net v0.15.0 // indirect
+	golang.org/x/sync v0.3.0 // indirect
 	golang.org/x/sys v0.14.0 // indirect
 	golang.org/x/text v0.13.0 // indirect
 	google.golang.org/genproto/googleapis/rpc v0.0.0-20230920204549-e6e6cdab5c13 // indirect
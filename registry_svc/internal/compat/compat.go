@@ -0,0 +1,346 @@
+package compat
+
+import (
+	"context"
+	"fmt"
+	"github.com/bufbuild/protocompile"
+	"google.golang.org/protobuf/reflect/protoreflect"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// the kinds of change
+const (
+	KindAdded       = "added"
+	KindRemoved     = "removed"
+	KindRenamed     = "renamed"
+	KindRenumbered  = "renumbered"
+	KindTypeChanged = "type_changed"
+)
+
+// Change is a difference between two versions of a package, the elements
+// are named relatively to their proto package(Order.lineItem) so the
+// versions of different majors compare. Breaking is true if a client of
+// the previous version would misread the messages of the new one
+type Change struct {
+	Kind     string `json:"kind"`
+	Element  string `json:"element"`
+	Message  string `json:"message"`
+	Breaking bool   `json:"breaking"`
+}
+
+// Report hold the changes, ordered by element
+type Report struct {
+	Changes []Change `json:"changes"`
+}
+
+// IsBreaking is true if one of the changes is
+func (r *Report) IsBreaking() bool {
+	for _, c := range r.Changes {
+		if c.Breaking {
+			return true
+		}
+	}
+	return false
+}
+
+// Breaking return the breaking changes only
+func (r *Report) Breaking() []Change {
+	changes := []Change{}
+	for _, c := range r.Changes {
+		if c.Breaking {
+			changes = append(changes, c)
+		}
+	}
+	return changes
+}
+
+func (r *Report) add(kind, element string, breaking bool, format string, args ...interface{}) {
+	r.Changes = append(r.Changes, Change{
+		Kind:     kind,
+		Element:  element,
+		Message:  fmt.Sprintf(format, args...),
+		Breaking: breaking,
+	})
+}
+
+// Compile compile the .proto of a package, files are the contents by file
+// name. An import is resolved by its name, then by its base name among the
+// files(api/v1/name/Address.proto is Address.proto), then among the
+// google/protobuf ones
+func Compile(files map[string][]byte) ([]protoreflect.FileDescriptor, error) {
+	names := []string{}
+	for name := range files {
+		if strings.HasSuffix(name, ".proto") {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+
+	compiler := protocompile.Compiler{
+		Resolver: protocompile.WithStandardImports(&protocompile.SourceResolver{
+			Accessor: func(path string) (io.ReadCloser, error) {
+				content, ok := files[path]
+				if !ok {
+					content, ok = files[filepath.Base(path)]
+				}
+				if !ok {
+					return nil, os.ErrNotExist
+				}
+				return io.NopCloser(strings.NewReader(string(content))), nil
+			},
+		}),
+	}
+
+	compiled, err := compiler.Compile(context.Background(), names...)
+	if err != nil {
+		return nil, err
+	}
+
+	fds := make([]protoreflect.FileDescriptor, 0, len(compiled))
+	for _, f := range compiled {
+		fds = append(fds, f)
+	}
+	return fds, nil
+}
+
+// Compare compile both versions of the package and report the changes
+// from prev to next
+func Compare(prev, next map[string][]byte) (*Report, error) {
+	prevFds, err := Compile(prev)
+	if err != nil {
+		return nil, fmt.Errorf("previous version: %v", err)
+	}
+	nextFds, err := Compile(next)
+	if err != nil {
+		return nil, err
+	}
+
+	r := &Report{Changes: []Change{}}
+	pm, nm := collect(prevFds), collect(nextFds)
+
+	compareEnums(r, pm.enums, nm.enums)
+	compareMessages(r, pm.messages, nm.messages)
+	compareServices(r, pm.services, nm.services)
+
+	sort.SliceStable(r.Changes, func(i, j int) bool { return r.Changes[i].Element < r.Changes[j].Element })
+	return r, nil
+}
+
+// elements are the declarations of a package by relative name
+type elements struct {
+	messages map[string]protoreflect.MessageDescriptor
+	enums    map[string]protoreflect.EnumDescriptor
+	services map[string]protoreflect.ServiceDescriptor
+}
+
+func collect(fds []protoreflect.FileDescriptor) elements {
+	el := elements{
+		messages: map[string]protoreflect.MessageDescriptor{},
+		enums:    map[string]protoreflect.EnumDescriptor{},
+		services: map[string]protoreflect.ServiceDescriptor{},
+	}
+
+	var walk func(pkg string, msgs protoreflect.MessageDescriptors, enums protoreflect.EnumDescriptors)
+	walk = func(pkg string, msgs protoreflect.MessageDescriptors, enums protoreflect.EnumDescriptors) {
+		for i := 0; i < enums.Len(); i++ {
+			el.enums[relName(pkg, enums.Get(i).FullName())] = enums.Get(i)
+		}
+		for i := 0; i < msgs.Len(); i++ {
+			m := msgs.Get(i)
+			if m.IsMapEntry() {
+				continue
+			}
+			el.messages[relName(pkg, m.FullName())] = m
+			walk(pkg, m.Messages(), m.Enums())
+		}
+	}
+
+	for _, fd := range fds {
+		pkg := string(fd.Package())
+		walk(pkg, fd.Messages(), fd.Enums())
+		for i := 0; i < fd.Services().Len(); i++ {
+			s := fd.Services().Get(i)
+			el.services[relName(pkg, s.FullName())] = s
+		}
+	}
+
+	return el
+}
+
+// relName strip the package of the name, v1.order.Order is Order
+func relName(pkg string, name protoreflect.FullName) string {
+	if pkg == "" {
+		return string(name)
+	}
+	return strings.TrimPrefix(string(name), pkg+".")
+}
+
+// typeName is the name of the type used by the element, relative if they
+// are in the same package(Order), full otherwise(google.protobuf.StringValue)
+func typeName(element, typ protoreflect.Descriptor) string {
+	pkg := element.ParentFile().Package()
+	if typ.ParentFile().Package() != pkg {
+		return string(typ.FullName())
+	}
+	return relName(string(pkg), typ.FullName())
+}
+
+func compareMessages(r *Report, prev, next map[string]protoreflect.MessageDescriptor) {
+	for name, pm := range prev {
+		nm, ok := next[name]
+		if !ok {
+			r.add(KindRemoved, name, true, "message %s was removed", name)
+			continue
+		}
+		compareFields(r, name, pm, nm)
+	}
+	for name := range next {
+		if _, ok := prev[name]; !ok {
+			r.add(KindAdded, name, false, "message %s was added", name)
+		}
+	}
+}
+
+// compareFields match the fields by name, a field keeping its number under
+// another name is only renamed, the wire does not carry the names
+func compareFields(r *Report, msg string, pm, nm protoreflect.MessageDescriptor) {
+	pFields, nFields := pm.Fields(), nm.Fields()
+
+	for i := 0; i < pFields.Len(); i++ {
+		pf := pFields.Get(i)
+		element := msg + "." + string(pf.Name())
+
+		nf := nFields.ByName(pf.Name())
+		if nf == nil {
+			if byNum := nFields.ByNumber(pf.Number()); byNum != nil {
+				r.add(KindRenamed, element, false, "field %s(%d) was renamed %s", pf.Name(), pf.Number(), byNum.Name())
+				compareFieldTypes(r, element, pf, byNum)
+				continue
+			}
+			if nm.ReservedRanges().Has(pf.Number()) {
+				r.add(KindRemoved, element, false, "field %s(%d) was removed, its number is reserved", pf.Name(), pf.Number())
+				continue
+			}
+			r.add(KindRemoved, element, true, "field %s(%d) was removed without reserving its number", pf.Name(), pf.Number())
+			continue
+		}
+
+		if nf.Number() != pf.Number() {
+			r.add(KindRenumbered, element, true, "field %s was renumbered from %d to %d", pf.Name(), pf.Number(), nf.Number())
+			continue
+		}
+		compareFieldTypes(r, element, pf, nf)
+	}
+
+	for i := 0; i < nFields.Len(); i++ {
+		nf := nFields.Get(i)
+		if pFields.ByName(nf.Name()) == nil && pFields.ByNumber(nf.Number()) == nil {
+			r.add(KindAdded, msg+"."+string(nf.Name()), false, "field %s(%d) was added", nf.Name(), nf.Number())
+		}
+	}
+}
+
+func compareFieldTypes(r *Report, element string, pf, nf protoreflect.FieldDescriptor) {
+	pt, nt := fieldType(pf), fieldType(nf)
+	if pt != nt {
+		r.add(KindTypeChanged, element, true, "field %s changed from %s to %s", pf.Name(), pt, nt)
+	}
+}
+
+// fieldType is the wire type of the field as "repeated Order", the
+// message and enum types are relative to their package
+func fieldType(f protoreflect.FieldDescriptor) string {
+	t := f.Kind().String()
+	switch {
+	case f.IsMap():
+		return fmt.Sprintf("map<%s, %s>", fieldType(f.MapKey()), fieldType(f.MapValue()))
+	case f.Message() != nil:
+		t = typeName(f, f.Message())
+	case f.Enum() != nil:
+		t = typeName(f, f.Enum())
+	}
+	if f.Cardinality() == protoreflect.Repeated {
+		t = "repeated " + t
+	}
+	return t
+}
+
+func compareEnums(r *Report, prev, next map[string]protoreflect.EnumDescriptor) {
+	for name, pe := range prev {
+		ne, ok := next[name]
+		if !ok {
+			r.add(KindRemoved, name, true, "enum %s was removed", name)
+			continue
+		}
+
+		pValues, nValues := pe.Values(), ne.Values()
+		for i := 0; i < pValues.Len(); i++ {
+			pv := pValues.Get(i)
+			element := name + "." + string(pv.Name())
+			nv := nValues.ByName(pv.Name())
+			switch {
+			case nv == nil && ne.ReservedRanges().Has(pv.Number()):
+				r.add(KindRemoved, element, false, "value %s(%d) was removed, its number is reserved", pv.Name(), pv.Number())
+			case nv == nil:
+				r.add(KindRemoved, element, true, "value %s(%d) was removed without reserving its number", pv.Name(), pv.Number())
+			case nv.Number() != pv.Number():
+				r.add(KindRenumbered, element, true, "value %s was renumbered from %d to %d", pv.Name(), pv.Number(), nv.Number())
+			}
+		}
+		for i := 0; i < nValues.Len(); i++ {
+			if nv := nValues.Get(i); pValues.ByName(nv.Name()) == nil {
+				r.add(KindAdded, name+"."+string(nv.Name()), false, "value %s(%d) was added", nv.Name(), nv.Number())
+			}
+		}
+	}
+	for name := range next {
+		if _, ok := prev[name]; !ok {
+			r.add(KindAdded, name, false, "enum %s was added", name)
+		}
+	}
+}
+
+func compareServices(r *Report, prev, next map[string]protoreflect.ServiceDescriptor) {
+	for name, ps := range prev {
+		ns, ok := next[name]
+		if !ok {
+			r.add(KindRemoved, name, true, "service %s was removed", name)
+			continue
+		}
+
+		pMethods, nMethods := ps.Methods(), ns.Methods()
+		for i := 0; i < pMethods.Len(); i++ {
+			pm := pMethods.Get(i)
+			element := name + "." + string(pm.Name())
+			nm := nMethods.ByName(pm.Name())
+			if nm == nil {
+				r.add(KindRemoved, element, true, "rpc %s was removed", pm.Name())
+				continue
+			}
+			if ps, ns := rpcSignature(pm), rpcSignature(nm); ps != ns {
+				r.add(KindTypeChanged, element, true, "rpc %s changed from %s to %s", pm.Name(), ps, ns)
+			}
+		}
+		for i := 0; i < nMethods.Len(); i++ {
+			if nm := nMethods.Get(i); pMethods.ByName(nm.Name()) == nil {
+				r.add(KindAdded, name+"."+string(nm.Name()), false, "rpc %s was added", nm.Name())
+			}
+		}
+	}
+	for name := range next {
+		if _, ok := prev[name]; !ok {
+			r.add(KindAdded, name, false, "service %s was added", name)
+		}
+	}
+}
+
+// rpcSignature is "(stream Order) returns (google.protobuf.StringValue)"
+func rpcSignature(m protoreflect.MethodDescriptor) string {
+	return fmt.Sprintf("(%s) returns (%s)",
+		streamed(typeName(m, m.Input()), m.IsStreamingClient()),
+		streamed(typeName(m, m.Output()), m.IsStreamingServer()))
+}
@@ -0,0 +1,111 @@
+package compat
+
+import (
+	"gitlab.com/grpasr/common/tests"
+	"strings"
+	"testing"
+)
+
+const orderProto = `syntax = "proto3";
+
+import "google/protobuf/wrappers.proto";
+
+package v1_order;
+
+service OrderManagement {
+	rpc GetOrder(google.protobuf.StringValue) returns (Order);
+	rpc CreateOrder(Order) returns (google.protobuf.StringValue);
+}
+
+message Order{
+	string id = 1;
+	repeated LineItem lineItem = 2;
+	string shippingAddress = 3;
+}
+
+message LineItem{
+	string itemCode = 1;
+	float quantity = 2;
+}
+`
+
+func compare(t *testing.T, next string) *Report {
+	r, err := Compare(
+		map[string][]byte{"Order.proto": []byte(orderProto)},
+		map[string][]byte{"Order.proto": []byte(next)})
+	tests.MaybeFail("Compare", err)
+	return r
+}
+
+func changeList(changes []Change) string {
+	els := []string{}
+	for _, c := range changes {
+		els = append(els, c.Kind+":"+c.Element)
+	}
+	return strings.Join(els, ",")
+}
+
+func TestCompareBreaking(t *testing.T) {
+	tests.MaybeFail = tests.InitFailFunc(t)
+
+	for name, tc := range map[string]struct {
+		old, new string
+		expected string
+	}{
+		"renumbered":  {"float quantity = 2;", "float quantity = 3;", "renumbered:LineItem.quantity"},
+		"type":        {"float quantity = 2;", "int32 quantity = 2;", "type_changed:LineItem.quantity"},
+		"cardinality": {"string itemCode = 1;", "repeated string itemCode = 1;", "type_changed:LineItem.itemCode"},
+		"field":       {"string shippingAddress = 3;", "", "removed:Order.shippingAddress"},
+		"rpc":         {"rpc CreateOrder(Order) returns (google.protobuf.StringValue);", "", "removed:OrderManagement.CreateOrder"},
+		"rpc_type": {"rpc GetOrder(google.protobuf.StringValue) returns (Order);",
+			"rpc GetOrder(google.protobuf.StringValue) returns (stream Order);", "type_changed:OrderManagement.GetOrder"},
+	} {
+		r := compare(t, strings.Replace(orderProto, tc.old, tc.new, 1))
+		tests.MaybeFail("Compare_"+name,
+			tests.Expect(r.IsBreaking(), true),
+			tests.Expect(changeList(r.Breaking()), tc.expected))
+	}
+}
+
+func TestCompareCompatible(t *testing.T) {
+	tests.MaybeFail = tests.InitFailFunc(t)
+
+	// a new major keep its messages comparable
+	next := strings.Replace(orderProto, "package v1_order;", "package v2_order;", 1)
+	// the names are not on the wire
+	next = strings.Replace(next, "string shippingAddress = 3;", "string address = 3;", 1)
+	// a removed field is fine once its number is reserved
+	next = strings.Replace(next, "string itemCode = 1;", "reserved 1;\n\tstring sku = 4;", 1)
+	next = strings.Replace(next, "}\n\nmessage LineItem", "\tstring note = 4;\n}\n\nmessage LineItem", 1)
+	next += "\nmessage Refund{\n\tstring id = 1;\n}\n"
+
+	r := compare(t, next)
+	tests.MaybeFail("Compare_compatible",
+		tests.Expect(r.IsBreaking(), false),
+		tests.Expect(changeList(r.Changes),
+			"removed:LineItem.itemCode,added:LineItem.sku,added:Order.note,renamed:Order.shippingAddress,added:Refund"))
+
+	_, err := Compile(map[string][]byte{"Order.proto": []byte("syntax = \"proto3\";\nmessage {")})
+	tests.MaybeFail("Compile_invalid", tests.Expect(err != nil, true))
+}
+
+func TestDescribe(t *testing.T) {
+	tests.MaybeFail = tests.InitFailFunc(t)
+
+	proto := strings.Replace(orderProto, "message LineItem{",
+		"message LineItem{\n\tenum Unit {\n\t\tPIECE = 0;\n\t\tKG = 1;\n\t}\n\tUnit unit = 3;", 1)
+	s, err := Describe(map[string][]byte{"Order.proto": []byte(proto)})
+	tests.MaybeFail("Describe", err,
+		tests.Expect(s.Package, "v1_order"),
+		tests.Expect(len(s.Services), 1),
+		tests.Expect(len(s.Services[0].RPCs), 2),
+		tests.Expect(s.Services[0].RPCs[0].Input, "google.protobuf.StringValue"),
+		tests.Expect(s.Services[0].RPCs[0].Output, "Order"),
+		tests.Expect(len(s.Messages), 2),
+		tests.Expect(s.Messages[0].Fields[1].Type, "repeated LineItem"),
+		tests.Expect(s.Messages[1].Fields[0].Type, "LineItem.Unit"),
+		tests.Expect(len(s.Enums), 1),
+		tests.Expect(s.Enums[0].Name, "LineItem.Unit"),
+		tests.Expect(s.Enums[0].Values[1].Name, "KG"),
+	)
+}
@@ -0,0 +1,122 @@
+package compat
+
+import (
+	"fmt"
+	"google.golang.org/protobuf/reflect/protoreflect"
+)
+
+// Schema is the content of a proto package as declared in its sources,
+// the names are relative to the package as in the reports
+type Schema struct {
+	Package  string    `json:"package"`
+	Services []Service `json:"services"`
+	Messages []Message `json:"messages"`
+	Enums    []Enum    `json:"enums"`
+}
+
+type Service struct {
+	Name string `json:"name"`
+	File string `json:"file"`
+	RPCs []RPC  `json:"rpcs"`
+}
+
+// RPC is a method of a service, Input and Output are "stream Order" for
+// the streamed messages
+type RPC struct {
+	Name   string `json:"name"`
+	Input  string `json:"input"`
+	Output string `json:"output"`
+}
+
+type Message struct {
+	Name   string  `json:"name"`
+	File   string  `json:"file"`
+	Fields []Field `json:"fields"`
+}
+
+type Field struct {
+	Name   string `json:"name"`
+	Number int32  `json:"number"`
+	Type   string `json:"type"`
+}
+
+type Enum struct {
+	Name   string      `json:"name"`
+	File   string      `json:"file"`
+	Values []EnumValue `json:"values"`
+}
+
+type EnumValue struct {
+	Name   string `json:"name"`
+	Number int32  `json:"number"`
+}
+
+// Describe compile the .proto of a package and list its declarations in
+// the order of the files then of the sources, the nested messages follow
+// their parent(Order.Status)
+func Describe(files map[string][]byte) (*Schema, error) {
+	fds, err := Compile(files)
+	if err != nil {
+		return nil, err
+	}
+
+	s := &Schema{Services: []Service{}, Messages: []Message{}, Enums: []Enum{}}
+	for _, fd := range fds {
+		pkg := string(fd.Package())
+		if s.Package == "" {
+			s.Package = pkg
+		} else if s.Package != pkg {
+			return nil, fmt.Errorf("%s declare the package %s, expected %s", fd.Path(), pkg, s.Package)
+		}
+
+		s.describeTypes(fd.Path(), pkg, fd.Messages(), fd.Enums())
+		for i := 0; i < fd.Services().Len(); i++ {
+			sd := fd.Services().Get(i)
+			service := Service{Name: relName(pkg, sd.FullName()), File: fd.Path(), RPCs: []RPC{}}
+			for j := 0; j < sd.Methods().Len(); j++ {
+				m := sd.Methods().Get(j)
+				service.RPCs = append(service.RPCs, RPC{
+					Name:   string(m.Name()),
+					Input:  streamed(typeName(m, m.Input()), m.IsStreamingClient()),
+					Output: streamed(typeName(m, m.Output()), m.IsStreamingServer()),
+				})
+			}
+			s.Services = append(s.Services, service)
+		}
+	}
+
+	return s, nil
+}
+
+func (s *Schema) describeTypes(file, pkg string, msgs protoreflect.MessageDescriptors, enums protoreflect.EnumDescriptors) {
+	for i := 0; i < enums.Len(); i++ {
+		ed := enums.Get(i)
+		enum := Enum{Name: relName(pkg, ed.FullName()), File: file, Values: []EnumValue{}}
+		for j := 0; j < ed.Values().Len(); j++ {
+			v := ed.Values().Get(j)
+			enum.Values = append(enum.Values, EnumValue{Name: string(v.Name()), Number: int32(v.Number())})
+		}
+		s.Enums = append(s.Enums, enum)
+	}
+
+	for i := 0; i < msgs.Len(); i++ {
+		md := msgs.Get(i)
+		if md.IsMapEntry() {
+			continue
+		}
+		msg := Message{Name: relName(pkg, md.FullName()), File: file, Fields: []Field{}}
+		for j := 0; j < md.Fields().Len(); j++ {
+			f := md.Fields().Get(j)
+			msg.Fields = append(msg.Fields, Field{Name: string(f.Name()), Number: int32(f.Number()), Type: fieldType(f)})
+		}
+		s.Messages = append(s.Messages, msg)
+		s.describeTypes(file, pkg, md.Messages(), md.Enums())
+	}
+}
+
+func streamed(t string, stream bool) string {
+	if stream {
+		return "stream " + t
+	}
+	return t
+}
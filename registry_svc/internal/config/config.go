@@ -1,10 +1,16 @@
 package config
 
 import (
+	"crypto/rand"
+	"encoding/base64"
 	"fmt"
 	"github.com/spf13/viper"
+	"time"
 )
 
+// random bytes of the PKCE code_verifier, 43 chars once base64url encoded
+const codeVerifierLength = 32
+
 // SetVarEnv Set the default varEnv and overwrite it if some exist
 func SetVarEnv() {
 	// global
@@ -18,11 +24,36 @@ func SetVarEnv() {
 	viper.SetDefault("GRPC_DIRECTORY", "api")
 	viper.SetDefault("CONFIGS_DIRECTORY", "configs")
 
+	// publish, the scope the jwtoken must have to upload schemas and the
+	// max size of an upload in bytes
+	viper.SetDefault("PUBLISH_SCOPE", "publish")
+	viper.SetDefault("PUBLISH_MAX_SIZE", 8<<20)
+
+	// discovery, the scope the jwtoken must have to register a service and
+	// the lease of a registration in seconds, renewed by the heartbeats
+	viper.SetDefault("DISCOVERY_SCOPE", "discovery")
+	viper.SetDefault("DISCOVERY_TTL", 30)
+	viper.SetDefault("DISCOVERY_MAX_TTL", 300)
+
+	// config store, the scope the jwtoken must have to change the configs,
+	// the file of the store under PATH_STORAGE and the revisions kept
+	viper.SetDefault("CONFIG_STORE_SCOPE", "config")
+	viper.SetDefault("CONFIG_STORE_FILE", "kvstore.json")
+	viper.SetDefault("CONFIG_STORE_HISTORY", 100)
+
+	// access policy, the file under PATH_STORAGE telling which packages and
+	// configs directories each service may download
+	viper.SetDefault("ACCESS_POLICY_FILE", "accessPolicy.yaml")
+
+	// secrets, the ring of master keys wrapping the data keys of the sealed
+	// private keys("<id>:<base64 key>" by line), the env wins over the file
+	viper.SetDefault("SECRETS_MASTER_KEY", "")
+	viper.SetDefault("SECRETS_MASTER_KEY_FILE", "")
+
 	// JWTRequestConfig
 	viper.SetDefault("AUTH_SVC_URL", "http://localhost:9096/v1")
 	viper.SetDefault("AUTH_SVC_PATH", "apiauth")
 	viper.SetDefault("AUTH_SVC_TOKEN_ENDPOINT", "http://localhost:9096/v1/oauth/token")
-	viper.SetDefault("CODE_VERIFIER", "exampleCodeVerifier")
 	viper.SetDefault("SERVICE_KEY_ID", "registrySvc")
 	viper.SetDefault("SERVICE_SECRET_KEY", "registrySvcSecret")
 	viper.SetDefault("SCOPE", "read, openid")
@@ -61,7 +92,6 @@ type JWTRequestConfig struct {
 	authSvcURL           string
 	authSvcPath          string
 	authSvcTokenEndpoint string
-	codeVerifier         string
 	serviceKeyID         string
 	serviceSecretkey     string
 	scope                string
@@ -72,7 +102,6 @@ func NewJWTRequestConfig() *JWTRequestConfig {
 	jrc.authSvcURL = viper.GetString("AUTH_SVC_URL")
 	jrc.authSvcPath = viper.GetString("AUTH_SVC_PATH")
 	jrc.authSvcTokenEndpoint = viper.GetString("AUTH_SVC_TOKEN_ENDPOINT")
-	jrc.codeVerifier = viper.GetString("CODE_VERIFIER")
 	jrc.serviceKeyID = viper.GetString("SERVICE_KEY_ID")
 	jrc.serviceSecretkey = viper.GetString("SERVICE_SECRET_KEY")
 	jrc.scope = viper.GetString("SCOPE")
@@ -92,8 +121,14 @@ func (r *JWTRequestConfig) JwtGetAuthSvcTokenEndpoint() string {
 	return r.authSvcTokenEndpoint
 }
 
-func (r *JWTRequestConfig) JwtGetCodeVerifier() string {
-	return r.codeVerifier
+// JwtNewCodeVerifier generate a fresh PKCE code_verifier(rfc7636),
+// a new one must be used for each login
+func (r *JWTRequestConfig) JwtNewCodeVerifier() (string, error) {
+	b := make([]byte, codeVerifierLength)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(b), nil
 }
 
 func (r *JWTRequestConfig) JwtGetServiceKeyID() string {
@@ -167,6 +202,17 @@ type IRestConfig interface {
 	RESTGetGrpcDirectory() string
 	RESTSetConfigsDirectory(confDir string)
 	RESTGetConfigsDirectory() string
+	RESTGetPublishScope() string
+	RESTGetPublishMaxSize() int64
+	RESTGetDiscoveryScope() string
+	RESTGetDiscoveryTTL() time.Duration
+	RESTGetDiscoveryMaxTTL() time.Duration
+	RESTGetConfigStoreScope() string
+	RESTGetConfigStoreFile() string
+	RESTGetConfigStoreHistory() int
+	RESTGetAccessPolicyFile() string
+	RESTGetSecretsMasterKey() string
+	RESTGetSecretsMasterKeyFile() string
 }
 
 // Rest hold the Rest configurations
@@ -174,6 +220,17 @@ type RestConfig struct {
 	pathToStorage    string
 	grpcDirectory    string
 	configsDirectory string
+	publishScope     string
+	publishMaxSize   int64
+	discoveryScope   string
+	discoveryTTL     time.Duration
+	discoveryMaxTTL  time.Duration
+	storeScope       string
+	storeFile        string
+	storeHistory     int
+	accessPolicyFile string
+	masterKey        string
+	masterKeyFile    string
 }
 
 func NewRestConfig() *RestConfig {
@@ -181,6 +238,17 @@ func NewRestConfig() *RestConfig {
 	rc.pathToStorage = viper.GetString("PATH_STORAGE")
 	rc.grpcDirectory = viper.GetString("GRPC_DIRECTORY")
 	rc.configsDirectory = viper.GetString("CONFIGS_DIRECTORY")
+	rc.publishScope = viper.GetString("PUBLISH_SCOPE")
+	rc.publishMaxSize = viper.GetInt64("PUBLISH_MAX_SIZE")
+	rc.discoveryScope = viper.GetString("DISCOVERY_SCOPE")
+	rc.discoveryTTL = time.Duration(viper.GetInt("DISCOVERY_TTL")) * time.Second
+	rc.discoveryMaxTTL = time.Duration(viper.GetInt("DISCOVERY_MAX_TTL")) * time.Second
+	rc.storeScope = viper.GetString("CONFIG_STORE_SCOPE")
+	rc.storeFile = viper.GetString("CONFIG_STORE_FILE")
+	rc.storeHistory = viper.GetInt("CONFIG_STORE_HISTORY")
+	rc.accessPolicyFile = viper.GetString("ACCESS_POLICY_FILE")
+	rc.masterKey = viper.GetString("SECRETS_MASTER_KEY")
+	rc.masterKeyFile = viper.GetString("SECRETS_MASTER_KEY_FILE")
 
 	return rc
 }
@@ -213,3 +281,47 @@ func (r *RestConfig) RESTSetConfigsDirectory(confDir string) {
 func (r *RestConfig) RESTGetConfigsDirectory() string {
 	return r.configsDirectory
 }
+
+func (r *RestConfig) RESTGetPublishScope() string {
+	return r.publishScope
+}
+
+func (r *RestConfig) RESTGetPublishMaxSize() int64 {
+	return r.publishMaxSize
+}
+
+func (r *RestConfig) RESTGetDiscoveryScope() string {
+	return r.discoveryScope
+}
+
+func (r *RestConfig) RESTGetDiscoveryTTL() time.Duration {
+	return r.discoveryTTL
+}
+
+func (r *RestConfig) RESTGetDiscoveryMaxTTL() time.Duration {
+	return r.discoveryMaxTTL
+}
+
+func (r *RestConfig) RESTGetConfigStoreScope() string {
+	return r.storeScope
+}
+
+func (r *RestConfig) RESTGetConfigStoreFile() string {
+	return r.storeFile
+}
+
+func (r *RestConfig) RESTGetConfigStoreHistory() int {
+	return r.storeHistory
+}
+
+func (r *RestConfig) RESTGetAccessPolicyFile() string {
+	return r.accessPolicyFile
+}
+
+func (r *RestConfig) RESTGetSecretsMasterKey() string {
+	return r.masterKey
+}
+
+func (r *RestConfig) RESTGetSecretsMasterKeyFile() string {
+	return r.masterKeyFile
+}
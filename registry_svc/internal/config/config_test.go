@@ -5,6 +5,7 @@ import (
 	"gitlab.com/grpasr/common/tests"
 	"os"
 	"testing"
+	"time"
 )
 
 func Test_appConfig_get_default_configs(t *testing.T) {
@@ -24,6 +25,16 @@ func Test_appConfig_get_default_configs(t *testing.T) {
 		tests.Expect(rc.pathToStorage, ".."),
 		tests.Expect(rc.grpcDirectory, "api"),
 		tests.Expect(rc.configsDirectory, "configs"),
+		tests.Expect(rc.publishScope, "publish"),
+		tests.Expect(rc.publishMaxSize, int64(8<<20)),
+		tests.Expect(rc.discoveryScope, "discovery"),
+		tests.Expect(rc.discoveryTTL, 30*time.Second),
+		tests.Expect(rc.discoveryMaxTTL, 5*time.Minute),
+		tests.Expect(rc.storeScope, "config"),
+		tests.Expect(rc.storeFile, "kvstore.json"),
+		tests.Expect(rc.storeHistory, 100),
+		tests.Expect(rc.accessPolicyFile, "accessPolicy.yaml"),
+		tests.Expect(rc.masterKeyFile, ""),
 	)
 }
 
@@ -0,0 +1,174 @@
+package discovery
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"regexp"
+	"sort"
+	"sync"
+	"time"
+)
+
+var (
+	ErrNotFound  = errors.New("unknown or expired registration")
+	ErrInvalid   = errors.New("invalid registration")
+	ErrForbidden = errors.New("the registration belong to another service")
+)
+
+// the names of the services, as in the servicesAddr files(order, auth)
+var nameRegexp = regexp.MustCompile(`^[a-zA-Z][a-zA-Z0-9_-]*$`)
+
+// Instance is a registered instance of a service, it is resolved until
+// ExpiresAt unless a heartbeat renew its lease
+type Instance struct {
+	ID        string            `json:"id"`
+	Name      string            `json:"name"`
+	Env       string            `json:"env"`
+	Address   string            `json:"address"`
+	Port      string            `json:"port"`
+	Version   string            `json:"version,omitempty"`
+	Metadata  map[string]string `json:"metadata,omitempty"`
+	TTL       int               `json:"ttl"` // seconds
+	ExpiresAt time.Time         `json:"expiresAt"`
+
+	// Owner is the service that registered the instance, the only one
+	// allowed to renew or remove it
+	Owner string `json:"-"`
+}
+
+func (i Instance) key() string {
+	return fmt.Sprintf("%s/%s/%s:%s", i.Env, i.Name, i.Address, i.Port)
+}
+
+// Registry hold the instances in memory, the services register again
+// once it restart as they do when their lease expire. It is safe for
+// concurrent use
+type Registry struct {
+	mu        sync.Mutex
+	instances map[string]*Instance // by ID
+	now       func() time.Time
+}
+
+func NewRegistry() *Registry {
+	return &Registry{
+		instances: map[string]*Instance{},
+		now:       time.Now,
+	}
+}
+
+// Register add the instance with a lease of ttl. An instance registered
+// again at the same address keep its ID, its lease is renewed
+func (r *Registry) Register(inst Instance, ttl time.Duration) (Instance, error) {
+	if !nameRegexp.MatchString(inst.Name) {
+		return Instance{}, fmt.Errorf("%w: name %q", ErrInvalid, inst.Name)
+	}
+	if inst.Address == "" || inst.Port == "" {
+		return Instance{}, fmt.Errorf("%w: the address and the port are required", ErrInvalid)
+	}
+	if ttl <= 0 {
+		return Instance{}, fmt.Errorf("%w: ttl %v", ErrInvalid, ttl)
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.prune()
+
+	for _, existing := range r.instances {
+		if existing.key() == inst.key() {
+			if existing.Owner != inst.Owner {
+				return Instance{}, ErrForbidden
+			}
+			inst.ID = existing.ID
+			break
+		}
+	}
+	if inst.ID == "" {
+		id, err := newID()
+		if err != nil {
+			return Instance{}, err
+		}
+		inst.ID = id
+	}
+
+	inst.TTL = int(ttl / time.Second)
+	inst.ExpiresAt = r.now().Add(ttl)
+	r.instances[inst.ID] = &inst
+
+	return inst, nil
+}
+
+// Heartbeat renew the lease of the instance of owner for its ttl
+func (r *Registry) Heartbeat(id, owner string) (Instance, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.prune()
+
+	inst, ok := r.instances[id]
+	if !ok {
+		return Instance{}, ErrNotFound
+	}
+	if inst.Owner != owner {
+		return Instance{}, ErrForbidden
+	}
+	inst.ExpiresAt = r.now().Add(time.Duration(inst.TTL) * time.Second)
+
+	return *inst, nil
+}
+
+// Deregister remove the instance of owner, on a graceful shutdown
+func (r *Registry) Deregister(id, owner string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.prune()
+
+	inst, ok := r.instances[id]
+	if !ok {
+		return ErrNotFound
+	}
+	if inst.Owner != owner {
+		return ErrForbidden
+	}
+	delete(r.instances, id)
+
+	return nil
+}
+
+// Resolve return the live instances of the service in env, version
+// filter them if set. An empty env or name match all
+func (r *Registry) Resolve(name, env, version string) []Instance {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.prune()
+
+	instances := []Instance{}
+	for _, inst := range r.instances {
+		if (name == "" || inst.Name == name) &&
+			(env == "" || inst.Env == env) &&
+			(version == "" || inst.Version == version) {
+			instances = append(instances, *inst)
+		}
+	}
+	sort.Slice(instances, func(i, j int) bool { return instances[i].key() < instances[j].key() })
+
+	return instances
+}
+
+// prune remove the expired instances, the lock must be held
+func (r *Registry) prune() {
+	now := r.now()
+	for id, inst := range r.instances {
+		if !now.Before(inst.ExpiresAt) {
+			delete(r.instances, id)
+		}
+	}
+}
+
+func newID() (string, error) {
+	b := make([]byte, 8)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(b), nil
+}
@@ -0,0 +1,84 @@
+package discovery
+
+import (
+	"errors"
+	"gitlab.com/grpasr/common/tests"
+	"testing"
+	"time"
+)
+
+func newTestRegistry() (*Registry, *time.Time) {
+	now := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	r := NewRegistry()
+	r.now = func() time.Time { return now }
+	return r, &now
+}
+
+func TestRegistryLease(t *testing.T) {
+	tests.MaybeFail = tests.InitFailFunc(t)
+	r, now := newTestRegistry()
+
+	order := Instance{Name: "order", Env: "localhost", Address: "localhost", Port: "50001", Version: "v1.0.0", Owner: "order"}
+	inst, err := r.Register(order, 30*time.Second)
+	tests.MaybeFail("Register", err,
+		tests.Expect(inst.ID != "", true),
+		tests.Expect(inst.TTL, 30),
+		tests.Expect(len(r.Resolve("order", "localhost", "")), 1),
+		tests.Expect(len(r.Resolve("order", "docker", "")), 0),
+		tests.Expect(len(r.Resolve("order", "", "v2.0.0")), 0),
+	)
+
+	// registered again after a restart, the same instance
+	again, err := r.Register(order, 30*time.Second)
+	tests.MaybeFail("Register_again", err,
+		tests.Expect(again.ID, inst.ID),
+		tests.Expect(len(r.Resolve("", "", "")), 1),
+	)
+
+	*now = now.Add(20 * time.Second)
+	_, err = r.Heartbeat(inst.ID, "order")
+	*now = now.Add(20 * time.Second)
+	tests.MaybeFail("Heartbeat", err, tests.Expect(len(r.Resolve("order", "", "")), 1))
+
+	// the lease expire without heartbeat
+	*now = now.Add(15 * time.Second)
+	_, err = r.Heartbeat(inst.ID, "order")
+	tests.MaybeFail("Expired",
+		tests.Expect(errors.Is(err, ErrNotFound), true),
+		tests.Expect(len(r.Resolve("order", "", "")), 0),
+	)
+}
+
+func TestRegistryDeregister(t *testing.T) {
+	tests.MaybeFail = tests.InitFailFunc(t)
+	r, _ := newTestRegistry()
+
+	a, _ := r.Register(Instance{Name: "order", Address: "10.0.0.1", Port: "50001", Owner: "order"}, time.Minute)
+	r.Register(Instance{Name: "order", Address: "10.0.0.2", Port: "50001", Owner: "order"}, time.Minute)
+
+	// only the service that registered the instance can remove it
+	tests.MaybeFail("Deregister_other",
+		tests.Expect(errors.Is(r.Deregister(a.ID, "brokerSvc"), ErrForbidden), true),
+	)
+	_, err := r.Heartbeat(a.ID, "brokerSvc")
+	tests.MaybeFail("Heartbeat_other", tests.Expect(errors.Is(err, ErrForbidden), true))
+	_, err = r.Register(Instance{Name: "order", Address: "10.0.0.1", Port: "50001", Owner: "brokerSvc"}, time.Minute)
+	tests.MaybeFail("Register_other", tests.Expect(errors.Is(err, ErrForbidden), true))
+
+	err = r.Deregister(a.ID, "order")
+	instances := r.Resolve("order", "", "")
+	tests.MaybeFail("Deregister", err,
+		tests.Expect(len(instances), 1),
+		tests.Expect(instances[0].Address, "10.0.0.2"),
+		tests.Expect(errors.Is(r.Deregister(a.ID, "order"), ErrNotFound), true),
+	)
+
+	for name, inst := range map[string]Instance{
+		"name":    {Name: "or der", Address: "localhost", Port: "1"},
+		"address": {Name: "order", Port: "1"},
+		"port":    {Name: "order", Address: "localhost"},
+	} {
+		_, err := r.Register(inst, time.Minute)
+		tests.MaybeFail("Register_invalid_"+name, tests.Expect(errors.Is(err, ErrInvalid), true))
+	}
+}
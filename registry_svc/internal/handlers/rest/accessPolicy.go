@@ -0,0 +1,146 @@
+package rest
+
+import (
+	"fmt"
+	"github.com/spf13/viper"
+	e "gitlab.com/grpasr/common/errors/json"
+	"net/http"
+	"os"
+	"sync"
+	"time"
+)
+
+// the kinds of resources of the access policy
+const (
+	accessGrpc    = "grpc"
+	accessConfigs = "configs"
+	accessKV      = "kv"
+
+	// accessAny allow every package or configs directory, as the id of a
+	// service it applies to all the services
+	accessAny = "*"
+)
+
+// the access policy of the downloads, nil until the handlers are set
+var accessPolicy *policy
+
+// accessRule is the packages and configs directories a service may
+// download and the services whose configuration it may resolve from the
+// config store, the services are keyed by a label in the file as:
+//
+//	services:
+//	  broker_svc:
+//	    id: "brokerSvc"
+//	    grpc: ["order", "brokerjwt"]
+//	    configs: ["certificates"]
+//	    kv: ["brokerSvc"]
+type accessRule struct {
+	ID      string   `mapstructure:"id"`
+	Grpc    []string `mapstructure:"grpc"`
+	Configs []string `mapstructure:"configs"`
+	KV      []string `mapstructure:"kv"`
+}
+
+// policy is the access policy read from its file, the file is read again
+// when it changes. Until a valid file is read every download is denied
+type policy struct {
+	mu      sync.Mutex
+	path    string
+	modTime time.Time
+	missing bool
+	rules   map[string]accessRule // by service id
+}
+
+func newPolicy(path string) *policy {
+	p := &policy{path: path}
+	p.reload()
+	return p
+}
+
+// reload read the file if it changed since the last read, the rules
+// stay the last valid ones if it is removed or invalid
+func (p *policy) reload() {
+	fi, err := os.Stat(p.path)
+	if err != nil {
+		if !p.missing {
+			fmt.Printf("registry_svc, accessPolicy.go, the access policy is unavailable: %v\n", err)
+		}
+		p.missing = true
+		p.modTime = time.Time{}
+		return
+	}
+	p.missing = false
+	if fi.ModTime().Equal(p.modTime) {
+		return
+	}
+	p.modTime = fi.ModTime()
+
+	v := viper.New()
+	v.SetConfigFile(p.path)
+	services := map[string]accessRule{}
+	err = v.ReadInConfig()
+	if err == nil {
+		err = v.UnmarshalKey("services", &services)
+	}
+	if err != nil {
+		fmt.Printf("registry_svc, accessPolicy.go, the access policy %s is invalid: %v\n", p.path, err)
+		return
+	}
+
+	rules := map[string]accessRule{}
+	for name, rule := range services {
+		if rule.ID == "" {
+			fmt.Printf("registry_svc, accessPolicy.go, the service %s of the access policy has no id\n", name)
+			continue
+		}
+		rules[rule.ID] = rule
+	}
+	p.rules = rules
+	fmt.Printf("registry_svc, accessPolicy.go, access policy loaded for %d services\n", len(rules))
+}
+
+// allow is true if the service may download the package or the configs
+// directory, or resolve the configuration of the service name for kv,
+// name is accessAny for all of them
+func (p *policy) allow(svc, kind, name string) bool {
+	if p == nil || svc == "" {
+		return false
+	}
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.reload()
+
+	for _, id := range []string{svc, accessAny} {
+		rule, ok := p.rules[id]
+		if !ok {
+			continue
+		}
+		allowed := rule.Grpc
+		switch kind {
+		case accessConfigs:
+			allowed = rule.Configs
+		case accessKV:
+			allowed = rule.KV
+		}
+		for _, a := range allowed {
+			if a == accessAny || a == name {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// authorize answer 403 and log the attempt if the service of the jwtoken
+// may not access the resource
+func authorize(w http.ResponseWriter, r *http.Request, kind, name string) bool {
+	svc := tokenSubject(r)
+	if !accessPolicy.allow(svc, kind, name) {
+		fmt.Printf("registry_svc, accessPolicy.go, %s denied to %q on %s\n", kind, svc, r.URL.Path)
+		writeJSONError(w, e.NewCustomHTTPStatus(e.StatusForbidden, r.URL.Path,
+			fmt.Sprintf("the service is not allowed to access %s %s", kind, name)))
+		return false
+	}
+	return true
+}
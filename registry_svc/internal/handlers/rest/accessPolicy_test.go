@@ -0,0 +1,104 @@
+package rest
+
+import (
+	"encoding/json"
+	"gitlab.com/grpasr/common/tests"
+	"net/http"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func svcQuery(svc, url string, resp interface{}) (int, error) {
+	req, _ := http.NewRequest(http.MethodGet, url, nil)
+	req.Header.Set("Authorization", "Bearer "+testTokenFor(svc, "read"))
+	r, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return 0, err
+	}
+	defer r.Body.Close()
+	if resp != nil {
+		json.NewDecoder(r.Body).Decode(resp)
+	}
+	return r.StatusCode, nil
+}
+
+func Test_access_policy_downloads(t *testing.T) {
+	tests.MaybeFail = tests.InitFailFunc(t)
+
+	for _, tt := range []struct {
+		svc, url string
+		code     int
+	}{
+		{"limitedSvc", "http://localhost:4000/grpc/v1/order", http.StatusOK},
+		{"limitedSvc", "http://localhost:4000/grpc/v1/order/order", http.StatusOK},
+		{"limitedSvc", "http://localhost:4000/grpc/v1/name", http.StatusForbidden},
+		{"limitedSvc", "http://localhost:4000/grpc/name/versions", http.StatusForbidden},
+		{"limitedSvc", "http://localhost:4000/configs/v1/certificates", http.StatusOK},
+		{"limitedSvc", "http://localhost:4000/configs/v1/xxx", http.StatusForbidden},
+		{"limitedSvc", "http://localhost:4000/configs/v1/all", http.StatusForbidden},
+		{"limitedSvc", "http://localhost:4000/catalog/grpc/name/latest", http.StatusForbidden},
+		// not in the policy
+		{"unknownSvc", "http://localhost:4000/grpc/v1/order", http.StatusForbidden},
+		{"unknownSvc", "http://localhost:4000/configs/v1/certificates", http.StatusForbidden},
+	} {
+		statusCode, err := svcQuery(tt.svc, tt.url, nil)
+		tests.MaybeFail(tt.svc+"_"+tt.url, err, tests.Expect(statusCode, tt.code))
+	}
+}
+
+func Test_access_policy_catalog(t *testing.T) {
+	tests.MaybeFail = tests.InitFailFunc(t)
+
+	packages := GrpcCatalogResponse{}
+	statusCode, err := svcQuery("limitedSvc", "http://localhost:4000/catalog/grpc", &packages)
+	tests.MaybeFail("catalog_grpc", err,
+		tests.Expect(statusCode, http.StatusOK),
+		tests.Expect(len(packages.Packages), 1),
+		tests.Expect(packages.Packages[0].Name, "order"),
+	)
+
+	bundles := ConfigsCatalogResponse{}
+	statusCode, err = svcQuery("limitedSvc", "http://localhost:4000/catalog/configs", &bundles)
+	tests.MaybeFail("catalog_configs", err,
+		tests.Expect(statusCode, http.StatusOK),
+		tests.Expect(len(bundles.Bundles), 1),
+		tests.Expect(bundles.Bundles[0].Name, "certificates"),
+	)
+}
+
+func Test_access_policy_reload(t *testing.T) {
+	tests.MaybeFail = tests.InitFailFunc(t)
+
+	path := filepath.Join(t.TempDir(), "accessPolicy.yaml")
+	write := func(content string, modTime time.Time) {
+		os.WriteFile(path, []byte(content), 0644)
+		os.Chtimes(path, modTime, modTime)
+	}
+
+	// denied until the file exists
+	p := newPolicy(path)
+	tests.MaybeFail("missing", nil, tests.Expect(p.allow("order", accessGrpc, "order"), false))
+
+	now := time.Now()
+	write("services:\n  order:\n    id: \"order\"\n    grpc: [\"order\"]\n  all:\n    id: \"*\"\n    configs: [\"kafka\"]\n", now)
+	tests.MaybeFail("loaded", nil,
+		tests.Expect(p.allow("order", accessGrpc, "order"), true),
+		tests.Expect(p.allow("order", accessGrpc, "name"), false),
+		tests.Expect(p.allow("order", accessConfigs, "kafka"), true),
+		tests.Expect(p.allow("brokerSvc", accessConfigs, "kafka"), true),
+		tests.Expect(p.allow("brokerSvc", accessConfigs, accessAny), false),
+		tests.Expect(p.allow("", accessConfigs, "kafka"), false),
+	)
+
+	// an invalid file keep the last rules
+	write("services: [", now.Add(time.Second))
+	tests.MaybeFail("invalid", nil, tests.Expect(p.allow("order", accessGrpc, "order"), true))
+
+	write("services:\n  order:\n    id: \"order\"\n    grpc: [\"name\"]\n", now.Add(2*time.Second))
+	tests.MaybeFail("reloaded", nil,
+		tests.Expect(p.allow("order", accessGrpc, "order"), false),
+		tests.Expect(p.allow("order", accessGrpc, "name"), true),
+	)
+}
@@ -1,15 +1,25 @@
 package rest
 
 import (
+	"encoding/json"
 	"fmt"
+	"gitlab.com/grpasr/asonrythme/registry_svc/internal/secrets"
 	e "gitlab.com/grpasr/common/errors/json"
-	"io"
 	"mime/multipart"
 	"net/http"
+	"net/textproto"
 	"os"
 	"path/filepath"
+	"strings"
 )
 
+// quoteEscaper escape the file names as multipart.CreateFormFile does
+var quoteEscaper = strings.NewReplacer("\\", "\\\\", `"`, "\\\"")
+
+func escapeQuotes(s string) string {
+	return quoteEscaper.Replace(s)
+}
+
 type IAgents interface {
 	handlePathSegment() e.IError
 	setPath() e.IError
@@ -48,7 +58,7 @@ func NewAgentsHandler(w http.ResponseWriter, files []string, storageDir, vers, p
 	}, nil
 }
 
-func (ah *agentsHandler) run() e.IError {
+func (ah *agentsHandler) run(r *http.Request) e.IError {
 	err := ah.handlePathSegment()
 	if err != nil {
 		ah.w.Header().Set("Content-Type", "text/plain")
@@ -61,9 +71,51 @@ func (ah *agentsHandler) run() e.IError {
 		return err
 	}
 
-	err = ah.multipartHandler()
+	// the manifest does not depend on the format
+	_, isManifest := r.URL.Query()["manifest"]
+	format := formatMultipart
+	if !isManifest {
+		format, err = negotiateFormat(r)
+		if err != nil {
+			ah.w.Header().Set("Content-Type", "text/plain")
+			return err
+		}
+	}
+
+	files, err := ah.selectFiles()
+	if err != nil {
+		ah.w.Header().Set("Content-Type", "text/plain")
+		return err
+	}
+
+	m, err := newManifest(files)
+	if err != nil {
+		ah.w.Header().Set("Content-Type", "text/plain")
+		return err
+	}
+
+	// the loaders keep their files if the content did not change
+	ah.w.Header().Set("ETag", m.etag(format))
+	ah.w.Header().Set("Vary", "Accept")
+	if etagMatch(r.Header.Get("If-None-Match"), m.etag(format)) {
+		ah.w.WriteHeader(http.StatusNotModified)
+		return nil
+	}
+
+	if isManifest {
+		ah.w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(ah.w).Encode(m)
+		return nil
+	}
+
+	switch format {
+	case formatTarGz, formatZip:
+		err = ah.archiveHandler(files, format)
+	default:
+		err = ah.multipartHandler(files)
+	}
 	if err != nil {
-		// the header is set to multipart/form-data, can not change it
+		// the header is set to the format, can not change it
 		return err
 	}
 
@@ -85,49 +137,35 @@ func (ah *agentsHandler) setPath() e.IError {
 	return ah.IAgents.setPath()
 }
 
-func (ah *agentsHandler) multipartHandler() e.IError {
-	// Create a multipart writer for the response
-	multipartWriter := multipart.NewWriter(ah.w)
-	ah.w.Header().Set("Content-Type", multipartWriter.FormDataContentType())
-	ah.multipartWriter = multipartWriter
+// agentFile is a file to send, name is the one of the multipart part
+// and rel its path in the archives
+type agentFile struct {
+	name string
+	rel  string
+	path string
+	sum  []byte
 
-	if ah.IAgents.getIsPathAndFilesDefined() {
-		// get files from a defined directory
-		err := ah.multipartSetter()
-		if err != nil {
-			return e.NewCustomHTTPStatus(e.StatusInternalServerError, "", err.Error())
-		}
-	} else {
+	// the file is a sealed secret, its name and rel are the secret's
+	sealed bool
+}
+
+// selectFiles list the files to send, the ones of the agent in its
+// directory if they are defined, all the files of the directory and its
+// sub-directories otherwise
+func (ah *agentsHandler) selectFiles() ([]agentFile, e.IError) {
+	if !ah.IAgents.getIsPathAndFilesDefined() {
 		// get all files from the dir and sub-dir
 		files, err := ah.getAllFilesInDirectory()
 		if err != nil {
-			return e.NewCustomHTTPStatus(e.StatusInternalServerError, "", err.Error())
+			return nil, e.NewCustomHTTPStatus(e.StatusInternalServerError, "", err.Error())
 		}
-		// fmt.Println("see frils from dir: ", files)
 		ah.IAgents.setFiles(files)
 
-		// will parse the files, pathToFiles will be include in the fileName
+		// pathToFiles is included in the fileName
 		ah.isPathToFilesValid = false
-		err = ah.multipartSetter()
-		if err != nil {
-			return e.NewCustomHTTPStatus(e.StatusInternalServerError, "", err.Error())
-		}
-	}
-
-	// reset the files to empty []string and isPathToFileVaid to its default for next req
-	// ah.IAgents.setFiles([]string{})
-	// ah.isPathToFilesValid = true
-
-	// close the connection
-	err := ah.multipartWriter.Close()
-	if err != nil {
-		return e.NewCustomHTTPStatus(e.StatusInternalServerError, "", err.Error())
-	} else {
-		return nil
 	}
-}
 
-func (ah *agentsHandler) multipartSetter() error {
+	files := []agentFile{}
 	for _, filename := range ah.IAgents.getFiles() {
 
 		// some agents may filter on specific files
@@ -135,32 +173,73 @@ func (ah *agentsHandler) multipartSetter() error {
 			continue
 		}
 
-		var filePath = ""
 		if ah.isPathToFilesValid {
-			filePath = filepath.Join(ah.currentDir, ah.IAgents.getPathToFiles(), filename)
+			// the private keys are stored sealed
+			path := filepath.Join(ah.currentDir, ah.IAgents.getPathToFiles(), filename)
+			if _, err := os.Stat(path); os.IsNotExist(err) {
+				if _, err := os.Stat(path + secrets.Ext); err == nil {
+					path += secrets.Ext
+				}
+			}
+			files = append(files, newAgentFile(filename, filename, path))
 		} else {
-			// define the filePath
-			filePath = filepath.Join(ah.currentDir, filename)
-
-			// Extract the file name
-			filename = filepath.Base(filename)
+			// the directory layout is kept in the archives
+			rel, err := filepath.Rel(ah.IAgents.getPathToFiles(), filename)
+			if err != nil {
+				rel = filepath.Base(filename)
+			}
+			files = append(files, newAgentFile(
+				filepath.Base(filename),
+				filepath.ToSlash(rel),
+				filepath.Join(ah.currentDir, filename)))
 		}
+	}
+
+	return files, nil
+}
+
+func (ah *agentsHandler) multipartHandler(files []agentFile) e.IError {
+	// Create a multipart writer for the response
+	multipartWriter := multipart.NewWriter(ah.w)
+	ah.w.Header().Set("Content-Type", multipartWriter.FormDataContentType())
+	ah.multipartWriter = multipartWriter
+
+	err := ah.multipartSetter(files)
+	if err != nil {
+		return e.NewCustomHTTPStatus(e.StatusInternalServerError, "", err.Error())
+	}
+
+	// close the connection
+	err = ah.multipartWriter.Close()
+	if err != nil {
+		return e.NewCustomHTTPStatus(e.StatusInternalServerError, "", err.Error())
+	} else {
+		return nil
+	}
+}
+
+func (ah *agentsHandler) multipartSetter(files []agentFile) error {
+	for _, f := range files {
 
-		// Open the file
-		file, err := os.Open(filePath)
+		// the sealed secrets are decrypted
+		content, err := readAgentFile(f)
 		if err != nil {
 			return err
 		}
-		defer file.Close()
 
-		// Create a new form file part
-		part, err := ah.multipartWriter.CreateFormFile("files", filename)
+		// Create a new form file part, its Digest let the client check it
+		h := make(textproto.MIMEHeader)
+		h.Set("Content-Disposition",
+			fmt.Sprintf(`form-data; name="files"; filename="%s"`, escapeQuotes(f.name)))
+		h.Set("Content-Type", "application/octet-stream")
+		h.Set("Digest", digestHeader(f.sum))
+		part, err := ah.multipartWriter.CreatePart(h)
 		if err != nil {
 			return err
 		}
 
 		// Copy the file content to the part
-		_, err = io.Copy(part, file)
+		_, err = part.Write(content)
 		if err != nil {
 			return err
 		}
@@ -192,7 +271,7 @@ func (ah *agentsHandler) getAllFilesInDirectory() ([]string, error) {
 		var selectedFiles []string
 		for i, extFile := range extractedFiles {
 			for _, selFile := range ah.IAgents.getFiles() {
-				fn := filepath.Base(extFile)
+				fn := strings.TrimSuffix(filepath.Base(extFile), secrets.Ext)
 				if fn == selFile {
 					selectedFiles = append(selectedFiles, extractedFiles[i])
 				}
@@ -0,0 +1,170 @@
+package rest
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"bytes"
+	"compress/gzip"
+	"fmt"
+	e "gitlab.com/grpasr/common/errors/json"
+	"io"
+	"mime"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// the formats of a download, multipart is the one of the loaders
+const (
+	formatMultipart = "multipart"
+	formatTarGz     = "tar.gz"
+	formatZip       = "zip"
+)
+
+// the media types of the Accept header by format
+var acceptFormats = map[string]string{
+	"multipart/form-data":    formatMultipart,
+	"application/gzip":       formatTarGz,
+	"application/x-gzip":     formatTarGz,
+	"application/x-gtar":     formatTarGz,
+	"application/x-tar+gzip": formatTarGz,
+	"application/zip":        formatZip,
+}
+
+// negotiateFormat choose the format of the download, from the format
+// query param(?format=zip, handy with curl) else from the first media type
+// of the Accept header known, multipart by default
+func negotiateFormat(r *http.Request) (string, e.IError) {
+	if format := r.URL.Query().Get("format"); format != "" {
+		switch format {
+		case formatMultipart, formatTarGz, formatZip:
+			return format, nil
+		case "tgz":
+			return formatTarGz, nil
+		}
+		return "", e.NewCustomHTTPStatus(e.StatusBadRequest, r.URL.Path,
+			fmt.Sprintf("unknown format %s, expected multipart, tar.gz or zip", format))
+	}
+
+	for _, mediaRange := range strings.Split(r.Header.Get("Accept"), ",") {
+		mediaType, params, err := mime.ParseMediaType(strings.TrimSpace(mediaRange))
+		if err != nil {
+			continue
+		}
+		// q=0 refuse the media type
+		if q, err := strconv.ParseFloat(params["q"], 64); err == nil && q == 0 {
+			continue
+		}
+		if format, ok := acceptFormats[mediaType]; ok {
+			return format, nil
+		}
+	}
+
+	return formatMultipart, nil
+}
+
+// archiveHandler send the files in a tar.gz or a zip, named as the
+// directory of the files
+func (ah *agentsHandler) archiveHandler(files []agentFile, format string) e.IError {
+	name := filepath.Base(ah.IAgents.getPathToFiles()) + "." + format
+
+	var err error
+	switch format {
+	case formatZip:
+		ah.w.Header().Set("Content-Type", "application/zip")
+		ah.w.Header().Set("Content-Disposition", fmt.Sprintf(`attachment; filename="%s"`, escapeQuotes(name)))
+		err = writeZip(ah.w, files)
+	default:
+		ah.w.Header().Set("Content-Type", "application/gzip")
+		ah.w.Header().Set("Content-Disposition", fmt.Sprintf(`attachment; filename="%s"`, escapeQuotes(name)))
+		err = writeTarGz(ah.w, files)
+	}
+	if err != nil {
+		return e.NewCustomHTTPStatus(e.StatusInternalServerError, "", err.Error())
+	}
+
+	return nil
+}
+
+func writeTarGz(w io.Writer, files []agentFile) error {
+	gw := gzip.NewWriter(w)
+	tw := tar.NewWriter(gw)
+
+	for _, f := range files {
+		err := addFile(f, func(fi os.FileInfo, src io.Reader) error {
+			hdr, err := tar.FileInfoHeader(fi, "")
+			if err != nil {
+				return err
+			}
+			hdr.Name = f.rel
+			hdr.Mode = 0644
+			if err := tw.WriteHeader(hdr); err != nil {
+				return err
+			}
+			_, err = io.Copy(tw, src)
+			return err
+		})
+		if err != nil {
+			return err
+		}
+	}
+
+	if err := tw.Close(); err != nil {
+		return err
+	}
+	return gw.Close()
+}
+
+func writeZip(w io.Writer, files []agentFile) error {
+	zw := zip.NewWriter(w)
+
+	for _, f := range files {
+		err := addFile(f, func(fi os.FileInfo, src io.Reader) error {
+			hdr, err := zip.FileInfoHeader(fi)
+			if err != nil {
+				return err
+			}
+			hdr.Name = f.rel
+			hdr.Method = zip.Deflate
+			dst, err := zw.CreateHeader(hdr)
+			if err != nil {
+				return err
+			}
+			_, err = io.Copy(dst, src)
+			return err
+		})
+		if err != nil {
+			return err
+		}
+	}
+
+	return zw.Close()
+}
+
+// addFile pass the content of the file to add with its infos, the size
+// is the one of the content as a sealed secret is decrypted
+func addFile(f agentFile, add func(fi os.FileInfo, src io.Reader) error) error {
+	fi, err := os.Stat(f.path)
+	if err != nil {
+		return err
+	}
+
+	content, err := readAgentFile(f)
+	if err != nil {
+		return err
+	}
+
+	return add(contentInfo{fi, int64(len(content))}, bytes.NewReader(content))
+}
+
+// contentInfo is the infos of a file with the size of its content
+type contentInfo struct {
+	os.FileInfo
+	size int64
+}
+
+func (ci contentInfo) Size() int64 {
+	return ci.size
+}
@@ -11,6 +11,11 @@ import (
 	"strings"
 )
 
+// the key of the jwtoken's infos(role, svc, scope) in the request context
+type ctxKey string
+
+const tokenInfosKey ctxKey = "tokenInfos"
+
 type authMiddleware struct {
 	tokenService *services.JWTokenService
 }
@@ -30,8 +35,8 @@ func (a authMiddleware) authorizationHandler() func(http.Handler) http.Handler {
 			//vars := mux.Vars(r) // but return nothing...
 			// _, ok := vars["healthcheck"]
 
-			// do not apply the token validation for the healthcheck
-			if r.URL.Path != "/v1/health" {
+			// do not apply the token validation for the healthchecks
+			if !strings.HasPrefix(r.URL.Path, "/v1/health") {
 
 				token := getTokenFromHeader(authHeader)
 				if token == "" {
@@ -43,7 +48,7 @@ func (a authMiddleware) authorizationHandler() func(http.Handler) http.Handler {
 				}
 
 				// validate the token
-				_, err := a.tokenService.JWTokenIsValidToken(context.TODO(), token)
+				infos, err := a.tokenService.JWTokenIsValidToken(context.TODO(), token)
 				if err != nil {
 					w.Header().Set("Content-Type", "application/json")
 					switch err.GetCode() {
@@ -55,6 +60,9 @@ func (a authMiddleware) authorizationHandler() func(http.Handler) http.Handler {
 					json.NewEncoder(w).Encode(err)
 					return
 				}
+
+				// the handlers may check the scope
+				r = r.WithContext(context.WithValue(r.Context(), tokenInfosKey, infos))
 			}
 
 			next.ServeHTTP(w, r)
@@ -74,3 +82,18 @@ func getTokenFromHeader(header string) string {
 
 	return ""
 }
+
+// hasScope is true if the jwtoken of the request was granted the scope,
+// the scopes are separated by commas or spaces as "read, openid"
+func hasScope(r *http.Request, scope string) bool {
+	infos, ok := r.Context().Value(tokenInfosKey).(map[string]string)
+	if !ok {
+		return false
+	}
+	for _, s := range strings.FieldsFunc(infos["scope"], func(c rune) bool { return c == ',' || c == ' ' }) {
+		if s == scope {
+			return true
+		}
+	}
+	return false
+}
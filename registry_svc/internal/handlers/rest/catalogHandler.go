@@ -0,0 +1,255 @@
+package rest
+
+import (
+	"encoding/json"
+	"fmt"
+	"github.com/gorilla/mux"
+	"gitlab.com/grpasr/asonrythme/registry_svc/internal/compat"
+	e "gitlab.com/grpasr/common/errors/json"
+	"net/http"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+// the names of the packages and of the config bundles, the hidden
+// directories of the publications are skipped
+var catalogNameRegexp = regexp.MustCompile(`^[a-zA-Z]+$`)
+
+type CatalogHandler struct {
+	router *mux.Router
+}
+
+func NewCatalogHandler(router *mux.Router) *CatalogHandler {
+	return &CatalogHandler{router}
+}
+
+func (c *CatalogHandler) RunCatalogRest() {
+
+	c.router.HandleFunc("/grpc", grpcCatalogHandler()).
+		Methods(http.MethodGet).
+		Name("CatalogGetGrpc")
+
+	c.router.HandleFunc(
+		"/grpc/{package:[a-zA-Z]+}/{version:"+versionPattern+"}",
+		grpcPackageCatalogHandler()).
+		Methods(http.MethodGet).
+		Name("CatalogGetGrpcPackage")
+
+	c.router.HandleFunc("/configs", configsCatalogHandler()).
+		Methods(http.MethodGet).
+		Name("CatalogGetConfigs")
+}
+
+// CatalogPackage is a grpc package and its versions from the oldest
+type CatalogPackage struct {
+	Name     string   `json:"name"`
+	Versions []string `json:"versions"`
+	Latest   string   `json:"latest"`
+}
+
+type GrpcCatalogResponse struct {
+	Packages []CatalogPackage `json:"packages"`
+}
+
+// PackageCatalogResponse describe a version of a package, its files as
+// in the manifest of the download and the declarations of its .proto
+type PackageCatalogResponse struct {
+	Package string         `json:"package"`
+	Version string         `json:"version"`
+	Digest  string         `json:"digest"`
+	Files   []manifestFile `json:"files"`
+	Schema  *compat.Schema `json:"schema"`
+}
+
+// CatalogBundle is a directory of configs, as /configs/{version}/{name}
+type CatalogBundle struct {
+	Version string         `json:"version"`
+	Name    string         `json:"name"`
+	Digest  string         `json:"digest"`
+	Files   []manifestFile `json:"files"`
+}
+
+type ConfigsCatalogResponse struct {
+	Bundles []CatalogBundle `json:"bundles"`
+}
+
+// grpcCatalogHandler list the packages of all the versions
+func grpcCatalogHandler() func(w http.ResponseWriter, r *http.Request) {
+	return func(w http.ResponseWriter, r *http.Request) {
+		names, err := catalogDirs(filepath.Join(restConfigs.RESTGetPathToStorage(), restConfigs.RESTGetGrpcDirectory()))
+		if err != nil {
+			writeJSONError(w, e.NewCustomHTTPStatus(e.StatusInternalServerError, "", err.Error()))
+			return
+		}
+
+		// a package is in several versions, only the ones the service may
+		// download are listed
+		svc := tokenSubject(r)
+		pkgs := map[string]bool{}
+		for _, dir := range names {
+			if accessPolicy.allow(svc, accessGrpc, filepath.Base(dir)) {
+				pkgs[filepath.Base(dir)] = true
+			}
+		}
+
+		resp := GrpcCatalogResponse{Packages: []CatalogPackage{}}
+		for p := range pkgs {
+			versions, err := packageVersions(p)
+			if err != nil {
+				writeJSONError(w, e.NewCustomHTTPStatus(e.StatusInternalServerError, "", err.Error()))
+				return
+			}
+			if len(versions) == 0 {
+				continue
+			}
+			cp := CatalogPackage{Name: p, Versions: make([]string, 0, len(versions))}
+			for _, v := range versions {
+				cp.Versions = append(cp.Versions, v.String())
+				if v.pre == "" {
+					cp.Latest = v.String()
+				}
+			}
+			resp.Packages = append(resp.Packages, cp)
+		}
+		sort.Slice(resp.Packages, func(i, j int) bool { return resp.Packages[i].Name < resp.Packages[j].Name })
+
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(resp)
+	}
+}
+
+// grpcPackageCatalogHandler describe the version of a package, the
+// version is resolved as for the download(latest, ^1.2...)
+func grpcPackageCatalogHandler() func(w http.ResponseWriter, r *http.Request) {
+	return func(w http.ResponseWriter, r *http.Request) {
+		vars := mux.Vars(r)
+		p := vars["package"]
+		if !authorize(w, r, accessGrpc, p) {
+			return
+		}
+
+		v, ce := resolveVersion(p, vars["version"])
+		if ce != nil {
+			writeJSONError(w, ce)
+			return
+		}
+
+		dir := filepath.Join(restConfigs.RESTGetPathToStorage(), restConfigs.RESTGetGrpcDirectory(), v.dir, p)
+		m, protos, ce := catalogFiles(dir)
+		if ce != nil {
+			writeJSONError(w, ce)
+			return
+		}
+
+		resp := PackageCatalogResponse{Package: p, Version: v.String(), Digest: m.Digest, Files: m.Files}
+		if len(protos) > 0 {
+			schema, err := compat.Describe(protos)
+			if err != nil {
+				// the files are still listed, the schema is the best effort
+				fmt.Printf("registry_svc, catalogHandler.go, %s/%s not described: %v\n", v, p, err)
+			}
+			resp.Schema = schema
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(resp)
+	}
+}
+
+// configsCatalogHandler list the config bundles of all the versions
+func configsCatalogHandler() func(w http.ResponseWriter, r *http.Request) {
+	return func(w http.ResponseWriter, r *http.Request) {
+		dirs, err := catalogDirs(filepath.Join(restConfigs.RESTGetPathToStorage(), restConfigs.RESTGetConfigsDirectory()))
+		if err != nil {
+			writeJSONError(w, e.NewCustomHTTPStatus(e.StatusInternalServerError, "", err.Error()))
+			return
+		}
+
+		svc := tokenSubject(r)
+		resp := ConfigsCatalogResponse{Bundles: []CatalogBundle{}}
+		for _, dir := range dirs {
+			if !accessPolicy.allow(svc, accessConfigs, filepath.Base(dir)) {
+				continue
+			}
+			m, _, ce := catalogFiles(dir)
+			if ce != nil {
+				writeJSONError(w, ce)
+				return
+			}
+			resp.Bundles = append(resp.Bundles, CatalogBundle{
+				Version: filepath.Base(filepath.Dir(dir)),
+				Name:    filepath.Base(dir),
+				Digest:  m.Digest,
+				Files:   m.Files,
+			})
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(resp)
+	}
+}
+
+// catalogDirs return the directories {root}/{version}/{name}, sorted
+func catalogDirs(root string) ([]string, error) {
+	versions, err := os.ReadDir(root)
+	if err != nil {
+		return nil, err
+	}
+
+	dirs := []string{}
+	for _, version := range versions {
+		if _, _, ok := parseSemver(version.Name()); !ok || !version.IsDir() {
+			continue
+		}
+		entries, err := os.ReadDir(filepath.Join(root, version.Name()))
+		if err != nil {
+			return nil, err
+		}
+		for _, entry := range entries {
+			if entry.IsDir() && catalogNameRegexp.MatchString(entry.Name()) {
+				dirs = append(dirs, filepath.Join(root, version.Name(), entry.Name()))
+			}
+		}
+	}
+
+	return dirs, nil
+}
+
+// catalogFiles return the manifest of the files of dir and the content
+// of its .proto by name
+func catalogFiles(dir string) (*manifest, map[string][]byte, e.IError) {
+	files := []agentFile{}
+	protos := map[string][]byte{}
+	err := filepath.Walk(dir, func(path string, info os.FileInfo, err error) error {
+		if err != nil || info.IsDir() {
+			return err
+		}
+		rel, err := filepath.Rel(dir, path)
+		if err != nil {
+			return err
+		}
+		rel = filepath.ToSlash(rel)
+		files = append(files, newAgentFile(rel, rel, path))
+
+		if strings.HasSuffix(rel, ".proto") {
+			content, err := os.ReadFile(path)
+			if err != nil {
+				return err
+			}
+			protos[rel] = content
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, nil, e.NewCustomHTTPStatus(e.StatusInternalServerError, "", err.Error())
+	}
+
+	m, ce := newManifest(files)
+	if ce != nil {
+		return nil, nil, ce
+	}
+	return m, protos, nil
+}
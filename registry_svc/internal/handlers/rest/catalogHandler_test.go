@@ -0,0 +1,70 @@
+package rest
+
+import (
+	"encoding/json"
+	"gitlab.com/grpasr/common/tests"
+	"net/http"
+	"testing"
+)
+
+func catalogQuery(url string, resp interface{}) (int, error) {
+	req, _ := http.NewRequest(http.MethodGet, url, nil)
+	req.Header.Set("Authorization", "Bearer "+testToken("read"))
+	r, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return 0, err
+	}
+	defer r.Body.Close()
+	json.NewDecoder(r.Body).Decode(resp)
+	return r.StatusCode, nil
+}
+
+func Test_catalog_grpc(t *testing.T) {
+	tests.MaybeFail = tests.InitFailFunc(t)
+
+	packages := GrpcCatalogResponse{}
+	statusCode, err := catalogQuery("http://localhost:4000/catalog/grpc", &packages)
+	tests.MaybeFail("catalog_grpc", err,
+		tests.Expect(statusCode, http.StatusOK),
+		tests.Expect(len(packages.Packages), 2),
+		tests.Expect(packages.Packages[0].Name, "name"),
+		tests.Expect(packages.Packages[1].Name, "order"),
+		tests.Expect(packages.Packages[1].Latest, "v1.0.0"),
+	)
+
+	order := PackageCatalogResponse{}
+	statusCode, err = catalogQuery("http://localhost:4000/catalog/grpc/order/latest", &order)
+	tests.MaybeFail("catalog_grpc_package", err,
+		tests.Expect(statusCode, http.StatusOK),
+		tests.Expect(order.Version, "v1.0.0"),
+		tests.Expect(len(order.Files), 3),
+		tests.Expect(order.Files[0].Name, "Order.pb.go"),
+		tests.Expect(order.Files[0].Size > 0, true),
+		tests.Expect(order.Schema != nil, true),
+	)
+	if order.Schema != nil {
+		tests.MaybeFail("catalog_grpc_schema",
+			tests.Expect(order.Schema.Package, "v1_order"),
+			tests.Expect(order.Schema.Services[0].Name, "OrderManagement"),
+			tests.Expect(len(order.Schema.Services[0].RPCs), 2),
+			tests.Expect(len(order.Schema.Messages), 2),
+		)
+	}
+
+	statusCode, err = catalogQuery("http://localhost:4000/catalog/grpc/order/v3", &order)
+	tests.MaybeFail("catalog_grpc_unknown", err, tests.Expect(statusCode, http.StatusNotFound))
+}
+
+func Test_catalog_configs(t *testing.T) {
+	tests.MaybeFail = tests.InitFailFunc(t)
+
+	bundles := ConfigsCatalogResponse{}
+	statusCode, err := catalogQuery("http://localhost:4000/catalog/configs", &bundles)
+	tests.MaybeFail("catalog_configs", err,
+		tests.Expect(statusCode, http.StatusOK),
+		tests.Expect(len(bundles.Bundles), 2),
+		tests.Expect(bundles.Bundles[0].Name, "certificates"),
+		tests.Expect(bundles.Bundles[0].Version, "v1"),
+		tests.Expect(len(bundles.Bundles[0].Files), 6),
+	)
+}
@@ -0,0 +1,193 @@
+package rest
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/gorilla/mux"
+	"gitlab.com/grpasr/asonrythme/registry_svc/internal/discovery"
+	e "gitlab.com/grpasr/common/errors/json"
+	"net/http"
+	"time"
+)
+
+// the instances registered, they are lost on a restart and registered
+// again with the next heartbeats
+var discoveryRegistry = discovery.NewRegistry()
+
+type DiscoveryHandler struct {
+	router *mux.Router
+}
+
+func NewDiscoveryHandler(router *mux.Router) *DiscoveryHandler {
+	return &DiscoveryHandler{router}
+}
+
+func (d *DiscoveryHandler) RunDiscoveryRest() {
+
+	d.router.HandleFunc("/services", registerHandler()).
+		Methods(http.MethodPost).
+		Name("DiscoveryRegister")
+
+	d.router.HandleFunc("/services", resolveHandler()).
+		Methods(http.MethodGet).
+		Name("DiscoveryList")
+
+	d.router.HandleFunc("/services/{name:[a-zA-Z][a-zA-Z0-9_-]*}", resolveHandler()).
+		Methods(http.MethodGet).
+		Name("DiscoveryResolve")
+
+	d.router.HandleFunc("/instances/{id:[a-f0-9]+}/heartbeat", heartbeatHandler()).
+		Methods(http.MethodPut).
+		Name("DiscoveryHeartbeat")
+
+	d.router.HandleFunc("/instances/{id:[a-f0-9]+}", deregisterHandler()).
+		Methods(http.MethodDelete).
+		Name("DiscoveryDeregister")
+}
+
+// RegisterRequest is the instance to register, TTL is its lease in
+// seconds(DISCOVERY_TTL if not set)
+type RegisterRequest struct {
+	Name     string            `json:"name"`
+	Env      string            `json:"env"`
+	Address  string            `json:"address"`
+	Port     string            `json:"port"`
+	Version  string            `json:"version"`
+	Metadata map[string]string `json:"metadata"`
+	TTL      int               `json:"ttl"`
+}
+
+// ResolveResponse list the live instances of a service, of all the
+// services on /discovery/services
+type ResolveResponse struct {
+	Name      string               `json:"name,omitempty"`
+	Instances []discovery.Instance `json:"instances"`
+}
+
+// registerHandler register an instance, the response hold its ID for the
+// heartbeats. A service register only under its own name, the sub of its
+// jwtoken
+func registerHandler() func(w http.ResponseWriter, r *http.Request) {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if !hasDiscoveryScope(w, r) {
+			return
+		}
+
+		req := RegisterRequest{}
+		if err := json.NewDecoder(http.MaxBytesReader(w, r.Body, 64<<10)).Decode(&req); err != nil {
+			writeJSONError(w, e.NewCustomHTTPStatus(e.StatusBadRequest, r.URL.Path, err.Error()))
+			return
+		}
+
+		svc := tokenSubject(r)
+		if req.Name != svc {
+			fmt.Printf("registry_svc, discoveryHandler.go, %q denied to register as %q\n", svc, req.Name)
+			writeJSONError(w, e.NewCustomHTTPStatus(e.StatusForbidden, r.URL.Path,
+				fmt.Sprintf("the service %s can not register as %s", svc, req.Name)))
+			return
+		}
+
+		ttl := restConfigs.RESTGetDiscoveryTTL()
+		if req.TTL > 0 {
+			ttl = time.Duration(req.TTL) * time.Second
+		}
+		if ttl > restConfigs.RESTGetDiscoveryMaxTTL() {
+			ttl = restConfigs.RESTGetDiscoveryMaxTTL()
+		}
+
+		inst, err := discoveryRegistry.Register(discovery.Instance{
+			Name:     req.Name,
+			Env:      req.Env,
+			Address:  req.Address,
+			Port:     req.Port,
+			Version:  req.Version,
+			Metadata: req.Metadata,
+			Owner:    svc,
+		}, ttl)
+		if err != nil {
+			writeDiscoveryError(w, r, err)
+			return
+		}
+
+		fmt.Printf("registry_svc, discoveryHandler.go, %s registered at %s:%s(%s) for %v\n", inst.Name, inst.Address, inst.Port, inst.Env, ttl)
+
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusCreated)
+		json.NewEncoder(w).Encode(inst)
+	}
+}
+
+// heartbeatHandler renew the lease of an instance, a 404 tell the
+// instance to register again
+func heartbeatHandler() func(w http.ResponseWriter, r *http.Request) {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if !hasDiscoveryScope(w, r) {
+			return
+		}
+
+		inst, err := discoveryRegistry.Heartbeat(mux.Vars(r)["id"], tokenSubject(r))
+		if err != nil {
+			writeDiscoveryError(w, r, err)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(inst)
+	}
+}
+
+func deregisterHandler() func(w http.ResponseWriter, r *http.Request) {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if !hasDiscoveryScope(w, r) {
+			return
+		}
+
+		if err := discoveryRegistry.Deregister(mux.Vars(r)["id"], tokenSubject(r)); err != nil {
+			writeDiscoveryError(w, r, err)
+			return
+		}
+
+		w.WriteHeader(http.StatusNoContent)
+	}
+}
+
+// resolveHandler list the live instances, filtered on ?env= and
+// ?version=. A service without instance is an empty list, not a 404, so
+// the resolvers keep polling
+func resolveHandler() func(w http.ResponseWriter, r *http.Request) {
+	return func(w http.ResponseWriter, r *http.Request) {
+		name := mux.Vars(r)["name"]
+		q := r.URL.Query()
+
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(ResolveResponse{
+			Name:      name,
+			Instances: discoveryRegistry.Resolve(name, q.Get("env"), q.Get("version")),
+		})
+	}
+}
+
+// hasDiscoveryScope write the 403 if the jwtoken can not register
+func hasDiscoveryScope(w http.ResponseWriter, r *http.Request) bool {
+	if !hasScope(r, restConfigs.RESTGetDiscoveryScope()) {
+		writeJSONError(w, e.NewCustomHTTPStatus(e.StatusForbidden, r.URL.Path,
+			fmt.Sprintf("the scope %s is required to register", restConfigs.RESTGetDiscoveryScope())))
+		return false
+	}
+	return true
+}
+
+func writeDiscoveryError(w http.ResponseWriter, r *http.Request, err error) {
+	switch {
+	case errors.Is(err, discovery.ErrNotFound):
+		writeJSONError(w, e.NewCustomHTTPStatus(e.StatusNotFound, r.URL.Path, err.Error()))
+	case errors.Is(err, discovery.ErrInvalid):
+		writeJSONError(w, e.NewCustomHTTPStatus(e.StatusBadRequest, r.URL.Path, err.Error()))
+	case errors.Is(err, discovery.ErrForbidden):
+		fmt.Printf("registry_svc, discoveryHandler.go, %q denied on %s\n", tokenSubject(r), r.URL.Path)
+		writeJSONError(w, e.NewCustomHTTPStatus(e.StatusForbidden, r.URL.Path, err.Error()))
+	default:
+		writeJSONError(w, e.NewCustomHTTPStatus(e.StatusInternalServerError, "", err.Error()))
+	}
+}
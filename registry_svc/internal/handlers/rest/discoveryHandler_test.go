@@ -0,0 +1,96 @@
+package rest
+
+import (
+	"bytes"
+	"encoding/json"
+	"gitlab.com/grpasr/asonrythme/registry_svc/internal/discovery"
+	"gitlab.com/grpasr/common/tests"
+	"net/http"
+	"testing"
+)
+
+func jsonQuery(method, url, scope string, body interface{}, resp interface{}) (int, error) {
+	return jsonQueryAs("testSvc", method, url, scope, body, resp)
+}
+
+// jsonQueryAs query with the jwtoken of the service svc
+func jsonQueryAs(svc, method, url, scope string, body interface{}, resp interface{}) (int, error) {
+	buf := &bytes.Buffer{}
+	if body != nil {
+		json.NewEncoder(buf).Encode(body)
+	}
+	req, _ := http.NewRequest(method, url, buf)
+	req.Header.Set("Authorization", "Bearer "+testTokenFor(svc, scope))
+	r, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return 0, err
+	}
+	defer r.Body.Close()
+	if resp != nil {
+		json.NewDecoder(r.Body).Decode(resp)
+	}
+	return r.StatusCode, nil
+}
+
+func Test_discovery_register_and_resolve(t *testing.T) {
+	tests.MaybeFail = tests.InitFailFunc(t)
+	t.Cleanup(func() { discoveryRegistry = discovery.NewRegistry() })
+
+	order := RegisterRequest{Name: "order", Env: "localhost", Address: "localhost", Port: "50001", Version: "v1.0.0", TTL: 3600}
+	inst := discovery.Instance{}
+	statusCode, err := jsonQueryAs("order", http.MethodPost, "http://localhost:4000/discovery/services", "read, discovery", order, &inst)
+	tests.MaybeFail("register", err,
+		tests.Expect(statusCode, http.StatusCreated),
+		tests.Expect(inst.ID != "", true),
+		// capped to DISCOVERY_MAX_TTL
+		tests.Expect(inst.TTL, 300),
+	)
+
+	resolved := ResolveResponse{}
+	statusCode, err = jsonQuery(http.MethodGet, "http://localhost:4000/discovery/services/order?env=localhost", "read", nil, &resolved)
+	tests.MaybeFail("resolve", err,
+		tests.Expect(statusCode, http.StatusOK),
+		tests.Expect(len(resolved.Instances), 1),
+		tests.Expect(resolved.Instances[0].Port, "50001"),
+	)
+
+	statusCode, err = jsonQueryAs("order", http.MethodPut, "http://localhost:4000/discovery/instances/"+inst.ID+"/heartbeat", "discovery", nil, nil)
+	tests.MaybeFail("heartbeat", err, tests.Expect(statusCode, http.StatusOK))
+
+	// the instance belong to order
+	statusCode, err = jsonQueryAs("brokerSvc", http.MethodPut, "http://localhost:4000/discovery/instances/"+inst.ID+"/heartbeat", "discovery", nil, nil)
+	tests.MaybeFail("heartbeat_other", err, tests.Expect(statusCode, http.StatusForbidden))
+	statusCode, err = jsonQueryAs("brokerSvc", http.MethodDelete, "http://localhost:4000/discovery/instances/"+inst.ID, "discovery", nil, nil)
+	tests.MaybeFail("deregister_other", err, tests.Expect(statusCode, http.StatusForbidden))
+
+	statusCode, err = jsonQueryAs("order", http.MethodDelete, "http://localhost:4000/discovery/instances/"+inst.ID, "discovery", nil, nil)
+	tests.MaybeFail("deregister", err, tests.Expect(statusCode, http.StatusNoContent))
+
+	// a heartbeat of an unknown instance ask it to register again
+	statusCode, err = jsonQueryAs("order", http.MethodPut, "http://localhost:4000/discovery/instances/"+inst.ID+"/heartbeat", "discovery", nil, nil)
+	tests.MaybeFail("heartbeat_unknown", err, tests.Expect(statusCode, http.StatusNotFound))
+
+	resolved = ResolveResponse{}
+	statusCode, err = jsonQuery(http.MethodGet, "http://localhost:4000/discovery/services/order", "read", nil, &resolved)
+	tests.MaybeFail("resolve_none", err,
+		tests.Expect(statusCode, http.StatusOK),
+		tests.Expect(len(resolved.Instances), 0),
+	)
+}
+
+func Test_discovery_register_invalid(t *testing.T) {
+	tests.MaybeFail = tests.InitFailFunc(t)
+	t.Cleanup(func() { discoveryRegistry = discovery.NewRegistry() })
+
+	order := RegisterRequest{Name: "order", Address: "localhost", Port: "50001"}
+	statusCode, err := jsonQuery(http.MethodPost, "http://localhost:4000/discovery/services", "read", order, nil)
+	tests.MaybeFail("register_scope", err, tests.Expect(statusCode, http.StatusForbidden))
+
+	// a service register only under its own name
+	statusCode, err = jsonQueryAs("brokerSvc", http.MethodPost, "http://localhost:4000/discovery/services", "discovery", order, nil)
+	tests.MaybeFail("register_other_name", err, tests.Expect(statusCode, http.StatusForbidden))
+
+	order.Port = ""
+	statusCode, err = jsonQueryAs("order", http.MethodPost, "http://localhost:4000/discovery/services", "discovery", order, nil)
+	tests.MaybeFail("register_port", err, tests.Expect(statusCode, http.StatusBadRequest))
+}
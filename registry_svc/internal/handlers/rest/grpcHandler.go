@@ -19,18 +19,33 @@ func NewGrpcHandler(router *mux.Router) *GrpcHandler {
 
 func (g *GrpcHandler) RunGrpcRest() {
 
+	// before /{version}/{package}, a package name has no digit so v1/versions
+	// stay the package "versions" of v1
 	g.router.HandleFunc(
-		"/{version:v[1-9]}/{package:[a-zA-Z]+}/{type:[a-zA-Z-_]+}",
+		"/{package:[a-zA-Z]+}/versions",
+		versionsHandler()).
+		Methods(http.MethodGet).
+		Name("GrpcGetVersions")
+
+	g.router.HandleFunc(
+		"/{version:"+versionPattern+"}/{package:[a-zA-Z]+}/{type:[a-zA-Z-_]+}",
 		serveFilesHandler(restConfigs.RESTGetGrpcDirectory())).
 		Methods(http.MethodGet).
 		Name("GrcpGetTypes")
 
 	g.router.HandleFunc(
-		"/{version:v[1-9]}/{package:[a-zA-Z]+}",
+		"/{version:"+versionPattern+"}/{package:[a-zA-Z]+}",
 		serveFilesHandler(restConfigs.RESTGetGrpcDirectory())).
 		Methods(http.MethodGet).
 		Name("GrcpGetPackage")
 
+	// publish the files of a package's version, a version is immutable
+	g.router.HandleFunc(
+		"/{version:"+versionPattern+"}/{package:[a-zA-Z]+}",
+		publishHandler()).
+		Methods(http.MethodPost).
+		Name("GrpcPublishPackage")
+
 }
 
 type grpcAgent struct {
@@ -52,7 +67,15 @@ func NewGrpcAgent(files []string, v, p, t string) *grpcAgent {
 	}
 }
 
+// handlePathSegment resolve the version(latest, ^1.2, v1...) to the
+// directory of the matching one
 func (ga *grpcAgent) handlePathSegment() e.IError {
+	v, err := resolveVersion(ga.fPackage, ga.fVersion)
+	if err != nil {
+		return err
+	}
+	ga.fVersion = v.dir
+
 	return nil
 }
 
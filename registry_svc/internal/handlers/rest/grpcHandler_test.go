@@ -2,7 +2,15 @@ package rest
 
 import (
 	// "fmt"
+	"archive/tar"
+	"archive/zip"
+	"bytes"
+	"compress/gzip"
+	"encoding/json"
 	"gitlab.com/grpasr/common/tests"
+	"io"
+	"mime/multipart"
+	"net/http"
 	"strings"
 	"testing"
 )
@@ -29,8 +37,8 @@ func Test_grpc_err_no_directory(t *testing.T) {
 	statusCode, respBody, filesCount, filesName, err := cltQuery("http://localhost:4000/grpc/v1/na")
 
 	tests.MaybeFail("createNewCodeError", err,
-		tests.Expect(statusCode, 500),
-		tests.Expect(respBody, "500 : Server error, Comment: lstat ../../../testsStorage/api/v1/na: no such file or directory"),
+		tests.Expect(statusCode, 404),
+		tests.Expect(strings.Contains(respBody, "no version matching v1"), true),
 		tests.Expect(filesCount, 0),
 		tests.Expect(len(filesName), 0),
 	)
@@ -88,3 +96,115 @@ func Test_grpc_get_directory_specific_invalid_files(t *testing.T) {
 		tests.Expect(len(filesName), 0),
 	)
 }
+
+func Test_grpc_etag_and_manifest(t *testing.T) {
+	tests.MaybeFail = tests.InitFailFunc(t)
+
+	get := func(url, ifNoneMatch string) (*http.Response, error) {
+		req, _ := http.NewRequest(http.MethodGet, url, nil)
+		req.Header.Set("Authorization", "Bearer "+testToken("read"))
+		if ifNoneMatch != "" {
+			req.Header.Set("If-None-Match", ifNoneMatch)
+		}
+		return http.DefaultClient.Do(req)
+	}
+
+	resp, err := get("http://localhost:4000/grpc/v1/name?manifest", "")
+	m := manifest{}
+	if err == nil {
+		json.NewDecoder(resp.Body).Decode(&m)
+		resp.Body.Close()
+	}
+
+	tests.MaybeFail("manifest", err,
+		tests.Expect(resp.StatusCode, http.StatusOK),
+		tests.Expect(resp.Header.Get("ETag"), `"`+strings.TrimPrefix(m.Digest, "sha256:")+`"`),
+		tests.Expect(len(m.Files), 4),
+		tests.Expect(m.Files[0].Name, "Address.pb.go"),
+		tests.Expect(strings.HasPrefix(m.Files[0].Digest, "sha256:"), true),
+	)
+
+	// the download has the etag of the manifest and a digest per file
+	resp, err = get("http://localhost:4000/grpc/v1/name", "")
+	var partDigest string
+	if err == nil {
+		mr := multipart.NewReader(resp.Body, boundaryFromContentType(resp.Header.Get("Content-Type")))
+		if part, perr := mr.NextPart(); perr == nil {
+			partDigest = part.Header.Get("Digest")
+		}
+		resp.Body.Close()
+	}
+	tests.MaybeFail("etag", err,
+		tests.Expect(resp.Header.Get("ETag"), `"`+strings.TrimPrefix(m.Digest, "sha256:")+`"`),
+		tests.Expect(strings.HasPrefix(partDigest, "sha-256="), true),
+	)
+
+	resp, err = get("http://localhost:4000/grpc/v1/name", `"other", `+resp.Header.Get("ETag"))
+	tests.MaybeFail("not_modified", err, tests.Expect(resp.StatusCode, http.StatusNotModified))
+
+	// a type filter is another download
+	resp, err = get("http://localhost:4000/grpc/v1/name/person", `"`+strings.TrimPrefix(m.Digest, "sha256:")+`"`)
+	tests.MaybeFail("other_etag", err, tests.Expect(resp.StatusCode, http.StatusOK))
+}
+
+func Test_grpc_archive_download(t *testing.T) {
+	tests.MaybeFail = tests.InitFailFunc(t)
+
+	get := func(url, accept string) (*http.Response, []byte, error) {
+		req, _ := http.NewRequest(http.MethodGet, url, nil)
+		req.Header.Set("Authorization", "Bearer "+testToken("read"))
+		if accept != "" {
+			req.Header.Set("Accept", accept)
+		}
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			return nil, nil, err
+		}
+		defer resp.Body.Close()
+		body, err := io.ReadAll(resp.Body)
+		return resp, body, err
+	}
+
+	resp, body, err := get("http://localhost:4000/grpc/v1/name", "application/zip;q=0.9, */*")
+	names := []string{}
+	zr, zerr := zip.NewReader(bytes.NewReader(body), int64(len(body)))
+	if zerr == nil {
+		for _, f := range zr.File {
+			names = append(names, f.Name)
+		}
+	}
+	tests.MaybeFail("zip", err, zerr,
+		tests.Expect(resp.StatusCode, http.StatusOK),
+		tests.Expect(resp.Header.Get("Content-Type"), "application/zip"),
+		tests.Expect(resp.Header.Get("Content-Disposition"), `attachment; filename="name.zip"`),
+		tests.Expect(len(names), 4),
+	)
+	zipETag := resp.Header.Get("ETag")
+
+	// the query param win over the Accept header
+	resp, body, err = get("http://localhost:4000/grpc/v1/name?format=tar.gz", "application/zip")
+	names = []string{}
+	gr, gerr := gzip.NewReader(bytes.NewReader(body))
+	if gerr == nil {
+		tr := tar.NewReader(gr)
+		for hdr, terr := tr.Next(); terr == nil; hdr, terr = tr.Next() {
+			names = append(names, hdr.Name)
+		}
+	}
+	tests.MaybeFail("tar.gz", err, gerr,
+		tests.Expect(resp.StatusCode, http.StatusOK),
+		tests.Expect(resp.Header.Get("Content-Type"), "application/gzip"),
+		tests.Expect(len(names), 4),
+		tests.Expect(resp.Header.Get("ETag") != zipETag, true),
+		tests.Expect(strings.HasSuffix(zipETag, `.zip"`), true),
+	)
+
+	resp, _, err = get("http://localhost:4000/grpc/v1/name", "application/zip;q=0")
+	tests.MaybeFail("refused", err,
+		tests.Expect(resp.StatusCode, http.StatusOK),
+		tests.Expect(strings.HasPrefix(resp.Header.Get("Content-Type"), "multipart/form-data"), true),
+	)
+
+	resp, _, err = get("http://localhost:4000/grpc/v1/name?format=rar", "")
+	tests.MaybeFail("unknown_format", err, tests.Expect(resp.StatusCode, http.StatusBadRequest))
+}
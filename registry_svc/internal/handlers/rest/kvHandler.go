@@ -0,0 +1,255 @@
+package rest
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/gorilla/mux"
+	"gitlab.com/grpasr/asonrythme/registry_svc/internal/kvstore"
+	e "gitlab.com/grpasr/common/errors/json"
+	"net/http"
+	"path/filepath"
+	"strconv"
+)
+
+// the configuration store, nil if it could not be read
+var kvStore *kvstore.Store
+
+type KVHandler struct {
+	router *mux.Router
+}
+
+func NewKVHandler(router *mux.Router) *KVHandler {
+	return &KVHandler{router}
+}
+
+func (k *KVHandler) RunKVRest() {
+
+	var err error
+	kvStore, err = kvstore.Open(kvStorePath(), restConfigs.RESTGetConfigStoreHistory())
+	if err != nil {
+		// the store is not overwritten, it must be fixed by hand
+		fmt.Printf("registry_svc, kvHandler.go, the config store is unavailable: %v\n", err)
+	}
+
+	k.router.HandleFunc(
+		"/resolve/{env:[a-zA-Z0-9_-]+}/{service:[a-zA-Z0-9_-]+}",
+		kvResolveHandler()).
+		Methods(http.MethodGet).
+		Name("KVResolve")
+
+	k.router.HandleFunc(
+		"/resolve/{env:[a-zA-Z0-9_-]+}/{service:[a-zA-Z0-9_-]+}/{instance:[a-zA-Z0-9_-]+}",
+		kvResolveHandler()).
+		Methods(http.MethodGet).
+		Name("KVResolveInstance")
+
+	// the global layer is /layers, the others /layers/{env}[/{service}[/{instance}]]
+	for _, path := range []string{"/layers", "/layers/{layer:.+}"} {
+		k.router.HandleFunc(path, kvLayerHandler()).
+			Methods(http.MethodGet)
+		k.router.HandleFunc(path, kvSetHandler()).
+			Methods(http.MethodPut)
+	}
+
+	k.router.HandleFunc("/history", kvHistoryHandler()).
+		Methods(http.MethodGet).
+		Name("KVHistory")
+
+	k.router.HandleFunc("/rollback/{revision:[0-9]+}", kvRollbackHandler()).
+		Methods(http.MethodPost).
+		Name("KVRollback")
+}
+
+func kvStorePath() string {
+	return filepath.Join(restConfigs.RESTGetPathToStorage(), restConfigs.RESTGetConfigStoreFile())
+}
+
+// ResolveConfigResponse is the configuration of a service, the layers
+// merged from global. Sources tell the layer of each key
+type ResolveConfigResponse struct {
+	Layer    string                 `json:"layer"`
+	Revision int                    `json:"revision"`
+	Values   map[string]interface{} `json:"values"`
+	Sources  map[string]string      `json:"sources"`
+}
+
+// LayerResponse is the values set on a layer
+type LayerResponse struct {
+	Layer    string                 `json:"layer"`
+	Revision int                    `json:"revision"`
+	Values   map[string]interface{} `json:"values"`
+}
+
+// SetLayerRequest set the values of a layer, a null value delete the key
+type SetLayerRequest struct {
+	Values  map[string]interface{} `json:"values"`
+	Comment string                 `json:"comment"`
+}
+
+type HistoryResponse struct {
+	Revisions []kvstore.Revision `json:"revisions"`
+}
+
+// kvResolveHandler serve the configuration of a service, the ETag is the
+// revision so a service polling it get a 304 until something change. The
+// access policy tell which services a jwtoken may resolve
+func kvResolveHandler() func(w http.ResponseWriter, r *http.Request) {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if !isKVStoreAvailable(w) {
+			return
+		}
+		vars := mux.Vars(r)
+		if !authorize(w, r, accessKV, vars["service"]) {
+			return
+		}
+		l := kvstore.Layer{Env: vars["env"], Service: vars["service"], Instance: vars["instance"]}
+
+		values, sources, revision := kvStore.Resolve(l)
+
+		etag := fmt.Sprintf(`"%d"`, revision)
+		w.Header().Set("ETag", etag)
+		if etagMatch(r.Header.Get("If-None-Match"), etag) {
+			w.WriteHeader(http.StatusNotModified)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(ResolveConfigResponse{
+			Layer:    l.String(),
+			Revision: revision,
+			Values:   values,
+			Sources:  sources,
+		})
+	}
+}
+
+// kvLayerHandler serve the values set on a layer, they are read by the
+// tools changing the configs
+func kvLayerHandler() func(w http.ResponseWriter, r *http.Request) {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if !isKVStoreAvailable(w) || !hasConfigStoreScope(w, r) {
+			return
+		}
+		l, err := kvstore.ParseLayer(mux.Vars(r)["layer"])
+		if err != nil {
+			writeJSONError(w, e.NewCustomHTTPStatus(e.StatusBadRequest, r.URL.Path, err.Error()))
+			return
+		}
+
+		values, revision := kvStore.Layer(l)
+
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(LayerResponse{Layer: l.String(), Revision: revision, Values: values})
+	}
+}
+
+// kvSetHandler set the values of a layer, the response is the revision
+// created
+func kvSetHandler() func(w http.ResponseWriter, r *http.Request) {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if !isKVStoreAvailable(w) || !hasConfigStoreScope(w, r) {
+			return
+		}
+		l, err := kvstore.ParseLayer(mux.Vars(r)["layer"])
+		if err != nil {
+			writeJSONError(w, e.NewCustomHTTPStatus(e.StatusBadRequest, r.URL.Path, err.Error()))
+			return
+		}
+
+		req := SetLayerRequest{}
+		if err := json.NewDecoder(http.MaxBytesReader(w, r.Body, 1<<20)).Decode(&req); err != nil {
+			writeJSONError(w, e.NewCustomHTTPStatus(e.StatusBadRequest, r.URL.Path, err.Error()))
+			return
+		}
+
+		rev, err := kvStore.Set(l, req.Values, tokenSubject(r), req.Comment)
+		if err != nil {
+			writeKVError(w, r, err)
+			return
+		}
+
+		fmt.Printf("registry_svc, kvHandler.go, %s set by %s, revision %d\n", l, rev.Author, rev.Number)
+
+		rev.Values = nil
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(rev)
+	}
+}
+
+// kvHistoryHandler serve the revisions, they hold the values of every
+// layer so the config scope is required as for a layer
+func kvHistoryHandler() func(w http.ResponseWriter, r *http.Request) {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if !isKVStoreAvailable(w) || !hasConfigStoreScope(w, r) {
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(HistoryResponse{Revisions: kvStore.History()})
+	}
+}
+
+// kvRollbackHandler restore the values of a revision as a new revision,
+// the history is kept
+func kvRollbackHandler() func(w http.ResponseWriter, r *http.Request) {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if !isKVStoreAvailable(w) || !hasConfigStoreScope(w, r) {
+			return
+		}
+		number, err := strconv.Atoi(mux.Vars(r)["revision"])
+		if err != nil {
+			writeJSONError(w, e.NewCustomHTTPStatus(e.StatusBadRequest, r.URL.Path, err.Error()))
+			return
+		}
+
+		rev, err := kvStore.Rollback(number, tokenSubject(r), r.URL.Query().Get("comment"))
+		if err != nil {
+			writeKVError(w, r, err)
+			return
+		}
+
+		fmt.Printf("registry_svc, kvHandler.go, rollback to %d by %s, revision %d\n", number, rev.Author, rev.Number)
+
+		rev.Values = nil
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(rev)
+	}
+}
+
+func isKVStoreAvailable(w http.ResponseWriter) bool {
+	if kvStore == nil {
+		writeJSONError(w, e.NewCustomHTTPStatus(e.StatusInternalServerError, "", "the config store is unavailable"))
+		return false
+	}
+	return true
+}
+
+// hasConfigStoreScope write the 403 if the jwtoken can not read the
+// layers or change the configs
+func hasConfigStoreScope(w http.ResponseWriter, r *http.Request) bool {
+	if !hasScope(r, restConfigs.RESTGetConfigStoreScope()) {
+		writeJSONError(w, e.NewCustomHTTPStatus(e.StatusForbidden, r.URL.Path,
+			fmt.Sprintf("the scope %s is required to manage the configs", restConfigs.RESTGetConfigStoreScope())))
+		return false
+	}
+	return true
+}
+
+// tokenSubject is the service or user of the jwtoken, the author of the
+// revisions
+func tokenSubject(r *http.Request) string {
+	infos, _ := r.Context().Value(tokenInfosKey).(map[string]string)
+	return infos["svc"]
+}
+
+func writeKVError(w http.ResponseWriter, r *http.Request, err error) {
+	switch {
+	case errors.Is(err, kvstore.ErrNotFound):
+		writeJSONError(w, e.NewCustomHTTPStatus(e.StatusNotFound, r.URL.Path, err.Error()))
+	case errors.Is(err, kvstore.ErrInvalid):
+		writeJSONError(w, e.NewCustomHTTPStatus(e.StatusBadRequest, r.URL.Path, err.Error()))
+	default:
+		writeJSONError(w, e.NewCustomHTTPStatus(e.StatusInternalServerError, "", err.Error()))
+	}
+}
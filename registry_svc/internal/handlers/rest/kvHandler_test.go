@@ -0,0 +1,105 @@
+package rest
+
+import (
+	"gitlab.com/grpasr/asonrythme/registry_svc/internal/kvstore"
+	"gitlab.com/grpasr/common/tests"
+	"net/http"
+	"os"
+	"testing"
+)
+
+// resetKVStore remove the store written by the test
+func resetKVStore() {
+	os.Remove(kvStorePath())
+	kvStore, _ = kvstore.Open(kvStorePath(), restConfigs.RESTGetConfigStoreHistory())
+}
+
+func Test_kv_layers_and_resolve(t *testing.T) {
+	tests.MaybeFail = tests.InitFailFunc(t)
+	t.Cleanup(resetKVStore)
+
+	for layer, values := range map[string]map[string]interface{}{
+		"":                    {"health.interval": 10, "obs.sampling": 0.6},
+		"/development":        {"obs.sampling": 1},
+		"/development/broker": {"health.interval": 20},
+	} {
+		rev := kvstore.Revision{}
+		statusCode, err := jsonQuery(http.MethodPut, "http://localhost:4000/kv/layers"+layer, "config",
+			SetLayerRequest{Values: values, Comment: "init"}, &rev)
+		tests.MaybeFail("set_"+layer, err,
+			tests.Expect(statusCode, http.StatusOK),
+			tests.Expect(rev.Author, "testSvc"),
+		)
+	}
+
+	resolved := ResolveConfigResponse{}
+	statusCode, err := jsonQuery(http.MethodGet, "http://localhost:4000/kv/resolve/development/broker/broker-1", "read", nil, &resolved)
+	tests.MaybeFail("resolve", err,
+		tests.Expect(statusCode, http.StatusOK),
+		tests.Expect(resolved.Revision, 3),
+		tests.Expect(resolved.Values["health.interval"], 20.0),
+		tests.Expect(resolved.Values["obs.sampling"], 1.0),
+		tests.Expect(resolved.Sources["health.interval"], "development/broker"),
+	)
+
+	req, _ := http.NewRequest(http.MethodGet, "http://localhost:4000/kv/resolve/development/broker", nil)
+	req.Header.Set("Authorization", "Bearer "+testToken("read"))
+	req.Header.Set("If-None-Match", `"3"`)
+	resp, err := http.DefaultClient.Do(req)
+	tests.MaybeFail("resolve_not_modified", err, tests.Expect(resp.StatusCode, http.StatusNotModified))
+
+	// a service resolve only the configuration the access policy allow
+	statusCode, err = jsonQueryAs("limitedSvc", http.MethodGet, "http://localhost:4000/kv/resolve/development/broker", "read", nil, nil)
+	tests.MaybeFail("resolve_denied", err, tests.Expect(statusCode, http.StatusForbidden))
+
+	statusCode, err = jsonQueryAs("limitedSvc", http.MethodGet, "http://localhost:4000/kv/resolve/development/limitedSvc", "read", nil, nil)
+	tests.MaybeFail("resolve_own", err, tests.Expect(statusCode, http.StatusOK))
+
+	// the layers are read with the scope of the configs
+	statusCode, err = jsonQuery(http.MethodGet, "http://localhost:4000/kv/layers/development/broker", "read", nil, nil)
+	tests.MaybeFail("layer_scope", err, tests.Expect(statusCode, http.StatusForbidden))
+
+	// the scope is required to change the configs
+	statusCode, err = jsonQuery(http.MethodPut, "http://localhost:4000/kv/layers/development", "read",
+		SetLayerRequest{Values: map[string]interface{}{"obs.sampling": 0}}, nil)
+	tests.MaybeFail("set_scope", err, tests.Expect(statusCode, http.StatusForbidden))
+
+	statusCode, err = jsonQuery(http.MethodPut, "http://localhost:4000/kv/layers/a/b/c/d", "config",
+		SetLayerRequest{Values: map[string]interface{}{"obs.sampling": 0}}, nil)
+	tests.MaybeFail("set_layer_invalid", err, tests.Expect(statusCode, http.StatusBadRequest))
+}
+
+func Test_kv_history_and_rollback(t *testing.T) {
+	tests.MaybeFail = tests.InitFailFunc(t)
+	t.Cleanup(resetKVStore)
+
+	for _, interval := range []interface{}{20, 5, nil} {
+		jsonQuery(http.MethodPut, "http://localhost:4000/kv/layers/development/broker", "config",
+			SetLayerRequest{Values: map[string]interface{}{"health.interval": interval}}, nil)
+	}
+
+	statusCode, err := jsonQuery(http.MethodGet, "http://localhost:4000/kv/history", "read", nil, nil)
+	tests.MaybeFail("history_scope", err, tests.Expect(statusCode, http.StatusForbidden))
+
+	history := HistoryResponse{}
+	statusCode, err = jsonQuery(http.MethodGet, "http://localhost:4000/kv/history", "config", nil, &history)
+	tests.MaybeFail("history", err,
+		tests.Expect(statusCode, http.StatusOK),
+		tests.Expect(len(history.Revisions), 4),
+		tests.Expect(history.Revisions[0].Number, 3),
+		tests.Expect(history.Revisions[0].Changes[0].Old, 5.0),
+	)
+
+	rev := kvstore.Revision{}
+	statusCode, err = jsonQuery(http.MethodPost, "http://localhost:4000/kv/rollback/1", "config", nil, &rev)
+	layer := LayerResponse{}
+	jsonQuery(http.MethodGet, "http://localhost:4000/kv/layers/development/broker", "config", nil, &layer)
+	tests.MaybeFail("rollback", err,
+		tests.Expect(statusCode, http.StatusOK),
+		tests.Expect(rev.Number, 4),
+		tests.Expect(layer.Values["health.interval"], 20.0),
+	)
+
+	statusCode, err = jsonQuery(http.MethodPost, "http://localhost:4000/kv/rollback/42", "config", nil, nil)
+	tests.MaybeFail("rollback_unknown", err, tests.Expect(statusCode, http.StatusNotFound))
+}
@@ -0,0 +1,123 @@
+package rest
+
+import (
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"fmt"
+	e "gitlab.com/grpasr/common/errors/json"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// the digests by file path, a file is hashed again only if its size or
+// modification time changed
+var digestCache sync.Map
+
+type cachedDigest struct {
+	size    int64
+	modTime time.Time
+	length  int64 // the size sent, a sealed secret is sent decrypted
+	sum     []byte
+}
+
+type manifestFile struct {
+	Name   string `json:"name"`
+	Size   int64  `json:"size"`
+	Digest string `json:"digest"`
+	sum    []byte
+}
+
+// manifest describe the files of a download. Digest is the sha256 of the
+// `sha256sum` lines of the files ordered by name("<hex>  <name>\n"), a
+// client compute it from its own copies to know if they are up to date
+type manifest struct {
+	Digest string         `json:"digest"`
+	Files  []manifestFile `json:"files"`
+}
+
+// newManifest hash the files, their sum is set for the Digest of their part
+func newManifest(files []agentFile) (*manifest, e.IError) {
+	m := &manifest{Files: make([]manifestFile, 0, len(files))}
+	for i, f := range files {
+		size, sum, err := fileDigest(f)
+		if err != nil {
+			return nil, e.NewCustomHTTPStatus(e.StatusInternalServerError, "", err.Error())
+		}
+		files[i].sum = sum
+		m.Files = append(m.Files, manifestFile{
+			Name:   f.name,
+			Size:   size,
+			Digest: "sha256:" + hex.EncodeToString(sum),
+			sum:    sum,
+		})
+	}
+	// the configs of several directories may have the same name
+	sort.Slice(m.Files, func(i, j int) bool {
+		if m.Files[i].Name != m.Files[j].Name {
+			return m.Files[i].Name < m.Files[j].Name
+		}
+		return m.Files[i].Digest < m.Files[j].Digest
+	})
+
+	h := sha256.New()
+	for _, f := range m.Files {
+		fmt.Fprintf(h, "%s  %s\n", hex.EncodeToString(f.sum), f.Name)
+	}
+	m.Digest = "sha256:" + hex.EncodeToString(h.Sum(nil))
+
+	return m, nil
+}
+
+// etag is the strong ETag of the download, the hex of Digest, suffixed
+// by the format for the archives as they are other representations
+func (m *manifest) etag(format string) string {
+	tag := strings.TrimPrefix(m.Digest, "sha256:")
+	if format != formatMultipart {
+		tag += "." + format
+	}
+	return `"` + tag + `"`
+}
+
+// digestHeader is the Digest header(rfc3230) of a file's part
+func digestHeader(sum []byte) string {
+	return "sha-256=" + base64.StdEncoding.EncodeToString(sum)
+}
+
+func fileDigest(f agentFile) (int64, []byte, error) {
+	fi, err := os.Stat(f.path)
+	if err != nil {
+		return 0, nil, err
+	}
+	if c, ok := digestCache.Load(f.path); ok {
+		cd := c.(cachedDigest)
+		if cd.size == fi.Size() && cd.modTime.Equal(fi.ModTime()) {
+			return cd.length, cd.sum, nil
+		}
+	}
+
+	content, err := readAgentFile(f)
+	if err != nil {
+		return 0, nil, err
+	}
+	h := sha256.Sum256(content)
+	sum := h[:]
+	length := int64(len(content))
+	digestCache.Store(f.path, cachedDigest{size: fi.Size(), modTime: fi.ModTime(), length: length, sum: sum})
+
+	return length, sum, nil
+}
+
+// etagMatch is true if the If-None-Match header list the etag or is *
+func etagMatch(ifNoneMatch, etag string) bool {
+	for _, candidate := range strings.Split(ifNoneMatch, ",") {
+		candidate = strings.TrimPrefix(strings.TrimSpace(candidate), "W/")
+		if candidate == "*" || candidate == etag {
+			return true
+		}
+	}
+	return false
+}
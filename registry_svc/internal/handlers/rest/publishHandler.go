@@ -0,0 +1,418 @@
+package rest
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/gorilla/mux"
+	"gitlab.com/grpasr/asonrythme/registry_svc/internal/compat"
+	e "gitlab.com/grpasr/common/errors/json"
+	"go/parser"
+	"go/token"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// the files of a package, the name start with the capitalized type so
+// GET /{version}/{package}/{type} can filter on it
+var publishFileRegexp = regexp.MustCompile(`^[A-Z][a-zA-Z0-9]*(\.proto|_grpc\.pb\.go|\.pb\.go)$`)
+
+// the package statement of a .proto, "package v1.name;"
+var protoPackageRegexp = regexp.MustCompile(`(?m)^\s*package\s+([a-zA-Z0-9_.]+)\s*;`)
+
+// one publication at a time, so a version is only published once and
+// checked against the versions published already
+var publishMu sync.Mutex
+
+// PublishResponse is returned once the files are downloadable, Changes
+// is the diff with the Previous version
+type PublishResponse struct {
+	Version  string          `json:"version"`
+	Package  string          `json:"package"`
+	Files    []string        `json:"files"`
+	Previous string          `json:"previous,omitempty"`
+	Changes  []compat.Change `json:"changes,omitempty"`
+}
+
+// BreakingResponse is returned when a version break the previous one
+// without bumping the major, or is broken by the Next one of its major
+type BreakingResponse struct {
+	Error    string          `json:"error"`
+	Previous string          `json:"previous,omitempty"`
+	Next     string          `json:"next,omitempty"`
+	Changes  []compat.Change `json:"changes"`
+}
+
+// errVersionExists is returned on a second publication of a version
+var errVersionExists = errors.New("the version is already published")
+
+// publishHandler upload the files of a package for an exact version as
+// v1.2.3, the multipart form hold them in "files". All the files are
+// validated, then they are downloadable at once. A published version is
+// immutable, a fix is published as a new version
+func publishHandler() func(w http.ResponseWriter, r *http.Request) {
+	return func(w http.ResponseWriter, r *http.Request) {
+		vars := mux.Vars(r)
+		p := vars["package"]
+
+		if !hasScope(r, restConfigs.RESTGetPublishScope()) {
+			writeJSONError(w, e.NewCustomHTTPStatus(e.StatusForbidden, r.URL.Path,
+				fmt.Sprintf("the scope %s is required to publish", restConfigs.RESTGetPublishScope())))
+			return
+		}
+
+		v, full, ok := parseSemver(vars["version"])
+		if !ok || !full {
+			writeJSONError(w, e.NewCustomHTTPStatus(e.StatusBadRequest, r.URL.Path,
+				fmt.Sprintf("invalid version %s, a version is published as v1.2.3", vars["version"])))
+			return
+		}
+
+		// fail early, writePackage check it again
+		if isPublished(p, v) {
+			writeJSONError(w, e.NewCustomHTTPStatus(e.StatusConflict, r.URL.Path,
+				fmt.Sprintf("%s of %s: %v", v, p, errVersionExists)))
+			return
+		}
+
+		files, ce := readPublishedFiles(w, r, v, p)
+		if ce != nil {
+			writeJSONError(w, ce)
+			return
+		}
+
+		// the files are well formed but the protos must compile
+		if _, err := compat.Compile(files); err != nil {
+			writeJSONError(w, e.NewCustomHTTPStatus(http.StatusUnprocessableEntity, r.URL.Path, err.Error()))
+			return
+		}
+
+		// the compatibility is checked and the package written under the
+		// same lock, two versions compatible with the previous one but not
+		// with each other can not both be published
+		publishMu.Lock()
+		defer publishMu.Unlock()
+
+		// the wire must stay compatible within a major, the publication is
+		// refused if the check can not be done
+		prev, report, ce := compareWithPrevious(p, v, files)
+		if ce != nil {
+			writeJSONError(w, ce)
+			return
+		}
+		if report != nil && report.IsBreaking() && prev.major == v.major {
+			fmt.Printf("registry_svc, publishHandler.go, %s/%s rejected, it break %s\n", v, p, prev)
+
+			writeBreaking(w, BreakingResponse{
+				Error:    fmt.Sprintf("%s break %s, publish it as v%d.0.0 or keep the wire compatible", v, prev, v.major+1),
+				Previous: prev.String(),
+				Changes:  report.Breaking(),
+			})
+			return
+		}
+
+		// a version published in the middle of a major(v1.1.5 once v1.2.0
+		// is) must not be broken by the next one
+		next, nextReport, ce := compareWithNext(p, v, files)
+		if ce != nil {
+			writeJSONError(w, ce)
+			return
+		}
+		if nextReport != nil && nextReport.IsBreaking() {
+			fmt.Printf("registry_svc, publishHandler.go, %s/%s rejected, %s break it\n", v, p, next)
+
+			writeBreaking(w, BreakingResponse{
+				Error:   fmt.Sprintf("%s, published already, break %s, keep the wire of %s compatible with %s", next, v, v, next),
+				Next:    next.String(),
+				Changes: nextReport.Breaking(),
+			})
+			return
+		}
+
+		err := writePackage(v, p, files)
+		if errors.Is(err, errVersionExists) {
+			writeJSONError(w, e.NewCustomHTTPStatus(e.StatusConflict, r.URL.Path,
+				fmt.Sprintf("%s of %s: %v", v, p, err)))
+			return
+		}
+		if err != nil {
+			writeJSONError(w, e.NewCustomHTTPStatus(e.StatusInternalServerError, "", err.Error()))
+			return
+		}
+
+		names := make([]string, 0, len(files))
+		for name := range files {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+
+		fmt.Printf("registry_svc, publishHandler.go, %s/%s published: %v\n", v, p, names)
+
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusCreated)
+		resp := PublishResponse{Version: v.String(), Package: p, Files: names}
+		if report != nil {
+			resp.Previous = prev.String()
+			resp.Changes = report.Changes
+		}
+		json.NewEncoder(w).Encode(resp)
+	}
+}
+
+// compareWithPrevious compare the files with the ones of the highest
+// version lower than v, the report is nil only if there is none. An
+// error is returned if the previous version can not be read or compiled
+func compareWithPrevious(p string, v semver, files map[string][]byte) (semver, *compat.Report, e.IError) {
+	versions, err := packageVersions(p)
+	if err != nil {
+		fmt.Printf("registry_svc, publishHandler.go, the versions of %s can not be read: %v\n", p, err)
+		return semver{}, nil, e.NewCustomHTTPStatus(e.StatusInternalServerError, "", err.Error())
+	}
+	var prev *semver
+	for i := range versions {
+		if versions[i].less(v) {
+			prev = &versions[i]
+		}
+	}
+	if prev == nil {
+		return semver{}, nil, nil
+	}
+
+	prevFiles, ce := readPublishedProtos(p, *prev)
+	if ce != nil {
+		return *prev, nil, ce
+	}
+
+	// the new files are compiled already, the previous ones are broken
+	report, err := compat.Compare(prevFiles, files)
+	if err != nil {
+		fmt.Printf("registry_svc, publishHandler.go, %s/%s not compared with %s: %v\n", v, p, prev, err)
+		return *prev, nil, e.NewCustomHTTPStatus(e.StatusInternalServerError, "",
+			fmt.Sprintf("%s can not be compared with %s", v, prev))
+	}
+
+	return *prev, report, nil
+}
+
+// compareWithNext compare the files with the ones of the lowest version
+// of v's major higher than v, the report is nil only if there is none.
+// An error is returned if the next version can not be read or compiled
+func compareWithNext(p string, v semver, files map[string][]byte) (semver, *compat.Report, e.IError) {
+	versions, err := packageVersions(p)
+	if err != nil {
+		fmt.Printf("registry_svc, publishHandler.go, the versions of %s can not be read: %v\n", p, err)
+		return semver{}, nil, e.NewCustomHTTPStatus(e.StatusInternalServerError, "", err.Error())
+	}
+	var next *semver
+	for i := len(versions) - 1; i >= 0; i-- {
+		if v.less(versions[i]) {
+			next = &versions[i]
+		}
+	}
+	if next == nil || next.major != v.major {
+		return semver{}, nil, nil
+	}
+
+	nextFiles, ce := readPublishedProtos(p, *next)
+	if ce != nil {
+		return *next, nil, ce
+	}
+
+	report, err := compat.Compare(files, nextFiles)
+	if err != nil {
+		fmt.Printf("registry_svc, publishHandler.go, %s/%s not compared with %s: %v\n", v, p, next, err)
+		return *next, nil, e.NewCustomHTTPStatus(e.StatusInternalServerError, "",
+			fmt.Sprintf("%s can not be compared with %s", v, next))
+	}
+
+	return *next, report, nil
+}
+
+// readPublishedProtos return the .proto files of a published version by name
+func readPublishedProtos(p string, v semver) (map[string][]byte, e.IError) {
+	dir := filepath.Join(restConfigs.RESTGetPathToStorage(), restConfigs.RESTGetGrpcDirectory(), v.dir, p)
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		fmt.Printf("registry_svc, publishHandler.go, can not read %s: %v\n", dir, err)
+		return nil, e.NewCustomHTTPStatus(e.StatusInternalServerError, "", err.Error())
+	}
+	protos := map[string][]byte{}
+	for _, entry := range entries {
+		if !strings.HasSuffix(entry.Name(), ".proto") {
+			continue
+		}
+		content, err := os.ReadFile(filepath.Join(dir, entry.Name()))
+		if err != nil {
+			fmt.Printf("registry_svc, publishHandler.go, can not read %s: %v\n", entry.Name(), err)
+			return nil, e.NewCustomHTTPStatus(e.StatusInternalServerError, "", err.Error())
+		}
+		protos[entry.Name()] = content
+	}
+	return protos, nil
+}
+
+// isPublished is true if the package has the version, v1 of the former
+// layout being v1.0.0
+func isPublished(p string, v semver) bool {
+	versions, _ := packageVersions(p)
+	for _, pv := range versions {
+		if pv.String() == v.String() {
+			return true
+		}
+	}
+	return false
+}
+
+// readPublishedFiles read and validate the uploaded files, they are
+// returned by name
+func readPublishedFiles(w http.ResponseWriter, r *http.Request, v semver, p string) (map[string][]byte, e.IError) {
+	maxSize := restConfigs.RESTGetPublishMaxSize()
+	r.Body = http.MaxBytesReader(w, r.Body, maxSize)
+	if err := r.ParseMultipartForm(maxSize); err != nil {
+		var mbe *http.MaxBytesError
+		if errors.As(err, &mbe) {
+			return nil, e.NewCustomHTTPStatus(e.StatusBadRequest, r.URL.Path,
+				fmt.Sprintf("the upload exceed %d bytes", maxSize))
+		}
+		return nil, e.NewCustomHTTPStatus(e.StatusBadRequest, r.URL.Path, err.Error())
+	}
+	defer r.MultipartForm.RemoveAll()
+
+	headers := r.MultipartForm.File["files"]
+	if len(headers) == 0 {
+		return nil, e.NewCustomHTTPStatus(e.StatusBadRequest, r.URL.Path, "no files to publish")
+	}
+
+	files := make(map[string][]byte, len(headers))
+	hasProto := false
+	for _, fh := range headers {
+		name := fh.Filename
+		if !publishFileRegexp.MatchString(name) {
+			return nil, e.NewCustomHTTPStatus(e.StatusBadRequest, r.URL.Path,
+				fmt.Sprintf("invalid file name %q, expected Type.proto, Type.pb.go or Type_grpc.pb.go", name))
+		}
+		if _, ok := files[name]; ok {
+			return nil, e.NewCustomHTTPStatus(e.StatusBadRequest, r.URL.Path, "duplicated file "+name)
+		}
+
+		f, err := fh.Open()
+		if err != nil {
+			return nil, e.NewCustomHTTPStatus(e.StatusBadRequest, r.URL.Path, err.Error())
+		}
+		content, err := io.ReadAll(f)
+		f.Close()
+		if err != nil {
+			return nil, e.NewCustomHTTPStatus(e.StatusBadRequest, r.URL.Path, err.Error())
+		}
+		if len(content) == 0 {
+			return nil, e.NewCustomHTTPStatus(e.StatusBadRequest, r.URL.Path, "empty file "+name)
+		}
+
+		if err := validateFile(name, content, v, p); err != nil {
+			return nil, e.NewCustomHTTPStatus(e.StatusBadRequest, r.URL.Path, err.Error())
+		}
+
+		if strings.HasSuffix(name, ".proto") {
+			hasProto = true
+		}
+		files[name] = content
+	}
+
+	if !hasProto {
+		return nil, e.NewCustomHTTPStatus(e.StatusBadRequest, r.URL.Path, "a package must have at least one .proto")
+	}
+
+	return files, nil
+}
+
+// validateFile check the file belong to the package, the .proto declare
+// "package v{major}.{package};"(or v{major}_{package}) and the go files
+// "package {package}"
+func validateFile(name string, content []byte, v semver, p string) error {
+	protoPkg := fmt.Sprintf("v%d.%s", v.major, p)
+	if strings.HasSuffix(name, ".proto") {
+		m := protoPackageRegexp.FindSubmatch(content)
+		if m == nil {
+			return fmt.Errorf("%s has no package statement", name)
+		}
+		if string(m[1]) != protoPkg && string(m[1]) != strings.Replace(protoPkg, ".", "_", 1) {
+			return fmt.Errorf("%s declare the package %s, expected %s", name, m[1], protoPkg)
+		}
+		return nil
+	}
+
+	f, err := parser.ParseFile(token.NewFileSet(), name, content, parser.PackageClauseOnly)
+	if err != nil {
+		return fmt.Errorf("%s is not a valid go file: %v", name, err)
+	}
+	if f.Name.Name != p {
+		return fmt.Errorf("%s declare the package %s, expected %s", name, f.Name.Name, p)
+	}
+	return nil
+}
+
+// writePackage write the files in a temporary directory next to the
+// package's one, then rename it so the loaders never read a partial
+// package, publishMu must be held
+func writePackage(v semver, p string, files map[string][]byte) error {
+	versionDir := filepath.Join(restConfigs.RESTGetPathToStorage(), restConfigs.RESTGetGrpcDirectory(), v.String())
+	if err := os.MkdirAll(versionDir, 0755); err != nil {
+		return err
+	}
+
+	// the temporary directories are hidden, the routes only match letters
+	tmpDir, err := os.MkdirTemp(versionDir, "."+p+"-publish-")
+	if err != nil {
+		return err
+	}
+	defer os.RemoveAll(tmpDir)
+
+	for name, content := range files {
+		if err := writeFileSync(filepath.Join(tmpDir, name), content); err != nil {
+			return err
+		}
+	}
+	if err := os.Chmod(tmpDir, 0755); err != nil {
+		return err
+	}
+
+	if isPublished(p, v) {
+		return errVersionExists
+	}
+
+	return os.Rename(tmpDir, filepath.Join(versionDir, p))
+}
+
+func writeFileSync(path string, content []byte) error {
+	f, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0644)
+	if err != nil {
+		return err
+	}
+	if _, err := f.Write(content); err != nil {
+		f.Close()
+		return err
+	}
+	if err := f.Sync(); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+func writeBreaking(w http.ResponseWriter, br BreakingResponse) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusBadRequest)
+	json.NewEncoder(w).Encode(br)
+}
+
+func writeJSONError(w http.ResponseWriter, ce e.IError) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(ce.GetCode())
+	json.NewEncoder(w).Encode(ce)
+}
@@ -0,0 +1,292 @@
+package rest
+
+import (
+	"bytes"
+	"encoding/json"
+	"gitlab.com/grpasr/common/tests"
+	"mime/multipart"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+)
+
+const publishProto = `syntax = "proto3";
+
+package v1.publish;
+
+message Book {
+	string title = 1;
+}
+`
+
+func publishQuery(scope, version string, files map[string]string) (int, []byte, error) {
+	body := &bytes.Buffer{}
+	mw := multipart.NewWriter(body)
+	for name, content := range files {
+		part, _ := mw.CreateFormFile("files", name)
+		part.Write([]byte(content))
+	}
+	mw.Close()
+
+	req, _ := http.NewRequest(http.MethodPost, "http://localhost:4000/grpc/"+version+"/publish", body)
+	req.Header.Set("Content-Type", mw.FormDataContentType())
+	req.Header.Set("Authorization", "Bearer "+testToken(scope))
+
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return 0, nil, err
+	}
+	defer resp.Body.Close()
+
+	buf := &bytes.Buffer{}
+	_, err = buf.ReadFrom(resp.Body)
+	return resp.StatusCode, buf.Bytes(), err
+}
+
+// cleanPublished remove the package "publish" and the versions it left empty
+func cleanPublished() {
+	dirs, _ := filepath.Glob("../../../testsStorage/api/*/publish")
+	for _, dir := range dirs {
+		os.RemoveAll(dir)
+		os.Remove(filepath.Dir(dir))
+	}
+}
+
+func Test_grpc_publish_package(t *testing.T) {
+	tests.MaybeFail = tests.InitFailFunc(t)
+	t.Cleanup(cleanPublished)
+
+	statusCode, body, err := publishQuery("read, publish", "1.0.0", map[string]string{
+		"Book.proto": publishProto,
+		"Book.pb.go": "package publish\n",
+	})
+	resp := PublishResponse{}
+	json.Unmarshal(body, &resp)
+
+	tests.MaybeFail("publish", err,
+		tests.Expect(statusCode, http.StatusCreated),
+		tests.Expect(resp.Version, "v1.0.0"),
+		tests.Expect(len(resp.Files), 2),
+		tests.Expect(resp.Files[0], "Book.pb.go"),
+		tests.Expect(resp.Files[1], "Book.proto"),
+	)
+
+	statusCode, _, filesCount, _, err := cltQuery("http://localhost:4000/grpc/v1.0.0/publish")
+	tests.MaybeFail("publish_download", err,
+		tests.Expect(statusCode, http.StatusOK),
+		tests.Expect(filesCount, 2),
+	)
+
+	// a published version is immutable
+	statusCode, body, err = publishQuery("publish", "v1.0.0", map[string]string{"Book.proto": publishProto})
+	entries, _ := os.ReadDir("../../../testsStorage/api/v1.0.0/publish")
+
+	tests.MaybeFail("publish_immutable", err,
+		tests.Expect(statusCode, http.StatusConflict),
+		tests.Expect(json.Valid(body), true),
+		tests.Expect(len(entries), 2),
+	)
+}
+
+func Test_grpc_publish_invalid(t *testing.T) {
+	tests.MaybeFail = tests.InitFailFunc(t)
+	t.Cleanup(cleanPublished)
+
+	for name, files := range map[string]map[string]string{
+		"no_proto":      {"Book.pb.go": "package publish\n"},
+		"bad_name":      {"Book.txt": publishProto},
+		"lower_name":    {"book.proto": publishProto},
+		"empty":         {"Book.proto": ""},
+		"proto_package": {"Book.proto": "syntax = \"proto3\";\npackage v1.other;\n"},
+		"go_package":    {"Book.proto": publishProto, "Book.pb.go": "package other\n"},
+		"go_invalid":    {"Book.proto": publishProto, "Book.pb.go": "not go"},
+	} {
+		statusCode, _, err := publishQuery("publish", "v1.0.0", files)
+		tests.MaybeFail("publish_"+name, err, tests.Expect(statusCode, http.StatusBadRequest))
+	}
+
+	// the major of the version and of the proto package must match
+	statusCode, _, err := publishQuery("publish", "v2.0.0", map[string]string{"Book.proto": publishProto})
+	tests.MaybeFail("publish_major", err, tests.Expect(statusCode, http.StatusBadRequest))
+
+	// only an exact version is published
+	statusCode, _, err = publishQuery("publish", "v1.2", map[string]string{"Book.proto": publishProto})
+	tests.MaybeFail("publish_range", err, tests.Expect(statusCode, http.StatusBadRequest))
+
+	// the scope is required
+	statusCode, _, err = publishQuery("read, openid", "v1.0.0", map[string]string{"Book.proto": publishProto})
+	published, _ := filepath.Glob("../../../testsStorage/api/*/publish")
+
+	tests.MaybeFail("publish_scope", err,
+		tests.Expect(statusCode, http.StatusForbidden),
+		tests.Expect(len(published), 0),
+	)
+}
+
+func Test_grpc_versions_resolution(t *testing.T) {
+	tests.MaybeFail = tests.InitFailFunc(t)
+	t.Cleanup(cleanPublished)
+
+	for _, v := range []string{"v1.0.0", "v1.2.0", "v1.2.5", "v1.3.0-rc.1", "v2.0.0"} {
+		proto := strings.Replace(publishProto, "v1.publish", "v"+v[1:2]+".publish", 1)
+		statusCode, _, err := publishQuery("publish", v, map[string]string{"Book.proto": proto})
+		tests.MaybeFail("publish_"+v, err, tests.Expect(statusCode, http.StatusCreated))
+	}
+
+	req, _ := http.NewRequest(http.MethodGet, "http://localhost:4000/grpc/publish/versions", nil)
+	req.Header.Set("Authorization", "Bearer "+testToken("read"))
+	resp, err := http.DefaultClient.Do(req)
+	versions := VersionsResponse{}
+	if err == nil {
+		json.NewDecoder(resp.Body).Decode(&versions)
+		resp.Body.Close()
+	}
+
+	tests.MaybeFail("versions", err,
+		tests.Expect(resp.StatusCode, http.StatusOK),
+		tests.Expect(strings.Join(versions.Versions, ","), "v1.0.0,v1.2.0,v1.2.5,v1.3.0-rc.1,v2.0.0"),
+		tests.Expect(versions.Latest, "v2.0.0"),
+	)
+
+	for constraint, expected := range map[string]string{
+		"latest":      "v2.0.0",
+		"v1":          "v1.2.5",
+		"%5E1.0":      "v1.2.5",
+		"~1.2":        "v1.2.5",
+		"v1.2.0":      "v1.2.0",
+		"v1.3.0-rc.1": "v1.3.0-rc.1",
+	} {
+		resolved, ce := resolveVersion("publish", strings.Replace(constraint, "%5E", "^", 1))
+		statusCode, _, filesCount, _, err := cltQuery("http://localhost:4000/grpc/" + constraint + "/publish")
+		tests.MaybeFail("resolve_"+constraint, ce, err,
+			tests.Expect(resolved.String(), expected),
+			tests.Expect(statusCode, http.StatusOK),
+			tests.Expect(filesCount, 1),
+		)
+	}
+
+	statusCode, _, _, _, err := cltQuery("http://localhost:4000/grpc/v3/publish")
+	tests.MaybeFail("resolve_none", err, tests.Expect(statusCode, http.StatusNotFound))
+}
+
+func Test_grpc_publish_breaking_change(t *testing.T) {
+	tests.MaybeFail = tests.InitFailFunc(t)
+	t.Cleanup(cleanPublished)
+
+	statusCode, _, err := publishQuery("publish", "v1.0.0", map[string]string{"Book.proto": publishProto})
+	tests.MaybeFail("publish_v1", err, tests.Expect(statusCode, http.StatusCreated))
+
+	// renumbering a field break the readers of v1.0.0
+	renumbered := strings.Replace(publishProto, "string title = 1;", "string title = 2;", 1)
+	statusCode, body, err := publishQuery("publish", "v1.1.0", map[string]string{"Book.proto": renumbered})
+	rejected := BreakingResponse{}
+	json.Unmarshal(body, &rejected)
+
+	tests.MaybeFail("publish_breaking", err,
+		tests.Expect(statusCode, http.StatusBadRequest),
+		tests.Expect(rejected.Previous, "v1.0.0"),
+		tests.Expect(len(rejected.Changes), 1),
+		tests.Expect(rejected.Changes[0].Element, "Book.title"),
+		tests.Expect(rejected.Changes[0].Kind, "renumbered"),
+	)
+
+	// unless the major is bumped, the diff is then reported
+	renumbered = strings.Replace(renumbered, "v1.publish", "v2.publish", 1)
+	statusCode, body, err = publishQuery("publish", "v2.0.0", map[string]string{"Book.proto": renumbered})
+	resp := PublishResponse{}
+	json.Unmarshal(body, &resp)
+
+	tests.MaybeFail("publish_major", err,
+		tests.Expect(statusCode, http.StatusCreated),
+		tests.Expect(resp.Previous, "v1.0.0"),
+		tests.Expect(len(resp.Changes), 1),
+		tests.Expect(resp.Changes[0].Breaking, true),
+	)
+
+	// the protos must compile
+	statusCode, _, err = publishQuery("publish", "v2.1.0", map[string]string{
+		"Book.proto": strings.Replace(renumbered, "string title", "strin title", 1)})
+	tests.MaybeFail("publish_compile", err, tests.Expect(statusCode, http.StatusUnprocessableEntity))
+}
+
+func Test_grpc_publish_previous_unreadable(t *testing.T) {
+	tests.MaybeFail = tests.InitFailFunc(t)
+	t.Cleanup(cleanPublished)
+
+	statusCode, _, err := publishQuery("publish", "v1.0.0", map[string]string{"Book.proto": publishProto})
+	tests.MaybeFail("publish_v1", err, tests.Expect(statusCode, http.StatusCreated))
+
+	// the check of the breaking changes can not be skipped
+	os.WriteFile("../../../testsStorage/api/v1.0.0/publish/Book.proto", []byte("not a proto"), 0644)
+	statusCode, _, err = publishQuery("publish", "v1.1.0", map[string]string{"Book.proto": publishProto})
+	published, _ := filepath.Glob("../../../testsStorage/api/v1.1.0/publish")
+
+	tests.MaybeFail("publish_previous_broken", err,
+		tests.Expect(statusCode, http.StatusInternalServerError),
+		tests.Expect(len(published), 0),
+	)
+}
+
+func Test_grpc_publish_between_versions(t *testing.T) {
+	tests.MaybeFail = tests.InitFailFunc(t)
+	t.Cleanup(cleanPublished)
+
+	withAuthor := strings.Replace(publishProto, "string title = 1;", "string title = 1;\n\tstring author = 2;", 1)
+	statusCode, _, err := publishQuery("publish", "v1.1.0", map[string]string{"Book.proto": publishProto})
+	tests.MaybeFail("publish_v1.1.0", err, tests.Expect(statusCode, http.StatusCreated))
+	statusCode, _, err = publishQuery("publish", "v1.2.0", map[string]string{"Book.proto": withAuthor})
+	tests.MaybeFail("publish_v1.2.0", err, tests.Expect(statusCode, http.StatusCreated))
+
+	// compatible with v1.1.0 but v1.2.0 change the type of its field 2
+	withPages := strings.Replace(publishProto, "string title = 1;", "string title = 1;\n\tint32 pages = 2;", 1)
+	statusCode, body, err := publishQuery("publish", "v1.1.5", map[string]string{"Book.proto": withPages})
+	rejected := BreakingResponse{}
+	json.Unmarshal(body, &rejected)
+	published, _ := filepath.Glob("../../../testsStorage/api/v1.1.5/publish")
+
+	tests.MaybeFail("publish_broken_by_next", err,
+		tests.Expect(statusCode, http.StatusBadRequest),
+		tests.Expect(rejected.Next, "v1.2.0"),
+		tests.Expect(len(rejected.Changes) > 0, true),
+		tests.Expect(len(published), 0),
+	)
+
+	// a fix which v1.2.0 stay compatible with is published
+	statusCode, _, err = publishQuery("publish", "v1.1.5", map[string]string{"Book.proto": publishProto})
+	tests.MaybeFail("publish_fix", err, tests.Expect(statusCode, http.StatusCreated))
+}
+
+func Test_grpc_publish_concurrent(t *testing.T) {
+	tests.MaybeFail = tests.InitFailFunc(t)
+	t.Cleanup(cleanPublished)
+
+	statusCode, _, err := publishQuery("publish", "v1.1.0", map[string]string{"Book.proto": publishProto})
+	tests.MaybeFail("publish_v1.1.0", err, tests.Expect(statusCode, http.StatusCreated))
+
+	// both add the field 2 with an other type, each one is compatible with
+	// v1.1.0 but they break each other, only one can be published
+	withAuthor := strings.Replace(publishProto, "string title = 1;", "string title = 1;\n\tstring author = 2;", 1)
+	withPages := strings.Replace(publishProto, "string title = 1;", "string title = 1;\n\tint32 pages = 2;", 1)
+
+	var wg sync.WaitGroup
+	codes := make([]int, 2)
+	for i, pub := range []struct{ version, proto string }{{"v1.2.0", withAuthor}, {"v1.3.0", withPages}} {
+		wg.Add(1)
+		go func(i int, version, proto string) {
+			defer wg.Done()
+			codes[i], _, _ = publishQuery("publish", version, map[string]string{"Book.proto": proto})
+		}(i, pub.version, pub.proto)
+	}
+	wg.Wait()
+
+	created := 0
+	for _, code := range codes {
+		if code == http.StatusCreated {
+			created++
+		}
+	}
+	tests.MaybeFail("publish_concurrent", tests.Expect(created, 1))
+}
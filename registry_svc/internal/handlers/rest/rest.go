@@ -4,19 +4,27 @@ import (
 	"fmt"
 	"github.com/gorilla/mux"
 	"gitlab.com/grpasr/asonrythme/registry_svc/internal/config"
+	"gitlab.com/grpasr/asonrythme/registry_svc/internal/health"
 	"gitlab.com/grpasr/asonrythme/registry_svc/internal/services"
 	"net/http"
+	"path/filepath"
 )
 
 var restConfigs config.IRestConfig
 
-func Handler(configs config.IRestConfig, services *services.JWTokenService) *mux.Router {
+func Handler(configs config.IRestConfig, services *services.JWTokenService, hl health.IHealth) *mux.Router {
 	router := mux.NewRouter()
 
 	// set the configs as global in the package
 	restConfigs = configs
 
-	// handle all grpc schemas download
+	// which service may download which packages and configs
+	accessPolicy = newPolicy(filepath.Join(configs.RESTGetPathToStorage(), configs.RESTGetAccessPolicyFile()))
+
+	// the private keys are sealed, decrypted when they are served
+	loadSecrets()
+
+	// handle all grpc schemas download and publish
 	grpcRouter := router.PathPrefix("/grpc/").Subrouter()
 	NewGrpcHandler(grpcRouter).RunGrpcRest()
 
@@ -24,13 +32,22 @@ func Handler(configs config.IRestConfig, services *services.JWTokenService) *mux
 	configsRouter := router.PathPrefix("/configs/").Subrouter()
 	NewConfigsHandler(configsRouter).RunConfigsRest()
 
-	// healthcheck endpoint
-	router.HandleFunc("/v1/health", func(w http.ResponseWriter, r *http.Request) {
-		fmt.Println("registry_svc, reach the healthcheck..................")
-		w.WriteHeader(http.StatusOK)
-		w.Write([]byte("OK"))
-	})
-	// }).Name("healthcheck") // do nothing
+	// browse the packages and the configs bundles
+	catalogRouter := router.PathPrefix("/catalog/").Subrouter()
+	NewCatalogHandler(catalogRouter).RunCatalogRest()
+
+	// the services register their address, broker_svc resolve them
+	discoveryRouter := router.PathPrefix("/discovery/").Subrouter()
+	NewDiscoveryHandler(discoveryRouter).RunDiscoveryRest()
+
+	// the configuration of the services, layered by env, service and instance
+	kvRouter := router.PathPrefix("/kv/").Subrouter()
+	NewKVHandler(kvRouter).RunKVRest()
+
+	// healthcheck endpoints, /v1/health is kept as the liveness
+	router.HandleFunc("/v1/health", hl.LivenessHandler).Methods(http.MethodGet)
+	router.HandleFunc("/v1/health/live", hl.LivenessHandler).Methods(http.MethodGet)
+	router.HandleFunc("/v1/health/ready", hl.ReadinessHandler).Methods(http.MethodGet)
 
 	// middleware
 	// tokenService := services.NewTokenService() // is not a pointer
@@ -49,13 +66,29 @@ func serveFilesHandler(storageDir string, files ...string) func(w http.ResponseW
 		t := vars["type"]
 		rd := vars["repodir"]
 
+		// the service of the jwtoken must be allowed by the access policy
+		switch {
+		case p != "":
+			if !authorize(w, r, accessGrpc, p) {
+				return
+			}
+		case rd == "all":
+			if !authorize(w, r, accessConfigs, accessAny) {
+				return
+			}
+		case rd != "":
+			if !authorize(w, r, accessConfigs, rd) {
+				return
+			}
+		}
+
 		ah, err := NewAgentsHandler(w, files, storageDir, v, p, t, rd)
 		if err != nil {
 			http.Error(w, err.Error(), err.GetCode())
 			return
 		}
 
-		err = ah.run()
+		err = ah.run(r)
 		if err != nil {
 			w.WriteHeader(err.GetCode())
 			fmt.Fprint(w, err.Error())
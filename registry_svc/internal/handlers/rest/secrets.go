@@ -0,0 +1,67 @@
+package rest
+
+import (
+	"fmt"
+	"gitlab.com/grpasr/asonrythme/registry_svc/internal/secrets"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// the master keys opening the sealed private keys, nil if none is set
+var secretsKeyRing *secrets.KeyRing
+
+// loadSecrets read the master keys and warn about the private keys left
+// in cleartext in the configs, those are not served
+func loadSecrets() {
+	kr, err := secrets.LoadKeyRing(restConfigs.RESTGetSecretsMasterKey(), restConfigs.RESTGetSecretsMasterKeyFile())
+	if err != nil {
+		fmt.Printf("registry_svc, secrets.go, the sealed secrets can not be served: %v\n", err)
+	}
+	secretsKeyRing = kr
+
+	cleartext, err := secrets.ScanDir(secretsRoot())
+	if err != nil {
+		fmt.Printf("registry_svc, secrets.go, the configs are not scanned: %v\n", err)
+	}
+	for _, path := range cleartext {
+		fmt.Printf("registry_svc, secrets.go, %s is a cleartext private key, seal it with secretsctl import\n", path)
+	}
+}
+
+// secretsRoot is the configs directory, the secrets are named after their
+// path under it
+func secretsRoot() string {
+	return filepath.Join(restConfigs.RESTGetPathToStorage(), restConfigs.RESTGetConfigsDirectory())
+}
+
+// newAgentFile is the file to send, a sealed secret is sent decrypted
+// under its own name
+func newAgentFile(name, rel, path string) agentFile {
+	if secrets.IsSealed(path) {
+		return agentFile{
+			name:   strings.TrimSuffix(name, secrets.Ext),
+			rel:    strings.TrimSuffix(rel, secrets.Ext),
+			path:   path,
+			sealed: true,
+		}
+	}
+	return agentFile{name: name, rel: rel, path: path}
+}
+
+// readAgentFile return the content to send, a private key is sent only if
+// it is sealed
+func readAgentFile(f agentFile) ([]byte, error) {
+	if f.sealed {
+		return secrets.ReadFile(secretsKeyRing, secretsRoot(), f.path)
+	}
+
+	content, err := os.ReadFile(f.path)
+	if err != nil {
+		return nil, err
+	}
+	if secrets.IsPrivateKey(content) {
+		return nil, fmt.Errorf("%s is a cleartext private key, it is not served", f.name)
+	}
+	return content, nil
+}
@@ -3,10 +3,14 @@ package rest
 import (
 	"context"
 	"fmt"
+	"github.com/golang-jwt/jwt"
 	"gitlab.com/grpasr/asonrythme/registry_svc/internal/config"
+	"gitlab.com/grpasr/asonrythme/registry_svc/internal/health"
+	"gitlab.com/grpasr/asonrythme/registry_svc/internal/services"
 	"io"
 	"mime"
 	"mime/multipart"
+	"net"
 	"net/http"
 	"os"
 	"sync"
@@ -17,6 +21,25 @@ import (
 var server *http.Server
 var serverWg sync.WaitGroup
 
+// the master key sealing the private keys of testsStorage
+const testMasterKey = "tests:EukZ4DEgcFxU+7tgXw47bxA3fqWMvB+TC5eprKX5GU4="
+
+// the key the test tokens are signed with, as published by auth_svc
+const (
+	testKeyID     = "testKeyID"
+	testSecretKey = "mySecretKey"
+)
+
+// testKeySet implement the services.IKeySet with the test key only
+type testKeySet struct{}
+
+func (testKeySet) KeySetGet(ctx context.Context, keyID string) (string, error) {
+	if keyID != testKeyID {
+		return "", services.ErrUnknownKeyID
+	}
+	return testSecretKey, nil
+}
+
 func TestMain(m *testing.M) {
 
 	startServer()
@@ -31,6 +54,7 @@ func TestMain(m *testing.M) {
 
 func startServer() {
 
+	os.Setenv("SECRETS_MASTER_KEY", testMasterKey)
 	config.SetVarEnv()
 
 	c := config.NewConfig()
@@ -39,17 +63,24 @@ func startServer() {
 
 	rc := config.NewRestConfig()
 	rc.RESTSetPathToStorage("../../../testsStorage")
-	router := Handler(rc)
+	router := Handler(rc, services.NewJWTokenService(testKeySet{}), health.NewHealth(0))
 
 	server = &http.Server{
 		Addr:    fmt.Sprintf("%s:%s", address, port),
 		Handler: router,
 	}
 
+	// listen before the tests run, the requests are queued until Serve
+	ln, err := net.Listen("tcp", server.Addr)
+	if err != nil {
+		fmt.Printf("registry_svc, setup_test.go, the test server can not listen: %v\n", err)
+		os.Exit(1)
+	}
+
 	serverWg.Add(1)
 	go func() {
 		defer serverWg.Done()
-		if err := server.ListenAndServe(); err != http.ErrServerClosed {
+		if err := server.Serve(ln); err != http.ErrServerClosed {
 			// Handle other errors if needed
 		}
 	}()
@@ -68,7 +99,10 @@ func stopServer() {
 
 func cltQuery(endpoint string) (statusCode int, respBody string, filesCount int, filesName []string, err error) {
 	// func cltQuery(endpoint string) {
-	resp, queryErr := http.Get(endpoint)
+	req, _ := http.NewRequest(http.MethodGet, endpoint, nil)
+	req.Header.Set("Authorization", "Bearer "+testToken("read, openid"))
+
+	resp, queryErr := http.DefaultClient.Do(req)
 	if queryErr != nil {
 		fmt.Printf("Error making GET request to serving server: %v\n", queryErr)
 		return
@@ -132,6 +166,23 @@ func cltQuery(endpoint string) (statusCode int, respBody string, filesCount int,
 	return
 }
 
+// testToken sign a jwtoken as auth_svc does for a service with the scope
+func testToken(scope string) string {
+	return testTokenFor("testSvc", scope)
+}
+
+// testTokenFor sign a jwtoken for the service svc
+func testTokenFor(svc, scope string) string {
+	token := jwt.NewWithClaims(jwt.SigningMethodHS256, jwt.MapClaims{
+		"sub":        svc,
+		"exp":        time.Now().Add(time.Minute).Unix(),
+		"openidInfo": map[string]interface{}{"role": "admin", "scope": scope},
+	})
+	token.Header["kid"] = testKeyID
+	signed, _ := token.SignedString([]byte(testSecretKey))
+	return signed
+}
+
 // Extract the boundary from the content type
 func boundaryFromContentType(contentType string) string {
 	_, params, err := mime.ParseMediaType(contentType)
@@ -0,0 +1,244 @@
+package rest
+
+import (
+	"encoding/json"
+	"fmt"
+	"github.com/gorilla/mux"
+	e "gitlab.com/grpasr/common/errors/json"
+	"net/http"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// the version of a route, an exact one as v1.2.3, a range as ^1.2 or ~1.2,
+// a major as v1(the former layout), or latest
+const versionPattern = `[0-9a-zA-Z.^~+-]+`
+
+const versionLatest = "latest"
+
+// v1.2.3-rc.1, the minor and patch are optional so the former v1 stay valid
+var semverRegexp = regexp.MustCompile(`^v?(0|[1-9][0-9]*)(?:\.(0|[1-9][0-9]*))?(?:\.(0|[1-9][0-9]*))?(?:-([0-9A-Za-z.-]+))?$`)
+
+// VersionsResponse list the versions of a package from the oldest, latest
+// is the highest release(empty if there are only pre-releases)
+type VersionsResponse struct {
+	Package  string   `json:"package"`
+	Versions []string `json:"versions"`
+	Latest   string   `json:"latest"`
+}
+
+// semver is a version of a package, dir is its directory in the storage
+type semver struct {
+	major, minor, patch int
+	pre                 string
+	dir                 string
+}
+
+// parseSemver parse v1.2.3, 1.2.3, v1.2 or v1, the missing parts are 0.
+// full is true if the minor and the patch are set
+func parseSemver(s string) (v semver, full bool, ok bool) {
+	m := semverRegexp.FindStringSubmatch(s)
+	if m == nil {
+		return v, false, false
+	}
+	v.major, _ = strconv.Atoi(m[1])
+	if m[2] != "" {
+		v.minor, _ = strconv.Atoi(m[2])
+	}
+	if m[3] != "" {
+		v.patch, _ = strconv.Atoi(m[3])
+	}
+	v.pre = m[4]
+	v.dir = s
+	return v, m[2] != "" && m[3] != "", true
+}
+
+func (v semver) String() string {
+	s := fmt.Sprintf("v%d.%d.%d", v.major, v.minor, v.patch)
+	if v.pre != "" {
+		s += "-" + v.pre
+	}
+	return s
+}
+
+// less order the versions, a pre-release is before its release
+func (v semver) less(o semver) bool {
+	if v.major != o.major {
+		return v.major < o.major
+	}
+	if v.minor != o.minor {
+		return v.minor < o.minor
+	}
+	if v.patch != o.patch {
+		return v.patch < o.patch
+	}
+	if v.pre == "" || o.pre == "" {
+		return v.pre != "" && o.pre == ""
+	}
+	return lessPre(v.pre, o.pre)
+}
+
+// lessPre compare the pre-releases by their dot separated identifiers,
+// the numeric ones numerically and before the alphanumeric ones
+func lessPre(a, b string) bool {
+	as, bs := strings.Split(a, "."), strings.Split(b, ".")
+	for i := 0; i < len(as) && i < len(bs); i++ {
+		if as[i] == bs[i] {
+			continue
+		}
+		an, aErr := strconv.Atoi(as[i])
+		bn, bErr := strconv.Atoi(bs[i])
+		switch {
+		case aErr == nil && bErr == nil:
+			return an < bn
+		case aErr == nil || bErr == nil:
+			return aErr == nil
+		default:
+			return as[i] < bs[i]
+		}
+	}
+	return len(as) < len(bs)
+}
+
+// packageVersions return the versions of the package found in the
+// storage, ordered from the oldest
+func packageVersions(pkg string) ([]semver, error) {
+	grpcDir := filepath.Join(restConfigs.RESTGetPathToStorage(), restConfigs.RESTGetGrpcDirectory())
+	entries, err := os.ReadDir(grpcDir)
+	if err != nil {
+		return nil, err
+	}
+
+	versions := []semver{}
+	seen := map[string]bool{}
+	for _, entry := range entries {
+		if !entry.IsDir() {
+			continue
+		}
+		v, _, ok := parseSemver(entry.Name())
+		if !ok {
+			continue
+		}
+		fi, err := os.Stat(filepath.Join(grpcDir, entry.Name(), pkg))
+		if err != nil || !fi.IsDir() {
+			continue
+		}
+		// v1 and v1.0.0 are the same, the full one win
+		if seen[v.String()] {
+			if strings.Count(entry.Name(), ".") < 2 {
+				continue
+			}
+			for i := range versions {
+				if versions[i].String() == v.String() {
+					versions = append(versions[:i], versions[i+1:]...)
+					break
+				}
+			}
+		}
+		seen[v.String()] = true
+		versions = append(versions, v)
+	}
+
+	sort.SliceStable(versions, func(i, j int) bool { return versions[i].less(versions[j]) })
+	return versions, nil
+}
+
+// resolveVersion return the version of the package matching the
+// constraint, the highest one if several do. The pre-releases only match
+// an exact version
+func resolveVersion(pkg, constraint string) (semver, e.IError) {
+	versions, err := packageVersions(pkg)
+	if err != nil {
+		return semver{}, e.NewCustomHTTPStatus(e.StatusInternalServerError, "", err.Error())
+	}
+
+	match, ce := versionMatcher(constraint)
+	if ce != nil {
+		return semver{}, ce
+	}
+
+	for i := len(versions) - 1; i >= 0; i-- {
+		if match(versions[i]) {
+			return versions[i], nil
+		}
+	}
+
+	return semver{}, e.NewCustomHTTPStatus(e.StatusNotFound,
+		fmt.Sprintf("%s/%s", constraint, pkg), "no version matching "+constraint)
+}
+
+// versionMatcher return the func matching the versions of the constraint:
+// latest, v1.2.3(exact), ^1.2(>=1.2.0 <2.0.0), ~1.2(>=1.2.0 <1.3.0),
+// v1(^1) or v1.2(~1.2)
+func versionMatcher(constraint string) (func(semver) bool, e.IError) {
+	if constraint == versionLatest {
+		return func(v semver) bool { return v.pre == "" }, nil
+	}
+
+	op := ""
+	if strings.HasPrefix(constraint, "^") || strings.HasPrefix(constraint, "~") {
+		op, constraint = constraint[:1], constraint[1:]
+	}
+	c, full, ok := parseSemver(constraint)
+	if !ok {
+		return nil, e.NewCustomHTTPStatus(e.StatusBadRequest, constraint, "invalid version "+op+constraint)
+	}
+
+	if op == "" {
+		switch {
+		case full:
+			return func(v semver) bool { return v.String() == c.String() }, nil
+		case strings.Count(constraint, ".") == 0:
+			op = "^"
+		default:
+			op = "~"
+		}
+	}
+
+	// as npm, ^0.2 does not allow the minor to change
+	if op == "^" && c.major == 0 {
+		op = "~"
+	}
+
+	return func(v semver) bool {
+		if v.pre != "" || v.less(c) || v.major != c.major {
+			return false
+		}
+		return op == "^" || v.minor == c.minor
+	}, nil
+}
+
+// versionsHandler list the versions of a package
+func versionsHandler() func(w http.ResponseWriter, r *http.Request) {
+	return func(w http.ResponseWriter, r *http.Request) {
+		p := mux.Vars(r)["package"]
+		if !authorize(w, r, accessGrpc, p) {
+			return
+		}
+
+		versions, err := packageVersions(p)
+		if err != nil {
+			writeJSONError(w, e.NewCustomHTTPStatus(e.StatusInternalServerError, "", err.Error()))
+			return
+		}
+		if len(versions) == 0 {
+			writeJSONError(w, e.NewCustomHTTPStatus(e.StatusNotFound, r.URL.Path, "unknown package "+p))
+			return
+		}
+
+		resp := VersionsResponse{Package: p, Versions: make([]string, 0, len(versions))}
+		for _, v := range versions {
+			resp.Versions = append(resp.Versions, v.String())
+			if v.pre == "" {
+				resp.Latest = v.String()
+			}
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(resp)
+	}
+}
@@ -0,0 +1,146 @@
+package health
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"sync"
+	"time"
+)
+
+const (
+	StatusUp   = "up"
+	StatusDown = "down"
+
+	checkTimeoutDefault = 3 * time.Second
+)
+
+// Check report the state of a dependency, it returns nil when the
+// dependency is usable
+type Check func(ctx context.Context) error
+
+// DependencyStatus is the result of a dependency's check
+type DependencyStatus struct {
+	Status  string `json:"status"`
+	Error   string `json:"error,omitempty"`
+	Latency string `json:"latency"`
+}
+
+// Report is the liveness or readiness state of the service
+type Report struct {
+	Status       string                      `json:"status"`
+	Dependencies map[string]DependencyStatus `json:"dependencies,omitempty"`
+}
+
+type IHealth interface {
+	HealthRegister(name string, check Check)
+	HealthLiveness() Report
+	HealthReadiness(ctx context.Context) Report
+	LivenessHandler(w http.ResponseWriter, r *http.Request)
+	ReadinessHandler(w http.ResponseWriter, r *http.Request)
+}
+
+type check struct {
+	name  string
+	check Check
+}
+
+// Health run the dependencies' checks, the readiness is ok when all of them are
+type Health struct {
+	timeout time.Duration
+	checks  []check
+	sync.RWMutex
+}
+
+// NewHealth set the checks' timeout, default to 3s when timeout <= 0
+func NewHealth(timeout time.Duration) *Health {
+	if timeout <= 0 {
+		timeout = checkTimeoutDefault
+	}
+	return &Health{
+		timeout: timeout,
+	}
+}
+
+// HealthRegister add a dependency to check for the readiness
+func (h *Health) HealthRegister(name string, c Check) {
+	h.Lock()
+	defer h.Unlock()
+	h.checks = append(h.checks, check{name: name, check: c})
+}
+
+// HealthLiveness is up as long as the process serve
+func (h *Health) HealthLiveness() Report {
+	return Report{Status: StatusUp}
+}
+
+// HealthReadiness run all checks concurrently
+func (h *Health) HealthReadiness(ctx context.Context) Report {
+	h.RLock()
+	checks := make([]check, len(h.checks))
+	copy(checks, h.checks)
+	h.RUnlock()
+
+	report := Report{
+		Status:       StatusUp,
+		Dependencies: make(map[string]DependencyStatus, len(checks)),
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, h.timeout)
+	defer cancel()
+
+	var mu sync.Mutex
+	var wg sync.WaitGroup
+	for _, c := range checks {
+		wg.Add(1)
+		go func(c check) {
+			defer wg.Done()
+			ds := runCheck(ctx, c.check)
+
+			mu.Lock()
+			report.Dependencies[c.name] = ds
+			if ds.Status == StatusDown {
+				report.Status = StatusDown
+			}
+			mu.Unlock()
+		}(c)
+	}
+	wg.Wait()
+
+	return report
+}
+
+func (h *Health) LivenessHandler(w http.ResponseWriter, r *http.Request) {
+	writeReport(w, h.HealthLiveness())
+}
+
+func (h *Health) ReadinessHandler(w http.ResponseWriter, r *http.Request) {
+	writeReport(w, h.HealthReadiness(r.Context()))
+}
+
+func runCheck(ctx context.Context, c Check) DependencyStatus {
+	start := time.Now()
+	err := c(ctx)
+
+	ds := DependencyStatus{
+		Status:  StatusUp,
+		Latency: time.Since(start).String(),
+	}
+	if err != nil {
+		ds.Status = StatusDown
+		ds.Error = err.Error()
+	}
+	return ds
+}
+
+func writeReport(w http.ResponseWriter, report Report) {
+	status := http.StatusOK
+	if report.Status != StatusUp {
+		status = http.StatusServiceUnavailable
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", "no-store")
+	w.WriteHeader(status)
+	_ = json.NewEncoder(w).Encode(report)
+}
@@ -0,0 +1,66 @@
+package health
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"gitlab.com/grpasr/common/tests"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestHealthReadiness(t *testing.T) {
+	tests.MaybeFail = tests.InitFailFunc(t)
+
+	hl := NewHealth(time.Second)
+	hl.HealthRegister("storage", func(ctx context.Context) error { return nil })
+	hl.HealthRegister("jwtoken", func(ctx context.Context) error { return errors.New("token expired") })
+
+	report := hl.HealthReadiness(context.Background())
+	tests.MaybeFail("HealthReadiness",
+		tests.Expect(report.Status, StatusDown),
+		tests.Expect(report.Dependencies["storage"].Status, StatusUp),
+		tests.Expect(report.Dependencies["jwtoken"].Error, "token expired"))
+
+	rr := httptest.NewRecorder()
+	hl.ReadinessHandler(rr, httptest.NewRequest(http.MethodGet, "/v1/health/ready", nil))
+	var body Report
+	err := json.NewDecoder(rr.Body).Decode(&body)
+	tests.MaybeFail("ReadinessHandler", err,
+		tests.Expect(rr.Code, http.StatusServiceUnavailable),
+		tests.Expect(rr.Header().Get("Cache-Control"), "no-store"),
+		tests.Expect(body.Dependencies["jwtoken"].Status, StatusDown))
+
+	rr = httptest.NewRecorder()
+	hl.LivenessHandler(rr, httptest.NewRequest(http.MethodGet, "/v1/health/live", nil))
+	tests.MaybeFail("LivenessHandler", tests.Expect(rr.Code, http.StatusOK))
+}
+
+func TestHealthReadinessUp(t *testing.T) {
+	tests.MaybeFail = tests.InitFailFunc(t)
+
+	hl := NewHealth(time.Second)
+	hl.HealthRegister("storage", func(ctx context.Context) error { return nil })
+
+	rr := httptest.NewRecorder()
+	hl.ReadinessHandler(rr, httptest.NewRequest(http.MethodGet, "/v1/health/ready", nil))
+	tests.MaybeFail("ReadinessHandler_up", tests.Expect(rr.Code, http.StatusOK))
+}
+
+func TestHealthReadinessTimeout(t *testing.T) {
+	tests.MaybeFail = tests.InitFailFunc(t)
+
+	// a check which hang is down once the timeout is elapsed
+	hl := NewHealth(50 * time.Millisecond)
+	hl.HealthRegister("jwtoken", func(ctx context.Context) error {
+		<-ctx.Done()
+		return ctx.Err()
+	})
+
+	report := hl.HealthReadiness(context.Background())
+	tests.MaybeFail("HealthReadiness_timeout",
+		tests.Expect(report.Status, StatusDown),
+		tests.Expect(report.Dependencies["jwtoken"].Error, context.DeadlineExceeded.Error()))
+}
@@ -0,0 +1,373 @@
+package kvstore
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"reflect"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+var (
+	ErrNotFound = errors.New("unknown revision")
+	ErrInvalid  = errors.New("invalid configuration")
+)
+
+// the keys are dotted as "health.interval", the client nest them
+var keyRegexp = regexp.MustCompile(`^[a-zA-Z0-9_-]+(\.[a-zA-Z0-9_-]+)*$`)
+
+// the env, service and instance names of a layer
+var layerNameRegexp = regexp.MustCompile(`^[a-zA-Z0-9_-]+$`)
+
+const globalLayer = "global"
+
+// Layer is a level of the configuration, global if empty, then an
+// environment, a service of the environment and one of its instances.
+// The values of a layer override the ones of its parents
+type Layer struct {
+	Env      string
+	Service  string
+	Instance string
+}
+
+// ParseLayer parse "global", "development", "development/broker" or
+// "development/broker/broker-1"
+func ParseLayer(path string) (Layer, error) {
+	path = strings.Trim(path, "/")
+	if path == "" || path == globalLayer {
+		return Layer{}, nil
+	}
+
+	parts := strings.Split(path, "/")
+	if len(parts) > 3 {
+		return Layer{}, fmt.Errorf("%w: layer %s is too deep", ErrInvalid, path)
+	}
+	for _, p := range parts {
+		if !layerNameRegexp.MatchString(p) || p == globalLayer {
+			return Layer{}, fmt.Errorf("%w: layer %s", ErrInvalid, path)
+		}
+	}
+
+	l := Layer{Env: parts[0]}
+	if len(parts) > 1 {
+		l.Service = parts[1]
+	}
+	if len(parts) > 2 {
+		l.Instance = parts[2]
+	}
+	return l, nil
+}
+
+func (l Layer) String() string {
+	parts := []string{}
+	for _, p := range []string{l.Env, l.Service, l.Instance} {
+		if p == "" {
+			break
+		}
+		parts = append(parts, p)
+	}
+	if len(parts) == 0 {
+		return globalLayer
+	}
+	return strings.Join(parts, "/")
+}
+
+// chain is the layer and its parents, from global
+func (l Layer) chain() []Layer {
+	chain := []Layer{{}}
+	if l.Env != "" {
+		chain = append(chain, Layer{Env: l.Env})
+	}
+	if l.Env != "" && l.Service != "" {
+		chain = append(chain, Layer{Env: l.Env, Service: l.Service})
+	}
+	if l.Env != "" && l.Service != "" && l.Instance != "" {
+		chain = append(chain, l)
+	}
+	return chain
+}
+
+// Change is the change of a key in a revision, a nil Old is an added key
+// and a nil New a deleted one
+type Change struct {
+	Layer string      `json:"layer"`
+	Key   string      `json:"key"`
+	Old   interface{} `json:"old,omitempty"`
+	New   interface{} `json:"new,omitempty"`
+}
+
+// Revision is the state of the store after a change, the values are by
+// layer then by key
+type Revision struct {
+	Number  int                               `json:"revision"`
+	Time    time.Time                         `json:"time"`
+	Author  string                            `json:"author"`
+	Comment string                            `json:"comment"`
+	Changes []Change                          `json:"changes"`
+	Values  map[string]map[string]interface{} `json:"values"`
+}
+
+// Store is the versioned configuration, each change is a new revision
+// and the maxHistory last ones are kept for the rollbacks. It is
+// persisted in a json file if path is set, it is safe for concurrent use
+type Store struct {
+	mu         sync.RWMutex
+	path       string
+	maxHistory int
+	revisions  []Revision // from the oldest
+	now        func() time.Time
+}
+
+// Open read the store of path, a missing file is an empty store
+func Open(path string, maxHistory int) (*Store, error) {
+	if maxHistory < 1 {
+		maxHistory = 1
+	}
+	s := &Store{
+		path:       path,
+		maxHistory: maxHistory,
+		revisions:  []Revision{{Values: map[string]map[string]interface{}{}, Changes: []Change{}}},
+		now:        time.Now,
+	}
+	if path == "" {
+		return s, nil
+	}
+
+	content, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return s, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	revisions := []Revision{}
+	if err := json.Unmarshal(content, &revisions); err != nil {
+		return nil, fmt.Errorf("%s: %v", path, err)
+	}
+	if len(revisions) > 0 {
+		s.revisions = revisions
+	}
+	return s, nil
+}
+
+func (s *Store) latest() Revision {
+	return s.revisions[len(s.revisions)-1]
+}
+
+// Revision is the number of the current revision
+func (s *Store) Revision() int {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.latest().Number
+}
+
+// Layer return the values set on the layer only
+func (s *Store) Layer(l Layer) (map[string]interface{}, int) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	cur := s.latest()
+	values := map[string]interface{}{}
+	for k, v := range cur.Values[l.String()] {
+		values[k] = v
+	}
+	return values, cur.Number
+}
+
+// Resolve merge the layers from global to l, sources tell the layer each
+// value come from
+func (s *Store) Resolve(l Layer) (values map[string]interface{}, sources map[string]string, revision int) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	cur := s.latest()
+	values, sources = map[string]interface{}{}, map[string]string{}
+	for _, layer := range l.chain() {
+		for k, v := range cur.Values[layer.String()] {
+			values[k] = v
+			sources[k] = layer.String()
+		}
+	}
+	return values, sources, cur.Number
+}
+
+// Set set the values of the layer, a nil value delete its key. A new
+// revision is created if something changed
+func (s *Store) Set(l Layer, values map[string]interface{}, author, comment string) (Revision, error) {
+	for k := range values {
+		if !keyRegexp.MatchString(k) {
+			return Revision{}, fmt.Errorf("%w: key %q", ErrInvalid, k)
+		}
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	next := copyValues(s.latest().Values)
+	layer := next[l.String()]
+	if layer == nil {
+		layer = map[string]interface{}{}
+	}
+	for k, v := range values {
+		if v == nil {
+			delete(layer, k)
+			continue
+		}
+		layer[k] = v
+	}
+	next[l.String()] = layer
+	if len(layer) == 0 {
+		delete(next, l.String())
+	}
+
+	return s.commit(next, author, comment)
+}
+
+// Rollback create a revision with the values of the revision number
+func (s *Store) Rollback(number int, author, comment string) (Revision, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for _, r := range s.revisions {
+		if r.Number == number {
+			if comment == "" {
+				comment = fmt.Sprintf("rollback to %d", number)
+			}
+			return s.commit(copyValues(r.Values), author, comment)
+		}
+	}
+	return Revision{}, fmt.Errorf("%w %d, the history start at %d", ErrNotFound, number, s.revisions[0].Number)
+}
+
+// History return the revisions kept, from the latest, without their values
+func (s *Store) History() []Revision {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	history := make([]Revision, 0, len(s.revisions))
+	for i := len(s.revisions) - 1; i >= 0; i-- {
+		r := s.revisions[i]
+		r.Values = nil
+		history = append(history, r)
+	}
+	return history
+}
+
+// commit append the revision of values and persist the store, the lock
+// must be held. Nothing is committed if the values did not change
+func (s *Store) commit(values map[string]map[string]interface{}, author, comment string) (Revision, error) {
+	cur := s.latest()
+	changes := diff(cur.Values, values)
+	if len(changes) == 0 {
+		return cur, nil
+	}
+
+	r := Revision{
+		Number:  cur.Number + 1,
+		Time:    s.now().UTC(),
+		Author:  author,
+		Comment: comment,
+		Changes: changes,
+		Values:  values,
+	}
+
+	revisions := append(append([]Revision{}, s.revisions...), r)
+	if len(revisions) > s.maxHistory {
+		revisions = revisions[len(revisions)-s.maxHistory:]
+	}
+	if err := s.persist(revisions); err != nil {
+		return Revision{}, err
+	}
+	s.revisions = revisions
+
+	return r, nil
+}
+
+// persist write the revisions in a temporary file renamed over the
+// store, a crash never leave a partial one
+func (s *Store) persist(revisions []Revision) error {
+	if s.path == "" {
+		return nil
+	}
+	content, err := json.MarshalIndent(revisions, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	if err := os.MkdirAll(filepath.Dir(s.path), 0755); err != nil {
+		return err
+	}
+	tmp, err := os.CreateTemp(filepath.Dir(s.path), "."+filepath.Base(s.path)+"-")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+
+	if _, err := tmp.Write(content); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Sync(); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), s.path)
+}
+
+// diff list the changes from prev to next, ordered by layer and key
+func diff(prev, next map[string]map[string]interface{}) []Change {
+	changes := []Change{}
+	layers := map[string]bool{}
+	for l := range prev {
+		layers[l] = true
+	}
+	for l := range next {
+		layers[l] = true
+	}
+
+	for l := range layers {
+		keys := map[string]bool{}
+		for k := range prev[l] {
+			keys[k] = true
+		}
+		for k := range next[l] {
+			keys[k] = true
+		}
+		for k := range keys {
+			old, new := prev[l][k], next[l][k]
+			if !reflect.DeepEqual(old, new) {
+				changes = append(changes, Change{Layer: l, Key: k, Old: old, New: new})
+			}
+		}
+	}
+
+	sort.Slice(changes, func(i, j int) bool {
+		if changes[i].Layer != changes[j].Layer {
+			return changes[i].Layer < changes[j].Layer
+		}
+		return changes[i].Key < changes[j].Key
+	})
+	return changes
+}
+
+// copyValues copy the layers, the values themselves are never modified
+func copyValues(values map[string]map[string]interface{}) map[string]map[string]interface{} {
+	c := make(map[string]map[string]interface{}, len(values))
+	for l, layer := range values {
+		c[l] = make(map[string]interface{}, len(layer))
+		for k, v := range layer {
+			c[l][k] = v
+		}
+	}
+	return c
+}
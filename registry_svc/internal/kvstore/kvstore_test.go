@@ -0,0 +1,115 @@
+package kvstore
+
+import (
+	"errors"
+	"gitlab.com/grpasr/common/tests"
+	"path/filepath"
+	"testing"
+)
+
+func TestParseLayer(t *testing.T) {
+	tests.MaybeFail = tests.InitFailFunc(t)
+
+	for path, expected := range map[string]string{
+		"":                       "global",
+		"global":                 "global",
+		"development":            "development",
+		"/development/broker/":   "development/broker",
+		"development/broker/b-1": "development/broker/b-1",
+	} {
+		l, err := ParseLayer(path)
+		tests.MaybeFail("ParseLayer_"+path, err, tests.Expect(l.String(), expected))
+	}
+
+	for _, path := range []string{"a/b/c/d", "dev/../x", "dev/global"} {
+		_, err := ParseLayer(path)
+		tests.MaybeFail("ParseLayer_invalid_"+path, tests.Expect(errors.Is(err, ErrInvalid), true))
+	}
+}
+
+func TestResolveOverlays(t *testing.T) {
+	tests.MaybeFail = tests.InitFailFunc(t)
+
+	s, err := Open("", 10)
+	tests.MaybeFail("Open", err)
+
+	dev := Layer{Env: "development"}
+	broker := Layer{Env: "development", Service: "broker"}
+	instance := Layer{Env: "development", Service: "broker", Instance: "broker-1"}
+
+	s.Set(Layer{}, map[string]interface{}{"health.interval": 10.0, "obs.sampling": 0.6}, "admin", "")
+	s.Set(dev, map[string]interface{}{"obs.sampling": 1.0}, "admin", "")
+	s.Set(broker, map[string]interface{}{"health.interval": 20.0}, "admin", "")
+	s.Set(instance, map[string]interface{}{"health.interval": 5.0}, "admin", "")
+	// the staging services keep the global values
+	s.Set(Layer{Env: "staging", Service: "broker"}, map[string]interface{}{"health.interval": 60.0}, "admin", "")
+
+	values, sources, revision := s.Resolve(broker)
+	tests.MaybeFail("Resolve_service",
+		tests.Expect(revision, 5),
+		tests.Expect(values["health.interval"], 20.0),
+		tests.Expect(values["obs.sampling"], 1.0),
+		tests.Expect(sources["obs.sampling"], "development"),
+	)
+
+	values, _, _ = s.Resolve(instance)
+	tests.MaybeFail("Resolve_instance", tests.Expect(values["health.interval"], 5.0))
+
+	values, sources, _ = s.Resolve(Layer{Env: "production", Service: "broker"})
+	tests.MaybeFail("Resolve_global",
+		tests.Expect(values["health.interval"], 10.0),
+		tests.Expect(sources["health.interval"], "global"),
+	)
+
+	// an unchanged value is not a revision
+	r, err := s.Set(broker, map[string]interface{}{"health.interval": 20.0}, "admin", "")
+	tests.MaybeFail("Set_unchanged", err, tests.Expect(r.Number, 5))
+
+	_, err = s.Set(broker, map[string]interface{}{"health interval": 1.0}, "admin", "")
+	tests.MaybeFail("Set_invalid_key", tests.Expect(errors.Is(err, ErrInvalid), true))
+}
+
+func TestHistoryAndRollback(t *testing.T) {
+	tests.MaybeFail = tests.InitFailFunc(t)
+
+	path := filepath.Join(t.TempDir(), "kv", "store.json")
+	s, _ := Open(path, 3)
+	broker := Layer{Env: "development", Service: "broker"}
+
+	s.Set(broker, map[string]interface{}{"health.interval": 20.0}, "admin", "slower")
+	s.Set(broker, map[string]interface{}{"health.interval": 5.0}, "admin", "faster")
+	r, err := s.Set(broker, map[string]interface{}{"health.interval": nil}, "ops", "default")
+
+	tests.MaybeFail("Set_delete", err,
+		tests.Expect(r.Number, 3),
+		tests.Expect(len(r.Changes), 1),
+		tests.Expect(r.Changes[0].Old, 5.0),
+		tests.Expect(r.Changes[0].New, nil),
+	)
+
+	r, err = s.Rollback(1, "ops", "")
+	values, _ := s.Layer(broker)
+	tests.MaybeFail("Rollback", err,
+		tests.Expect(r.Number, 4),
+		tests.Expect(r.Comment, "rollback to 1"),
+		tests.Expect(values["health.interval"], 20.0),
+	)
+
+	// only maxHistory revisions are kept
+	history := s.History()
+	_, err = s.Rollback(0, "ops", "")
+	tests.MaybeFail("History",
+		tests.Expect(len(history), 3),
+		tests.Expect(history[0].Number, 4),
+		tests.Expect(history[0].Values == nil, true),
+		tests.Expect(errors.Is(err, ErrNotFound), true),
+	)
+
+	// the store is persisted
+	reopened, err := Open(path, 3)
+	values, _, revision := reopened.Resolve(broker)
+	tests.MaybeFail("Open_persisted", err,
+		tests.Expect(revision, 4),
+		tests.Expect(values["health.interval"], 20.0),
+	)
+}
@@ -0,0 +1,160 @@
+package secrets
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+)
+
+// the size of the master keys and of the data keys, AES-256
+const keySize = 32
+
+var (
+	ErrNoMasterKey = errors.New("secrets: no master key")
+	ErrUnknownKey  = errors.New("secrets: unknown master key")
+	ErrInvalid     = errors.New("secrets: invalid")
+)
+
+// KMS wrap the data keys of the secrets with its master key, KeyRing is
+// its local stand-in
+type KMS interface {
+	// Wrap encrypt the data key with the current master key
+	Wrap(dek, aad []byte) (kid string, wrapped []byte, err error)
+	// Unwrap decrypt the data key with the master key kid
+	Unwrap(kid string, wrapped, aad []byte) ([]byte, error)
+}
+
+// KeyRing is the master keys by id, the last one of the ring is the
+// current one, the others are kept to open the secrets not rotated yet
+type KeyRing struct {
+	keys    map[string][]byte
+	current string
+}
+
+// ParseKeyRing read the entries "<id>:<base64 key>" separated by new lines
+// or commas, the lines starting with # are skipped
+func ParseKeyRing(s string) (*KeyRing, error) {
+	kr := &KeyRing{keys: map[string][]byte{}}
+	for _, line := range strings.Split(s, "\n") {
+		if strings.HasPrefix(strings.TrimSpace(line), "#") {
+			continue
+		}
+		for _, entry := range strings.Split(line, ",") {
+			entry = strings.TrimSpace(entry)
+			if entry == "" {
+				continue
+			}
+			id, b64, ok := strings.Cut(entry, ":")
+			if !ok || id == "" {
+				return nil, fmt.Errorf("%w: master key entry without id", ErrInvalid)
+			}
+			key, err := base64.StdEncoding.DecodeString(b64)
+			if err != nil || len(key) != keySize {
+				return nil, fmt.Errorf("%w: master key %s must be %d bytes in base64", ErrInvalid, id, keySize)
+			}
+			kr.keys[id] = key
+			kr.current = id
+		}
+	}
+	if kr.current == "" {
+		return nil, ErrNoMasterKey
+	}
+	return kr, nil
+}
+
+// LoadKeyRing read the ring from env if it is set, from the file otherwise
+func LoadKeyRing(env, file string) (*KeyRing, error) {
+	if env != "" {
+		return ParseKeyRing(env)
+	}
+	if file == "" {
+		return nil, ErrNoMasterKey
+	}
+	content, err := os.ReadFile(file)
+	if err != nil {
+		return nil, err
+	}
+	return ParseKeyRing(string(content))
+}
+
+// NewMasterKey generate the entry of a new master key, appended to the
+// ring it becomes the current one
+func NewMasterKey(id string) (string, error) {
+	if id == "" || strings.ContainsAny(id, ":,\n") {
+		return "", fmt.Errorf("%w: master key id %q", ErrInvalid, id)
+	}
+	key := make([]byte, keySize)
+	if _, err := rand.Read(key); err != nil {
+		return "", err
+	}
+	return id + ":" + base64.StdEncoding.EncodeToString(key), nil
+}
+
+// Current is the id of the master key wrapping the new data keys
+func (k *KeyRing) Current() string {
+	if k == nil {
+		return ""
+	}
+	return k.current
+}
+
+func (k *KeyRing) Wrap(dek, aad []byte) (string, []byte, error) {
+	if k == nil {
+		return "", nil, ErrNoMasterKey
+	}
+	wrapped, err := seal(k.keys[k.current], dek, aad)
+	return k.current, wrapped, err
+}
+
+func (k *KeyRing) Unwrap(kid string, wrapped, aad []byte) ([]byte, error) {
+	if k == nil {
+		return nil, ErrNoMasterKey
+	}
+	key, ok := k.keys[kid]
+	if !ok {
+		return nil, fmt.Errorf("%w %s", ErrUnknownKey, kid)
+	}
+	return open(key, wrapped, aad)
+}
+
+// seal encrypt with AES-GCM, the nonce is the prefix of the result
+func seal(key, plaintext, aad []byte) ([]byte, error) {
+	gcm, err := newGCM(key)
+	if err != nil {
+		return nil, err
+	}
+	nonce := make([]byte, gcm.NonceSize(), gcm.NonceSize()+len(plaintext)+gcm.Overhead())
+	if _, err := io.ReadFull(rand.Reader, nonce); err != nil {
+		return nil, err
+	}
+	return gcm.Seal(nonce, nonce, plaintext, aad), nil
+}
+
+func open(key, sealed, aad []byte) ([]byte, error) {
+	gcm, err := newGCM(key)
+	if err != nil {
+		return nil, err
+	}
+	if len(sealed) < gcm.NonceSize() {
+		return nil, fmt.Errorf("%w: ciphertext too short", ErrInvalid)
+	}
+	plaintext, err := gcm.Open(nil, sealed[:gcm.NonceSize()], sealed[gcm.NonceSize():], aad)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalid, err)
+	}
+	return plaintext, nil
+}
+
+func newGCM(key []byte) (cipher.AEAD, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
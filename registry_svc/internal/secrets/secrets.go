@@ -0,0 +1,215 @@
+// Package secrets seal the private keys served by the registry with the
+// envelope encryption: each secret is encrypted by its own data key, the
+// data key is wrapped by the master key of the KMS and kept with it
+package secrets
+
+import (
+	"bytes"
+	"crypto/rand"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// Ext is the extension of a sealed secret, client.key is stored as
+// client.key.enc and served as client.key
+const Ext = ".enc"
+
+const envelopeVersion = 1
+
+// envelope is the content of a sealed secret, the name of the secret(its
+// path under the configs root) is authenticated so that a secret can not be
+// swapped with another one, even one of the same file name
+type envelope struct {
+	Version int    `json:"version"`
+	KeyID   string `json:"kid"`
+	Key     []byte `json:"key"`  // the wrapped data key
+	Data    []byte `json:"data"` // the nonce and the ciphertext
+}
+
+// Seal encrypt the secret name with a new data key, name is the one
+// returned by Name
+func Seal(kms KMS, name string, plaintext []byte) ([]byte, error) {
+	dek := make([]byte, keySize)
+	if _, err := rand.Read(dek); err != nil {
+		return nil, err
+	}
+	data, err := seal(dek, plaintext, []byte(name))
+	if err != nil {
+		return nil, err
+	}
+	kid, wrapped, err := kms.Wrap(dek, []byte(name))
+	if err != nil {
+		return nil, err
+	}
+	return json.MarshalIndent(envelope{Version: envelopeVersion, KeyID: kid, Key: wrapped, Data: data}, "", "  ")
+}
+
+// Open decrypt the secret name
+func Open(kms KMS, name string, sealed []byte) ([]byte, error) {
+	env := envelope{}
+	if err := json.Unmarshal(sealed, &env); err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalid, err)
+	}
+	if env.Version != envelopeVersion {
+		return nil, fmt.Errorf("%w: envelope version %d", ErrInvalid, env.Version)
+	}
+	dek, err := kms.Unwrap(env.KeyID, env.Key, []byte(name))
+	if err != nil {
+		return nil, err
+	}
+	return open(dek, env.Data, []byte(name))
+}
+
+// IsSealed is true if the file is a sealed secret
+func IsSealed(path string) bool {
+	return strings.HasSuffix(path, Ext)
+}
+
+// Name is the name of the secret sealed in the file, its slash separated
+// path relative to root(the configs directory) without Ext, as
+// "v1/certificates/server.key"
+func Name(root, path string) (string, error) {
+	absRoot, err := filepath.Abs(root)
+	if err != nil {
+		return "", err
+	}
+	absPath, err := filepath.Abs(path)
+	if err != nil {
+		return "", err
+	}
+	rel, err := filepath.Rel(absRoot, absPath)
+	if err != nil {
+		return "", err
+	}
+	if rel == "." || rel == ".." || strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+		return "", fmt.Errorf("%w: %s is not under %s", ErrInvalid, path, root)
+	}
+	return strings.TrimSuffix(filepath.ToSlash(rel), Ext), nil
+}
+
+// IsPrivateKey is true if content is a PEM private key in cleartext
+func IsPrivateKey(content []byte) bool {
+	return bytes.Contains(content, []byte("-----BEGIN ")) &&
+		bytes.Contains(content, []byte("PRIVATE KEY-----"))
+}
+
+// ReadFile return the content of the file, decrypted if it is sealed, the
+// secrets are named after their path under root
+func ReadFile(kms KMS, root, path string) ([]byte, error) {
+	content, err := os.ReadFile(path)
+	if err != nil || !IsSealed(path) {
+		return content, err
+	}
+	name, err := Name(root, path)
+	if err != nil {
+		return nil, err
+	}
+	return Open(kms, name, content)
+}
+
+// ImportFile seal the file of root to path.enc, the cleartext is removed
+// once the secret is written unless keep. An existing secret is replaced
+func ImportFile(kms KMS, root, path string, keep bool) (string, error) {
+	if IsSealed(path) {
+		return "", fmt.Errorf("%w: %s is already sealed", ErrInvalid, path)
+	}
+	name, err := Name(root, path)
+	if err != nil {
+		return "", err
+	}
+	content, err := os.ReadFile(path)
+	if err != nil {
+		return "", err
+	}
+	sealed, err := Seal(kms, name, content)
+	if err != nil {
+		return "", err
+	}
+	if err := writeFile(path+Ext, sealed); err != nil {
+		return "", err
+	}
+	if !keep {
+		if err := os.Remove(path); err != nil {
+			return "", err
+		}
+	}
+	return path + Ext, nil
+}
+
+// RotateDir seal again the secrets of dir, a directory of root, and its
+// sub-directories with new data keys wrapped by the current master key,
+// return the rotated
+func RotateDir(kms KMS, root, dir string) ([]string, error) {
+	rotated := []string{}
+	err := filepath.Walk(dir, func(path string, info os.FileInfo, err error) error {
+		if err != nil || info.IsDir() || !IsSealed(path) {
+			return err
+		}
+		name, err := Name(root, path)
+		if err != nil {
+			return err
+		}
+		content, err := os.ReadFile(path)
+		if err != nil {
+			return err
+		}
+		plaintext, err := Open(kms, name, content)
+		if err != nil {
+			return fmt.Errorf("%s: %w", path, err)
+		}
+		sealed, err := Seal(kms, name, plaintext)
+		if err != nil {
+			return fmt.Errorf("%s: %w", path, err)
+		}
+		if err := writeFile(path, sealed); err != nil {
+			return err
+		}
+		rotated = append(rotated, path)
+		return nil
+	})
+	return rotated, err
+}
+
+// ScanDir list the cleartext private keys of dir and its sub-directories
+func ScanDir(dir string) ([]string, error) {
+	found := []string{}
+	err := filepath.Walk(dir, func(path string, info os.FileInfo, err error) error {
+		if err != nil || info.IsDir() || IsSealed(path) {
+			return err
+		}
+		content, err := os.ReadFile(path)
+		if err != nil {
+			return err
+		}
+		if IsPrivateKey(content) {
+			found = append(found, path)
+		}
+		return nil
+	})
+	return found, err
+}
+
+// writeFile replace the file atomically, readable by the owner only
+func writeFile(path string, content []byte) error {
+	tmp, err := os.CreateTemp(filepath.Dir(path), "."+filepath.Base(path)+"-")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+
+	if _, err := tmp.Write(content); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Sync(); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), path)
+}
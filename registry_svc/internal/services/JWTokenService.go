@@ -11,26 +11,21 @@ import (
 	// "time"
 )
 
-// TODO pass these const via var env(secrets)
-const (
-	signedKey = "mySecretKey"
-)
-
-// JWTAccessGenerate generate the jwt access token
+// JWTokenService validate the jwt signed by auth_svc, the keys are the
+// ones auth_svc publish, see KeySetClient
 type JWTokenService struct {
-	signedKey []byte // secret key
+	keys IKeySet
 }
 
-func NewJWTokenService() *JWTokenService {
+func NewJWTokenService(keys IKeySet) *JWTokenService {
 	return &JWTokenService{
-		signedKey: []byte(signedKey),
+		keys: keys,
 	}
 }
 
 // JWTokenIsValidToken valid the jwt_token, if valid return nil
 func (a *JWTokenService) JWTokenIsValidToken(ctx context.Context, tokenString string) (map[string]string, e.IError) {
 
-	var secretKey = a.signedKey
 	tokenString = strings.TrimSpace(tokenString)
 	tokenString = strings.Trim(tokenString, `"`)
 
@@ -56,7 +51,15 @@ func (a *JWTokenService) JWTokenIsValidToken(ctx context.Context, tokenString st
 			return nil, errors.New("ErrInvalidJWToken")
 		}
 
-		return secretKey, nil
+		// the kid must be one of the keys of auth_svc, a token without
+		// kid or with an unknown one is rejected
+		keyID, _ := token.Header["kid"].(string)
+		secretKey, err := a.keys.KeySetGet(ctx, keyID)
+		if err != nil {
+			return nil, err
+		}
+
+		return []byte(secretKey), nil
 	})
 	if err != nil {
 		if err.Error() == "ErrExpiredJWToken" {
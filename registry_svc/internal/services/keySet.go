@@ -0,0 +1,147 @@
+package services
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"sync"
+	"time"
+)
+
+const (
+	// the path of the keys endpoint, under the auth_svc url
+	keySetPath = "/keys"
+
+	// keySetFetchMin is the min delay between two fetches triggered by an
+	// unknown kid, a rotation is seen at once but a forged kid can not
+	// hammer auth_svc
+	keySetFetchMin = 5 * time.Second
+
+	keySetTimeout = 10 * time.Second
+)
+
+// ErrUnknownKeyID is returned for a kid auth_svc does not sign with
+var ErrUnknownKeyID = errors.New("unknown jwt key id")
+
+// IKeySet return the secret of the key the jwt was signed with
+type IKeySet interface {
+	KeySetGet(ctx context.Context, keyID string) (string, error)
+}
+
+// KeySetClient fetch the signing keys of auth_svc(/v1/keys) with the
+// service's client credentials, the keys are cached and fetched again
+// when a jwt is signed with an unknown kid(after a rotation)
+type KeySetClient struct {
+	url          string
+	clientID     string
+	clientSecret string
+	client       *http.Client
+	keys         map[string]string
+	fetchedAt    time.Time
+	mu           sync.RWMutex
+	// fetchMu make sure only one fetch is in flight
+	fetchMu sync.Mutex
+}
+
+func NewKeySetClient(authSvcURL, clientID, clientSecret string) *KeySetClient {
+	return &KeySetClient{
+		url:          authSvcURL + keySetPath,
+		clientID:     clientID,
+		clientSecret: clientSecret,
+		client:       &http.Client{Timeout: keySetTimeout},
+		keys:         make(map[string]string),
+	}
+}
+
+// KeySetGet return the secret of keyID, ErrUnknownKeyID if auth_svc does
+// not have it
+func (k *KeySetClient) KeySetGet(ctx context.Context, keyID string) (string, error) {
+	if keyID == "" {
+		return "", ErrUnknownKeyID
+	}
+	if secret, ok := k.lookup(keyID); ok {
+		return secret, nil
+	}
+
+	k.fetchMu.Lock()
+	defer k.fetchMu.Unlock()
+
+	// fetched by an other request while waiting
+	if secret, ok := k.lookup(keyID); ok {
+		return secret, nil
+	}
+
+	k.mu.RLock()
+	fetch := time.Since(k.fetchedAt) > keySetFetchMin
+	k.mu.RUnlock()
+	if !fetch {
+		return "", ErrUnknownKeyID
+	}
+	if err := k.fetch(ctx); err != nil {
+		return "", err
+	}
+
+	if secret, ok := k.lookup(keyID); ok {
+		return secret, nil
+	}
+	return "", ErrUnknownKeyID
+}
+
+func (k *KeySetClient) lookup(keyID string) (string, bool) {
+	k.mu.RLock()
+	defer k.mu.RUnlock()
+	secret, ok := k.keys[keyID]
+	return secret, ok
+}
+
+// fetch replace the cached keys with the ones of auth_svc, the keys
+// dropped by auth_svc are not valid anymore
+func (k *KeySetClient) fetch(ctx context.Context) error {
+	k.mu.Lock()
+	k.fetchedAt = time.Now()
+	k.mu.Unlock()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, k.url, nil)
+	if err != nil {
+		return err
+	}
+	req.SetBasicAuth(k.clientID, k.clientSecret)
+
+	resp, err := k.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("fetch the signing keys failed with status %d", resp.StatusCode)
+	}
+
+	set := struct {
+		Keys []struct {
+			KeyID string `json:"kid"`
+			K     string `json:"k"`
+		} `json:"keys"`
+	}{}
+	if err := json.NewDecoder(resp.Body).Decode(&set); err != nil {
+		return err
+	}
+
+	keys := make(map[string]string, len(set.Keys))
+	for _, key := range set.Keys {
+		secret, err := base64.RawURLEncoding.DecodeString(key.K)
+		if err != nil {
+			return err
+		}
+		keys[key.KeyID] = string(secret)
+	}
+
+	k.mu.Lock()
+	k.keys = keys
+	k.mu.Unlock()
+
+	return nil
+}
@@ -0,0 +1,115 @@
+package services
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"github.com/golang-jwt/jwt"
+	"gitlab.com/grpasr/common/tests"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+)
+
+// fakeAuthSvc serve the keys endpoint of auth_svc, fetches count the
+// requests served
+func fakeAuthSvc(t *testing.T, keys map[string]string, fetches *int, mu *sync.Mutex) *httptest.Server {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		id, secret, ok := r.BasicAuth()
+		if !ok || id != "registrySvc" || secret != "registrySvcSecret" || r.URL.Path != "/v1/keys" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		mu.Lock()
+		defer mu.Unlock()
+		*fetches++
+
+		set := map[string][]map[string]string{"keys": {}}
+		for kid, k := range keys {
+			set["keys"] = append(set["keys"], map[string]string{
+				"kty": "oct",
+				"kid": kid,
+				"k":   base64.RawURLEncoding.EncodeToString([]byte(k)),
+			})
+		}
+		json.NewEncoder(w).Encode(set)
+	}))
+	t.Cleanup(srv.Close)
+	return srv
+}
+
+func signTestToken(keyID, secretKey string) string {
+	token := jwt.NewWithClaims(jwt.SigningMethodHS256, jwt.MapClaims{
+		"sub":        "testSvc",
+		"exp":        time.Now().Add(time.Minute).Unix(),
+		"openidInfo": map[string]interface{}{"role": "admin", "scope": "read"},
+	})
+	if keyID != "" {
+		token.Header["kid"] = keyID
+	}
+	signed, _ := token.SignedString([]byte(secretKey))
+	return signed
+}
+
+func TestKeySetClient(t *testing.T) {
+	tests.MaybeFail = tests.InitFailFunc(t)
+
+	mu := &sync.Mutex{}
+	keys := map[string]string{"key1": "secret1"}
+	var fetches int
+	srv := fakeAuthSvc(t, keys, &fetches, mu)
+	ks := NewKeySetClient(srv.URL+"/v1", "registrySvc", "registrySvcSecret")
+	ctx := context.Background()
+
+	secret, err := ks.KeySetGet(ctx, "key1")
+	tests.MaybeFail("KeySetGet", err, tests.Expect(secret, "secret1"), tests.Expect(fetches, 1))
+
+	// the keys are cached
+	_, err = ks.KeySetGet(ctx, "key1")
+	tests.MaybeFail("KeySetGet_cached", err, tests.Expect(fetches, 1))
+
+	// an unknown kid right after a fetch does not fetch again
+	_, err = ks.KeySetGet(ctx, "forged")
+	tests.MaybeFail("KeySetGet_unknown",
+		tests.Expect(errors.Is(err, ErrUnknownKeyID), true),
+		tests.Expect(fetches, 1))
+
+	_, err = ks.KeySetGet(ctx, "")
+	tests.MaybeFail("KeySetGet_no_kid", tests.Expect(errors.Is(err, ErrUnknownKeyID), true))
+
+	// a key rotated by auth_svc is fetched once the min delay is elapsed
+	mu.Lock()
+	keys["key2"] = "secret2"
+	mu.Unlock()
+	ks.fetchedAt = ks.fetchedAt.Add(-2 * keySetFetchMin)
+	secret, err = ks.KeySetGet(ctx, "key2")
+	tests.MaybeFail("KeySetGet_rotated", err, tests.Expect(secret, "secret2"), tests.Expect(fetches, 2))
+
+	// the wrong credentials can not fetch the keys
+	_, err = NewKeySetClient(srv.URL+"/v1", "registrySvc", "wrong").KeySetGet(ctx, "key1")
+	tests.MaybeFail("KeySetGet_unauthorized", tests.Expect(err != nil, true))
+}
+
+func TestJWTokenIsValidTokenKeyID(t *testing.T) {
+	tests.MaybeFail = tests.InitFailFunc(t)
+
+	var fetches int
+	srv := fakeAuthSvc(t, map[string]string{"key1": "secret1"}, &fetches, &sync.Mutex{})
+	js := NewJWTokenService(NewKeySetClient(srv.URL+"/v1", "registrySvc", "registrySvcSecret"))
+	ctx := context.Background()
+
+	infos, ce := js.JWTokenIsValidToken(ctx, signTestToken("key1", "secret1"))
+	tests.MaybeFail("valid", ce, tests.Expect(infos["svc"], "testSvc"))
+
+	// the token signed with the key of auth_svc but an other kid, without
+	// kid or with an other secret are rejected
+	_, ce = js.JWTokenIsValidToken(ctx, signTestToken("unknown", "secret1"))
+	tests.MaybeFail("unknown_kid", tests.Expect(ce.GetCode(), http.StatusForbidden))
+	_, ce = js.JWTokenIsValidToken(ctx, signTestToken("", "secret1"))
+	tests.MaybeFail("no_kid", tests.Expect(ce.GetCode(), http.StatusForbidden))
+	_, ce = js.JWTokenIsValidToken(ctx, signTestToken("key1", "mySecretKey"))
+	tests.MaybeFail("wrong_secret", tests.Expect(ce.GetCode(), http.StatusForbidden))
+}
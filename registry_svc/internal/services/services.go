@@ -5,9 +5,9 @@ type Services struct {
 	*JWTokenService
 }
 
-func NewServices() Services {
+func NewServices(keys IKeySet) Services {
 	svc := Services{
-		JWTokenService: NewJWTokenService(),
+		JWTokenService: NewJWTokenService(keys),
 	}
 	return svc
 }
@@ -0,0 +1,161 @@
+package discovery
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"google.golang.org/grpc/credentials"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+const timeoutDefault = 10 * time.Second
+
+// ErrNotFound is returned by Heartbeat when the registry_svc lost the
+// registration(expired lease, restart), the instance register again
+var ErrNotFound = errors.New("registration not found")
+
+// Instance is an instance of a service registered in registry_svc
+type Instance struct {
+	ID        string            `json:"id,omitempty"`
+	Name      string            `json:"name"`
+	Env       string            `json:"env"`
+	Address   string            `json:"address"`
+	Port      string            `json:"port"`
+	Version   string            `json:"version,omitempty"`
+	Metadata  map[string]string `json:"metadata,omitempty"`
+	TTL       int               `json:"ttl,omitempty"` // seconds
+	ExpiresAt time.Time         `json:"expiresAt,omitempty"`
+}
+
+// Client call the discovery API of registry_svc, the requests carry the
+// service's jwtoken read from creds(the tokensource). registry_svc accept
+// only the instances named as the jwtoken's subject
+type Client struct {
+	url    string // "http://localhost:4000"
+	creds  credentials.PerRPCCredentials
+	client *http.Client
+}
+
+func NewClient(registryURL string, creds credentials.PerRPCCredentials) *Client {
+	return &Client{
+		url:    strings.TrimSuffix(registryURL, "/") + "/discovery",
+		creds:  creds,
+		client: &http.Client{Timeout: timeoutDefault},
+	}
+}
+
+// Resolve return the live instances of the service in env
+func (c *Client) Resolve(ctx context.Context, name, env string) ([]Instance, error) {
+	resp := struct {
+		Instances []Instance `json:"instances"`
+	}{}
+	path := "/services/" + url.PathEscape(name) + "?env=" + url.QueryEscape(env)
+	if err := c.do(ctx, http.MethodGet, path, nil, http.StatusOK, &resp); err != nil {
+		return nil, err
+	}
+	return resp.Instances, nil
+}
+
+// Register register the instance for ttl, the returned one has the ID of
+// the heartbeats
+func (c *Client) Register(ctx context.Context, inst Instance, ttl time.Duration) (Instance, error) {
+	inst.TTL = int(ttl / time.Second)
+	registered := Instance{}
+	err := c.do(ctx, http.MethodPost, "/services", inst, http.StatusCreated, &registered)
+	return registered, err
+}
+
+// Heartbeat renew the lease of the instance
+func (c *Client) Heartbeat(ctx context.Context, id string) error {
+	return c.do(ctx, http.MethodPut, "/instances/"+url.PathEscape(id)+"/heartbeat", nil, http.StatusOK, nil)
+}
+
+func (c *Client) Deregister(ctx context.Context, id string) error {
+	return c.do(ctx, http.MethodDelete, "/instances/"+url.PathEscape(id), nil, http.StatusNoContent, nil)
+}
+
+// Keepalive register the instance and renew its lease every third of ttl
+// until ctx is done, it is then deregistered and the returned channel is
+// closed. A lost registration is registered again
+func (c *Client) Keepalive(ctx context.Context, inst Instance, ttl time.Duration) (<-chan struct{}, error) {
+	registered, err := c.Register(ctx, inst, ttl)
+	if err != nil {
+		return nil, err
+	}
+
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		ticker := time.NewTicker(ttl / 3)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ctx.Done():
+				// the ctx is done, the deregistration has its own
+				dctx, cancel := context.WithTimeout(context.Background(), timeoutDefault)
+				c.Deregister(dctx, registered.ID)
+				cancel()
+				return
+			case <-ticker.C:
+				err := c.Heartbeat(ctx, registered.ID)
+				if errors.Is(err, ErrNotFound) {
+					if r, err := c.Register(ctx, inst, ttl); err == nil {
+						registered = r
+					}
+				}
+			}
+		}
+	}()
+
+	return done, nil
+}
+
+func (c *Client) do(ctx context.Context, method, path string, body interface{}, expected int, out interface{}) error {
+	buf := &bytes.Buffer{}
+	if body != nil {
+		if err := json.NewEncoder(buf).Encode(body); err != nil {
+			return err
+		}
+	}
+
+	req, err := http.NewRequestWithContext(ctx, method, c.url+path, buf)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	if c.creds != nil {
+		md, err := c.creds.GetRequestMetadata(ctx)
+		if err != nil {
+			return err
+		}
+		for k, v := range md {
+			req.Header.Set(k, v)
+		}
+	}
+
+	resp, err := c.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	switch resp.StatusCode {
+	case expected:
+	case http.StatusNotFound:
+		return ErrNotFound
+	default:
+		msg, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+		return fmt.Errorf("registry_svc %s %s: %d %s", method, path, resp.StatusCode, strings.TrimSpace(string(msg)))
+	}
+
+	if out != nil {
+		return json.NewDecoder(resp.Body).Decode(out)
+	}
+	return nil
+}
@@ -0,0 +1,192 @@
+package discovery
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"gitlab.com/grpasr/common/tests"
+	"google.golang.org/grpc/resolver"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"sync"
+	"testing"
+	"time"
+)
+
+type staticCreds struct{}
+
+func (staticCreds) GetRequestMetadata(ctx context.Context, uri ...string) (map[string]string, error) {
+	return map[string]string{"authorization": "Bearer jwt"}, nil
+}
+
+func (staticCreds) RequireTransportSecurity() bool {
+	return false
+}
+
+// fakeRegistry serve the discovery API of registry_svc with instances,
+// heartbeats count the renewed leases
+func fakeRegistry(t *testing.T, instances *[]Instance, heartbeats *int, mu *sync.Mutex) *httptest.Server {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "Bearer jwt" {
+			w.WriteHeader(http.StatusForbidden)
+			return
+		}
+		mu.Lock()
+		defer mu.Unlock()
+
+		switch {
+		case r.Method == http.MethodGet && r.URL.Path == "/discovery/services/order":
+			live := []Instance{}
+			for _, inst := range *instances {
+				if inst.Env == r.URL.Query().Get("env") {
+					live = append(live, inst)
+				}
+			}
+			json.NewEncoder(w).Encode(map[string]interface{}{"instances": live})
+		case r.Method == http.MethodPost && r.URL.Path == "/discovery/services":
+			inst := Instance{}
+			json.NewDecoder(r.Body).Decode(&inst)
+			inst.ID = "1a"
+			*instances = append(*instances, inst)
+			w.WriteHeader(http.StatusCreated)
+			json.NewEncoder(w).Encode(inst)
+		case r.Method == http.MethodPut && r.URL.Path == "/discovery/instances/1a/heartbeat" && len(*instances) > 0:
+			*heartbeats++
+			json.NewEncoder(w).Encode((*instances)[0])
+		case r.Method == http.MethodDelete && r.URL.Path == "/discovery/instances/1a":
+			*instances = nil
+			w.WriteHeader(http.StatusNoContent)
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	t.Cleanup(srv.Close)
+	return srv
+}
+
+func TestClient(t *testing.T) {
+	tests.MaybeFail = tests.InitFailFunc(t)
+
+	var instances []Instance
+	var heartbeats int
+	srv := fakeRegistry(t, &instances, &heartbeats, &sync.Mutex{})
+	c := NewClient(srv.URL, staticCreds{})
+	ctx := context.Background()
+
+	inst, err := c.Register(ctx, Instance{Name: "order", Env: "docker", Address: "order", Port: "50001"}, 30*time.Second)
+	tests.MaybeFail("Register", err,
+		tests.Expect(inst.ID, "1a"),
+		tests.Expect(inst.TTL, 30),
+	)
+
+	resolved, err := c.Resolve(ctx, "order", "docker")
+	tests.MaybeFail("Resolve", err,
+		tests.Expect(len(resolved), 1),
+		tests.Expect(resolved[0].Port, "50001"),
+	)
+
+	err = c.Heartbeat(ctx, inst.ID)
+	tests.MaybeFail("Heartbeat", err, tests.Expect(heartbeats, 1))
+
+	err = c.Deregister(ctx, inst.ID)
+	tests.MaybeFail("Deregister", err,
+		tests.Expect(errors.Is(c.Heartbeat(ctx, inst.ID), ErrNotFound), true),
+	)
+
+	_, err = NewClient(srv.URL, nil).Resolve(ctx, "order", "docker")
+	tests.MaybeFail("Resolve_forbidden", tests.Expect(err != nil, true))
+
+	_, err = NewClient(srv.URL, nil).Register(ctx, Instance{Name: "order"}, time.Minute)
+	tests.MaybeFail("Register_forbidden", tests.Expect(err != nil, true))
+}
+
+func TestKeepalive(t *testing.T) {
+	tests.MaybeFail = tests.InitFailFunc(t)
+
+	mu := &sync.Mutex{}
+	var instances []Instance
+	var heartbeats int
+	srv := fakeRegistry(t, &instances, &heartbeats, mu)
+	c := NewClient(srv.URL, staticCreds{})
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done, err := c.Keepalive(ctx, Instance{Name: "order", Env: "docker", Address: "order", Port: "50001"}, 300*time.Millisecond)
+	tests.MaybeFail("Keepalive", err)
+
+	time.Sleep(250 * time.Millisecond)
+	mu.Lock()
+	tests.MaybeFail("Keepalive_heartbeats",
+		tests.Expect(len(instances), 1),
+		tests.Expect(heartbeats > 0, true),
+	)
+	mu.Unlock()
+
+	// the instance is deregistered once ctx is done
+	cancel()
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		t.Fatal("Keepalive_deregister: not deregistered")
+	}
+	mu.Lock()
+	tests.MaybeFail("Keepalive_deregister", tests.Expect(len(instances), 0))
+	mu.Unlock()
+}
+
+// fakeClientConn record the states pushed by the resolver
+type fakeClientConn struct {
+	resolver.ClientConn
+	states chan resolver.State
+	errs   chan error
+}
+
+func (f *fakeClientConn) UpdateState(s resolver.State) error {
+	f.states <- s
+	return nil
+}
+
+func (f *fakeClientConn) ReportError(err error) {
+	f.errs <- err
+}
+
+func TestResolver(t *testing.T) {
+	tests.MaybeFail = tests.InitFailFunc(t)
+
+	mu := &sync.Mutex{}
+	instances := []Instance{{ID: "1a", Name: "order", Env: "docker", Address: "order1", Port: "50001"}}
+	var heartbeats int
+	srv := fakeRegistry(t, &instances, &heartbeats, mu)
+
+	cc := &fakeClientConn{states: make(chan resolver.State, 10), errs: make(chan error, 10)}
+	b := NewBuilder(NewClient(srv.URL, staticCreds{}), "docker", time.Hour)
+	target, _ := url.Parse(Target("order"))
+	r, err := b.Build(resolver.Target{URL: *target}, cc, resolver.BuildOptions{})
+	tests.MaybeFail("Build", err)
+	defer r.Close()
+
+	s := <-cc.states
+	tests.MaybeFail("Resolve_first",
+		tests.Expect(len(s.Addresses), 1),
+		tests.Expect(s.Addresses[0].Addr, "order1:50001"),
+		tests.Expect(s.Addresses[0].ServerName, "order1"),
+	)
+
+	// a new instance is picked at the next resolution
+	mu.Lock()
+	instances = append(instances, Instance{ID: "2b", Name: "order", Env: "docker", Address: "order2", Port: "50001"})
+	mu.Unlock()
+	r.ResolveNow(resolver.ResolveNowOptions{})
+
+	s = <-cc.states
+	tests.MaybeFail("Resolve_now", tests.Expect(len(s.Addresses), 2))
+
+	// no instance is an error, grpc keep the previous addresses
+	mu.Lock()
+	instances = nil
+	mu.Unlock()
+	r.ResolveNow(resolver.ResolveNowOptions{})
+
+	err = <-cc.errs
+	tests.MaybeFail("Resolve_none", tests.Expect(err != nil, true))
+}
@@ -0,0 +1,132 @@
+package discovery
+
+import (
+	"context"
+	"fmt"
+	"google.golang.org/grpc/resolver"
+	"net"
+	"sort"
+	"sync"
+	"time"
+)
+
+// Scheme is the scheme of the grpc targets resolved by registry_svc,
+// "registry:///order" dial the instances of order
+const Scheme = "registry"
+
+const intervalDefault = 10 * time.Second
+
+// Builder is the grpc resolver of the services registered in
+// registry_svc, pass it with grpc.WithResolvers. The instances are polled
+// every interval, so the connection follow the instances registering and
+// expiring
+type Builder struct {
+	client   *Client
+	env      string
+	interval time.Duration
+}
+
+func NewBuilder(client *Client, env string, interval time.Duration) *Builder {
+	if interval <= 0 {
+		interval = intervalDefault
+	}
+	return &Builder{client: client, env: env, interval: interval}
+}
+
+// Target is the grpc target of the service
+func Target(name string) string {
+	return fmt.Sprintf("%s:///%s", Scheme, name)
+}
+
+func (b *Builder) Scheme() string {
+	return Scheme
+}
+
+func (b *Builder) Build(target resolver.Target, cc resolver.ClientConn, opts resolver.BuildOptions) (resolver.Resolver, error) {
+	ctx, cancel := context.WithCancel(context.Background())
+	r := &registryResolver{
+		builder: b,
+		name:    target.Endpoint(),
+		cc:      cc,
+		cancel:  cancel,
+		now:     make(chan struct{}, 1),
+	}
+
+	r.wg.Add(1)
+	go r.watch(ctx)
+
+	return r, nil
+}
+
+type registryResolver struct {
+	builder *Builder
+	name    string
+	cc      resolver.ClientConn
+	cancel  context.CancelFunc
+	now     chan struct{}
+	wg      sync.WaitGroup
+	last    string // the addresses of the last update
+}
+
+// watch resolve the instances at once then every interval, or when grpc
+// ask for it(a connection failed)
+func (r *registryResolver) watch(ctx context.Context) {
+	defer r.wg.Done()
+
+	ticker := time.NewTicker(r.builder.interval)
+	defer ticker.Stop()
+	for {
+		r.resolve(ctx)
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		case <-r.now:
+		}
+	}
+}
+
+func (r *registryResolver) resolve(ctx context.Context) {
+	instances, err := r.builder.client.Resolve(ctx, r.name, r.builder.env)
+	if err != nil {
+		if ctx.Err() == nil {
+			r.cc.ReportError(err)
+		}
+		return
+	}
+	if len(instances) == 0 {
+		r.cc.ReportError(fmt.Errorf("no instance of %s registered in %s", r.name, r.builder.env))
+		return
+	}
+
+	addrs := make([]resolver.Address, 0, len(instances))
+	for _, inst := range instances {
+		addrs = append(addrs, resolver.Address{
+			Addr:       net.JoinHostPort(inst.Address, inst.Port),
+			ServerName: inst.Address,
+		})
+	}
+	sort.Slice(addrs, func(i, j int) bool { return addrs[i].Addr < addrs[j].Addr })
+
+	// grpc rebuild its balancer on each update, skip the unchanged ones
+	key := fmt.Sprint(addrs)
+	if key == r.last {
+		return
+	}
+	if err := r.cc.UpdateState(resolver.State{Addresses: addrs}); err == nil {
+		r.last = key
+	}
+}
+
+func (r *registryResolver) ResolveNow(resolver.ResolveNowOptions) {
+	select {
+	case r.now <- struct{}{}:
+	default:
+	}
+}
+
+func (r *registryResolver) Close() {
+	r.cancel()
+	r.wg.Wait()
+}
@@ -0,0 +1,17 @@
+module gitlab.com/grpasr/asonrythme/shared
+
+go 1.21.0
+
+require (
+	gitlab.com/grpasr/common v0.0.0-20240418081513-7e280db72a3f
+	google.golang.org/grpc v1.58.2
+)
+
+require (
+	github.com/golang/protobuf v1.5.3 // indirect
+	golang.org/x/net v0.15.0 // indirect
+	golang.org/x/sys v0.14.0 // indirect
+	golang.org/x/text v0.13.0 // indirect
+	google.golang.org/genproto/googleapis/rpc v0.0.0-20230822172742-b8732ec3820d // indirect
+	google.golang.org/protobuf v1.31.0 // indirect
+)
@@ -0,0 +1,35 @@
+package health
+
+import (
+	"context"
+	"fmt"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/connectivity"
+)
+
+// GRPCConnCheck is up once the client connection is ready, an idle
+// connection is asked to connect and the check wait for its next state
+func GRPCConnCheck(conn *grpc.ClientConn) Check {
+	return func(ctx context.Context) error {
+		if conn == nil {
+			return fmt.Errorf("grpc connection not set")
+		}
+
+		for {
+			state := conn.GetState()
+			switch state {
+			case connectivity.Ready:
+				return nil
+			case connectivity.Idle:
+				conn.Connect()
+			case connectivity.TransientFailure, connectivity.Shutdown:
+				return fmt.Errorf("grpc connection %s", state)
+			}
+
+			if !conn.WaitForStateChange(ctx, state) {
+				return fmt.Errorf("grpc connection %s: %v", state, ctx.Err())
+			}
+		}
+	}
+}
@@ -0,0 +1,162 @@
+package health
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"gitlab.com/grpasr/common/tests"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials/insecure"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestHealthReadiness(t *testing.T) {
+	tests.MaybeFail = tests.InitFailFunc(t)
+
+	hl := NewHealth(time.Second)
+	hl.HealthRegister("mongo", func(ctx context.Context) error { return nil })
+
+	report := hl.HealthReadiness(context.Background())
+	tests.MaybeFail("HealthReadiness_up",
+		tests.Expect(report.Status, StatusUp),
+		tests.Expect(report.Dependencies["mongo"].Status, StatusUp))
+
+	hl.HealthRegister("redis", func(ctx context.Context) error { return errors.New("connection refused") })
+
+	report = hl.HealthReadiness(context.Background())
+	tests.MaybeFail("HealthReadiness_down",
+		tests.Expect(report.Status, StatusDown),
+		tests.Expect(report.Dependencies["mongo"].Status, StatusUp),
+		tests.Expect(report.Dependencies["redis"].Status, StatusDown),
+		tests.Expect(report.Dependencies["redis"].Error, "connection refused"))
+}
+
+func TestHealthReadinessTimeout(t *testing.T) {
+	tests.MaybeFail = tests.InitFailFunc(t)
+
+	hl := NewHealth(50 * time.Millisecond)
+	hl.HealthRegister("kafka", func(ctx context.Context) error {
+		<-ctx.Done()
+		return ctx.Err()
+	})
+
+	report := hl.HealthReadiness(context.Background())
+	tests.MaybeFail("HealthReadiness_timeout",
+		tests.Expect(report.Status, StatusDown),
+		tests.Expect(report.Dependencies["kafka"].Error, context.DeadlineExceeded.Error()))
+}
+
+func TestHealthHandlers(t *testing.T) {
+	tests.MaybeFail = tests.InitFailFunc(t)
+
+	hl := NewHealth(time.Second)
+	hl.HealthRegister("redis", func(ctx context.Context) error { return errors.New("connection refused") })
+
+	rr := httptest.NewRecorder()
+	hl.LivenessHandler(rr, httptest.NewRequest(http.MethodGet, "/v1/health/live", nil))
+	tests.MaybeFail("LivenessHandler", tests.Expect(rr.Code, http.StatusOK))
+
+	rr = httptest.NewRecorder()
+	hl.ReadinessHandler(rr, httptest.NewRequest(http.MethodGet, "/v1/health/ready", nil))
+	report := Report{}
+	err := json.NewDecoder(rr.Body).Decode(&report)
+	tests.MaybeFail("ReadinessHandler", err,
+		tests.Expect(rr.Code, http.StatusServiceUnavailable),
+		tests.Expect(rr.Header().Get("Content-Type"), "application/json"),
+		tests.Expect(report.Dependencies["redis"].Status, StatusDown))
+}
+
+func TestHealthGRPC(t *testing.T) {
+	tests.MaybeFail = tests.InitFailFunc(t)
+
+	up := true
+	hl := NewHealth(time.Second)
+	hl.HealthRegister("mongo", func(ctx context.Context) error {
+		if up {
+			return nil
+		}
+		return errors.New("server selection timeout")
+	})
+	hl.HealthRegisterGRPC(grpc.NewServer(), "v1_auth.AuthManagement")
+
+	check := func(service string) healthpb.HealthCheckResponse_ServingStatus {
+		resp, err := hl.grpcServer.Check(context.Background(), &healthpb.HealthCheckRequest{Service: service})
+		if err != nil {
+			return healthpb.HealthCheckResponse_UNKNOWN
+		}
+		return resp.GetStatus()
+	}
+
+	hl.HealthReadiness(context.Background())
+	tests.MaybeFail("HealthGRPC_serving",
+		tests.Expect(check(""), healthpb.HealthCheckResponse_SERVING),
+		tests.Expect(check("v1_auth.AuthManagement"), healthpb.HealthCheckResponse_SERVING))
+
+	up = false
+	hl.HealthReadiness(context.Background())
+	tests.MaybeFail("HealthGRPC_not_serving",
+		tests.Expect(check(""), healthpb.HealthCheckResponse_NOT_SERVING),
+		tests.Expect(check("v1_auth.AuthManagement"), healthpb.HealthCheckResponse_NOT_SERVING))
+
+	up = true
+	hl.HealthShutdown()
+	report := hl.HealthReadiness(context.Background())
+	tests.MaybeFail("HealthGRPC_shutdown",
+		tests.Expect(report.Status, StatusDown),
+		tests.Expect(check(""), healthpb.HealthCheckResponse_NOT_SERVING))
+}
+
+func TestHealthWatchShutdown(t *testing.T) {
+	tests.MaybeFail = tests.InitFailFunc(t)
+
+	hl := NewHealth(time.Second)
+	hl.HealthRegisterGRPC(grpc.NewServer(), "order")
+	hl.HealthRegister("kafka", func(ctx context.Context) error { return nil })
+
+	check := func(service string) healthpb.HealthCheckResponse_ServingStatus {
+		resp, err := hl.grpcServer.Check(context.Background(), &healthpb.HealthCheckRequest{Service: service})
+		if err != nil {
+			return healthpb.HealthCheckResponse_UNKNOWN
+		}
+		return resp.GetStatus()
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		hl.HealthWatch(ctx, 10*time.Millisecond)
+		close(done)
+	}()
+
+	time.Sleep(50 * time.Millisecond)
+	tests.MaybeFail("HealthWatch_serving",
+		tests.Expect(check("order"), healthpb.HealthCheckResponse_SERVING))
+
+	// the service is not ready anymore once the watch is stopped, even if
+	// its dependencies are
+	cancel()
+	<-done
+	tests.MaybeFail("HealthWatch_shutdown",
+		tests.Expect(check("order"), healthpb.HealthCheckResponse_NOT_SERVING),
+		tests.Expect(hl.HealthReadiness(context.Background()).Status, StatusDown))
+}
+
+func TestGRPCConnCheck(t *testing.T) {
+	tests.MaybeFail = tests.InitFailFunc(t)
+
+	// nothing listen on the port, the connection never get ready
+	conn, err := grpc.Dial("127.0.0.1:1", grpc.WithTransportCredentials(insecure.NewCredentials()))
+	tests.MaybeFail("GRPCConnCheck_dial", err)
+	defer conn.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+	defer cancel()
+
+	err = GRPCConnCheck(conn)(ctx)
+	tests.MaybeFail("GRPCConnCheck", tests.Expect(err != nil, true))
+	tests.MaybeFail("GRPCConnCheck_nil", tests.Expect(GRPCConnCheck(nil)(ctx) != nil, true))
+}
@@ -0,0 +1,183 @@
+package kvconfig
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"google.golang.org/grpc/credentials"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+const timeoutDefault = 10 * time.Second
+
+// ErrUnavailable is returned when neither registry_svc nor the cache
+// could give the configuration, the local one is kept
+var ErrUnavailable = errors.New("remote configuration unavailable")
+
+// Config locate the configuration of the service in registry_svc
+type Config struct {
+	RegistryURL string // "http://localhost:4000"
+	Env         string
+	Service     string
+	Instance    string // optional
+	CacheFile   string // the last configuration received, optional
+}
+
+// Client load the configuration of a service from the config store of
+// registry_svc, the requests carry the service's jwtoken read from creds
+type Client struct {
+	cfg    Config
+	creds  credentials.PerRPCCredentials
+	client *http.Client
+}
+
+func New(cfg Config, creds credentials.PerRPCCredentials) *Client {
+	return &Client{
+		cfg:    cfg,
+		creds:  creds,
+		client: &http.Client{Timeout: timeoutDefault},
+	}
+}
+
+// resolved is the response of /kv/resolve
+type resolved struct {
+	Revision int                    `json:"revision"`
+	Values   map[string]interface{} `json:"values"`
+}
+
+// Load decode the configuration in dst, a pointer to a struct whose json
+// fields match the dotted keys("health.interval" is the interval of
+// health). dst is filled with the local values first, the keys not set
+// in registry_svc keep them. If registry_svc can not be reached the last
+// configuration received is used. It return the revision loaded
+func (c *Client) Load(ctx context.Context, dst interface{}) (int, error) {
+	r, err := c.fetch(ctx)
+	if err != nil {
+		cached, cerr := c.readCache()
+		if cerr != nil {
+			return 0, fmt.Errorf("%w: %v", ErrUnavailable, err)
+		}
+		r = cached
+	} else {
+		// the cache is a best effort
+		c.writeCache(r)
+	}
+
+	nested, err := nest(r.Values)
+	if err != nil {
+		return 0, err
+	}
+	content, err := json.Marshal(nested)
+	if err != nil {
+		return 0, err
+	}
+	if err := json.Unmarshal(content, dst); err != nil {
+		return 0, fmt.Errorf("revision %d: %v", r.Revision, err)
+	}
+
+	return r.Revision, nil
+}
+
+func (c *Client) fetch(ctx context.Context) (*resolved, error) {
+	path := []string{url.PathEscape(c.cfg.Env), url.PathEscape(c.cfg.Service)}
+	if c.cfg.Instance != "" {
+		path = append(path, url.PathEscape(c.cfg.Instance))
+	}
+	u := strings.TrimSuffix(c.cfg.RegistryURL, "/") + "/kv/resolve/" + strings.Join(path, "/")
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, u, nil)
+	if err != nil {
+		return nil, err
+	}
+	if c.creds != nil {
+		md, err := c.creds.GetRequestMetadata(ctx)
+		if err != nil {
+			return nil, err
+		}
+		for k, v := range md {
+			req.Header.Set(k, v)
+		}
+	}
+
+	resp, err := c.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		msg, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+		return nil, fmt.Errorf("registry_svc %s: %d %s", u, resp.StatusCode, strings.TrimSpace(string(msg)))
+	}
+
+	r := &resolved{}
+	if err := json.NewDecoder(resp.Body).Decode(r); err != nil {
+		return nil, err
+	}
+	return r, nil
+}
+
+func (c *Client) readCache() (*resolved, error) {
+	if c.cfg.CacheFile == "" {
+		return nil, os.ErrNotExist
+	}
+	content, err := os.ReadFile(c.cfg.CacheFile)
+	if err != nil {
+		return nil, err
+	}
+	r := &resolved{}
+	return r, json.Unmarshal(content, r)
+}
+
+func (c *Client) writeCache(r *resolved) error {
+	if c.cfg.CacheFile == "" {
+		return nil
+	}
+	content, err := json.Marshal(r)
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(c.cfg.CacheFile), 0755); err != nil {
+		return err
+	}
+	tmp := c.cfg.CacheFile + ".tmp"
+	if err := os.WriteFile(tmp, content, 0600); err != nil {
+		return err
+	}
+	return os.Rename(tmp, c.cfg.CacheFile)
+}
+
+// nest turn the dotted keys in nested objects, {"health.interval": 10}
+// is {"health": {"interval": 10}}
+func nest(values map[string]interface{}) (map[string]interface{}, error) {
+	nested := map[string]interface{}{}
+	for key, v := range values {
+		parts := strings.Split(key, ".")
+		m := nested
+		for _, p := range parts[:len(parts)-1] {
+			child, ok := m[p]
+			if !ok {
+				child = map[string]interface{}{}
+				m[p] = child
+			}
+			cm, ok := child.(map[string]interface{})
+			if !ok {
+				return nil, fmt.Errorf("key %s conflict with the value of %s", key, p)
+			}
+			m = cm
+		}
+		last := parts[len(parts)-1]
+		if _, ok := m[last]; ok {
+			return nil, fmt.Errorf("key %s conflict with its sub-keys", key)
+		}
+		m[last] = v
+	}
+	return nested, nil
+}
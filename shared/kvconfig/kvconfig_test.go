@@ -0,0 +1,82 @@
+package kvconfig
+
+import (
+	"context"
+	"errors"
+	"gitlab.com/grpasr/common/tests"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"testing"
+)
+
+type testConfig struct {
+	Health struct {
+		Timeout  int `json:"timeout"`
+		Interval int `json:"interval"`
+	} `json:"health"`
+	Discovery struct {
+		URL string `json:"url"`
+	} `json:"discovery"`
+}
+
+func TestLoad(t *testing.T) {
+	tests.MaybeFail = tests.InitFailFunc(t)
+
+	up := true
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !up || r.URL.Path != "/kv/resolve/development/broker/broker-1" {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		w.Write([]byte(`{"revision": 7, "values": {"health.interval": 20, "discovery.url": "http://registry:4000"}}`))
+	}))
+	defer srv.Close()
+
+	cfg := Config{
+		RegistryURL: srv.URL,
+		Env:         "development",
+		Service:     "broker",
+		Instance:    "broker-1",
+		CacheFile:   filepath.Join(t.TempDir(), "remote", "config.json"),
+	}
+
+	// the local values
+	conf := testConfig{}
+	conf.Health.Timeout = 3
+	conf.Health.Interval = 10
+
+	revision, err := New(cfg, nil).Load(context.Background(), &conf)
+	tests.MaybeFail("Load", err,
+		tests.Expect(revision, 7),
+		tests.Expect(conf.Health.Timeout, 3),
+		tests.Expect(conf.Health.Interval, 20),
+		tests.Expect(conf.Discovery.URL, "http://registry:4000"),
+	)
+
+	// registry_svc is down, the cache is used
+	up = false
+	cached := testConfig{}
+	revision, err = New(cfg, nil).Load(context.Background(), &cached)
+	tests.MaybeFail("Load_cached", err,
+		tests.Expect(revision, 7),
+		tests.Expect(cached.Health.Interval, 20),
+	)
+
+	cfg.CacheFile = ""
+	_, err = New(cfg, nil).Load(context.Background(), &cached)
+	tests.MaybeFail("Load_unavailable", tests.Expect(errors.Is(err, ErrUnavailable), true))
+}
+
+func TestNest(t *testing.T) {
+	tests.MaybeFail = tests.InitFailFunc(t)
+
+	nested, err := nest(map[string]interface{}{"a.b.c": 1.0, "a.d": "x", "e": true})
+	tests.MaybeFail("nest", err,
+		tests.Expect(nested["a"].(map[string]interface{})["b"].(map[string]interface{})["c"], 1.0),
+		tests.Expect(nested["e"], true),
+	)
+
+	_, err = nest(map[string]interface{}{"a": 1.0, "a.b": 2.0})
+	tests.MaybeFail("nest_conflict", tests.Expect(err != nil, true))
+}
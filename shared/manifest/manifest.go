@@ -0,0 +1,101 @@
+package manifest
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+)
+
+const timeoutDefault = 10 * time.Second
+
+// Digest compute the registry_svc's digest of the files in dir and its
+// sub-directories: the sha256 of the `sha256sum` lines("<hex>  <name>\n")
+// ordered by name, the name being the file's base name. It is the hex, as
+// in the registry's ETag
+func Digest(dir string) (string, error) {
+	type file struct{ name, sum string }
+	files := []file{}
+
+	err := filepath.Walk(dir, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if !info.Mode().IsRegular() {
+			return nil
+		}
+
+		f, err := os.Open(path)
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+
+		h := sha256.New()
+		if _, err := io.Copy(h, f); err != nil {
+			return err
+		}
+		files = append(files, file{filepath.Base(path), hex.EncodeToString(h.Sum(nil))})
+		return nil
+	})
+	if err != nil {
+		return "", err
+	}
+	if len(files) == 0 {
+		return "", fmt.Errorf("no files in %s", dir)
+	}
+
+	sort.Slice(files, func(i, j int) bool {
+		if files[i].name != files[j].name {
+			return files[i].name < files[j].name
+		}
+		return files[i].sum < files[j].sum
+	})
+
+	h := sha256.New()
+	for _, f := range files {
+		fmt.Fprintf(h, "%s  %s\n", f.sum, f.name)
+	}
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
+
+// UpToDate ask the registry if the files of dir are the ones of the
+// download url, a conditional request on its manifest answered with 304.
+// Any failure return false, the files are then downloaded again
+func UpToDate(ctx context.Context, url, token, dir string) bool {
+	digest, err := Digest(dir)
+	if err != nil {
+		return false
+	}
+
+	sep := "?"
+	if strings.Contains(url, "?") {
+		sep = "&"
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, timeoutDefault)
+	defer cancel()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url+sep+"manifest", nil)
+	if err != nil {
+		return false
+	}
+	req.Header.Set("Authorization", "Bearer "+token)
+	req.Header.Set("If-None-Match", `"`+digest+`"`)
+
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return false
+	}
+	defer resp.Body.Close()
+	io.Copy(io.Discard, resp.Body)
+
+	return resp.StatusCode == http.StatusNotModified
+}
@@ -0,0 +1,64 @@
+package manifest
+
+import (
+	"context"
+	"gitlab.com/grpasr/common/tests"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestDigest(t *testing.T) {
+	tests.MaybeFail = tests.InitFailFunc(t)
+
+	dir := t.TempDir()
+	_ = os.WriteFile(filepath.Join(dir, "Person.proto"), []byte("a"), 0600)
+	_ = os.MkdirAll(filepath.Join(dir, "sub"), 0700)
+	_ = os.WriteFile(filepath.Join(dir, "sub", "Address.proto"), []byte("b"), 0600)
+
+	// the sha256 of "<sha256(b)>  Address.proto\n<sha256(a)>  Person.proto\n"
+	digest, err := Digest(dir)
+	tests.MaybeFail("Digest", err,
+		tests.Expect(digest, "fb493e7ceccd3a40b66d299b3ae517f8d9fdd51caa29c92defa966ecd278be8f"))
+
+	_, err = Digest(t.TempDir())
+	tests.MaybeFail("Digest_empty", tests.Expect(err != nil, true))
+}
+
+func TestUpToDate(t *testing.T) {
+	tests.MaybeFail = tests.InitFailFunc(t)
+
+	dir := t.TempDir()
+	_ = os.WriteFile(filepath.Join(dir, "Person.proto"), []byte("a"), 0600)
+	digest, _ := Digest(dir)
+
+	etag := `"` + digest + `"`
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, isManifest := r.URL.Query()["manifest"]
+		if !isManifest || r.Header.Get("Authorization") != "Bearer jwt" {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		if r.Header.Get("If-None-Match") == etag {
+			w.WriteHeader(http.StatusNotModified)
+			return
+		}
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer srv.Close()
+
+	upToDate := UpToDate(context.Background(), srv.URL+"/grpc/v1/name", "jwt", dir)
+	changed := UpToDate(context.Background(), srv.URL+"/grpc/v1/name", "other", dir)
+
+	_ = os.WriteFile(filepath.Join(dir, "Person.proto"), []byte("changed"), 0600)
+	modified := UpToDate(context.Background(), srv.URL+"/grpc/v1/name", "jwt", dir)
+	missing := UpToDate(context.Background(), srv.URL+"/grpc/v1/name", "jwt", filepath.Join(dir, "none"))
+
+	tests.MaybeFail("UpToDate",
+		tests.Expect(upToDate, true),
+		tests.Expect(changed, false),
+		tests.Expect(modified, false),
+		tests.Expect(missing, false))
+}
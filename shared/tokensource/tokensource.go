@@ -0,0 +1,232 @@
+package tokensource
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	// the token is refreshed once refreshRatio of its lifetime is elapsed
+	refreshRatio = 0.8
+	// the path of the refresh endpoint, under the auth_svc url
+	refreshPath = "/refreshopenid"
+
+	retryDelayDefault    = 2 * time.Second
+	maxRetryDelayDefault = 5 * time.Minute
+	timeoutDefault       = 10 * time.Second
+)
+
+var ErrExpired = errors.New("jwtoken expired")
+
+// Config set the refresh of the token, the client credentials are the
+// ones the service login with
+type Config struct {
+	AuthSvcURL    string // "http://localhost:9096/v1"
+	ClientID      string
+	ClientSecret  string
+	RetryDelay    time.Duration // first retry delay, doubled on each failure
+	MaxRetryDelay time.Duration
+	Timeout       time.Duration // of a refresh request
+}
+
+// TokenSource hold the service's jwt_token and refresh it through the
+// auth_svc /v1/refreshopenid ahead of its expiry, it is safe for
+// concurrent use, readers always get the last valid token
+type TokenSource struct {
+	cfg      Config
+	client   *http.Client
+	token    string
+	issuedAt time.Time
+	expiry   time.Time
+	mu       sync.RWMutex
+	// refreshMu make sure only one refresh is in flight
+	refreshMu sync.Mutex
+}
+
+// New return the TokenSource of token, the token obtained at login
+func New(token string, cfg Config) (*TokenSource, error) {
+	if cfg.RetryDelay <= 0 {
+		cfg.RetryDelay = retryDelayDefault
+	}
+	if cfg.MaxRetryDelay <= 0 {
+		cfg.MaxRetryDelay = maxRetryDelayDefault
+	}
+	if cfg.Timeout <= 0 {
+		cfg.Timeout = timeoutDefault
+	}
+
+	ts := &TokenSource{
+		cfg:    cfg,
+		client: &http.Client{Timeout: cfg.Timeout},
+	}
+	if err := ts.set(token); err != nil {
+		return nil, err
+	}
+	return ts, nil
+}
+
+// Token return the current token
+func (ts *TokenSource) Token() string {
+	ts.mu.RLock()
+	defer ts.mu.RUnlock()
+	return ts.token
+}
+
+// Expiry return the expiry of the current token
+func (ts *TokenSource) Expiry() time.Time {
+	ts.mu.RLock()
+	defer ts.mu.RUnlock()
+	return ts.expiry
+}
+
+// Valid return ErrExpired if the current token is expired
+func (ts *TokenSource) Valid() error {
+	if !ts.Expiry().After(time.Now()) {
+		return ErrExpired
+	}
+	return nil
+}
+
+// refreshAt is when the current token should be refreshed
+func (ts *TokenSource) refreshAt() time.Time {
+	ts.mu.RLock()
+	defer ts.mu.RUnlock()
+	lifetime := ts.expiry.Sub(ts.issuedAt)
+	return ts.issuedAt.Add(time.Duration(float64(lifetime) * refreshRatio))
+}
+
+// Refresh exchange the current token for a new one, the current token
+// is kept if the refresh fails
+func (ts *TokenSource) Refresh(ctx context.Context) error {
+	ts.refreshMu.Lock()
+	defer ts.refreshMu.Unlock()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost,
+		strings.TrimSuffix(ts.cfg.AuthSvcURL, "/")+refreshPath, nil)
+	if err != nil {
+		return err
+	}
+	req.SetBasicAuth(ts.cfg.ClientID, ts.cfg.ClientSecret)
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.AddCookie(&http.Cookie{Name: "jwt_token", Value: ts.Token()})
+
+	resp, err := ts.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(io.LimitReader(resp.Body, 1<<16))
+	if err != nil {
+		return err
+	}
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("refresh jwtoken failed, status %d: %s", resp.StatusCode, body)
+	}
+
+	// the new token is returned as a json string
+	var token string
+	if err := json.Unmarshal(body, &token); err != nil {
+		return fmt.Errorf("refresh jwtoken, invalid response: %w", err)
+	}
+
+	return ts.set(token)
+}
+
+// Run refresh the token ahead of its expiry until ctx is done, a failed
+// refresh is retried with a backoff
+func (ts *TokenSource) Run(ctx context.Context) {
+	delay := ts.cfg.RetryDelay
+	for {
+		wait := time.Until(ts.refreshAt())
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(wait):
+		}
+
+		if err := ts.Refresh(ctx); err != nil {
+			if ctx.Err() != nil {
+				return
+			}
+			log.Printf("tokensource: %v, retry in %v", err, delay)
+
+			select {
+			case <-ctx.Done():
+				return
+			case <-time.After(delay):
+			}
+			delay *= 2
+			if delay > ts.cfg.MaxRetryDelay {
+				delay = ts.cfg.MaxRetryDelay
+			}
+			continue
+		}
+
+		delay = ts.cfg.RetryDelay
+		log.Printf("tokensource: jwtoken refreshed, expires at %v", ts.Expiry())
+	}
+}
+
+// GetRequestMetadata add the token to the grpc requests, so the
+// TokenSource can be used as grpc.WithPerRPCCredentials
+func (ts *TokenSource) GetRequestMetadata(ctx context.Context, uri ...string) (map[string]string, error) {
+	return map[string]string{"authorization": "Bearer " + ts.Token()}, nil
+}
+
+// RequireTransportSecurity make sure the token is only sent over tls
+func (ts *TokenSource) RequireTransportSecurity() bool {
+	return true
+}
+
+// set replace the token, its expiry is read from the exp claim
+func (ts *TokenSource) set(token string) error {
+	token = strings.Trim(strings.TrimSpace(token), `"`)
+	exp, err := expiryOf(token)
+	if err != nil {
+		return err
+	}
+
+	ts.mu.Lock()
+	ts.token = token
+	ts.issuedAt = time.Now()
+	ts.expiry = exp
+	ts.mu.Unlock()
+	return nil
+}
+
+// expiryOf read the exp claim of the jwt, the signature is checked
+// by the services the token is sent to
+func expiryOf(token string) (time.Time, error) {
+	parts := strings.Split(token, ".")
+	if len(parts) != 3 {
+		return time.Time{}, errors.New("invalid jwtoken")
+	}
+
+	payload, err := base64.RawURLEncoding.DecodeString(parts[1])
+	if err != nil {
+		return time.Time{}, fmt.Errorf("invalid jwtoken payload: %w", err)
+	}
+
+	var claims struct {
+		Exp int64 `json:"exp"`
+	}
+	if err := json.Unmarshal(payload, &claims); err != nil {
+		return time.Time{}, fmt.Errorf("invalid jwtoken claims: %w", err)
+	}
+	if claims.Exp == 0 {
+		return time.Time{}, errors.New("jwtoken without exp")
+	}
+
+	return time.Unix(claims.Exp, 0), nil
+}
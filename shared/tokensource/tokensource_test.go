@@ -0,0 +1,152 @@
+package tokensource
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"gitlab.com/grpasr/common/tests"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+)
+
+const (
+	clientID     = "testSvc"
+	clientSecret = "testSvcSecret"
+)
+
+func newJWT(exp time.Time, n int) string {
+	header := base64.RawURLEncoding.EncodeToString([]byte(`{"alg":"HS256","typ":"JWT"}`))
+	payload := base64.RawURLEncoding.EncodeToString([]byte(fmt.Sprintf(`{"exp":%d,"sub":"%s","n":%d}`, exp.Unix(), clientID, n)))
+	return header + "." + payload + ".signature"
+}
+
+// refreshStandIn is the auth_svc /v1/refreshopenid, it answer fails
+// times with a 403 before refreshing the tokens
+type refreshStandIn struct {
+	srv      *httptest.Server
+	fails    int
+	hits     int
+	lifetime time.Duration
+	sync.Mutex
+}
+
+func newRefreshStandIn(fails int, lifetime time.Duration) *refreshStandIn {
+	si := &refreshStandIn{fails: fails, lifetime: lifetime}
+	si.srv = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		si.Lock()
+		defer si.Unlock()
+		si.hits++
+
+		id, secret, ok := r.BasicAuth()
+		cookie, err := r.Cookie("jwt_token")
+		if !ok || id != clientID || secret != clientSecret || err != nil || cookie.Value == "" || r.URL.Path != "/v1/refreshopenid" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		if si.hits <= si.fails {
+			w.WriteHeader(http.StatusForbidden)
+			return
+		}
+
+		json.NewEncoder(w).Encode(newJWT(time.Now().Add(si.lifetime), si.hits))
+	}))
+	return si
+}
+
+func (si *refreshStandIn) count() int {
+	si.Lock()
+	defer si.Unlock()
+	return si.hits
+}
+
+func (si *refreshStandIn) config() Config {
+	return Config{
+		AuthSvcURL:    si.srv.URL + "/v1",
+		ClientID:      clientID,
+		ClientSecret:  clientSecret,
+		RetryDelay:    10 * time.Millisecond,
+		MaxRetryDelay: 40 * time.Millisecond,
+	}
+}
+
+func TestTokenSourceRefresh(t *testing.T) {
+	tests.MaybeFail = tests.InitFailFunc(t)
+
+	si := newRefreshStandIn(1, time.Hour)
+	defer si.srv.Close()
+
+	exp := time.Now().Add(time.Minute).Truncate(time.Second)
+	token := newJWT(exp, 0)
+	ts, err := New(`"`+token+`"`, si.config())
+	tests.MaybeFail("New", err,
+		tests.Expect(ts.Token(), token),
+		tests.Expect(ts.Expiry().Equal(exp), true),
+		tests.Expect(ts.Valid(), nil))
+
+	// a failed refresh keep the current token
+	err = ts.Refresh(context.Background())
+	tests.MaybeFail("Refresh_failed", tests.Expect(err != nil, true), tests.Expect(ts.Token(), token))
+
+	err = ts.Refresh(context.Background())
+	tests.MaybeFail("Refresh", err,
+		tests.Expect(ts.Token() != token, true),
+		tests.Expect(ts.Expiry().After(exp), true))
+
+	md, _ := ts.GetRequestMetadata(context.Background())
+	tests.MaybeFail("GetRequestMetadata", tests.Expect(md["authorization"], "Bearer "+ts.Token()))
+
+	_, err = New("notAJWT", si.config())
+	tests.MaybeFail("New_invalid", tests.Expect(err != nil, true))
+
+	ts, _ = New(newJWT(time.Now().Add(-time.Second), 0), si.config())
+	tests.MaybeFail("Valid_expired", tests.Expect(ts.Valid(), ErrExpired))
+}
+
+func TestTokenSourceRun(t *testing.T) {
+	tests.MaybeFail = tests.InitFailFunc(t)
+
+	// the first two refreshes fail, then each token live an hour
+	si := newRefreshStandIn(2, time.Hour)
+	defer si.srv.Close()
+
+	token := newJWT(time.Now().Add(time.Second), 0)
+	ts, _ := New(token, si.config())
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		ts.Run(ctx)
+		close(done)
+	}()
+
+	// the readers are never blocked by the refreshes
+	var wg sync.WaitGroup
+	for i := 0; i < 4; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for j := 0; j < 100; j++ {
+				_ = ts.Token()
+				time.Sleep(time.Millisecond)
+			}
+		}()
+	}
+	wg.Wait()
+
+	deadline := time.Now().Add(3 * time.Second)
+	for ts.Token() == token && time.Now().Before(deadline) {
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	cancel()
+	<-done
+
+	tests.MaybeFail("Run",
+		tests.Expect(ts.Token() != token, true),
+		tests.Expect(si.count(), 3),
+		tests.Expect(ts.Expiry().After(time.Now().Add(50*time.Minute)), true))
+}